@@ -10,7 +10,6 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
@@ -63,12 +62,19 @@ func StartConfigWatcher(ctx context.Context, hostname, daemonName string, conf *
 	}()
 
 	go func() {
-		r := routes.NewRouter(apiRoutes(ctx, kfrtconfg))
+		r := routes.NewRouter(instrumentRoutes(apiRoutes(ctx, kfrtconfg)))
 		if conf.SwaggerApiEnabled {
 			r.Mount("/swagger", httpSwagger.WrapHandler)
 		}
 
-		s.l3afdServer.Handler = r
+		handler := routes.RateLimit(conf.L3afConfigsRateLimit, conf.L3afConfigsRateBurst,
+			routes.LimitBodySize(conf.L3afConfigsMaxBodySize,
+				routes.Compress(conf.L3afConfigsMaxBodySize, r)))
+		s.l3afdServer.Handler = routes.AllowCIDRs(conf.L3afConfigsAllowedCIDRs, handler)
+
+		if len(conf.L3afConfigsSocket) > 0 {
+			go serveSocket(conf.L3afConfigsSocket, conf.L3afConfigsSocketGroup, handler)
+		}
 
 		// As per design discussion when mTLS flag is not set and not listening on loopback or localhost
 		if !conf.MTLSEnabled && !isLoopback(conf.L3afConfigsRestAPIAddr) && conf.Environment == config.ENV_PROD {
@@ -78,7 +84,7 @@ func StartConfigWatcher(ctx context.Context, hostname, daemonName string, conf *
 		if conf.MTLSEnabled {
 			log.Info().Msgf("l3afd server listening with mTLS - %s ", conf.L3afConfigsRestAPIAddr)
 			// Create a CA certificate pool and add client ca's to it
-			caCert, err := ioutil.ReadFile(path.Join(conf.MTLSCertDir, conf.MTLSCACertFilename))
+			caCert, err := os.ReadFile(path.Join(conf.MTLSCertDir, conf.MTLSCACertFilename))
 			if err != nil {
 				log.Fatal().Err(err).Msgf("client CA %s file not found", conf.MTLSCACertFilename)
 			}
@@ -0,0 +1,42 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GetPendingApplies Returns the updates queued until the apply window of the program opens
+// @Summary Returns the updates queued until the apply window of the program opens
+// @Description Returns the version upgrades and chain reorders of the programs queued outside their apply window
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.L3afDPendingApply
+// @Router /l3af/applies/v1 [get]
+func GetPendingApplies(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.PendingApplies(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
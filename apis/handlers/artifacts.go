@@ -0,0 +1,57 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+)
+
+// GetSharedArtifact Downloads a verified artifact of the node cache
+// @Summary Downloads a verified artifact of the node cache
+// @Description Returns the archive of the verified artifact to the peers of the site presenting the shared bearer token, when artifact sharing is enabled
+// @Produce  application/octet-stream
+// @Param name path string true "program name"
+// @Param progVersion path string true "program version"
+// @Param platform path string true "platform of the artifact"
+// @Param artifact path string true "artifact name"
+// @Success 200
+// @Router /l3af/artifacts/v1/{name}/{progVersion}/{platform}/{artifact} [get]
+func GetSharedArtifact(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	name, artifact := chi.URLParam(r, "name"), chi.URLParam(r, "artifact")
+	data, tag, err := kfcfgs.SharedArtifact(token, name, chi.URLParam(r, "progVersion"), chi.URLParam(r, "platform"), artifact)
+	if err != nil {
+		mesg := fmt.Sprintf("failed to share artifact %s of program %s with %s: %v", artifact, name, r.RemoteAddr, err)
+		statusCode := http.StatusNotFound
+		switch {
+		case errors.Is(err, kf.ErrArtifactPeerUnauthorized):
+			statusCode = http.StatusUnauthorized
+		case !errors.Is(err, kf.ErrArtifactNotShared):
+			statusCode = http.StatusInternalServerError
+		}
+		log.Warn().Msg(mesg)
+		w.WriteHeader(statusCode)
+		if _, err := w.Write([]byte(mesg)); err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	if len(tag) > 0 {
+		w.Header().Add("ETag", tag)
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		log.Warn().Msgf("Failed to write response bytes: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+)
+
+// GetChainGraph Returns the graph of the programs chained on the interface
+// @Summary Returns the graph of the programs chained on the interface
+// @Description Returns the root program, the chained programs with their seq ids and prog ids, and the prog maps and shared maps linking them, as json or in the Graphviz DOT language
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param format query string false "json or dot, json by default"
+// @Success 200 {object} models.L3afDChainGraph
+// @Router /l3af/chains/v1/{iface}/graph [get]
+func GetChainGraph(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	iface := chi.URLParam(r, "iface")
+	format := r.URL.Query().Get("format")
+	if len(format) == 0 {
+		format = "json"
+	}
+	if format != "json" && format != "dot" {
+		w.Header().Add("Content-Type", "application/json")
+		mesg = "format must be json or dot"
+		log.Error().Msg(mesg)
+		statusCode = http.StatusBadRequest
+		return
+	}
+
+	graph, err := kfcfgs.ChainGraph(iface)
+	if err != nil {
+		w.Header().Add("Content-Type", "application/json")
+		mesg = err.Error()
+		log.Error().Err(err).Msgf("failed to build the chain graph of iface %s", iface)
+		statusCode = http.StatusInternalServerError
+		if errors.Is(err, kf.ErrChainNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		return
+	}
+
+	if format == "dot" {
+		w.Header().Add("Content-Type", "text/vnd.graphviz")
+		mesg = kf.ChainGraphDOT(graph)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	resp, err := json.MarshalIndent(graph, "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
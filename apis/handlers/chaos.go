@@ -0,0 +1,112 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// InjectFault Injects a fault into the NF lifecycle
+// @Summary Injects a fault into the NF lifecycle
+// @Description Injects artifact download failure, map pin delay or kills the NF process, fault injection must be enabled in the config
+// @Accept  json
+// @Produce  json
+// @Param fault body models.L3afDFault true "fault"
+// @Success 200
+// @Router /l3af/chaos/v1 [post]
+func InjectFault(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var f models.L3afDFault
+		if err := json.Unmarshal(bodyBuffer, &f); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		if err := kfcfg.InjectFault(f); err != nil {
+			mesg = fmt.Sprintf("failed to inject fault: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+	}
+}
+
+// ClearFaults Removes the pending faults
+// @Summary Removes the pending faults
+// @Description Removes the pending faults
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Router /l3af/chaos/v1 [delete]
+func ClearFaults(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		kfcfg.ClearFaults()
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetFaults Returns the pending faults
+// @Summary Returns the pending faults
+// @Description Returns the pending faults
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Router /l3af/chaos/v1 [get]
+func GetFaults(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.Faults(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
@@ -0,0 +1,42 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GetDoctor Runs the preflight diagnostics of the node
+// @Summary Runs the preflight diagnostics of the node
+// @Description Checks the bpf filesystem, kernel version, BTF, capabilities, memlock limit, XDP support of the driver of the configured interfaces, KF repo reachability and free disk space
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.L3afDDoctorReport
+// @Router /l3af/doctor/v1 [get]
+func GetDoctor(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.Doctor(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
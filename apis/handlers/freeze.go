@@ -0,0 +1,98 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// GetConfigFreeze Returns the config freeze state
+// @Summary Returns the config freeze state
+// @Description Returns whether mutating config operations are rejected, the reason and the time of the freeze
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.L3afDFreeze
+// @Router /l3af/freeze/v1 [get]
+func GetConfigFreeze(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.ConfigFreeze(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
+
+// SetConfigFreeze Freezes or unfreezes the config
+// @Summary Freezes or unfreezes the config
+// @Description Config applies and admin operations changing the programs are rejected with 423 Locked while frozen, supervision and metrics continue
+// @Accept  json
+// @Produce  json
+// @Param freeze body models.L3afDFreeze true "frozen and reason"
+// @Success 200 {object} models.L3afDFreeze
+// @Router /l3af/freeze/v1 [put]
+func SetConfigFreeze(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var f models.L3afDFreeze
+		if err := json.Unmarshal(bodyBuffer, &f); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		resp, err := json.MarshalIndent(kfcfg.SetConfigFreeze(f.Frozen, f.Reason, r.RemoteAddr), "", "  ")
+		if err != nil {
+			mesg = "internal server error"
+			log.Error().Msgf("failed to marshal response: %v", err)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+		mesg = string(resp)
+	}
+}
@@ -6,6 +6,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	chi "github.com/go-chi/chi/v5"
 	"github.com/l3af-project/l3afd/kf"
@@ -49,6 +50,7 @@ func GetConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setAppliedGeneration(w)
 	resp, err := json.MarshalIndent(kfcfgs.EBPFPrograms(iface), "", "  ")
 	if err != nil {
 		mesg = "internal server error"
@@ -80,6 +82,7 @@ func GetConfigAll(w http.ResponseWriter, r *http.Request) {
 		}
 	}(&mesg, &statusCode)
 
+	setAppliedGeneration(w)
 	resp, err := json.MarshalIndent(kfcfgs.EBPFProgramsAll(), "", "  ")
 	if err != nil {
 		mesg = "internal server error"
@@ -89,3 +92,42 @@ func GetConfigAll(w http.ResponseWriter, r *http.Request) {
 	}
 	mesg = string(resp)
 }
+
+// GetConfigEffective Returns the configuration l3afd acts on with the defaults resolved
+// @Summary Returns the configuration l3afd acts on with the defaults resolved
+// @Description Returns the configs of the interfaces with the assigned seq ids, the l3afd defaults of the unset program fields, the artifact urls of the platform, the paused programs, the configs waiting for the link and the updates waiting for the apply window
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.L3afDEffectiveConfig
+// @Router /l3af/configs/v1/effective [get]
+func GetConfigEffective(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	setAppliedGeneration(w)
+	resp, err := json.MarshalIndent(kfcfgs.EffectiveConfig(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
+
+// setAppliedGeneration - sets the last applied config generation header of the response
+func setAppliedGeneration(w http.ResponseWriter) {
+	if applied, ok := kfcfgs.AppliedGeneration(); ok {
+		w.Header().Set(ConfigGenerationHeader, strconv.FormatUint(applied.Generation, 10))
+	}
+}
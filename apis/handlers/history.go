@@ -0,0 +1,113 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// GetConfigHistory Returns the revisions of the config history
+// @Summary Returns the revisions of the config history
+// @Description Returns the revisions of the last applied configs without the configs, the latest first
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.L3afDConfigRevision
+// @Router /l3af/configs/v1/history [get]
+func GetConfigHistory(w http.ResponseWriter, r *http.Request) {
+	writeHistoryResponse(w, func() (interface{}, int, error) {
+		return kfcfgs.ConfigHistory(), http.StatusOK, nil
+	})
+}
+
+// GetConfigRevision Returns a revision of the config history
+// @Summary Returns a revision of the config history
+// @Description Returns the configs applied in the revision, 0 is the latest revision
+// @Accept  json
+// @Produce  json
+// @Param revision path integer true "revision"
+// @Success 200 {object} models.L3afDConfigRevision
+// @Failure 404 "revision is not in the history"
+// @Router /l3af/configs/v1/history/{revision} [get]
+func GetConfigRevision(w http.ResponseWriter, r *http.Request) {
+	writeHistoryResponse(w, func() (interface{}, int, error) {
+		revision, err := strconv.ParseUint(chi.URLParam(r, "revision"), 10, 64)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid revision: %w", err)
+		}
+		rev, err := kfcfgs.ConfigRevision(revision)
+		if err != nil {
+			return nil, http.StatusNotFound, err
+		}
+		return rev, http.StatusOK, nil
+	})
+}
+
+// GetConfigDiff Returns the changes of the programs between two revisions of the config history
+// @Summary Returns the changes of the programs between two revisions of the config history
+// @Description Returns the added, removed and changed programs with the changed fields, to defaults to the latest revision and from to the revision before to
+// @Accept  json
+// @Produce  json
+// @Param from query integer false "revision compared from"
+// @Param to query integer false "revision compared to"
+// @Success 200 {object} models.L3afDConfigDiff
+// @Failure 404 "revision is not in the history"
+// @Router /l3af/configs/v1/history/diff [get]
+func GetConfigDiff(w http.ResponseWriter, r *http.Request) {
+	writeHistoryResponse(w, func() (interface{}, int, error) {
+		var revisions [2]uint64
+		for i, name := range []string{"from", "to"} {
+			v := r.URL.Query().Get(name)
+			if len(v) == 0 {
+				continue
+			}
+			var err error
+			if revisions[i], err = strconv.ParseUint(v, 10, 64); err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("invalid %s revision: %w", name, err)
+			}
+		}
+		diff, err := kfcfgs.DiffConfigRevisions(revisions[0], revisions[1])
+		if err != nil {
+			return nil, http.StatusNotFound, err
+		}
+		return diff, http.StatusOK, nil
+	})
+}
+
+// writeHistoryResponse - writes the result of the config history query as JSON
+func writeHistoryResponse(w http.ResponseWriter, query func() (interface{}, int, error)) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	result, code, err := query()
+	if err != nil {
+		mesg = err.Error()
+		log.Error().Msg(mesg)
+		statusCode = code
+		return
+	}
+	resp, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
@@ -0,0 +1,79 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// GetIncidents Returns the crash incidents of the NF processes
+// @Summary Returns the crash incidents of the NF processes
+// @Description Returns the incidents of the crash forensics bundles kept on the node, the latest first
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.L3afDNFIncident
+// @Router /l3af/incidents/v1 [get]
+func GetIncidents(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	incidents, err := kfcfgs.Incidents()
+	if err != nil {
+		mesg = fmt.Sprintf("failed to list incidents: %v", err)
+		log.Error().Msg(mesg)
+		statusCode = http.StatusNotFound
+		return
+	}
+	resp, err := json.MarshalIndent(incidents, "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
+
+// GetIncidentBundle Downloads the crash forensics bundle of an incident
+// @Summary Downloads the crash forensics bundle of an incident
+// @Description Returns the gzipped tar bundle with the exit status, last output lines, BPF kernel log lines and map details of the crashed NF
+// @Produce  application/gzip
+// @Param id path string true "incident id"
+// @Success 200
+// @Router /l3af/incidents/v1/{id} [get]
+func GetIncidentBundle(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	bundle, err := kfcfgs.IncidentBundle(id)
+	if err != nil {
+		mesg := fmt.Sprintf("failed to read incident bundle %s: %v", id, err)
+		log.Error().Msg(mesg)
+		w.WriteHeader(http.StatusNotFound)
+		if _, err := w.Write([]byte(mesg)); err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/gzip")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".tar.gz"))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(bundle); err != nil {
+		log.Warn().Msgf("Failed to write response bytes: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// GetLogLevels Returns the log levels
+// @Summary Returns the log levels
+// @Description Returns the level of the l3afd logs and the level overrides of the kf, apis and stats modules
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.L3afDLogLevels
+// @Router /l3af/logging/v1 [get]
+func GetLogLevels(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kf.LogLevels(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
+
+// SetLogLevels Changes the log levels
+// @Summary Changes the log levels
+// @Description Changes the level of the l3afd logs when set and the level overrides of the modules in the request without restarting l3afd, an empty module level removes the override
+// @Accept  json
+// @Produce  json
+// @Param levels body models.L3afDLogLevels true "level and module levels"
+// @Success 200 {object} models.L3afDLogLevels
+// @Router /l3af/logging/v1 [put]
+func SetLogLevels(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var req models.L3afDLogLevels
+		if err := json.Unmarshal(bodyBuffer, &req); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		levels, err := kfcfg.SetLogLevels(req, r.RemoteAddr)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to set log levels: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			if errors.Is(err, kf.ErrInvalidLogLevel) {
+				statusCode = http.StatusBadRequest
+			}
+			return
+		}
+
+		resp, err := json.MarshalIndent(levels, "", "  ")
+		if err != nil {
+			mesg = "internal server error"
+			log.Error().Msgf("failed to marshal response: %v", err)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+		mesg = string(resp)
+	}
+}
+
+// GetLogLevel Returns the log level and the programs with debug enabled
+// @Summary Returns the log level and the programs with debug enabled
+// @Description Returns the level of the l3afd logs and the programs whose map lookups and chain updates are logged regardless of the level
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.L3afDLogLevel
+// @Router /l3af/loglevel/v1 [get]
+func GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kf.LogLevel(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
+
+// SetLogLevel Changes the log level and the programs with debug enabled
+// @Summary Changes the log level and the programs with debug enabled
+// @Description Changes the level of the l3afd logs when set and replaces the programs with debug enabled when set, so a faulty program is debugged without restarting l3afd and losing its state
+// @Accept  json
+// @Produce  json
+// @Param level body models.L3afDLogLevel true "level and debug programs"
+// @Success 200 {object} models.L3afDLogLevel
+// @Router /l3af/loglevel/v1 [put]
+func SetLogLevel(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var req models.L3afDLogLevel
+		if err := json.Unmarshal(bodyBuffer, &req); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		level, err := kfcfg.SetLogLevel(req, r.RemoteAddr)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to set log level: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			if errors.Is(err, kf.ErrInvalidLogLevel) {
+				statusCode = http.StatusBadRequest
+			}
+			return
+		}
+
+		resp, err := json.MarshalIndent(level, "", "  ")
+		if err != nil {
+			mesg = "internal server error"
+			log.Error().Msgf("failed to marshal response: %v", err)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+		mesg = string(resp)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// entries written between the flushes of the streamed response
+const mapDumpFlushEntries = 100
+
+// GetMapEntries Returns the entries of an eBPF map of a running program
+// @Summary Returns the entries of an eBPF map of a running program
+// @Description Streams the map entries matching the key prefix and range filters, keys, values and filters are hex encoded in the map layout. The continue token of the response resumes the dump when the limit is reached.
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param direction path string true "direction xdpingress, ingress or egress"
+// @Param program path string true "program name"
+// @Param map query string true "map name"
+// @Param prefix query string false "hex key prefix"
+// @Param start query string false "hex start key, inclusive"
+// @Param end query string false "hex end key, exclusive"
+// @Param limit query int false "maximum number of entries"
+// @Param continue query string false "continuation token"
+// @Success 200
+// @Router /l3af/maps/v1/{iface}/{direction}/{program} [get]
+func GetMapEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+
+	writeError := func(statusCode int, mesg string) {
+		log.Error().Msg(mesg)
+		w.WriteHeader(statusCode)
+		if _, err := w.Write([]byte(mesg)); err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}
+
+	iface := chi.URLParam(r, "iface")
+	direction := chi.URLParam(r, "direction")
+	program := chi.URLParam(r, "program")
+	query := r.URL.Query()
+	mapName := query.Get("map")
+	if len(iface) == 0 || len(direction) == 0 || len(program) == 0 || len(mapName) == 0 {
+		writeError(http.StatusBadRequest, "iface, direction, program and map are required")
+		return
+	}
+
+	filter, err := mapDumpFilter(r)
+	if err != nil {
+		writeError(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dump, err := kfcfgs.OpenMapDump(iface, direction, program, mapName)
+	if err != nil {
+		writeError(http.StatusNotFound, fmt.Sprintf("failed to open map: %v", err))
+		return
+	}
+	defer dump.Close()
+
+	// entries are streamed as they are read, errors after the first write are reported in the error field
+	flusher, _ := w.(http.Flusher)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, `{"entries":[`)
+	count := 0
+	next, err := dump.Dump(filter, func(entry models.L3afDMapEntry) error {
+		buf, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			fmt.Fprint(w, ",")
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		count++
+		if flusher != nil && count%mapDumpFlushEntries == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	fmt.Fprintf(w, `],"continue":%q`, next)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to dump map %s of program %s", mapName, program)
+		errMsg, _ := json.Marshal(err.Error())
+		fmt.Fprintf(w, `,"error":%s`, errMsg)
+	}
+	fmt.Fprint(w, "}")
+}
+
+// mapDumpFilter - parses the hex encoded filters of the map dump request
+func mapDumpFilter(r *http.Request) (kf.MapDumpFilter, error) {
+	var filter kf.MapDumpFilter
+	query := r.URL.Query()
+
+	params := []struct {
+		name string
+		dst  *[]byte
+	}{
+		{"prefix", &filter.Prefix},
+		{"start", &filter.Start},
+		{"end", &filter.End},
+		{"continue", &filter.Continue},
+	}
+	for _, p := range params {
+		v, err := hex.DecodeString(query.Get(p.name))
+		if err != nil {
+			return filter, fmt.Errorf("%s is not hex encoded: %v", p.name, err)
+		}
+		*p.dst = v
+	}
+
+	if limit := query.Get("limit"); len(limit) > 0 {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 {
+			return filter, fmt.Errorf("limit must be a positive number")
+		}
+		filter.Limit = l
+	}
+	return filter, nil
+}
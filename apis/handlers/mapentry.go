@@ -0,0 +1,94 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// UpdateMapEntry Updates a single entry of an eBPF map of a running program
+// @Summary Updates a single entry of an eBPF map of a running program
+// @Description Writes the typed key and value to the map bypassing the config push, the update is recorded in the audit log and reconciled on the next config apply
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param program path string true "program name"
+// @Param map path string true "map name, pinned path is URL encoded"
+// @Param key path string true "map key"
+// @Param entry body models.L3afDMapEntryUpdate true "map entry"
+// @Success 200
+// @Router /l3af/maps/v1/{iface}/{program}/{map}/{key} [put]
+func UpdateMapEntry(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		iface := chi.URLParam(r, "iface")
+		program := chi.URLParam(r, "program")
+		mapName, err := url.PathUnescape(chi.URLParam(r, "map"))
+		if err != nil {
+			mesg = fmt.Sprintf("invalid map name: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+		key, err := url.PathUnescape(chi.URLParam(r, "key"))
+		if err != nil {
+			mesg = fmt.Sprintf("invalid key: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+		if len(iface) == 0 || len(program) == 0 || len(mapName) == 0 || len(key) == 0 {
+			mesg = "iface, program, map and key are required"
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var entry models.L3afDMapEntryUpdate
+		if err := json.Unmarshal(bodyBuffer, &entry); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		if err := kfcfg.UpdateMapEntry(iface, program, mapName, key, entry, r.RemoteAddr); err != nil {
+			mesg = fmt.Sprintf("failed to update map entry: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+	}
+}
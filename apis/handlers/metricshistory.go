@@ -0,0 +1,60 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+)
+
+// GetMetricsHistory Returns the retained samples of a monitor map metric
+// @Summary Returns the retained samples of a monitor map metric
+// @Description Returns the samples of the monitor map metric of the programs kept in memory by l3afd, so the values sampled during an outage of the scrape pipeline can be queried
+// @Accept  json
+// @Produce  json
+// @Param metric query string true "metric name of the monitor map i.e. <map>_<key>_<aggregator>[_<field>]"
+// @Param program query string false "program name, all the programs when empty"
+// @Param since query string false "time in RFC 3339 format or duration before now e.g. 15m, the whole retention when empty"
+// @Success 200 {array} models.L3afDMetricHistory
+// @Router /l3af/metrics/v1/history [get]
+func GetMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	query := r.URL.Query()
+	history, err := kf.MetricsHistory(query.Get("metric"), query.Get("program"), query.Get("since"))
+	if err != nil {
+		mesg = err.Error()
+		log.Error().Err(err).Msg("failed to query the metrics history")
+		statusCode = http.StatusInternalServerError
+		if errors.Is(err, kf.ErrInvalidHistoryQuery) {
+			statusCode = http.StatusBadRequest
+		}
+		return
+	}
+
+	resp, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
@@ -0,0 +1,42 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GetIfaceNUMA Returns the NUMA node of the NICs of the interfaces
+// @Summary Returns the NUMA node of the NICs of the interfaces
+// @Description Returns the NUMA node of the NIC of every host interface and the CPUs of the node, same-node cpu affinity of the programs resolves to these CPUs
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.L3afDIfaceNUMA
+// @Router /l3af/numa/v1 [get]
+func GetIfaceNUMA(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.IfaceNUMA(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
@@ -0,0 +1,137 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+)
+
+// PauseProgram Detaches a program from the chain and stops it without removing it from the config
+// @Summary Detaches a program from the chain and stops it without removing it from the config
+// @Description Links the neighbours of the program in the chain and stops its process, the program stays paused on config applies until it is resumed
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param direction path string true "xdpingress, ingress or egress"
+// @Param program path string true "program name"
+// @Success 200
+// @Router /l3af/nfs/v1/{iface}/{direction}/{program}/pause [post]
+func PauseProgram(kfcfg *kf.NFConfigs) http.HandlerFunc {
+	return pauseResume(kfcfg.PauseBPFProgram, "pause")
+}
+
+// ResumeProgram Starts a paused program and splices it into the chain
+// @Summary Starts a paused program and splices it into the chain
+// @Description Starts the paused program with its current config at its sequence position in the chain
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param direction path string true "xdpingress, ingress or egress"
+// @Param program path string true "program name"
+// @Success 200
+// @Router /l3af/nfs/v1/{iface}/{direction}/{program}/resume [post]
+func ResumeProgram(kfcfg *kf.NFConfigs) http.HandlerFunc {
+	return pauseResume(kfcfg.ResumeBPFProgram, "resume")
+}
+
+// ActivateProgram Links a program in standby into the chain
+// @Summary Links a program in standby into the chain
+// @Description Links the running program in standby into the chain at its sequence position without a restart, the program stays activated on config applies until its config is enabled or disabled
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param direction path string true "xdpingress, ingress or egress"
+// @Param program path string true "program name"
+// @Success 200
+// @Router /l3af/nfs/v1/{iface}/{direction}/{program}/activate [post]
+func ActivateProgram(kfcfg *kf.NFConfigs) http.HandlerFunc {
+	return pauseResume(kfcfg.ActivateBPFProgram, "activate")
+}
+
+// StandbyProgram Unlinks an activated program from the chain
+// @Summary Unlinks an activated program from the chain
+// @Description Links the neighbours of the program activated by the API in the chain, the program keeps running in standby
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param direction path string true "xdpingress, ingress or egress"
+// @Param program path string true "program name"
+// @Success 200
+// @Router /l3af/nfs/v1/{iface}/{direction}/{program}/standby [post]
+func StandbyProgram(kfcfg *kf.NFConfigs) http.HandlerFunc {
+	return pauseResume(kfcfg.StandbyBPFProgram, "standby")
+}
+
+func pauseResume(action func(iface, direction, program, remote string) error, name string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		iface := chi.URLParam(r, "iface")
+		direction := chi.URLParam(r, "direction")
+		program := chi.URLParam(r, "program")
+		if len(iface) == 0 || len(direction) == 0 || len(program) == 0 {
+			mesg = "iface, direction and program are required"
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		if err := action(iface, direction, program, r.RemoteAddr); err != nil {
+			mesg = fmt.Sprintf("failed to %s program: %v", name, err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+	}
+}
+
+// GetPausedPrograms Returns the paused programs
+// @Summary Returns the paused programs
+// @Description Returns the programs detached from the chain by the pause API
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.L3afDPausedProgram
+// @Router /l3af/nfs/v1/paused [get]
+func GetPausedPrograms(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.PausedPrograms(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
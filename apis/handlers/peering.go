@@ -0,0 +1,77 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+)
+
+// ActivatePeering Activates the standby node of the pair
+// @Summary Activates the standby node of the pair
+// @Description Activates the standby node of the pair by deploying the last configs of the active peer
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Router /l3af/peering/v1/activate [post]
+func ActivatePeering(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		if err := kfcfg.ActivatePeering(); err != nil {
+			mesg = fmt.Sprintf("failed to activate standby: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+	}
+}
+
+// GetPeeringStatus Returns the peering state of the node
+// @Summary Returns the peering state of the node
+// @Description Returns the peering role, the active peer followed by the standby and the readiness of the standby
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Router /l3af/peering/v1 [get]
+func GetPeeringStatus(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.PeeringStatus(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
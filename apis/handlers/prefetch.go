@@ -0,0 +1,67 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// PrefetchArtifacts Downloads the artifacts of upcoming configs into the artifact cache
+// @Summary Downloads the artifacts of upcoming configs into the artifact cache
+// @Description Pre-stages the artifacts in parallel without starting the programs, so the rollout is not waiting for downloads
+// @Accept  json
+// @Produce  json
+// @Param artifacts body []models.L3afDArtifact true "artifacts"
+// @Success 200 {array} models.L3afDPrefetchResult
+// @Router /l3af/artifacts/v1/prefetch [post]
+func PrefetchArtifacts(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var artifacts []models.L3afDArtifact
+		if err := json.Unmarshal(bodyBuffer, &artifacts); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		resp, err := json.Marshal(kfcfg.PrefetchArtifacts(artifacts))
+		if err != nil {
+			mesg = fmt.Sprintf("failed to marshal response: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+		mesg = string(resp)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// PatchRules Adds and removes rule entries of a running program
+// @Summary Adds and removes rule entries of a running program
+// @Description Applies the rule entries to the rules map and the rules file of the program without restarting it, the change is recorded in the audit log and saved to the config store
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param direction path string true "direction of the program"
+// @Param program path string true "program name"
+// @Param patch body models.L3afDRulesPatch true "rule entries to add and remove"
+// @Success 200 {object} models.L3afDRulesPatchResult
+// @Router /l3af/rules/v1/{iface}/{direction}/{program} [patch]
+func PatchRules(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		iface := chi.URLParam(r, "iface")
+		direction := chi.URLParam(r, "direction")
+		program := chi.URLParam(r, "program")
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var patch models.L3afDRulesPatch
+		if err := json.Unmarshal(bodyBuffer, &patch); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		result, err := kfcfg.PatchRules(iface, direction, program, patch, r.RemoteAddr)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to patch rules: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		resp, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			mesg = "internal server error"
+			log.Error().Msgf("failed to marshal response: %v", err)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+		mesg = string(resp)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ResetStats Resets the stats of the running programs
+// @Summary Resets the stats of the running programs
+// @Description Zeroes the reset counters of the programs, drops the samples of their monitor maps and their metrics history, and resets their counter metrics, of all the programs when no program is set
+// @Accept  json
+// @Produce  json
+// @Param iface query string false "interface name"
+// @Param direction query string false "xdpingress, ingress or egress"
+// @Param program query string false "program name"
+// @Success 200 {object} models.L3afDStatsReset
+// @Router /l3af/stats/v1/reset [post]
+func ResetStats(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		query := r.URL.Query()
+		direction := query.Get("direction")
+		switch direction {
+		case "", models.XDPIngressType, models.IngressType, models.EgressType:
+		default:
+			mesg = fmt.Sprintf("unknown direction %s", direction)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		result, err := kfcfg.ResetStats(query.Get("iface"), direction, query.Get("program"), r.RemoteAddr)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to reset stats: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			if errors.Is(err, kf.ErrNoProgramsToReset) {
+				statusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		resp, err := json.Marshal(result)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to marshal response: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+		mesg = string(resp)
+	}
+}
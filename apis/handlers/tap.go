@@ -0,0 +1,148 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// StartTap Starts packet tap on the interface
+// @Summary Starts packet tap on the interface
+// @Description Starts packet tap on the interface writing the sampled packets to a pcap file or streaming to a collector
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Param tap body models.L3afDTap true "packet tap"
+// @Success 200
+// @Router /l3af/tap/v1/{iface} [post]
+func StartTap(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		iface := chi.URLParam(r, "iface")
+		if len(iface) == 0 {
+			mesg = "iface value is empty"
+			log.Error().Msgf(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		bodyBuffer, err := io.ReadAll(r.Body)
+		if err != nil {
+			mesg = fmt.Sprintf("failed to read request body: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+
+		var t models.L3afDTap
+		if err := json.Unmarshal(bodyBuffer, &t); err != nil {
+			mesg = fmt.Sprintf("failed to unmarshal payload: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+		t.Iface = iface
+
+		if err := kfcfg.StartTap(t); err != nil {
+			mesg = fmt.Sprintf("failed to start packet tap: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusInternalServerError
+			return
+		}
+	}
+}
+
+// StopTap Stops packet tap on the interface
+// @Summary Stops packet tap on the interface
+// @Description Stops packet tap on the interface
+// @Accept  json
+// @Produce  json
+// @Param iface path string true "interface name"
+// @Success 200
+// @Router /l3af/tap/v1/{iface} [delete]
+func StopTap(kfcfg *kf.NFConfigs) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mesg := ""
+		statusCode := http.StatusOK
+
+		w.Header().Add("Content-Type", "application/json")
+
+		defer func(mesg *string, statusCode *int) {
+			w.WriteHeader(*statusCode)
+			_, err := w.Write([]byte(*mesg))
+			if err != nil {
+				log.Warn().Msgf("Failed to write response bytes: %v", err)
+			}
+		}(&mesg, &statusCode)
+
+		iface := chi.URLParam(r, "iface")
+		if len(iface) == 0 {
+			mesg = "iface value is empty"
+			log.Error().Msgf(mesg)
+			statusCode = http.StatusBadRequest
+			return
+		}
+
+		if err := kfcfg.StopTap(iface); err != nil {
+			mesg = fmt.Sprintf("failed to stop packet tap: %v", err)
+			log.Error().Msg(mesg)
+			statusCode = http.StatusNotFound
+			return
+		}
+	}
+}
+
+// GetTaps Returns the running packet taps
+// @Summary Returns the running packet taps
+// @Description Returns the running packet taps
+// @Accept  json
+// @Produce  json
+// @Success 200
+// @Router /l3af/tap/v1 [get]
+func GetTaps(w http.ResponseWriter, r *http.Request) {
+	mesg := ""
+	statusCode := http.StatusOK
+
+	w.Header().Add("Content-Type", "application/json")
+
+	defer func(mesg *string, statusCode *int) {
+		w.WriteHeader(*statusCode)
+		_, err := w.Write([]byte(*mesg))
+		if err != nil {
+			log.Warn().Msgf("Failed to write response bytes: %v", err)
+		}
+	}(&mesg, &statusCode)
+
+	resp, err := json.MarshalIndent(kfcfgs.Taps(), "", "  ")
+	if err != nil {
+		mesg = "internal server error"
+		log.Error().Msgf("failed to marshal response: %v", err)
+		statusCode = http.StatusInternalServerError
+		return
+	}
+	mesg = string(resp)
+}
@@ -6,10 +6,12 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
-	"io/ioutil"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/rs/zerolog/log"
 
@@ -17,13 +19,30 @@ import (
 	"github.com/l3af-project/l3afd/models"
 )
 
+// Headers of the config generation of the config pushes
+const (
+	ConfigGenerationHeader = "X-Config-Generation"
+	ConfigReplayedHeader   = "X-Config-Replayed"
+)
+
+// Headers of the trace of the config apply
+const (
+	TraceParentHeader = "traceparent"
+	TraceIDHeader     = "X-L3afd-Trace-Id"
+)
+
 // UpdateConfig Update eBPF Programs configuration
 // @Summary Update eBPF Programs configuration
 // @Description Update eBPF Programs configuration
 // @Accept  json
 // @Produce  json
 // @Param cfgs body []models.L3afBPFPrograms true "BPF programs"
+// @Param X-Config-Generation header integer false "config generation, replays of the applied generation return the recorded result"
+// @Param traceparent header string false "W3C trace context of the caller, the trace id is the exemplar of the apply metrics"
 // @Success 200
+// @Failure 403 "artifact is not permitted by the artifact allowlist"
+// @Failure 409 "another config apply is in progress or the config generation is older than the applied generation"
+// @Failure 422 "chain limit exceeded"
 // @Router /l3af/configs/v1/update [post]
 func UpdateConfig(ctx context.Context, kfcfg *kf.NFConfigs) http.HandlerFunc {
 
@@ -41,7 +60,33 @@ func UpdateConfig(ctx context.Context, kfcfg *kf.NFConfigs) http.HandlerFunc {
 			}
 		}(&mesg, &statusCode)
 
-		bodyBuffer, err := ioutil.ReadAll(r.Body)
+		var generation uint64
+		if v := r.Header.Get(ConfigGenerationHeader); len(v) > 0 {
+			var err error
+			if generation, err = strconv.ParseUint(v, 10, 64); err != nil {
+				mesg = fmt.Sprintf("invalid %s header: %v", ConfigGenerationHeader, err)
+				log.Error().Msg(mesg)
+				statusCode = http.StatusBadRequest
+				return
+			}
+			w.Header().Set(ConfigGenerationHeader, v)
+			applied, err := kfcfg.CheckGeneration(generation)
+			if err != nil {
+				mesg = err.Error()
+				log.Error().Msg(mesg)
+				statusCode = http.StatusConflict
+				return
+			}
+			if applied != nil {
+				log.Info().Msgf("config generation %d is applied already, replaying the result", generation)
+				w.Header().Set(ConfigReplayedHeader, "true")
+				mesg = applied.Message
+				statusCode = applied.StatusCode
+				return
+			}
+		}
+
+		bodyBuffer, err := io.ReadAll(r.Body)
 		if err != nil {
 			mesg = fmt.Sprintf("failed to read request body: %v", err)
 			log.Error().Msg(mesg)
@@ -57,11 +102,28 @@ func UpdateConfig(ctx context.Context, kfcfg *kf.NFConfigs) http.HandlerFunc {
 			return
 		}
 
-		if err := kfcfg.DeployeBPFPrograms(t); err != nil {
+		if generation > 0 {
+			// result of the apply is replayed for the retries of the generation
+			defer func() { kfcfg.RecordGeneration(generation, statusCode, mesg) }()
+		}
+
+		traceID := kf.TraceIDFromParent(r.Header.Get(TraceParentHeader))
+		if len(traceID) == 0 {
+			traceID = kf.NewTraceID()
+		}
+		w.Header().Set(TraceIDHeader, traceID)
+
+		if err := kfcfg.DeployeBPFProgramsInTrace(traceID, t); err != nil {
 			mesg = fmt.Sprintf("failed to deploy ebpf programs: %v", err)
 			log.Error().Msg(mesg)
 
 			statusCode = http.StatusInternalServerError
+			if errors.Is(err, kf.ErrChainLimitExceeded) || errors.Is(err, kf.ErrPinPathConflict) {
+				statusCode = http.StatusUnprocessableEntity
+			}
+			if errors.Is(err, kf.ErrArtifactNotAllowed) {
+				statusCode = http.StatusForbidden
+			}
 			return
 		}
 	}
@@ -0,0 +1,46 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package apis
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/l3af-project/l3afd/routes"
+	"github.com/l3af-project/l3afd/stats"
+)
+
+// statusRecorder - response writer recording the status code of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush - flushes the streamed responses e.g. the map dumps
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrumentRoutes - records the latency of the requests of the routes by the path pattern, so the
+// parameters of the path do not add series
+func instrumentRoutes(rs []routes.Route) []routes.Route {
+	for i := range rs {
+		method, pattern, next := rs[i].Method, rs[i].Path, rs[i].HandlerFunc
+		rs[i].HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			next(rec, r)
+			stats.Observe(time.Since(started), stats.APIRequestDuration, method, pattern, strconv.Itoa(rec.code))
+		}
+	}
+	return rs
+}
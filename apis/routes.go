@@ -5,6 +5,7 @@ package apis
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/l3af-project/l3afd/apis/handlers"
 	"github.com/l3af-project/l3afd/kf"
@@ -13,11 +14,39 @@ import (
 
 func apiRoutes(ctx context.Context, kfcfg *kf.NFConfigs) []routes.Route {
 
+	// overlapping config applies are rejected
+	applyGate := routes.NewApplyGate()
+
+	// mutating requests are rejected during the config freeze
+	freezeGate := routes.FreezeGate(func(r *http.Request) error {
+		return kfcfg.RejectFrozen("api", r.RemoteAddr, map[string]string{"method": r.Method, "path": r.URL.Path})
+	})
+
 	r := []routes.Route{
 		{
 			Method:      "POST",
 			Path:        "/l3af/configs/{version}/update",
-			HandlerFunc: handlers.UpdateConfig(ctx, kfcfg),
+			HandlerFunc: freezeGate.Wrap(applyGate.Wrap(handlers.UpdateConfig(ctx, kfcfg))),
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/configs/{version}/effective",
+			HandlerFunc: handlers.GetConfigEffective,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/configs/{version}/history",
+			HandlerFunc: handlers.GetConfigHistory,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/configs/{version}/history/diff",
+			HandlerFunc: handlers.GetConfigDiff,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/configs/{version}/history/{revision}",
+			HandlerFunc: handlers.GetConfigRevision,
 		},
 		{
 			Method:      "GET",
@@ -29,6 +58,171 @@ func apiRoutes(ctx context.Context, kfcfg *kf.NFConfigs) []routes.Route {
 			Path:        "/l3af/configs/{version}",
 			HandlerFunc: handlers.GetConfigAll,
 		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/tap/{version}/{iface}",
+			HandlerFunc: freezeGate.Wrap(handlers.StartTap(kfcfg)),
+		},
+		{
+			Method:      "DELETE",
+			Path:        "/l3af/tap/{version}/{iface}",
+			HandlerFunc: freezeGate.Wrap(handlers.StopTap(kfcfg)),
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/tap/{version}",
+			HandlerFunc: handlers.GetTaps,
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/peering/{version}/activate",
+			HandlerFunc: freezeGate.Wrap(applyGate.Wrap(handlers.ActivatePeering(kfcfg))),
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/peering/{version}",
+			HandlerFunc: handlers.GetPeeringStatus,
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/chaos/{version}",
+			HandlerFunc: freezeGate.Wrap(handlers.InjectFault(kfcfg)),
+		},
+		{
+			Method:      "DELETE",
+			Path:        "/l3af/chaos/{version}",
+			HandlerFunc: handlers.ClearFaults(kfcfg),
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/chaos/{version}",
+			HandlerFunc: handlers.GetFaults,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/maps/{version}/{iface}/{direction}/{program}",
+			HandlerFunc: handlers.GetMapEntries,
+		},
+		{
+			Method:      "PUT",
+			Path:        "/l3af/maps/{version}/{iface}/{program}/{map}/{key}",
+			HandlerFunc: freezeGate.Wrap(handlers.UpdateMapEntry(kfcfg)),
+		},
+		{
+			Method:      "PATCH",
+			Path:        "/l3af/rules/{version}/{iface}/{direction}/{program}",
+			HandlerFunc: freezeGate.Wrap(applyGate.Wrap(handlers.PatchRules(kfcfg))),
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/artifacts/{version}/prefetch",
+			HandlerFunc: handlers.PrefetchArtifacts(kfcfg),
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/nfs/{version}/{iface}/{direction}/{program}/pause",
+			HandlerFunc: freezeGate.Wrap(handlers.PauseProgram(kfcfg)),
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/nfs/{version}/{iface}/{direction}/{program}/resume",
+			HandlerFunc: freezeGate.Wrap(handlers.ResumeProgram(kfcfg)),
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/nfs/{version}/{iface}/{direction}/{program}/activate",
+			HandlerFunc: freezeGate.Wrap(handlers.ActivateProgram(kfcfg)),
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/nfs/{version}/{iface}/{direction}/{program}/standby",
+			HandlerFunc: freezeGate.Wrap(handlers.StandbyProgram(kfcfg)),
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/nfs/{version}/paused",
+			HandlerFunc: handlers.GetPausedPrograms,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/chains/{version}/{iface}/graph",
+			HandlerFunc: handlers.GetChainGraph,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/metrics/{version}/history",
+			HandlerFunc: handlers.GetMetricsHistory,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/links/{version}",
+			HandlerFunc: handlers.GetLinkStatus,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/numa/{version}",
+			HandlerFunc: handlers.GetIfaceNUMA,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/applies/{version}",
+			HandlerFunc: handlers.GetPendingApplies,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/incidents/{version}",
+			HandlerFunc: handlers.GetIncidents,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/incidents/{version}/{id}",
+			HandlerFunc: handlers.GetIncidentBundle,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/artifacts/{version}/{name}/{progVersion}/{platform}/{artifact}",
+			HandlerFunc: handlers.GetSharedArtifact,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/freeze/{version}",
+			HandlerFunc: handlers.GetConfigFreeze,
+		},
+		{
+			Method:      "PUT",
+			Path:        "/l3af/freeze/{version}",
+			HandlerFunc: handlers.SetConfigFreeze(kfcfg),
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/doctor/{version}",
+			HandlerFunc: handlers.GetDoctor,
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/logging/{version}",
+			HandlerFunc: handlers.GetLogLevels,
+		},
+		{
+			Method:      "PUT",
+			Path:        "/l3af/logging/{version}",
+			HandlerFunc: handlers.SetLogLevels(kfcfg),
+		},
+		{
+			Method:      "GET",
+			Path:        "/l3af/loglevel/{version}",
+			HandlerFunc: handlers.GetLogLevel,
+		},
+		{
+			Method:      "PUT",
+			Path:        "/l3af/loglevel/{version}",
+			HandlerFunc: handlers.SetLogLevel(kfcfg),
+		},
+		{
+			Method:      "POST",
+			Path:        "/l3af/stats/{version}/reset",
+			HandlerFunc: freezeGate.Wrap(handlers.ResetStats(kfcfg)),
+		},
 	}
 
 	return r
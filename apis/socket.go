@@ -0,0 +1,99 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package apis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// socketPeerKey - context key of the peer credentials of the unix socket connection
+type socketPeerKey struct{}
+
+// listenSocket - listens on the unix socket of the config API, the socket left by a previous run is removed.
+// The socket is accessible by root only, or by root and the members of the group when set.
+func listenSocket(socketPath, group string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dir of socket %s: %w", socketPath, err)
+	}
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %w", socketPath, err)
+	}
+
+	gid := -1
+	if len(group) > 0 {
+		var err error
+		if gid, err = lookupGroupID(group); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket %s: %w", socketPath, err)
+	}
+	mode := os.FileMode(0600)
+	if gid >= 0 {
+		if err := os.Chown(socketPath, -1, gid); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to set group %s of socket %s: %w", group, socketPath, err)
+		}
+		mode = 0660
+	}
+	if err := os.Chmod(socketPath, mode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set permissions of socket %s: %w", socketPath, err)
+	}
+	return l, nil
+}
+
+// lookupGroupID - id of the group name or id
+func lookupGroupID(group string) (int, error) {
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		if g, err = user.LookupGroupId(group); err != nil {
+			return -1, fmt.Errorf("unknown socket group %s: %w", group, err)
+		}
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// serveSocket - serves the config API on the unix socket, the local clients are not filtered by source CIDRs and
+// their peer credentials are the remote address of the requests audited
+func serveSocket(socketPath, group string, handler http.Handler) {
+	l, err := listenSocket(socketPath, group)
+	if err != nil {
+		log.Error().Err(err).Msg("config API is not served on the unix socket")
+		return
+	}
+	server := &http.Server{
+		Handler: socketPeerHandler(handler),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, socketPeerKey{}, socketPeer(c))
+		},
+	}
+	log.Info().Msgf("l3afd server listening - unix:%s", socketPath)
+	if err := server.Serve(l); err != nil {
+		log.Error().Err(err).Msgf("config API on unix socket %s stopped", socketPath)
+	}
+}
+
+// socketPeerHandler - sets the remote address of the request to the peer credentials of the connection
+func socketPeerHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if peer, ok := r.Context().Value(socketPeerKey{}).(string); ok && len(peer) > 0 {
+			r.RemoteAddr = peer
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,14 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+//
+//go:build WINDOWS
+// +build WINDOWS
+
+package apis
+
+import "net"
+
+// socketPeer - peer credentials of the unix socket are not available on windows
+func socketPeer(c net.Conn) string {
+	return "unix"
+}
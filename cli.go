@@ -0,0 +1,260 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+const cliUsage = `usage: l3afd [-config path] <command> [flags]
+
+commands:
+  status                                         programs of the interfaces and their state
+  apply -f <config.json>                         applies the configs of the interfaces, - reads stdin
+  maps dump -iface <iface> -direction <direction> -program <program> -map <map> [-prefix hex] [-start hex] [-end hex] [-limit n] [-continue token]
+                                                 dumps the entries of an eBPF map of a running program
+  chain show [-format text|json|dot] <iface>     programs chained on the interface and the maps linking them
+`
+
+// timeout of the subcommands, an apply waits for the programs to start
+const cliTimeout = 5 * time.Minute
+
+// errCLIUsage is returned when the subcommand or its arguments are invalid
+var errCLIUsage = errors.New("invalid command")
+
+// cliClient - client of the config API served by the local daemon on the unix socket
+type cliClient struct {
+	client *http.Client
+	out    io.Writer
+}
+
+// newCLIClient - client of the config API on the unix socket, the responses are written to out
+func newCLIClient(socketPath string, timeout time.Duration, out io.Writer) *cliClient {
+	dialer := &net.Dialer{}
+	return &cliClient{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+		out: out,
+	}
+}
+
+// runCLI - runs the subcommand against the local daemon, returns the exit code
+func runCLI(conf *config.Config, args []string) int {
+	if len(conf.L3afConfigsSocket) == 0 {
+		fmt.Fprintln(os.Stderr, "l3afd: the config API socket is disabled in the config")
+		return 1
+	}
+	c := newCLIClient(conf.L3afConfigsSocket, cliTimeout, os.Stdout)
+	if err := c.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "l3afd: %v\n", err)
+		if errors.Is(err, errCLIUsage) {
+			fmt.Fprint(os.Stderr, cliUsage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// run - dispatches the subcommand
+func (c *cliClient) run(args []string) error {
+	if len(args) == 0 {
+		return errCLIUsage
+	}
+	switch {
+	case args[0] == "status":
+		return c.status()
+	case args[0] == "apply":
+		return c.apply(args[1:])
+	case args[0] == "maps" && len(args) > 1 && args[1] == "dump":
+		return c.mapsDump(args[2:])
+	case args[0] == "chain" && len(args) > 1 && args[1] == "show":
+		return c.chainShow(args[2:])
+	}
+	return fmt.Errorf("%w %s", errCLIUsage, strings.Join(args, " "))
+}
+
+// do - sends the request to the daemon, the response body is returned for the successful status codes
+func (c *cliClient) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, "http://l3afd"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("l3afd is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(buf)))
+	}
+	return buf, nil
+}
+
+// status - prints the programs of the effective config of the interfaces
+func (c *cliClient) status() error {
+	buf, err := c.do(http.MethodGet, "/l3af/configs/v1/effective", nil)
+	if err != nil {
+		return err
+	}
+	var configs []models.L3afDEffectiveConfig
+	if err := json.Unmarshal(buf, &configs); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "IFACE\tDIRECTION\tSEQ\tPROGRAM\tVERSION\tSTATE")
+	for _, cfg := range configs {
+		for _, p := range cfg.BpfPrograms {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", cfg.Iface, p.Direction, p.Program.SeqID, p.Program.Name, p.Program.Version, p.State)
+		}
+	}
+	return w.Flush()
+}
+
+// apply - applies the configs of the file to the daemon
+func (c *cliClient) apply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("f", "", "config file, - reads stdin")
+	if err := fs.Parse(args); err != nil || len(*file) == 0 || fs.NArg() > 0 {
+		return fmt.Errorf("%w: apply requires -f <config.json>", errCLIUsage)
+	}
+
+	var buf []byte
+	var err error
+	if *file == "-" {
+		buf, err = io.ReadAll(os.Stdin)
+	} else {
+		buf, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var t []models.L3afBPFPrograms
+	if err := json.Unmarshal(buf, &t); err != nil {
+		return fmt.Errorf("invalid config %s: %w", *file, err)
+	}
+
+	if _, err := c.do(http.MethodPost, "/l3af/configs/v1/update", bytes.NewReader(buf)); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "config of %d interfaces applied\n", len(t))
+	return nil
+}
+
+// mapsDump - prints the entries of the map of the running program
+func (c *cliClient) mapsDump(args []string) error {
+	fs := flag.NewFlagSet("maps dump", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	iface := fs.String("iface", "", "interface name")
+	direction := fs.String("direction", "", "xdpingress, ingress or egress")
+	program := fs.String("program", "", "program name")
+	mapName := fs.String("map", "", "map name")
+	limit := fs.Int("limit", 0, "maximum number of entries")
+	query := url.Values{}
+	for _, name := range []string{"prefix", "start", "end", "continue"} {
+		name := name
+		fs.Func(name, "hex "+name, func(v string) error {
+			query.Set(name, v)
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return fmt.Errorf("%w: maps dump %s", errCLIUsage, strings.Join(args, " "))
+	}
+	if len(*iface) == 0 || len(*direction) == 0 || len(*program) == 0 || len(*mapName) == 0 {
+		return fmt.Errorf("%w: maps dump requires -iface, -direction, -program and -map", errCLIUsage)
+	}
+	query.Set("map", *mapName)
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+
+	path := fmt.Sprintf("/l3af/maps/v1/%s/%s/%s?%s", url.PathEscape(*iface), url.PathEscape(*direction), url.PathEscape(*program), query.Encode())
+	buf, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf, "", "  "); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(c.out)
+	return err
+}
+
+// chainShow - prints the chain graph of the interface
+func (c *cliClient) chainShow(args []string) error {
+	fs := flag.NewFlagSet("chain show", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "text", "text, json or dot")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return fmt.Errorf("%w: chain show requires the iface", errCLIUsage)
+	}
+	apiFormat := *format
+	switch *format {
+	case "text":
+		apiFormat = "json"
+	case "json", "dot":
+	default:
+		return fmt.Errorf("%w: unknown format %s", errCLIUsage, *format)
+	}
+
+	buf, err := c.do(http.MethodGet, fmt.Sprintf("/l3af/chains/v1/%s/graph?format=%s", url.PathEscape(fs.Arg(0)), apiFormat), nil)
+	if err != nil {
+		return err
+	}
+	if *format != "text" {
+		_, err = fmt.Fprintln(c.out, strings.TrimSpace(string(buf)))
+		return err
+	}
+
+	var graph models.L3afDChainGraph
+	if err := json.Unmarshal(buf, &graph); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DIRECTION\tSEQ\tPROGRAM\tVERSION\tPROG ID\tSTATE")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\n", node.Direction, node.SeqID, node.Name, node.Version, node.ProgID, node.State)
+	}
+	if len(graph.Edges) > 0 {
+		fmt.Fprintln(w, "\nFROM\tTO\tKIND\tMAP")
+		for _, edge := range graph.Edges {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", edge.From, edge.To, edge.Kind, edge.Map)
+		}
+	}
+	return w.Flush()
+}
@@ -7,6 +7,9 @@ package config
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
+	"os"
+	"regexp"
 	"strings"
 	"time"
 
@@ -16,8 +19,95 @@ import (
 
 const (
 	ENV_PROD = "PROD"
+
+	// tenantQuotaGroupPrefix is prefix of the groups defining per tenant quotas e.g. [tenant-quota.edge-team]
+	tenantQuotaGroupPrefix = "tenant-quota."
+
+	// ifaceQueuesGroupPrefix is prefix of the groups defining per interface channels and RSS e.g. [iface-queues.eth0]
+	ifaceQueuesGroupPrefix = "iface-queues."
+
+	// programArgsGroupPrefix is prefix of the groups defining per program argument schemas e.g. [program-args.ratelimiting]
+	programArgsGroupPrefix = "program-args."
+
+	// directionArgsGroupPrefix is prefix of the groups defining per direction default args e.g. [direction-args.xdpingress]
+	directionArgsGroupPrefix = "direction-args."
+
+	// chainLimitsGroupPrefix is prefix of the groups defining per interface chain limits e.g. [chain-limits.eth0]
+	chainLimitsGroupPrefix = "chain-limits."
+
+	// kfRepoMirrorGroupPrefix is prefix of the groups defining the mirrors of the KF repo e.g. [kf-repo-mirror.us-west]
+	kfRepoMirrorGroupPrefix = "kf-repo-mirror."
+
+	// artifactAllowlistGroupPrefix is prefix of the groups defining per program allowed artifacts e.g. [artifact-allowlist.ratelimiting]
+	artifactAllowlistGroupPrefix = "artifact-allowlist."
+
+	// rootFlavorGroupPrefix is prefix of the groups defining the root program flavors of interfaces e.g. [root-flavor.smartnic]
+	rootFlavorGroupPrefix = "root-flavor."
+
+	// ifaceGroupGroupPrefix is prefix of the groups defining the interface groups of the configs e.g. [iface-group.ports]
+	ifaceGroupGroupPrefix = "iface-group."
+
+	// chainTemplateGroupPrefix is prefix of the groups defining the chain templates of the configs e.g. [chain-template.edge]
+	chainTemplateGroupPrefix = "chain-template."
 )
 
+// ArgSchema defines the allowed start, stop and status arguments of a program, value regex by argument name.
+type ArgSchema map[string]*regexp.Regexp
+
+// TenantQuota defines resource limits of a tenant, enforced at config apply time.
+// Zero value of a limit means unlimited.
+type TenantQuota struct {
+	MaxPrograms  int // Maximum number of enabled programs across all interfaces
+	MaxMapMemory int // Maximum sum of the declared BPF map memory of the programs in bytes
+	MaxCPU       int // Maximum cpu limit of a single user program
+}
+
+// KFRepoMirror defines a mirror of the KF repo, the repos are tried by ascending priority
+type KFRepoMirror struct {
+	URL      string // Repo url of any artifact store scheme, with the layout of the KF repo
+	Priority int    // Priority of the mirror, lower is tried first
+}
+
+// AllowedArtifacts defines the artifacts a program is permitted to download, an empty list allows any value
+type AllowedArtifacts struct {
+	Artifacts []string // Artifact names
+	Versions  []string // Versions
+	SHA256    []string // Hex SHA-256 of the artifact archives
+}
+
+// IfaceQueues defines ethtool channel counts and RSS spreading of an interface, applied when the
+// XDP root program is attached and restored when it is removed. Zero value of a setting means unchanged.
+type IfaceQueues struct {
+	CombinedChannels int // ethtool -L <iface> combined N
+	RxChannels       int // ethtool -L <iface> rx N
+	TxChannels       int // ethtool -L <iface> tx N
+	RSSQueues        int // ethtool -X <iface> equal N
+}
+
+// RootFlavor defines the root programs of the interfaces of the flavor. Empty values are the values of the
+// xdp-root-program and tc-root-program groups.
+type RootFlavor struct {
+	Ifaces           []string // Interfaces the root programs of the flavor are loaded on
+	XDPArtifact      string   // Artifact of the XDP root program
+	XDPVersion       string   // Version of the XDP root program
+	XDPCommand       string   // Start and stop command of the XDP root program
+	XDPMapName       string   // Pinned prog map of the XDP root program
+	XDPCapabilities  []string // Capabilities of the XDP root program the chained programs may require
+	TCArtifact       string   // Artifact of the TC root program
+	TCVersion        string   // Version of the TC root program
+	TCCommand        string   // Start and stop command of the TC root program
+	TCIngressMapName string   // Pinned ingress prog map of the TC root program
+	TCEgressMapName  string   // Pinned egress prog map of the TC root program
+	TCCapabilities   []string // Capabilities of the TC root program the chained programs may require
+}
+
+// ChainLimits defines the maximum number of enabled programs of an interface, enforced at config apply time.
+// Zero value of a limit means unlimited.
+type ChainLimits struct {
+	MaxChainLength int // Maximum number of programs chained in a single direction
+	MaxPrograms    int // Maximum number of programs across all directions
+}
+
 type Config struct {
 	PIDFilename       string
 	DataCenter        string
@@ -27,18 +117,50 @@ type Config struct {
 	MinKernelMinorVer int
 	KFRepoURL         string
 	HttpClientTimeout time.Duration
+
+	// Proxy, CA bundle and pinned certificates of the KF repo client, the proxy of the environment is used when empty
+	KFRepoProxy            string
+	KFRepoCABundle         string
+	KFRepoPinnedCertSHA256 []string
+
+	// Cached artifacts are checked against the KF repo with HEAD requests before they are used
+	KFRepoFreshnessCheck bool
+
+	// Mirrors of the KF repo by name, failed repos are tried after the healthy repos for the failover backoff
+	KFRepoPriority        int
+	KFRepoMirrors         map[string]KFRepoMirror
+	KFRepoFailoverBackoff time.Duration
+
+	// Config of the s3:// artifact store, credentials of the environment are used when the access key is empty
+	ArtifactS3Region              string
+	ArtifactS3Endpoint            string
+	ArtifactS3AccessKeyID         string
+	ArtifactS3SecretAccessKeyFile string
+
+	// Config of the oci:// artifact store, pulls are anonymous when the username is empty
+	ArtifactOCIUsername     string
+	ArtifactOCIPasswordFile string
+	ArtifactOCIPlainHTTP    bool
+
 	MaxNFReStartCount int
 	MaxNFsAttachCount int
 	Environment       string
 
+	// BPF map memory budgets in bytes, 0 means unlimited
+	MaxProgramMapMemory int
+	MaxMapMemory        int
+
 	// Flag to enable chaining with root program
 	BpfChainingEnabled bool
 
 	// stats
 	// Prometheus endpoint for pull/scrape the metrics.
-	MetricsAddr    string
-	KFPollInterval time.Duration
-	NMetricSamples int
+	MetricsAddr            string
+	KFPollInterval         time.Duration
+	NMetricSamples         int
+	ProcessMetricsInterval time.Duration
+	// Metrics are exported in OpenMetrics with the trace ids of the config applies and the restarts as exemplars
+	MetricsExemplars bool
 
 	ShutdownTimeout time.Duration
 
@@ -58,6 +180,7 @@ type Config struct {
 	XDPRootProgramCommand           string
 	XDPRootProgramVersion           string
 	XDPRootProgramUserProgramDaemon bool
+	XDPRootProgramCapabilities      []string
 
 	// TC Root program details.
 	TCRootProgramName              string
@@ -67,13 +190,45 @@ type Config struct {
 	TCRootProgramCommand           string
 	TCRootProgramVersion           string
 	TCRootProgramUserProgramDaemon bool
+	TCRootProgramCapabilities      []string
+
+	// Root program flavors by name, interfaces of no flavor load the root programs above
+	RootFlavors map[string]RootFlavor
+
+	// Interfaces of the interface groups by group name, configs of the iface @<group> apply to the interfaces
+	IfaceGroups map[string][]string
+	// Files of the programs of the chain templates by template name, referenced by the template of the configs
+	ChainTemplates map[string]string
 
 	// ebpf chain details
 	EBPFChainDebugAddr    string
 	EBPFChainDebugEnabled bool
 
+	// packet tap perf event maps pinned by the root programs
+	TapMapDir string
+	// directory of the pcap files of the packet taps, the pcap file of a tap is a file name in the directory
+	TapPcapDir string
+	// collector addresses the packet taps may stream to, no collector is allowed when empty
+	TapCollectors []string
+
+	// chain latency self test after deployment
+	ChainSelfTestEnabled    bool
+	ChainSelfTestRepeat     int
+	ChainSelfTestMaxLatency time.Duration
+
+	// per position hit counters maps pinned by the instrumented root programs, 0 interval disables
+	ChainHitsMapDir   string
+	ChainHitsInterval time.Duration
+
+	// labels of the node matched by the node selectors of the programs, key=value
+	NodeLabels []string
+
 	// l3af configs to listen addrs
 	L3afConfigsRestAPIAddr string
+	// unix socket of the config API used by the l3afd subcommands, empty disables
+	L3afConfigsSocket string
+	// group allowed to use the unix socket besides root, empty allows root only
+	L3afConfigsSocketGroup string
 
 	// l3af config store
 	L3afConfigStoreFileName string
@@ -85,6 +240,239 @@ type Config struct {
 	MTLSCACertFilename     string
 	MTLSServerCertFilename string
 	MTLSServerKeyFilename  string
+
+	// Multi-tenancy, quotas by tenant name
+	TenantQuotas map[string]TenantQuota
+
+	// BTF of the running kernel for CO-RE programs, used when kernel BTF is absent
+	BTFHubURL string
+	BTFDir    string
+
+	// Interface channels and RSS by interface name
+	IfaceQueues map[string]IfaceQueues
+
+	// Kubernetes mode, NF configs are read from ConfigMaps or L3afNodeConfig custom resources
+	KubernetesEnabled        bool
+	KubernetesNamespace      string
+	KubernetesResource       string
+	KubernetesNodeName       string
+	KubernetesResyncInterval time.Duration
+
+	// Node facts heartbeat to the control plane
+	HeartbeatURL      string
+	HeartbeatInterval time.Duration
+
+	// Active/standby peering of the edge node pairs
+	PeeringEnabled         bool
+	PeeringRole            string
+	PeeringPeerURL         string
+	PeeringPollInterval    time.Duration
+	PeeringFailoverTimeout time.Duration
+
+	// Artifacts of the cache shared between the nodes of the site, fetched from the peers before the KF repo
+	ArtifactPeersEnabled   bool
+	ArtifactPeers          []string
+	ArtifactPeersTokenFile string
+	ArtifactPeersTimeout   time.Duration
+
+	// Artifacts the programs are permitted to download by program name, of the signed manifest when set
+	ArtifactAllowlistEnabled       bool
+	ArtifactAllowlist              map[string]AllowedArtifacts
+	ArtifactAllowlistManifestFile  string
+	ArtifactAllowlistPublicKeyFile string
+
+	// Webhook targets of the state change notifications, signed with the HMAC-SHA256 of the secret
+	WebhookTargets       []string
+	WebhookEvents        []string
+	WebhookSecretFile    string
+	WebhookTimeout       time.Duration
+	WebhookQueueSize     int
+	WebhookMaxRetries    int
+	WebhookRetryInterval time.Duration
+
+	// Fault injection API for testing the alerting and recovery in staging
+	ChaosEnabled bool
+
+	// Audit log of the admin operations, logged to the l3afd log when empty
+	AuditLogFile string
+
+	// Single map entry updates, overwrite or preserve the entries on the next config apply
+	MapEntryReconcilePolicy string
+
+	// Argument schemas by program name, programs without schema have no arguments in strict mode
+	ProgramArgSchemas map[string]ArgSchema
+	StrictProgramArgs bool
+	// default args of the directions passed to the programs of the arg schema version 2, value by arg name
+	DirectionDefaultArgs map[string]map[string]string
+
+	// NF commands timeouts and environment, extra environment variables are KEY=VALUE
+	NFCommandStartTimeout  time.Duration
+	NFCommandStopTimeout   time.Duration
+	NFCommandStatusTimeout time.Duration
+	NFCommandEnv           []string
+	// directory of the per program control sockets of the programs of the arg schema version 3
+	NFControlSocketDir string
+	// default interval of the heartbeat writes to the heartbeat maps of the programs
+	NFHeartbeatInterval time.Duration
+	// default timeout of the runs of the scheduled tasks of the programs
+	NFTaskTimeout time.Duration
+	// bpffs dir of the staging prog maps the standby programs insert their programs into
+	NFStandbyMapDir string
+	// bpffs dir the maps consumed by the programs declaring no pin path are re-pinned into
+	NFConsumedMapDir string
+
+	// LSM BPF gatekeeper permitting the bpf() program loads of the approved binaries only, binaries are path or
+	// path=sha256
+	BPFGatekeeperEnabled         bool
+	BPFGatekeeperName            string
+	BPFGatekeeperArtifact        string
+	BPFGatekeeperVersion         string
+	BPFGatekeeperObject          string
+	BPFGatekeeperProgram         string
+	BPFGatekeeperMap             string
+	BPFGatekeeperPinDir          string
+	BPFGatekeeperAllowedBinaries []string
+
+	// User programs run in a mount namespace exposing only their paths, with Landlock rules when supported
+	NFSandboxEnabled        bool
+	NFSandboxLandlock       bool
+	NFSandboxRoot           string
+	NFSandboxReadOnlyPaths  []string
+	NFSandboxReadWritePaths []string
+
+	// Time the user programs may take to exit after SIGTERM before they are killed
+	NFStopGracePeriod time.Duration
+
+	// Overall deadline of the program start i.e. download, load, pinned map detection and prog ID fetch
+	NFStartDeadline time.Duration
+
+	// Version skew check of the running programs against the configured artifacts, 0 disables
+	VersionSkewCheckInterval time.Duration
+
+	// Number of artifacts downloaded in parallel by the artifact prefetch API
+	ArtifactPrefetchParallelism int
+
+	// Global chain limits and per interface overrides
+	ChainLimits      ChainLimits
+	IfaceChainLimits map[string]ChainLimits
+
+	// Programs paused by the admin API
+	PausedProgramsFileName string
+
+	// Programs disabled in the config along with their preserved maps
+	DisabledProgramsFileName string
+
+	// Mutating config operations are rejected while frozen, the freeze toggled by the admin API is kept
+	// in the freeze file across restarts
+	ConfigFreezeEnabled  bool
+	ConfigFreezeReason   string
+	ConfigFreezeFileName string
+
+	// Source CIDRs allowed per listener, empty allows all
+	L3afConfigsAllowedCIDRs    []*net.IPNet
+	MetricsAllowedCIDRs        []*net.IPNet
+	EBPFChainDebugAllowedCIDRs []*net.IPNet
+
+	// Request limits of the config API
+	L3afConfigsMaxBodySize int64
+	L3afConfigsRateLimit   float64
+	L3afConfigsRateBurst   int
+
+	// Last applied config generation
+	ConfigGenerationFileName string
+
+	// Memlock limit of l3afd, inherited by the user programs, raised for the BPF maps on kernels before 5.11,
+	// unlimited when the limit is 0
+	MemlockEnabled bool
+	MemlockLimit   int
+
+	// Container mode of l3afd, auto detects the container, and the network namespace of the host verified to
+	// be the namespace of l3afd in a container
+	ContainerMode          string
+	ContainerHostNetNSPath string
+
+	// Lowest level of the logs sent to the event log when l3afd runs as a windows service
+	EventLogLevel string
+
+	// Preflight diagnostics run at startup, the startup fails on a failed check when fail-on-error is set,
+	// and the free space of the BPF dirs below the minimum is reported
+	DoctorOnStartup     bool
+	DoctorFailOnError   bool
+	DoctorMinFreeDiskMB int
+
+	// Last applied configs kept in the config history file, 0 disables the history
+	ConfigHistoryFileName   string
+	ConfigHistoryMaxEntries int
+
+	// AES-GCM encryption of the state files at rest, key is read from the key file or the output of the key command
+	EncryptionEnabled    bool
+	EncryptionKeyFile    string
+	EncryptionKeyCommand []string
+
+	// Directory of the rules and KF config files handed to the NFs, artifact directory of the program when
+	// empty. Private tmpfs of the size is mounted on the directory at start when enabled.
+	NFFilesDir        string
+	NFFilesMountTmpfs bool
+	NFFilesTmpfsSize  string
+
+	// TLS and basic auth of the metrics listener, client certificates are verified when the client CA is set
+	MetricsTLSEnabled            bool
+	MetricsTLSMinVersion         uint16
+	MetricsTLSCertFile           string
+	MetricsTLSKeyFile            string
+	MetricsTLSClientCAFile       string
+	MetricsBasicAuthUsername     string
+	MetricsBasicAuthPasswordFile string
+
+	// Interval of the full resync of the running programs with the config, 0 disables
+	ReconcileInterval time.Duration
+
+	// Configs of the interfaces with the link down are queued and attached when the link comes up
+	AttachOnLinkUp bool
+
+	// Window of the version upgrades and chain reorders of the programs without their own window, always
+	// open when empty, and the interval the queued updates are checked for the window
+	ApplyWindow              string
+	ApplyWindowCheckInterval time.Duration
+
+	// Period of the monitor map samples and the time the samples of a period may take
+	MonitorMapsPeriod time.Duration
+	MonitorMapsBudget time.Duration
+	// History of the monitor map values kept for the local queries, samples kept per metric
+	MonitorMapsHistoryRetention time.Duration
+	MonitorMapsHistorySamples   int
+
+	// Incident bundles of the crashed NF processes, kept under the BPF log dir when the dir is empty
+	CrashForensicsEnabled     bool
+	CrashForensicsDir         string
+	CrashForensicsOutputLines int
+	CrashForensicsMaxBundles  int
+
+	// Collected cores of the user programs enabling core dumps, kept under the BPF log dir when the dir is empty
+	CoreDumpsDir       string
+	CoreDumpsMaxSizeMB int
+
+	// Outputs of the l3afd logs i.e. console, syslog and journald, the level and the level overrides of the
+	// kf, apis and stats modules, e.g. kf=debug
+	LogOutputs        []string
+	LogLevel          string
+	LogModuleLevels   []string
+	LogSyslogNetwork  string
+	LogSyslogAddress  string
+	LogSyslogFacility string
+	LogJournaldSocket string
+
+	// Monitor maps exported as metrics matched by <program>/<map> patterns, deny wins over allow, and the
+	// series limits of a program and of the node, 0 is unlimited
+	MonitorMapsAllow               []string
+	MonitorMapsDeny                []string
+	MonitorMapsMaxSeriesPerProgram int
+	MonitorMapsSeriesBudget        int
+
+	// NIC drivers supporting XDP offload besides nfp, and the default fallback of the programs of the offload
+	// XDP mode on interfaces not supporting offload, native, generic or fail
+	XDPOffloadDrivers  []string
+	XDPOffloadFallback string
 }
 
 // ReadConfig - Initializes configuration from file
@@ -95,10 +483,56 @@ func ReadConfig(configPath string) (*Config, error) {
 	if configErr != nil {
 		log.Fatal().Err(configErr).Msgf("Could not open config file %q", configPath)
 	}
-	minTLSVersion, err := loadTLSVersion(confReader, "min-tls-version")
+	minTLSVersion, err := loadTLSVersion(confReader, "mTLS", "min-tls-version")
+	if err != nil {
+		return nil, err
+	}
+	metricsMinTLSVersion, err := loadTLSVersion(confReader, "metrics", "min-tls-version")
+	if err != nil {
+		return nil, err
+	}
+	offloadFallback := LoadOptionalConfigString(confReader, "xdp-offload", "fallback", "native")
+	switch offloadFallback {
+	case "native", "generic", "fail":
+	default:
+		return nil, fmt.Errorf("invalid xdp-offload fallback %q, must be native, generic or fail", offloadFallback)
+	}
+	argSchemas, err := loadArgSchemas(confReader)
+	if err != nil {
+		return nil, err
+	}
+	directionArgs, err := loadDirectionArgs(confReader)
+	if err != nil {
+		return nil, err
+	}
+	configsAllowedCIDRs, err := loadCIDRs(confReader, "l3af-configs", "allowed-cidrs")
+	if err != nil {
+		return nil, err
+	}
+	metricsAllowedCIDRs, err := loadCIDRs(confReader, "web", "metrics-allowed-cidrs")
+	if err != nil {
+		return nil, err
+	}
+	debugAllowedCIDRs, err := loadCIDRs(confReader, "ebpf-chain-debug", "allowed-cidrs")
 	if err != nil {
 		return nil, err
 	}
+	rootFlavors, err := loadRootFlavors(confReader)
+	if err != nil {
+		return nil, err
+	}
+	ifaceGroups, err := loadIfaceGroups(confReader)
+	if err != nil {
+		return nil, err
+	}
+	chainTemplates, err := loadChainTemplates(confReader)
+	if err != nil {
+		return nil, err
+	}
+	chainLimits := ChainLimits{
+		MaxChainLength: LoadOptionalConfigInt(confReader, "chain-limits", "max-chain-length", 0),
+		MaxPrograms:    LoadOptionalConfigInt(confReader, "chain-limits", "max-programs", 0),
+	}
 
 	return &Config{
 		PIDFilename:                     LoadConfigString(confReader, "l3afd", "pid-file"),
@@ -108,13 +542,31 @@ func ReadConfig(configPath string) (*Config, error) {
 		MinKernelMajorVer:               LoadConfigInt(confReader, "l3afd", "kernel-major-version"),
 		MinKernelMinorVer:               LoadConfigInt(confReader, "l3afd", "kernel-minor-version"),
 		KFRepoURL:                       LoadConfigString(confReader, "kf-repo", "url"),
+		KFRepoProxy:                     LoadOptionalConfigString(confReader, "kf-repo", "proxy", ""),
+		KFRepoCABundle:                  LoadOptionalConfigString(confReader, "kf-repo", "ca-bundle", ""),
+		KFRepoPinnedCertSHA256:          LoadOptionalConfigStringCSV(confReader, "kf-repo", "pinned-cert-sha256", nil),
+		KFRepoFreshnessCheck:            LoadOptionalConfigBool(confReader, "kf-repo", "freshness-check", false),
+		KFRepoPriority:                  LoadOptionalConfigInt(confReader, "kf-repo", "priority", 0),
+		KFRepoMirrors:                   loadKFRepoMirrors(confReader),
+		KFRepoFailoverBackoff:           LoadOptionalConfigDuration(confReader, "kf-repo", "failover-backoff", time.Minute),
+		ArtifactS3Region:                LoadOptionalConfigString(confReader, "artifact-store-s3", "region", "us-east-1"),
+		ArtifactS3Endpoint:              LoadOptionalConfigString(confReader, "artifact-store-s3", "endpoint", ""),
+		ArtifactS3AccessKeyID:           LoadOptionalConfigString(confReader, "artifact-store-s3", "access-key-id", ""),
+		ArtifactS3SecretAccessKeyFile:   LoadOptionalConfigString(confReader, "artifact-store-s3", "secret-access-key-file", ""),
+		ArtifactOCIUsername:             LoadOptionalConfigString(confReader, "artifact-store-oci", "username", ""),
+		ArtifactOCIPasswordFile:         LoadOptionalConfigString(confReader, "artifact-store-oci", "password-file", ""),
+		ArtifactOCIPlainHTTP:            LoadOptionalConfigBool(confReader, "artifact-store-oci", "plain-http", false),
 		HttpClientTimeout:               LoadConfigDuration(confReader, "l3afd", "http-client-timeout"),
 		MaxNFReStartCount:               LoadConfigInt(confReader, "l3afd", "max-nf-restart-count"),
 		MaxNFsAttachCount:               LoadConfigInt(confReader, "l3afd", "max-nfs-attach-count"),
+		MaxProgramMapMemory:             LoadOptionalConfigInt(confReader, "l3afd", "max-program-map-memory", 0),
+		MaxMapMemory:                    LoadOptionalConfigInt(confReader, "l3afd", "max-map-memory", 0),
 		BpfChainingEnabled:              LoadOptionalConfigBool(confReader, "l3afd", "bpf-chaining-enabled", true),
 		MetricsAddr:                     LoadConfigString(confReader, "web", "metrics-addr"),
 		KFPollInterval:                  LoadOptionalConfigDuration(confReader, "web", "kf-poll-interval", 30*time.Second),
 		NMetricSamples:                  LoadOptionalConfigInt(confReader, "web", "n-metric-samples", 20),
+		ProcessMetricsInterval:          LoadOptionalConfigDuration(confReader, "web", "process-metrics-interval", 30*time.Second),
+		MetricsExemplars:                LoadOptionalConfigBool(confReader, "web", "metrics-exemplars", false),
 		ShutdownTimeout:                 LoadConfigDuration(confReader, "l3afd", "shutdown-timeout"),
 		SwaggerApiEnabled:               LoadOptionalConfigBool(confReader, "l3afd", "swagger-api-enabled", false),
 		Environment:                     LoadOptionalConfigString(confReader, "l3afd", "environment", ENV_PROD),
@@ -129,6 +581,7 @@ func ReadConfig(configPath string) (*Config, error) {
 		XDPRootProgramCommand:           LoadOptionalConfigString(confReader, "xdp-root-program", "command", "xdp_root"),
 		XDPRootProgramVersion:           LoadOptionalConfigString(confReader, "xdp-root-program", "version", "1.01"),
 		XDPRootProgramUserProgramDaemon: LoadOptionalConfigBool(confReader, "xdp-root-program", "user-program-daemon", false),
+		XDPRootProgramCapabilities:      LoadOptionalConfigStringCSV(confReader, "xdp-root-program", "capabilities", nil),
 		TCRootProgramName:               LoadOptionalConfigString(confReader, "tc-root-program", "name", "tc_root"),
 		TCRootProgramArtifact:           LoadOptionalConfigString(confReader, "tc-root-program", "artifact", "l3af_tc_root.tar.gz"),
 		TCRootProgramIngressMapName:     LoadOptionalConfigString(confReader, "tc-root-program", "ingress-map-name", "/sys/fs/bpf/tc/globals/tc_ingress_root_array"),
@@ -136,9 +589,24 @@ func ReadConfig(configPath string) (*Config, error) {
 		TCRootProgramCommand:            LoadOptionalConfigString(confReader, "tc-root-program", "command", "tc_root"),
 		TCRootProgramVersion:            LoadOptionalConfigString(confReader, "tc-root-program", "version", "1.0"),
 		TCRootProgramUserProgramDaemon:  LoadOptionalConfigBool(confReader, "tc-root-program", "user-program-daemon", false),
+		TCRootProgramCapabilities:       LoadOptionalConfigStringCSV(confReader, "tc-root-program", "capabilities", nil),
+		RootFlavors:                     rootFlavors,
+		IfaceGroups:                     ifaceGroups,
+		ChainTemplates:                  chainTemplates,
 		EBPFChainDebugAddr:              LoadOptionalConfigString(confReader, "ebpf-chain-debug", "addr", "0.0.0.0:8899"),
 		EBPFChainDebugEnabled:           LoadOptionalConfigBool(confReader, "ebpf-chain-debug", "enabled", false),
+		TapMapDir:                       LoadOptionalConfigString(confReader, "tap", "map-dir", "/sys/fs/bpf/tap"),
+		TapPcapDir:                      LoadOptionalConfigString(confReader, "tap", "pcap-dir", "/var/log/l3afd/tap"),
+		TapCollectors:                   LoadOptionalConfigStringCSV(confReader, "tap", "collectors", nil),
+		ChainSelfTestEnabled:            LoadOptionalConfigBool(confReader, "chain-self-test", "enabled", false),
+		ChainSelfTestRepeat:             LoadOptionalConfigInt(confReader, "chain-self-test", "repeat", 1000),
+		ChainSelfTestMaxLatency:         LoadOptionalConfigDuration(confReader, "chain-self-test", "max-latency", 0),
+		ChainHitsMapDir:                 LoadOptionalConfigString(confReader, "chain-hits", "map-dir", "/sys/fs/bpf/chain_hits"),
+		ChainHitsInterval:               LoadOptionalConfigDuration(confReader, "chain-hits", "interval", 0),
+		NodeLabels:                      LoadOptionalConfigStringCSV(confReader, "node", "labels", nil),
 		L3afConfigsRestAPIAddr:          LoadOptionalConfigString(confReader, "l3af-configs", "restapi-addr", "localhost:53000"),
+		L3afConfigsSocket:               LoadOptionalConfigString(confReader, "l3af-configs", "socket", "/var/run/l3afd/l3afd.sock"),
+		L3afConfigsSocketGroup:          LoadOptionalConfigString(confReader, "l3af-configs", "socket-group", ""),
 		L3afConfigStoreFileName:         LoadOptionalConfigString(confReader, "l3af-config-store", "filename", "/etc/l3afd/l3af-config.json"),
 		MTLSEnabled:                     LoadOptionalConfigBool(confReader, "mtls", "enabled", true),
 		MTLSMinVersion:                  minTLSVersion,
@@ -146,11 +614,382 @@ func ReadConfig(configPath string) (*Config, error) {
 		MTLSCACertFilename:              LoadOptionalConfigString(confReader, "mtls", "cacert-filename", "ca.pem"),
 		MTLSServerCertFilename:          LoadOptionalConfigString(confReader, "mtls", "server-cert-filename", "server.crt"),
 		MTLSServerKeyFilename:           LoadOptionalConfigString(confReader, "mtls", "server-key-filename", "server.key"),
+		TenantQuotas:                    loadTenantQuotas(confReader),
+		BTFHubURL:                       LoadOptionalConfigString(confReader, "btf", "hub-url", ""),
+		BTFDir:                          LoadOptionalConfigString(confReader, "btf", "dir", "/var/l3afd/btf"),
+		IfaceQueues:                     loadIfaceQueues(confReader),
+		KubernetesEnabled:               LoadOptionalConfigBool(confReader, "kubernetes", "enabled", false),
+		KubernetesNamespace:             LoadOptionalConfigString(confReader, "kubernetes", "namespace", "l3afd"),
+		KubernetesResource:              LoadOptionalConfigString(confReader, "kubernetes", "resource", "crd"),
+		KubernetesNodeName:              LoadOptionalConfigString(confReader, "kubernetes", "node-name", os.Getenv("NODE_NAME")),
+		KubernetesResyncInterval:        LoadOptionalConfigDuration(confReader, "kubernetes", "resync-interval", 5*time.Minute),
+		HeartbeatURL:                    LoadOptionalConfigString(confReader, "heartbeat", "url", ""),
+		HeartbeatInterval:               LoadOptionalConfigDuration(confReader, "heartbeat", "interval", time.Minute),
+		PeeringEnabled:                  LoadOptionalConfigBool(confReader, "peering", "enabled", false),
+		PeeringRole:                     LoadOptionalConfigString(confReader, "peering", "role", "active"),
+		PeeringPeerURL:                  LoadOptionalConfigString(confReader, "peering", "peer-url", ""),
+		PeeringPollInterval:             LoadOptionalConfigDuration(confReader, "peering", "poll-interval", 10*time.Second),
+		PeeringFailoverTimeout:          LoadOptionalConfigDuration(confReader, "peering", "failover-timeout", 0),
+		ArtifactPeersEnabled:            LoadOptionalConfigBool(confReader, "artifact-peers", "enabled", false),
+		ArtifactPeers:                   LoadOptionalConfigStringCSV(confReader, "artifact-peers", "peers", nil),
+		ArtifactPeersTokenFile:          LoadOptionalConfigString(confReader, "artifact-peers", "token-file", ""),
+		ArtifactPeersTimeout:            LoadOptionalConfigDuration(confReader, "artifact-peers", "timeout", 30*time.Second),
+		ArtifactAllowlistEnabled:        LoadOptionalConfigBool(confReader, "artifact-allowlist", "enabled", false),
+		ArtifactAllowlist:               loadArtifactAllowlist(confReader),
+		ArtifactAllowlistManifestFile:   LoadOptionalConfigString(confReader, "artifact-allowlist", "manifest-file", ""),
+		ArtifactAllowlistPublicKeyFile:  LoadOptionalConfigString(confReader, "artifact-allowlist", "public-key-file", ""),
+		WebhookTargets:                  LoadOptionalConfigStringCSV(confReader, "webhooks", "targets", nil),
+		WebhookEvents:                   LoadOptionalConfigStringCSV(confReader, "webhooks", "events", nil),
+		WebhookSecretFile:               LoadOptionalConfigString(confReader, "webhooks", "secret-file", ""),
+		WebhookTimeout:                  LoadOptionalConfigDuration(confReader, "webhooks", "timeout", 10*time.Second),
+		WebhookQueueSize:                LoadOptionalConfigInt(confReader, "webhooks", "queue-size", 1000),
+		WebhookMaxRetries:               LoadOptionalConfigInt(confReader, "webhooks", "max-retries", 5),
+		WebhookRetryInterval:            LoadOptionalConfigDuration(confReader, "webhooks", "retry-interval", 10*time.Second),
+		ChaosEnabled:                    LoadOptionalConfigBool(confReader, "chaos", "enabled", false),
+		AuditLogFile:                    LoadOptionalConfigString(confReader, "audit", "log-file", ""),
+		MapEntryReconcilePolicy:         LoadOptionalConfigString(confReader, "map-entries", "reconcile-policy", "overwrite"),
+		ProgramArgSchemas:               argSchemas,
+		StrictProgramArgs:               LoadOptionalConfigBool(confReader, "program-args", "strict", false),
+		DirectionDefaultArgs:            directionArgs,
+		NFCommandStartTimeout:           LoadOptionalConfigDuration(confReader, "nf-commands", "start-timeout", time.Minute),
+		NFCommandStopTimeout:            LoadOptionalConfigDuration(confReader, "nf-commands", "stop-timeout", 30*time.Second),
+		NFCommandStatusTimeout:          LoadOptionalConfigDuration(confReader, "nf-commands", "status-timeout", 10*time.Second),
+		NFCommandEnv:                    LoadOptionalConfigStringCSV(confReader, "nf-commands", "environment", nil),
+		NFStopGracePeriod:               LoadOptionalConfigDuration(confReader, "nf-commands", "stop-grace-period", 30*time.Second),
+		NFStartDeadline:                 LoadOptionalConfigDuration(confReader, "nf-commands", "start-deadline", 3*time.Minute),
+		NFControlSocketDir:              LoadOptionalConfigString(confReader, "nf-commands", "control-socket-dir", "/var/run/l3afd/nf"),
+		NFHeartbeatInterval:             LoadOptionalConfigDuration(confReader, "nf-commands", "heartbeat-interval", 5*time.Second),
+		NFTaskTimeout:                   LoadOptionalConfigDuration(confReader, "nf-commands", "task-timeout", time.Minute),
+		NFStandbyMapDir:                 LoadOptionalConfigString(confReader, "nf-commands", "standby-map-dir", "/sys/fs/bpf/l3afd/standby"),
+		NFConsumedMapDir:                LoadOptionalConfigString(confReader, "nf-commands", "consumed-map-dir", "/sys/fs/bpf/l3afd/consumed"),
+		BPFGatekeeperEnabled:            LoadOptionalConfigBool(confReader, "bpf-gatekeeper", "enabled", false),
+		BPFGatekeeperName:               LoadOptionalConfigString(confReader, "bpf-gatekeeper", "name", "bpf-gatekeeper"),
+		BPFGatekeeperArtifact:           LoadOptionalConfigString(confReader, "bpf-gatekeeper", "artifact", "l3af_bpf_gatekeeper.tar.gz"),
+		BPFGatekeeperVersion:            LoadOptionalConfigString(confReader, "bpf-gatekeeper", "version", ""),
+		BPFGatekeeperObject:             LoadOptionalConfigString(confReader, "bpf-gatekeeper", "object", "bpf_gatekeeper.bpf.o"),
+		BPFGatekeeperProgram:            LoadOptionalConfigString(confReader, "bpf-gatekeeper", "program", "bpf_gatekeeper"),
+		BPFGatekeeperMap:                LoadOptionalConfigString(confReader, "bpf-gatekeeper", "map", "allowed_exe"),
+		BPFGatekeeperPinDir:             LoadOptionalConfigString(confReader, "bpf-gatekeeper", "pin-dir", "/sys/fs/bpf/l3afd/gatekeeper"),
+		BPFGatekeeperAllowedBinaries:    LoadOptionalConfigStringCSV(confReader, "bpf-gatekeeper", "allowed-binaries", nil),
+		NFSandboxEnabled:                LoadOptionalConfigBool(confReader, "nf-sandbox", "enabled", false),
+		NFSandboxLandlock:               LoadOptionalConfigBool(confReader, "nf-sandbox", "landlock", true),
+		NFSandboxRoot:                   LoadOptionalConfigString(confReader, "nf-sandbox", "root", "/var/run/l3afd/sandbox"),
+		NFSandboxReadOnlyPaths:          LoadOptionalConfigStringCSV(confReader, "nf-sandbox", "read-only-paths", []string{"/bin", "/lib", "/lib64", "/usr", "/etc/ld.so.cache", "/sys"}),
+		NFSandboxReadWritePaths:         LoadOptionalConfigStringCSV(confReader, "nf-sandbox", "read-write-paths", nil),
+		VersionSkewCheckInterval:        LoadOptionalConfigDuration(confReader, "version-skew", "check-interval", 5*time.Minute),
+		ArtifactPrefetchParallelism:     LoadOptionalConfigInt(confReader, "artifacts", "prefetch-parallelism", 4),
+		ChainLimits:                     chainLimits,
+		IfaceChainLimits:                loadIfaceChainLimits(confReader, chainLimits),
+		PausedProgramsFileName:          LoadOptionalConfigString(confReader, "l3af-config-store", "paused-filename", "/etc/l3afd/l3af-paused.json"),
+		DisabledProgramsFileName:        LoadOptionalConfigString(confReader, "l3af-config-store", "disabled-filename", "/etc/l3afd/l3af-disabled.json"),
+		ConfigFreezeEnabled:             LoadOptionalConfigBool(confReader, "config-freeze", "enabled", false),
+		ConfigFreezeReason:              LoadOptionalConfigString(confReader, "config-freeze", "reason", ""),
+		ConfigFreezeFileName:            LoadOptionalConfigString(confReader, "l3af-config-store", "freeze-filename", "/etc/l3afd/l3af-freeze.json"),
+		L3afConfigsAllowedCIDRs:         configsAllowedCIDRs,
+		MetricsAllowedCIDRs:             metricsAllowedCIDRs,
+		EBPFChainDebugAllowedCIDRs:      debugAllowedCIDRs,
+		L3afConfigsMaxBodySize:          int64(LoadOptionalConfigInt(confReader, "l3af-configs", "max-body-size", 10<<20)),
+		L3afConfigsRateLimit:            LoadOptionalConfigFloat(confReader, "l3af-configs", "rate-limit", 10),
+		L3afConfigsRateBurst:            LoadOptionalConfigInt(confReader, "l3af-configs", "rate-burst", 20),
+		ConfigGenerationFileName:        LoadOptionalConfigString(confReader, "l3af-config-store", "generation-filename", "/etc/l3afd/l3af-generation.json"),
+		ConfigHistoryFileName:           LoadOptionalConfigString(confReader, "l3af-config-store", "history-filename", "/etc/l3afd/l3af-history.json"),
+		ConfigHistoryMaxEntries:         LoadOptionalConfigInt(confReader, "config-history", "max-entries", 20),
+		MemlockEnabled:                  LoadOptionalConfigBool(confReader, "memlock", "enabled", true),
+		MemlockLimit:                    LoadOptionalConfigInt(confReader, "memlock", "limit", 0),
+		ContainerMode:                   LoadOptionalConfigString(confReader, "container", "mode", "auto"),
+		ContainerHostNetNSPath:          LoadOptionalConfigString(confReader, "container", "host-netns-path", ""),
+		EventLogLevel:                   LoadOptionalConfigString(confReader, "windows-service", "event-log-level", "warn"),
+		DoctorOnStartup:                 LoadOptionalConfigBool(confReader, "doctor", "on-startup", true),
+		DoctorFailOnError:               LoadOptionalConfigBool(confReader, "doctor", "fail-on-error", false),
+		DoctorMinFreeDiskMB:             LoadOptionalConfigInt(confReader, "doctor", "min-free-disk-mb", 1024),
+		EncryptionEnabled:               LoadOptionalConfigBool(confReader, "encryption", "enabled", false),
+		EncryptionKeyFile:               LoadOptionalConfigString(confReader, "encryption", "key-file", ""),
+		EncryptionKeyCommand:            strings.Fields(LoadOptionalConfigString(confReader, "encryption", "key-command", "")),
+		NFFilesDir:                      LoadOptionalConfigString(confReader, "nf-files", "dir", ""),
+		NFFilesMountTmpfs:               LoadOptionalConfigBool(confReader, "nf-files", "mount-tmpfs", false),
+		NFFilesTmpfsSize:                LoadOptionalConfigString(confReader, "nf-files", "tmpfs-size", "64m"),
+		MetricsTLSEnabled:               LoadOptionalConfigBool(confReader, "metrics", "tls-enabled", false),
+		MetricsTLSMinVersion:            metricsMinTLSVersion,
+		MetricsTLSCertFile:              LoadOptionalConfigString(confReader, "metrics", "tls-cert-file", ""),
+		MetricsTLSKeyFile:               LoadOptionalConfigString(confReader, "metrics", "tls-key-file", ""),
+		MetricsTLSClientCAFile:          LoadOptionalConfigString(confReader, "metrics", "tls-client-ca-file", ""),
+		MetricsBasicAuthUsername:        LoadOptionalConfigString(confReader, "metrics", "basic-auth-username", ""),
+		MetricsBasicAuthPasswordFile:    LoadOptionalConfigString(confReader, "metrics", "basic-auth-password-file", ""),
+		ReconcileInterval:               LoadOptionalConfigDuration(confReader, "reconcile", "interval", 5*time.Minute),
+		AttachOnLinkUp:                  LoadOptionalConfigBool(confReader, "link-state", "attach-on-up", true),
+		ApplyWindow:                     LoadOptionalConfigString(confReader, "apply-window", "window", ""),
+		ApplyWindowCheckInterval:        LoadOptionalConfigDuration(confReader, "apply-window", "check-interval", time.Minute),
+		MonitorMapsPeriod:               LoadOptionalConfigDuration(confReader, "web", "monitor-maps-period", time.Second),
+		MonitorMapsBudget:               LoadOptionalConfigDuration(confReader, "web", "monitor-maps-budget", 0),
+		MonitorMapsHistoryRetention:     LoadOptionalConfigDuration(confReader, "web", "monitor-maps-history-retention", time.Hour),
+		MonitorMapsHistorySamples:       LoadOptionalConfigInt(confReader, "web", "monitor-maps-history-samples", 3600),
+		CrashForensicsEnabled:           LoadOptionalConfigBool(confReader, "crash-forensics", "enabled", false),
+		CrashForensicsDir:               LoadOptionalConfigString(confReader, "crash-forensics", "dir", ""),
+		CrashForensicsOutputLines:       LoadOptionalConfigInt(confReader, "crash-forensics", "output-lines", 200),
+		CrashForensicsMaxBundles:        LoadOptionalConfigInt(confReader, "crash-forensics", "max-bundles", 50),
+		CoreDumpsDir:                    LoadOptionalConfigString(confReader, "core-dumps", "dir", ""),
+		CoreDumpsMaxSizeMB:              LoadOptionalConfigInt(confReader, "core-dumps", "max-size-mb", 1024),
+		LogOutputs:                      LoadOptionalConfigStringCSV(confReader, "logging", "outputs", []string{"console"}),
+		LogLevel:                        LoadOptionalConfigString(confReader, "logging", "level", ""),
+		LogModuleLevels:                 LoadOptionalConfigStringCSV(confReader, "logging", "module-levels", nil),
+		LogSyslogNetwork:                LoadOptionalConfigString(confReader, "logging", "syslog-network", "unixgram"),
+		LogSyslogAddress:                LoadOptionalConfigString(confReader, "logging", "syslog-address", "/dev/log"),
+		LogSyslogFacility:               LoadOptionalConfigString(confReader, "logging", "syslog-facility", "daemon"),
+		LogJournaldSocket:               LoadOptionalConfigString(confReader, "logging", "journald-socket", "/run/systemd/journal/socket"),
+		MonitorMapsAllow:                LoadOptionalConfigStringCSV(confReader, "monitor-maps", "allow", nil),
+		MonitorMapsDeny:                 LoadOptionalConfigStringCSV(confReader, "monitor-maps", "deny", nil),
+		MonitorMapsMaxSeriesPerProgram:  LoadOptionalConfigInt(confReader, "monitor-maps", "max-series-per-program", 0),
+		MonitorMapsSeriesBudget:         LoadOptionalConfigInt(confReader, "monitor-maps", "series-budget", 0),
+		XDPOffloadDrivers:               LoadOptionalConfigStringCSV(confReader, "xdp-offload", "drivers", nil),
+		XDPOffloadFallback:              offloadFallback,
 	}, nil
 }
 
-func loadTLSVersion(cfgRdr *config.Config, fieldName string) (uint16, error) {
-	ver := strings.TrimSpace(LoadOptionalConfigString(cfgRdr, "mTLS", fieldName, "TLS_1.3"))
+// loadTenantQuotas reads all the tenant-quota.<tenant> groups
+func loadTenantQuotas(cfgRdr *config.Config) map[string]TenantQuota {
+	quotas := make(map[string]TenantQuota)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, tenantQuotaGroupPrefix) {
+			continue
+		}
+		tenant := strings.TrimPrefix(group, tenantQuotaGroupPrefix)
+		quotas[tenant] = TenantQuota{
+			MaxPrograms:  LoadOptionalConfigInt(cfgRdr, group, "max-programs", 0),
+			MaxMapMemory: LoadOptionalConfigInt(cfgRdr, group, "max-map-memory", 0),
+			MaxCPU:       LoadOptionalConfigInt(cfgRdr, group, "max-cpu", 0),
+		}
+	}
+	return quotas
+}
+
+// loadKFRepoMirrors reads all the kf-repo-mirror.<name> groups, mirrors without url are ignored
+func loadKFRepoMirrors(cfgRdr *config.Config) map[string]KFRepoMirror {
+	mirrors := make(map[string]KFRepoMirror)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, kfRepoMirrorGroupPrefix) {
+			continue
+		}
+		mirror := KFRepoMirror{
+			URL:      LoadOptionalConfigString(cfgRdr, group, "url", ""),
+			Priority: LoadOptionalConfigInt(cfgRdr, group, "priority", 0),
+		}
+		if len(mirror.URL) == 0 {
+			continue
+		}
+		mirrors[strings.TrimPrefix(group, kfRepoMirrorGroupPrefix)] = mirror
+	}
+	return mirrors
+}
+
+// loadArtifactAllowlist reads all the artifact-allowlist.<program> groups
+func loadArtifactAllowlist(cfgRdr *config.Config) map[string]AllowedArtifacts {
+	allowlist := make(map[string]AllowedArtifacts)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, artifactAllowlistGroupPrefix) {
+			continue
+		}
+		program := strings.TrimPrefix(group, artifactAllowlistGroupPrefix)
+		allowlist[program] = AllowedArtifacts{
+			Artifacts: LoadOptionalConfigStringCSV(cfgRdr, group, "artifacts", nil),
+			Versions:  LoadOptionalConfigStringCSV(cfgRdr, group, "versions", nil),
+			SHA256:    LoadOptionalConfigStringCSV(cfgRdr, group, "sha256", nil),
+		}
+	}
+	return allowlist
+}
+
+// loadIfaceQueues reads all the iface-queues.<iface> groups
+func loadIfaceQueues(cfgRdr *config.Config) map[string]IfaceQueues {
+	queues := make(map[string]IfaceQueues)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, ifaceQueuesGroupPrefix) {
+			continue
+		}
+		iface := strings.TrimPrefix(group, ifaceQueuesGroupPrefix)
+		queues[iface] = IfaceQueues{
+			CombinedChannels: LoadOptionalConfigInt(cfgRdr, group, "combined-channels", 0),
+			RxChannels:       LoadOptionalConfigInt(cfgRdr, group, "rx-channels", 0),
+			TxChannels:       LoadOptionalConfigInt(cfgRdr, group, "tx-channels", 0),
+			RSSQueues:        LoadOptionalConfigInt(cfgRdr, group, "rss-queues", 0),
+		}
+	}
+	return queues
+}
+
+// loadRootFlavors reads all the root-flavor.<flavor> groups, an interface is of a single flavor
+func loadRootFlavors(cfgRdr *config.Config) (map[string]RootFlavor, error) {
+	flavors := make(map[string]RootFlavor)
+	ifaces := make(map[string]string)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, rootFlavorGroupPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(group, rootFlavorGroupPrefix)
+		flavor := RootFlavor{
+			Ifaces:           LoadOptionalConfigStringCSV(cfgRdr, group, "ifaces", nil),
+			XDPArtifact:      LoadOptionalConfigString(cfgRdr, group, "xdp-artifact", ""),
+			XDPVersion:       LoadOptionalConfigString(cfgRdr, group, "xdp-version", ""),
+			XDPCommand:       LoadOptionalConfigString(cfgRdr, group, "xdp-command", ""),
+			XDPMapName:       LoadOptionalConfigString(cfgRdr, group, "xdp-ingress-map-name", ""),
+			XDPCapabilities:  LoadOptionalConfigStringCSV(cfgRdr, group, "xdp-capabilities", nil),
+			TCArtifact:       LoadOptionalConfigString(cfgRdr, group, "tc-artifact", ""),
+			TCVersion:        LoadOptionalConfigString(cfgRdr, group, "tc-version", ""),
+			TCCommand:        LoadOptionalConfigString(cfgRdr, group, "tc-command", ""),
+			TCIngressMapName: LoadOptionalConfigString(cfgRdr, group, "tc-ingress-map-name", ""),
+			TCEgressMapName:  LoadOptionalConfigString(cfgRdr, group, "tc-egress-map-name", ""),
+			TCCapabilities:   LoadOptionalConfigStringCSV(cfgRdr, group, "tc-capabilities", nil),
+		}
+		for _, iface := range flavor.Ifaces {
+			if other, ok := ifaces[iface]; ok {
+				return nil, fmt.Errorf("interface %s is of root flavors %s and %s", iface, other, name)
+			}
+			ifaces[iface] = name
+		}
+		flavors[name] = flavor
+	}
+	return flavors, nil
+}
+
+// loadIfaceGroups reads all the iface-group.<group> groups, a group has at least one interface
+func loadIfaceGroups(cfgRdr *config.Config) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, ifaceGroupGroupPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(group, ifaceGroupGroupPrefix)
+		var ifaces []string
+		for _, iface := range LoadOptionalConfigStringCSV(cfgRdr, group, "ifaces", nil) {
+			if iface = strings.TrimSpace(iface); len(iface) > 0 {
+				ifaces = append(ifaces, iface)
+			}
+		}
+		if len(ifaces) == 0 {
+			return nil, fmt.Errorf("interface group %s has no interfaces", name)
+		}
+		groups[name] = ifaces
+	}
+	return groups, nil
+}
+
+// loadChainTemplates reads all the chain-template.<template> groups, a template has the file of its programs
+func loadChainTemplates(cfgRdr *config.Config) (map[string]string, error) {
+	templates := make(map[string]string)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, chainTemplateGroupPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(group, chainTemplateGroupPrefix)
+		file := strings.TrimSpace(LoadOptionalConfigString(cfgRdr, group, "file", ""))
+		if len(file) == 0 {
+			return nil, fmt.Errorf("chain template %s has no file", name)
+		}
+		templates[name] = file
+	}
+	return templates, nil
+}
+
+// loadCIDRs reads the comma separated list of CIDRs, single addresses are accepted as host CIDRs
+func loadCIDRs(cfgRdr *config.Config, group, key string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, v := range LoadOptionalConfigStringCSV(cfgRdr, group, key, nil) {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s of group %s: %w", key, group, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// loadIfaceChainLimits reads all the chain-limits.<iface> groups, missing values are the global limits
+func loadIfaceChainLimits(cfgRdr *config.Config, global ChainLimits) map[string]ChainLimits {
+	limits := make(map[string]ChainLimits)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, chainLimitsGroupPrefix) {
+			continue
+		}
+		iface := strings.TrimPrefix(group, chainLimitsGroupPrefix)
+		limits[iface] = ChainLimits{
+			MaxChainLength: LoadOptionalConfigInt(cfgRdr, group, "max-chain-length", global.MaxChainLength),
+			MaxPrograms:    LoadOptionalConfigInt(cfgRdr, group, "max-programs", global.MaxPrograms),
+		}
+	}
+	return limits
+}
+
+// loadArgSchemas reads all the program-args.<program> groups, option values are regular expressions
+// the argument values must match
+func loadArgSchemas(cfgRdr *config.Config) (map[string]ArgSchema, error) {
+	schemas := make(map[string]ArgSchema)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, programArgsGroupPrefix) {
+			continue
+		}
+		program := strings.TrimPrefix(group, programArgsGroupPrefix)
+		options, err := cfgRdr.Options(group)
+		if err != nil {
+			return nil, err
+		}
+		schema := make(ArgSchema)
+		for _, option := range options {
+			// regular expressions are not unfolded
+			expr, err := cfgRdr.RawString(group, option)
+			if err != nil {
+				return nil, err
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression of argument %s of program %s: %w", option, program, err)
+			}
+			schema[option] = re
+		}
+		schemas[program] = schema
+	}
+	return schemas, nil
+}
+
+// loadDirectionArgs reads all the direction-args.<direction> groups, option values are the default args of the
+// programs of the direction
+func loadDirectionArgs(cfgRdr *config.Config) (map[string]map[string]string, error) {
+	directionArgs := make(map[string]map[string]string)
+	for _, group := range cfgRdr.Sections() {
+		if !strings.HasPrefix(group, directionArgsGroupPrefix) {
+			continue
+		}
+		direction := strings.TrimPrefix(group, directionArgsGroupPrefix)
+		switch direction {
+		case "xdpingress", "ingress", "egress":
+		default:
+			return nil, fmt.Errorf("unknown direction %s of group %s", direction, group)
+		}
+		options, err := cfgRdr.SectionOptions(group)
+		if err != nil {
+			return nil, err
+		}
+		args := make(map[string]string)
+		for _, option := range options {
+			args[option] = LoadOptionalConfigString(cfgRdr, group, option, "")
+		}
+		directionArgs[direction] = args
+	}
+	return directionArgs, nil
+}
+
+func loadTLSVersion(cfgRdr *config.Config, group, fieldName string) (uint16, error) {
+	ver := strings.TrimSpace(LoadOptionalConfigString(cfgRdr, group, fieldName, "TLS_1.3"))
 	switch ver {
 	case "", "Default", "default":
 		return tls.VersionTLS13, nil
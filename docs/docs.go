@@ -16,6 +16,203 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/l3af/applies/v1": {
+            "get": {
+                "description": "Returns the version upgrades and chain reorders of the programs queued outside their apply window",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the updates queued until the apply window of the program opens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDPendingApply"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/artifacts/v1/prefetch": {
+            "post": {
+                "description": "Pre-stages the artifacts in parallel without starting the programs, so the rollout is not waiting for downloads",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Downloads the artifacts of upcoming configs into the artifact cache",
+                "parameters": [
+                    {
+                        "description": "artifacts",
+                        "name": "artifacts",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDArtifact"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDPrefetchResult"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/artifacts/v1/{name}/{progVersion}/{platform}/{artifact}": {
+            "get": {
+                "description": "Returns the archive of the verified artifact to the peers of the site presenting the shared bearer token, when artifact sharing is enabled",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "summary": "Downloads a verified artifact of the node cache",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program version",
+                        "name": "progVersion",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "platform of the artifact",
+                        "name": "platform",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "artifact name",
+                        "name": "artifact",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/chains/v1/{iface}/graph": {
+            "get": {
+                "description": "Returns the root program, the chained programs with their seq ids and prog ids, and the prog maps and shared maps linking them, as json or in the Graphviz DOT language",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the graph of the programs chained on the interface",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "json or dot, json by default",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDChainGraph"
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/chaos/v1": {
+            "get": {
+                "description": "Returns the pending faults",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the pending faults",
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            },
+            "post": {
+                "description": "Injects artifact download failure, map pin delay or kills the NF process, fault injection must be enabled in the config",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Injects a fault into the NF lifecycle",
+                "parameters": [
+                    {
+                        "description": "fault",
+                        "name": "fault",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDFault"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the pending faults",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Removes the pending faults",
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
         "/l3af/configs/v1": {
             "get": {
                 "description": "Returns details of the configuration of eBPF Programs for all interfaces on a node",
@@ -33,6 +230,121 @@ const docTemplate = `{
                 }
             }
         },
+        "/l3af/configs/v1/effective": {
+            "get": {
+                "description": "Returns the configs of the interfaces with the assigned seq ids, the l3afd defaults of the unset program fields, the artifact urls of the platform, the paused programs, the configs waiting for the link and the updates waiting for the apply window",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the configuration l3afd acts on with the defaults resolved",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDEffectiveConfig"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/configs/v1/history": {
+            "get": {
+                "description": "Returns the revisions of the last applied configs without the configs, the latest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the revisions of the config history",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDConfigRevision"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/configs/v1/history/diff": {
+            "get": {
+                "description": "Returns the added, removed and changed programs with the changed fields, to defaults to the latest revision and from to the revision before to",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the changes of the programs between two revisions of the config history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "revision compared from",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "revision compared to",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDConfigDiff"
+                        }
+                    },
+                    "404": {
+                        "description": "revision is not in the history"
+                    }
+                }
+            }
+        },
+        "/l3af/configs/v1/history/{revision}": {
+            "get": {
+                "description": "Returns the configs applied in the revision, 0 is the latest revision",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns a revision of the config history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "revision",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDConfigRevision"
+                        }
+                    },
+                    "404": {
+                        "description": "revision is not in the history"
+                    }
+                }
+            }
+        },
         "/l3af/configs/v1/update": {
             "post": {
                 "description": "Update eBPF Programs configuration",
@@ -55,11 +367,32 @@ const docTemplate = `{
                                 "$ref": "#/definitions/models.L3afBPFPrograms"
                             }
                         }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "config generation, replays of the applied generation return the recorded result",
+                        "name": "X-Config-Generation",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "W3C trace context of the caller, the trace id is the exemplar of the apply metrics",
+                        "name": "traceparent",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": ""
+                    },
+                    "409": {
+                        "description": "another config apply is in progress or the config generation is older than the applied generation"
+                    },
+                    "422": {
+                        "description": "chain limit exceeded"
+                    },
+                    "403": {
+                        "description": "artifact is not permitted by the artifact allowlist"
                     }
                 }
             }
@@ -89,114 +422,1941 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.BPFProgram": {
+        },
+        "/l3af/doctor/v1": {
+            "get": {
+                "description": "Checks the bpf filesystem, kernel version, BTF, capabilities, memlock limit, XDP support of the driver of the configured interfaces, KF repo reachability and free disk space",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Runs the preflight diagnostics of the node",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDDoctorReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/freeze/v1": {
+            "get": {
+                "description": "Returns whether mutating config operations are rejected, the reason and the time of the freeze",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the config freeze state",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDFreeze"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Config applies and admin operations changing the programs are rejected with 423 Locked while frozen, supervision and metrics continue",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Freezes or unfreezes the config",
+                "parameters": [
+                    {
+                        "description": "frozen and reason",
+                        "name": "freeze",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDFreeze"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDFreeze"
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/incidents/v1": {
+            "get": {
+                "description": "Returns the incidents of the crash forensics bundles kept on the node, the latest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the crash incidents of the NF processes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDNFIncident"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/incidents/v1/{id}": {
+            "get": {
+                "description": "Returns the gzipped tar bundle with the exit status, last output lines, BPF kernel log lines and map details of the crashed NF",
+                "produces": [
+                    "application/gzip"
+                ],
+                "summary": "Downloads the crash forensics bundle of an incident",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "incident id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/links/v1": {
+            "get": {
+                "description": "Returns the interfaces attached and the interfaces whose config is queued until the link comes up",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the link state of the interfaces in the config",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDLinkStatus"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/logging/v1": {
+            "get": {
+                "description": "Returns the level of the l3afd logs and the level overrides of the kf, apis and stats modules",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the log levels",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDLogLevels"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Changes the level of the l3afd logs when set and the level overrides of the modules in the request without restarting l3afd, an empty module level removes the override",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Changes the log levels",
+                "parameters": [
+                    {
+                        "description": "level and module levels",
+                        "name": "levels",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDLogLevels"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDLogLevels"
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/loglevel/v1": {
+            "get": {
+                "description": "Returns the level of the l3afd logs and the programs whose map lookups and chain updates are logged regardless of the level",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the log level and the programs with debug enabled",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDLogLevel"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Changes the level of the l3afd logs when set and replaces the programs with debug enabled when set, so a faulty program is debugged without restarting l3afd and losing its state",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Changes the log level and the programs with debug enabled",
+                "parameters": [
+                    {
+                        "description": "level and debug programs",
+                        "name": "level",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDLogLevel"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDLogLevel"
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/maps/v1/{iface}/{direction}/{program}": {
+            "get": {
+                "description": "Streams the map entries matching the key prefix and range filters, keys, values and filters are hex encoded in the map layout. The continue token of the response resumes the dump when the limit is reached.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the entries of an eBPF map of a running program",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "direction xdpingress, ingress or egress",
+                        "name": "direction",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "map name",
+                        "name": "map",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "hex key prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "hex start key, inclusive",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "hex end key, exclusive",
+                        "name": "end",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "maximum number of entries",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "continuation token",
+                        "name": "continue",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/maps/v1/{iface}/{program}/{map}/{key}": {
+            "put": {
+                "description": "Writes the typed key and value to the map bypassing the config push, the update is recorded in the audit log and reconciled on the next config apply",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Updates a single entry of an eBPF map of a running program",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "map name, pinned path is URL encoded",
+                        "name": "map",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "map key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "map entry",
+                        "name": "entry",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDMapEntryUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/metrics/v1/history": {
+            "get": {
+                "description": "Returns the samples of the monitor map metric of the programs kept in memory by l3afd, so the values sampled during an outage of the scrape pipeline can be queried",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the retained samples of a monitor map metric",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "metric name of the monitor map i.e. <map>_<key>_<aggregator>[_<field>]",
+                        "name": "metric",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name, all the programs when empty",
+                        "name": "program",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "time in RFC 3339 format or duration before now e.g. 15m, the whole retention when empty",
+                        "name": "since",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDMetricHistory"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/nfs/v1/paused": {
+            "get": {
+                "description": "Returns the programs detached from the chain by the pause API",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the paused programs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDPausedProgram"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/nfs/v1/{iface}/{direction}/{program}/pause": {
+            "post": {
+                "description": "Links the neighbours of the program in the chain and stops its process, the program stays paused on config applies until it is resumed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Detaches a program from the chain and stops it without removing it from the config",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "xdpingress, ingress or egress",
+                        "name": "direction",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/nfs/v1/{iface}/{direction}/{program}/resume": {
+            "post": {
+                "description": "Starts the paused program with its current config at its sequence position in the chain",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Starts a paused program and splices it into the chain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "xdpingress, ingress or egress",
+                        "name": "direction",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/numa/v1": {
+            "get": {
+                "description": "Returns the NUMA node of the NIC of every host interface and the CPUs of the node, same-node cpu affinity of the programs resolves to these CPUs",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the NUMA node of the NICs of the interfaces",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.L3afDIfaceNUMA"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/peering/v1": {
+            "get": {
+                "description": "Returns the peering role, the active peer followed by the standby and the readiness of the standby",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the peering state of the node",
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/peering/v1/activate": {
+            "post": {
+                "description": "Activates the standby node of the pair by deploying the last configs of the active peer",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Activates the standby node of the pair",
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/rules/v1/{iface}/{direction}/{program}": {
+            "patch": {
+                "description": "Applies the rule entries to the rules map and the rules file of the program without restarting it, the change is recorded in the audit log and saved to the config store",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Adds and removes rule entries of a running program",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "direction of the program",
+                        "name": "direction",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "rule entries to add and remove",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDRulesPatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDRulesPatchResult"
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/tap/v1": {
+            "get": {
+                "description": "Returns the running packet taps",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Returns the running packet taps",
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/tap/v1/{iface}": {
+            "post": {
+                "description": "Starts packet tap on the interface writing the sampled packets to a pcap file or streaming to a collector",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Starts packet tap on the interface",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "packet tap",
+                        "name": "tap",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDTap"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stops packet tap on the interface",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Stops packet tap on the interface",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/stats/v1/reset": {
+            "post": {
+                "description": "Zeroes the reset counters of the programs, drops the samples of their monitor maps and their metrics history, and resets their counter metrics, of all the programs when no program is set",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Resets the stats of the running programs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "xdpingress, ingress or egress",
+                        "name": "direction",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.L3afDStatsReset"
+                        }
+                    }
+                }
+            }
+        },
+        "/l3af/nfs/v1/{iface}/{direction}/{program}/activate": {
+            "post": {
+                "description": "Links the running program in standby into the chain at its sequence position without a restart, the program stays activated on config applies until its config is enabled or disabled",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Links a program in standby into the chain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "xdpingress, ingress or egress",
+                        "name": "direction",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        },
+        "/l3af/nfs/v1/{iface}/{direction}/{program}/standby": {
+            "post": {
+                "description": "Links the neighbours of the program activated by the API in the chain, the program keeps running in standby",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Unlinks an activated program from the chain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "interface name",
+                        "name": "iface",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "xdpingress, ingress or egress",
+                        "name": "direction",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "program name",
+                        "name": "program",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": ""
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.BPFProgram": {
+            "type": "object",
+            "properties": {
+                "admin_status": {
+                    "description": "Program admin status enabled or disabled",
+                    "type": "string"
+                },
+                "af_xdp": {
+                    "description": "AF_XDP sockets config of the user program",
+                    "$ref": "#/definitions/models.L3afDNFAFXDP"
+                },
+                "after": {
+                    "description": "Names of the programs chained before this program in the same direction",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "apply_window": {
+                    "description": "Window of the version upgrades and chain reorders e.g. Mon-Fri 02:00-04:00, l3afd window when empty",
+                    "type": "string"
+                },
+                "arg_schema_version": {
+                    "description": "Contract of the args l3afd passes to the start and stop commands, 1 flags, 2 JSON document on stdin, 3 control socket, 1 when 0",
+                    "type": "integer"
+                },
+                "artifact": {
+                    "description": "Artifact file name",
+                    "type": "string"
+                },
+                "bypass_on_failure": {
+                    "description": "Bypass the program in the chain when the restarts are exhausted",
+                    "type": "boolean"
+                },
+                "cfg_version": {
+                    "description": "Config version",
+                    "type": "integer"
+                },
+                "cmd_config": {
+                    "description": "Program config providing command",
+                    "type": "string"
+                },
+                "cmd_start": {
+                    "description": "Program start command",
+                    "type": "string"
+                },
+                "cmd_status": {
+                    "description": "Program status command",
+                    "type": "string"
+                },
+                "cmd_stop": {
+                    "description": "Program stop command",
+                    "type": "string"
+                },
+                "config_args": {
+                    "description": "Map of arguments to config command",
+                    "$ref": "#/definitions/models.L3afDNFArgs"
+                },
+                "config_file_path": {
+                    "description": "Config file location",
+                    "type": "string"
+                },
+                "consumed_maps": {
+                    "description": "Pinned maps of other programs used by this program",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDNFConsumedMap"
+                    }
+                },
+                "core_dumps": {
+                    "description": "Enable core dumps of the user program and collect them for post-mortem debugging",
+                    "type": "boolean"
+                },
+                "cpu": {
+                    "description": "User program cpu limits",
+                    "type": "integer"
+                },
+                "cpu_affinity": {
+                    "description": "CPUs the user program runs on e.g. 2-5,8 or same-node for the NUMA node of the NIC, all the CPUs when empty",
+                    "type": "string"
+                },
+                "dependencies": {
+                    "description": "Names of the programs required to be running before this program",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "external_stop": {
+                    "description": "Instances running outside l3afd are killed, only logged in dry-run or left running when disabled, kill by default",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Program id",
+                    "type": "integer"
+                },
+                "iface_addrs": {
+                    "description": "Pass IPv4 and IPv6 addresses of the interface to the start command",
+                    "type": "boolean"
+                },
+                "ionice_class": {
+                    "description": "I/O scheduling class of the user program, realtime, best-effort or idle",
+                    "type": "string"
+                },
+                "ionice_level": {
+                    "description": "I/O priority of the realtime and best-effort classes, 0 highest to 7 lowest",
+                    "type": "integer"
+                },
+                "is_plugin": {
+                    "description": "User program is plugin or not",
+                    "type": "boolean"
+                },
+                "map_args": {
+                    "description": "Config BPF Map of arguments",
+                    "$ref": "#/definitions/models.L3afDNFArgs"
+                },
+                "map_memory": {
+                    "description": "Bytes of locked memory of the BPF maps of the program, memlock limit inherited by the user program is raised to it",
+                    "type": "integer"
+                },
+                "map_name": {
+                    "description": "BPF map to store next program fd",
+                    "type": "string"
+                },
+                "memory": {
+                    "description": "User program memory limits",
+                    "type": "integer"
+                },
+                "monitor_maps": {
+                    "description": "Metrics BPF maps",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDNFMetricsMap"
+                    }
+                },
+                "name": {
+                    "description": "Name of the BPF program",
+                    "type": "string"
+                },
+                "nice": {
+                    "description": "Nice value of the user program, -20 to 19",
+                    "type": "integer"
+                },
+                "node_selector": {
+                    "description": "Expressions on the node labels e.g. region=us-west or kernel>=5.10, the program is applied on the nodes matching all of them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "pass_fds": {
+                    "description": "Pinned maps and programs opened by l3afd and inherited by the user program, so it needs no CAP_BPF to open them",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDNFPassedFD"
+                    }
+                },
+                "preserve_maps": {
+                    "description": "Maps whose entries are kept while the program is disabled and restored when it is enabled again",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "priority": {
+                    "description": "Priority class of the position in the chain, seq id is assigned by l3afd",
+                    "type": "string"
+                },
+                "prog_type": {
+                    "description": "Program type XDP or TC",
+                    "type": "string"
+                },
+                "requires_core": {
+                    "description": "Program uses CO-RE relocations and requires kernel BTF",
+                    "type": "boolean"
+                },
+                "rules": {
+                    "description": "Config rules",
+                    "type": "string"
+                },
+                "rules_file": {
+                    "description": "Config rules file name",
+                    "type": "string"
+                },
+                "rules_key_type": {
+                    "description": "Type of the rule keys, u8, u16, u32, u64, be16, be32, be64, ipv4, ipv6, mac or hex",
+                    "type": "string"
+                },
+                "rules_map": {
+                    "description": "Map the rule entries are written to, <key> [<value>] per rule, pinned path for TC programs",
+                    "type": "string"
+                },
+                "rules_schema": {
+                    "description": "JSON schema of the rules of the json-schema rules validator",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "rules_validator": {
+                    "description": "Validator of the rules, json, json-schema, cidr-list or command:<command of the artifact>, rules are not validated when empty",
+                    "type": "string"
+                },
+                "rules_value_type": {
+                    "description": "Type of the rule values, zero value when a rule has no value",
+                    "type": "string"
+                },
+                "seq_id": {
+                    "description": "Sequence position in the chain",
+                    "type": "integer"
+                },
+                "shared_maps": {
+                    "description": "Pinned maps shared with other programs, logical name to pin path",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "shared_pins": {
+                    "description": "Pin paths the program declares along with other programs",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "start_args": {
+                    "description": "Map of arguments to start command",
+                    "$ref": "#/definitions/models.L3afDNFArgs"
+                },
+                "status_args": {
+                    "description": "Map of arguments to status command",
+                    "$ref": "#/definitions/models.L3afDNFArgs"
+                },
+                "stop_args": {
+                    "description": "Map of arguments to stop command",
+                    "$ref": "#/definitions/models.L3afDNFArgs"
+                },
+                "stop_grace_period": {
+                    "description": "Seconds the user program may take to exit after SIGTERM before it is killed, l3afd default when 0",
+                    "type": "integer"
+                },
+                "tenant": {
+                    "description": "Tenant owning the program",
+                    "type": "string"
+                },
+                "test_vectors": {
+                    "description": "Test packets run through the program before it is started",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDNFTestVector"
+                    }
+                },
+                "user_program_daemon": {
+                    "description": "User program daemon or not",
+                    "type": "boolean"
+                },
+                "version": {
+                    "description": "Program version",
+                    "type": "string"
+                },
+                "watchdog": {
+                    "description": "Resource thresholds of the user program and the action taken when one is exceeded",
+                    "$ref": "#/definitions/models.L3afDNFWatchdog"
+                },
+                "heartbeat": {
+                    "description": "Map l3afd writes its liveness timestamp to, the kernel program fails open or closed when it is stale",
+                    "$ref": "#/definitions/models.L3afDNFHeartbeat"
+                },
+                "on_failure": {
+                    "description": "fail-closed keeps the kernel program in the chain when the user process dies, fail-open bypasses it until the process is restarted, fail-closed when empty",
+                    "type": "string"
+                },
+                "group": {
+                    "description": "Apply group of the programs of the interface applied atomically, in all their directions or in none",
+                    "type": "string"
+                },
+                "reset_counters": {
+                    "description": "Map counters zeroed by the stats reset of the program",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDNFCounter"
+                    }
+                },
+                "blue_green": {
+                    "description": "Version upgrades start the new version alongside the running one and swap it into the chain, stop then start when nil",
+                    "$ref": "#/definitions/models.L3afDNFBlueGreen"
+                },
+                "shadow": {
+                    "description": "Candidate version evaluated on copies of the packets of the program, its verdicts are ignored",
+                    "$ref": "#/definitions/models.L3afDNFShadow"
+                },
+                "requires_root": {
+                    "description": "Capabilities the root program of the interface must provide e.g. hw-offload",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "xdp_mode": {
+                    "description": "XDP attach mode generic, native or offload of the XDP program, mode of the loader of the program when empty",
+                    "type": "string"
+                },
+                "offload_fallback": {
+                    "description": "Mode native or generic the program of the offload XDP mode is started in on interfaces not supporting offload, or fail, xdp-offload fallback of l3afd.cfg when empty",
+                    "type": "string"
+                },
+                "queues": {
+                    "description": "RX queues of the NIC the program serves, all the queues when nil",
+                    "$ref": "#/definitions/models.L3afDNFQueues"
+                },
+                "tasks": {
+                    "description": "Commands of the artifact l3afd runs periodically while the program is running",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDNFTask"
+                    }
+                }
+            }
+        },
+        "models.BPFPrograms": {
+            "type": "object",
+            "properties": {
+                "tc_egress": {
+                    "description": "list of tc egress bpf programs",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BPFProgram"
+                    }
+                },
+                "tc_ingress": {
+                    "description": "list of tc ingress bpf programs",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BPFProgram"
+                    }
+                },
+                "xdp_ingress": {
+                    "description": "list of xdp ingress bpf programs",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BPFProgram"
+                    }
+                }
+            }
+        },
+        "models.L3afBPFPrograms": {
+            "type": "object",
+            "properties": {
+                "bpf_programs": {
+                    "description": "List of bpf programs",
+                    "$ref": "#/definitions/models.BPFPrograms"
+                },
+                "host_name": {
+                    "description": "Host name or pod name",
+                    "type": "string"
+                },
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
+                },
+                "template": {
+                    "description": "Chain template of l3afd.cfg the programs override",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDArtifact": {
+            "type": "object",
+            "properties": {
+                "artifact": {
+                    "description": "Artifact file name",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name of the BPF program",
+                    "type": "string"
+                },
+                "platform": {
+                    "description": "Platform of the artifact, platform of the node by default",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Program version",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDChainGraph": {
+            "type": "object",
+            "properties": {
+                "edges": {
+                    "description": "Maps linking the programs",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDChainGraphEdge"
+                    }
+                },
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
+                },
+                "nodes": {
+                    "description": "Programs in the chain order of the directions",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDChainGraphNode"
+                    }
+                }
+            }
+        },
+        "models.L3afDChainGraphEdge": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "Node id of the program owning the map",
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "chain or shared-map",
+                    "type": "string"
+                },
+                "map": {
+                    "description": "Pinned prog map of the chain or logical name of the shared map",
+                    "type": "string"
+                },
+                "to": {
+                    "description": "Node id of the program reached through or using the map",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDChainGraphNode": {
+            "type": "object",
+            "properties": {
+                "direction": {
+                    "description": "Direction of the chain, xdpingress, ingress or egress",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Node id, <direction>/<program name>",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Program name",
+                    "type": "string"
+                },
+                "prog_id": {
+                    "description": "eBPF program ID, 0 when not loaded",
+                    "type": "integer"
+                },
+                "seq_id": {
+                    "description": "Sequence position in the chain",
+                    "type": "integer"
+                },
+                "state": {
+                    "description": "root, running or bypassed",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Program version",
+                    "type": "string"
+                },
+                "flavor": {
+                    "description": "Root program flavor of the interface, set on the root node of the interface of a flavor",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDConfigChange": {
+            "type": "object",
+            "properties": {
+                "change": {
+                    "description": "added, removed or changed",
+                    "type": "string"
+                },
+                "direction": {
+                    "description": "xdpingress, ingress or egress",
+                    "type": "string"
+                },
+                "fields": {
+                    "description": "Changed fields of the program",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDFieldChange"
+                    }
+                },
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Program name",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDConfigDiff": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "description": "Changed programs sorted by interface, direction and name",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDConfigChange"
+                    }
+                },
+                "from": {
+                    "description": "Revision compared from",
+                    "type": "integer"
+                },
+                "to": {
+                    "description": "Revision compared to",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.L3afDConfigRevision": {
+            "type": "object",
+            "properties": {
+                "applied_at": {
+                    "description": "Time of the apply in RFC 3339 format",
+                    "type": "string"
+                },
+                "configs": {
+                    "description": "Applied configs, omitted in the history list",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afBPFPrograms"
+                    }
+                },
+                "revision": {
+                    "description": "Revision of the config history, incremented by every apply changing the config",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.L3afDDoctorCheck": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Finding of the check",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "bpffs, kernel, btf, capabilities, memlock, iface <name>, kf-repo or disk <dir>",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "pass, warn or fail",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDDoctorReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "description": "Checks in the order they are run",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDDoctorCheck"
+                    }
+                },
+                "host_name": {
+                    "description": "Host name",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Worst status of the checks, pass, warn or fail",
+                    "type": "string"
+                },
+                "time": {
+                    "description": "Time of the diagnostics in RFC 3339 format",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDEffectiveConfig": {
+            "type": "object",
+            "properties": {
+                "bpf_programs": {
+                    "description": "Programs in chain order per direction followed by the paused programs",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDEffectiveProgram"
+                    }
+                },
+                "host_name": {
+                    "description": "Host name",
+                    "type": "string"
+                },
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
+                },
+                "link_status": {
+                    "description": "attached or waiting for link",
+                    "type": "string"
+                },
+                "platform": {
+                    "description": "Platform of the artifact downloads",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDEffectiveProgram": {
+            "type": "object",
+            "properties": {
+                "artifact_url": {
+                    "description": "Download url of the artifact",
+                    "type": "string"
+                },
+                "direction": {
+                    "description": "xdpingress, ingress or egress",
+                    "type": "string"
+                },
+                "file_path": {
+                    "description": "Dir of the extracted artifact, empty when not started",
+                    "type": "string"
+                },
+                "pending_update": {
+                    "description": "Update queued until the apply window opens, null when none",
+                    "$ref": "#/definitions/models.BPFProgram"
+                },
+                "program": {
+                    "description": "Config with the assigned seq id and the l3afd defaults of the unset fields",
+                    "$ref": "#/definitions/models.BPFProgram"
+                },
+                "state": {
+                    "description": "running, root, bypassed, paused or waiting for link",
+                    "type": "string"
+                },
+                "xdp_mode": {
+                    "description": "XDP mode offload, native or generic the program runs in, omitted for TC programs",
+                    "type": "string"
+                },
+                "queue_instances": {
+                    "description": "Per-queue instances of the program started per queue, the program serving the first queue",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDQueueInstance"
+                    }
+                },
+                "tasks": {
+                    "description": "Scheduled tasks of the running program",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDTaskStatus"
+                    }
+                },
+                "map_memory": {
+                    "description": "Declared BPF map memory of the eBPF objects of the running program in bytes",
+                    "type": "integer"
+                },
+                "memlock_bytes": {
+                    "description": "Locked memory reserved for the maps of the running program under the managed memlock limit in bytes",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.L3afDFault": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Number of lifecycle operations the fault is applied to, 1 by default",
+                    "type": "integer"
+                },
+                "delay": {
+                    "description": "Map pin delay in seconds",
+                    "type": "integer"
+                },
+                "direction": {
+                    "description": "Direction of the program to kill",
+                    "type": "string"
+                },
+                "iface": {
+                    "description": "Interface name of the program to kill",
+                    "type": "string"
+                },
+                "program": {
+                    "description": "Name of the BPF program",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "fail-download, delay-map-pin or kill-nf",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDFieldChange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "JSON name of the field",
+                    "type": "string"
+                },
+                "from": {
+                    "description": "Value in the revision compared from, null when unset",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "to": {
+                    "description": "Value in the revision compared to, null when unset",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.L3afDFreeze": {
+            "type": "object",
+            "properties": {
+                "by": {
+                    "description": "Remote address of the admin or config when frozen by the config file",
+                    "type": "string"
+                },
+                "frozen": {
+                    "description": "Config is frozen",
+                    "type": "boolean"
+                },
+                "reason": {
+                    "description": "Reason of the freeze e.g. the incident id",
+                    "type": "string"
+                },
+                "since": {
+                    "description": "Time the config was frozen in RFC 3339 format, empty when not frozen",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDIfaceNUMA": {
+            "type": "object",
+            "properties": {
+                "cpus": {
+                    "description": "CPUs of the NUMA node e.g. 0-11,24-35",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Error of the discovery",
+                    "type": "string"
+                },
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
+                },
+                "numa_node": {
+                    "description": "NUMA node of the NIC, -1 when the NIC has no NUMA node",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.L3afDLinkStatus": {
+            "type": "object",
+            "properties": {
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
+                },
+                "queued_at": {
+                    "description": "Time the config was queued for the link in RFC 3339 format, empty when attached",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "attached or waiting for link",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDLogLevel": {
+            "type": "object",
+            "properties": {
+                "debug_programs": {
+                    "description": "Programs whose map lookups and chain updates are logged regardless of the level, unchanged when absent",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "level": {
+                    "description": "Level of the logs, unchanged when empty",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDLogLevels": {
+            "type": "object",
+            "properties": {
+                "level": {
+                    "description": "Level of the logs i.e. trace, debug, info, warn, error, fatal or panic",
+                    "type": "string"
+                },
+                "modules": {
+                    "description": "Level overrides of the kf, apis and stats modules, an empty level removes the override",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.L3afDMapEntryUpdate": {
+            "type": "object",
+            "properties": {
+                "key_type": {
+                    "description": "u8, u16, u32, u64, be16, be32, be64, ipv4, ipv6, mac or hex",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Value of the entry",
+                    "type": "string"
+                },
+                "value_type": {
+                    "description": "u8, u16, u32, u64, be16, be32, be64, ipv4, ipv6, mac or hex",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDMetricHistory": {
+            "type": "object",
+            "properties": {
+                "metric": {
+                    "description": "Metric name of the monitor map i.e. <map>_<key>_<aggregator>[_<field>]",
+                    "type": "string"
+                },
+                "program": {
+                    "description": "Program name",
+                    "type": "string"
+                },
+                "samples": {
+                    "description": "Samples oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDMetricSample"
+                    }
+                }
+            }
+        },
+        "models.L3afDMetricSample": {
+            "type": "object",
+            "properties": {
+                "time": {
+                    "description": "Time of the sample in RFC 3339 format",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Aggregated value of the monitor map",
+                    "type": "number"
+                }
+            }
+        },
+        "models.L3afDNFAFXDP": {
+            "type": "object",
+            "properties": {
+                "queue_ids": {
+                    "description": "Interface queues served by the user program sockets",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "xsk_map_name": {
+                    "description": "Pinned xsk map the root program redirects the packets to",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDNFArgs": {
+            "type": "object",
+            "additionalProperties": true
+        },
+        "models.L3afDNFConsumedMap": {
+            "type": "object",
+            "properties": {
+                "arg": {
+                    "description": "Start argument to pass the pinned map path",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Logical name of the map shared by the program",
+                    "type": "string"
+                },
+                "pin_path": {
+                    "description": "Optional path to re-pin the map for this program, consumed-map-dir of l3afd.cfg by default",
+                    "type": "string"
+                },
+                "program": {
+                    "description": "Name of the program sharing the map",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDNFIncident": {
+            "type": "object",
+            "properties": {
+                "direction": {
+                    "description": "Direction xdpingress, ingress or egress",
+                    "type": "string"
+                },
+                "exit_code": {
+                    "description": "Exit code, -1 when killed by a signal or unknown",
+                    "type": "integer"
+                },
+                "exit_status": {
+                    "description": "Exit status e.g. exit status 1 or signal: segmentation fault",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Incident id, name of the bundle",
+                    "type": "string"
+                },
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name of the BPF program",
+                    "type": "string"
+                },
+                "restart_count": {
+                    "description": "Restarts of the program before the crash",
+                    "type": "integer"
+                },
+                "time": {
+                    "description": "Time the crash is detected in RFC 3339 format",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Program version",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDNFMetricsField": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Field name, appended to the metric name",
+                    "type": "string"
+                },
+                "offset": {
+                    "description": "Byte offset of the field in the value",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "u8, u16, u32, u64, be16, be32 or be64",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDNFMetricsMap": {
+            "type": "object",
+            "properties": {
+                "aggregator": {
+                    "description": "Aggregation function names",
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Index of the bpf map",
+                    "type": "integer"
+                },
+                "keys": {
+                    "description": "Key ranges e.g. 0-15 or 0-3,8, or * for all the keys of the map, overrides key",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "BPF map name",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Fields of the struct value, one metric per field, the value is an integer by default",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.L3afDNFMetricsField"
+                    }
+                }
+            }
+        },
+        "models.L3afDNFPassedFD": {
             "type": "object",
             "properties": {
-                "admin_status": {
-                    "description": "Program admin status enabled or disabled",
+                "name": {
+                    "description": "Start argument passing the fd number, name of the fd on the control socket",
                     "type": "string"
                 },
-                "artifact": {
-                    "description": "Artifact file name",
+                "pin_path": {
+                    "description": "Pin path of the map or program",
                     "type": "string"
                 },
-                "cfg_version": {
-                    "description": "Config version",
-                    "type": "integer"
-                },
-                "cmd_config": {
-                    "description": "Program config providing command",
+                "type": {
+                    "description": "map or program",
                     "type": "string"
-                },
-                "cmd_start": {
-                    "description": "Program start command",
+                }
+            }
+        },
+        "models.L3afDNFTestVector": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Test vector name",
                     "type": "string"
                 },
-                "cmd_status": {
-                    "description": "Program status command",
+                "packet": {
+                    "description": "Input packet in hex including ethernet header",
                     "type": "string"
                 },
-                "cmd_stop": {
-                    "description": "Program stop command",
+                "program": {
+                    "description": "eBPF program name in the object, first program of the program type by default",
                     "type": "string"
                 },
-                "config_args": {
-                    "description": "Map of arguments to config command",
-                    "$ref": "#/definitions/models.L3afDNFArgs"
-                },
-                "config_file_path": {
-                    "description": "Config file location",
+                "verdict": {
+                    "description": "Expected verdict name e.g. XDP_DROP, TC_ACT_SHOT or return code",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDNFWatchdog": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "log, restart, bypass or alert, log when empty",
                     "type": "string"
                 },
-                "cpu": {
-                    "description": "User program cpu limits",
-                    "type": "integer"
+                "max_cpu_percent": {
+                    "description": "CPU usage over the monitoring interval in percent of a CPU, no limit when 0",
+                    "type": "number"
                 },
-                "id": {
-                    "description": "Program id",
+                "max_restarts": {
+                    "description": "Restarts by the process monitoring in the restart window, no limit when 0",
                     "type": "integer"
                 },
-                "is_plugin": {
-                    "description": "User program is plugin or not",
-                    "type": "boolean"
-                },
-                "map_args": {
-                    "description": "Config BPF Map of arguments",
-                    "$ref": "#/definitions/models.L3afDNFArgs"
+                "max_rss": {
+                    "description": "Bytes of resident memory, no limit when 0",
+                    "type": "integer"
                 },
-                "map_name": {
-                    "description": "BPF map to store next program fd",
+                "restart_window": {
+                    "description": "Window of the max restarts e.g. 30m, 1h when empty",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDPausedProgram": {
+            "type": "object",
+            "properties": {
+                "direction": {
+                    "description": "Direction xdpingress, ingress or egress",
                     "type": "string"
                 },
-                "memory": {
-                    "description": "User program memory limits",
-                    "type": "integer"
-                },
-                "monitor_maps": {
-                    "description": "Metrics BPF maps",
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.L3afDNFMetricsMap"
-                    }
+                "iface": {
+                    "description": "Interface name",
+                    "type": "string"
                 },
                 "name": {
                     "description": "Name of the BPF program",
                     "type": "string"
+                }
+            }
+        },
+        "models.L3afDPendingApply": {
+            "type": "object",
+            "properties": {
+                "apply_window": {
+                    "description": "Window the update waits for",
+                    "type": "string"
                 },
-                "prog_type": {
-                    "description": "Program type XDP or TC",
+                "direction": {
+                    "description": "Direction of the program",
                     "type": "string"
                 },
-                "rules": {
-                    "description": "Config rules",
+                "iface": {
+                    "description": "Interface name",
                     "type": "string"
                 },
-                "rules_file": {
-                    "description": "Config rules file name",
+                "name": {
+                    "description": "Program name",
+                    "type": "string"
+                },
+                "queued_at": {
+                    "description": "Time the update was queued in RFC 3339 format",
                     "type": "string"
                 },
                 "seq_id": {
-                    "description": "Sequence position in the chain",
+                    "description": "Queued seq id",
                     "type": "integer"
                 },
-                "start_args": {
-                    "description": "Map of arguments to start command",
-                    "$ref": "#/definitions/models.L3afDNFArgs"
+                "version": {
+                    "description": "Queued version",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDPrefetchResult": {
+            "type": "object",
+            "properties": {
+                "artifact": {
+                    "description": "Artifact file name",
+                    "type": "string"
                 },
-                "status_args": {
-                    "description": "Map of arguments to status command",
-                    "$ref": "#/definitions/models.L3afDNFArgs"
+                "cached": {
+                    "description": "Artifact was already in the cache",
+                    "type": "boolean"
                 },
-                "stop_args": {
-                    "description": "Map of arguments to stop command",
-                    "$ref": "#/definitions/models.L3afDNFArgs"
+                "error": {
+                    "description": "Error of the download, empty on success",
+                    "type": "string"
                 },
-                "user_program_daemon": {
-                    "description": "User program daemon or not",
-                    "type": "boolean"
+                "name": {
+                    "description": "Name of the BPF program",
+                    "type": "string"
                 },
                 "version": {
                     "description": "Program version",
@@ -204,66 +2364,251 @@ const docTemplate = `{
                 }
             }
         },
-        "models.BPFPrograms": {
+        "models.L3afDRulesPatch": {
             "type": "object",
             "properties": {
-                "tc_egress": {
-                    "description": "list of tc egress bpf programs",
+                "add": {
+                    "description": "Entries appended to the rules, existing entries are skipped",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.BPFProgram"
+                        "type": "string"
                     }
                 },
-                "tc_ingress": {
-                    "description": "list of tc ingress bpf programs",
+                "remove": {
+                    "description": "Entries removed from the rules, missing entries are skipped",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.BPFProgram"
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.L3afDRulesPatchResult": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "description": "Entries added to the rules",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
                 },
-                "xdp_ingress": {
-                    "description": "list of xdp ingress bpf programs",
+                "removed": {
+                    "description": "Entries removed from the rules",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.BPFProgram"
+                        "type": "string"
                     }
+                },
+                "rules": {
+                    "description": "Entries of the rules after the patch",
+                    "type": "integer"
                 }
             }
         },
-        "models.L3afBPFPrograms": {
+        "models.L3afDTap": {
             "type": "object",
             "properties": {
-                "bpf_programs": {
-                    "description": "List of bpf programs",
-                    "$ref": "#/definitions/models.BPFPrograms"
+                "collector": {
+                    "description": "Collector address to stream the packets in pcap format, one of the tap collectors of l3afd.cfg",
+                    "type": "string"
                 },
-                "host_name": {
-                    "description": "Host name or pod name",
+                "filter": {
+                    "description": "Classic BPF filter in tcpdump -ddd format joined by commas",
                     "type": "string"
                 },
                 "iface": {
                     "description": "Interface name",
                     "type": "string"
+                },
+                "pcap_file": {
+                    "description": "Pcap file name in the tap pcap-dir of l3afd.cfg to write the packets",
+                    "type": "string"
+                },
+                "sample_rate": {
+                    "description": "Capture one of every sample_rate packets, all packets by default",
+                    "type": "integer"
                 }
             }
         },
-        "models.L3afDNFArgs": {
+        "models.L3afDNFHeartbeat": {
             "type": "object",
-            "additionalProperties": true
+            "properties": {
+                "behavior": {
+                    "description": "fail-open or fail-closed, fail-open when empty",
+                    "type": "string"
+                },
+                "interval": {
+                    "description": "Interval of the heartbeat writes e.g. 5s, l3afd default when empty",
+                    "type": "string"
+                },
+                "key": {
+                    "description": "u32 key of the heartbeat entry",
+                    "type": "integer"
+                },
+                "map_name": {
+                    "description": "Array or hash map of the heartbeat, pinned path for TC programs",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "Age of the heartbeat the program considers l3afd gone e.g. 30s, 3 intervals when empty",
+                    "type": "string"
+                }
+            }
         },
-        "models.L3afDNFMetricsMap": {
+        "models.L3afDNFCounter": {
             "type": "object",
             "properties": {
-                "aggregator": {
-                    "description": "Aggregation function names",
+                "keys": {
+                    "description": "Keys and key ranges e.g. 0-15 or 0-3,8, or * for all the keys of the map",
                     "type": "string"
                 },
-                "key": {
-                    "description": "Index of the bpf map",
+                "map": {
+                    "description": "BPF map name, pinned path for TC programs",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDStatsReset": {
+            "type": "object",
+            "properties": {
+                "counters": {
+                    "description": "Map counters zeroed",
+                    "type": "integer"
+                },
+                "programs": {
+                    "description": "Programs reset as iface/direction/program",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.L3afDNFBlueGreen": {
+            "type": "object",
+            "properties": {
+                "map_name": {
+                    "description": "BPF map to store next program fd of the version running in the green slot",
+                    "type": "string"
+                },
+                "validate_samples": {
+                    "description": "Packets test run through the new version before the swap, sampled by the packet tap of the interface when running, the test runs update the maps of the program, no validation when 0",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.L3afDNFShadow": {
+            "type": "object",
+            "properties": {
+                "artifact": {
+                    "description": "Artifact of the candidate version, the artifact of the program when empty",
+                    "type": "string"
+                },
+                "interval": {
+                    "description": "Interval of the evaluations e.g. 30s, 10s when empty",
+                    "type": "string"
+                },
+                "map_name": {
+                    "description": "BPF map to store next program fd of the candidate, pinned apart from the map of the program",
+                    "type": "string"
+                },
+                "samples": {
+                    "description": "Packets run through both versions at each evaluation, sampled by the packet tap of the interface when running, 16 when 0",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "Candidate version of the program",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDNFQueues": {
+            "type": "object",
+            "properties": {
+                "queue_ids": {
+                    "description": "RX queues of the interface served by the program, the packets of the other queues are passed to the next program",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "instance_per_queue": {
+                    "description": "An instance of the user program is started per queue serving its queue only, the instances of the queues after the first are not chained",
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.L3afDQueueInstance": {
+            "type": "object",
+            "properties": {
+                "queue_id": {
+                    "description": "RX queue served by the instance",
+                    "type": "integer"
+                },
+                "running": {
+                    "description": "Instance is running",
+                    "type": "boolean"
+                },
+                "pid": {
+                    "description": "Process id of the instance, 0 when not running",
                     "type": "integer"
+                }
+            }
+        },
+        "models.L3afDNFTask": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Name of the task, unique in the program",
+                    "type": "string"
+                },
+                "command": {
+                    "description": "Command of the artifact relative to the artifact directory",
+                    "type": "string"
+                },
+                "args": {
+                    "description": "Args of the command passed as --key=value after the --iface and --direction flags",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "interval": {
+                    "description": "Interval of the runs e.g. 1h",
+                    "type": "string"
+                },
+                "jitter": {
+                    "description": "Random delay up to the jitter added to each interval e.g. 5m, spreading the runs of the fleet, none when empty",
+                    "type": "string"
                 },
+                "timeout": {
+                    "description": "Timeout of a run e.g. 30s, task-timeout of l3afd.cfg when empty",
+                    "type": "string"
+                }
+            }
+        },
+        "models.L3afDTaskStatus": {
+            "type": "object",
+            "properties": {
                 "name": {
-                    "description": "BPF map name",
+                    "description": "Name of the task",
+                    "type": "string"
+                },
+                "running": {
+                    "description": "Task is running",
+                    "type": "boolean"
+                },
+                "last_run": {
+                    "description": "Start time of the last run in RFC 3339 format, empty before the first run",
+                    "type": "string"
+                },
+                "last_result": {
+                    "description": "success, failure or timeout of the last run, skipped when the previous run had not completed, empty before the first run",
+                    "type": "string"
+                },
+                "next_run": {
+                    "description": "Time of the next run in RFC 3339 format",
                     "type": "string"
                 }
             }
@@ -0,0 +1,128 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// xskMapFD is fd of the xsk map in the AF_XDP user program, first fd after stdin, stdout and stderr
+const xskMapFD = 3
+
+// xdpStatistics is struct xdp_statistics of the AF_XDP socket
+type xdpStatistics struct {
+	RxDropped            uint64 // Dropped for other reasons
+	RxInvalidDescs       uint64 // Dropped due to invalid descriptor
+	TxInvalidDescs       uint64 // Dropped due to invalid descriptor
+	RxRingFull           uint64 // Dropped due to rx ring being full
+	RxFillRingEmptyDescs uint64 // Failed to retrieve item from fill ring
+	TxRingEmptyDescs     uint64 // Failed to retrieve item from tx ring
+}
+
+// prepareAFXDP - sets up the xsk map of the AF_XDP user program and returns the start arguments
+// along with the xsk map file to be inherited by the user program, and whether the xsk map is created by l3afd
+func prepareAFXDP(afxdp *models.L3afDNFAFXDP) ([]string, *os.File, bool, error) {
+	if len(afxdp.XSKMapName) == 0 {
+		return nil, nil, false, errors.New("xsk map name is empty")
+	}
+	if len(afxdp.QueueIDs) == 0 {
+		return nil, nil, false, errors.New("no queue ids are configured")
+	}
+
+	xskMap, created, err := setupXSKMap(afxdp)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer xskMap.Close()
+
+	fd, err := dupFD(xskMap.FD())
+	if err != nil {
+		if created {
+			removeXSKMap(afxdp.XSKMapName)
+		}
+		return nil, nil, false, fmt.Errorf("failed to duplicate xsk map fd: %w", err)
+	}
+
+	queueIDs := make([]string, 0, len(afxdp.QueueIDs))
+	for _, id := range afxdp.QueueIDs {
+		queueIDs = append(queueIDs, strconv.Itoa(id))
+	}
+	args := []string{
+		"--xsk-map-fd=" + strconv.Itoa(xskMapFD),
+		"--queue-ids=" + strings.Join(queueIDs, ","),
+	}
+	return args, os.NewFile(uintptr(fd), afxdp.XSKMapName), created, nil
+}
+
+// setupXSKMap - returns the pinned xsk map, map is created and pinned when the root program has not created it
+func setupXSKMap(afxdp *models.L3afDNFAFXDP) (*ebpf.Map, bool, error) {
+	if fileExists(afxdp.XSKMapName) {
+		xskMap, err := ebpf.LoadPinnedMap(afxdp.XSKMapName, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to access pinned xsk map %s: %w", afxdp.XSKMapName, err)
+		}
+		if xskMap.Type() != ebpf.XSKMap {
+			xskMap.Close()
+			return nil, false, fmt.Errorf("pinned map %s is %s, expected %s", afxdp.XSKMapName, xskMap.Type(), ebpf.XSKMap)
+		}
+		for _, id := range afxdp.QueueIDs {
+			if id < 0 || uint32(id) >= xskMap.MaxEntries() {
+				xskMap.Close()
+				return nil, false, fmt.Errorf("queue id %d is out of range of xsk map %s", id, afxdp.XSKMapName)
+			}
+		}
+		return xskMap, false, nil
+	}
+
+	var maxEntries uint32
+	for _, id := range afxdp.QueueIDs {
+		if id < 0 {
+			return nil, false, fmt.Errorf("invalid queue id %d", id)
+		}
+		if uint32(id) >= maxEntries {
+			maxEntries = uint32(id) + 1
+		}
+	}
+
+	xskMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.XSKMap,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: maxEntries,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create xsk map: %w", err)
+	}
+	if err := xskMap.Pin(afxdp.XSKMapName); err != nil {
+		xskMap.Close()
+		return nil, false, fmt.Errorf("failed to pin xsk map %s: %w", afxdp.XSKMapName, err)
+	}
+	log.Info().Msgf("xsk map %s created with %d entries", afxdp.XSKMapName, maxEntries)
+	return xskMap, true, nil
+}
+
+// removeXSKMap - removes the pin of the xsk map created by l3afd
+func removeXSKMap(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msgf("failed to remove xsk map %s", path)
+	}
+}
+
+// removeCreatedXSKMap - removes the pin of the xsk map created by l3afd for the program
+func (b *BPF) removeCreatedXSKMap() {
+	if len(b.xskMapPin) == 0 {
+		return
+	}
+	removeXSKMap(b.xskMapPin)
+	b.xskMapPin = ""
+}
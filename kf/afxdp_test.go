@@ -0,0 +1,60 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_prepareAFXDP(t *testing.T) {
+	tests := []struct {
+		name    string
+		afxdp   *models.L3afDNFAFXDP
+		wantErr bool
+	}{
+		{
+			name:    "NoXSKMapName",
+			afxdp:   &models.L3afDNFAFXDP{QueueIDs: []int{0}},
+			wantErr: true,
+		},
+		{
+			name:    "NoQueueIDs",
+			afxdp:   &models.L3afDNFAFXDP{XSKMapName: "/sys/fs/bpf/xsks_map"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, f, _, err := prepareAFXDP(tt.afxdp)
+			if f != nil {
+				f.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("prepareAFXDP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBPF_removeCreatedXSKMap(t *testing.T) {
+	pin := filepath.Join(t.TempDir(), "xsks_map")
+	if err := os.WriteFile(pin, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// xsk map of the root program is kept
+	b := &BPF{}
+	b.removeCreatedXSKMap()
+	if !fileExists(pin) {
+		t.Fatalf("removeCreatedXSKMap() removed the xsk map not created by l3afd")
+	}
+	b.xskMapPin = pin
+	b.removeCreatedXSKMap()
+	if fileExists(pin) || len(b.xskMapPin) != 0 {
+		t.Errorf("removeCreatedXSKMap() kept the xsk map created by l3afd")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// groupMember - program of an apply group and the program it replaces, nil when the program is not in the chain
+type groupMember struct {
+	direction string
+	name      string
+	prev      *models.BPFProgram
+	applied   bool
+}
+
+// applyGroups - members of the apply groups of the programs of an interface by group name, in the apply order
+type applyGroups map[string][]*groupMember
+
+// snapshotApplyGroups - records the programs the members of the apply groups replace, before they are applied
+func (c *NFConfigs) snapshotApplyGroups(ifaceName string, refs []bpfProgramRef) applyGroups {
+	groups := make(applyGroups)
+	for _, ref := range refs {
+		if ref.prog == nil || len(ref.prog.Group) == 0 {
+			continue
+		}
+		m := &groupMember{direction: ref.direction, name: ref.prog.Name}
+		if bpf, err := c.findBPF(ifaceName, ref.direction, ref.prog.Name); err == nil {
+			m.prev = bpf.configProgram()
+		}
+		groups[ref.prog.Group] = append(groups[ref.prog.Group], m)
+	}
+	return groups
+}
+
+// member - member of the program in its apply group, nil when the program has no group
+func (g applyGroups) member(ref bpfProgramRef) *groupMember {
+	if ref.prog == nil {
+		return nil
+	}
+	for _, m := range g[ref.prog.Group] {
+		if m.direction == ref.direction && m.name == ref.prog.Name {
+			return m
+		}
+	}
+	return nil
+}
+
+// rollbackApplyGroup - restores the applied members of the group of the failed program and the failed program
+// to the programs they replaced in the reverse apply order, members new to the chain are stopped and removed
+func (c *NFConfigs) rollbackApplyGroup(ifaceName string, groups applyGroups, failed bpfProgramRef) error {
+	if failed.prog == nil || len(failed.prog.Group) == 0 {
+		return nil
+	}
+	if m := groups.member(failed); m != nil {
+		m.applied = true
+	}
+	members := groups[failed.prog.Group]
+	var errs []string
+	for i := len(members) - 1; i >= 0; i-- {
+		m := members[i]
+		if !m.applied {
+			continue
+		}
+		var err error
+		if m.prev == nil {
+			err = c.removeBPFProgram(ifaceName, m.direction, m.name)
+		} else {
+			err = c.deployBPFProgram(m.prev, ifaceName, m.direction)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s: %v", m.name, m.direction, err))
+			continue
+		}
+		m.applied = false
+		log.Info().Msgf("program %s iface %s direction %s of apply group %s is rolled back", m.name, ifaceName, m.direction, failed.prog.Group)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to roll back apply group %s: %s", failed.prog.Group, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// removeBPFProgram - stops the program and removes it from the chain of the interface, its predecessor is linked
+// to its successor. The root program is stopped with the last program of the chain.
+func (c *NFConfigs) removeBPFProgram(ifaceName, direction, name string) error {
+	bpfs, err := c.bpfLists(direction)
+	if err != nil {
+		return err
+	}
+	bpfList := bpfs[ifaceName]
+	if bpfList == nil {
+		return nil
+	}
+	chain := c.hostConfig != nil && c.hostConfig.BpfChainingEnabled
+
+	e := bpfList.Front()
+	if e != nil && chain {
+		e = e.Next()
+	}
+	for ; e != nil; e = e.Next() {
+		if e.Value.(*BPF).Program.Name == name {
+			break
+		}
+	}
+	if e == nil {
+		return nil
+	}
+
+	bpf := e.Value.(*BPF)
+	// bypassed and standby programs are not linked into the chain
+	linked := !bpf.bypassed()
+	bpf.Program.AdminStatus = models.Disabled
+	// program failing to start may not be running, the program never started has no command
+	if bpf.Cmd != nil {
+		if err := bpf.Stop(ifaceName, direction, chain); err != nil {
+			log.Warn().Err(err).Msgf("failed to stop program %s iface %s direction %s", name, ifaceName, direction)
+		}
+	}
+	prev, next := chainNeighbours(e)
+	bpfList.Remove(e)
+	if chain && linked && prev != nil {
+		if next != nil {
+			if err := c.LinkBPFPrograms(prev.Value.(*BPF), next.Value.(*BPF)); err != nil {
+				return fmt.Errorf("failed to link the successor of program %s: %w", name, err)
+			}
+		} else if err := prev.Value.(*BPF).RemoveNextProgFD(); err != nil {
+			log.Warn().Err(err).Msgf("failed to unlink program %s from its predecessor", name)
+		}
+	}
+
+	switch {
+	case chain && bpfList.Len() == 1:
+		log.Info().Msgf("no network functions are running, stopping root program")
+		return c.StopRootProgram(ifaceName, direction)
+	case bpfList.Len() == 0:
+		bpfs[ifaceName] = nil
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"sync"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_rollbackApplyGroup(t *testing.T) {
+	xdpList, egressList := list.New(), list.New()
+	xdpList.PushBack(&BPF{Program: models.BPFProgram{Name: "ratelimiting", AdminStatus: models.Enabled}})
+	c := &NFConfigs{
+		hostConfig:     &config.Config{},
+		IngressXDPBpfs: map[string]*list.List{"eth0": xdpList},
+		IngressTCBpfs:  map[string]*list.List{},
+		EgressTCBpfs:   map[string]*list.List{"eth0": egressList},
+		mu:             new(sync.Mutex),
+	}
+
+	natIngress := &models.BPFProgram{Name: "nat", Group: "nat", AdminStatus: models.Enabled}
+	natEgress := &models.BPFProgram{Name: "nat", Group: "nat", AdminStatus: models.Enabled}
+	refs := []bpfProgramRef{
+		{direction: models.XDPIngressType, prog: &models.BPFProgram{Name: "ratelimiting", AdminStatus: models.Enabled}},
+		{direction: models.XDPIngressType, prog: natIngress},
+		{direction: models.EgressType, prog: natEgress},
+	}
+	groups := c.snapshotApplyGroups("eth0", refs)
+	if len(groups["nat"]) != 2 || groups.member(refs[0]) != nil {
+		t.Fatalf("snapshotApplyGroups() = %v, want the 2 members of the nat group", groups)
+	}
+	if groups["nat"][0].prev != nil {
+		t.Errorf("snapshotApplyGroups() recorded a replaced program of the new member")
+	}
+
+	// ingress member is applied, egress member fails to start after it is pushed back
+	xdpList.PushBack(&BPF{Program: *natIngress})
+	groups.member(refs[1]).applied = true
+	egressList.PushBack(&BPF{Program: *natEgress})
+
+	if err := c.rollbackApplyGroup("eth0", groups, refs[2]); err != nil {
+		t.Fatalf("rollbackApplyGroup() error = %v", err)
+	}
+	if xdpList.Len() != 1 || xdpList.Front().Value.(*BPF).Program.Name != "ratelimiting" {
+		t.Errorf("xdp programs = %d, want the nat program removed and ratelimiting kept", xdpList.Len())
+	}
+	if c.EgressTCBpfs["eth0"] != nil {
+		t.Errorf("egress programs = %v, want the nat program removed", c.EgressTCBpfs["eth0"])
+	}
+
+	// programs without a group are not rolled back
+	if err := c.rollbackApplyGroup("eth0", groups, refs[0]); err != nil || xdpList.Len() != 1 {
+		t.Errorf("rollbackApplyGroup() = %v with %d xdp programs, want nothing rolled back", err, xdpList.Len())
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// windowEntry - days of the week and the minutes of the day of the window, the window ends on the next
+// day when end is not after start
+type windowEntry struct {
+	days       [7]bool
+	start, end int
+}
+
+// applyWindow - windows in the local time of the node, always open when empty
+type applyWindow []windowEntry
+
+// defaultApplyWindow - apply window of the programs without their own window
+var defaultApplyWindow applyWindow
+
+func setApplyWindow(conf *config.Config) error {
+	defaultApplyWindow = nil
+	if conf == nil {
+		return nil
+	}
+	w, err := parseApplyWindow(conf.ApplyWindow)
+	if err != nil {
+		return fmt.Errorf("invalid apply window %q: %w", conf.ApplyWindow, err)
+	}
+	defaultApplyWindow = w
+	return nil
+}
+
+// parseApplyWindow - parses windows separated by ';', a window is the days e.g. *, Mon-Fri or Sat,Sun,
+// optionally followed by the time of the day e.g. 02:00-04:00
+func parseApplyWindow(spec string) (applyWindow, error) {
+	var w applyWindow
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("window %q is not <days> [<hh:mm>-<hh:mm>]", strings.TrimSpace(entry))
+		}
+		var e windowEntry
+		if err := parseWindowDays(fields[0], &e.days); err != nil {
+			return nil, err
+		}
+		e.end = 24 * 60
+		if len(fields) == 2 {
+			times := strings.Split(fields[1], "-")
+			if len(times) != 2 {
+				return nil, fmt.Errorf("time %q is not <hh:mm>-<hh:mm>", fields[1])
+			}
+			var err error
+			if e.start, err = parseWindowTime(times[0]); err != nil {
+				return nil, err
+			}
+			if e.end, err = parseWindowTime(times[1]); err != nil {
+				return nil, err
+			}
+		}
+		w = append(w, e)
+	}
+	return w, nil
+}
+
+func parseWindowDays(spec string, days *[7]bool) error {
+	if spec == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return nil
+	}
+	for _, r := range strings.Split(spec, ",") {
+		bounds := strings.Split(strings.ToLower(r), "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid days %q", r)
+		}
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseWindowTime - minutes of the day of hh:mm, 24:00 is the end of the day
+func parseWindowTime(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// open - time is in one of the windows
+func (w applyWindow) open(t time.Time) bool {
+	if len(w) == 0 {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	yesterday := (t.Weekday() + 6) % 7
+	for _, e := range w {
+		if e.end > e.start {
+			if e.days[t.Weekday()] && minute >= e.start && minute < e.end {
+				return true
+			}
+			continue
+		}
+		// the window crosses midnight
+		if (e.days[t.Weekday()] && minute >= e.start) || (e.days[yesterday] && minute < e.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// programApplyWindow - window of the program, the l3afd window when the program has none
+func programApplyWindow(prog *models.BPFProgram) applyWindow {
+	if len(prog.ApplyWindow) == 0 {
+		return defaultApplyWindow
+	}
+	w, err := parseApplyWindow(prog.ApplyWindow)
+	if err != nil {
+		log.Error().Err(err).Msgf("invalid apply window of program %s, using the l3afd window", prog.Name)
+		return defaultApplyWindow
+	}
+	return w
+}
+
+// ValidateApplyWindows - Verifies the apply windows of the programs
+func ValidateApplyWindows(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil || len(ref.prog.ApplyWindow) == 0 {
+				continue
+			}
+			if _, err := parseApplyWindow(ref.prog.ApplyWindow); err != nil {
+				return fmt.Errorf("program %s on iface %s apply window %q: %w", ref.prog.Name, cfg.Iface, ref.prog.ApplyWindow, err)
+			}
+		}
+	}
+	return nil
+}
+
+// disruptiveUpdate - update restarts the program or moves it in the chain
+func disruptiveUpdate(running, prog *models.BPFProgram) bool {
+	return running.Version != prog.Version || !reflect.DeepEqual(running.StartArgs, prog.StartArgs) ||
+		running.SeqID != prog.SeqID
+}
+
+// pendingApply - disruptive update of a program queued until its apply window opens
+type pendingApply struct {
+	iface     string
+	direction string
+	prog      models.BPFProgram
+	queuedAt  time.Time
+}
+
+// applyQueue - disruptive updates outside the apply windows
+type applyQueue struct {
+	mu      sync.Mutex
+	pending map[string]pendingApply
+}
+
+var pendingApplies = &applyQueue{pending: make(map[string]pendingApply)}
+
+func pendingApplyKey(ifaceName, direction, name string) string {
+	return ifaceName + "/" + direction + "/" + name
+}
+
+// reset - drops the queued updates, every apply carries the full config
+func (q *applyQueue) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = make(map[string]pendingApply)
+}
+
+func (q *applyQueue) queue(p pendingApply) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := pendingApplyKey(p.iface, p.direction, p.prog.Name)
+	if queued, ok := q.pending[key]; ok {
+		p.queuedAt = queued.queuedAt
+	}
+	q.pending[key] = p
+}
+
+func (q *applyQueue) drop(ifaceName, direction, name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, pendingApplyKey(ifaceName, direction, name))
+}
+
+// list - queued updates sorted by interface, direction and program
+func (q *applyQueue) list() []pendingApply {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	applies := make([]pendingApply, 0, len(q.pending))
+	for _, p := range q.pending {
+		applies = append(applies, p)
+	}
+	sort.Slice(applies, func(i, j int) bool {
+		return pendingApplyKey(applies[i].iface, applies[i].direction, applies[i].prog.Name) <
+			pendingApplyKey(applies[j].iface, applies[j].direction, applies[j].prog.Name)
+	})
+	return applies
+}
+
+// deferDisruptiveUpdate - queues the disruptive update of the running program when its apply window is
+// closed, the queued update of the program is replaced by every apply
+func deferDisruptiveUpdate(running, prog *models.BPFProgram, ifaceName, direction string) bool {
+	pendingApplies.drop(ifaceName, direction, prog.Name)
+	if !disruptiveUpdate(running, prog) || programApplyWindow(prog).open(time.Now()) {
+		return false
+	}
+	log.Info().Msgf("apply window of program %s iface %s direction %s is closed, version %s seq id %d is queued",
+		prog.Name, ifaceName, direction, prog.Version, prog.SeqID)
+	pendingApplies.queue(pendingApply{iface: ifaceName, direction: direction, prog: *prog, queuedAt: time.Now()})
+	return true
+}
+
+// applyPendingUpdates - applies the queued updates whose apply window is open
+func (c *NFConfigs) applyPendingUpdates() {
+	now := time.Now()
+	applied := 0
+	for _, p := range pendingApplies.list() {
+		if !programApplyWindow(&p.prog).open(now) {
+			continue
+		}
+		c.mu.Lock()
+		pendingApplies.drop(p.iface, p.direction, p.prog.Name)
+		// the program is removed or disabled after the update is queued
+		if _, err := c.findBPF(p.iface, p.direction, p.prog.Name); err != nil {
+			c.mu.Unlock()
+			continue
+		}
+		log.Info().Msgf("apply window of program %s iface %s direction %s is open, applying version %s seq id %d",
+			p.prog.Name, p.iface, p.direction, p.prog.Version, p.prog.SeqID)
+		prog := p.prog
+		if err := c.VerifyNUpdateBPFProgram(&prog, p.iface, p.direction); err != nil {
+			log.Error().Err(err).Msgf("failed to apply the queued update of program %s iface %s direction %s", p.prog.Name, p.iface, p.direction)
+		}
+		c.mu.Unlock()
+		applied++
+	}
+	if applied == 0 {
+		return
+	}
+	if err := c.SaveConfigsToConfigStore(); err != nil {
+		log.Error().Err(err).Msg("failed to save configs after applying the queued updates")
+	}
+}
+
+// applyWindowLoop - applies the queued updates when their apply window opens
+func (c *NFConfigs) applyWindowLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.applyPendingUpdates()
+		}
+	}
+}
+
+// PendingApplies - disruptive updates queued until the apply window of the program opens
+func (c *NFConfigs) PendingApplies() []models.L3afDPendingApply {
+	applies := make([]models.L3afDPendingApply, 0)
+	for _, p := range pendingApplies.list() {
+		window := p.prog.ApplyWindow
+		if len(window) == 0 && c.hostConfig != nil {
+			window = c.hostConfig.ApplyWindow
+		}
+		applies = append(applies, models.L3afDPendingApply{
+			Iface:       p.iface,
+			Direction:   p.direction,
+			Name:        p.prog.Name,
+			Version:     p.prog.Version,
+			SeqID:       p.prog.SeqID,
+			ApplyWindow: window,
+			QueuedAt:    p.queuedAt.Format(time.RFC3339),
+		})
+	}
+	return applies
+}
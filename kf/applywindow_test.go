@@ -0,0 +1,114 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_parseApplyWindow(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "", wantErr: false},
+		{spec: "*", wantErr: false},
+		{spec: "Mon-Fri 02:00-04:00; Sat,Sun", wantErr: false},
+		{spec: "* 22:00-24:00", wantErr: false},
+		{spec: "Someday", wantErr: true},
+		{spec: "Mon 2am-4am", wantErr: true},
+		{spec: "Mon 02:00", wantErr: true},
+		{spec: "Mon 02:00-04:00 UTC", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if _, err := parseApplyWindow(tt.spec); (err != nil) != tt.wantErr {
+				t.Errorf("parseApplyWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_applyWindowOpen(t *testing.T) {
+	// 2024-05-03 is a Friday
+	at := func(day int, hhmm string) time.Time {
+		tm, _ := time.Parse("15:04", hhmm)
+		return time.Date(2024, 5, day, tm.Hour(), tm.Minute(), 0, 0, time.Local)
+	}
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{spec: "", t: at(3, "12:00"), want: true},
+		{spec: "Mon-Fri 02:00-04:00", t: at(3, "03:00"), want: true},
+		{spec: "Mon-Fri 02:00-04:00", t: at(3, "04:00"), want: false},
+		{spec: "Mon-Fri 02:00-04:00", t: at(4, "03:00"), want: false},
+		{spec: "Mon-Fri 02:00-04:00; Sat,Sun", t: at(4, "12:00"), want: true},
+		{spec: "Fri-Mon", t: at(6, "12:00"), want: true},
+		{spec: "Fri-Mon", t: at(7, "12:00"), want: false},
+		{spec: "Fri 22:00-02:00", t: at(3, "23:00"), want: true},
+		{spec: "Fri 22:00-02:00", t: at(4, "01:00"), want: true},
+		{spec: "Fri 22:00-02:00", t: at(3, "01:00"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+"@"+tt.t.Format("Mon15:04"), func(t *testing.T) {
+			w, err := parseApplyWindow(tt.spec)
+			if err != nil {
+				t.Fatalf("parseApplyWindow() error = %v", err)
+			}
+			if got := w.open(tt.t); got != tt.want {
+				t.Errorf("open() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_deferDisruptiveUpdate(t *testing.T) {
+	pendingApplies.reset()
+	t.Cleanup(pendingApplies.reset)
+	closed := ((time.Now().Weekday() + 3) % 7).String()[:3]
+
+	running := &models.BPFProgram{Name: "ratelimiting", Version: "1.0", SeqID: 1, ApplyWindow: closed}
+	mapArgs := *running
+	mapArgs.MapArgs = models.L3afDNFArgs{"rate": "100"}
+	if deferDisruptiveUpdate(running, &mapArgs, "eth0", models.XDPIngressType) {
+		t.Errorf("map args update is deferred")
+	}
+
+	upgrade := mapArgs
+	upgrade.Version = "1.1"
+	if !deferDisruptiveUpdate(running, &upgrade, "eth0", models.XDPIngressType) {
+		t.Fatalf("version upgrade outside the window is not deferred")
+	}
+	if got := pendingApplies.list(); len(got) != 1 || got[0].prog.Version != "1.1" {
+		t.Fatalf("queued updates = %v, want version 1.1", got)
+	}
+
+	open := upgrade
+	open.ApplyWindow = "*"
+	if deferDisruptiveUpdate(running, &open, "eth0", models.XDPIngressType) {
+		t.Errorf("version upgrade inside the window is deferred")
+	}
+	if got := pendingApplies.list(); len(got) != 0 {
+		t.Errorf("queued updates = %v, want the queued update dropped", got)
+	}
+}
+
+func TestNFConfigs_PrepareBPFPrograms_applyWindow(t *testing.T) {
+	c := &NFConfigs{hostConfig: &config.Config{}, mu: new(sync.Mutex)}
+	progs := []models.L3afBPFPrograms{{
+		Iface:       "eth0",
+		BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Version: "1.0", ApplyWindow: "Someday"}}},
+	}}
+	if err := c.PrepareBPFPrograms(progs); err == nil || !strings.Contains(err.Error(), "apply window") {
+		t.Errorf("PrepareBPFPrograms() error = %v, want the apply window rejected", err)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// shellMetaChars - characters rejected in the argument values, arguments are not passed through a shell
+// but the user programs may
+const shellMetaChars = "`$;&|<>(){}\\'\"\n\r\x00"
+
+// argNameRegexp - argument names are passed as --<name>=<value>
+var argNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// reservedArgs - arguments set by l3afd, configs must not override them
+var reservedArgs = map[string]bool{
+	"iface":          true,
+	"direction":      true,
+	"map-name":       true,
+	"log-dir":        true,
+	"btf-path":       true,
+	"rules-file":     true,
+	"control-socket": true,
+}
+
+// ValidateProgramArgs - Verifies the start, stop and status arguments of the programs are allowed by the
+// program argument schemas. Configs with unexpected arguments are rejected before applying any change.
+func (c *NFConfigs) ValidateProgramArgs(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		if cfg.BpfPrograms == nil {
+			continue
+		}
+		for _, progs := range [][]*models.BPFProgram{cfg.BpfPrograms.XDPIngress, cfg.BpfPrograms.TCIngress, cfg.BpfPrograms.TCEgress} {
+			for _, prog := range progs {
+				if prog == nil {
+					continue
+				}
+				if err := validateArgs(prog, c.hostConfig.ProgramArgSchemas, c.hostConfig.StrictProgramArgs); err != nil {
+					return fmt.Errorf("program %s on iface %s: %w", prog.Name, cfg.Iface, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateArgs - verifies the arguments of the program against its schema
+func validateArgs(prog *models.BPFProgram, schemas map[string]config.ArgSchema, strict bool) error {
+	if err := validateArgSchemaVersion(prog); err != nil {
+		return err
+	}
+	if err := validatePassFDs(prog); err != nil {
+		return err
+	}
+	schema, hasSchema := schemas[prog.Name]
+	for argType, args := range map[string]models.L3afDNFArgs{
+		"start_args":  prog.StartArgs,
+		"stop_args":   prog.StopArgs,
+		"status_args": prog.StatusArgs,
+	} {
+		if len(args) > 0 && !hasSchema && strict {
+			return fmt.Errorf("%s are not allowed without argument schema", argType)
+		}
+		for k, val := range args {
+			v, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("%s %s is not a string", argType, k)
+			}
+			if !argNameRegexp.MatchString(k) {
+				return fmt.Errorf("%s name %q is invalid", argType, k)
+			}
+			if reservedArgs[k] {
+				return fmt.Errorf("%s %s is set by l3afd", argType, k)
+			}
+			if strings.ContainsAny(v, shellMetaChars) {
+				return fmt.Errorf("%s %s value contains shell metacharacters", argType, k)
+			}
+			if !hasSchema {
+				continue
+			}
+			re, ok := schema[k]
+			if !ok {
+				return fmt.Errorf("%s %s is not allowed", argType, k)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s %s value %q does not match %s", argType, k, v, re)
+			}
+		}
+	}
+	return nil
+}
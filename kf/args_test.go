@@ -0,0 +1,87 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_validateArgs(t *testing.T) {
+	schemas := map[string]config.ArgSchema{
+		"ratelimiting": {
+			"ports": regexp.MustCompile(`^[0-9]+(,[0-9]+)*$`),
+			"rate":  regexp.MustCompile(`^[0-9]+$`),
+		},
+	}
+	tests := []struct {
+		name    string
+		prog    models.BPFProgram
+		strict  bool
+		wantErr bool
+	}{
+		{
+			name:    "ValidSchema",
+			prog:    models.BPFProgram{Name: "ratelimiting", StartArgs: models.L3afDNFArgs{"ports": "80,443", "rate": "2"}},
+			wantErr: false,
+		},
+		{
+			name:    "UnexpectedArg",
+			prog:    models.BPFProgram{Name: "ratelimiting", StartArgs: models.L3afDNFArgs{"ports": "80", "exec": "/bin/sh"}},
+			wantErr: true,
+		},
+		{
+			name:    "ValueMismatch",
+			prog:    models.BPFProgram{Name: "ratelimiting", StopArgs: models.L3afDNFArgs{"rate": "2 --debug"}},
+			wantErr: true,
+		},
+		{
+			name:    "ShellMetaChars",
+			prog:    models.BPFProgram{Name: "connection-limit", StartArgs: models.L3afDNFArgs{"max-conn": "5;reboot"}},
+			wantErr: true,
+		},
+		{
+			name:    "ReservedArg",
+			prog:    models.BPFProgram{Name: "connection-limit", StartArgs: models.L3afDNFArgs{"iface": "lo"}},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidName",
+			prog:    models.BPFProgram{Name: "connection-limit", StatusArgs: models.L3afDNFArgs{"-x": "1"}},
+			wantErr: true,
+		},
+		{
+			name:    "NotString",
+			prog:    models.BPFProgram{Name: "connection-limit", StartArgs: models.L3afDNFArgs{"max-conn": 5}},
+			wantErr: true,
+		},
+		{
+			name:    "NoSchema",
+			prog:    models.BPFProgram{Name: "connection-limit", StartArgs: models.L3afDNFArgs{"max-conn": "5"}},
+			wantErr: false,
+		},
+		{
+			name:    "NoSchemaStrict",
+			prog:    models.BPFProgram{Name: "connection-limit", StartArgs: models.L3afDNFArgs{"max-conn": "5"}},
+			strict:  true,
+			wantErr: true,
+		},
+		{
+			name:    "NoArgsStrict",
+			prog:    models.BPFProgram{Name: "connection-limit"},
+			strict:  true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateArgs(&tt.prog, schemas, tt.strict); (err != nil) != tt.wantErr {
+				t.Errorf("validateArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
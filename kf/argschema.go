@@ -0,0 +1,117 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// Versions of the contract of the args l3afd passes to the start and stop commands of the programs
+const (
+	// ArgSchemaV1 - implicit args are the --iface, --direction, --map-name, --log-dir, --btf-path and --rules-file flags
+	ArgSchemaV1 = 1
+	// ArgSchemaV2 - implicit args and the default args of the direction are a JSON document on stdin
+	ArgSchemaV2 = 2
+	// ArgSchemaV3 - the only implicit arg is the --control-socket flag, the program reads its config, map fds and
+	// config updates from the control socket
+	ArgSchemaV3 = 3
+
+	maxArgSchemaVersion = ArgSchemaV3
+)
+
+// argSchemaVersion - arg schema version of the program, programs not declaring it get the flags
+func argSchemaVersion(prog *models.BPFProgram) int {
+	if prog.ArgSchemaVersion == 0 {
+		return ArgSchemaV1
+	}
+	return prog.ArgSchemaVersion
+}
+
+// validateArgSchemaVersion - verifies l3afd supports the arg schema version declared by the program
+func validateArgSchemaVersion(prog *models.BPFProgram) error {
+	if prog.ArgSchemaVersion < 0 || prog.ArgSchemaVersion > maxArgSchemaVersion {
+		return fmt.Errorf("arg schema version %d is not supported, l3afd supports up to %d", prog.ArgSchemaVersion, maxArgSchemaVersion)
+	}
+	return nil
+}
+
+// implicitArgs - implicit args of the start or stop command of the program on the interface
+func (b *BPF) implicitArgs(command, ifaceName, direction string) models.L3afDNFImplicitArgs {
+	return models.L3afDNFImplicitArgs{
+		SchemaVersion: argSchemaVersion(&b.Program),
+		Command:       command,
+		Iface:         ifaceName,
+		Direction:     direction,
+		Slot:          b.slot,
+	}
+}
+
+// implicitArgFlags - flags of the implicit args of the arg schema version 1, the control socket of the version 3
+// and empty for the version 2
+func implicitArgFlags(implicit models.L3afDNFImplicitArgs) []string {
+	switch implicit.SchemaVersion {
+	case ArgSchemaV1:
+	case ArgSchemaV3:
+		return []string{"--control-socket=" + implicit.ControlSocket}
+	default:
+		return nil
+	}
+	flags := []string{
+		"--iface=" + implicit.Iface,         // attaching to or detaching from interface
+		"--direction=" + implicit.Direction, // direction xdpingress or ingress or egress
+	}
+	if len(implicit.MapName) > 0 {
+		flags = append(flags, "--map-name="+implicit.MapName)
+	}
+	if len(implicit.LogDir) > 0 {
+		flags = append(flags, "--log-dir="+implicit.LogDir)
+	}
+	if len(implicit.BTFPath) > 0 {
+		flags = append(flags, "--btf-path="+implicit.BTFPath)
+	}
+	if len(implicit.RulesFile) > 0 {
+		flags = append(flags, "--rules-file="+implicit.RulesFile)
+	}
+	if len(implicit.Slot) > 0 {
+		flags = append(flags, "--slot="+implicit.Slot)
+	}
+	if len(implicit.XDPMode) > 0 {
+		flags = append(flags, "--xdp-mode="+implicit.XDPMode)
+	}
+	if implicit.Ifindex > 0 {
+		flags = append(flags, "--ifindex="+strconv.Itoa(implicit.Ifindex))
+	}
+	if len(implicit.RxQueues) > 0 {
+		queues := make([]string, 0, len(implicit.RxQueues))
+		for _, id := range implicit.RxQueues {
+			queues = append(queues, strconv.Itoa(id))
+		}
+		flags = append(flags, "--rx-queues="+strings.Join(queues, ","))
+	}
+	return flags
+}
+
+// passImplicitArgs - passes the implicit args of the arg schema version 2 as a JSON document on stdin of the
+// command, along with the default args of the direction
+func passImplicitArgs(cmd *exec.Cmd, implicit models.L3afDNFImplicitArgs) error {
+	if implicit.SchemaVersion != ArgSchemaV2 {
+		return nil
+	}
+	if defaults := nfCmdConfig.directionArgs[implicit.Direction]; len(defaults) > 0 {
+		implicit.DefaultArgs = defaults
+	}
+	doc, err := json.Marshal(implicit)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the args document: %w", err)
+	}
+	cmd.Stdin = bytes.NewReader(append(doc, '\n'))
+	return nil
+}
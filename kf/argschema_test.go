@@ -0,0 +1,74 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_implicitArgFlags(t *testing.T) {
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting"}}
+	implicit := b.implicitArgs("start", "eth0", models.XDPIngressType)
+	implicit.MapName = "/sys/fs/bpf/xdp_root_array"
+	implicit.RulesFile = "/var/l3afd/ratelimiting/rules.txt"
+	want := []string{"--iface=eth0", "--direction=xdpingress", "--map-name=/sys/fs/bpf/xdp_root_array", "--rules-file=/var/l3afd/ratelimiting/rules.txt"}
+	if got := implicitArgFlags(implicit); !reflect.DeepEqual(got, want) {
+		t.Errorf("implicitArgFlags() = %v, want %v", got, want)
+	}
+
+	b.Program.ArgSchemaVersion = ArgSchemaV2
+	if got := implicitArgFlags(b.implicitArgs("stop", "eth0", models.XDPIngressType)); len(got) != 0 {
+		t.Errorf("implicitArgFlags() = %v, want no flags for the arg schema version 2", got)
+	}
+
+	for version, valid := range map[int]bool{0: true, ArgSchemaV1: true, ArgSchemaV2: true, ArgSchemaV3: true, 4: false, -1: false} {
+		err := validateArgSchemaVersion(&models.BPFProgram{ArgSchemaVersion: version})
+		if (err == nil) != valid {
+			t.Errorf("validateArgSchemaVersion(%d) error = %v, want valid %v", version, err, valid)
+		}
+	}
+}
+
+func Test_passImplicitArgs(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	savedCmdConfig := nfCmdConfig
+	nfCmdConfig.directionArgs = map[string]map[string]string{models.XDPIngressType: {"xdp-mode": "native"}}
+	t.Cleanup(func() { nfCmdConfig = savedCmdConfig })
+
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", ArgSchemaVersion: ArgSchemaV2}}
+	implicit := b.implicitArgs("start", "eth0", models.XDPIngressType)
+	implicit.LogDir = "/var/log/l3afd"
+
+	cmd := exec.Command("/bin/sh", "-c", "cat")
+	if err := passImplicitArgs(cmd, implicit); err != nil {
+		t.Fatalf("passImplicitArgs() error = %v", err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got models.L3afDNFImplicitArgs
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("invalid args document %s: %v", out, err)
+	}
+	want := models.L3afDNFImplicitArgs{SchemaVersion: ArgSchemaV2, Command: "start", Iface: "eth0", Direction: models.XDPIngressType,
+		LogDir: "/var/log/l3afd", DefaultArgs: map[string]string{"xdp-mode": "native"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("args document = %+v, want %+v", got, want)
+	}
+
+	// programs of the flags contract get no document
+	v1 := exec.Command("/bin/sh", "-c", "cat")
+	if err := passImplicitArgs(v1, (&BPF{}).implicitArgs("stop", "eth0", models.IngressType)); err != nil || v1.Stdin != nil {
+		t.Errorf("passImplicitArgs() stdin = %v error = %v, want no stdin for arg schema version 1", v1.Stdin, err)
+	}
+}
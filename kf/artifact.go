@@ -0,0 +1,317 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/l3af-project/l3afd/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// artifactETagExt - ETag of the downloaded artifact, next to the artifact dir
+const artifactETagExt = ".etag"
+
+// artifactMu - serializes the extraction and swap of the artifacts i.e. starts and prefetches
+var artifactMu sync.Mutex
+
+// stagingDir - dir the artifact of the version dir is extracted into, next to the version dir on the same filesystem
+func stagingDir(versionDir string) string {
+	return filepath.Join(filepath.Dir(versionDir), "."+filepath.Base(versionDir)+".staging")
+}
+
+// newStagingDir - creates an empty staging dir of the version dir, the leftover of an interrupted extraction
+// is removed
+func newStagingDir(versionDir string) (string, error) {
+	dir := stagingDir(versionDir)
+	if err := appFS.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("failed to remove staging dir %s: %w", dir, err)
+	}
+	if err := appFS.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging dir %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// removeDirTree - removes the dir tree i.e. the staging dir of a failed extraction, the swapped dir is gone already
+func removeDirTree(dir string) {
+	if err := appFS.RemoveAll(dir); err != nil {
+		log.Warn().Err(err).Msgf("failed to remove staging dir %s", dir)
+	}
+}
+
+// installArtifact - validates the extracted artifact, marks its files read-only and renames the staging dir to
+// the version dir. A version dir without the artifact dir, i.e. left by an extraction before the dirs were swapped,
+// is replaced. ETag of the download is kept next to the artifact dir for the freshness checks.
+func installArtifact(tempDir, versionDir, artifactDir, cmdStart, etag string) error {
+	if err := validateExtractedArtifact(filepath.Join(tempDir, artifactDir), cmdStart); err != nil {
+		return err
+	}
+	if len(etag) > 0 {
+		if err := appFS.WriteFile(filepath.Join(tempDir, artifactDir+artifactETagExt), []byte(etag), 0644); err != nil {
+			return fmt.Errorf("failed to write the artifact ETag: %w", err)
+		}
+	}
+	if err := markReadOnly(tempDir); err != nil {
+		return fmt.Errorf("failed to mark the artifact read-only: %w", err)
+	}
+
+	if _, err := appFS.Stat(filepath.Join(versionDir, artifactDir)); err == nil {
+		log.Info().Msgf("artifact dir %s is already installed", filepath.Join(versionDir, artifactDir))
+		return nil
+	}
+	if _, err := appFS.Stat(versionDir); err == nil {
+		staleDir := filepath.Join(filepath.Dir(versionDir), "."+filepath.Base(versionDir)+".stale")
+		if err := appFS.RemoveAll(staleDir); err != nil {
+			return fmt.Errorf("failed to remove stale dir %s: %w", staleDir, err)
+		}
+		if err := appFS.Rename(versionDir, staleDir); err != nil {
+			return fmt.Errorf("failed to move incomplete version dir %s aside: %w", versionDir, err)
+		}
+		log.Warn().Msgf("replacing incomplete version dir %s", versionDir)
+		defer removeDirTree(staleDir)
+	}
+	if err := appFS.Rename(tempDir, versionDir); err != nil {
+		return fmt.Errorf("failed to swap the artifact into %s: %w", versionDir, err)
+	}
+	return nil
+}
+
+// validateExtractedArtifact - the artifact dir and the start command of the program are extracted
+func validateExtractedArtifact(artifactDir, cmdStart string) error {
+	info, err := appFS.Stat(artifactDir)
+	if err != nil {
+		return fmt.Errorf("artifact dir %s is not extracted: %w", filepath.Base(artifactDir), err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("artifact dir %s is not a directory", filepath.Base(artifactDir))
+	}
+	if len(cmdStart) == 0 {
+		return nil
+	}
+	if info, err := appFS.Stat(filepath.Join(artifactDir, cmdStart)); err != nil {
+		return fmt.Errorf("start command %s is not extracted: %w", cmdStart, err)
+	} else if !info.Mode().IsRegular() {
+		return fmt.Errorf("start command %s is not a regular file", cmdStart)
+	}
+	return nil
+}
+
+// markReadOnly - removes the write permissions of the files of the tree, so the installed artifacts are immutable
+func markReadOnly(dir string) error {
+	return walkFiles(appFS, dir, func(path string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := appFS.Chmod(path, info.Mode().Perm()&^0222); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// verifyCachedArtifact - start command of the cached artifact is executable and its ELF files are parsable
+func (b *BPF) verifyCachedArtifact() error {
+	if len(b.Program.CmdStart) > 0 {
+		cmd := filepath.Join(b.FilePath, b.Program.CmdStart)
+		if err := assertExecutable(cmd); err != nil {
+			return err
+		}
+		if err := verifyELF(cmd); err != nil {
+			return fmt.Errorf("invalid start command %s: %w", b.Program.CmdStart, err)
+		}
+	}
+	objects, err := findBPFObjects(b.FilePath)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if _, err := loadCollectionSpec(object); err != nil {
+			return fmt.Errorf("invalid eBPF object %s: %w", filepath.Base(object), err)
+		}
+	}
+	return nil
+}
+
+// verifyELF - ELF file is parsable, files without the ELF magic e.g. scripts are not verified
+func verifyELF(fileName string) error {
+	f, err := appFS.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rd, ok := f.(io.ReaderAt)
+	if !ok {
+		return fmt.Errorf("file %s does not support random access", fileName)
+	}
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := rd.ReadAt(magic, 0); err != nil {
+		return fmt.Errorf("failed to read the header: %w", err)
+	}
+	if !bytes.Equal(magic, []byte(elf.ELFMAG)) {
+		return nil
+	}
+	ef, err := elf.NewFile(rd)
+	if err != nil {
+		return err
+	}
+	for _, section := range ef.Sections {
+		if section.Type == elf.SHT_NOBITS {
+			continue
+		}
+		if _, err := io.Copy(io.Discard, section.Open()); err != nil {
+			return fmt.Errorf("section %s is truncated: %w", section.Name, err)
+		}
+	}
+	return nil
+}
+
+// invalidateCachedArtifact - removes the corrupted or modified artifact dir from the cache, so it is downloaded again
+func invalidateCachedArtifact(artifactDir string) error {
+	artifactMu.Lock()
+	defer artifactMu.Unlock()
+	if err := appFS.RemoveAll(artifactDir); err != nil {
+		return fmt.Errorf("failed to invalidate cached artifact %s: %w", artifactDir, err)
+	}
+	if err := appFS.Remove(artifactDir + artifactETagExt); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to invalidate cached artifact %s: %w", artifactDir, err)
+	}
+	return nil
+}
+
+// cachedArtifactModified - checks the artifact in the artifact store against the version tag of the cached
+// artifact e.g. with a HEAD request of its ETag, the cached artifact is used when the check fails or the
+// artifact has no tag
+func (b *BPF) cachedArtifactModified(conf *config.Config, artifactDir string) bool {
+	tag, err := appFS.ReadFile(artifactDir + artifactETagExt)
+	if err != nil {
+		return false
+	}
+	modified, err := modifiedInRepos(conf, &b.Program, string(tag))
+	if err != nil {
+		log.Warn().Err(err).Msgf("freshness check of artifact %s failed, using the cached artifact", b.Program.Artifact)
+		return false
+	}
+	return modified
+}
+
+// extractArtifact - extracts the .zip or .tar.gz artifact into the dir, entries escaping the dir are rejected
+func extractArtifact(data []byte, artifact, dir string) error {
+	var fPath = ""
+
+	if strings.HasSuffix(artifact, ".zip") {
+		c := bytes.NewReader(data)
+		zipReader, err := zip.NewReader(c, int64(c.Len()))
+		if err != nil {
+			return fmt.Errorf("failed to create zip reader: %w", err)
+		}
+
+		for _, file := range zipReader.File {
+
+			zippedFile, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("unzip failed: %w", err)
+			}
+
+			extractedFilePath := filepath.Join(
+				dir,
+				file.Name,
+			)
+			if !strings.HasPrefix(extractedFilePath, filepath.Clean(dir)+string(os.PathSeparator)) {
+				return fmt.Errorf("invalid file path: %s", extractedFilePath)
+			}
+			if file.FileInfo().IsDir() {
+				zippedFile.Close()
+				if err := appFS.MkdirAll(extractedFilePath, file.Mode()); err != nil {
+					return fmt.Errorf("unzip failed to create directories: %w", err)
+				}
+			} else {
+				outputFile, err := appFS.OpenFile(
+					extractedFilePath,
+					os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+					file.Mode(),
+				)
+				if err != nil {
+					zippedFile.Close()
+					return fmt.Errorf("unzip failed to create file: %w", err)
+				}
+
+				buf := copyBufPool.Get().(*bytes.Buffer)
+				_, err = io.CopyBuffer(outputFile, zippedFile, buf.Bytes())
+				copyBufPool.Put(buf)
+				zippedFile.Close()
+				// files are closed before the staging dir is swapped
+				if cerr := outputFile.Close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					return fmt.Errorf("GetArtifacts failed to copy files: %w", err)
+				}
+			}
+		}
+	} else if strings.HasSuffix(artifact, ".tar.gz") {
+		archive, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to create Gzip reader: %w", err)
+		}
+		defer archive.Close()
+		tarReader := tar.NewReader(archive)
+
+		for {
+			header, err := tarReader.Next()
+
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("untar failed: %w", err)
+			}
+
+			if strings.Contains(header.Name, "..") {
+				return fmt.Errorf("zipped file contians filepath (%s) that includes (..)", header.Name)
+			}
+
+			fPath = filepath.Join(dir, header.Name)
+			info := header.FileInfo()
+			if info.IsDir() {
+				if err = appFS.MkdirAll(fPath, info.Mode()); err != nil {
+					return fmt.Errorf("untar failed to create directories: %w", err)
+				}
+				continue
+			}
+
+			file, err := appFS.OpenFile(fPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+			if err != nil {
+				return fmt.Errorf("untar failed to create file: %w", err)
+			}
+
+			buf := copyBufPool.Get().(*bytes.Buffer)
+			_, err = io.CopyBuffer(file, tarReader, buf.Bytes())
+			copyBufPool.Put(buf)
+			// files are closed before the staging dir is swapped
+			if cerr := file.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return fmt.Errorf("GetArtifacts failed to copy files: %w", err)
+			}
+		}
+	} else {
+		return fmt.Errorf("unknown artifact format ")
+	}
+
+	return nil
+}
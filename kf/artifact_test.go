@@ -0,0 +1,173 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_installArtifact(t *testing.T) {
+	versionDir := "/var/l3afd/ratelimiting/1.0"
+	staged := func(m *memFS, files ...string) string {
+		dir, err := newStagingDir(versionDir)
+		if err != nil {
+			t.Fatalf("newStagingDir() error = %v", err)
+		}
+		for _, f := range files {
+			if err := m.WriteFile(dir+"/"+f, []byte("elf"), 0755); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("Complete", func(t *testing.T) {
+		m := useMemFS(t, map[string]string{
+			versionDir + "/l3af_ratelimiting.partial": "", // left by an interrupted extraction
+		})
+		dir := staged(m, "l3af_ratelimiting/ratelimiting", "l3af_ratelimiting/ratelimiting_kern.o")
+		if err := installArtifact(dir, versionDir, "l3af_ratelimiting", "ratelimiting", ""); err != nil {
+			t.Fatalf("installArtifact() error = %v", err)
+		}
+		info, err := m.Stat(versionDir + "/l3af_ratelimiting/ratelimiting")
+		if err != nil {
+			t.Fatalf("start command is not installed, error = %v", err)
+		}
+		if info.Mode().Perm() != 0555 {
+			t.Errorf("start command mode = %v, want read-only 0555", info.Mode().Perm())
+		}
+		for _, gone := range []string{dir, versionDir + "/l3af_ratelimiting.partial", "/var/l3afd/ratelimiting/.1.0.stale"} {
+			if _, err := m.Stat(gone); err == nil {
+				t.Errorf("%s is left after the swap", gone)
+			}
+		}
+	})
+
+	t.Run("MissingStartCommand", func(t *testing.T) {
+		m := useMemFS(t, map[string]string{})
+		dir := staged(m, "l3af_ratelimiting/ratelimiting_kern.o")
+		if err := installArtifact(dir, versionDir, "l3af_ratelimiting", "ratelimiting", ""); err == nil {
+			t.Fatalf("installArtifact() error = nil, want missing start command")
+		}
+		if _, err := m.Stat(versionDir); err == nil {
+			t.Errorf("version dir of the incomplete artifact is installed")
+		}
+	})
+
+	t.Run("AlreadyInstalled", func(t *testing.T) {
+		m := useMemFS(t, map[string]string{versionDir + "/l3af_ratelimiting/ratelimiting": "running"})
+		dir := staged(m, "l3af_ratelimiting/ratelimiting")
+		if err := installArtifact(dir, versionDir, "l3af_ratelimiting", "ratelimiting", ""); err != nil {
+			t.Fatalf("installArtifact() error = %v", err)
+		}
+		if data, _ := m.ReadFile(versionDir + "/l3af_ratelimiting/ratelimiting"); string(data) != "running" {
+			t.Errorf("installed artifact is replaced")
+		}
+	})
+}
+
+func Test_verifyELF(t *testing.T) {
+	exe, err := os.ReadFile(os.Args[0])
+	if err != nil || len(exe) < 4096 || string(exe[:4]) != "\x7fELF" {
+		t.Skip("test binary is not an ELF file")
+	}
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"complete":  exe,
+		"truncated": exe[:len(exe)/2],
+		"script":    []byte("#!/bin/sh\nexec ./ratelimiting \"$@\"\n"),
+		"empty":     nil,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "complete", wantErr: false},
+		{name: "truncated", wantErr: true},
+		{name: "script", wantErr: false},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyELF(filepath.Join(dir, tt.name)); (err != nil) != tt.wantErr {
+				t.Errorf("verifyELF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBPF_verifyCachedArtifact(t *testing.T) {
+	m := useMemFS(t, map[string]string{
+		"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/ratelimiting_kern.o": "\x7fELF\x02\x01", // truncated by bit rot
+	})
+	b := &BPF{
+		Program:  models.BPFProgram{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz"},
+		FilePath: "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting",
+	}
+	if err := b.verifyCachedArtifact(); err == nil {
+		t.Fatalf("verifyCachedArtifact() error = nil, want invalid eBPF object")
+	}
+	if err := invalidateCachedArtifact(b.FilePath); err != nil {
+		t.Fatalf("invalidateCachedArtifact() error = %v", err)
+	}
+	if _, err := m.Stat(b.FilePath + "/ratelimiting_kern.o"); err == nil {
+		t.Errorf("corrupted artifact is left in the cache")
+	}
+}
+
+func TestBPF_cachedArtifactModified(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command("/bin/sh", "-c", "echo focal") }
+	defer func() { execCommand = exec.Command }()
+
+	current := `"v2"`
+	repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/ratelimiting/latest/focal/l3af_ratelimiting.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", current)
+		if r.Header.Get("If-None-Match") == current {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer repo.Close()
+
+	artifactDir := "/var/l3afd/ratelimiting/latest/l3af_ratelimiting"
+	conf := &config.Config{KFRepoURL: repo.URL, KFRepoFreshnessCheck: true}
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", Version: "latest", Artifact: "l3af_ratelimiting.tar.gz"}}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{name: "NotModified", files: map[string]string{artifactDir + artifactETagExt: `"v2"`}, want: false},
+		{name: "Modified", files: map[string]string{artifactDir + artifactETagExt: `"v1"`}, want: true},
+		{name: "NoETag", files: map[string]string{}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t, tt.files)
+			if got := b.cachedArtifactModified(conf, artifactDir); got != tt.want {
+				t.Errorf("cachedArtifactModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
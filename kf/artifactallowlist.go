@@ -0,0 +1,205 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ErrArtifactNotAllowed is returned when the artifact of a program is not in the artifact allowlist
+var ErrArtifactNotAllowed = errors.New("artifact is not allowed")
+
+// manifestSignatureExt - extension of the signature file of the allowlist manifest
+const manifestSignatureExt = ".sig"
+
+// artifactManifest - signed manifest of the allowed artifacts by program name
+type artifactManifest struct {
+	Programs map[string]struct {
+		Artifacts []string `json:"artifacts"`
+		Versions  []string `json:"versions"`
+		SHA256    []string `json:"sha256"`
+	} `json:"programs"`
+}
+
+// allowlist - artifacts the programs are permitted to download, of the config groups or the signed manifest
+type allowlist struct {
+	programs     map[string]config.AllowedArtifacts
+	manifestFile string
+	publicKey    ed25519.PublicKey
+}
+
+// artifactAllowlist - nil when the allowlist is disabled
+var artifactAllowlist *allowlist
+
+// setArtifactAllowlist - configures the artifact allowlist from l3afd.cfg, the manifest is verified at start
+func setArtifactAllowlist(conf *config.Config) error {
+	artifactAllowlist = nil
+	if conf == nil || !conf.ArtifactAllowlistEnabled {
+		return nil
+	}
+	a := &allowlist{programs: conf.ArtifactAllowlist, manifestFile: conf.ArtifactAllowlistManifestFile}
+	if len(a.manifestFile) > 0 {
+		key, err := readManifestKey(conf.ArtifactAllowlistPublicKeyFile)
+		if err != nil {
+			return err
+		}
+		a.publicKey = key
+		if _, err := a.entries(); err != nil {
+			return err
+		}
+	}
+	artifactAllowlist = a
+	return nil
+}
+
+// readManifestKey - ed25519 public key of the manifest signature, PEM encoded
+func readManifestKey(keyFile string) (ed25519.PublicKey, error) {
+	if len(keyFile) == 0 {
+		return nil, fmt.Errorf("public key file of the artifact manifest is not configured")
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact manifest public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("artifact manifest public key %s is not PEM encoded", keyFile)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse artifact manifest public key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("artifact manifest public key %s is not an ed25519 key", keyFile)
+	}
+	return edKey, nil
+}
+
+// entries - allowed artifacts by program, the manifest is read and its signature verified on every call
+func (a *allowlist) entries() (map[string]config.AllowedArtifacts, error) {
+	if len(a.manifestFile) == 0 {
+		return a.programs, nil
+	}
+	data, err := os.ReadFile(a.manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact manifest: %w", err)
+	}
+	encoded, err := os.ReadFile(a.manifestFile + manifestSignatureExt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact manifest signature: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("artifact manifest signature is not base64 encoded: %w", err)
+	}
+	if !ed25519.Verify(a.publicKey, data, sig) {
+		return nil, fmt.Errorf("artifact manifest %s signature verification failed", a.manifestFile)
+	}
+
+	var m artifactManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse artifact manifest: %w", err)
+	}
+	programs := make(map[string]config.AllowedArtifacts, len(m.Programs))
+	for name, p := range m.Programs {
+		programs[name] = config.AllowedArtifacts{Artifacts: p.Artifacts, Versions: p.Versions, SHA256: p.SHA256}
+	}
+	return programs, nil
+}
+
+// allowedValue - value is in the list, any value is allowed by an empty list
+func allowedValue(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed - allowed artifacts of the program, the artifact and the version of the program are verified
+func (a *allowlist) allowed(prog *models.BPFProgram) (config.AllowedArtifacts, error) {
+	programs, err := a.entries()
+	if err != nil {
+		return config.AllowedArtifacts{}, fmt.Errorf("%w: %v", ErrArtifactNotAllowed, err)
+	}
+	entry, ok := programs[prog.Name]
+	if !ok {
+		return entry, fmt.Errorf("%w: program %s is not in the artifact allowlist", ErrArtifactNotAllowed, prog.Name)
+	}
+	if !allowedValue(entry.Artifacts, prog.Artifact) {
+		return entry, fmt.Errorf("%w: artifact %s of program %s", ErrArtifactNotAllowed, prog.Artifact, prog.Name)
+	}
+	if !allowedValue(entry.Versions, prog.Version) {
+		return entry, fmt.Errorf("%w: version %s of program %s", ErrArtifactNotAllowed, prog.Version, prog.Name)
+	}
+	return entry, nil
+}
+
+// artifactAllowed - artifact and version of the program are in the allowlist, always when it is disabled
+func artifactAllowed(prog *models.BPFProgram) error {
+	if artifactAllowlist == nil {
+		return nil
+	}
+	_, err := artifactAllowlist.allowed(prog)
+	return err
+}
+
+// verifyArtifactDigest - downloaded archive of the program matches a digest of the allowlist, the archives
+// of the programs without digests are not verified
+func verifyArtifactDigest(prog *models.BPFProgram, data []byte) error {
+	if artifactAllowlist == nil {
+		return nil
+	}
+	entry, err := artifactAllowlist.allowed(prog)
+	if err != nil {
+		return err
+	}
+	if len(entry.SHA256) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	for _, d := range entry.SHA256 {
+		if strings.EqualFold(strings.TrimSpace(d), digest) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: sha256 %s of artifact %s of program %s", ErrArtifactNotAllowed, digest, prog.Artifact, prog.Name)
+}
+
+// ValidateArtifacts - Verifies the artifacts of the programs are permitted by the artifact allowlist
+func ValidateArtifacts(bpfProgs []models.L3afBPFPrograms) error {
+	if artifactAllowlist == nil {
+		return nil
+	}
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := artifactAllowed(ref.prog); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
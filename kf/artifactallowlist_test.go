@@ -0,0 +1,125 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_artifactAllowed(t *testing.T) {
+	defer func() { artifactAllowlist = nil }()
+	archive := []byte("l3af_ratelimiting.tar.gz content")
+	sum := sha256.Sum256(archive)
+	conf := &config.Config{ArtifactAllowlistEnabled: true, ArtifactAllowlist: map[string]config.AllowedArtifacts{
+		"ratelimiting": {Artifacts: []string{"l3af_ratelimiting.tar.gz"}, Versions: []string{"1.0.0"}, SHA256: []string{hex.EncodeToString(sum[:])}},
+		"connlimit":    {},
+	}}
+	if err := setArtifactAllowlist(conf); err != nil {
+		t.Fatalf("setArtifactAllowlist() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		prog    models.BPFProgram
+		wantErr bool
+	}{
+		{"allowed", models.BPFProgram{Name: "ratelimiting", Artifact: "l3af_ratelimiting.tar.gz", Version: "1.0.0"}, false},
+		{"any artifact", models.BPFProgram{Name: "connlimit", Artifact: "l3af_connlimit.tar.gz", Version: "latest"}, false},
+		{"version", models.BPFProgram{Name: "ratelimiting", Artifact: "l3af_ratelimiting.tar.gz", Version: "1.0.1"}, true},
+		{"artifact", models.BPFProgram{Name: "ratelimiting", Artifact: "evil.tar.gz", Version: "1.0.0"}, true},
+		{"program", models.BPFProgram{Name: "ipfix", Artifact: "l3af_ipfix.tar.gz", Version: "1.0.0"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := artifactAllowed(&tt.prog)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("artifactAllowed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrArtifactNotAllowed) {
+				t.Errorf("artifactAllowed() error = %v, want ErrArtifactNotAllowed", err)
+			}
+		})
+	}
+
+	prog := &models.BPFProgram{Name: "ratelimiting", Artifact: "l3af_ratelimiting.tar.gz", Version: "1.0.0"}
+	if err := verifyArtifactDigest(prog, archive); err != nil {
+		t.Errorf("verifyArtifactDigest() error = %v", err)
+	}
+	if err := verifyArtifactDigest(prog, []byte("tampered")); !errors.Is(err, ErrArtifactNotAllowed) {
+		t.Errorf("verifyArtifactDigest() error = %v, want ErrArtifactNotAllowed", err)
+	}
+}
+
+func Test_artifactAllowlistManifest(t *testing.T) {
+	defer func() { artifactAllowlist = nil }()
+	dir := t.TempDir()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "manifest.pub")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	manifestFile := filepath.Join(dir, "artifacts.json")
+	writeManifest := func(manifest string, key ed25519.PrivateKey) {
+		if err := os.WriteFile(manifestFile, []byte(manifest), 0600); err != nil {
+			t.Fatal(err)
+		}
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(manifest)))
+		if err := os.WriteFile(manifestFile+manifestSignatureExt, []byte(sig+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeManifest(`{"programs": {"ratelimiting": {"versions": ["1.0.0"]}}}`, private)
+
+	conf := &config.Config{ArtifactAllowlistEnabled: true, ArtifactAllowlistManifestFile: manifestFile, ArtifactAllowlistPublicKeyFile: keyFile,
+		ArtifactAllowlist: map[string]config.AllowedArtifacts{"connlimit": {}}}
+	if err := setArtifactAllowlist(conf); err != nil {
+		t.Fatalf("setArtifactAllowlist() error = %v", err)
+	}
+	if err := artifactAllowed(&models.BPFProgram{Name: "ratelimiting", Version: "1.0.0"}); err != nil {
+		t.Errorf("artifactAllowed() error = %v", err)
+	}
+	// groups are not used with the manifest
+	if err := artifactAllowed(&models.BPFProgram{Name: "connlimit", Version: "1.0.0"}); err == nil {
+		t.Errorf("artifactAllowed() error = nil, want the program missing in the manifest")
+	}
+
+	// new manifest is used without restart
+	writeManifest(`{"programs": {"ratelimiting": {"versions": ["1.0.1"]}}}`, private)
+	if err := artifactAllowed(&models.BPFProgram{Name: "ratelimiting", Version: "1.0.1"}); err != nil {
+		t.Errorf("artifactAllowed() error = %v for the new manifest", err)
+	}
+
+	// manifest signed by another key is rejected
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeManifest(`{"programs": {"ratelimiting": {}}}`, other)
+	if err := artifactAllowed(&models.BPFProgram{Name: "ratelimiting", Version: "1.0.1"}); !errors.Is(err, ErrArtifactNotAllowed) {
+		t.Errorf("artifactAllowed() error = %v, want the manifest signature rejected", err)
+	}
+	if err := setArtifactAllowlist(conf); err == nil {
+		t.Errorf("setArtifactAllowlist() error = nil, want the manifest signature rejected")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// artifactPeerPath - path of the shared artifacts of the l3afd API of the peers
+const artifactPeerPath = "/l3af/artifacts/v1"
+
+var (
+	// ErrArtifactNotShared - artifact sharing is disabled or the artifact is not in the cache of the node
+	ErrArtifactNotShared = errors.New("artifact is not shared")
+	// ErrArtifactPeerUnauthorized - token of the peer doesn't match the token of the site
+	ErrArtifactPeerUnauthorized = errors.New("artifact peer is not authorized")
+)
+
+// artifactPeerToken - token shared by the nodes of the site
+func artifactPeerToken(conf *config.Config) (string, error) {
+	if len(conf.ArtifactPeersTokenFile) == 0 {
+		return "", fmt.Errorf("artifact peers token file is not configured")
+	}
+	token, err := os.ReadFile(conf.ArtifactPeersTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read artifact peers token: %w", err)
+	}
+	if len(bytes.TrimSpace(token)) == 0 {
+		return "", fmt.Errorf("artifact peers token file %s is empty", conf.ArtifactPeersTokenFile)
+	}
+	return string(bytes.TrimSpace(token)), nil
+}
+
+// validArtifactPathElem - element of the artifact path doesn't leave its dir
+func validArtifactPathElem(elem string) bool {
+	return len(elem) > 0 && elem != "." && elem != ".." && !strings.ContainsAny(elem, `/\`)
+}
+
+// sharedArtifactPath - archive of the verified artifact kept in the version dir for the peers
+func sharedArtifactPath(conf *config.Config, prog *models.BPFProgram) string {
+	return filepath.Join(conf.BPFDir, prog.Name, prog.Version, prog.Artifact)
+}
+
+// SharedArtifact - archive of the verified artifact of the node cache and its version tag, served to the peers
+// of the site presenting the token of the site
+func (c *NFConfigs) SharedArtifact(token, name, version, platform, artifact string) ([]byte, string, error) {
+	conf := c.hostConfig
+	if conf == nil || !conf.ArtifactPeersEnabled {
+		return nil, "", ErrArtifactNotShared
+	}
+	want, err := artifactPeerToken(conf)
+	if err != nil {
+		return nil, "", err
+	}
+	// hashes of equal length are compared, so the comparison doesn't leak the length of the token
+	gotSum, wantSum := sha256.Sum256([]byte(token)), sha256.Sum256([]byte(want))
+	if subtle.ConstantTimeCompare(gotSum[:], wantSum[:]) != 1 {
+		return nil, "", ErrArtifactPeerUnauthorized
+	}
+	for _, elem := range []string{name, version, platform, artifact} {
+		if !validArtifactPathElem(elem) {
+			return nil, "", fmt.Errorf("%w: invalid path element %q", ErrArtifactNotShared, elem)
+		}
+	}
+	// the cache holds the artifacts of the platform of the node
+	if local, err := GetPlatform(); err != nil || local != platform {
+		return nil, "", fmt.Errorf("%w: platform %s is not the platform of the node", ErrArtifactNotShared, platform)
+	}
+
+	prog := &models.BPFProgram{Name: name, Version: version, Artifact: artifact}
+	archive := sharedArtifactPath(conf, prog)
+	artifactMu.Lock()
+	defer artifactMu.Unlock()
+	data, err := appFS.ReadFile(archive)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrArtifactNotShared, err)
+	}
+	tag, _ := appFS.ReadFile(filepath.Join(filepath.Dir(archive), strings.Split(artifact, ".")[0]+artifactETagExt))
+	return data, string(tag), nil
+}
+
+// fetchArtifactFromPeers - artifact of the program from the cache of a peer of the site and its version tag,
+// the peers are tried in a random order so the downloads of a rollout are spread across them
+func fetchArtifactFromPeers(conf *config.Config, prog *models.BPFProgram) ([]byte, string, error) {
+	token, err := artifactPeerToken(conf)
+	if err != nil {
+		return nil, "", err
+	}
+	platform, err := GetPlatform()
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := newPeerClient(conf)
+	if err != nil {
+		return nil, "", err
+	}
+	if conf.ArtifactPeersTimeout > 0 {
+		client.Timeout = conf.ArtifactPeersTimeout
+	}
+
+	artifactPath := strings.Join([]string{artifactPeerPath, url.PathEscape(prog.Name), url.PathEscape(prog.Version),
+		url.PathEscape(platform), url.PathEscape(prog.Artifact)}, "/")
+	var errs []string
+	for _, i := range rand.Perm(len(conf.ArtifactPeers)) {
+		peer := strings.TrimSuffix(conf.ArtifactPeers[i], "/")
+		data, tag, err := fetchPeerArtifact(client, peer+artifactPath, token)
+		if err == nil {
+			return data, tag, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", peer, err))
+	}
+	return nil, "", fmt.Errorf("artifact %s is not available from the peers: %s", prog.Artifact, strings.Join(errs, "; "))
+}
+
+func fetchPeerArtifact(client *http.Client, artifactURL, token string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, artifactURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("get request returned status code %d", resp.StatusCode)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), resp.Header.Get("ETag"), nil
+}
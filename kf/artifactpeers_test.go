@@ -0,0 +1,86 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestArtifactPeers(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command("/bin/sh", "-c", "echo focal") }
+	defer func() { execCommand = exec.Command }()
+
+	tokenFile := t.TempDir() + "/token"
+	if err := os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	useMemFS(t, map[string]string{
+		"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting.tar.gz": "archive",
+		"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting.etag":   `"v1"`,
+	})
+	conf := &config.Config{BPFDir: "/var/l3afd", ArtifactPeersEnabled: true, ArtifactPeersTokenFile: tokenFile}
+	c := &NFConfigs{hostConfig: conf}
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		elems := strings.Split(strings.TrimPrefix(r.URL.Path, artifactPeerPath+"/"), "/")
+		if len(elems) != 4 {
+			http.NotFound(w, r)
+			return
+		}
+		data, tag, err := c.SharedArtifact(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), elems[0], elems[1], elems[2], elems[3])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", tag)
+		w.Write(data)
+	}))
+	defer peer.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	fetchConf := &config.Config{ArtifactPeers: []string{down.URL, peer.URL}, ArtifactPeersTokenFile: tokenFile}
+	prog := &models.BPFProgram{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz"}
+	data, tag, err := fetchArtifactFromPeers(fetchConf, prog)
+	if err != nil || string(data) != "archive" || tag != `"v1"` {
+		t.Fatalf("fetchArtifactFromPeers() = %q, %q, %v", data, tag, err)
+	}
+	if _, _, err := fetchArtifactFromPeers(fetchConf, &models.BPFProgram{Name: "ratelimiting", Version: "2.0", Artifact: "l3af_ratelimiting.tar.gz"}); err == nil {
+		t.Errorf("fetchArtifactFromPeers() error = nil, want artifact not in the peer cache")
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		elems    [4]string
+		disabled bool
+		wantErr  error
+	}{
+		{name: "Unauthorized", token: "guess", elems: [4]string{"ratelimiting", "1.0", "focal", "l3af_ratelimiting.tar.gz"}, wantErr: ErrArtifactPeerUnauthorized},
+		{name: "Traversal", token: "s3cr3t", elems: [4]string{"ratelimiting", "..", "focal", "l3af_ratelimiting.tar.gz"}, wantErr: ErrArtifactNotShared},
+		{name: "OtherPlatform", token: "s3cr3t", elems: [4]string{"ratelimiting", "1.0", "jammy", "l3af_ratelimiting.tar.gz"}, wantErr: ErrArtifactNotShared},
+		{name: "Disabled", token: "s3cr3t", elems: [4]string{"ratelimiting", "1.0", "focal", "l3af_ratelimiting.tar.gz"}, disabled: true, wantErr: ErrArtifactNotShared},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf.ArtifactPeersEnabled = !tt.disabled
+			defer func() { conf.ArtifactPeersEnabled = true }()
+			if _, _, err := c.SharedArtifact(tt.token, tt.elems[0], tt.elems[1], tt.elems[2], tt.elems[3]); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SharedArtifact() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+// ArtifactStore - source of the artifacts of the programs, the artifact location is the KF repo url joined
+// with the name, version, platform and artifact of the program
+type ArtifactStore interface {
+	// Fetch - content of the artifact and its version tag e.g. the ETag, empty when the store has none
+	Fetch(location *url.URL) ([]byte, string, error)
+	// Modified - artifact differs from the version tag of the cached artifact
+	Modified(location *url.URL, tag string) (bool, error)
+	// Check - store of the KF repo url is reachable
+	Check() error
+}
+
+// ArtifactStoreFactory - creates the artifact store of the KF repo url with its driver config
+type ArtifactStoreFactory func(conf *config.Config, repoURL *url.URL) (ArtifactStore, error)
+
+// artifactStores - drivers of the artifact stores keyed by the scheme of the KF repo url
+var artifactStores = struct {
+	sync.RWMutex
+	drivers map[string]ArtifactStoreFactory
+}{drivers: make(map[string]ArtifactStoreFactory)}
+
+func init() {
+	RegisterArtifactStore("http", newHTTPArtifactStore)
+	RegisterArtifactStore("https", newHTTPArtifactStore)
+	RegisterArtifactStore("file", newFileArtifactStore)
+	RegisterArtifactStore("s3", newS3ArtifactStore)
+	RegisterArtifactStore("oci", newOCIArtifactStore)
+}
+
+// RegisterArtifactStore - registers the driver of the artifact stores of the scheme, a driver registered
+// again replaces the previous one
+func RegisterArtifactStore(scheme string, factory ArtifactStoreFactory) {
+	artifactStores.Lock()
+	defer artifactStores.Unlock()
+	artifactStores.drivers[strings.ToLower(scheme)] = factory
+}
+
+// artifactStoreSchemes - schemes of the registered drivers
+func artifactStoreSchemes() []string {
+	artifactStores.RLock()
+	defer artifactStores.RUnlock()
+	schemes := make([]string, 0, len(artifactStores.drivers))
+	for scheme := range artifactStores.drivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// newArtifactStore - artifact store of the KF repo url by the driver of its scheme
+func newArtifactStore(conf *config.Config) (ArtifactStore, error) {
+	return newRepoArtifactStore(conf, conf.KFRepoURL)
+}
+
+// newRepoArtifactStore - artifact store of the KF repo url or of a mirror by the driver of its scheme
+func newRepoArtifactStore(conf *config.Config, kfRepoURL string) (ArtifactStore, error) {
+	repoURL, err := url.Parse(kfRepoURL)
+	if err != nil {
+		return nil, fmt.Errorf("unknown KF repo url format: %w", err)
+	}
+	artifactStores.RLock()
+	factory, ok := artifactStores.drivers[strings.ToLower(repoURL.Scheme)]
+	artifactStores.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no artifact store driver for scheme %q of KF repo url %s, registered schemes %s",
+			repoURL.Scheme, kfRepoURL, strings.Join(artifactStoreSchemes(), ", "))
+	}
+	store, err := factory(conf, repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure %s artifact store: %w", repoURL.Scheme, err)
+	}
+	return store, nil
+}
+
+// httpArtifactStore - artifacts of a HTTP repo e.g. nexus, the version tag is the ETag
+type httpArtifactStore struct {
+	repoURL *url.URL
+	client  *http.Client
+}
+
+func newHTTPArtifactStore(conf *config.Config, repoURL *url.URL) (ArtifactStore, error) {
+	client, err := newKFRepoClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &httpArtifactStore{repoURL: repoURL, client: client}, nil
+}
+
+func (s *httpArtifactStore) Fetch(location *url.URL) ([]byte, string, error) {
+	resp, err := s.client.Get(location.String())
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("get request returned unexpected status code: %d (%s), %d was expected\n\tResponse Body: %s", resp.StatusCode, http.StatusText(resp.StatusCode), http.StatusOK, buf.Bytes())
+	}
+	return buf.Bytes(), resp.Header.Get("ETag"), nil
+}
+
+// Modified - HEAD request with If-None-Match of the tag, a response without ETag is not modified
+func (s *httpArtifactStore) Modified(location *url.URL, tag string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, location.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("If-None-Match", tag)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+		current := resp.Header.Get("ETag")
+		return len(current) > 0 && current != tag, nil
+	default:
+		return false, fmt.Errorf("HEAD request returned status code %d", resp.StatusCode)
+	}
+}
+
+// Check - repo responds to a HEAD request of its url without a server error
+func (s *httpArtifactStore) Check() error {
+	if s.client.Timeout == 0 {
+		s.client.Timeout = 10 * time.Second
+	}
+	resp, err := s.client.Head(s.repoURL.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("HEAD request returned %s", resp.Status)
+	}
+	return nil
+}
+
+// fileArtifactStore - artifacts of a local dir or a mounted share e.g. file:///srv/l3af, the version tag
+// is the SHA-256 of the artifact
+type fileArtifactStore struct {
+	dir string
+}
+
+func newFileArtifactStore(conf *config.Config, repoURL *url.URL) (ArtifactStore, error) {
+	if len(repoURL.Host) > 0 && repoURL.Host != "localhost" {
+		return nil, fmt.Errorf("file KF repo url %s must be a local path e.g. file:///srv/l3af", repoURL)
+	}
+	return &fileArtifactStore{dir: repoURL.Path}, nil
+}
+
+func fileArtifactTag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (s *fileArtifactStore) Fetch(location *url.URL) ([]byte, string, error) {
+	data, err := appFS.ReadFile(location.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, fileArtifactTag(data), nil
+}
+
+func (s *fileArtifactStore) Modified(location *url.URL, tag string) (bool, error) {
+	data, err := appFS.ReadFile(location.Path)
+	if err != nil {
+		return false, err
+	}
+	return fileArtifactTag(data) != tag, nil
+}
+
+func (s *fileArtifactStore) Check() error {
+	info, err := appFS.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.dir)
+	}
+	return nil
+}
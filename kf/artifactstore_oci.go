@@ -0,0 +1,264 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+const (
+	ociManifestMediaTypes = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+	ociTitleAnnotation    = "org.opencontainers.image.title"
+)
+
+// ociManifest - layers of an image manifest
+type ociManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Size        int64             `json:"size"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// ociArtifactStore - artifacts of an OCI registry e.g. oci://<registry>/<prefix>, the artifact of the program
+// is the layer titled with the artifact name of the <prefix>/<name>:<version>-<platform> manifest, the version
+// tag is the digest of the layer
+type ociArtifactStore struct {
+	scheme   string
+	registry string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newOCIArtifactStore(conf *config.Config, repoURL *url.URL) (ArtifactStore, error) {
+	if len(repoURL.Host) == 0 {
+		return nil, fmt.Errorf("registry of the oci KF repo url %s is empty", repoURL)
+	}
+	client, err := newKFRepoClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	s := &ociArtifactStore{scheme: "https", registry: repoURL.Host, username: conf.ArtifactOCIUsername, client: client}
+	if conf.ArtifactOCIPlainHTTP {
+		s.scheme = "http"
+	}
+	if len(conf.ArtifactOCIPasswordFile) > 0 {
+		password, err := os.ReadFile(conf.ArtifactOCIPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read oci registry password: %w", err)
+		}
+		s.password = strings.TrimSpace(string(password))
+	}
+	return s, nil
+}
+
+// ociReference - repository, tag and layer title of the artifact location
+func ociReference(location *url.URL) (string, string, string, error) {
+	parts := strings.Split(strings.Trim(location.Path, "/"), "/")
+	if len(parts) < 4 {
+		return "", "", "", fmt.Errorf("artifact location %s is not <repository>/<version>/<platform>/<artifact>", location)
+	}
+	n := len(parts)
+	return path.Join(parts[:n-3]...), parts[n-3] + "-" + parts[n-2], parts[n-1], nil
+}
+
+// get - GET of the registry api, the bearer token or the basic credentials of the challenge are sent again
+// when the registry requires authentication
+func (s *ociArtifactStore) get(apiPath, accept string) (*http.Response, error) {
+	u := s.scheme + "://" + s.registry + apiPath
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err == nil && len(accept) > 0 {
+			req.Header.Set("Accept", accept)
+		}
+		return req, err
+	}
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+	scheme, params := parseAuthChallenge(challenge)
+	switch scheme {
+	case "bearer":
+		token, err := s.token(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		if len(s.username) == 0 {
+			return nil, fmt.Errorf("oci registry %s requires credentials", s.registry)
+		}
+		req.SetBasicAuth(s.username, s.password)
+	default:
+		return nil, fmt.Errorf("unsupported authentication challenge %q of oci registry %s", challenge, s.registry)
+	}
+	return s.client.Do(req)
+}
+
+// token - bearer token of the realm of the challenge, anonymous without credentials
+func (s *ociArtifactStore) token(params map[string]string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || len(params["realm"]) == 0 {
+		return "", fmt.Errorf("invalid token realm %q of oci registry %s", params["realm"], s.registry)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if len(params[key]) > 0 {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if len(s.username) > 0 {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request of oci registry %s returned status code %d", s.registry, resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token of oci registry %s: %w", s.registry, err)
+	}
+	if len(body.Token) > 0 {
+		return body.Token, nil
+	}
+	if len(body.AccessToken) > 0 {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("token response of oci registry %s has no token", s.registry)
+}
+
+// parseAuthChallenge - lower case scheme and the parameters of a WWW-Authenticate challenge
+func parseAuthChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	fields := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(fields) == 2 {
+		for _, param := range strings.Split(fields[1], ",") {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 {
+				params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+			}
+		}
+	}
+	return strings.ToLower(fields[0]), params
+}
+
+// layerDigest - digest of the layer of the artifact in the manifest of the location, the only layer of a
+// manifest without titles
+func (s *ociArtifactStore) layerDigest(location *url.URL) (string, string, error) {
+	repository, tag, artifact, err := ociReference(location)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := s.get("/v2/"+repository+"/manifests/"+tag, ociManifestMediaTypes)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("oci manifest %s:%s returned status code %d", repository, tag, resp.StatusCode)
+	}
+	var manifest ociManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return "", "", fmt.Errorf("failed to decode oci manifest %s:%s: %w", repository, tag, err)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.Annotations[ociTitleAnnotation] == artifact {
+			return repository, layer.Digest, nil
+		}
+	}
+	if len(manifest.Layers) == 1 && len(manifest.Layers[0].Annotations[ociTitleAnnotation]) == 0 {
+		return repository, manifest.Layers[0].Digest, nil
+	}
+	return "", "", fmt.Errorf("oci manifest %s:%s has no layer %s", repository, tag, artifact)
+}
+
+// Fetch - blob of the layer of the artifact, verified against its digest
+func (s *ociArtifactStore) Fetch(location *url.URL) ([]byte, string, error) {
+	repository, digest, err := s.layerDigest(location)
+	if err != nil {
+		return nil, "", err
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, "", fmt.Errorf("unsupported digest %s of oci layer", digest)
+	}
+	resp, err := s.get("/v2/"+repository+"/blobs/"+digest, "")
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("oci blob %s@%s returned unexpected status code: %d (%s)", repository, digest, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, "", fmt.Errorf("oci blob %s@%s doesn't match its digest", repository, digest)
+	}
+	return buf.Bytes(), digest, nil
+}
+
+func (s *ociArtifactStore) Modified(location *url.URL, tag string) (bool, error) {
+	_, digest, err := s.layerDigest(location)
+	if err != nil {
+		return false, err
+	}
+	return digest != tag, nil
+}
+
+// Check - registry responds to the api version check, unauthorized is reachable
+func (s *ociArtifactStore) Check() error {
+	if s.client.Timeout == 0 {
+		s.client.Timeout = 10 * time.Second
+	}
+	resp, err := s.client.Get(s.scheme + "://" + s.registry + "/v2/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("oci registry %s returned %s", s.registry, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+// emptyPayloadSHA256 - SHA-256 of the empty body of the GET and HEAD requests
+const emptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Credentials - credentials of the signed requests, requests are unsigned when the access key is empty
+type s3Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// s3ArtifactStore - artifacts of a S3 bucket or a S3 compatible store e.g. s3://<bucket>/<prefix>, the
+// version tag is the ETag of the object
+type s3ArtifactStore struct {
+	bucket   string
+	region   string
+	endpoint *url.URL
+	creds    s3Credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func newS3ArtifactStore(conf *config.Config, repoURL *url.URL) (ArtifactStore, error) {
+	if len(repoURL.Host) == 0 {
+		return nil, fmt.Errorf("bucket of the s3 KF repo url %s is empty", repoURL)
+	}
+	client, err := newKFRepoClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	s := &s3ArtifactStore{bucket: repoURL.Host, region: conf.ArtifactS3Region, client: client, now: time.Now}
+	if len(s.region) == 0 {
+		s.region = "us-east-1"
+	}
+	if len(conf.ArtifactS3Endpoint) > 0 {
+		if s.endpoint, err = url.Parse(conf.ArtifactS3Endpoint); err != nil {
+			return nil, fmt.Errorf("invalid s3 endpoint %s: %w", conf.ArtifactS3Endpoint, err)
+		}
+	}
+
+	if len(conf.ArtifactS3AccessKeyID) > 0 {
+		secret, err := os.ReadFile(conf.ArtifactS3SecretAccessKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read s3 secret access key: %w", err)
+		}
+		s.creds = s3Credentials{accessKeyID: conf.ArtifactS3AccessKeyID, secretAccessKey: strings.TrimSpace(string(secret))}
+	} else {
+		s.creds = s3Credentials{
+			accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+	return s, nil
+}
+
+// objectURL - url of the object of the key, path-style with the endpoint, virtual-hosted on AWS otherwise
+func (s *s3ArtifactStore) objectURL(key string) *url.URL {
+	key = strings.TrimPrefix(key, "/")
+	if s.endpoint != nil {
+		u := *s.endpoint
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+		return &u
+	}
+	return &url.URL{Scheme: "https", Host: s.bucket + ".s3." + s.region + ".amazonaws.com", Path: "/" + key}
+}
+
+func (s *s3ArtifactStore) request(method, key string) (*http.Response, error) {
+	u := s.objectURL(key)
+	// the key is sent as encoded in the signature
+	u.RawPath = awsURIEncode(u.Path)
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.creds.accessKeyID) > 0 {
+		signS3Request(req, s.creds, s.region, s.now())
+	}
+	return s.client.Do(req)
+}
+
+func (s *s3ArtifactStore) Fetch(location *url.URL) ([]byte, string, error) {
+	resp, err := s.request(http.MethodGet, location.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("s3 get object %s returned unexpected status code: %d (%s)\n\tResponse Body: %s", location.Path, resp.StatusCode, http.StatusText(resp.StatusCode), buf.Bytes())
+	}
+	return buf.Bytes(), resp.Header.Get("ETag"), nil
+}
+
+func (s *s3ArtifactStore) Modified(location *url.URL, tag string) (bool, error) {
+	resp, err := s.request(http.MethodHead, location.Path)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("s3 head object %s returned status code %d", location.Path, resp.StatusCode)
+	}
+	current := resp.Header.Get("ETag")
+	return len(current) > 0 && current != tag, nil
+}
+
+// Check - bucket responds to a HEAD request without a server error
+func (s *s3ArtifactStore) Check() error {
+	if s.client.Timeout == 0 {
+		s.client.Timeout = 10 * time.Second
+	}
+	resp, err := s.request(http.MethodHead, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("s3 head bucket %s returned %s", s.bucket, resp.Status)
+	}
+	return nil
+}
+
+// signS3Request - signs the request with AWS signature version 4, the host and all the headers of the
+// request are signed
+func signS3Request(req *http.Request, creds s3Credentials, region string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadSHA256)
+	if len(creds.sessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadSHA256,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode - path encoded as signed by S3, every byte except the unreserved characters and '/'
+func awsURIEncode(p string) string {
+	if len(p) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, strings.ReplaceAll(url.QueryEscape(k), "+", "%20")+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,57 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// auditRecord - admin operation recorded in the audit log
+type auditRecord struct {
+	Time    time.Time         `json:"time"`
+	Action  string            `json:"action"`
+	Remote  string            `json:"remote,omitempty"`
+	Details map[string]string `json:"details"`
+}
+
+// serializes the writes of the audit records
+var auditMu sync.Mutex
+
+// Audit - records the admin operation in the audit log file as a JSON line, the record is logged
+// to the l3afd log when the audit log file is not configured or not writable
+func (c *NFConfigs) Audit(action, remote string, details map[string]string) {
+	record := auditRecord{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Remote:  remote,
+		Details: details,
+	}
+	buf, err := json.Marshal(record)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to marshal audit record of %s", action)
+		return
+	}
+
+	if len(c.hostConfig.AuditLogFile) > 0 {
+		auditMu.Lock()
+		defer auditMu.Unlock()
+		f, err := appFS.OpenFile(c.hostConfig.AuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.Write(append(buf, '\n'))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err == nil {
+			return
+		}
+		log.Error().Err(err).Msgf("failed to write audit log %s", c.hostConfig.AuditLogFile)
+	}
+	log.Info().RawJSON("audit", buf).Msg("audit")
+}
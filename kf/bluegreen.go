@@ -0,0 +1,203 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// slotGreen - blue/green slot of the version running with the map name of the blue_green config
+const slotGreen = "green"
+
+// testRunProgram - runs the packet through the loaded program of the ID by BPF_PROG_TEST_RUN and returns its
+// verdict
+var testRunProgram = func(progID int, packet []byte) (uint32, error) {
+	prog, err := ebpf.NewProgramFromID(ebpf.ProgramID(progID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get program from ID %d: %w", progID, err)
+	}
+	defer prog.Close()
+	verdict, _, err := prog.Test(packet)
+	return verdict, err
+}
+
+// validateBlueGreen - chaining map of the green slot is set apart from the map of the blue slot, and the samples
+// are not negative
+func validateBlueGreen(prog *models.BPFProgram) error {
+	bg := prog.BlueGreen
+	if bg == nil {
+		return nil
+	}
+	if len(bg.MapName) == 0 {
+		return fmt.Errorf("blue_green map name is not set")
+	}
+	if bg.MapName == prog.MapName {
+		return fmt.Errorf("blue_green map name %s is the map name of the blue slot", bg.MapName)
+	}
+	if filepath.Base(bg.MapName) != filepath.Base(prog.MapName) {
+		return fmt.Errorf("blue_green map name %s is not pinned as map %s of the eBPF objects", bg.MapName, filepath.Base(prog.MapName))
+	}
+	if bg.ValidateSamples < 0 {
+		return fmt.Errorf("invalid blue_green validate samples %d", bg.ValidateSamples)
+	}
+	for _, cm := range prog.ConsumedMaps {
+		if len(cm.PinPath) > 0 {
+			return fmt.Errorf("consumed map %s is re-pinned at %s, which is not supported by blue/green upgrades", cm.Name, cm.PinPath)
+		}
+	}
+	return nil
+}
+
+// ValidateBlueGreen - Verifies the blue/green configs of the programs, the versions are swapped in the chain so
+// bpf chaining is required
+func (c *NFConfigs) ValidateBlueGreen(bpfProgs []models.L3afBPFPrograms) error {
+	chain := c.hostConfig != nil && c.hostConfig.BpfChainingEnabled
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil || ref.prog.BlueGreen == nil {
+				continue
+			}
+			if !chain {
+				return fmt.Errorf("program %s on iface %s has blue/green upgrades, which require bpf chaining", ref.prog.Name, cfg.Iface)
+			}
+			if err := validateBlueGreen(ref.prog); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// slotName - name of the program instance, the version in the green slot runs alongside the version in the
+// blue slot during the upgrade
+func (b *BPF) slotName() string {
+	if len(b.slot) == 0 {
+		return b.Program.Name
+	}
+	return b.Program.Name + "-" + b.slot
+}
+
+// configProgram - config of the program, the version in the green slot runs with the chaining maps of the slots
+// swapped
+func (b *BPF) configProgram() *models.BPFProgram {
+	if b.slot != slotGreen || b.Program.BlueGreen == nil {
+		return &b.Program
+	}
+	prog := b.Program
+	bg := *prog.BlueGreen
+	prog.MapName, bg.MapName = bg.MapName, prog.MapName
+	prog.BlueGreen = &bg
+	return &prog
+}
+
+// samplePackets - packets of the interface to test run the programs with, the latest packet tap samples first
+// and the self test packet for the rest, returns the count of the tap samples
+func samplePackets(ifaceName string, count int) ([][]byte, int) {
+	samples := packetTaps.recentSamples(ifaceName, count)
+	tapped := len(samples)
+	for len(samples) < count {
+		samples = append(samples, selfTestPacket())
+	}
+	return samples, tapped
+}
+
+// validateGreen - test runs the sample packets through the new version in the shadow slot. The swap is aborted
+// when a run fails or the XDP program aborts a packet. The runs update the maps of the new version as the packets
+// would.
+func validateGreen(b *BPF, ifaceName, direction string, count int) error {
+	if count <= 0 {
+		return nil
+	}
+	samples, tapped := samplePackets(ifaceName, count)
+	for i, sample := range samples {
+		verdict, err := testRunProgram(b.ProgID, sample)
+		if err != nil {
+			return fmt.Errorf("test run of sample %d failed: %w", i, err)
+		}
+		if direction == models.XDPIngressType && verdict == verdicts["XDP_ABORTED"] {
+			return fmt.Errorf("sample %d is aborted by the program", i)
+		}
+	}
+	log.Info().Msgf("program %s version %s validated by %d samples, %d of the packet tap", b.Program.Name, b.Program.Version, count, tapped)
+	return nil
+}
+
+// stopSlot - stops the version of the program in its slot, the shared maps, the pin paths and the running state
+// of the program are kept for the version in the other slot
+func stopSlot(b, other *BPF, ifaceName, direction string) {
+	if err := b.Stop(ifaceName, direction, true); err != nil {
+		log.Warn().Err(err).Msgf("failed to stop version %s of program %s in slot %s", b.Program.Version, b.Program.Name, b.slotName())
+	}
+	sharedMaps.register(ifaceName, other.Program.Name, other.Program.SharedMaps)
+	other.claimPinPaths(ifaceName, direction)
+	stats.Set(1.0, stats.NFRunning, other.Program.Name, direction)
+}
+
+// blueGreenUpgrade - starts the new version of the program in the other slot alongside the running version with
+// its program inserted into a shadow prog map, validates it and swaps it into the chain by a single update of
+// the prog map of the predecessor, then retires the running version. The running version keeps processing the
+// packets when the new version fails to start or validate.
+func (c *NFConfigs) blueGreenUpgrade(e *list.Element, bpfProg *models.BPFProgram, ifaceName, direction string) error {
+	blue := e.Value.(*BPF)
+	started := time.Now()
+
+	green := NewBpfProgram(c.ctx, *bpfProg, c.hostConfig.BPFLogDir, c.hostConfig.DataCenter)
+	green.BTFPath = c.btfPath
+	green.activated = blue.activated
+	if blue.slot != slotGreen {
+		green.slot = slotGreen
+		green.Program = *green.configProgram()
+	}
+	log.Info().Msgf("blue/green upgrade of program %s iface %s direction %s from version %s to %s in slot %s",
+		bpfProg.Name, ifaceName, direction, blue.Program.Version, bpfProg.Version, green.slotName())
+
+	shadow := standbyMapPath(ifaceName, direction, green.slotName())
+	if err := createStandbyMap(shadow); err != nil {
+		return fmt.Errorf("failed to create the shadow slot of program %s: %w", bpfProg.Name, err)
+	}
+	green.PrevMapName = shadow
+	green.standbyMap = shadow
+
+	if green.beginStart(nfCmdConfig.startDeadline) {
+		defer green.endStart()
+	}
+	if err := c.downloadAndStart(green, ifaceName, direction); err != nil {
+		if green.Cmd != nil {
+			stopSlot(green, blue, ifaceName, direction)
+		}
+		green.removeStandbyMap()
+		return fmt.Errorf("failed to start version %s of program %s alongside the running version: %w", bpfProg.Version, bpfProg.Name, err)
+	}
+	if err := validateGreen(green, ifaceName, direction, bpfProg.BlueGreen.ValidateSamples); err != nil {
+		stopSlot(green, blue, ifaceName, direction)
+		return fmt.Errorf("version %s of program %s failed the validation: %w", bpfProg.Version, bpfProg.Name, err)
+	}
+
+	e.Value = green
+	if err := linkBPF(e); err != nil {
+		e.Value = blue
+		if _, next := chainNeighbours(e); next != nil {
+			next.Value.(*BPF).PrevMapName = blue.Program.MapName
+		}
+		stopSlot(green, blue, ifaceName, direction)
+		return fmt.Errorf("failed to swap version %s of program %s into the chain: %w", bpfProg.Version, bpfProg.Name, err)
+	}
+	green.removeStandbyMap()
+	log.Info().Msgf("version %s of program %s is swapped into the chain in %s", bpfProg.Version, bpfProg.Name, time.Since(started))
+
+	// candidate in shadow mode is evaluated against the new version
+	green.shadow, blue.shadow = blue.shadow, nil
+	green.shadowNext, green.lastShadowRun = blue.shadowNext, blue.lastShadowRun
+	stopSlot(blue, green, ifaceName, direction)
+	return nil
+}
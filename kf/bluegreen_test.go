@@ -0,0 +1,153 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_validateBlueGreen(t *testing.T) {
+	blueMap := "/sys/fs/bpf/xdp_rl_ingress_next_prog"
+	tests := []struct {
+		name    string
+		prog    models.BPFProgram
+		wantErr bool
+	}{
+		{"none", models.BPFProgram{MapName: blueMap}, false},
+		{"green map", models.BPFProgram{MapName: blueMap, BlueGreen: &models.L3afDNFBlueGreen{MapName: "/sys/fs/bpf/green/xdp_rl_ingress_next_prog", ValidateSamples: 8}}, false},
+		{"no map", models.BPFProgram{MapName: blueMap, BlueGreen: &models.L3afDNFBlueGreen{}}, true},
+		{"blue map", models.BPFProgram{MapName: blueMap, BlueGreen: &models.L3afDNFBlueGreen{MapName: blueMap}}, true},
+		{"map not of the objects", models.BPFProgram{MapName: blueMap, BlueGreen: &models.L3afDNFBlueGreen{MapName: "/sys/fs/bpf/xdp_rl_green_next_prog"}}, true},
+		{"negative samples", models.BPFProgram{MapName: blueMap, BlueGreen: &models.L3afDNFBlueGreen{MapName: "/sys/fs/bpf/green/xdp_rl_ingress_next_prog", ValidateSamples: -1}}, true},
+		{"re-pinned consumed map", models.BPFProgram{
+			MapName:      blueMap,
+			BlueGreen:    &models.L3afDNFBlueGreen{MapName: "/sys/fs/bpf/green/xdp_rl_ingress_next_prog"},
+			ConsumedMaps: []models.L3afDNFConsumedMap{{Name: "blocklist", Program: "acl", PinPath: "/sys/fs/bpf/rl_blocklist"}},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateBlueGreen(&tt.prog); (err != nil) != tt.wantErr {
+				t.Errorf("validateBlueGreen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNFConfigs_ValidateBlueGreen(t *testing.T) {
+	progs := []models.L3afBPFPrograms{{
+		Iface: "eth0",
+		BpfPrograms: &models.BPFPrograms{
+			XDPIngress: []*models.BPFProgram{{
+				Name:      "ratelimiting",
+				MapName:   "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+				BlueGreen: &models.L3afDNFBlueGreen{MapName: "/sys/fs/bpf/green/xdp_rl_ingress_next_prog"},
+			}},
+		},
+	}}
+	c := &NFConfigs{hostConfig: &config.Config{BpfChainingEnabled: true}}
+	if err := c.ValidateBlueGreen(progs); err != nil {
+		t.Errorf("ValidateBlueGreen() error = %v, want the blue/green program chained", err)
+	}
+	c.hostConfig.BpfChainingEnabled = false
+	if err := c.ValidateBlueGreen(progs); err == nil {
+		t.Errorf("ValidateBlueGreen() error = nil, want the blue/green program rejected without chaining")
+	}
+}
+
+func TestBPF_configProgram(t *testing.T) {
+	prog := models.BPFProgram{
+		Name:      "ratelimiting",
+		MapName:   "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+		BlueGreen: &models.L3afDNFBlueGreen{MapName: "/sys/fs/bpf/green/xdp_rl_ingress_next_prog", ValidateSamples: 4},
+	}
+	blue := &BPF{Program: prog}
+	if blue.configProgram() != &blue.Program || blue.slotName() != "ratelimiting" {
+		t.Errorf("configProgram() slot name %s, want the program of the blue slot", blue.slotName())
+	}
+
+	green := &BPF{Program: prog, slot: slotGreen}
+	green.Program = *green.configProgram()
+	if green.Program.MapName != prog.BlueGreen.MapName || green.Program.BlueGreen.MapName != prog.MapName {
+		t.Errorf("green map names = %s and %s, want the maps of the slots swapped", green.Program.MapName, green.Program.BlueGreen.MapName)
+	}
+	if got := green.configProgram(); got.MapName != prog.MapName || got.BlueGreen.MapName != prog.BlueGreen.MapName {
+		t.Errorf("configProgram() map names = %s and %s, want the config map names", got.MapName, got.BlueGreen.MapName)
+	}
+	if green.slotName() != "ratelimiting-green" {
+		t.Errorf("slotName() = %s, want ratelimiting-green", green.slotName())
+	}
+}
+
+func Test_validateGreen(t *testing.T) {
+	savedTestRun := testRunProgram
+	t.Cleanup(func() { testRunProgram = savedTestRun })
+
+	tap := &packetTap{}
+	for i := 0; i < tapRecentSamples+2; i++ {
+		tap.keep([]byte{byte(i)})
+	}
+	packetTaps.mu.Lock()
+	packetTaps.taps["bg0"] = tap
+	packetTaps.mu.Unlock()
+	t.Cleanup(func() {
+		packetTaps.mu.Lock()
+		delete(packetTaps.taps, "bg0")
+		packetTaps.mu.Unlock()
+	})
+
+	if got := packetTaps.recentSamples("bg0", 2); len(got) != 2 || got[0][0] != tapRecentSamples+1 || got[1][0] != tapRecentSamples {
+		t.Errorf("recentSamples() = %v, want the latest samples first", got)
+	}
+	if got := packetTaps.recentSamples("bg0", tapRecentSamples+2); len(got) != tapRecentSamples {
+		t.Errorf("recentSamples() returned %d samples, want %d kept", len(got), tapRecentSamples)
+	}
+
+	var runs [][]byte
+	verdict := verdicts["XDP_PASS"]
+	var runErr error
+	testRunProgram = func(progID int, packet []byte) (uint32, error) {
+		runs = append(runs, packet)
+		return verdict, runErr
+	}
+
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, ProgID: 42}
+	if err := validateGreen(b, "bg0", models.XDPIngressType, 0); err != nil || len(runs) != 0 {
+		t.Errorf("validateGreen() error = %v runs %d, want no validation", err, len(runs))
+	}
+
+	// interface without a packet tap is validated by the self test packet
+	if err := validateGreen(b, "bg1", models.XDPIngressType, 2); err != nil {
+		t.Fatalf("validateGreen() error = %v", err)
+	}
+	if len(runs) != 2 || !bytes.Equal(runs[0], selfTestPacket()) {
+		t.Errorf("validateGreen() ran %d packets, want 2 self test packets", len(runs))
+	}
+
+	runs = nil
+	if err := validateGreen(b, "bg0", models.XDPIngressType, 3); err != nil {
+		t.Fatalf("validateGreen() error = %v", err)
+	}
+	if len(runs) != 3 || runs[0][0] != tapRecentSamples+1 {
+		t.Errorf("validateGreen() runs = %v, want the latest tap samples", runs)
+	}
+
+	verdict = verdicts["XDP_ABORTED"]
+	if err := validateGreen(b, "bg0", models.XDPIngressType, 1); err == nil {
+		t.Errorf("validateGreen() error = nil, want the aborted sample failing the validation")
+	}
+	if err := validateGreen(b, "bg0", models.IngressType, 1); err != nil {
+		t.Errorf("validateGreen() error = %v, want the TC verdict accepted", err)
+	}
+
+	verdict, runErr = verdicts["XDP_PASS"], errors.New("test run failed")
+	if err := validateGreen(b, "bg0", models.XDPIngressType, 1); err == nil {
+		t.Errorf("validateGreen() error = nil, want the failed test run failing the validation")
+	}
+}
@@ -5,25 +5,18 @@
 package kf
 
 import (
-	"archive/tar"
-	"archive/zip"
 	"bytes"
-	"compress/gzip"
 	"container/ring"
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 	"unsafe"
 
@@ -59,6 +52,48 @@ type BPF struct {
 	Ctx            context.Context
 	Done           chan bool `json:"-"`
 	DataCenter     string
+	BTFPath        string                   // BTF of the running kernel for CO-RE programs
+	MapMemory      uint64                   // Declared BPF map memory in bytes
+	NFStatus       *models.L3afDNFStatus    // Last status reported in JSON by the status command
+	BinaryHash     string                   // sha256 of the start command binary at start
+	VersionSkew    string                   // Drift of the running program from the configured artifact and version
+	StartFailure   string                   // Phase the last start timed out in
+	Degraded       bool                     // Crash looping program is bypassed in the chain
+	BuildInfo      *models.L3afDNFBuildInfo // Build manifest of the artifact, nil when not packaged
+	ChainHits      uint64                   // Packets processed by the chain position of the program, read from the root program counters
+	CoreDumps      []string                 // Collected cores of the user program, the latest first
+
+	startDeadline time.Time      // Overall deadline of the start in progress
+	startPhase    string         // Phase of the start in progress
+	phaseStarted  time.Time      // Time the phase of the start in progress was entered
+	keptArtifact  bool           // Cached artifact was kept while the program was disabled, used without the freshness check
+	control       *controlServer // Control socket of the program of the arg schema version 3
+	usageSample   usageSample    // CPU time of the user process at the last watchdog check
+	restarts      []time.Time    // Restarts by the process monitoring in the watchdog restart window
+	watchdogTrip  string         // Threshold exceeded at the last watchdog check, the action is taken once per trip
+	traceID       string         // Trace of the config apply or the restart starting the program
+	approvedExe   *gatekeeperKey // Binary of the program approved by the bpf gatekeeper
+
+	heartbeatMap    ebpfMap   // Heartbeat map of the running program, opened at the first heartbeat
+	lastHeartbeat   time.Time // Time of the last heartbeat written
+	heartbeatFailed bool      // Last heartbeat write failed, the failure is logged once
+
+	failedOpen bool // Program is bypassed by its fail-open policy until it is restarted
+
+	standbyMap string // Staging prog map the program in standby inserts its program into
+	xskMapPin  string // Xsk map created and pinned by l3afd for the AF_XDP program, removed when it is stopped
+	activated  bool   // Program in standby by its config is activated by the API
+	slot       string // Blue/green slot of the running version, green runs with the map name of the blue_green config
+
+	shadow        *BPF      // Candidate version of the program evaluated in shadow mode
+	shadowNext    int       // Program ID of the successor linked to the candidate in shadow mode
+	lastShadowRun time.Time // Time of the last evaluation or start attempt of the candidate in shadow mode
+
+	queueInstances map[int]*BPF // Per-queue instances of the program by queue, the program serves the first queue
+
+	tasks map[string]*nfTask // Scheduled tasks of the running program by name
+
+	xdpMode string // XDP mode the program is started in, resolved from the xdp mode of the config and the offload support of the interface
 }
 
 func NewBpfProgram(ctx context.Context, program models.BPFProgram, logDir, dataCenter string) *BPF {
@@ -81,60 +116,21 @@ func NewBpfProgram(ctx context.Context, program models.BPFProgram, logDir, dataC
 func LoadRootProgram(ifaceName string, direction string, progType string, conf *config.Config) (*BPF, error) {
 
 	log.Info().Msgf("LoadRootProgram iface %s direction %s progType %s", ifaceName, direction, progType)
-	var rootProgBPF *BPF
-
-	switch progType {
-	case models.XDPType:
-		rootProgBPF = &BPF{
-			Program: models.BPFProgram{
-				Name:              conf.XDPRootProgramName,
-				Artifact:          conf.XDPRootProgramArtifact,
-				MapName:           conf.XDPRootProgramMapName,
-				Version:           conf.XDPRootProgramVersion,
-				UserProgramDaemon: conf.XDPRootProgramUserProgramDaemon,
-				CmdStart:          conf.XDPRootProgramCommand,
-				CmdStop:           conf.XDPRootProgramCommand,
-				CmdStatus:         "",
-				AdminStatus:       models.Enabled,
-				SeqID:             0,
-				StartArgs:         map[string]interface{}{},
-				StopArgs:          map[string]interface{}{},
-				StatusArgs:        map[string]interface{}{},
-			},
-			RestartCount: 0,
-			Cmd:          nil,
-			FilePath:     "",
-			LogDir:       "",
-			PrevMapName:  "",
-		}
-	case models.TCType:
-		rootProgBPF = &BPF{
-			Program: models.BPFProgram{
-				Name:              conf.TCRootProgramName,
-				Artifact:          conf.TCRootProgramArtifact,
-				Version:           conf.TCRootProgramVersion,
-				UserProgramDaemon: conf.TCRootProgramUserProgramDaemon,
-				CmdStart:          conf.TCRootProgramCommand,
-				CmdStop:           conf.TCRootProgramCommand,
-				CmdStatus:         "",
-				AdminStatus:       models.Enabled,
-				StartArgs:         map[string]interface{}{},
-				StopArgs:          map[string]interface{}{},
-				StatusArgs:        map[string]interface{}{},
-			},
-			RestartCount: 0,
-			Cmd:          nil,
-			FilePath:     "",
-			LogDir:       "",
-			PrevMapName:  "",
-		}
-		if direction == models.IngressType {
-			rootProgBPF.Program.MapName = conf.TCRootProgramIngressMapName
-		} else if direction == models.EgressType {
-			rootProgBPF.Program.MapName = conf.TCRootProgramEgressMapName
-		}
-	default:
-		return nil, fmt.Errorf("unknown direction %s for root program in iface %s", direction, ifaceName)
+	// flavor of the interface selects the artifact, version, command and map of the root program
+	prog, err := rootProgram(conf, ifaceName, direction, progType)
+	if err != nil {
+		return nil, err
+	}
+	if flavor, _ := rootFlavor(conf, ifaceName); len(flavor) > 0 {
+		log.Info().Msgf("root program %s of iface %s is of flavor %s artifact %s version %s", prog.Name, ifaceName, flavor, prog.Artifact, prog.Version)
+	}
+	rootProgBPF := &BPF{
+		Program:      prog,
+		RestartCount: 0,
+		Cmd:          nil,
+		FilePath:     "",
+		LogDir:       "",
+		PrevMapName:  "",
 	}
 
 	// Loading default arguments
@@ -162,17 +158,18 @@ func LoadRootProgram(ifaceName string, direction string, progType string, conf *
 	return rootProgBPF, nil
 }
 
-// Stop the NF process if running outside l3afd
-func StopExternalRunningProcess(processName string) error {
-	// validate process name
-	if len(processName) < 1 {
+// Stop the NF process if running outside l3afd. Processes are matched on the executable and the command line,
+// so only the instances of the start command of the artifact are stopped.
+func StopExternalRunningProcess(cmdPath, mode string) error {
+	if len(cmdPath) < 1 {
 		return fmt.Errorf("process name can not be empty")
 	}
-
-	// process names are truncated to 15 chars
-	psName := processName
-	if len(processName) > 15 {
-		psName = processName[:15]
+	switch mode {
+	case "", models.ExternalStopKill, models.ExternalStopDryRun:
+	case models.ExternalStopDisabled:
+		return nil
+	default:
+		return fmt.Errorf("unknown external stop mode %s", mode)
 	}
 
 	myPid := os.Getpid()
@@ -180,24 +177,48 @@ func StopExternalRunningProcess(processName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to fetch processes list")
 	}
-	log.Info().Msgf("Searching for process %s and not ppid %d", processName, myPid)
+	log.Info().Msgf("Searching for process %s and not ppid %d", cmdPath, myPid)
 	for _, process := range processList {
-		if strings.Contains(process.Executable(), psName) {
-			if process.PPid() != myPid {
-				log.Warn().Msgf("found process id %d name %s ppid %d, stopping it", process.Pid(), process.Executable(), process.PPid())
-				osProcess, err := os.FindProcess(process.Pid())
-				if err == nil {
-					err = osProcess.Kill()
-				}
-				if err != nil {
-					return fmt.Errorf("external BPFProgram stop failed with error: %w", err)
-				}
-			}
+		if process.Pid() == myPid || process.PPid() == myPid {
+			continue
+		}
+		exe, cmdline, err := processCmdline(process.Pid())
+		if err != nil || !matchExternalProcess(cmdPath, process.Executable(), exe, cmdline) {
+			continue
+		}
+		if mode == models.ExternalStopDryRun {
+			log.Warn().Msgf("found process id %d exe %s ppid %d running %s, dry-run not stopping it", process.Pid(), exe, process.PPid(), cmdPath)
+			continue
+		}
+		log.Warn().Msgf("found process id %d exe %s ppid %d running %s, stopping it", process.Pid(), exe, process.PPid(), cmdPath)
+		osProcess, err := os.FindProcess(process.Pid())
+		if err == nil {
+			err = osProcess.Kill()
+		}
+		if err != nil {
+			return fmt.Errorf("external BPFProgram stop failed with error: %w", err)
 		}
 	}
 	return nil
 }
 
+// matchExternalProcess - process runs the command i.e. the executable is the command, or the command is the first
+// argument. A script run by its interpreter is the second argument and the process is named after the script,
+// process names are truncated to 15 chars.
+func matchExternalProcess(cmdPath, name, exe string, cmdline []string) bool {
+	if strings.TrimSuffix(exe, " (deleted)") == cmdPath {
+		return true
+	}
+	if len(cmdline) > 0 && cmdline[0] == cmdPath {
+		return true
+	}
+	script := filepath.Base(cmdPath)
+	if len(script) > 15 {
+		script = script[:15]
+	}
+	return len(cmdline) > 1 && cmdline[1] == cmdPath && name == script
+}
+
 // Stop returns the last error seen, but stops bpf program.
 // Clean up all map handles.
 // Verify next program pinned map file is removed
@@ -207,19 +228,34 @@ func (b *BPF) Stop(ifaceName, direction string, chain bool) error {
 	}
 
 	log.Info().Msgf("Stopping BPF Program - %s", b.Program.Name)
+	b.stopShadow(ifaceName, direction)
+	b.stopQueueInstances(ifaceName, direction)
+	b.stopTasks()
+	defer b.closeControl()
+	defer b.revokeExecutable()
+	defer b.closeHeartbeat()
+	defer b.removeStandbyMap()
+	defer b.removeCreatedXSKMap()
 
 	// Removing maps
 	for key, val := range b.BpfMaps {
-		log.Debug().Msgf("removing BPF maps %s value map %#v", key, val)
+		debugProgram(b.Program.Name).Msgf("removing BPF maps %s value map %#v", key, val)
 		delete(b.BpfMaps, key)
 	}
 
 	// Removing Metrics maps
 	for key, val := range b.MetricsBpfMaps {
-		log.Debug().Msgf("removing metric bpf maps %s value %#v", key, val)
+		debugProgram(b.Program.Name).Msgf("removing metric bpf maps %s value %#v", key, val)
 		delete(b.MetricsBpfMaps, key)
 	}
 
+	// Removing shared map references
+	sharedMaps.release(ifaceName, direction, b.slotName())
+	sharedMaps.unregister(ifaceName, b.Program.Name, b.Program.SharedMaps)
+	memlockUsage.release(ifaceName, direction, b.slotName())
+	// pins vanish after the process is stopped
+	defer b.releasePinPaths(ifaceName, direction)
+
 	// Stop KFcnfigs
 	if len(b.Program.CmdConfig) > 0 && len(b.Program.ConfigFilePath) > 0 {
 		log.Info().Msgf("Stopping KF configs %s ", b.Program.Name)
@@ -233,17 +269,23 @@ func (b *BPF) Stop(ifaceName, direction string, chain bool) error {
 
 	// Setting NFRunning to 0, indicates not running
 	stats.Set(0.0, stats.NFRunning, b.Program.Name, direction)
+	b.Degraded = false
+	b.failedOpen = false
+	stats.Set(0.0, stats.NFDegraded, b.Program.Name, direction)
 
+	terminateStarted := time.Now()
 	if len(b.Program.CmdStop) < 1 {
 		if err := b.ProcessTerminate(); err != nil {
 			return fmt.Errorf("BPFProgram %s process terminate failed with error: %w", b.Program.Name, err)
 		}
-		if b.Cmd != nil {
-			if err := b.Cmd.Wait(); err != nil {
-				log.Error().Err(err).Msgf("cmd wait at stopping bpf program %s errored", b.Program.Name)
+		if b.Cmd != nil && b.Cmd.ProcessState == nil {
+			if b.waitTerminated(direction, b.stopGracePeriod()) {
+				b.removeKilledPins(chain)
 			}
 			b.Cmd = nil
 		}
+		stats.Observe(time.Since(terminateStarted), stats.NFPhaseDuration, b.Program.Name, "stop", "terminate")
+		defer b.observeStopPhase("verify", time.Now())
 
 		// verify pinned map file is removed.
 		if err := b.VerifyPinnedMapVanish(chain); err != nil {
@@ -266,9 +308,21 @@ func (b *BPF) Stop(ifaceName, direction string, chain bool) error {
 		return fmt.Errorf("no executable permissions on %s - error %w", b.Program.CmdStop, err)
 	}
 
+	implicit := b.implicitArgs("stop", ifaceName, direction)
+	if implicit.SchemaVersion == ArgSchemaV3 {
+		// stop command reads the stop command of the implicit args from the control socket
+		implicit.ControlSocket = controlSocketPath(ifaceName, direction, b.slotName())
+		if b.control == nil {
+			control, err := startControlServer(implicit, b.Program, nil)
+			if err != nil {
+				return fmt.Errorf("failed to stop the program %s: %w", b.Program.Name, err)
+			}
+			b.control = control
+		}
+		b.control.setCommand("stop")
+	}
 	args := make([]string, 0, len(b.Program.StopArgs)<<1)
-	args = append(args, "--iface="+ifaceName)     // detaching from iface
-	args = append(args, "--direction="+direction) // xdpingress or ingress or egress
+	args = append(args, implicitArgFlags(implicit)...)
 
 	for k, val := range b.Program.StopArgs {
 		if v, ok := val.(string); !ok {
@@ -281,11 +335,19 @@ func (b *BPF) Stop(ifaceName, direction string, chain bool) error {
 	}
 
 	log.Info().Msgf("bpf program stop command : %s %v", cmd, args)
-	prog := execCommand(cmd, args...)
-	if err := prog.Run(); err != nil {
-		log.Warn().Err(err).Msgf("l3afd/nf : Failed to stop the program %s", b.Program.CmdStop)
+	prog, err := newNFCommand(cmd, args...)
+	if err != nil {
+		return fmt.Errorf("failed to stop the program %s: %w", b.Program.Name, err)
+	}
+	if err := passImplicitArgs(prog, implicit); err != nil {
+		return fmt.Errorf("failed to stop the program %s: %w", b.Program.Name, err)
+	}
+	if out, err := runNFCommand(prog, nfCmdConfig.stopTimeout); err != nil {
+		log.Warn().Err(err).Msgf("l3afd/nf : Failed to stop the program %s output %s", b.Program.CmdStop, out)
 	}
 	b.Cmd = nil
+	stats.Observe(time.Since(terminateStarted), stats.NFPhaseDuration, b.Program.Name, "stop", "terminate")
+	defer b.observeStopPhase("verify", time.Now())
 
 	// verify pinned map file is removed.
 	if err := b.VerifyPinnedMapVanish(chain); err != nil {
@@ -302,17 +364,32 @@ func (b *BPF) Stop(ifaceName, direction string, chain bool) error {
 	return nil
 }
 
+// observeStopPhase - observes the time taken by the phase of the stop started at the time
+func (b *BPF) observeStopPhase(phase string, started time.Time) {
+	stats.Observe(time.Since(started), stats.NFPhaseDuration, b.Program.Name, "stop", phase)
+}
+
 // Start returns the last error seen, but starts bpf program.
 // Here initially prevprogmap entry is removed and passed to the bpf program
 // After starting the user program, will update the kernel progam fd into prevprogram map.
 // This method waits till prog fd entry is updated, else returns error assuming kernel program is not loaded.
 // It also verifies the next program pinned map is created or not.
-func (b *BPF) Start(ifaceName, direction string, chain bool) error {
+func (b *BPF) Start(ifaceName, direction string, chain bool) (err error) {
 	if b.FilePath == "" {
 		return errors.New("no program binary path found")
 	}
 
-	if err := StopExternalRunningProcess(b.Program.CmdStart); err != nil {
+	if b.Program.RequiresCORE && len(b.BTFPath) == 0 {
+		return fmt.Errorf("program %s requires CO-RE and kernel BTF is not available", b.Program.Name)
+	}
+
+	if b.beginStart(nfCmdConfig.startDeadline) {
+		defer b.endStart()
+	}
+	defer b.finishStartPhase()
+	b.enterStartPhase(StartPhaseLoad)
+
+	if err := StopExternalRunningProcess(filepath.Join(b.FilePath, b.Program.CmdStart), b.Program.ExternalStop); err != nil {
 		return fmt.Errorf("failed to stop external instance of the program %s with error : %w", b.Program.CmdStart, err)
 	}
 
@@ -322,6 +399,42 @@ func (b *BPF) Start(ifaceName, direction string, chain bool) error {
 		return fmt.Errorf("no executable permissions on %s - error %w", b.Program.CmdStart, err)
 	}
 
+	// Loader inherits the memlock limit of l3afd
+	if err := b.reserveMemlock(ifaceName, direction); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			memlockUsage.release(ifaceName, direction, b.slotName())
+		}
+	}()
+
+	// Binary of the extracted artifact, running binary is compared against it by the version skew check
+	hash, err := binaryHash(cmd)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to hash the binary of the program %s", b.Program.Name)
+	}
+	b.BinaryHash = hash
+	b.VersionSkew = ""
+	b.closeHeartbeat()
+
+	// Binary of the program loads its programs once the bpf gatekeeper approves it
+	if err := b.approveExecutable(cmd); err != nil {
+		return fmt.Errorf("failed to approve the program %s by the bpf gatekeeper: %w", b.Program.Name, err)
+	}
+	defer func() {
+		if err != nil {
+			b.revokeExecutable()
+		}
+	}()
+
+	// Program in standby inserts its program into its staging map, it is linked into the chain on activation
+	if chain && b.standby() {
+		if err := b.stageStandby(ifaceName, direction); err != nil {
+			return fmt.Errorf("failed to stage the program %s in standby: %w", b.Program.Name, err)
+		}
+	}
+
 	// Making sure old map entry is removed before passing the prog fd map to the program.
 	if len(b.PrevMapName) > 0 {
 		if err := b.RemovePrevProgFD(); err != nil {
@@ -329,25 +442,60 @@ func (b *BPF) Start(ifaceName, direction string, chain bool) error {
 		}
 	}
 
-	args := make([]string, 0, len(b.Program.StartArgs)<<1)
-	args = append(args, "--iface="+ifaceName)     // attaching to interface
-	args = append(args, "--direction="+direction) // direction xdpingress or ingress or egress
-
+	implicit := b.implicitArgs("start", ifaceName, direction)
 	if chain {
 		if len(b.PrevMapName) > 1 {
-			args = append(args, "--map-name="+b.PrevMapName)
+			implicit.MapName = b.PrevMapName
 		}
 	}
 
 	if len(b.LogDir) > 1 {
-		args = append(args, "--log-dir="+b.LogDir)
+		implicit.LogDir = b.LogDir
+	}
+
+	if implicit.SchemaVersion == ArgSchemaV3 {
+		implicit.ControlSocket = controlSocketPath(ifaceName, direction, b.slotName())
+	}
+
+	// Program of the offload XDP mode falls back on interfaces not supporting offload
+	if err := b.xdpImplicitArgs(&implicit, ifaceName, direction); err != nil {
+		return fmt.Errorf("failed to resolve the xdp mode of the program %s: %w", b.Program.Name, err)
+	}
+	if err := b.queueImplicitArgs(&implicit, ifaceName); err != nil {
+		return fmt.Errorf("failed to set the queues of the program %s: %w", b.Program.Name, err)
+	}
+
+	// Loader uses kernel BTF by default, downloaded BTF is passed explicitly
+	if b.Program.RequiresCORE && b.BTFPath != kernelBTFPath {
+		implicit.BTFPath = b.BTFPath
+	}
+
+	// malformed rules fail the start instead of crashing the NF
+	if len(b.Program.Rules) > 0 {
+		if err := b.validateRules(b.Program.Rules); err != nil {
+			return err
+		}
 	}
 
 	if len(b.Program.RulesFile) > 1 && len(b.Program.Rules) > 1 {
 		fileName, err := b.createUpdateRulesFile(direction)
 		if err == nil {
-			args = append(args, "--rules-file="+fileName)
+			implicit.RulesFile = fileName
+		} else {
+			log.Error().Err(err).Msgf("failed to create rules file of program %s", b.Program.Name)
+		}
+	}
+
+	args := make([]string, 0, len(b.Program.StartArgs)<<1)
+	args = append(args, implicitArgFlags(implicit)...)
+
+	// Addresses are discovered at every start, restarted program gets the current addresses
+	if b.Program.IfaceAddrs {
+		addrArgs, err := ifaceAddrArgs(ifaceName, direction)
+		if err != nil {
+			return fmt.Errorf("failed to discover addresses for the program %s: %w", b.Program.Name, err)
 		}
+		args = append(args, addrArgs...)
 	}
 
 	for k, val := range b.Program.StartArgs {
@@ -355,30 +503,148 @@ func (b *BPF) Start(ifaceName, direction string, chain bool) error {
 			err := fmt.Errorf("start args is not a string for the ebpf program %s", b.Program.Name)
 			log.Error().Err(err).Msgf("failed to convert start args value into string for program %s", b.Program.Name)
 			return err
-		} else {
+		} else if implicit.SchemaVersion != ArgSchemaV3 {
+			// programs of the control socket read the start args from the config
 			args = append(args, "--"+k+"="+v)
 		}
 	}
 
+	// Maps passed as fds over the control socket
+	var controlMaps []controlMap
+	if len(implicit.MapName) > 0 {
+		controlMaps = append(controlMaps, controlMap{name: controlMapName, pinPath: implicit.MapName})
+	}
+
+	// Shared maps of other programs, the references taken are dropped when the start fails
+	defer func() {
+		if err != nil {
+			sharedMaps.release(ifaceName, direction, b.slotName())
+		}
+	}()
+	for _, cm := range b.Program.ConsumedMaps {
+		pinPath, err := sharedMaps.acquire(ifaceName, direction, b.slotName(), cm)
+		if err != nil {
+			return fmt.Errorf("failed to access consumed map of the program %s: %w", b.Program.Name, err)
+		}
+		switch {
+		case implicit.SchemaVersion == ArgSchemaV3:
+			name := cm.Arg
+			if len(name) == 0 {
+				name = cm.Name
+			}
+			controlMaps = append(controlMaps, controlMap{name: name, pinPath: pinPath})
+		case len(cm.Arg) > 0:
+			args = append(args, "--"+cm.Arg+"="+pinPath)
+		}
+	}
+	if implicit.SchemaVersion == ArgSchemaV3 {
+		for _, p := range b.Program.PassFDs {
+			controlMaps = append(controlMaps, controlMap{name: p.Name, kind: p.Type, pinPath: p.PinPath})
+		}
+	}
+
+	// AF_XDP xsk map is inherited by the user program
+	var xskFile *os.File
+	if b.Program.AFXDP != nil {
+		xskArgs, f, created, err := prepareAFXDP(b.afxdpConfig())
+		if err != nil {
+			return fmt.Errorf("failed to setup AF_XDP of the program %s: %w", b.Program.Name, err)
+		}
+		defer f.Close()
+		if created {
+			b.xskMapPin = b.afxdpConfig().XSKMapName
+		}
+		args = append(args, xskArgs...)
+		xskFile = f
+	}
+	// xsk map created for the program is removed when the start fails
+	defer func() {
+		if err != nil {
+			b.removeCreatedXSKMap()
+		}
+	}()
+
+	// Pinned maps and programs are inherited after the xsk map, the user program needs no CAP_BPF to open them
+	var passedFiles []*os.File
+	if len(b.Program.PassFDs) > 0 && implicit.SchemaVersion != ArgSchemaV3 {
+		firstFD := xskMapFD
+		if xskFile != nil {
+			firstFD++
+		}
+		fdArgs, files, err := openPassedFDs(b.Program.PassFDs, firstFD)
+		if err != nil {
+			return fmt.Errorf("failed to pass fds to the program %s: %w", b.Program.Name, err)
+		}
+		defer closeFiles(files)
+		args = append(args, fdArgs...)
+		passedFiles = files
+	}
+
+	if implicit.SchemaVersion == ArgSchemaV3 {
+		b.closeControl()
+		control, err := startControlServer(implicit, b.Program, controlMaps)
+		if err != nil {
+			return fmt.Errorf("failed to open control socket of the program %s: %w", b.Program.Name, err)
+		}
+		b.control = control
+		defer func() {
+			if err != nil {
+				b.closeControl()
+			}
+		}()
+	}
+
 	log.Info().Msgf("BPF Program start command : %s %v", cmd, args)
-	b.Cmd = execCommand(cmd, args...)
-	if err := b.Cmd.Start(); err != nil {
-		log.Info().Err(err).Msgf("user mode BPF program failed - %s", b.Program.Name)
-		return fmt.Errorf("failed to start : %s %v", cmd, args)
+	nfCmd, err := newNFCommand(cmd, args...)
+	if err != nil {
+		return fmt.Errorf("failed to start the program %s: %w", b.Program.Name, err)
+	}
+	if err := passImplicitArgs(nfCmd, implicit); err != nil {
+		return fmt.Errorf("failed to start the program %s: %w", b.Program.Name, err)
+	}
+	if err := b.sandbox(nfCmd, implicit); err != nil {
+		return fmt.Errorf("failed to sandbox the program %s: %w", b.Program.Name, err)
+	}
+	b.Cmd = nfCmd
+	if xskFile != nil {
+		b.Cmd.ExtraFiles = []*os.File{xskFile}
 	}
+	b.Cmd.ExtraFiles = append(b.Cmd.ExtraFiles, passedFiles...)
 	if !b.Program.UserProgramDaemon {
 		log.Info().Msgf("no user mode BPF program - %s No Pid", b.Program.Name)
-		if err := b.Cmd.Wait(); err != nil {
-			return fmt.Errorf("cmd wait at starting of bpf program returned with error %w", err)
+		if out, err := runNFCommand(b.Cmd, b.startTimeLeft(nfCmdConfig.startTimeout)); err != nil {
+			b.Cmd = nil
+			return fmt.Errorf("start command of bpf program returned with error %w output %s", err, out)
 		}
 		b.Cmd = nil
 
 		if err := b.VerifyPinnedMapExists(chain); err != nil {
 			return fmt.Errorf("no userprogram and failed to find pinned file %s, %w", b.Program.MapName, err)
 		}
+		sharedMaps.register(ifaceName, b.Program.Name, b.Program.SharedMaps)
+		b.claimPinPaths(ifaceName, direction)
 		return nil
 	}
 
+	// output of the user program is kept for the incident bundle of a crash
+	if console := openConsole(ifaceName, direction, b.Program.Name); console != nil {
+		defer console.Close()
+		b.Cmd.Stdout = console
+		b.Cmd.Stderr = console
+	}
+
+	if err := b.Cmd.Start(); err != nil {
+		log.Info().Err(err).Msgf("user mode BPF program failed - %s", b.Program.Name)
+		return fmt.Errorf("failed to start : %s %v", cmd, args)
+	}
+	assignNFJob(b.Cmd)
+	// started process is killed before the reservations of the failed start are released
+	defer func() {
+		if err != nil {
+			b.killStartedProcess()
+		}
+	}()
+
 	isRunning, err := b.isRunning()
 	if !isRunning {
 		log.Error().Err(err).Msg("eBPF program failed to start")
@@ -386,23 +652,29 @@ func (b *BPF) Start(ifaceName, direction string, chain bool) error {
 	}
 
 	// making sure program fd map pinned file is created
+	b.enterStartPhase(StartPhasePin)
 	if err := b.VerifyPinnedMapExists(chain); err != nil {
 		return fmt.Errorf("failed to find pinned file %s  %w", b.Program.MapName, err)
 	}
 
+	b.enterStartPhase(StartPhaseMapArgs)
 	if len(b.Program.MapArgs) > 0 {
 		if err := b.Update(ifaceName, direction); err != nil {
 			log.Error().Err(err).Msg("failed to update network functions BPF maps")
 			return fmt.Errorf("failed to update network functions BPF maps %w", err)
 		}
 	}
+	if err := b.loadRulesMap(); err != nil {
+		return err
+	}
 
 	// Fetch when prev program map is updated
 	if len(b.PrevMapName) > 0 {
 		// retry 10 times to verify entry is created
+		b.enterStartPhase(StartPhaseProgID)
 		for i := 0; i < 10; i++ {
 			b.ProgID, err = b.GetProgID()
-			if err == nil {
+			if err == nil || b.startExpired() {
 				break
 			}
 
@@ -426,13 +698,35 @@ func (b *BPF) Start(ifaceName, direction string, chain bool) error {
 	if err := b.SetPrLimits(); err != nil {
 		log.Warn().Err(err).Msg("failed to set resource limits")
 	}
-	stats.Incr(stats.NFStartCount, b.Program.Name, direction)
+	if err := b.SetScheduling(ifaceName); err != nil {
+		log.Warn().Err(err).Msg("failed to set scheduling")
+	}
+	b.prepareCoreDumps(ifaceName, direction)
+	stats.IncrWithExemplar(stats.NFStartCount, b.traceID, b.Program.Name, direction)
 	stats.Set(float64(time.Now().Unix()), stats.NFStartTime, b.Program.Name, direction)
+	sharedMaps.register(ifaceName, b.Program.Name, b.Program.SharedMaps)
+	b.claimPinPaths(ifaceName, direction)
 
-	log.Info().Msgf("BPF program - %s started Process id %d Program ID %d", b.Program.Name, b.Cmd.Process.Pid, b.ProgID)
+	log.Info().Msgf("BPF program - %s started Process id %d Program ID %d trace %s", b.Program.Name, b.Cmd.Process.Pid, b.ProgID, b.traceID)
 	return nil
 }
 
+// killStartedProcess - kills and reaps the user program of a failed start
+func (b *BPF) killStartedProcess() {
+	if b.Cmd == nil || b.Cmd.Process == nil {
+		return
+	}
+	if err := signalNFProcess(b.Cmd, syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		log.Warn().Err(err).Msgf("failed to kill the program %s", b.Program.Name)
+	}
+	if b.Cmd.ProcessState == nil {
+		if err := b.Cmd.Wait(); err != nil {
+			log.Debug().Err(err).Msgf("program %s of the failed start exited", b.Program.Name)
+		}
+	}
+	b.Cmd = nil
+}
+
 // Updates the config map_args
 func (b *BPF) Update(ifaceName, direction string) error {
 	for k, val := range b.Program.MapArgs {
@@ -480,20 +774,29 @@ func (b *BPF) isRunning() (bool, error) {
 			}
 		}
 
-		prog := execCommand(cmd, args...)
-		var out bytes.Buffer
-		prog.Stdout = &out
-		prog.Stderr = &out
-		if err := prog.Run(); err != nil {
+		prog, err := newNFCommand(cmd, args...)
+		if err != nil {
+			return false, err
+		}
+		out, err := runNFCommand(prog, nfCmdConfig.statusTimeout)
+		if err != nil {
 			log.Warn().Err(err).Msgf("l3afd/nf : Failed to execute %s", b.Program.CmdStatus)
 		}
 
-		outStr, errStr := out.String(), out.String()
-		if strings.EqualFold(outStr, bpfStatus) {
+		if status, running, ok := parseNFStatus(out); ok {
+			b.NFStatus = status
+			if running {
+				return true, nil
+			}
+			return false, fmt.Errorf("l3afd/nf : BPF Program not running state %s %s", status.State, status.Message)
+		}
+
+		// legacy status commands print RUNNING
+		if strings.EqualFold(out, bpfStatus) {
 			return true, nil
 		}
 
-		return false, fmt.Errorf("l3afd/nf : BPF Program not running %s", errStr)
+		return false, fmt.Errorf("l3afd/nf : BPF Program not running %s", out)
 	}
 
 	// No running user program and command status is not provided then return true
@@ -508,152 +811,109 @@ func (b *BPF) isRunning() (bool, error) {
 	return IsProcessRunning(b.Cmd.Process.Pid, b.Program.Name)
 }
 
-// Check binary already exists
+// Check binary already exists, the artifacts not permitted by the artifact allowlist are not used even when cached
 func (b *BPF) VerifyAndGetArtifacts(conf *config.Config) error {
+	if err := artifactAllowed(&b.Program); err != nil {
+		return err
+	}
 
 	fPath := filepath.Join(conf.BPFDir, b.Program.Name, b.Program.Version, strings.Split(b.Program.Artifact, ".")[0])
-	if _, err := os.Stat(fPath); os.IsNotExist(err) {
-		return b.GetArtifacts(conf)
+	if _, err := appFS.Stat(fPath); os.IsNotExist(err) {
+		if err := b.GetArtifacts(conf); err != nil {
+			return err
+		}
+	} else {
+		b.FilePath = fPath
+		// corrupted cached artifact is downloaded again once
+		if err := b.verifyCachedArtifact(); err != nil {
+			log.Warn().Err(err).Msgf("cached artifact %s of program %s version %s is corrupted, downloading it again",
+				b.Program.Artifact, b.Program.Name, b.Program.Version)
+			stats.Incr(stats.NFArtifactCacheInvalidations, b.Program.Name, b.Program.Version)
+			if err := invalidateCachedArtifact(fPath); err != nil {
+				return err
+			}
+			if err := b.GetArtifacts(conf); err != nil {
+				return err
+			}
+		} else if conf.KFRepoFreshnessCheck && !b.keptArtifact && b.cachedArtifactModified(conf, fPath) {
+			log.Info().Msgf("artifact %s of program %s version %s is modified in the KF repo, downloading it again",
+				b.Program.Artifact, b.Program.Name, b.Program.Version)
+			if err := invalidateCachedArtifact(fPath); err != nil {
+				return err
+			}
+			if err := b.GetArtifacts(conf); err != nil {
+				return err
+			}
+		}
 	}
 
-	b.FilePath = fPath
+	b.loadBuildInfo()
 	return nil
 }
 
-// GetArtifacts downloads artifacts from the nexus repo
+// GetArtifacts downloads artifacts from the artifact store of the KF repo url or its mirrors
 func (b *BPF) GetArtifacts(conf *config.Config) error {
-	var fPath = ""
-
-	kfRepoURL, err := url.Parse(conf.KFRepoURL)
-	if err != nil {
-		return fmt.Errorf("unknown KF repo url format: %w", err)
+	if err := injectedFaults.downloadFault(b.Program.Name); err != nil {
+		return fmt.Errorf("download failed: %w", err)
 	}
 
-	platform, err := GetPlatform()
-	if err != nil {
-		return fmt.Errorf("failed to find KF repo download path: %w", err)
+	var data []byte
+	var tag string
+	var err error
+	if conf.ArtifactPeersEnabled && len(conf.ArtifactPeers) > 0 {
+		started := time.Now()
+		if data, tag, err = fetchArtifactFromPeers(conf, &b.Program); err != nil {
+			log.Info().Err(err).Msgf("downloading artifact %s from the KF repo", b.Program.Artifact)
+			data = nil
+		} else {
+			log.Info().Msgf("artifact %s of program %s version %s downloaded from a peer", b.Program.Artifact, b.Program.Name, b.Program.Version)
+			stats.Incr(stats.NFArtifactPeerDownloads, b.Program.Name, b.Program.Version)
+			observeDownload(b.Program.Name, "peer", started, len(data))
+		}
 	}
-
-	kfRepoURL.Path = path.Join(kfRepoURL.Path, b.Program.Name, b.Program.Version, platform, b.Program.Artifact)
-	log.Info().Msgf("Downloading - %s", kfRepoURL)
-
-	timeOut := time.Duration(conf.HttpClientTimeout) * time.Second
-	var netTransport = &http.Transport{
-		ResponseHeaderTimeout: timeOut,
+	if data == nil {
+		started := time.Now()
+		if data, tag, err = fetchFromRepos(conf, &b.Program); err != nil {
+			return fmt.Errorf("download failed: %w", err)
+		}
+		observeDownload(b.Program.Name, "repo", started, len(data))
+	}
+	if err := verifyArtifactDigest(&b.Program, data); err != nil {
+		return err
 	}
-	client := http.Client{Transport: netTransport, Timeout: timeOut}
 
-	// Get the data
-	resp, err := client.Get(kfRepoURL.String())
+	// artifact is extracted into the staging dir and swapped into the version dir once complete
+	versionDir := filepath.Join(conf.BPFDir, b.Program.Name, b.Program.Version)
+	artifactMu.Lock()
+	defer artifactMu.Unlock()
+	tempDir, err := newStagingDir(versionDir)
 	if err != nil {
-		return fmt.Errorf("download failed: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	buf := &bytes.Buffer{}
-	buf.ReadFrom(resp.Body)
+	defer removeDirTree(tempDir)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("get request returned unexpected status code: %d (%s), %d was expected\n\tResponse Body: %s", resp.StatusCode, http.StatusText(resp.StatusCode), http.StatusOK, buf.Bytes())
+	if err := extractArtifact(data, b.Program.Artifact, tempDir); err != nil {
+		return err
 	}
-
-	if strings.HasSuffix(b.Program.Artifact, ".zip") {
-		c := bytes.NewReader(buf.Bytes())
-		zipReader, err := zip.NewReader(c, int64(c.Len()))
-		if err != nil {
-			return fmt.Errorf("failed to create zip reader: %w", err)
+	if conf.ArtifactPeersEnabled && validArtifactPathElem(b.Program.Artifact) {
+		// archive is served to the peers once the artifact is verified and installed
+		if err := appFS.WriteFile(filepath.Join(tempDir, b.Program.Artifact), data, 0644); err != nil {
+			return fmt.Errorf("failed to keep the artifact archive for the peers: %w", err)
 		}
-		tempDir := filepath.Join(conf.BPFDir, b.Program.Name, b.Program.Version)
-
-		for _, file := range zipReader.File {
-
-			zippedFile, err := file.Open()
-			if err != nil {
-				return fmt.Errorf("unzip failed: %w", err)
-			}
-			defer zippedFile.Close()
-
-			extractedFilePath := filepath.Join(
-				tempDir,
-				file.Name,
-			)
-			if !strings.HasPrefix(extractedFilePath, filepath.Clean(tempDir)+string(os.PathSeparator)) {
-				return fmt.Errorf("invalid file path: %s", extractedFilePath)
-			}
-			if file.FileInfo().IsDir() {
-				os.MkdirAll(extractedFilePath, file.Mode())
-			} else {
-				outputFile, err := os.OpenFile(
-					extractedFilePath,
-					os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
-					file.Mode(),
-				)
-				if err != nil {
-					return fmt.Errorf("unzip failed to create file: %w", err)
-				}
-				defer outputFile.Close()
-
-				buf := copyBufPool.Get().(*bytes.Buffer)
-				_, err = io.CopyBuffer(outputFile, zippedFile, buf.Bytes())
-				if err != nil {
-					return fmt.Errorf("GetArtifacts failed to copy files: %w", err)
-				}
-				copyBufPool.Put(buf)
-			}
-		}
-		newDir := strings.Split(b.Program.Artifact, ".")
-		b.FilePath = filepath.Join(tempDir, newDir[0])
-		return nil
-	} else if strings.HasSuffix(b.Program.Artifact, ".tar.gz") {
-		archive, err := gzip.NewReader(buf)
-		if err != nil {
-			return fmt.Errorf("failed to create Gzip reader: %w", err)
-		}
-		defer archive.Close()
-		tarReader := tar.NewReader(archive)
-		tempDir := filepath.Join(conf.BPFDir, b.Program.Name, b.Program.Version)
-
-		for {
-			header, err := tarReader.Next()
-
-			if err == io.EOF {
-				break
-			} else if err != nil {
-				return fmt.Errorf("untar failed: %w", err)
-			}
-
-			if strings.Contains(header.Name, "..") {
-				return fmt.Errorf("zipped file contians filepath (%s) that includes (..)", header.Name)
-			}
-
-			fPath = filepath.Join(tempDir, header.Name)
-			info := header.FileInfo()
-			if info.IsDir() {
-				if err = os.MkdirAll(fPath, info.Mode()); err != nil {
-					return fmt.Errorf("untar failed to create directories: %w", err)
-				}
-				continue
-			}
-
-			file, err := os.OpenFile(fPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
-			if err != nil {
-				return fmt.Errorf("untar failed to create file: %w", err)
-			}
-			defer file.Close()
+	}
 
-			buf := copyBufPool.Get().(*bytes.Buffer)
-			_, err = io.CopyBuffer(file, tarReader, buf.Bytes())
-			if err != nil {
-				return fmt.Errorf("GetArtifacts failed to copy files: %w", err)
-			}
-			copyBufPool.Put(buf)
-		}
-		newDir := strings.Split(b.Program.Artifact, ".")
-		b.FilePath = filepath.Join(tempDir, newDir[0])
-		return nil
-	} else {
-		return fmt.Errorf("unknown artifact format ")
+	newDir := strings.Split(b.Program.Artifact, ".")
+	if err := installArtifact(tempDir, versionDir, newDir[0], b.Program.CmdStart, tag); err != nil {
+		return err
 	}
+	b.FilePath = filepath.Join(versionDir, newDir[0])
+	return nil
+}
+
+// observeDownload - records the time taken and the bytes of the artifact download from the source
+func observeDownload(name, source string, started time.Time, size int) {
+	stats.Observe(time.Since(started), stats.ArtifactDownloadDuration, name, source)
+	stats.Add(float64(size), stats.ArtifactDownloadBytes, name, source)
 }
 
 // create rules file
@@ -663,9 +923,17 @@ func (b *BPF) createUpdateRulesFile(direction string) (string, error) {
 		return "", fmt.Errorf("RulesFile name is empty")
 	}
 
-	fileName := path.Join(b.FilePath, direction, b.Program.RulesFile)
+	if err := validateNFFileName(b.Program.RulesFile); err != nil {
+		return "", err
+	}
+	dir, err := b.nfFilesDir(direction)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Join(dir, b.Program.RulesFile)
 
-	if err := ioutil.WriteFile(fileName, []byte(b.Program.Rules), 0644); err != nil {
+	if err := appFS.WriteFile(fileName, []byte(b.Program.Rules), 0600); err != nil {
 		return "", fmt.Errorf("create or Update Rules File failed with error %w", err)
 	}
 
@@ -675,7 +943,7 @@ func (b *BPF) createUpdateRulesFile(direction string) (string, error) {
 
 // fileExists checks if a file exists or not
 func fileExists(filename string) bool {
-	info, err := os.Stat(filename)
+	info, err := appFS.Stat(filename)
 	if os.IsNotExist(err) {
 		return false
 	}
@@ -699,7 +967,7 @@ func (b *BPF) GetBPFMap(mapName string) (*BPFMap, error) {
 
 	// TC maps are pinned by default
 	if b.Program.ProgType == models.TCType {
-		ebpfMap, err := ebpf.LoadPinnedMap(mapName, nil)
+		ebpfMap, err := bpfAPI.LoadPinnedMap(mapName, nil)
 		if err != nil {
 			return nil, fmt.Errorf("ebpf LoadPinnedMap failed %v", err)
 		}
@@ -734,13 +1002,13 @@ func (b *BPF) GetBPFMap(mapName string) (*BPFMap, error) {
 		var mpId ebpf.MapID = 0
 
 		for {
-			tmpMapId, err := ebpf.MapGetNextID(mpId)
+			tmpMapId, err := bpfAPI.MapGetNextID(mpId)
 
 			if err != nil {
 				return nil, fmt.Errorf("failed to fetch the map object %v", err)
 			}
 
-			ebpfMap, err := ebpf.NewMapFromID(tmpMapId)
+			ebpfMap, err := bpfAPI.NewMapFromID(tmpMapId)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get NewMapFromID %v", err)
 			}
@@ -769,45 +1037,74 @@ func (b *BPF) GetBPFMap(mapName string) (*BPFMap, error) {
 }
 
 // Add eBPF map into BPFMaps list
-func (b *BPF) AddMetricsBPFMap(mapName, aggregator string, key, samplesLength int) error {
+func (b *BPF) AddMetricsBPFMap(element models.L3afDNFMetricsMap, samplesLength int) error {
 
 	var tmpMetricsBPFMap MetricsBPFMap
-	bpfMap, err := b.GetBPFMap(mapName)
+	bpfMap, err := b.GetBPFMap(element.Name)
 
 	if err != nil {
 		return err
 	}
 
 	tmpMetricsBPFMap.BPFMap = *bpfMap
-	tmpMetricsBPFMap.key = key
-	tmpMetricsBPFMap.aggregator = aggregator
+	tmpMetricsBPFMap.key = element.Key
+	tmpMetricsBPFMap.aggregator = element.Aggregator
+	tmpMetricsBPFMap.field = monitorField(element)
 	tmpMetricsBPFMap.Values = ring.New(samplesLength)
 
 	log.Info().Msgf("added Metrics map ID %d Name %s Type %s Key %d Aggregator %s", tmpMetricsBPFMap.MapID, tmpMetricsBPFMap.Name, tmpMetricsBPFMap.Type, tmpMetricsBPFMap.key, tmpMetricsBPFMap.aggregator)
-	map_key := mapName + strconv.Itoa(key) + aggregator
-	b.MetricsBpfMaps[map_key] = &tmpMetricsBPFMap
+	b.MetricsBpfMaps[monitorElementKey(element)] = &tmpMetricsBPFMap
 
 	return nil
 }
 
 // This method to fetch values from bpf maps and publish to metrics
 func (b *BPF) MonitorMaps(ifaceName string, intervals int) error {
-	for _, element := range b.Program.MonitorMaps {
-		log.Debug().Msgf("monitor maps element %s key %d aggregator %s", element.Name, element.Key, element.Aggregator)
-		mapKey := element.Name + strconv.Itoa(element.Key) + element.Aggregator
-		_, ok := b.MetricsBpfMaps[mapKey]
-		if !ok {
-			if err := b.AddMetricsBPFMap(element.Name, element.Aggregator, element.Key, intervals); err != nil {
-				return fmt.Errorf("not able to fetch map %s key %d aggregator %s", element.Name, element.Key, element.Aggregator)
+	for _, configured := range b.Program.MonitorMaps {
+		for _, element := range b.monitorElements(configured) {
+			if err := b.monitorMap(element, intervals); err != nil {
+				return err
 			}
 		}
-		bpfMap := b.MetricsBpfMaps[mapKey]
-		MetricName := element.Name + "_" + strconv.Itoa(element.Key) + "_" + element.Aggregator
-		stats.SetValue(bpfMap.GetValue(), stats.NFMointorMap, b.Program.Name, MetricName)
 	}
 	return nil
 }
 
+// monitorMap - samples the monitor map element and publishes the aggregated value
+func (b *BPF) monitorMap(element models.L3afDNFMetricsMap, intervals int) error {
+	debugProgram(b.Program.Name).Msgf("monitor maps element %s key %d aggregator %s", element.Name, element.Key, element.Aggregator)
+	mapKey := monitorElementKey(element)
+	_, ok := b.MetricsBpfMaps[mapKey]
+	if !ok {
+		if err := b.AddMetricsBPFMap(element, intervals); err != nil {
+			return fmt.Errorf("not able to fetch map %s key %d aggregator %s", element.Name, element.Key, element.Aggregator)
+		}
+	}
+	bpfMap := b.MetricsBpfMaps[mapKey]
+	value := bpfMap.GetValue()
+	stats.SetValue(value, stats.NFMointorMap, b.Program.Name, monitorMetricName(element))
+	metricsHistory.record(b.Program.Name, monitorMetricName(element), value, timeNow())
+	return nil
+}
+
+// monitorMetricName - metric name of the monitor map element, suffixed with the field of struct values
+func monitorMetricName(element models.L3afDNFMetricsMap) string {
+	name := element.Name + "_" + strconv.Itoa(element.Key) + "_" + element.Aggregator
+	if f := monitorField(element); f != nil {
+		name += "_" + f.Name
+	}
+	return name
+}
+
+// monitorElementKey - key of the metrics BPF map of the monitor map element
+func monitorElementKey(element models.L3afDNFMetricsMap) string {
+	key := element.Name + strconv.Itoa(element.Key) + element.Aggregator
+	if f := monitorField(element); f != nil {
+		key += f.Name
+	}
+	return key
+}
+
 // Updating next program FD from program ID
 func (b *BPF) PutNextProgFDFromID(progID int) error {
 
@@ -817,29 +1114,31 @@ func (b *BPF) PutNextProgFDFromID(progID int) error {
 	}
 
 	log.Info().Msgf("PutNextProgFDFromID : Map Name %s ID %d", b.Program.MapName, progID)
-	ebpfMap, err := ebpf.LoadPinnedMap(b.Program.MapName, nil)
+	ebpfMap, err := bpfAPI.LoadPinnedMap(b.Program.MapName, nil)
 	if err != nil {
 		return fmt.Errorf("unable to access pinned next prog map %s %v", b.Program.MapName, err)
 	}
 	defer ebpfMap.Close()
 
-	bpfProg, err := ebpf.NewProgramFromID(ebpf.ProgramID(progID))
+	bpfProg, err := bpfAPI.NewProgramFromID(ebpf.ProgramID(progID))
 	if err != nil {
 		return fmt.Errorf("failed to get next prog FD from ID for program %s %v", b.Program.Name, err)
 	}
+	defer bpfProg.Close()
 	key := 0
 	fd := bpfProg.FD()
 	log.Info().Msgf("PutNextProgFDFromID : Map Name %s FD %d", b.Program.MapName, fd)
 	if err = ebpfMap.Update(unsafe.Pointer(&key), unsafe.Pointer(&fd), 0); err != nil {
 		return fmt.Errorf("unable to update prog next map %s %v", b.Program.MapName, err)
 	}
+	debugProgram(b.Program.Name).Msgf("chain update: next prog map %s points to program ID %d", b.Program.MapName, progID)
 	return nil
 }
 
 // GetProgID - This returns ID of the bpf program
 func (b *BPF) GetProgID() (int, error) {
 
-	ebpfMap, err := ebpf.LoadPinnedMap(b.PrevMapName, &ebpf.LoadPinOptions{ReadOnly: true})
+	ebpfMap, err := bpfAPI.LoadPinnedMap(b.PrevMapName, &ebpf.LoadPinOptions{ReadOnly: true})
 	if err != nil {
 		log.Error().Err(err).Msgf("unable to access pinned prog map %s", b.PrevMapName)
 		return 0, fmt.Errorf("unable to access pinned prog map %s %v", b.PrevMapName, err)
@@ -852,13 +1151,15 @@ func (b *BPF) GetProgID() (int, error) {
 		log.Warn().Err(err).Msgf("unable to lookup prog map %s", b.PrevMapName)
 		return 0, fmt.Errorf("unable to lookup prog map %w", err)
 	}
+	debugProgram(b.Program.Name).Msgf("map lookup: prog map %s key %d value %d", b.PrevMapName, key, value)
 
 	// verify progID before storing in locally.
-	_, err = ebpf.NewProgramFromID(ebpf.ProgramID(value))
+	bpfProg, err := bpfAPI.NewProgramFromID(ebpf.ProgramID(value))
 	if err != nil {
 		log.Warn().Err(err).Msgf("failed to verify program ID %s", b.PrevMapName)
 		return 0, fmt.Errorf("failed to verify program ID %s %v", b.Program.Name, err)
 	}
+	bpfProg.Close()
 
 	log.Info().Msgf("GetProgID - Name %s PrevMapName %s ID %d", b.Program.Name, b.PrevMapName, value)
 	return value, nil
@@ -871,7 +1172,7 @@ func (b *BPF) RemoveNextProgFD() error {
 		// no chaining map in case of root programs
 		return nil
 	}
-	ebpfMap, err := ebpf.LoadPinnedMap(b.Program.MapName, nil)
+	ebpfMap, err := bpfAPI.LoadPinnedMap(b.Program.MapName, nil)
 	if err != nil {
 		return fmt.Errorf("unable to access pinned next prog map %s %v", b.Program.MapName, err)
 	}
@@ -881,13 +1182,14 @@ func (b *BPF) RemoveNextProgFD() error {
 	if err := ebpfMap.Delete(unsafe.Pointer(&key)); err != nil {
 		return fmt.Errorf("failed to delete prog fd entry")
 	}
+	debugProgram(b.Program.Name).Msgf("chain update: next prog map %s entry removed", b.Program.MapName)
 	return nil
 }
 
 // Delete the entry if the last element
 func (b *BPF) RemovePrevProgFD() error {
 
-	ebpfMap, err := ebpf.LoadPinnedMap(b.PrevMapName, nil)
+	ebpfMap, err := bpfAPI.LoadPinnedMap(b.PrevMapName, nil)
 	if err != nil {
 		return fmt.Errorf("unable to access pinned prev prog map %s %v", b.PrevMapName, err)
 	}
@@ -896,7 +1198,7 @@ func (b *BPF) RemovePrevProgFD() error {
 
 	if err := ebpfMap.Delete(unsafe.Pointer(&key)); err != nil {
 		// Some cases map may be empty ignore it.
-		log.Debug().Err(err).Msg("RemovePrevProgFD failed")
+		debugProgram(b.Program.Name).Err(err).Msgf("chain update: prev prog map %s entry not removed", b.PrevMapName)
 	}
 	return nil
 }
@@ -910,12 +1212,18 @@ func (b *BPF) VerifyPinnedMapExists(chain bool) error {
 
 	var err error
 	if len(b.Program.MapName) > 0 {
-		log.Debug().Msgf("VerifyPinnedMapExists : Program %s MapName %s", b.Program.Name, b.Program.MapName)
+		debugProgram(b.Program.Name).Msgf("VerifyPinnedMapExists : Program %s MapName %s", b.Program.Name, b.Program.MapName)
+		if delay := injectedFaults.mapPinDelay(b.Program.Name); delay > 0 {
+			time.Sleep(delay)
+		}
 		for i := 0; i < 10; i++ {
-			if _, err = os.Stat(b.Program.MapName); err == nil {
+			if _, err = appFS.Stat(b.Program.MapName); err == nil {
 				log.Info().Msgf("VerifyPinnedMapExists : map file created %s", b.Program.MapName)
 				return nil
 			}
+			if b.startExpired() {
+				break
+			}
 			log.Warn().Msgf("failed to find pinned file, checking again after a second ... ")
 			time.Sleep(1 * time.Second)
 		}
@@ -938,9 +1246,9 @@ func (b *BPF) VerifyPinnedMapVanish(chain bool) error {
 	}
 
 	var err error
-	log.Debug().Msgf("VerifyPinnedMapVanish : Program %s MapName %s", b.Program.Name, b.Program.MapName)
+	debugProgram(b.Program.Name).Msgf("VerifyPinnedMapVanish : Program %s MapName %s", b.Program.Name, b.Program.MapName)
 	for i := 0; i < 10; i++ {
-		if _, err = os.Stat(b.Program.MapName); os.IsNotExist(err) {
+		if _, err = appFS.Stat(b.Program.MapName); os.IsNotExist(err) {
 			log.Info().Msgf("VerifyPinnedMapVanish : map file removed successfully - %s ", b.Program.MapName)
 			return nil
 		} else if err != nil {
@@ -469,3 +469,51 @@ func Test_assertExecute(t *testing.T) {
 		})
 	}
 }
+
+func Test_matchExternalProcess(t *testing.T) {
+	cmdPath := "/opt/l3afd/ratelimiting/1.0/ratelimiting"
+	tests := []struct {
+		name    string
+		comm    string
+		exe     string
+		cmdline []string
+		want    bool
+	}{
+		{name: "Executable", comm: "ratelimiting", exe: cmdPath, cmdline: []string{"./ratelimiting", "--iface=eth0"}, want: true},
+		{name: "DeletedExecutable", comm: "ratelimiting", exe: cmdPath + " (deleted)", cmdline: []string{"./ratelimiting"}, want: true},
+		{name: "Argv0", comm: "bash", exe: "/usr/bin/bash", cmdline: []string{cmdPath, "--iface=eth0"}, want: true},
+		{name: "Script", comm: "ratelimiting", exe: "/usr/bin/python3", cmdline: []string{"/usr/bin/python3", cmdPath}, want: true},
+		{name: "SamePrefix", comm: "ratelimiting-age", exe: "/usr/sbin/ratelimiting-agent", cmdline: []string{"/usr/sbin/ratelimiting-agent"}, want: false},
+		{name: "OtherVersion", comm: "ratelimiting", exe: "/opt/l3afd/ratelimiting/0.9/ratelimiting", cmdline: []string{"./ratelimiting"}, want: false},
+		{name: "Argument", comm: "less", exe: "/usr/bin/less", cmdline: []string{"less", cmdPath}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchExternalProcess(cmdPath, tt.comm, tt.exe, tt.cmdline); got != tt.want {
+				t.Errorf("matchExternalProcess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopExternalRunningProcessMode(t *testing.T) {
+	if err := StopExternalRunningProcess("/opt/l3afd/ratelimiting/1.0/ratelimiting", models.ExternalStopDisabled); err != nil {
+		t.Errorf("StopExternalRunningProcess() disabled error = %v", err)
+	}
+	if err := StopExternalRunningProcess("/opt/l3afd/ratelimiting/1.0/ratelimiting", "terminate"); err == nil {
+		t.Errorf("StopExternalRunningProcess() unknown mode error = nil")
+	}
+}
+
+func TestBPF_killStartedProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to start sleep: %v", err)
+	}
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, Cmd: cmd}
+	b.killStartedProcess()
+	if b.Cmd != nil || cmd.ProcessState == nil {
+		t.Errorf("killStartedProcess() cmd = %v state = %v, want the process killed and reaped", b.Cmd, cmd.ProcessState)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"strings"
 	"unsafe"
 
+	"github.com/l3af-project/l3afd/models"
+
 	"github.com/cilium/ebpf"
 	"github.com/rs/zerolog/log"
 )
@@ -31,6 +33,17 @@ type MetricsBPFMap struct {
 	Values     *ring.Ring
 	aggregator string
 	lastValue  float64
+
+	// field - field of the struct value to decode, nil when the value is an integer
+	field *models.L3afDNFMetricsField
+}
+
+// programName - name of the program of the map, empty when the map is not of a program
+func (b *BPFMap) programName() string {
+	if b.BPFProg == nil {
+		return ""
+	}
+	return b.BPFProg.Program.Name
 }
 
 // This function is used to update eBPF maps, which are used by network functions.
@@ -52,7 +65,7 @@ type MetricsBPFMap struct {
 // 		key => 0 value => 10000
 func (b *BPFMap) Update(value string) error {
 
-	log.Debug().Msgf("update map name %s ID %d", b.Name, b.MapID)
+	debugProgram(b.programName()).Msgf("update map name %s ID %d", b.Name, b.MapID)
 	ebpfMap, err := ebpf.NewMapFromID(b.MapID)
 	if err != nil {
 		return fmt.Errorf("access new map from ID failed %v", err)
@@ -122,32 +135,49 @@ func (b *MetricsBPFMap) GetValue() float64 {
 	}
 	defer ebpfMap.Close()
 
-	var value int64
-	if err = ebpfMap.Lookup(unsafe.Pointer(&b.key), unsafe.Pointer(&value)); err != nil {
+	value, err := b.lookupValue(ebpfMap)
+	if err != nil {
 		log.Warn().Err(err).Msgf("GetValue Lookup failed : Name %s ID %d", b.Name, b.MapID)
 		return 0
 	}
+	debugProgram(b.programName()).Msgf("map lookup: map %s ID %d key %d value %v", b.Name, b.MapID, b.key, value)
 
 	var retVal float64
 	switch b.aggregator {
 	case "scalar":
-		retVal = float64(value)
+		retVal = value
 	case "max-rate":
 		b.Values = b.Values.Next()
-		b.Values.Value = math.Abs(float64(float64(value) - b.lastValue))
-		b.lastValue = float64(value)
+		b.Values.Value = math.Abs(value - b.lastValue)
+		b.lastValue = value
 		retVal = b.MaxValue()
 	case "avg":
 		b.Values.Value = value
 		b.Values = b.Values.Next()
 		retVal = b.AvgValue()
 	default:
-		log.Warn().Msgf("unsupported aggregator %s and value %d", b.aggregator, value)
+		log.Warn().Msgf("unsupported aggregator %s and value %v", b.aggregator, value)
 	}
 
 	return retVal
 }
 
+// lookupValue - value of the key, the field of the struct value when a value layout is configured
+func (b *MetricsBPFMap) lookupValue(m ebpfMap) (float64, error) {
+	if b.field == nil {
+		var value int64
+		if err := m.Lookup(unsafe.Pointer(&b.key), unsafe.Pointer(&value)); err != nil {
+			return 0, err
+		}
+		return float64(value), nil
+	}
+	var value []byte
+	if err := m.Lookup(unsafe.Pointer(&b.key), &value); err != nil {
+		return 0, err
+	}
+	return decodeMonitorField(value, *b.field)
+}
+
 // This method  finds the max value in the circular list
 func (b *MetricsBPFMap) MaxValue() float64 {
 	tmp := b.Values
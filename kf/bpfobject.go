@@ -0,0 +1,173 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// VerifyBPFObjects - inspects the eBPF ELF objects of the extracted artifact before the program is started.
+// It verifies the objects are parsable, licensed and match the configured program type and map name,
+// and runs the configured test vectors.
+func (b *BPF) VerifyBPFObjects(chain bool) error {
+	objects, err := findBPFObjects(b.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to find eBPF objects in artifact %s: %w", b.Program.Artifact, err)
+	}
+	if len(objects) == 0 {
+		if len(b.Program.TestVectors) > 0 {
+			return fmt.Errorf("test vectors are configured and no eBPF objects found in artifact %s", b.Program.Artifact)
+		}
+		log.Debug().Msgf("no eBPF objects found in artifact %s, skipping object inspection", b.Program.Artifact)
+		return nil
+	}
+
+	specs := make(map[string]*ebpf.CollectionSpec, len(objects))
+	for _, object := range objects {
+		spec, err := loadCollectionSpec(object)
+		if err != nil {
+			return fmt.Errorf("artifact %s contains invalid eBPF object %s: %w", b.Program.Artifact, filepath.Base(object), err)
+		}
+		specs[filepath.Base(object)] = spec
+	}
+
+	mapName := ""
+	if chain {
+		mapName = b.Program.MapName
+	}
+	if err := verifyCollectionSpecs(specs, b.Program.ProgType, mapName); err != nil {
+		return fmt.Errorf("artifact %s of program %s: %w", b.Program.Artifact, b.Program.Name, err)
+	}
+
+	b.MapMemory = declaredMapMemory(specs)
+
+	if len(b.Program.TestVectors) > 0 {
+		if err := b.RunTestVectors(specs); err != nil {
+			return fmt.Errorf("smoke test of program %s failed: %w", b.Program.Name, err)
+		}
+	}
+	return nil
+}
+
+// findBPFObjects - returns the ELF object files in the artifact dir
+func findBPFObjects(dir string) ([]string, error) {
+	objects := make([]string, 0)
+	err := walkFiles(appFS, dir, func(path string, d fs.DirEntry) error {
+		if strings.HasSuffix(d.Name(), ".o") {
+			objects = append(objects, path)
+		}
+		return nil
+	})
+	return objects, err
+}
+
+// loadCollectionSpec - parses the ELF object file
+func loadCollectionSpec(object string) (*ebpf.CollectionSpec, error) {
+	f, err := appFS.Open(object)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rd, ok := f.(io.ReaderAt)
+	if !ok {
+		return nil, fmt.Errorf("file %s does not support random access", object)
+	}
+	return ebpf.LoadCollectionSpecFromReader(rd)
+}
+
+// verifyCollectionSpecs - validates program types, license and next program map of the eBPF objects
+func verifyCollectionSpecs(specs map[string]*ebpf.CollectionSpec, progType, mapName string) error {
+	progTypes := make(map[string]bool)
+	mapFound := false
+	for object, spec := range specs {
+		for name, prog := range spec.Programs {
+			if len(prog.License) == 0 {
+				return fmt.Errorf("object %s program %s has no license section", object, name)
+			}
+			progTypes[hookType(prog.Type)] = true
+		}
+		if len(mapName) > 0 {
+			if _, ok := spec.Maps[filepath.Base(mapName)]; ok {
+				mapFound = true
+			}
+		}
+	}
+
+	if len(progTypes) == 0 {
+		return fmt.Errorf("contains no eBPF programs")
+	}
+
+	if len(progType) > 0 && !progTypes[progType] {
+		found := make([]string, 0, len(progTypes))
+		for t := range progTypes {
+			found = append(found, t)
+		}
+		sort.Strings(found)
+		return fmt.Errorf("artifact contains %s program but config says %s", strings.Join(found, ","), progType)
+	}
+
+	if len(mapName) > 0 && !mapFound {
+		return fmt.Errorf("map %s is not defined in the eBPF objects", filepath.Base(mapName))
+	}
+	return nil
+}
+
+// possibleCPUsPath - CPUs the kernel allocates the values of the per cpu maps for, including the offline CPUs and
+// the CPUs l3afd is not allowed to run on
+const possibleCPUsPath = "/sys/devices/system/cpu/possible"
+
+// possibleCPUs - count of the possible CPUs of the kernel, the CPUs of l3afd when the kernel doesn't report them
+func possibleCPUs() int {
+	data, err := appFS.ReadFile(possibleCPUsPath)
+	if err == nil {
+		var cpus []int
+		if cpus, err = parseCPUList(strings.TrimSpace(string(data))); err == nil {
+			return len(cpus)
+		}
+	}
+	log.Debug().Err(err).Msgf("failed to read the possible cpus, per cpu maps are accounted for the cpus of l3afd")
+	return runtime.NumCPU()
+}
+
+// declaredMapMemory - sum of max_entries x (key_size + value_size) of the maps defined in the eBPF objects,
+// per cpu maps are accounted for every possible cpu
+func declaredMapMemory(specs map[string]*ebpf.CollectionSpec) uint64 {
+	var memory uint64
+	cpus := uint64(possibleCPUs())
+	for _, spec := range specs {
+		for _, m := range spec.Maps {
+			size := uint64(m.MaxEntries) * uint64(m.KeySize+m.ValueSize)
+			switch m.Type {
+			case ebpf.PerCPUHash, ebpf.PerCPUArray, ebpf.LRUCPUHash:
+				size *= cpus
+			}
+			memory += size
+		}
+	}
+	return memory
+}
+
+// hookType - returns the config program type of the eBPF program type
+func hookType(progType ebpf.ProgramType) string {
+	switch progType {
+	case ebpf.XDP:
+		return models.XDPType
+	case ebpf.SchedCLS, ebpf.SchedACT:
+		return models.TCType
+	default:
+		return strings.ToLower(progType.String())
+	}
+}
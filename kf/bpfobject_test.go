@@ -0,0 +1,92 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_verifyCollectionSpecs(t *testing.T) {
+	xdpSpec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{"xdp_ratelimiting": {Type: ebpf.XDP, License: "Dual BSD/GPL"}},
+		Maps:     map[string]*ebpf.MapSpec{"xdp_rl_ingress_next_prog": {Type: ebpf.ProgramArray}},
+	}
+	tests := []struct {
+		name     string
+		specs    map[string]*ebpf.CollectionSpec
+		progType string
+		mapName  string
+		wantErr  bool
+	}{
+		{
+			name:     "Valid",
+			specs:    map[string]*ebpf.CollectionSpec{"ratelimiting_kern.o": xdpSpec},
+			progType: models.XDPType,
+			mapName:  "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+			wantErr:  false,
+		},
+		{
+			name:     "NoProgramType",
+			specs:    map[string]*ebpf.CollectionSpec{"ratelimiting_kern.o": xdpSpec},
+			progType: "",
+			mapName:  "",
+			wantErr:  false,
+		},
+		{
+			name:     "ProgramTypeMismatch",
+			specs:    map[string]*ebpf.CollectionSpec{"ratelimiting_kern.o": xdpSpec},
+			progType: models.TCType,
+			mapName:  "",
+			wantErr:  true,
+		},
+		{
+			name:     "MapNotDefined",
+			specs:    map[string]*ebpf.CollectionSpec{"ratelimiting_kern.o": xdpSpec},
+			progType: models.XDPType,
+			mapName:  "/sys/fs/bpf/xdp_rl_egress_next_prog",
+			wantErr:  true,
+		},
+		{
+			name: "NoLicense",
+			specs: map[string]*ebpf.CollectionSpec{"ratelimiting_kern.o": {
+				Programs: map[string]*ebpf.ProgramSpec{"xdp_ratelimiting": {Type: ebpf.XDP}},
+			}},
+			progType: models.XDPType,
+			mapName:  "",
+			wantErr:  true,
+		},
+		{
+			name: "NoPrograms",
+			specs: map[string]*ebpf.CollectionSpec{"ratelimiting_kern.o": {
+				Maps: map[string]*ebpf.MapSpec{"xdp_rl_ingress_next_prog": {Type: ebpf.ProgramArray}},
+			}},
+			progType: models.XDPType,
+			mapName:  "",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyCollectionSpecs(tt.specs, tt.progType, tt.mapName); (err != nil) != tt.wantErr {
+				t.Errorf("verifyCollectionSpecs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_declaredMapMemory(t *testing.T) {
+	useMemFS(t, map[string]string{possibleCPUsPath: "0-63\n"})
+	specs := map[string]*ebpf.CollectionSpec{"ratelimiting.bpf.o": {Maps: map[string]*ebpf.MapSpec{
+		"rl_config_map":     {Type: ebpf.Array, KeySize: 4, ValueSize: 8, MaxEntries: 2},
+		"rl_drop_count_map": {Type: ebpf.PerCPUArray, KeySize: 4, ValueSize: 8, MaxEntries: 1},
+	}}}
+	// per cpu map is accounted for the 64 possible cpus
+	if got, want := declaredMapMemory(specs), uint64(2*12+64*12); got != want {
+		t.Errorf("declaredMapMemory() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// kernelBTFPath is BTF exposed by the kernels built with CONFIG_DEBUG_INFO_BTF
+var kernelBTFPath = "/sys/kernel/btf/vmlinux"
+
+// ProbeBTF - Verifies BTF of the running kernel is available for CO-RE programs.
+// When the kernel does not expose BTF, BTF of the running kernel is downloaded from the configured BTF hub.
+func (c *NFConfigs) ProbeBTF() error {
+	btfPath, err := probeBTF(c.hostConfig)
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("kernel BTF is available at %s", btfPath)
+	c.btfPath = btfPath
+	return nil
+}
+
+// probeBTF - returns path of the kernel BTF, downloaded BTF files are cached in the BTF dir
+func probeBTF(conf *config.Config) (string, error) {
+	if fileExists(kernelBTFPath) {
+		return kernelBTFPath, nil
+	}
+
+	if len(conf.BTFHubURL) == 0 {
+		return "", fmt.Errorf("kernel BTF %s is not available and BTF hub is not configured", kernelBTFPath)
+	}
+
+	release, err := getKernelRelease()
+	if err != nil {
+		return "", fmt.Errorf("failed to find kernel release: %w", err)
+	}
+
+	btfFile := filepath.Join(conf.BTFDir, release+".btf")
+	if fileExists(btfFile) {
+		return btfFile, nil
+	}
+
+	if err := downloadBTF(conf, release, btfFile); err != nil {
+		return "", fmt.Errorf("failed to download BTF of kernel %s: %w", release, err)
+	}
+	return btfFile, nil
+}
+
+// downloadBTF - downloads <hub-url>/<platform>/<arch>/<kernel release>.btf.tar.gz and extracts the BTF file
+func downloadBTF(conf *config.Config, release, btfFile string) error {
+	hubURL, err := url.Parse(conf.BTFHubURL)
+	if err != nil {
+		return fmt.Errorf("unknown BTF hub url format: %w", err)
+	}
+
+	platform, err := GetPlatform()
+	if err != nil {
+		return fmt.Errorf("failed to find BTF hub download path: %w", err)
+	}
+
+	hubURL.Path = path.Join(hubURL.Path, platform, btfArch(), release+".btf.tar.gz")
+	log.Info().Msgf("Downloading BTF - %s", hubURL)
+
+	timeOut := time.Duration(conf.HttpClientTimeout) * time.Second
+	var netTransport = &http.Transport{
+		ResponseHeaderTimeout: timeOut,
+	}
+	client := http.Client{Transport: netTransport, Timeout: timeOut}
+
+	resp, err := client.Get(hubURL.String())
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get request returned unexpected status code: %d (%s), %d was expected", resp.StatusCode, http.StatusText(resp.StatusCode), http.StatusOK)
+	}
+
+	// truncated download is never extracted
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("failed to read BTF archive: %w", err)
+	}
+
+	archive, err := gzip.NewReader(buf)
+	if err != nil {
+		return fmt.Errorf("failed to create Gzip reader: %w", err)
+	}
+	defer archive.Close()
+
+	tarReader := tar.NewReader(archive)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return errors.New("BTF file is not found in the archive")
+		} else if err != nil {
+			return fmt.Errorf("untar failed: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || filepath.Ext(header.Name) != ".btf" {
+			continue
+		}
+
+		if err := appFS.MkdirAll(conf.BTFDir, 0755); err != nil {
+			return fmt.Errorf("failed to create BTF dir: %w", err)
+		}
+
+		// extracting to temporary file, partially written BTF file is never used
+		tmpFile := btfFile + ".tmp"
+		file, err := appFS.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create BTF file: %w", err)
+		}
+		if _, err := io.Copy(file, tarReader); err != nil {
+			file.Close()
+			appFS.Remove(tmpFile)
+			return fmt.Errorf("failed to copy BTF file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			appFS.Remove(tmpFile)
+			return fmt.Errorf("failed to write BTF file: %w", err)
+		}
+		return appFS.Rename(tmpFile, btfFile)
+	}
+}
+
+// btfArch - architecture name used by the BTF hub
+func btfArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "386":
+		return "x86"
+	default:
+		return runtime.GOARCH
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+func Test_probeBTF(t *testing.T) {
+	vmlinux := "/sys/kernel/btf/vmlinux"
+	useMemFS(t, map[string]string{vmlinux: "btf"})
+
+	savedKernelBTFPath := kernelBTFPath
+	defer func() { kernelBTFPath = savedKernelBTFPath }()
+
+	tests := []struct {
+		name      string
+		kernelBTF string
+		conf      *config.Config
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "KernelBTF",
+			kernelBTF: vmlinux,
+			conf:      &config.Config{},
+			want:      vmlinux,
+			wantErr:   false,
+		},
+		{
+			name:      "NoKernelBTFNoHub",
+			kernelBTF: "/sys/kernel/btf/absent",
+			conf:      &config.Config{BTFDir: "/var/l3afd/btf"},
+			want:      "",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kernelBTFPath = tt.kernelBTF
+			got, err := probeBTF(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("probeBTF() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("probeBTF() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_downloadBTF_truncated(t *testing.T) {
+	if _, err := GetPlatform(); err != nil {
+		t.Skipf("platform is not available: %v", err)
+	}
+	// archive is cut short of its content length
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		w.Write([]byte{0x1f, 0x8b})
+	}))
+	defer srv.Close()
+
+	useMemFS(t, map[string]string{})
+	conf := &config.Config{BTFHubURL: srv.URL, BTFDir: "/var/l3afd/btf", HttpClientTimeout: 10}
+	if err := downloadBTF(conf, "5.15.0", "/var/l3afd/btf/5.15.0.btf"); err == nil || !strings.Contains(err.Error(), "failed to read BTF archive") {
+		t.Errorf("downloadBTF() error = %v, want the truncated archive rejected", err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// buildManifestName - build manifest in the root of the artifact
+const buildManifestName = "manifest.json"
+
+// loadBuildInfo - parses the build manifest packaged in the artifact, artifacts without the manifest have
+// no build info
+func (b *BPF) loadBuildInfo() {
+	b.BuildInfo = nil
+	data, err := appFS.ReadFile(filepath.Join(b.FilePath, buildManifestName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msgf("failed to read build manifest of program %s", b.Program.Name)
+		}
+		return
+	}
+	info := &models.L3afDNFBuildInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		log.Warn().Err(err).Msgf("invalid build manifest of program %s", b.Program.Name)
+		return
+	}
+	b.BuildInfo = info
+	log.Info().Msgf("program %s version %s build commit %s compiler %s clang %s build date %s",
+		b.Program.Name, b.Program.Version, info.Commit, info.Compiler, info.ClangVersion, info.BuildDate)
+}
@@ -0,0 +1,52 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestBPF_loadBuildInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string // absent when empty
+		want     *models.L3afDNFBuildInfo
+	}{
+		{
+			name:     "Manifest",
+			manifest: `{"build_commit":"3f2a9c1","compiler":"go1.16.15","clang_version":"12.0.1","build_date":"2024-05-01T10:00:00Z"}`,
+			want:     &models.L3afDNFBuildInfo{Commit: "3f2a9c1", Compiler: "go1.16.15", ClangVersion: "12.0.1", BuildDate: "2024-05-01T10:00:00Z"},
+		},
+		{
+			name: "NoManifest",
+			want: nil,
+		},
+		{
+			name:     "InvalidManifest",
+			manifest: `build_commit=3f2a9c1`,
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/ratelimiting": ""}
+			if len(tt.manifest) > 0 {
+				files["/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/manifest.json"] = tt.manifest
+			}
+			useMemFS(t, files)
+
+			b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz"}}
+			if err := b.VerifyAndGetArtifacts(&config.Config{BPFDir: "/var/l3afd"}); err != nil {
+				t.Fatalf("VerifyAndGetArtifacts() error = %v", err)
+			}
+			if !reflect.DeepEqual(b.BuildInfo, tt.want) {
+				t.Errorf("VerifyAndGetArtifacts() build info = %+v, want %+v", b.BuildInfo, tt.want)
+			}
+		})
+	}
+}
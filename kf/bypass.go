@@ -0,0 +1,166 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// bypassBPF - wires the predecessor of the crash looping program directly to its successor, so the rest of
+// the chain keeps processing packets. The program stays degraded until it is started again by a config apply.
+// The reason the program is bypassed is logged and notified.
+func bypassBPF(e *list.Element, ifaceName, direction, reason string) error {
+	bpf := e.Value.(*BPF)
+	if bpf.Degraded {
+		return nil
+	}
+
+	if err := unlinkBPF(e); err != nil {
+		return err
+	}
+
+	bpf.Degraded = true
+	bpf.failedOpen = false
+	stats.Set(1.0, stats.NFDegraded, bpf.Program.Name, direction)
+	log.Error().Msgf("program %s iface %s direction %s is DEGRADED, %s and the program is bypassed in the chain",
+		bpf.Program.Name, ifaceName, direction, reason)
+	notifyEvent(EventProgramBypassed, ifaceName, direction, bpf.Program.Name, reason+" and the program is bypassed in the chain")
+	return nil
+}
+
+// chainNeighbours - predecessor and successor of the program in the chain, bypassed and standby neighbours
+// are skipped
+func chainNeighbours(e *list.Element) (*list.Element, *list.Element) {
+	prev := e.Prev()
+	for prev != nil && prev.Value.(*BPF).bypassed() {
+		prev = prev.Prev()
+	}
+	next := e.Next()
+	for next != nil && next.Value.(*BPF).bypassed() {
+		next = next.Next()
+	}
+	return prev, next
+}
+
+// bypassed - program is not linked into the chain, it is degraded or in standby
+func (b *BPF) bypassed() bool {
+	return b.Degraded || b.standby()
+}
+
+// unlinkBPF - links the predecessor of the program directly to its successor, the program is out of the chain
+func unlinkBPF(e *list.Element) error {
+	bpf := e.Value.(*BPF)
+	prev, next := chainNeighbours(e)
+	if prev == nil {
+		return fmt.Errorf("program %s has no predecessor in the chain to bypass it", bpf.Program.Name)
+	}
+
+	prevBPF := prev.Value.(*BPF)
+	if next != nil {
+		nextBPF := next.Value.(*BPF)
+		nextBPF.PrevMapName = prevBPF.Program.MapName
+		if err := prevBPF.PutNextProgFDFromID(nextBPF.ProgID); err != nil {
+			return fmt.Errorf("failed to link %s to %s bypassing %s: %w", prevBPF.Program.Name, nextBPF.Program.Name, bpf.Program.Name, err)
+		}
+	} else if err := prevBPF.RemoveNextProgFD(); err != nil {
+		return fmt.Errorf("failed to unlink %s bypassing %s: %w", prevBPF.Program.Name, bpf.Program.Name, err)
+	}
+	return nil
+}
+
+// linkBPF - links the program into the chain between its predecessor and successor, the program is linked to
+// its successor before its predecessor is linked to it
+func linkBPF(e *list.Element) error {
+	bpf := e.Value.(*BPF)
+	prev, next := chainNeighbours(e)
+	if prev == nil {
+		return fmt.Errorf("program %s has no predecessor in the chain to link it", bpf.Program.Name)
+	}
+
+	if next != nil {
+		nextBPF := next.Value.(*BPF)
+		if err := bpf.PutNextProgFDFromID(nextBPF.ProgID); err != nil {
+			return fmt.Errorf("failed to link %s to %s: %w", bpf.Program.Name, nextBPF.Program.Name, err)
+		}
+		nextBPF.PrevMapName = bpf.Program.MapName
+	} else if err := bpf.RemoveNextProgFD(); err != nil {
+		// map of the restarted program has no entry
+		log.Debug().Err(err).Msgf("program %s has no next program entry", bpf.Program.Name)
+	}
+	prevBPF := prev.Value.(*BPF)
+	if err := prevBPF.PutNextProgFDFromID(bpf.ProgID); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", prevBPF.Program.Name, bpf.Program.Name, err)
+	}
+	bpf.PrevMapName = prevBPF.Program.MapName
+	return nil
+}
+
+// failOpen - program is removed from the chain as soon as its user process dies, by its on failure policy
+func (b *BPF) failOpen() bool {
+	return b.Program.OnFailure == models.OnFailureFailOpen
+}
+
+// validateOnFailure - on failure policy is known
+func validateOnFailure(policy string) error {
+	switch policy {
+	case "", models.OnFailureFailOpen, models.OnFailureFailClosed:
+		return nil
+	default:
+		return fmt.Errorf("unknown on failure policy %q, expected %s or %s", policy, models.OnFailureFailOpen, models.OnFailureFailClosed)
+	}
+}
+
+// ValidateOnFailure - Verifies the on failure policies of the programs
+func ValidateOnFailure(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateOnFailure(ref.prog.OnFailure); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// failOpenBPF - bypasses the fail-open program whose user process died, the process monitoring keeps restarting
+// the program and links it back into the chain once it is running
+func failOpenBPF(e *list.Element, ifaceName, direction string) error {
+	bpf := e.Value.(*BPF)
+	if bpf.Degraded {
+		return nil
+	}
+	if err := bypassBPF(e, ifaceName, direction, "process is not running, fail-open"); err != nil {
+		return err
+	}
+	bpf.failedOpen = true
+	return nil
+}
+
+// restoreBPF - links the restarted fail-open program back into the chain, the program is linked to its
+// successor before its predecessor is linked to it
+func restoreBPF(e *list.Element, ifaceName, direction string) error {
+	bpf := e.Value.(*BPF)
+	if !bpf.Degraded || !bpf.failedOpen {
+		return nil
+	}
+	if err := linkBPF(e); err != nil {
+		return fmt.Errorf("failed to restore program %s: %w", bpf.Program.Name, err)
+	}
+
+	bpf.Degraded = false
+	bpf.failedOpen = false
+	stats.Set(0.0, stats.NFDegraded, bpf.Program.Name, direction)
+	log.Info().Msgf("program %s iface %s direction %s is restarted and linked back into the chain", bpf.Program.Name, ifaceName, direction)
+	notifyEvent(EventChainRepaired, ifaceName, direction, bpf.Program.Name, "fail-open program restarted and linked back into the chain")
+	return nil
+}
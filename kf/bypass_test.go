@@ -0,0 +1,190 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_bypassBPF(t *testing.T) {
+	tests := []struct {
+		name         string
+		degraded     []bool // root, rl, cl, lb
+		bypass       int
+		wantRootMap  map[int]int
+		wantRLMap    map[int]int
+		wantCLMap    map[int]int
+		wantPrevName string
+		wantErr      bool
+	}{
+		{
+			name:         "Middle",
+			degraded:     []bool{false, false, false, false},
+			bypass:       2,
+			wantRootMap:  map[int]int{0: 111},
+			wantRLMap:    map[int]int{0: 113},
+			wantCLMap:    map[int]int{0: 113},
+			wantPrevName: "/sys/fs/bpf/rl_next_prog",
+		},
+		{
+			name:         "Last",
+			degraded:     []bool{false, false, false, false},
+			bypass:       3,
+			wantRootMap:  map[int]int{0: 111},
+			wantRLMap:    map[int]int{0: 112},
+			wantCLMap:    map[int]int{},
+			wantPrevName: "/sys/fs/bpf/cl_next_prog",
+		},
+		{
+			name:         "PredecessorDegraded",
+			degraded:     []bool{false, true, false, false},
+			bypass:       2,
+			wantRootMap:  map[int]int{0: 113},
+			wantRLMap:    map[int]int{0: 112},
+			wantCLMap:    map[int]int{0: 113},
+			wantPrevName: "/sys/fs/bpf/root_next_prog",
+		},
+		{
+			name:     "NoPredecessor",
+			degraded: []bool{false, false, false, false},
+			bypass:   0,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// root -> rl -> cl -> lb, root map holds rl unless rl is bypassed already
+			rootMap := &fakeMap{entries: map[int]int{0: 111}}
+			if tt.degraded[1] {
+				rootMap.entries[0] = 112
+			}
+			rlMap := &fakeMap{entries: map[int]int{0: 112}}
+			clMap := &fakeMap{entries: map[int]int{0: 113}}
+			useFakeEBPF(t, &fakeEBPF{
+				maps: map[string]*fakeMap{
+					"/sys/fs/bpf/root_next_prog": rootMap,
+					"/sys/fs/bpf/rl_next_prog":   rlMap,
+					"/sys/fs/bpf/cl_next_prog":   clMap,
+				},
+				programs: map[ebpf.ProgramID]bool{11: true, 12: true, 13: true},
+			})
+
+			bpfList := list.New()
+			var elements []*list.Element
+			for i, name := range []string{"root", "rl", "cl", "lb"} {
+				b := &BPF{Program: models.BPFProgram{Name: name, MapName: "/sys/fs/bpf/" + name + "_next_prog"}, ProgID: 10 + i, Degraded: tt.degraded[i]}
+				if i > 0 {
+					b.PrevMapName = elements[i-1].Value.(*BPF).Program.MapName
+				}
+				elements = append(elements, bpfList.PushBack(b))
+			}
+
+			err := bypassBPF(elements[tt.bypass], "eth0", models.XDPIngressType, "restarts are exhausted")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bypassBPF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !elements[tt.bypass].Value.(*BPF).Degraded {
+				t.Errorf("bypassBPF() program is not degraded")
+			}
+			if !reflect.DeepEqual(rootMap.entries, tt.wantRootMap) {
+				t.Errorf("bypassBPF() root map = %v, want %v", rootMap.entries, tt.wantRootMap)
+			}
+			if !reflect.DeepEqual(rlMap.entries, tt.wantRLMap) {
+				t.Errorf("bypassBPF() rl map = %v, want %v", rlMap.entries, tt.wantRLMap)
+			}
+			if !reflect.DeepEqual(clMap.entries, tt.wantCLMap) {
+				t.Errorf("bypassBPF() cl map = %v, want %v", clMap.entries, tt.wantCLMap)
+			}
+			if next := elements[tt.bypass].Next(); next != nil && next.Value.(*BPF).PrevMapName != tt.wantPrevName {
+				t.Errorf("bypassBPF() successor prev map = %s, want %s", next.Value.(*BPF).PrevMapName, tt.wantPrevName)
+			}
+		})
+	}
+}
+
+func Test_restoreBPF(t *testing.T) {
+	// root -> rl -> lb while the fail-open cl is bypassed, cl is restarted with the program ID 12
+	rootMap := &fakeMap{entries: map[int]int{0: 111}}
+	rlMap := &fakeMap{entries: map[int]int{0: 113}}
+	clMap := &fakeMap{entries: map[int]int{}}
+	useFakeEBPF(t, &fakeEBPF{
+		maps: map[string]*fakeMap{
+			"/sys/fs/bpf/root_next_prog": rootMap,
+			"/sys/fs/bpf/rl_next_prog":   rlMap,
+			"/sys/fs/bpf/cl_next_prog":   clMap,
+		},
+		programs: map[ebpf.ProgramID]bool{11: true, 12: true, 13: true},
+	})
+
+	bpfList := list.New()
+	var elements []*list.Element
+	for i, name := range []string{"root", "rl", "cl", "lb"} {
+		b := &BPF{Program: models.BPFProgram{Name: name, MapName: "/sys/fs/bpf/" + name + "_next_prog"}, ProgID: 10 + i}
+		if i > 0 {
+			b.PrevMapName = elements[i-1].Value.(*BPF).Program.MapName
+		}
+		elements = append(elements, bpfList.PushBack(b))
+	}
+	cl, lb := elements[2].Value.(*BPF), elements[3].Value.(*BPF)
+	cl.Program.OnFailure = models.OnFailureFailOpen
+	cl.Degraded = true
+	lb.PrevMapName = "/sys/fs/bpf/rl_next_prog"
+
+	// program bypassed by the watchdog or the exhausted restarts stays bypassed
+	if err := restoreBPF(elements[2], "eth0", models.XDPIngressType); err != nil || !cl.Degraded {
+		t.Fatalf("restoreBPF() = %v degraded %v, want the program bypassed without the fail-open policy", err, cl.Degraded)
+	}
+
+	cl.failedOpen = true
+	if err := restoreBPF(elements[2], "eth0", models.XDPIngressType); err != nil {
+		t.Fatalf("restoreBPF() error = %v", err)
+	}
+	if cl.Degraded || cl.failedOpen {
+		t.Errorf("restoreBPF() program is still degraded")
+	}
+	if !reflect.DeepEqual(rlMap.entries, map[int]int{0: 112}) || !reflect.DeepEqual(clMap.entries, map[int]int{0: 113}) {
+		t.Errorf("restoreBPF() rl map = %v cl map = %v, want rl -> cl -> lb", rlMap.entries, clMap.entries)
+	}
+	if cl.PrevMapName != "/sys/fs/bpf/rl_next_prog" || lb.PrevMapName != "/sys/fs/bpf/cl_next_prog" {
+		t.Errorf("restoreBPF() prev maps = %s and %s, want the chain relinked", cl.PrevMapName, lb.PrevMapName)
+	}
+}
+
+func Test_failOpenBPF(t *testing.T) {
+	rootMap := &fakeMap{entries: map[int]int{0: 111}}
+	rlMap := &fakeMap{entries: map[int]int{0: 112}}
+	useFakeEBPF(t, &fakeEBPF{
+		maps: map[string]*fakeMap{
+			"/sys/fs/bpf/root_next_prog": rootMap,
+			"/sys/fs/bpf/rl_next_prog":   rlMap,
+		},
+		programs: map[ebpf.ProgramID]bool{11: true, 12: true},
+	})
+	bpfList := list.New()
+	bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "root", MapName: "/sys/fs/bpf/root_next_prog"}, ProgID: 10})
+	e := bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "rl", MapName: "/sys/fs/bpf/rl_next_prog", OnFailure: models.OnFailureFailOpen},
+		ProgID: 11, PrevMapName: "/sys/fs/bpf/root_next_prog"})
+
+	if err := failOpenBPF(e, "eth0", models.XDPIngressType); err != nil {
+		t.Fatalf("failOpenBPF() error = %v", err)
+	}
+	if rl := e.Value.(*BPF); !rl.Degraded || !rl.failedOpen {
+		t.Errorf("failOpenBPF() degraded %v failed open %v, want the program bypassed", rl.Degraded, rl.failedOpen)
+	}
+	if len(rootMap.entries) != 0 {
+		t.Errorf("failOpenBPF() root map = %v, want the last program unlinked", rootMap.entries)
+	}
+	if err := validateOnFailure("drop"); err == nil {
+		t.Errorf("validateOnFailure() accepted an unknown policy")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ErrChainNotFound is returned when no programs are running on the interface
+var ErrChainNotFound = errors.New("no programs are running on the iface")
+
+// ChainGraph - graph of the programs running on the interface, the chain edges follow the prog maps from the
+// root program to the last program of each direction and the shared map edges link the programs owning the
+// pinned maps to the programs using them
+func (c *NFConfigs) ChainGraph(ifaceName string) (models.L3afDChainGraph, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	graph := models.L3afDChainGraph{
+		Iface: ifaceName,
+		Nodes: make([]models.L3afDChainGraphNode, 0),
+		Edges: make([]models.L3afDChainGraphEdge, 0),
+	}
+	programs := make(map[string]*BPF)
+	for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+		bpfs, _ := c.bpfLists(direction)
+		bpfList := bpfs[ifaceName]
+		if bpfList == nil {
+			continue
+		}
+		var prev *BPF
+		for e := bpfList.Front(); e != nil; e = e.Next() {
+			bpf := e.Value.(*BPF)
+			state := models.EffectiveRunning
+			switch {
+			case c.hostConfig.BpfChainingEnabled && e == bpfList.Front() &&
+				(bpf.Program.Name == c.hostConfig.XDPRootProgramName || bpf.Program.Name == c.hostConfig.TCRootProgramName):
+				state = models.EffectiveRoot
+			case bpf.Degraded:
+				state = models.EffectiveBypassed
+			case bpf.standby():
+				state = models.EffectiveStandby
+			}
+			id := chainGraphNodeID(direction, bpf.Program.Name)
+			programs[id] = bpf
+			node := models.L3afDChainGraphNode{
+				ID:        id,
+				Name:      bpf.Program.Name,
+				Direction: direction,
+				SeqID:     bpf.Program.SeqID,
+				ProgID:    bpf.ProgID,
+				Version:   bpf.Program.Version,
+				State:     state,
+			}
+			if state == models.EffectiveRoot {
+				node.Flavor, _ = rootFlavor(c.hostConfig, ifaceName)
+			}
+			graph.Nodes = append(graph.Nodes, node)
+
+			if prev != nil && c.hostConfig.BpfChainingEnabled {
+				mapName := bpf.PrevMapName
+				if len(mapName) == 0 {
+					mapName = prev.Program.MapName
+				}
+				graph.Edges = append(graph.Edges, models.L3afDChainGraphEdge{
+					From: chainGraphNodeID(direction, prev.Program.Name),
+					To:   id,
+					Map:  mapName,
+					Kind: models.ChainEdge,
+				})
+			}
+			prev = bpf
+		}
+	}
+	if len(graph.Nodes) == 0 {
+		return graph, fmt.Errorf("%w %s", ErrChainNotFound, ifaceName)
+	}
+
+	// the owner of the shared map is looked up in the direction of the consumer first
+	for _, node := range graph.Nodes {
+		for _, consumed := range programs[node.ID].Program.ConsumedMaps {
+			owner := ""
+			for _, candidate := range graph.Nodes {
+				if candidate.Name != consumed.Program || candidate.ID == node.ID {
+					continue
+				}
+				if len(owner) == 0 || candidate.Direction == node.Direction {
+					owner = candidate.ID
+				}
+			}
+			if len(owner) == 0 {
+				continue
+			}
+			graph.Edges = append(graph.Edges, models.L3afDChainGraphEdge{
+				From: owner,
+				To:   node.ID,
+				Map:  consumed.Name,
+				Kind: models.SharedMapEdge,
+			})
+		}
+	}
+	return graph, nil
+}
+
+// chainGraphNodeID - id of the program in the chain graph
+func chainGraphNodeID(direction, name string) string {
+	return direction + "/" + name
+}
+
+// ChainGraphDOT - chain graph in the Graphviz DOT language, the directions are clusters of the graph and the
+// shared map edges are dashed
+func ChainGraphDOT(graph models.L3afDChainGraph) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", graph.Iface)
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	direction := ""
+	for _, node := range graph.Nodes {
+		if node.Direction != direction {
+			if len(direction) > 0 {
+				b.WriteString("  }\n")
+			}
+			direction = node.Direction
+			fmt.Fprintf(&b, "  subgraph %q {\n", "cluster_"+direction)
+			fmt.Fprintf(&b, "    label=%q;\n", direction)
+		}
+		label := fmt.Sprintf("%s %s\nseq %d prog %d", node.Name, node.Version, node.SeqID, node.ProgID)
+		style := ""
+		switch node.State {
+		case models.EffectiveRoot:
+			style = ", style=bold"
+		case models.EffectiveBypassed:
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "    %q [label=%q%s];\n", node.ID, label, style)
+	}
+	if len(direction) > 0 {
+		b.WriteString("  }\n")
+	}
+	for _, edge := range graph.Edges {
+		style := ""
+		if edge.Kind == models.SharedMapEdge {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %q -> %q [label=%q%s];\n", edge.From, edge.To, edge.Map, style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,77 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_ChainGraph(t *testing.T) {
+	xdp := list.New()
+	xdp.PushBack(&BPF{Program: models.BPFProgram{Name: "xdp-root", Version: "1.0", MapName: "/sys/fs/bpf/xdp_root_array"}, ProgID: 10})
+	xdp.PushBack(&BPF{
+		Program:     models.BPFProgram{Name: "ratelimiting", Version: "1.1", SeqID: 1, MapName: "/sys/fs/bpf/ratelimiting_next", SharedMaps: map[string]string{"blocklist": "/sys/fs/bpf/blocklist"}},
+		PrevMapName: "/sys/fs/bpf/xdp_root_array",
+		ProgID:      11,
+	})
+	xdp.PushBack(&BPF{
+		Program:     models.BPFProgram{Name: "connlimit", Version: "2.0", SeqID: 2, ConsumedMaps: []models.L3afDNFConsumedMap{{Name: "blocklist", Program: "ratelimiting"}}},
+		PrevMapName: "/sys/fs/bpf/ratelimiting_next",
+		ProgID:      12,
+		Degraded:    true,
+	})
+	c := &NFConfigs{
+		hostConfig:     &config.Config{BpfChainingEnabled: true, XDPRootProgramName: "xdp-root"},
+		IngressXDPBpfs: map[string]*list.List{"eth0": xdp},
+		IngressTCBpfs:  make(map[string]*list.List),
+		EgressTCBpfs:   make(map[string]*list.List),
+		mu:             new(sync.Mutex),
+	}
+
+	graph, err := c.ChainGraph("eth0")
+	if err != nil {
+		t.Fatalf("ChainGraph() error = %v", err)
+	}
+	if len(graph.Nodes) != 3 || graph.Nodes[0].State != models.EffectiveRoot || graph.Nodes[2].State != models.EffectiveBypassed {
+		t.Fatalf("nodes = %+v, want the root, ratelimiting and the bypassed connlimit", graph.Nodes)
+	}
+	if n := graph.Nodes[1]; n.ID != "xdpingress/ratelimiting" || n.SeqID != 1 || n.ProgID != 11 {
+		t.Errorf("node = %+v, want ratelimiting with seq id 1 and prog id 11", n)
+	}
+	want := []models.L3afDChainGraphEdge{
+		{From: "xdpingress/xdp-root", To: "xdpingress/ratelimiting", Map: "/sys/fs/bpf/xdp_root_array", Kind: models.ChainEdge},
+		{From: "xdpingress/ratelimiting", To: "xdpingress/connlimit", Map: "/sys/fs/bpf/ratelimiting_next", Kind: models.ChainEdge},
+		{From: "xdpingress/ratelimiting", To: "xdpingress/connlimit", Map: "blocklist", Kind: models.SharedMapEdge},
+	}
+	if len(graph.Edges) != len(want) {
+		t.Fatalf("edges = %+v, want %+v", graph.Edges, want)
+	}
+	for i := range want {
+		if graph.Edges[i] != want[i] {
+			t.Errorf("edge %d = %+v, want %+v", i, graph.Edges[i], want[i])
+		}
+	}
+
+	dot := ChainGraphDOT(graph)
+	for _, line := range []string{
+		`subgraph "cluster_xdpingress" {`,
+		`"xdpingress/xdp-root" [label="xdp-root 1.0\nseq 0 prog 10", style=bold];`,
+		`"xdpingress/ratelimiting" -> "xdpingress/connlimit" [label="blocklist", style=dashed];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("ChainGraphDOT() = %s, want line %s", dot, line)
+		}
+	}
+
+	if _, err := c.ChainGraph("eth1"); !errors.Is(err, ErrChainNotFound) {
+		t.Errorf("ChainGraph(eth1) error = %v, want ErrChainNotFound", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// chainHitsLoop - reads the chain hit counters of the interfaces every interval
+func (c *NFConfigs) chainHitsLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.ReadChainHits()
+		}
+	}
+}
+
+// ReadChainHits - reads the per position hit counters pinned by the root programs and publishes the hits of
+// the programs. Interfaces without the counters map, i.e. root programs without the instrumentation, are skipped.
+func (c *NFConfigs) ReadChainHits() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+		bpfs, _ := c.bpfLists(direction)
+		for ifaceName, bpfList := range bpfs {
+			if bpfList == nil {
+				continue
+			}
+			mapName := chainHitsMapName(c.hostConfig.ChainHitsMapDir, ifaceName, direction)
+			m, err := bpfAPI.LoadPinnedMap(mapName, nil)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					log.Warn().Err(err).Msgf("failed to open chain hit counters %s", mapName)
+				}
+				continue
+			}
+			readChainHits(m, bpfList, ifaceName, direction)
+			m.Close()
+		}
+	}
+}
+
+// chainHitsMapName - pinned hit counters map of the chain of the interface direction
+func chainHitsMapName(mapDir, ifaceName, direction string) string {
+	return filepath.Join(mapDir, ifaceName+"_"+direction+"_chain_hits")
+}
+
+// readChainHits - sets the hits of the chain positions, the position of a program is its index in the list.
+// A program receiving no packets while the previous position did is logged, the chain link may be
+// passing the packets around the program.
+func readChainHits(m ebpfMap, bpfList *list.List, ifaceName, direction string) {
+	var prev *BPF
+	var prevDelta uint64
+	position := 0
+	for e := bpfList.Front(); e != nil; e, position = e.Next(), position+1 {
+		bpf := e.Value.(*BPF)
+		key := position
+		var hits uint64
+		if err := m.Lookup(unsafe.Pointer(&key), unsafe.Pointer(&hits)); err != nil {
+			if !errors.Is(err, ebpf.ErrKeyNotExist) {
+				log.Warn().Err(err).Msgf("failed to read chain hits of program %s iface %s direction %s", bpf.Program.Name, ifaceName, direction)
+			}
+			prev = nil
+			continue
+		}
+		delta := hits - bpf.ChainHits
+		if hits < bpf.ChainHits { // counters are reset when the root program is restarted
+			delta = hits
+		}
+		bpf.ChainHits = hits
+		stats.Set(float64(hits), stats.NFChainHits, bpf.Program.Name, direction)
+
+		if prev != nil && prevDelta > 0 && delta == 0 && bpf.Program.AdminStatus == models.Enabled {
+			log.Warn().Msgf("program %s iface %s direction %s received no packets while %s received %d, chain link may be bypassing it",
+				bpf.Program.Name, ifaceName, direction, prev.Program.Name, prevDelta)
+		}
+		prev, prevDelta = bpf, delta
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_readChainHits(t *testing.T) {
+	bpfList := list.New()
+	for _, name := range []string{"xdp_root", "ratelimiting", "connection-limit"} {
+		bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: name, AdminStatus: models.Enabled}})
+	}
+	m := &fakeMap{entries: map[int]int{0: 100, 1: 80, 2: 80}}
+
+	readChainHits(m, bpfList, "dummy", models.XDPIngressType)
+	want := []uint64{100, 80, 80}
+	for e, i := bpfList.Front(), 0; e != nil; e, i = e.Next(), i+1 {
+		if got := e.Value.(*BPF).ChainHits; got != want[i] {
+			t.Errorf("ChainHits of position %d = %d, want %d", i, got, want[i])
+		}
+	}
+
+	// counters are reset on the root program restart
+	m.entries = map[int]int{0: 10, 1: 5}
+	readChainHits(m, bpfList, "dummy", models.XDPIngressType)
+	want = []uint64{10, 5, 80}
+	for e, i := bpfList.Front(), 0; e != nil; e, i = e.Next(), i+1 {
+		if got := e.Value.(*BPF).ChainHits; got != want[i] {
+			t.Errorf("ChainHits of position %d after reset = %d, want %d", i, got, want[i])
+		}
+	}
+}
+
+func Test_chainHitsMapName(t *testing.T) {
+	got := chainHitsMapName("/sys/fs/bpf/chain_hits", "eth0", models.XDPIngressType)
+	if want := "/sys/fs/bpf/chain_hits/eth0_xdpingress_chain_hits"; got != want {
+		t.Errorf("chainHitsMapName() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ErrChainLimitExceeded is returned when the config exceeds the chain limits of an interface
+var ErrChainLimitExceeded = errors.New("chain limit exceeded")
+
+// ValidateChainLimits - Verifies the number of enabled programs per direction and per interface
+// does not exceed the configured chain limits, tail call limits and per packet latency budgets
+// make unbounded chains dangerous. This is checked before applying any change.
+func (c *NFConfigs) ValidateChainLimits(bpfProgs []models.L3afBPFPrograms) error {
+	chains := make(map[string]map[string]int)
+	for _, cfg := range bpfProgs {
+		if cfg.BpfPrograms == nil {
+			continue
+		}
+		directions := map[string][]*models.BPFProgram{
+			models.XDPIngressType: cfg.BpfPrograms.XDPIngress,
+			models.IngressType:    cfg.BpfPrograms.TCIngress,
+			models.EgressType:     cfg.BpfPrograms.TCEgress,
+		}
+		for direction, progs := range directions {
+			for _, prog := range progs {
+				if prog == nil || !loadedStatus(prog.AdminStatus) {
+					continue
+				}
+				if chains[cfg.Iface] == nil {
+					chains[cfg.Iface] = make(map[string]int)
+				}
+				chains[cfg.Iface][direction]++
+			}
+		}
+	}
+
+	for iface, lengths := range chains {
+		limits := c.chainLimits(iface)
+		total := 0
+		for direction, length := range lengths {
+			if limits.MaxChainLength > 0 && length > limits.MaxChainLength {
+				return fmt.Errorf("%w: %d %s programs requested on interface %s, max allowed %d",
+					ErrChainLimitExceeded, length, direction, iface, limits.MaxChainLength)
+			}
+			total += length
+		}
+		if limits.MaxPrograms > 0 && total > limits.MaxPrograms {
+			return fmt.Errorf("%w: %d programs requested on interface %s, max allowed %d",
+				ErrChainLimitExceeded, total, iface, limits.MaxPrograms)
+		}
+	}
+
+	return nil
+}
+
+// chainLimits - limits of the interface, global limits unless overridden
+func (c *NFConfigs) chainLimits(iface string) config.ChainLimits {
+	if limits, ok := c.hostConfig.IfaceChainLimits[iface]; ok {
+		return limits
+	}
+	return c.hostConfig.ChainLimits
+}
@@ -0,0 +1,84 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_ValidateChainLimits(t *testing.T) {
+	progs := func(n int, status string) []*models.BPFProgram {
+		var p []*models.BPFProgram
+		for i := 0; i < n; i++ {
+			p = append(p, &models.BPFProgram{Name: "nf", SeqID: i + 1, AdminStatus: status})
+		}
+		return p
+	}
+	tests := []struct {
+		name     string
+		limits   config.ChainLimits
+		iface    map[string]config.ChainLimits
+		bpfProgs []models.L3afBPFPrograms
+		wantErr  bool
+	}{
+		{
+			name:   "Unlimited",
+			limits: config.ChainLimits{},
+			bpfProgs: []models.L3afBPFPrograms{
+				{Iface: "eth0", BpfPrograms: &models.BPFPrograms{XDPIngress: progs(20, models.Enabled)}},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "ChainLengthExceeded",
+			limits: config.ChainLimits{MaxChainLength: 2},
+			bpfProgs: []models.L3afBPFPrograms{
+				{Iface: "eth0", BpfPrograms: &models.BPFPrograms{TCIngress: progs(3, models.Enabled)}},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "DisabledNotCounted",
+			limits: config.ChainLimits{MaxChainLength: 2},
+			bpfProgs: []models.L3afBPFPrograms{
+				{Iface: "eth0", BpfPrograms: &models.BPFPrograms{TCIngress: append(progs(2, models.Enabled), progs(2, models.Disabled)...)}},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "ProgramsExceeded",
+			limits: config.ChainLimits{MaxChainLength: 2, MaxPrograms: 3},
+			bpfProgs: []models.L3afBPFPrograms{
+				{Iface: "eth0", BpfPrograms: &models.BPFPrograms{XDPIngress: progs(2, models.Enabled), TCEgress: progs(2, models.Enabled)}},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "IfaceOverride",
+			limits: config.ChainLimits{MaxChainLength: 2},
+			iface:  map[string]config.ChainLimits{"eth1": {MaxChainLength: 4}},
+			bpfProgs: []models.L3afBPFPrograms{
+				{Iface: "eth0", BpfPrograms: &models.BPFPrograms{XDPIngress: progs(2, models.Enabled)}},
+				{Iface: "eth1", BpfPrograms: &models.BPFPrograms{XDPIngress: progs(4, models.Enabled)}},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NFConfigs{hostConfig: &config.Config{ChainLimits: tt.limits, IfaceChainLimits: tt.iface}}
+			err := c.ValidateChainLimits(tt.bpfProgs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateChainLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrChainLimitExceeded) {
+				t.Errorf("ValidateChainLimits() error = %v, want ErrChainLimitExceeded", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ifaceGroupPrefix - prefix of the iface of the configs applying to the interfaces of an interface group
+const ifaceGroupPrefix = "@"
+
+// ExpandIfaceGroups - configs of the interface groups of l3afd.cfg expanded to a config per interface of the
+// group. Configs of the interface itself take precedence over the configs of its groups.
+func (c *NFConfigs) ExpandIfaceGroups(bpfProgs []models.L3afBPFPrograms) ([]models.L3afBPFPrograms, error) {
+	explicit := make(map[string]bool)
+	for _, cfg := range bpfProgs {
+		if !strings.HasPrefix(cfg.Iface, ifaceGroupPrefix) {
+			explicit[cfg.HostName+"/"+cfg.Iface] = true
+		}
+	}
+
+	expanded := make([]models.L3afBPFPrograms, 0, len(bpfProgs))
+	grouped := make(map[string]string)
+	for _, cfg := range bpfProgs {
+		if !strings.HasPrefix(cfg.Iface, ifaceGroupPrefix) {
+			expanded = append(expanded, cfg)
+			continue
+		}
+		name := strings.TrimPrefix(cfg.Iface, ifaceGroupPrefix)
+		var ifaces []string
+		if c.hostConfig != nil {
+			ifaces = c.hostConfig.IfaceGroups[name]
+		}
+		if len(ifaces) == 0 {
+			return nil, fmt.Errorf("unknown interface group %s", name)
+		}
+		data, err := json.Marshal(cfg.BpfPrograms)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy programs of interface group %s: %w", name, err)
+		}
+		for _, iface := range ifaces {
+			key := cfg.HostName + "/" + iface
+			if explicit[key] {
+				continue
+			}
+			if other, ok := grouped[key]; ok {
+				return nil, fmt.Errorf("interface %s of host %s is of interface groups %s and %s", iface, cfg.HostName, other, name)
+			}
+			grouped[key] = name
+			// each interface gets its own copy of the programs of the group
+			var progs *models.BPFPrograms
+			if err := json.Unmarshal(data, &progs); err != nil {
+				return nil, fmt.Errorf("failed to copy programs of interface group %s: %w", name, err)
+			}
+			cfg.Iface = iface
+			cfg.BpfPrograms = progs
+			expanded = append(expanded, cfg)
+		}
+	}
+	return expanded, nil
+}
+
+// ExpandChainTemplates - programs of the configs referencing a chain template of l3afd.cfg. The programs of the
+// config override the programs of the template of the same name and are added to the template otherwise.
+// The template files are read at each apply so the edits of a template apply to all its interfaces.
+func (c *NFConfigs) ExpandChainTemplates(bpfProgs []models.L3afBPFPrograms) ([]models.L3afBPFPrograms, error) {
+	templates := make(map[string][]byte)
+	expanded := make([]models.L3afBPFPrograms, 0, len(bpfProgs))
+	for _, cfg := range bpfProgs {
+		if len(cfg.Template) == 0 {
+			expanded = append(expanded, cfg)
+			continue
+		}
+		data, ok := templates[cfg.Template]
+		if !ok {
+			var err error
+			if data, err = c.readChainTemplate(cfg.Template); err != nil {
+				return nil, err
+			}
+			templates[cfg.Template] = data
+		}
+		// each config gets its own copy of the programs of the template
+		progs := &models.BPFPrograms{}
+		if err := json.Unmarshal(data, progs); err != nil {
+			return nil, fmt.Errorf("failed to parse chain template %s: %w", cfg.Template, err)
+		}
+		if cfg.BpfPrograms != nil {
+			progs.XDPIngress = overrideTemplatePrograms(progs.XDPIngress, cfg.BpfPrograms.XDPIngress)
+			progs.TCIngress = overrideTemplatePrograms(progs.TCIngress, cfg.BpfPrograms.TCIngress)
+			progs.TCEgress = overrideTemplatePrograms(progs.TCEgress, cfg.BpfPrograms.TCEgress)
+		}
+		cfg.BpfPrograms = progs
+		cfg.Template = ""
+		expanded = append(expanded, cfg)
+	}
+	return expanded, nil
+}
+
+// readChainTemplate - programs file of the chain template
+func (c *NFConfigs) readChainTemplate(name string) ([]byte, error) {
+	var file string
+	if c.hostConfig != nil {
+		file = c.hostConfig.ChainTemplates[name]
+	}
+	if len(file) == 0 {
+		return nil, fmt.Errorf("unknown chain template %s", name)
+	}
+	data, err := appFS.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chain template %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// overrideTemplatePrograms - programs of the template with the programs of the config of the same name replaced,
+// the other programs of the config appended. Overrides setting no seq id keep the position of the program of the
+// template in the chain.
+func overrideTemplatePrograms(template, overrides []*models.BPFProgram) []*models.BPFProgram {
+	index := make(map[string]int, len(template))
+	for i, prog := range template {
+		if prog != nil {
+			index[prog.Name] = i
+		}
+	}
+	for _, prog := range overrides {
+		if prog == nil {
+			continue
+		}
+		if i, ok := index[prog.Name]; ok {
+			if prog.SeqID == 0 {
+				p := *prog
+				p.SeqID = template[i].SeqID
+				prog = &p
+			}
+			template[i] = prog
+			continue
+		}
+		index[prog.Name] = len(template)
+		template = append(template, prog)
+	}
+	return template
+}
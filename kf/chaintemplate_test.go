@@ -0,0 +1,88 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_ExpandIfaceGroups(t *testing.T) {
+	c := &NFConfigs{hostConfig: &config.Config{IfaceGroups: map[string][]string{
+		"ports":  {"eth0", "eth1", "eth2"},
+		"uplink": {"eth2"},
+	}}}
+	progs := &models.BPFPrograms{XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", SeqID: 1}}}
+	own := &models.BPFPrograms{}
+	got, err := c.ExpandIfaceGroups([]models.L3afBPFPrograms{
+		{HostName: "l3af-local-test", Iface: "@ports", BpfPrograms: progs},
+		{HostName: "l3af-local-test", Iface: "eth1", BpfPrograms: own},
+	})
+	if err != nil {
+		t.Fatalf("ExpandIfaceGroups() error = %v", err)
+	}
+	var ifaces []string
+	for _, cfg := range got {
+		ifaces = append(ifaces, cfg.Iface)
+	}
+	if !reflect.DeepEqual(ifaces, []string{"eth0", "eth2", "eth1"}) || got[2].BpfPrograms != own {
+		t.Errorf("ExpandIfaceGroups() ifaces = %v, want the config of eth1 taking precedence over the group", ifaces)
+	}
+	for _, cfg := range got[:2] {
+		if len(cfg.BpfPrograms.XDPIngress) != 1 || cfg.BpfPrograms.XDPIngress[0].Name != "ratelimiting" {
+			t.Errorf("ExpandIfaceGroups() %s = %+v, want the programs of the group", cfg.Iface, cfg.BpfPrograms)
+		}
+	}
+	if got[0].BpfPrograms.XDPIngress[0] == got[1].BpfPrograms.XDPIngress[0] {
+		t.Errorf("ExpandIfaceGroups() interfaces of the group share the programs")
+	}
+
+	if _, err := c.ExpandIfaceGroups([]models.L3afBPFPrograms{{HostName: "l3af-local-test", Iface: "@spine"}}); err == nil {
+		t.Errorf("ExpandIfaceGroups() error = nil, want the unknown group rejected")
+	}
+	if _, err := c.ExpandIfaceGroups([]models.L3afBPFPrograms{
+		{HostName: "l3af-local-test", Iface: "@ports"},
+		{HostName: "l3af-local-test", Iface: "@uplink"},
+	}); err == nil {
+		t.Errorf("ExpandIfaceGroups() error = nil, want eth2 of two groups rejected")
+	}
+}
+
+func TestNFConfigs_ExpandChainTemplates(t *testing.T) {
+	useMemFS(t, map[string]string{"/etc/l3afd/templates/edge.json": `{"xdp_ingress": [
+		{"name": "ratelimiting", "seq_id": 1, "version": "1.0", "admin_status": "enabled"},
+		{"name": "connection-limit", "seq_id": 2, "version": "1.0", "admin_status": "enabled"}
+	]}`})
+	c := &NFConfigs{hostConfig: &config.Config{ChainTemplates: map[string]string{"edge": "/etc/l3afd/templates/edge.json"}}}
+
+	got, err := c.ExpandChainTemplates([]models.L3afBPFPrograms{
+		{HostName: "l3af-local-test", Iface: "eth0", Template: "edge"},
+		{HostName: "l3af-local-test", Iface: "eth1", Template: "edge", BpfPrograms: &models.BPFPrograms{
+			XDPIngress: []*models.BPFProgram{
+				{Name: "ratelimiting", Version: "2.0", AdminStatus: models.Enabled},
+				{Name: "ipfix-flow-exporter", SeqID: 3, Version: "1.0", AdminStatus: models.Enabled},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("ExpandChainTemplates() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Template != "" || len(got[0].BpfPrograms.XDPIngress) != 2 {
+		t.Fatalf("ExpandChainTemplates() = %+v, want the programs of the template", got)
+	}
+	eth1 := got[1].BpfPrograms.XDPIngress
+	if len(eth1) != 3 || eth1[0].Version != "2.0" || eth1[0].SeqID != 1 || eth1[2].Name != "ipfix-flow-exporter" {
+		t.Errorf("ExpandChainTemplates() eth1 = %+v, want ratelimiting overridden in place and the exporter added", eth1)
+	}
+	if got[0].BpfPrograms.XDPIngress[0] == eth1[0] || got[0].BpfPrograms.XDPIngress[0].Version != "1.0" {
+		t.Errorf("ExpandChainTemplates() eth0 shares the programs of eth1")
+	}
+
+	if _, err := c.ExpandChainTemplates([]models.L3afBPFPrograms{{Iface: "eth0", Template: "core"}}); err == nil {
+		t.Errorf("ExpandChainTemplates() error = nil, want the unknown template rejected")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Container modes of l3afd.cfg
+const (
+	ContainerModeAuto     = "auto"
+	ContainerModeEnabled  = "enabled"
+	ContainerModeDisabled = "disabled"
+)
+
+// bpffsPath - mount point of the bpf filesystem, bind mounted from the host in a container
+const bpffsPath = "/sys/fs/bpf"
+
+// inContainer - l3afd runs in a container, the bpf filesystem is expected from the host instead of being
+// mounted by l3afd
+var inContainer bool
+
+// containerCgroupMarkers - cgroup paths of the processes of the container runtimes
+var containerCgroupMarkers = []string{"docker", "kubepods", "containerd", "libpod", "lxc"}
+
+// detectContainer - container runtimes leave a marker file, the container environment variable or the
+// cgroup path of the init process
+func detectContainer() bool {
+	if len(os.Getenv("container")) > 0 {
+		return true
+	}
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := appFS.Stat(marker); err == nil {
+			return true
+		}
+	}
+	cgroup, err := appFS.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, marker := range containerCgroupMarkers {
+		if strings.Contains(string(cgroup), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// containerMode - l3afd runs in a container by the mode of the config, the container is detected in the auto
+// mode
+func containerMode(conf *config.Config) (bool, error) {
+	if conf == nil {
+		return false, nil
+	}
+	switch conf.ContainerMode {
+	case "", ContainerModeAuto:
+		return detectContainer(), nil
+	case ContainerModeEnabled:
+		return true, nil
+	case ContainerModeDisabled:
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown container mode %q, expected %s, %s or %s", conf.ContainerMode, ContainerModeAuto, ContainerModeEnabled, ContainerModeDisabled)
+}
+
+// bpffsMounted - bpf filesystem is mounted on /sys/fs/bpf
+func bpffsMounted() (bool, error) {
+	mounts, err := appFS.ReadFile("/proc/mounts")
+	if err != nil {
+		return false, fmt.Errorf("failed to read mounts: %w", err)
+	}
+	for _, line := range strings.Split(string(mounts), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[1] == bpffsPath && fields[2] == "bpf" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setContainerMode - verifies the mounts, capabilities and network namespace l3afd requires from the host
+// when it runs in a container, l3afd fails fast with the guidance instead of failing on the first program
+func setContainerMode(conf *config.Config) error {
+	var err error
+	if inContainer, err = containerMode(conf); err != nil || !inContainer {
+		return err
+	}
+	log.Info().Msg("l3afd runs in a container, external instances of the programs are searched in the pid namespace of the container only")
+
+	mounted, err := bpffsMounted()
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		return fmt.Errorf("bpf filesystem of the host is not mounted on %s, run the container with -v %s:%s or a hostPath volume of the pod",
+			bpffsPath, bpffsPath, bpffsPath)
+	}
+	if caps := checkCapabilities(); caps.Status == models.DoctorFail {
+		return fmt.Errorf("capabilities of the container: %s, run the container with --cap-add NET_ADMIN --cap-add SYS_ADMIN or --privileged",
+			caps.Message)
+	}
+	if len(conf.ContainerHostNetNSPath) > 0 {
+		same, err := sameNetNS(conf.ContainerHostNetNSPath)
+		if err != nil {
+			return fmt.Errorf("failed to verify the network namespace of the host: %w", err)
+		}
+		if !same {
+			return fmt.Errorf("l3afd is not in the network namespace of the host %s, run the container with --network host or hostNetwork of the pod",
+				conf.ContainerHostNetNSPath)
+		}
+	}
+	return nil
+}
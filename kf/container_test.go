@@ -0,0 +1,67 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+func TestDetectContainer(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{name: "docker", files: map[string]string{"/.dockerenv": ""}, want: true},
+		{name: "podman", files: map[string]string{"/run/.containerenv": ""}, want: true},
+		{name: "kubernetes", files: map[string]string{"/proc/1/cgroup": "0::/kubepods/besteffort/pod1234/abcd\n"}, want: true},
+		{name: "host", files: map[string]string{"/proc/1/cgroup": "0::/init.scope\n"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t, tt.files)
+			if got := detectContainer(); got != tt.want {
+				t.Errorf("detectContainer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetContainerMode(t *testing.T) {
+	t.Cleanup(func() { inContainer = false })
+	conf := &config.Config{ContainerMode: ContainerModeEnabled}
+
+	useMemFS(t, map[string]string{
+		"/proc/mounts":      "overlay / overlay rw 0 0\n",
+		"/proc/self/status": "CapEff:\t000001ffffffffff\n",
+	})
+	err := setContainerMode(conf)
+	if err == nil || !strings.Contains(err.Error(), "-v /sys/fs/bpf:/sys/fs/bpf") {
+		t.Errorf("setContainerMode() error = %v, want the bpffs mount guidance", err)
+	}
+
+	useMemFS(t, map[string]string{
+		"/proc/mounts":      "bpf /sys/fs/bpf bpf rw,relatime 0 0\n",
+		"/proc/self/status": "CapEff:\t0000000000001000\n",
+	})
+	if err := setContainerMode(conf); err == nil || !strings.Contains(err.Error(), "--cap-add") {
+		t.Errorf("setContainerMode() error = %v, want the capabilities guidance", err)
+	}
+
+	useMemFS(t, map[string]string{
+		"/proc/mounts":      "bpf /sys/fs/bpf bpf rw,relatime 0 0\n",
+		"/proc/self/status": "CapEff:\t000001ffffffffff\n",
+	})
+	if err := setContainerMode(conf); err != nil {
+		t.Errorf("setContainerMode() error = %v with the host mounts", err)
+	}
+
+	conf.ContainerMode = "sometimes"
+	if err := setContainerMode(conf); err == nil {
+		t.Errorf("setContainerMode() accepted an unknown mode")
+	}
+}
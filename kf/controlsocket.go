@@ -0,0 +1,195 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// controlMapName - name of the fd of the prog map of the previous program in the chain
+const controlMapName = "map-name"
+
+// controlMap - map or program passed to the program over its control socket, named by the arg of the consumed
+// map or the name of the passed fd
+type controlMap struct {
+	name    string
+	kind    string // map or program, map when empty
+	pinPath string
+}
+
+// controlServer - control socket of a program of the arg schema version 3. The program gets its config and the
+// map fds when it connects, and the config updated without a restart on the open connections.
+type controlServer struct {
+	path     string
+	listener net.Listener
+	maps     []controlMap
+
+	mu    sync.Mutex
+	msg   models.L3afDControlMessage
+	conns map[*net.UnixConn]bool
+}
+
+// controlSocketPath - control socket of the program on the interface direction
+func controlSocketPath(ifaceName, direction, name string) string {
+	return filepath.Join(nfCmdConfig.controlDir, ifaceName+"-"+direction+"-"+name+".sock")
+}
+
+// startControlServer - listens on the control socket of the implicit args, the socket left by a previous run is
+// removed and the socket is accessible by root only
+func startControlServer(implicit models.L3afDNFImplicitArgs, prog models.BPFProgram, maps []controlMap) (*controlServer, error) {
+	path := implicit.ControlSocket
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dir of control socket %s: %w", path, err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale control socket %s: %w", path, err)
+	}
+	l, err := listenControlSocket(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set permissions of control socket %s: %w", path, err)
+	}
+
+	if defaults := nfCmdConfig.directionArgs[implicit.Direction]; len(defaults) > 0 {
+		implicit.DefaultArgs = defaults
+	}
+	s := &controlServer{
+		path:     path,
+		listener: l,
+		maps:     maps,
+		msg:      models.L3afDControlMessage{Type: models.ControlMessageConfig, Args: implicit, Program: prog},
+		conns:    make(map[*net.UnixConn]bool),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// serve - sends the config and the map fds to the connecting programs until the socket is closed
+func (s *controlServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		uc, ok := conn.(*net.UnixConn)
+		if !ok {
+			conn.Close()
+			continue
+		}
+
+		s.mu.Lock()
+		msg := s.msg
+		msg.Type = models.ControlMessageConfig
+		if err := sendControlMessage(uc, msg, s.maps); err != nil {
+			log.Warn().Err(err).Msgf("failed to send config of program %s on control socket %s", msg.Program.Name, s.path)
+			uc.Close()
+		} else {
+			s.conns[uc] = true
+		}
+		s.mu.Unlock()
+	}
+}
+
+// sendControlMessage - sends the message with the fds of the maps, the fds are closed once sent
+func sendControlMessage(conn *net.UnixConn, msg models.L3afDControlMessage, maps []controlMap) error {
+	files := make([]*os.File, 0, len(maps))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	fds := make([]int, 0, len(maps))
+	msg.MapFDs = nil
+	for _, m := range maps {
+		f, err := pinnedObjectFile(m.kind, m.pinPath)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		fds = append(fds, int(f.Fd()))
+		msg.MapFDs = append(msg.MapFDs, m.name)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal control message: %w", err)
+	}
+	var oob []byte
+	if len(fds) > 0 {
+		oob = controlRights(fds)
+	}
+	if _, _, err := conn.WriteMsgUnix(data, oob, nil); err != nil {
+		return fmt.Errorf("failed to write control message: %w", err)
+	}
+	return nil
+}
+
+// update - sends the config of the program updated without a restart to the connected programs, the
+// connections failing the write are closed
+func (s *controlServer) update(prog models.BPFProgram) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.msg.Program = prog
+	msg := s.msg
+	msg.Type = models.ControlMessageUpdate
+	for conn := range s.conns {
+		if err := sendControlMessage(conn, msg, nil); err != nil {
+			log.Warn().Err(err).Msgf("closing control connection of program %s", prog.Name)
+			conn.Close()
+			delete(s.conns, conn)
+		}
+	}
+}
+
+// setCommand - command of the implicit args sent to the programs connecting from now on
+func (s *controlServer) setCommand(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msg.Args.Command = command
+}
+
+// close - closes the control socket and the connections of the programs
+func (s *controlServer) close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Msgf("failed to remove control socket %s", s.path)
+	}
+}
+
+// updateControl - sends the config of the program updated without a restart over its control socket
+func (b *BPF) updateControl() {
+	if b.control != nil {
+		b.control.update(b.Program)
+	}
+}
+
+// closeControl - closes the control socket of the program
+func (b *BPF) closeControl() {
+	if b.control != nil {
+		b.control.close()
+		b.control = nil
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+//
+//go:build !WINDOWS
+// +build !WINDOWS
+
+package kf
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// readControlMessage - reads the control message and the fds passed with it
+func readControlMessage(t *testing.T, conn *net.UnixConn) (models.L3afDControlMessage, []int) {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, syscall.CmsgSpace(4*4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("ReadMsgUnix() error = %v", err)
+	}
+	var msg models.L3afDControlMessage
+	if err := json.Unmarshal(buf[:n], &msg); err != nil {
+		t.Fatalf("invalid control message %s: %v", buf[:n], err)
+	}
+	var fds []int
+	if oobn > 0 {
+		scms, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, scm := range scms {
+			rights, err := syscall.ParseUnixRights(&scm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fds = append(fds, rights...)
+		}
+	}
+	return msg, fds
+}
+
+func Test_controlServer(t *testing.T) {
+	dir := t.TempDir()
+	savedCmdConfig := nfCmdConfig
+	nfCmdConfig.controlDir = filepath.Join(dir, "nf")
+	nfCmdConfig.directionArgs = map[string]map[string]string{models.XDPIngressType: {"xdp-mode": "native"}}
+	t.Cleanup(func() { nfCmdConfig = savedCmdConfig })
+
+	// regular files stand in for the pinned maps
+	savedPinnedObjectFile := pinnedObjectFile
+	pinnedObjectFile = func(kind, pinPath string) (*os.File, error) { return os.Open(pinPath) }
+	defer func() { pinnedObjectFile = savedPinnedObjectFile }()
+	prevMap := filepath.Join(dir, "xdp_root_array")
+	counters := filepath.Join(dir, "counters")
+	for _, f := range []string{prevMap, counters} {
+		if err := os.WriteFile(f, []byte(filepath.Base(f)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", ArgSchemaVersion: ArgSchemaV3, Rules: "10.0.0.1"}}
+	implicit := b.implicitArgs("start", "eth0", models.XDPIngressType)
+	implicit.ControlSocket = controlSocketPath("eth0", models.XDPIngressType, b.Program.Name)
+	if want := []string{"--control-socket=" + implicit.ControlSocket}; !reflect.DeepEqual(implicitArgFlags(implicit), want) {
+		t.Errorf("implicitArgFlags() = %v, want %v", implicitArgFlags(implicit), want)
+	}
+
+	maps := []controlMap{{name: controlMapName, pinPath: prevMap}, {name: "counters-map", pinPath: counters}}
+	control, err := startControlServer(implicit, b.Program, maps)
+	if err != nil {
+		t.Fatalf("startControlServer() error = %v", err)
+	}
+	b.control = control
+	if fi, err := os.Stat(implicit.ControlSocket); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("control socket %v error = %v, want 0600", fi, err)
+	}
+
+	conn, err := net.DialUnix("unixpacket", nil, &net.UnixAddr{Name: implicit.ControlSocket, Net: "unixpacket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	msg, fds := readControlMessage(t, conn)
+	if msg.Type != models.ControlMessageConfig || msg.Args.Command != "start" || msg.Args.DefaultArgs["xdp-mode"] != "native" || msg.Program.Rules != "10.0.0.1" {
+		t.Errorf("config message = %+v", msg)
+	}
+	if want := []string{controlMapName, "counters-map"}; !reflect.DeepEqual(msg.MapFDs, want) || len(fds) != 2 {
+		t.Fatalf("map fds %v %v, want %v", msg.MapFDs, fds, want)
+	}
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), msg.MapFDs[i])
+		buf := make([]byte, 64)
+		n, _ := f.Read(buf)
+		f.Close()
+		if want := filepath.Base(maps[i].pinPath); string(buf[:n]) != want {
+			t.Errorf("fd %s reads %q, want %q", msg.MapFDs[i], buf[:n], want)
+		}
+	}
+
+	b.Program.Rules = "10.0.0.1,10.0.0.2"
+	b.updateControl()
+	msg, fds = readControlMessage(t, conn)
+	if msg.Type != models.ControlMessageUpdate || msg.Program.Rules != "10.0.0.1,10.0.0.2" || len(fds) != 0 || len(msg.MapFDs) != 0 {
+		t.Errorf("update message = %+v fds %v", msg, fds)
+	}
+
+	b.closeControl()
+	if _, err := os.Stat(implicit.ControlSocket); !os.IsNotExist(err) {
+		t.Errorf("control socket is not removed, error = %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	corePatternFile  = "/proc/sys/kernel/core_pattern"
+	coreDumpTimeFmt  = "20060102T150405Z"
+	elfCoreType      = 4 // ET_CORE of the ELF header
+	elfHeaderTypeEnd = 18
+)
+
+// coreDumpsConfig - collected cores of the NF processes, disabled when dir is empty
+type coreDumpsConfig struct {
+	dir     string
+	maxSize int64
+}
+
+var coreDumps coreDumpsConfig
+
+// setCoreDumps - configures the core dump dir from l3afd.cfg, cores are kept under the BPF log dir unless the dir
+// is configured
+func setCoreDumps(conf *config.Config) {
+	if conf == nil {
+		coreDumps = coreDumpsConfig{}
+		return
+	}
+	dir := conf.CoreDumpsDir
+	if len(dir) == 0 {
+		dir = filepath.Join(conf.BPFLogDir, "cores")
+	}
+	coreDumps = coreDumpsConfig{
+		dir:     dir,
+		maxSize: int64(conf.CoreDumpsMaxSizeMB) << 20,
+	}
+}
+
+// corePattern - core_pattern of the kernel
+func corePattern() (string, error) {
+	buf, err := appFS.ReadFile(corePatternFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", corePatternFile, err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// coreFilePrefix - file name prefix of the cores written by the kernel into the working dir of the process.
+// Cores piped to a handler e.g. systemd-coredump or written to an absolute path are not collected by l3afd.
+func coreFilePrefix(pattern string) (string, bool) {
+	if len(pattern) == 0 || strings.HasPrefix(pattern, "|") || strings.Contains(pattern, "/") {
+		return "", false
+	}
+	if i := strings.IndexByte(pattern, '%'); i >= 0 {
+		pattern = pattern[:i]
+	}
+	return pattern, true
+}
+
+// prepareCoreDumps - logs where the kernel writes the cores of the program and lists its collected cores
+func (b *BPF) prepareCoreDumps(ifaceName, direction string) {
+	if !b.Program.CoreDumps || len(coreDumps.dir) == 0 {
+		return
+	}
+	pattern, err := corePattern()
+	if err != nil {
+		log.Warn().Err(err).Msgf("core dumps of program %s may not be collected", b.Program.Name)
+	} else if _, ok := coreFilePrefix(pattern); !ok {
+		log.Warn().Msgf("core_pattern %q of the kernel is not relative, cores of program %s are not collected by l3afd",
+			pattern, b.Program.Name)
+	}
+	b.CoreDumps = listCoreDumps(coreDumps.dir, ifaceName, direction, b.Program.Name)
+}
+
+// collectCoreDumps - moves the cores written into the working dir of the crashed user program into the core
+// dump dir and removes the oldest cores beyond the max size
+func (b *BPF) collectCoreDumps(ifaceName, direction string) {
+	if !b.Program.CoreDumps || len(coreDumps.dir) == 0 || b.Cmd == nil || len(b.Cmd.Dir) == 0 {
+		return
+	}
+	pattern, err := corePattern()
+	if err != nil {
+		return
+	}
+	prefix, ok := coreFilePrefix(pattern)
+	if !ok {
+		return
+	}
+	entries, err := appFS.ReadDir(b.Cmd.Dir)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to read working dir of program %s", b.Program.Name)
+		return
+	}
+
+	now := time.Now().UTC()
+	collected := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		src := filepath.Join(b.Cmd.Dir, entry.Name())
+		if !isCoreFile(src) {
+			continue
+		}
+		if err := appFS.MkdirAll(coreDumps.dir, 0750); err != nil {
+			log.Warn().Err(err).Msgf("failed to create core dump dir %s", coreDumps.dir)
+			return
+		}
+		dst := filepath.Join(coreDumps.dir, coreDumpName(now, ifaceName, direction, b.Program.Name, entry.Name()))
+		if err := appFS.Rename(src, dst); err != nil {
+			log.Warn().Err(err).Msgf("failed to collect core %s of program %s", src, b.Program.Name)
+			continue
+		}
+		log.Warn().Msgf("program %s iface %s direction %s dumped core %s", b.Program.Name, ifaceName, direction, dst)
+		collected++
+	}
+	if collected > 0 {
+		pruneCoreDumps(coreDumps.dir, coreDumps.maxSize)
+	}
+	b.CoreDumps = listCoreDumps(coreDumps.dir, ifaceName, direction, b.Program.Name)
+}
+
+// isCoreFile - file is an ELF core
+func isCoreFile(fileName string) bool {
+	f, err := appFS.Open(fileName)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	hdr := make([]byte, elfHeaderTypeEnd)
+	if _, err := io.ReadFull(f, hdr); err != nil {
+		return false
+	}
+	if string(hdr[:4]) != "\x7fELF" {
+		return false
+	}
+	// e_type is in the byte order of the ELF data encoding
+	if hdr[5] == 2 {
+		return hdr[16] == 0 && hdr[17] == elfCoreType
+	}
+	return hdr[16] == elfCoreType && hdr[17] == 0
+}
+
+// coreDumpName - collected core file name, sortable by time
+func coreDumpName(t time.Time, ifaceName, direction, name, core string) string {
+	return t.Format(coreDumpTimeFmt) + "_" + ifaceName + "_" + direction + "_" + name + "_" + core
+}
+
+// listCoreDumps - collected cores of the program, the latest first
+func listCoreDumps(dir, ifaceName, direction, name string) []string {
+	entries, err := appFS.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	program := "_" + ifaceName + "_" + direction + "_" + name + "_"
+	var cores []string
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || len(fileName) <= len(coreDumpTimeFmt) {
+			continue
+		}
+		if strings.HasPrefix(fileName[len(coreDumpTimeFmt):], program) {
+			cores = append(cores, fileName)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(cores)))
+	return cores
+}
+
+// pruneCoreDumps - removes the oldest cores until the dir fits in max bytes, 0 keeps all the cores
+func pruneCoreDumps(dir string, max int64) {
+	if max <= 0 {
+		return
+	}
+	entries, err := appFS.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() > entries[j].Name() })
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		if total <= max {
+			continue
+		}
+		if err := appFS.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			log.Warn().Err(err).Msgf("failed to remove core %s", entry.Name())
+		}
+	}
+}
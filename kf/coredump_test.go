@@ -0,0 +1,92 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// elfCore - ELF header of a little endian core followed by the payload
+func elfCore(payload string) string {
+	hdr := []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x04\x00")
+	return string(hdr) + payload
+}
+
+func Test_coreFilePrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantOk  bool
+	}{
+		{pattern: "core", want: "core", wantOk: true},
+		{pattern: "core.%p", want: "core.", wantOk: true},
+		{pattern: "%e.core", want: "", wantOk: true},
+		{pattern: "|/usr/lib/systemd/systemd-coredump %P %u", wantOk: false},
+		{pattern: "/var/crash/core.%e.%p", wantOk: false},
+		{pattern: "", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, ok := coreFilePrefix(tt.pattern)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("coreFilePrefix() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCollectCoreDumps(t *testing.T) {
+	dir := "/var/log/l3afd/cores"
+	m := useMemFS(t, map[string]string{
+		corePatternFile:                                                  "core.%p\n",
+		"/opt/l3afd/ratelimiting/core.4242":                              elfCore(strings.Repeat("x", 100)),
+		"/opt/l3afd/ratelimiting/core.go":                                "package main\n",
+		"/opt/l3afd/ratelimiting/ratelimiting":                           "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x03\x00",
+		dir + "/20240501T100000Z_eth0_xdpingress_ratelimiting_core.4000": elfCore(strings.Repeat("x", 2<<20)),
+	})
+	saved := coreDumps
+	coreDumps = coreDumpsConfig{dir: dir, maxSize: 1 << 20}
+	t.Cleanup(func() { coreDumps = saved })
+
+	cmd := exec.Command("/opt/l3afd/ratelimiting/ratelimiting")
+	cmd.Dir = "/opt/l3afd/ratelimiting"
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", CoreDumps: true}, Cmd: cmd}
+	b.collectCoreDumps("eth0", models.XDPIngressType)
+
+	if len(b.CoreDumps) != 1 || !strings.HasSuffix(b.CoreDumps[0], "_eth0_xdpingress_ratelimiting_core.4242") {
+		t.Fatalf("CoreDumps = %v, want the collected core.4242 only", b.CoreDumps)
+	}
+	if _, err := m.Stat("/opt/l3afd/ratelimiting/core.4242"); err == nil {
+		t.Errorf("core.4242 is not moved out of the working dir")
+	}
+	for _, keep := range []string{"/opt/l3afd/ratelimiting/core.go", "/opt/l3afd/ratelimiting/ratelimiting"} {
+		if _, err := m.Stat(keep); err != nil {
+			t.Errorf("%s is not a core and must be kept, error = %v", keep, err)
+		}
+	}
+
+	if got := listCoreDumps(dir, "eth0", models.IngressType, "ratelimiting"); len(got) != 0 {
+		t.Errorf("listCoreDumps() of another direction = %v, want none", got)
+	}
+}
+
+func Test_listCoreDumps(t *testing.T) {
+	dir := "/var/log/l3afd/cores"
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	first := coreDumpName(start, "eth0", models.XDPIngressType, "ratelimiting", "core")
+	second := coreDumpName(start.Add(time.Minute), "eth0", models.XDPIngressType, "ratelimiting", "core")
+	other := coreDumpName(start, "eth0", models.XDPIngressType, "ratelimiting-v2", "core")
+	useMemFS(t, map[string]string{dir + "/" + first: "", dir + "/" + second: "", dir + "/" + other: ""})
+
+	want := []string{second, first}
+	if got := listCoreDumps(dir, "eth0", models.XDPIngressType, "ratelimiting"); !reflect.DeepEqual(got, want) {
+		t.Errorf("listCoreDumps() = %v, want %v", got, want)
+	}
+}
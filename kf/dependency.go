@@ -0,0 +1,143 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// bpfProgramRef - program config along with its direction
+type bpfProgramRef struct {
+	direction string
+	prog      *models.BPFProgram
+}
+
+// ValidateDependencies - Verifies the dependencies of the enabled programs are present and enabled
+// on the same interface and there are no cyclic dependencies.
+func ValidateDependencies(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		if _, err := orderBPFPrograms(cfg.Iface, cfg.BpfPrograms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderBPFPrograms - returns the programs of an interface in the order to be applied.
+// Programs to be stopped come first in reverse dependency order i.e. dependents are stopped before their dependencies,
+// followed by the enabled programs in dependency order. Config order is preserved otherwise.
+func orderBPFPrograms(ifaceName string, bpfProgs *models.BPFPrograms) ([]bpfProgramRef, error) {
+	if bpfProgs == nil {
+		return nil, nil
+	}
+
+	refs := make([]bpfProgramRef, 0, len(bpfProgs.XDPIngress)+len(bpfProgs.TCIngress)+len(bpfProgs.TCEgress))
+	for _, prog := range bpfProgs.XDPIngress {
+		refs = append(refs, bpfProgramRef{direction: models.XDPIngressType, prog: prog})
+	}
+	for _, prog := range bpfProgs.TCIngress {
+		refs = append(refs, bpfProgramRef{direction: models.IngressType, prog: prog})
+	}
+	for _, prog := range bpfProgs.TCEgress {
+		refs = append(refs, bpfProgramRef{direction: models.EgressType, prog: prog})
+	}
+
+	// program name can be present in more than one direction
+	enabled := make(map[string]bool)
+	pending := make(map[string]int)
+	for _, ref := range refs {
+		if ref.prog == nil {
+			continue
+		}
+		pending[ref.prog.Name]++
+		if loadedStatus(ref.prog.AdminStatus) {
+			enabled[ref.prog.Name] = true
+		}
+	}
+
+	for _, ref := range refs {
+		if ref.prog == nil || !loadedStatus(ref.prog.AdminStatus) {
+			continue
+		}
+		for _, dep := range programDependencies(ref.prog) {
+			if dep == ref.prog.Name {
+				return nil, fmt.Errorf("program %s on iface %s can not depend on itself", ref.prog.Name, ifaceName)
+			}
+			if _, ok := pending[dep]; !ok {
+				return nil, fmt.Errorf("program %s on iface %s requires program %s, which is absent in the config", ref.prog.Name, ifaceName, dep)
+			}
+			if !enabled[dep] {
+				return nil, fmt.Errorf("program %s on iface %s requires program %s, which is not enabled", ref.prog.Name, ifaceName, dep)
+			}
+		}
+	}
+
+	sorted := make([]bpfProgramRef, 0, len(refs))
+	placed := make([]bool, len(refs))
+	for len(sorted) < len(refs) {
+		progress := false
+		for i, ref := range refs {
+			if placed[i] {
+				continue
+			}
+			if ref.prog != nil && !dependenciesPlaced(programDependencies(ref.prog), pending) {
+				continue
+			}
+			placed[i] = true
+			progress = true
+			sorted = append(sorted, ref)
+			if ref.prog != nil {
+				pending[ref.prog.Name]--
+			}
+		}
+		if !progress {
+			cyclic := make([]string, 0)
+			for i, ref := range refs {
+				if !placed[i] {
+					cyclic = append(cyclic, ref.prog.Name)
+				}
+			}
+			return nil, fmt.Errorf("cyclic dependencies between programs %s on iface %s", strings.Join(cyclic, ","), ifaceName)
+		}
+	}
+
+	ordered := make([]bpfProgramRef, 0, len(sorted))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if sorted[i].prog != nil && !loadedStatus(sorted[i].prog.AdminStatus) {
+			ordered = append(ordered, sorted[i])
+		}
+	}
+	for _, ref := range sorted {
+		if ref.prog == nil || loadedStatus(ref.prog.AdminStatus) {
+			ordered = append(ordered, ref)
+		}
+	}
+	return ordered, nil
+}
+
+// programDependencies - programs required by the program, producers of the consumed maps are implicit dependencies
+func programDependencies(prog *models.BPFProgram) []string {
+	if len(prog.ConsumedMaps) == 0 {
+		return prog.Dependencies
+	}
+	deps := make([]string, 0, len(prog.Dependencies)+len(prog.ConsumedMaps))
+	deps = append(deps, prog.Dependencies...)
+	for _, cm := range prog.ConsumedMaps {
+		deps = append(deps, cm.Program)
+	}
+	return deps
+}
+
+// dependenciesPlaced - all instances of the dependencies are already ordered
+func dependenciesPlaced(dependencies []string, pending map[string]int) bool {
+	for _, dep := range dependencies {
+		if pending[dep] > 0 {
+			return false
+		}
+	}
+	return true
+}
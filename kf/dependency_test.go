@@ -0,0 +1,110 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_orderBPFPrograms(t *testing.T) {
+	tests := []struct {
+		name     string
+		bpfProgs *models.BPFPrograms
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "NilPrograms",
+			bpfProgs: nil,
+			want:     []string{},
+			wantErr:  false,
+		},
+		{
+			name: "NoDependencies",
+			bpfProgs: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", AdminStatus: models.Enabled}},
+				TCIngress:  []*models.BPFProgram{{Name: "connection-tracker", AdminStatus: models.Enabled}},
+			},
+			want:    []string{"ratelimiting", "connection-tracker"},
+			wantErr: false,
+		},
+		{
+			name: "DependencyStartedFirst",
+			bpfProgs: &models.BPFPrograms{
+				TCIngress: []*models.BPFProgram{{Name: "flow-exporter", AdminStatus: models.Enabled, Dependencies: []string{"connection-tracker"}}},
+				TCEgress:  []*models.BPFProgram{{Name: "connection-tracker", AdminStatus: models.Enabled}},
+			},
+			want:    []string{"connection-tracker", "flow-exporter"},
+			wantErr: false,
+		},
+		{
+			name: "DependentStoppedFirst",
+			bpfProgs: &models.BPFPrograms{
+				TCIngress: []*models.BPFProgram{{Name: "connection-tracker", AdminStatus: models.Disabled}},
+				TCEgress:  []*models.BPFProgram{{Name: "flow-exporter", AdminStatus: models.Disabled, Dependencies: []string{"connection-tracker"}}},
+			},
+			want:    []string{"flow-exporter", "connection-tracker"},
+			wantErr: false,
+		},
+		{
+			name: "ConsumedMapProducerStartedFirst",
+			bpfProgs: &models.BPFPrograms{
+				TCIngress: []*models.BPFProgram{{Name: "flow-exporter", AdminStatus: models.Enabled,
+					ConsumedMaps: []models.L3afDNFConsumedMap{{Name: "flows", Program: "connection-tracker", Arg: "flows-map"}}}},
+				TCEgress: []*models.BPFProgram{{Name: "connection-tracker", AdminStatus: models.Enabled}},
+			},
+			want:    []string{"connection-tracker", "flow-exporter"},
+			wantErr: false,
+		},
+		{
+			name: "AbsentDependency",
+			bpfProgs: &models.BPFPrograms{
+				TCIngress: []*models.BPFProgram{{Name: "flow-exporter", AdminStatus: models.Enabled, Dependencies: []string{"connection-tracker"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "DisabledDependency",
+			bpfProgs: &models.BPFPrograms{
+				TCIngress: []*models.BPFProgram{
+					{Name: "flow-exporter", AdminStatus: models.Enabled, Dependencies: []string{"connection-tracker"}},
+					{Name: "connection-tracker", AdminStatus: models.Disabled},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "CyclicDependencies",
+			bpfProgs: &models.BPFPrograms{
+				TCIngress: []*models.BPFProgram{
+					{Name: "flow-exporter", AdminStatus: models.Enabled, Dependencies: []string{"connection-tracker"}},
+					{Name: "connection-tracker", AdminStatus: models.Enabled, Dependencies: []string{"flow-exporter"}},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := orderBPFPrograms("dummy", tt.bpfProgs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("orderBPFPrograms() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			names := make([]string, 0, len(got))
+			for _, ref := range got {
+				names = append(names, ref.prog.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("orderBPFPrograms() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
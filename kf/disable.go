@@ -0,0 +1,307 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// maxPreservedMapEntries - entries of a preserved map kept while the program is disabled
+const maxPreservedMapEntries = 1 << 16
+
+// disabledProgram - program disabled in the config, its config and the entries of its preserved maps are
+// kept so enabling it again starts it from the cached artifact with the state of its maps
+type disabledProgram struct {
+	Iface     string                            `json:"iface"`
+	Direction string                            `json:"direction"`
+	Program   models.BPFProgram                 `json:"program"`
+	Maps      map[string][]models.L3afDMapEntry `json:"maps,omitempty"` // entries of the preserved maps when the program was stopped
+}
+
+// disabledRegistry - disabled programs kept across config applies and restarts
+type disabledRegistry struct {
+	mu       sync.Mutex
+	programs map[string]*disabledProgram // key is iface/direction/program
+}
+
+var disabledPrograms = &disabledRegistry{programs: make(map[string]*disabledProgram)}
+
+func (r *disabledRegistry) get(iface, direction, name string) (*disabledProgram, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.programs[pendingApplyKey(iface, direction, name)]
+	return p, ok
+}
+
+// keep - keeps the config of the disabled program, the map entries kept when it was stopped are not replaced
+// unless maps is set
+func (r *disabledRegistry) keep(iface, direction string, prog *models.BPFProgram, maps map[string][]models.L3afDMapEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := pendingApplyKey(iface, direction, prog.Name)
+	p, ok := r.programs[key]
+	if !ok {
+		p = &disabledProgram{Iface: iface, Direction: direction}
+		r.programs[key] = p
+	}
+	p.Program = *prog
+	p.Program.AdminStatus = models.Disabled
+	if maps != nil {
+		p.Maps = maps
+	}
+}
+
+func (r *disabledRegistry) delete(iface, direction, name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := pendingApplyKey(iface, direction, name)
+	_, ok := r.programs[key]
+	delete(r.programs, key)
+	return ok
+}
+
+// list - disabled programs sorted by interface, direction and name
+func (r *disabledRegistry) list() []*disabledProgram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	progs := make([]*disabledProgram, 0, len(r.programs))
+	for _, p := range r.programs {
+		progs = append(progs, p)
+	}
+	sort.Slice(progs, func(i, j int) bool {
+		return pendingApplyKey(progs[i].Iface, progs[i].Direction, progs[i].Program.Name) <
+			pendingApplyKey(progs[j].Iface, progs[j].Direction, progs[j].Program.Name)
+	})
+	return progs
+}
+
+// disabledBPFPrograms - configs of the disabled programs of the interface in the direction
+func disabledBPFPrograms(iface, direction string) []*models.BPFProgram {
+	var progs []*models.BPFProgram
+	for _, p := range disabledPrograms.list() {
+		if p.Iface == iface && p.Direction == direction {
+			prog := p.Program
+			progs = append(progs, &prog)
+		}
+	}
+	sort.SliceStable(progs, func(i, j int) bool { return progs[i].SeqID < progs[j].SeqID })
+	return progs
+}
+
+// keepDisabledBPFProgram - keeps the config of the program disabled in the config, the program is not running
+func (c *NFConfigs) keepDisabledBPFProgram(bpfProg *models.BPFProgram, iface, direction string) {
+	if _, err := c.findBPF(iface, direction, bpfProg.Name); err == nil {
+		// running program is stopped by VerifyNUpdateBPFProgram, which keeps its maps
+		return
+	}
+	disabledPrograms.keep(iface, direction, bpfProg, nil)
+	c.saveDisabledPrograms()
+}
+
+// disableBPFProgram - keeps the config of the running program being disabled along with the entries of its
+// preserved maps, called before the program is stopped
+func (c *NFConfigs) disableBPFProgram(bpf *BPF, bpfProg *models.BPFProgram, iface, direction string) {
+	maps := make(map[string][]models.L3afDMapEntry)
+	for _, name := range bpfProg.PreserveMaps {
+		entries, err := snapshotProgramMap(bpf, name)
+		if err != nil {
+			log.Warn().Err(err).Msgf("map %s of disabled program %s is not preserved", name, bpf.Program.Name)
+			continue
+		}
+		maps[name] = entries
+		log.Info().Msgf("%d entries of map %s of disabled program %s preserved", len(entries), name, bpf.Program.Name)
+	}
+	disabledPrograms.keep(iface, direction, bpfProg, maps)
+	c.saveDisabledPrograms()
+}
+
+// enableBPFProgram - marks the start of the disabled program, the cached artifact is used without the
+// freshness check when the version is unchanged. Returns the kept program.
+func enableBPFProgram(bpf *BPF, iface, direction string) *disabledProgram {
+	p, ok := disabledPrograms.get(iface, direction, bpf.Program.Name)
+	if !ok {
+		return nil
+	}
+	bpf.keptArtifact = p.Program.Version == bpf.Program.Version
+	return p
+}
+
+// restoreDisabledBPFProgram - writes the preserved map entries of the enabled program back to its maps when the
+// version is unchanged, and forgets the disabled program
+func (c *NFConfigs) restoreDisabledBPFProgram(bpf *BPF, p *disabledProgram, iface, direction string) {
+	if p.Program.Version != bpf.Program.Version && len(p.Maps) > 0 {
+		log.Warn().Msgf("preserved maps of program %s version %s are not restored to version %s", bpf.Program.Name, p.Program.Version, bpf.Program.Version)
+	} else {
+		names := make([]string, 0, len(p.Maps))
+		for name := range p.Maps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := restoreProgramMap(bpf, name, p.Maps[name]); err != nil {
+				log.Warn().Err(err).Msgf("preserved map %s of program %s is not restored", name, bpf.Program.Name)
+				continue
+			}
+			log.Info().Msgf("%d preserved entries of map %s of program %s restored", len(p.Maps[name]), name, bpf.Program.Name)
+		}
+	}
+	disabledPrograms.delete(iface, direction, bpf.Program.Name)
+	c.saveDisabledPrograms()
+}
+
+// snapshotProgramMap - entries of the map of the running program
+func snapshotProgramMap(bpf *BPF, name string) ([]models.L3afDMapEntry, error) {
+	m, err := openProgramMap(bpf, name)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	return snapshotMap(m)
+}
+
+// snapshotMap - entries of the map in hex, per-CPU and fd maps are not supported
+func snapshotMap(m ebpfMap) ([]models.L3afDMapEntry, error) {
+	info, err := m.Info()
+	if err != nil {
+		return nil, fmt.Errorf("fetching map info failed %v", err)
+	}
+	switch info.Type {
+	case ebpf.Hash, ebpf.LRUHash, ebpf.Array, ebpf.LPMTrie:
+	default:
+		return nil, fmt.Errorf("map type %s is not supported", info.Type)
+	}
+
+	entries := make([]models.L3afDMapEntry, 0)
+	var cur interface{}
+	for {
+		var key []byte
+		if err := m.NextKey(cur, &key); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("map iteration failed %v", err)
+		}
+		cur = key
+		var value []byte
+		if err := m.Lookup(key, &value); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("map lookup failed %v", err)
+		}
+		if len(entries) == maxPreservedMapEntries {
+			return nil, fmt.Errorf("map has more than %d entries", maxPreservedMapEntries)
+		}
+		entries = append(entries, models.L3afDMapEntry{Key: hex.EncodeToString(key), Value: hex.EncodeToString(value)})
+	}
+}
+
+// restoreProgramMap - writes the entries to the map of the started program
+func restoreProgramMap(bpf *BPF, name string, entries []models.L3afDMapEntry) error {
+	m, err := openProgramMap(bpf, name)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return restoreMap(m, entries)
+}
+
+// restoreMap - writes the hex entries to the map, all the entries are decoded before the map is changed
+func restoreMap(m ebpfMap, entries []models.L3afDMapEntry) error {
+	info, err := m.Info()
+	if err != nil {
+		return fmt.Errorf("fetching map info failed %v", err)
+	}
+	keys, values := make([][]byte, 0, len(entries)), make([][]byte, 0, len(entries))
+	for _, e := range entries {
+		key, err := hex.DecodeString(e.Key)
+		if err != nil || len(key) != int(info.KeySize) {
+			return fmt.Errorf("preserved key %s does not match map key size %d", e.Key, info.KeySize)
+		}
+		value, err := hex.DecodeString(e.Value)
+		if err != nil || len(value) != int(info.ValueSize) {
+			return fmt.Errorf("preserved value %s does not match map value size %d", e.Value, info.ValueSize)
+		}
+		keys, values = append(keys, key), append(values, value)
+	}
+	for i, key := range keys {
+		if err := m.Update(key, values[i], ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("failed to restore key %x: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// removeMissingDisabledPrograms - disabled programs missing in the config are forgotten
+func (c *NFConfigs) removeMissingDisabledPrograms(bpfProgCfgs []models.L3afBPFPrograms) {
+	inConfig := make(map[string]bool)
+	for _, cfg := range bpfProgCfgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog != nil && ref.prog.AdminStatus == models.Disabled {
+				inConfig[pendingApplyKey(cfg.Iface, ref.direction, ref.prog.Name)] = true
+			}
+		}
+	}
+
+	removed := false
+	for _, p := range disabledPrograms.list() {
+		if !inConfig[pendingApplyKey(p.Iface, p.Direction, p.Program.Name)] {
+			log.Info().Msgf("disabled program %s not found in config on iface %s direction %s", p.Program.Name, p.Iface, p.Direction)
+			disabledPrograms.delete(p.Iface, p.Direction, p.Program.Name)
+			removed = true
+		}
+	}
+	if removed {
+		c.saveDisabledPrograms()
+	}
+}
+
+// saveDisabledPrograms - writes the disabled programs to the persistent store
+func (c *NFConfigs) saveDisabledPrograms() {
+	if c.hostConfig == nil || len(c.hostConfig.DisabledProgramsFileName) == 0 {
+		return
+	}
+	buf, err := json.Marshal(disabledPrograms.list())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal disabled programs")
+		return
+	}
+	if err := writeStateFile(c.hostConfig.DisabledProgramsFileName, buf, 0600); err != nil {
+		log.Error().Err(err).Msgf("failed to write disabled programs to %s", c.hostConfig.DisabledProgramsFileName)
+	}
+}
+
+// loadDisabledPrograms - reads the disabled programs of the persistent store, so the preserved maps survive
+// restarts of l3afd
+func (c *NFConfigs) loadDisabledPrograms() {
+	if c.hostConfig == nil || len(c.hostConfig.DisabledProgramsFileName) == 0 {
+		return
+	}
+	buf, err := ReadStateFile(c.hostConfig.DisabledProgramsFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msgf("failed to read disabled programs from %s", c.hostConfig.DisabledProgramsFileName)
+		}
+		return
+	}
+	var progs []*disabledProgram
+	if err := json.Unmarshal(buf, &progs); err != nil {
+		log.Error().Err(err).Msgf("failed to unmarshal disabled programs of %s", c.hostConfig.DisabledProgramsFileName)
+		return
+	}
+	for _, p := range progs {
+		disabledPrograms.keep(p.Iface, p.Direction, &p.Program, p.Maps)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_snapshotMap(t *testing.T) {
+	m := &fakeHashMap{mapType: ebpf.Hash, entries: map[string][]byte{
+		string([]byte{0x00, 0x50}): {1},
+		string([]byte{0x01, 0xbb}): {2},
+	}}
+	entries, err := snapshotMap(m)
+	if err != nil {
+		t.Fatalf("snapshotMap() error = %v", err)
+	}
+	want := []models.L3afDMapEntry{{Key: "0050", Value: "01"}, {Key: "01bb", Value: "02"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("snapshotMap() = %v, want %v", entries, want)
+	}
+
+	restored := &fakeHashMap{mapType: ebpf.Hash, entries: make(map[string][]byte)}
+	if err := restoreMap(restored, entries); err != nil {
+		t.Fatalf("restoreMap() error = %v", err)
+	}
+	if !reflect.DeepEqual(restored.entries, m.entries) {
+		t.Errorf("restored entries = %v, want %v", restored.entries, m.entries)
+	}
+
+	// entries of another layout leave the map unchanged
+	empty := &fakeHashMap{mapType: ebpf.Hash, entries: make(map[string][]byte)}
+	if err := restoreMap(empty, []models.L3afDMapEntry{{Key: "0050", Value: "01"}, {Key: "005000", Value: "01"}}); err == nil {
+		t.Errorf("restoreMap() error = nil, want key size mismatch")
+	}
+	if len(empty.entries) != 0 {
+		t.Errorf("map is changed by the invalid entries")
+	}
+
+	if _, err := snapshotMap(&fakeHashMap{mapType: ebpf.PerCPUHash}); err == nil {
+		t.Errorf("snapshotMap() error = nil, want per-CPU map not supported")
+	}
+}
+
+func TestDisabledPrograms(t *testing.T) {
+	useMemFS(t, map[string]string{})
+	t.Cleanup(func() { disabledPrograms = &disabledRegistry{programs: make(map[string]*disabledProgram)} })
+	conf := &config.Config{DisabledProgramsFileName: "/etc/l3afd/l3af-disabled.json"}
+	c := &NFConfigs{hostConfig: conf, IngressXDPBpfs: map[string]*list.List{}, IngressTCBpfs: map[string]*list.List{}, EgressTCBpfs: map[string]*list.List{}}
+
+	prog := &models.BPFProgram{Name: "ratelimiting", Version: "1.0", SeqID: 1, AdminStatus: models.Disabled, PreserveMaps: []string{"rl_state"}}
+	c.keepDisabledBPFProgram(prog, "eth0", models.XDPIngressType)
+	maps := map[string][]models.L3afDMapEntry{"rl_state": {{Key: "0050", Value: "01"}}}
+	disabledPrograms.keep("eth0", models.XDPIngressType, prog, maps)
+	c.saveDisabledPrograms()
+
+	// config of the disabled program stays in the config of the interface
+	got := c.EBPFPrograms("eth0").BpfPrograms.XDPIngress
+	if len(got) != 1 || got[0].Name != "ratelimiting" || got[0].AdminStatus != models.Disabled {
+		t.Fatalf("EBPFPrograms() = %+v, want the disabled program", got)
+	}
+
+	// preserved maps survive the restart
+	disabledPrograms = &disabledRegistry{programs: make(map[string]*disabledProgram)}
+	c.loadDisabledPrograms()
+	p, ok := disabledPrograms.get("eth0", models.XDPIngressType, "ratelimiting")
+	if !ok || !reflect.DeepEqual(p.Maps, maps) {
+		t.Fatalf("loaded disabled program = %+v, want maps %v", p, maps)
+	}
+
+	// config applies keep the preserved maps
+	c.keepDisabledBPFProgram(&models.BPFProgram{Name: "ratelimiting", Version: "1.0", AdminStatus: models.Disabled}, "eth0", models.XDPIngressType)
+	if p, _ := disabledPrograms.get("eth0", models.XDPIngressType, "ratelimiting"); !reflect.DeepEqual(p.Maps, maps) {
+		t.Errorf("maps = %v after config apply, want %v", p.Maps, maps)
+	}
+
+	// cached artifact of the same version is used without the freshness check
+	bpf := &BPF{Program: models.BPFProgram{Name: "ratelimiting", Version: "1.0"}}
+	if kept := enableBPFProgram(bpf, "eth0", models.XDPIngressType); kept == nil || !bpf.keptArtifact {
+		t.Errorf("enableBPFProgram() = %v keptArtifact %v, want the kept program", kept, bpf.keptArtifact)
+	}
+	upgraded := &BPF{Program: models.BPFProgram{Name: "ratelimiting", Version: "2.0"}}
+	if enableBPFProgram(upgraded, "eth0", models.XDPIngressType); upgraded.keptArtifact {
+		t.Errorf("keptArtifact of another version = true, want false")
+	}
+
+	// disabled programs missing in the config are forgotten
+	c.removeMissingDisabledPrograms([]models.L3afBPFPrograms{{Iface: "eth0", BpfPrograms: &models.BPFPrograms{}}})
+	if _, ok := disabledPrograms.get("eth0", models.XDPIngressType, "ratelimiting"); ok {
+		t.Errorf("disabled program missing in the config is kept")
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// Capabilities checked by the diagnostics
+const (
+	capNetAdmin = 12
+	capSysAdmin = 21
+	capPerfmon  = 38
+	capBPF      = 39
+)
+
+// memlockUnlimited - soft limit reported for an unlimited RLIMIT_MEMLOCK
+const memlockUnlimited = ^uint64(0)
+
+// doctorStatusRank - severity order of the check statuses
+var doctorStatusRank = map[string]int{models.DoctorPass: 0, models.DoctorWarn: 1, models.DoctorFail: 2}
+
+// RunDoctor - runs the preflight diagnostics of the node for the interfaces, the report is fail when any
+// check fails
+func RunDoctor(conf *config.Config, ifaces []string) models.L3afDDoctorReport {
+	checks := []models.L3afDDoctorCheck{
+		checkBPFFS(conf),
+		checkKernel(conf),
+		checkBTF(),
+		checkCapabilities(),
+		checkMemlock(),
+	}
+	sort.Strings(ifaces)
+	for _, iface := range ifaces {
+		checks = append(checks, checkIfaceXDP(iface))
+	}
+	checks = append(checks, checkRepo(conf))
+	for _, repo := range kfRepos(conf, timeNow()) {
+		if repo.name != kfRepoName {
+			checks = append(checks, checkRepoMirror(conf, repo))
+		}
+	}
+	for _, dir := range []string{conf.BPFDir, conf.BPFLogDir} {
+		if len(dir) > 0 {
+			checks = append(checks, checkDiskSpace(dir, uint64(conf.DoctorMinFreeDiskMB)<<20))
+		}
+	}
+
+	return doctorReport(checks)
+}
+
+// RunDoctorFromStore - runs the preflight diagnostics for the interfaces of the persisted configs before
+// l3afd starts the programs
+func RunDoctorFromStore(conf *config.Config) models.L3afDDoctorReport {
+	var ifaces []string
+	store := doctorCheck("config-store", models.DoctorPass, "no persistent config exists")
+	if err := setStateEncryption(conf); err != nil {
+		store = doctorCheck("config-store", models.DoctorFail, "%v", err)
+	} else if buf, err := ReadStateFile(conf.L3afConfigStoreFileName); err == nil {
+		var configs []models.L3afBPFPrograms
+		if err := json.Unmarshal(buf, &configs); err != nil {
+			store = doctorCheck("config-store", models.DoctorFail, "failed to unmarshal persistent config %s: %v", conf.L3afConfigStoreFileName, err)
+		} else {
+			for _, cfg := range configs {
+				ifaces = append(ifaces, cfg.Iface)
+			}
+			store = doctorCheck("config-store", models.DoctorPass, "persistent config %s has %d interfaces", conf.L3afConfigStoreFileName, len(configs))
+		}
+	} else if !os.IsNotExist(err) {
+		store = doctorCheck("config-store", models.DoctorFail, "failed to read persistent config %s: %v", conf.L3afConfigStoreFileName, err)
+	}
+	report := doctorReport(append([]models.L3afDDoctorCheck{store}, RunDoctor(conf, ifaces).Checks...))
+	report.HostName, _ = os.Hostname()
+	return report
+}
+
+// Doctor - runs the preflight diagnostics for the interfaces of the config
+func (c *NFConfigs) Doctor() models.L3afDDoctorReport {
+	c.mu.Lock()
+	ifaces := make([]string, 0, len(c.ifaces))
+	for iface := range c.ifaces {
+		ifaces = append(ifaces, iface)
+	}
+	c.mu.Unlock()
+	// configs of the interfaces waiting for the link are not in the config yet
+	for _, p := range pendingLinks.list() {
+		ifaces = append(ifaces, p.config.Iface)
+	}
+	report := RunDoctor(c.hostConfig, ifaces)
+	report.HostName = c.hostName
+	return report
+}
+
+// doctorReport - report of the checks with the worst status of the checks
+func doctorReport(checks []models.L3afDDoctorCheck) models.L3afDDoctorReport {
+	report := models.L3afDDoctorReport{
+		Time:   time.Now().UTC().Format(time.RFC3339),
+		Status: models.DoctorPass,
+		Checks: checks,
+	}
+	for _, check := range checks {
+		if doctorStatusRank[check.Status] > doctorStatusRank[report.Status] {
+			report.Status = check.Status
+		}
+	}
+	return report
+}
+
+func doctorCheck(name, status, format string, args ...interface{}) models.L3afDDoctorCheck {
+	return models.L3afDDoctorCheck{Name: name, Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// checkBPFFS - bpf filesystem is mounted on /sys/fs/bpf, in a container it must be mounted from the host
+func checkBPFFS(conf *config.Config) models.L3afDDoctorCheck {
+	mounted, err := bpffsMounted()
+	if err != nil {
+		return doctorCheck("bpffs", models.DoctorFail, "%v", err)
+	}
+	if mounted {
+		return doctorCheck("bpffs", models.DoctorPass, "bpf filesystem is mounted on %s", bpffsPath)
+	}
+	if container, _ := containerMode(conf); container {
+		return doctorCheck("bpffs", models.DoctorFail, "bpf filesystem of the host is not mounted on %s in the container, run the container with -v %s:%s", bpffsPath, bpffsPath, bpffsPath)
+	}
+	return doctorCheck("bpffs", models.DoctorWarn, "bpf filesystem is not mounted on %s, l3afd mounts it when programs are started", bpffsPath)
+}
+
+// kernelVersion - major and minor version of the kernel release e.g. 5.15.0-91-generic
+func kernelVersion(release string) (int, int, error) {
+	ver := strings.SplitN(release, ".", 3)
+	if len(ver) < 2 {
+		return 0, 0, fmt.Errorf("invalid kernel release %q", release)
+	}
+	major, err := strconv.Atoi(ver[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid kernel major version of %q", release)
+	}
+	// minor version of a release without the patch version is followed by the suffix e.g. 5.4-rc1
+	if i := strings.IndexFunc(ver[1], func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		ver[1] = ver[1][:i]
+	}
+	minor, err := strconv.Atoi(ver[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid kernel minor version of %q", release)
+	}
+	return major, minor, nil
+}
+
+// checkKernel - kernel is the minimum version of the config or newer
+func checkKernel(conf *config.Config) models.L3afDDoctorCheck {
+	release, err := getKernelRelease()
+	if err != nil {
+		return doctorCheck("kernel", models.DoctorFail, "%v", err)
+	}
+	major, minor, err := kernelVersion(release)
+	if err != nil {
+		return doctorCheck("kernel", models.DoctorFail, "%v", err)
+	}
+	if major < conf.MinKernelMajorVer || (major == conf.MinKernelMajorVer && minor < conf.MinKernelMinorVer) {
+		return doctorCheck("kernel", models.DoctorFail, "kernel %s is older than the minimum %d.%d", release, conf.MinKernelMajorVer, conf.MinKernelMinorVer)
+	}
+	return doctorCheck("kernel", models.DoctorPass, "kernel %s", release)
+}
+
+// checkBTF - kernel exposes BTF for the CO-RE programs
+func checkBTF() models.L3afDDoctorCheck {
+	if fileExists(kernelBTFPath) {
+		return doctorCheck("btf", models.DoctorPass, "kernel BTF is available at %s", kernelBTFPath)
+	}
+	return doctorCheck("btf", models.DoctorWarn, "kernel BTF %s is not available, programs requiring CO-RE need the BTF hub", kernelBTFPath)
+}
+
+// checkCapabilities - l3afd has CAP_NET_ADMIN and CAP_SYS_ADMIN or CAP_BPF with CAP_PERFMON
+func checkCapabilities() models.L3afDDoctorCheck {
+	status, err := appFS.ReadFile("/proc/self/status")
+	if err != nil {
+		return doctorCheck("capabilities", models.DoctorFail, "failed to read process status: %v", err)
+	}
+	var capEff uint64
+	found := false
+	for _, line := range strings.Split(string(status), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			v := strings.TrimPrefix(line, "CapEff:")
+			if capEff, err = strconv.ParseUint(strings.TrimSpace(v), 16, 64); err != nil {
+				return doctorCheck("capabilities", models.DoctorFail, "invalid effective capabilities %q", strings.TrimSpace(v))
+			}
+			found = true
+		}
+	}
+	if !found {
+		return doctorCheck("capabilities", models.DoctorFail, "effective capabilities are not reported")
+	}
+	has := func(capability uint) bool { return capEff&(1<<capability) != 0 }
+	var missing []string
+	if !has(capNetAdmin) {
+		missing = append(missing, "CAP_NET_ADMIN")
+	}
+	if !has(capSysAdmin) && !(has(capBPF) && has(capPerfmon)) {
+		missing = append(missing, "CAP_SYS_ADMIN or CAP_BPF and CAP_PERFMON")
+	}
+	if len(missing) > 0 {
+		return doctorCheck("capabilities", models.DoctorFail, "missing %s", strings.Join(missing, ", "))
+	}
+	return doctorCheck("capabilities", models.DoctorPass, "effective capabilities %016x", capEff)
+}
+
+// checkMemlock - memlock limit doesn't restrict the BPF maps, kernels 5.11 and newer account the maps to
+// the memory cgroup instead
+func checkMemlock() models.L3afDDoctorCheck {
+	limit, err := memlockLimit()
+	if err != nil {
+		return doctorCheck("memlock", models.DoctorWarn, "%v", err)
+	}
+	if limit == memlockUnlimited {
+		return doctorCheck("memlock", models.DoctorPass, "memlock limit is unlimited")
+	}
+	if memcgAccounting() {
+		return doctorCheck("memlock", models.DoctorPass, "memlock limit is %d bytes, BPF maps are accounted to the memory cgroup", limit)
+	}
+	return doctorCheck("memlock", models.DoctorWarn, "memlock limit is %d bytes, BPF maps larger than the limit fail to load", limit)
+}
+
+// checkIfaceXDP - interface exists and its driver supports native XDP
+func checkIfaceXDP(iface string) models.L3afDDoctorCheck {
+	name := "iface " + iface
+	if _, err := net.InterfaceByName(iface); err != nil {
+		return doctorCheck(name, models.DoctorFail, "interface not found: %v", err)
+	}
+	driver, err := getIfaceDriver(iface)
+	if err != nil {
+		return doctorCheck(name, models.DoctorWarn, "failed to get the driver: %v", err)
+	}
+	modes := xdpModes(driver)
+	if len(modes) == 1 {
+		return doctorCheck(name, models.DoctorWarn, "driver %s supports generic XDP only", driver)
+	}
+	return doctorCheck(name, models.DoctorPass, "driver %s supports XDP modes %s", driver, strings.Join(modes, ", "))
+}
+
+// checkRepo - artifact store of the KF repo url is reachable
+func checkRepo(conf *config.Config) models.L3afDDoctorCheck {
+	if len(conf.KFRepoURL) == 0 {
+		return doctorCheck("kf-repo", models.DoctorWarn, "KF repo url is not configured")
+	}
+	store, err := newArtifactStore(conf)
+	if err != nil {
+		return doctorCheck("kf-repo", models.DoctorFail, "%v", err)
+	}
+	if err := store.Check(); err != nil {
+		return doctorCheck("kf-repo", models.DoctorFail, "KF repo %s is not reachable: %v", conf.KFRepoURL, err)
+	}
+	return doctorCheck("kf-repo", models.DoctorPass, "KF repo %s is reachable", conf.KFRepoURL)
+}
+
+// checkRepoMirror - artifact store of the mirror of the KF repo is reachable
+func checkRepoMirror(conf *config.Config, repo kfRepo) models.L3afDDoctorCheck {
+	name := "kf-repo-mirror." + repo.name
+	store, err := newRepoArtifactStore(conf, repo.url)
+	if err != nil {
+		return doctorCheck(name, models.DoctorFail, "%v", err)
+	}
+	if err := store.Check(); err != nil {
+		return doctorCheck(name, models.DoctorWarn, "KF repo mirror %s is not reachable: %v", repo.url, err)
+	}
+	return doctorCheck(name, models.DoctorPass, "KF repo mirror %s is reachable", repo.url)
+}
+
+// checkDiskSpace - filesystem of the directory has the minimum free space
+func checkDiskSpace(dir string, min uint64) models.L3afDDoctorCheck {
+	name := "disk " + dir
+	free, err := diskFree(dir)
+	if err != nil {
+		return doctorCheck(name, models.DoctorWarn, "%v", err)
+	}
+	if free < min {
+		return doctorCheck(name, models.DoctorWarn, "%d MB free, less than %d MB", free>>20, min>>20)
+	}
+	return doctorCheck(name, models.DoctorPass, "%d MB free", free>>20)
+}
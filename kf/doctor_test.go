@@ -0,0 +1,93 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestKernelVersion(t *testing.T) {
+	tests := []struct {
+		release      string
+		major, minor int
+		wantErr      bool
+	}{
+		{release: "5.15.0-91-generic", major: 5, minor: 15},
+		{release: "6.1.55", major: 6, minor: 1},
+		{release: "5.4-rc1", major: 5, minor: 4},
+		{release: "6", wantErr: true},
+		{release: "v5.10.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		major, minor, err := kernelVersion(tt.release)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("kernelVersion(%q) error = %v, wantErr %v", tt.release, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (major != tt.major || minor != tt.minor) {
+			t.Errorf("kernelVersion(%q) = %d.%d, want %d.%d", tt.release, major, minor, tt.major, tt.minor)
+		}
+	}
+}
+
+func TestCheckBPFFS(t *testing.T) {
+	useMemFS(t, map[string]string{
+		"/proc/mounts": "proc /proc proc rw 0 0\nbpf /sys/fs/bpf bpf rw,relatime,mode=700 0 0\n",
+	})
+	conf := &config.Config{ContainerMode: ContainerModeDisabled}
+	if got := checkBPFFS(conf); got.Status != models.DoctorPass {
+		t.Errorf("checkBPFFS() = %+v, want pass", got)
+	}
+
+	useMemFS(t, map[string]string{"/proc/mounts": "proc /proc proc rw 0 0\n"})
+	if got := checkBPFFS(conf); got.Status != models.DoctorWarn {
+		t.Errorf("checkBPFFS() = %+v without bpffs, want warn", got)
+	}
+
+	conf.ContainerMode = ContainerModeEnabled
+	if got := checkBPFFS(conf); got.Status != models.DoctorFail {
+		t.Errorf("checkBPFFS() = %+v without bpffs in a container, want fail", got)
+	}
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	tests := []struct {
+		name   string
+		capEff string
+		want   string
+	}{
+		{name: "root", capEff: "000001ffffffffff", want: models.DoctorPass},
+		{name: "net admin and sys admin", capEff: "0000000000201000", want: models.DoctorPass},
+		{name: "net admin, bpf and perfmon", capEff: "000000c000001000", want: models.DoctorPass},
+		{name: "net admin and bpf only", capEff: "0000008000001000", want: models.DoctorFail},
+		{name: "no capabilities", capEff: "0000000000000000", want: models.DoctorFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t, map[string]string{
+				"/proc/self/status": "Name:\tl3afd\nCapInh:\t0000000000000000\nCapEff:\t" + tt.capEff + "\n",
+			})
+			if got := checkCapabilities(); got.Status != tt.want {
+				t.Errorf("checkCapabilities() = %+v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoctorReport(t *testing.T) {
+	report := doctorReport([]models.L3afDDoctorCheck{
+		doctorCheck("bpffs", models.DoctorPass, "mounted"),
+		doctorCheck("memlock", models.DoctorWarn, "limited"),
+	})
+	if report.Status != models.DoctorWarn {
+		t.Errorf("doctorReport() status = %s, want warn", report.Status)
+	}
+	report = doctorReport(append(report.Checks, doctorCheck("kf-repo", models.DoctorFail, "not reachable")))
+	if report.Status != models.DoctorFail {
+		t.Errorf("doctorReport() status = %s, want fail", report.Status)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"github.com/cilium/ebpf"
+)
+
+// ebpfMap - eBPF map operations used by the chaining logic and the map dump
+type ebpfMap interface {
+	Lookup(key, valueOut interface{}) error
+	NextKey(key, nextKeyOut interface{}) error
+	Update(key, value interface{}, flags ebpf.MapUpdateFlags) error
+	Delete(key interface{}) error
+	Info() (*ebpf.MapInfo, error)
+	Close() error
+}
+
+// ebpfProgram - eBPF program operations used by the chaining logic
+type ebpfProgram interface {
+	FD() int
+	Close() error
+}
+
+// ebpfAPI - kernel eBPF object access of the package. Tests replace bpfAPI to run the chaining logic
+// without kernel privileges.
+type ebpfAPI interface {
+	LoadPinnedMap(fileName string, opts *ebpf.LoadPinOptions) (ebpfMap, error)
+	NewMapFromID(id ebpf.MapID) (ebpfMap, error)
+	MapGetNextID(startID ebpf.MapID) (ebpf.MapID, error)
+	NewProgramFromID(id ebpf.ProgramID) (ebpfProgram, error)
+}
+
+// ciliumEBPF - ebpfAPI of the running kernel
+type ciliumEBPF struct{}
+
+var bpfAPI ebpfAPI = ciliumEBPF{}
+
+func (ciliumEBPF) LoadPinnedMap(fileName string, opts *ebpf.LoadPinOptions) (ebpfMap, error) {
+	m, err := ebpf.LoadPinnedMap(fileName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (ciliumEBPF) NewMapFromID(id ebpf.MapID) (ebpfMap, error) {
+	m, err := ebpf.NewMapFromID(id)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (ciliumEBPF) MapGetNextID(startID ebpf.MapID) (ebpf.MapID, error) {
+	return ebpf.MapGetNextID(startID)
+}
+
+func (ciliumEBPF) NewProgramFromID(id ebpf.ProgramID) (ebpfProgram, error) {
+	p, err := ebpf.NewProgramFromID(id)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,247 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"unsafe"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+// fakeMap - program array of a single entry, keys and values are unsafe pointers to int like the chaining logic
+type fakeMap struct {
+	entries map[int]int
+}
+
+func (m *fakeMap) Lookup(key, valueOut interface{}) error {
+	v, ok := m.entries[*(*int)(key.(unsafe.Pointer))]
+	if !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	*(*int)(valueOut.(unsafe.Pointer)) = v
+	return nil
+}
+
+func (m *fakeMap) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	m.entries[*(*int)(key.(unsafe.Pointer))] = *(*int)(value.(unsafe.Pointer))
+	return nil
+}
+
+func (m *fakeMap) Delete(key interface{}) error {
+	k := *(*int)(key.(unsafe.Pointer))
+	if _, ok := m.entries[k]; !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	delete(m.entries, k)
+	return nil
+}
+
+// NextKey - smallest key greater than key, the smallest key when key is nil
+func (m *fakeMap) NextKey(key, nextKeyOut interface{}) error {
+	found := false
+	next := 0
+	for k := range m.entries {
+		if key != nil && k <= *(*int)(key.(unsafe.Pointer)) {
+			continue
+		}
+		if !found || k < next {
+			found, next = true, k
+		}
+	}
+	if !found {
+		return ebpf.ErrKeyNotExist
+	}
+	*(*int)(nextKeyOut.(unsafe.Pointer)) = next
+	return nil
+}
+
+func (m *fakeMap) Info() (*ebpf.MapInfo, error) { return nil, errors.New("not supported") }
+func (m *fakeMap) Close() error                 { return nil }
+
+type fakeProgram struct{ fd int }
+
+func (p *fakeProgram) FD() int      { return p.fd }
+func (p *fakeProgram) Close() error { return nil }
+
+// fakeEBPF - pinned maps by path and loaded programs by ID, program FD is the ID + 100
+type fakeEBPF struct {
+	maps     map[string]*fakeMap
+	programs map[ebpf.ProgramID]bool
+}
+
+func (f *fakeEBPF) LoadPinnedMap(fileName string, opts *ebpf.LoadPinOptions) (ebpfMap, error) {
+	m, ok := f.maps[fileName]
+	if !ok {
+		return nil, fmt.Errorf("map %s is not pinned", fileName)
+	}
+	return m, nil
+}
+
+func (f *fakeEBPF) NewMapFromID(id ebpf.MapID) (ebpfMap, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeEBPF) MapGetNextID(startID ebpf.MapID) (ebpf.MapID, error) {
+	return 0, errors.New("not supported")
+}
+
+func (f *fakeEBPF) NewProgramFromID(id ebpf.ProgramID) (ebpfProgram, error) {
+	if !f.programs[id] {
+		return nil, fmt.Errorf("program %d is not loaded", id)
+	}
+	return &fakeProgram{fd: int(id) + 100}, nil
+}
+
+// useFakeEBPF - replaces bpfAPI with the fake for the test
+func useFakeEBPF(t *testing.T, f *fakeEBPF) {
+	saved := bpfAPI
+	bpfAPI = f
+	t.Cleanup(func() { bpfAPI = saved })
+}
+
+func TestBPF_PutNextProgFDFromID(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapName string
+		progID  int
+		wantFD  int
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			mapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+			progID:  12,
+			wantFD:  112,
+			wantErr: false,
+		},
+		{
+			name:    "NoChainingMap",
+			mapName: "",
+			progID:  12,
+			wantErr: false,
+		},
+		{
+			name:    "MapNotPinned",
+			mapName: "/sys/fs/bpf/absent_next_prog",
+			progID:  12,
+			wantErr: true,
+		},
+		{
+			name:    "ProgramNotLoaded",
+			mapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+			progID:  13,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextProgMap := &fakeMap{entries: make(map[int]int)}
+			useFakeEBPF(t, &fakeEBPF{
+				maps:     map[string]*fakeMap{"/sys/fs/bpf/xdp_rl_ingress_next_prog": nextProgMap},
+				programs: map[ebpf.ProgramID]bool{12: true},
+			})
+			b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", MapName: tt.mapName}}
+			err := b.PutNextProgFDFromID(tt.progID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PutNextProgFDFromID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantFD > 0 && nextProgMap.entries[0] != tt.wantFD {
+				t.Errorf("PutNextProgFDFromID() map entry = %d, want %d", nextProgMap.entries[0], tt.wantFD)
+			}
+		})
+	}
+}
+
+func TestBPF_GetProgID(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[int]int
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			entries: map[int]int{0: 12},
+			want:    12,
+			wantErr: false,
+		},
+		{
+			name:    "EmptyMap",
+			entries: map[int]int{},
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "ProgramNotLoaded",
+			entries: map[int]int{0: 13},
+			want:    0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeEBPF(t, &fakeEBPF{
+				maps:     map[string]*fakeMap{"/sys/fs/bpf/xdp_root_next_prog": {entries: tt.entries}},
+				programs: map[ebpf.ProgramID]bool{12: true},
+			})
+			b := &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, PrevMapName: "/sys/fs/bpf/xdp_root_next_prog"}
+			got, err := b.GetProgID()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetProgID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetProgID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBPF_RemoveNextProgFD(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapName string
+		entries map[int]int
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			mapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+			entries: map[int]int{0: 112},
+			wantErr: false,
+		},
+		{
+			name:    "RootProgram",
+			mapName: "",
+			entries: map[int]int{},
+			wantErr: false,
+		},
+		{
+			name:    "NoEntry",
+			mapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+			entries: map[int]int{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextProgMap := &fakeMap{entries: tt.entries}
+			useFakeEBPF(t, &fakeEBPF{maps: map[string]*fakeMap{"/sys/fs/bpf/xdp_rl_ingress_next_prog": nextProgMap}})
+			b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", MapName: tt.mapName}}
+			if err := b.RemoveNextProgFD(); (err != nil) != tt.wantErr {
+				t.Errorf("RemoveNextProgFD() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if _, ok := nextProgMap.entries[0]; ok {
+				t.Errorf("RemoveNextProgFD() entry is not removed")
+			}
+		})
+	}
+}
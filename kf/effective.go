@@ -0,0 +1,142 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// repoArtifactURL - download url of the artifact of the program for the platform
+func repoArtifactURL(repoURL, platform string, prog *models.BPFProgram) (*url.URL, error) {
+	kfRepoURL, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("unknown KF repo url format: %w", err)
+	}
+	kfRepoURL.Path = path.Join(kfRepoURL.Path, prog.Name, prog.Version, platform, prog.Artifact)
+	return kfRepoURL, nil
+}
+
+// resolveProgram - config of the program with the l3afd defaults of the unset fields
+func (c *NFConfigs) resolveProgram(prog models.BPFProgram) models.BPFProgram {
+	prog.Priority = priorityClass(&prog)
+	if prog.StopGracePeriod == 0 {
+		prog.StopGracePeriod = int(nfCmdConfig.stopGrace / time.Second)
+	}
+	if len(prog.ExternalStop) == 0 {
+		prog.ExternalStop = models.ExternalStopKill
+	}
+	prog.ArgSchemaVersion = argSchemaVersion(&prog)
+	if len(prog.ApplyWindow) == 0 && c.hostConfig != nil {
+		prog.ApplyWindow = c.hostConfig.ApplyWindow
+	}
+	return prog
+}
+
+// effectiveProgram - resolved config of the program in the state l3afd acts on it
+func (c *NFConfigs) effectiveProgram(prog models.BPFProgram, ifaceName, direction, state, platform string) models.L3afDEffectiveProgram {
+	p := models.L3afDEffectiveProgram{
+		Direction: direction,
+		State:     state,
+		Program:   c.resolveProgram(prog),
+	}
+	if len(prog.Artifact) > 0 && len(platform) > 0 {
+		if u, err := repoArtifactURL(c.hostConfig.KFRepoURL, platform, &prog); err == nil {
+			p.ArtifactURL = u.String()
+		}
+	}
+	for _, pending := range pendingApplies.list() {
+		if pending.iface == ifaceName && pending.direction == direction && pending.prog.Name == prog.Name {
+			update := c.resolveProgram(pending.prog)
+			p.PendingUpdate = &update
+		}
+	}
+	return p
+}
+
+// EffectiveConfig - configs of the interfaces l3afd acts on, with the seq ids assigned, the defaults of the
+// programs and the platform of the artifacts resolved, along with the paused programs, the configs waiting
+// for the link and the updates waiting for the apply window
+func (c *NFConfigs) EffectiveConfig() []models.L3afDEffectiveConfig {
+	platform, err := GetPlatform()
+	if err != nil {
+		log.Warn().Err(err).Msg("platform of the artifacts is not resolved")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	configs := make([]models.L3afDEffectiveConfig, 0, len(c.ifaces))
+	for ifaceName := range c.ifaces {
+		if pendingLinks.queued(ifaceName) {
+			continue
+		}
+		cfg := models.L3afDEffectiveConfig{
+			HostName:    c.hostName,
+			Iface:       ifaceName,
+			Platform:    platform,
+			LinkStatus:  models.LinkAttached,
+			BpfPrograms: make([]models.L3afDEffectiveProgram, 0),
+		}
+		xdpModes := c.ifaceXDPModes(ifaceName)
+		for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+			bpfs, _ := c.bpfLists(direction)
+			if bpfList := bpfs[ifaceName]; bpfList != nil {
+				for e := bpfList.Front(); e != nil; e = e.Next() {
+					bpf := e.Value.(*BPF)
+					state := models.EffectiveRunning
+					switch {
+					case c.hostConfig.BpfChainingEnabled && e == bpfList.Front() &&
+						(bpf.Program.Name == c.hostConfig.XDPRootProgramName || bpf.Program.Name == c.hostConfig.TCRootProgramName):
+						state = models.EffectiveRoot
+					case bpf.Degraded:
+						state = models.EffectiveBypassed
+					case bpf.standby():
+						state = models.EffectiveStandby
+					}
+					p := c.effectiveProgram(bpf.Program, ifaceName, direction, state, platform)
+					p.FilePath = bpf.FilePath
+					p.Tasks = bpf.taskStatus()
+					p.MapMemory = bpf.MapMemory
+					p.MemlockBytes = memlockUsage.usage(ifaceName, direction, bpf.slotName())
+					if direction == models.XDPIngressType {
+						p.XDPMode = xdpModes[bpf.Program.Name]
+						p.QueueInstances = bpf.queueInstanceStatus()
+					}
+					cfg.BpfPrograms = append(cfg.BpfPrograms, p)
+				}
+			}
+			for _, prog := range pausedBPFPrograms(ifaceName, direction) {
+				cfg.BpfPrograms = append(cfg.BpfPrograms, c.effectiveProgram(*prog, ifaceName, direction, models.EffectivePaused, platform))
+			}
+		}
+		configs = append(configs, cfg)
+	}
+
+	for _, p := range pendingLinks.list() {
+		cfg := models.L3afDEffectiveConfig{
+			HostName:    p.config.HostName,
+			Iface:       p.config.Iface,
+			Platform:    platform,
+			LinkStatus:  models.LinkWaitingForLink,
+			BpfPrograms: make([]models.L3afDEffectiveProgram, 0),
+		}
+		for _, ref := range configProgramRefs(p.config.BpfPrograms) {
+			if ref.prog != nil {
+				cfg.BpfPrograms = append(cfg.BpfPrograms, c.effectiveProgram(*ref.prog, p.config.Iface, ref.direction, models.EffectiveWaitingForLink, platform))
+			}
+		}
+		configs = append(configs, cfg)
+	}
+
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Iface < configs[j].Iface })
+	return configs
+}
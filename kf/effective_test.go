@@ -0,0 +1,79 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_EffectiveConfig(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command("/bin/sh", "-c", "echo focal") }
+	defer func() { execCommand = exec.Command }()
+	savedCmdConfig := nfCmdConfig
+	nfCmdConfig.stopGrace = 30 * time.Second
+	t.Cleanup(func() { nfCmdConfig = savedCmdConfig })
+	pendingApplies.reset()
+	t.Cleanup(pendingApplies.reset)
+
+	xdp := list.New()
+	xdp.PushBack(&BPF{Program: models.BPFProgram{Name: "xdp-root", Version: "1.0", Artifact: "l3af_xdp_root.tar.gz"}})
+	xdp.PushBack(&BPF{
+		Program:   models.BPFProgram{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz", SeqID: 1, StopGracePeriod: 5},
+		FilePath:  "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting",
+		MapMemory: 4096,
+	})
+	memlockUsage.bytes[memlockKey("eth0", models.XDPIngressType, "ratelimiting")] = 8192
+	t.Cleanup(memlockUsage.reset)
+	c := &NFConfigs{
+		hostName: "l3af-test-host",
+		hostConfig: &config.Config{
+			BpfChainingEnabled: true,
+			XDPRootProgramName: "xdp-root",
+			KFRepoURL:          "http://kf-repo.example.com/l3af",
+			ApplyWindow:        "Mon-Fri 02:00-04:00",
+		},
+		ifaces:         map[string]string{"eth0": "eth0"},
+		IngressXDPBpfs: map[string]*list.List{"eth0": xdp},
+		IngressTCBpfs:  make(map[string]*list.List),
+		EgressTCBpfs:   make(map[string]*list.List),
+		mu:             new(sync.Mutex),
+	}
+	pendingApplies.queue(pendingApply{iface: "eth0", direction: models.XDPIngressType, prog: models.BPFProgram{Name: "ratelimiting", Version: "1.1"}})
+
+	got := c.EffectiveConfig()
+	if len(got) != 1 || got[0].Platform != "focal" || len(got[0].BpfPrograms) != 2 {
+		t.Fatalf("EffectiveConfig() = %+v, want eth0 with the root and ratelimiting on focal", got)
+	}
+	if root := got[0].BpfPrograms[0]; root.State != models.EffectiveRoot {
+		t.Errorf("state of %s = %s, want root", root.Program.Name, root.State)
+	}
+	p := got[0].BpfPrograms[1]
+	if p.State != models.EffectiveRunning || p.FilePath != "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting" {
+		t.Errorf("ratelimiting state %s file path %s, want running from the version dir", p.State, p.FilePath)
+	}
+	if p.MapMemory != 4096 || p.MemlockBytes != 8192 {
+		t.Errorf("ratelimiting map memory %d memlock %d, want the declared map memory and the reserved memlock", p.MapMemory, p.MemlockBytes)
+	}
+	if p.ArtifactURL != "http://kf-repo.example.com/l3af/ratelimiting/1.0/focal/l3af_ratelimiting.tar.gz" {
+		t.Errorf("ArtifactURL = %s", p.ArtifactURL)
+	}
+	if p.Program.StopGracePeriod != 5 || p.Program.ExternalStop != models.ExternalStopKill ||
+		p.Program.Priority != models.PriorityNormal || p.Program.ApplyWindow != "Mon-Fri 02:00-04:00" {
+		t.Errorf("resolved program = %+v, want the program grace period and the l3afd defaults", p.Program)
+	}
+	if p.PendingUpdate == nil || p.PendingUpdate.Version != "1.1" || p.PendingUpdate.StopGracePeriod != 30 {
+		t.Errorf("PendingUpdate = %+v, want the resolved version 1.1", p.PendingUpdate)
+	}
+}
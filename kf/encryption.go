@@ -0,0 +1,138 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+// encryptedStateMagic - prefix of the encrypted state files, files without the prefix are plaintext
+var encryptedStateMagic = []byte("L3AFENC1")
+
+// stateKeySize - AES-256 key size
+const stateKeySize = 32
+
+// stateKeyCommandTimeout - timeout of the command providing the key e.g. a KMS client
+const stateKeyCommandTimeout = 30 * time.Second
+
+// stateAEAD - cipher of the state files, nil when encryption is disabled
+var stateAEAD cipher.AEAD
+
+// setStateEncryption - reads the key of the state files from the key file or the key command
+func setStateEncryption(conf *config.Config) error {
+	stateAEAD = nil
+	if conf == nil || !conf.EncryptionEnabled {
+		return nil
+	}
+
+	var raw []byte
+	var err error
+	switch {
+	case len(conf.EncryptionKeyFile) > 0:
+		if raw, err = os.ReadFile(conf.EncryptionKeyFile); err != nil {
+			return fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+	case len(conf.EncryptionKeyCommand) > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), stateKeyCommandTimeout)
+		defer cancel()
+		if raw, err = exec.CommandContext(ctx, conf.EncryptionKeyCommand[0], conf.EncryptionKeyCommand[1:]...).Output(); err != nil {
+			return fmt.Errorf("encryption key command failed: %w", err)
+		}
+	default:
+		return fmt.Errorf("encryption is enabled without key-file or key-command")
+	}
+
+	key, err := decodeStateKey(raw)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+	if stateAEAD, err = cipher.NewGCM(block); err != nil {
+		return fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return nil
+}
+
+// decodeStateKey - key is raw, hex or base64 encoded
+func decodeStateKey(raw []byte) ([]byte, error) {
+	if len(raw) == stateKeySize {
+		return raw, nil
+	}
+	s := string(bytes.TrimSpace(raw))
+	if key, err := hex.DecodeString(s); err == nil && len(key) == stateKeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == stateKeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be %d bytes, raw, hex or base64 encoded", stateKeySize)
+}
+
+// sealState - encrypts the state when encryption is enabled, nonce is stored after the magic prefix
+func sealState(plain []byte) ([]byte, error) {
+	if stateAEAD == nil {
+		return plain, nil
+	}
+	nonce := make([]byte, stateAEAD.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append(append([]byte{}, encryptedStateMagic...), nonce...)
+	return stateAEAD.Seal(out, nonce, plain, encryptedStateMagic), nil
+}
+
+// openState - decrypts the encrypted state, plaintext state written before encryption was enabled is
+// returned as is
+func openState(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedStateMagic) {
+		return data, nil
+	}
+	if stateAEAD == nil {
+		return nil, fmt.Errorf("state is encrypted and encryption is not enabled")
+	}
+	data = data[len(encryptedStateMagic):]
+	if len(data) < stateAEAD.NonceSize() {
+		return nil, fmt.Errorf("encrypted state is truncated")
+	}
+	plain, err := stateAEAD.Open(nil, data[:stateAEAD.NonceSize()], data[stateAEAD.NonceSize():], encryptedStateMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt state: %w", err)
+	}
+	return plain, nil
+}
+
+// writeStateFile - writes the state file, encrypted when encryption is enabled
+func writeStateFile(name string, data []byte, perm fs.FileMode) error {
+	sealed, err := sealState(data)
+	if err != nil {
+		return err
+	}
+	return appFS.WriteFile(name, sealed, perm)
+}
+
+// ReadStateFile - reads the state file written by l3afd, decrypted in memory
+func ReadStateFile(name string) ([]byte, error) {
+	data, err := appFS.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return openState(data)
+}
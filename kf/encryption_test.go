@@ -0,0 +1,85 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+func TestStateEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, stateKeySize)
+	tests := []struct {
+		name      string
+		keyFile   string
+		plain     bool // state written before encryption was enabled
+		wantErr   bool
+		wantSetup bool
+	}{
+		{
+			name:      "HexKey",
+			keyFile:   hex.EncodeToString(key) + "\n",
+			wantSetup: true,
+		},
+		{
+			name:      "RawKey",
+			keyFile:   string(key),
+			wantSetup: true,
+		},
+		{
+			name:      "PlaintextState",
+			keyFile:   string(key),
+			plain:     true,
+			wantSetup: true,
+		},
+		{
+			name:      "ShortKey",
+			keyFile:   "0123456789abcdef",
+			wantSetup: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { stateAEAD = nil })
+			useMemFS(t, nil)
+			keyFile := t.TempDir() + "/state.key"
+			if err := os.WriteFile(keyFile, []byte(tt.keyFile), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := setStateEncryption(&config.Config{EncryptionEnabled: true, EncryptionKeyFile: keyFile})
+			if (err == nil) != tt.wantSetup {
+				t.Fatalf("setStateEncryption() error = %v, wantSetup %v", err, tt.wantSetup)
+			}
+			if err != nil {
+				return
+			}
+
+			state := []byte(`[{"iface":"eth0"}]`)
+			if tt.plain {
+				err = appFS.WriteFile("/etc/l3afd/l3af-config.json", state, 0600)
+			} else {
+				err = writeStateFile("/etc/l3afd/l3af-config.json", state, 0600)
+			}
+			if err != nil {
+				t.Fatalf("writeStateFile() error = %v", err)
+			}
+			onDisk, _ := appFS.ReadFile("/etc/l3afd/l3af-config.json")
+			if !tt.plain && bytes.Contains(onDisk, []byte("eth0")) {
+				t.Errorf("writeStateFile() state is not encrypted")
+			}
+			got, err := ReadStateFile("/etc/l3afd/l3af-config.json")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadStateFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, state) {
+				t.Errorf("ReadStateFile() = %s, want %s", got, state)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Fault types injected for testing the alerting and recovery in staging
+const (
+	FaultFailDownload = "fail-download"
+	FaultDelayMapPin  = "delay-map-pin"
+	FaultKillNF       = "kill-nf"
+)
+
+type faultRegistry struct {
+	mu     sync.Mutex
+	faults map[string]*models.L3afDFault // key is fault type and program name
+}
+
+var injectedFaults = &faultRegistry{faults: make(map[string]*models.L3afDFault)}
+
+func faultKey(faultType, program string) string {
+	return faultType + "/" + program
+}
+
+// InjectFault - injects the fault, download and map pin faults are applied to the next count lifecycle
+// operations of the program and kill fault kills the running program process immediately
+func (c *NFConfigs) InjectFault(fault models.L3afDFault) error {
+	if !c.hostConfig.ChaosEnabled {
+		return fmt.Errorf("fault injection is disabled")
+	}
+	if len(fault.Program) == 0 {
+		return fmt.Errorf("program name is empty")
+	}
+	if fault.Count <= 0 {
+		fault.Count = 1
+	}
+
+	switch fault.Type {
+	case FaultFailDownload:
+	case FaultDelayMapPin:
+		if fault.Delay <= 0 {
+			return fmt.Errorf("delay is required for %s fault", fault.Type)
+		}
+	case FaultKillNF:
+		return c.killNF(fault)
+	default:
+		return fmt.Errorf("unknown fault type %s", fault.Type)
+	}
+
+	injectedFaults.mu.Lock()
+	defer injectedFaults.mu.Unlock()
+	injectedFaults.faults[faultKey(fault.Type, fault.Program)] = &fault
+	log.Warn().Msgf("fault %s injected for program %s count %d", fault.Type, fault.Program, fault.Count)
+	return nil
+}
+
+// Faults - returns the pending faults
+func (c *NFConfigs) Faults() []models.L3afDFault {
+	injectedFaults.mu.Lock()
+	defer injectedFaults.mu.Unlock()
+
+	faults := make([]models.L3afDFault, 0, len(injectedFaults.faults))
+	for _, f := range injectedFaults.faults {
+		faults = append(faults, *f)
+	}
+	sort.Slice(faults, func(i, j int) bool {
+		return faultKey(faults[i].Type, faults[i].Program) < faultKey(faults[j].Type, faults[j].Program)
+	})
+	return faults
+}
+
+// ClearFaults - removes the pending faults
+func (c *NFConfigs) ClearFaults() {
+	injectedFaults.mu.Lock()
+	defer injectedFaults.mu.Unlock()
+	injectedFaults.faults = make(map[string]*models.L3afDFault)
+}
+
+// take - consumes one occurrence of the pending fault of the program
+func (r *faultRegistry) take(faultType, program string) (*models.L3afDFault, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := faultKey(faultType, program)
+	f, ok := r.faults[key]
+	if !ok {
+		return nil, false
+	}
+	f.Count--
+	if f.Count <= 0 {
+		delete(r.faults, key)
+	}
+	log.Warn().Msgf("injecting fault %s for program %s", faultType, program)
+	return f, true
+}
+
+// downloadFault - returns error when artifact download failure is injected for the program
+func (r *faultRegistry) downloadFault(program string) error {
+	if _, ok := r.take(FaultFailDownload, program); ok {
+		return fmt.Errorf("injected download failure")
+	}
+	return nil
+}
+
+// mapPinDelay - returns the injected delay of the program map pin
+func (r *faultRegistry) mapPinDelay(program string) time.Duration {
+	if f, ok := r.take(FaultDelayMapPin, program); ok {
+		return time.Duration(f.Delay) * time.Second
+	}
+	return 0
+}
+
+// killNF - kills the running user program process, monitor is expected to restart the program
+func (c *NFConfigs) killNF(fault models.L3afDFault) error {
+	bpf, err := c.findBPF(fault.Iface, fault.Direction, fault.Program)
+	if err != nil {
+		return err
+	}
+	if bpf.Cmd == nil || bpf.Cmd.Process == nil {
+		return fmt.Errorf("program %s has no user program process", fault.Program)
+	}
+	log.Warn().Msgf("injecting fault %s, killing program %s pid %d", fault.Type, fault.Program, bpf.Cmd.Process.Pid)
+	return bpf.Cmd.Process.Kill()
+}
+
+// findBPF - returns the program running on the interface in the direction
+func (c *NFConfigs) findBPF(iface, direction, program string) (*BPF, error) {
+	var bpfLists map[string]*list.List
+	switch direction {
+	case models.XDPIngressType:
+		bpfLists = c.IngressXDPBpfs
+	case models.IngressType:
+		bpfLists = c.IngressTCBpfs
+	case models.EgressType:
+		bpfLists = c.EgressTCBpfs
+	default:
+		return nil, fmt.Errorf("unknown direction %s", direction)
+	}
+
+	bpfList := bpfLists[iface]
+	if bpfList == nil {
+		return nil, fmt.Errorf("no programs are running on iface %s direction %s", iface, direction)
+	}
+	for e := bpfList.Front(); e != nil; e = e.Next() {
+		bpf := e.Value.(*BPF)
+		if bpf.Program.Name == program {
+			return bpf, nil
+		}
+	}
+	return nil, fmt.Errorf("program %s is not running on iface %s direction %s", program, iface, direction)
+}
@@ -0,0 +1,60 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_faultRegistry(t *testing.T) {
+	tests := []struct {
+		name      string
+		faults    []models.L3afDFault
+		program   string
+		downloads []bool // expected download failure of the consecutive downloads
+		delay     time.Duration
+	}{
+		{
+			name:      "FailDownloadTwice",
+			faults:    []models.L3afDFault{{Type: FaultFailDownload, Program: "ratelimiting", Count: 2}},
+			program:   "ratelimiting",
+			downloads: []bool{true, true, false},
+		},
+		{
+			name:      "OtherProgram",
+			faults:    []models.L3afDFault{{Type: FaultFailDownload, Program: "ratelimiting", Count: 1}},
+			program:   "connection-limit",
+			downloads: []bool{false},
+		},
+		{
+			name:      "DelayMapPin",
+			faults:    []models.L3afDFault{{Type: FaultDelayMapPin, Program: "ratelimiting", Count: 1, Delay: 5}},
+			program:   "ratelimiting",
+			downloads: []bool{false},
+			delay:     5 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &faultRegistry{faults: make(map[string]*models.L3afDFault)}
+			for i := range tt.faults {
+				r.faults[faultKey(tt.faults[i].Type, tt.faults[i].Program)] = &tt.faults[i]
+			}
+			for i, want := range tt.downloads {
+				if err := r.downloadFault(tt.program); (err != nil) != want {
+					t.Errorf("downloadFault() call %d error = %v, want failure %v", i, err, want)
+				}
+			}
+			if got := r.mapPinDelay(tt.program); got != tt.delay {
+				t.Errorf("mapPinDelay() = %v, want %v", got, tt.delay)
+			}
+			if got := r.mapPinDelay(tt.program); got != 0 {
+				t.Errorf("mapPinDelay() second call = %v, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	incidentBundleExt  = ".tar.gz"
+	incidentSummaryExt = ".json"
+	consoleFileExt     = ".console"
+	dmesgTimeout       = 10 * time.Second
+)
+
+// dmesgBPFLines - kernel log lines of the verifier, BPF and XDP drivers kept in the bundle
+var dmesgBPFLines = regexp.MustCompile(`(?i)bpf|xdp|verifier`)
+
+// crashForensicsConfig - incident bundles of the crashed NF processes, disabled when dir is empty
+type crashForensicsConfig struct {
+	dir         string
+	outputLines int
+	maxBundles  int
+}
+
+var crashForensics crashForensicsConfig
+
+// setCrashForensics - configures the incident bundles from l3afd.cfg, bundles are kept under the BPF log dir
+// unless the dir is configured
+func setCrashForensics(conf *config.Config) {
+	if conf == nil || !conf.CrashForensicsEnabled {
+		crashForensics = crashForensicsConfig{}
+		return
+	}
+	dir := conf.CrashForensicsDir
+	if len(dir) == 0 {
+		dir = filepath.Join(conf.BPFLogDir, "crash")
+	}
+	crashForensics = crashForensicsConfig{
+		dir:         dir,
+		outputLines: conf.CrashForensicsOutputLines,
+		maxBundles:  conf.CrashForensicsMaxBundles,
+	}
+}
+
+// consoleFile - file capturing the output of the user program, the output is not kept when the incident
+// bundles are disabled
+func consoleFile(ifaceName, direction, name string) string {
+	if len(crashForensics.dir) == 0 {
+		return ""
+	}
+	return filepath.Join(crashForensics.dir, ifaceName+"_"+direction+"_"+name+consoleFileExt)
+}
+
+// openConsole - truncates the console file of the program and returns it for the output of the user program
+func openConsole(ifaceName, direction, name string) *os.File {
+	fileName := consoleFile(ifaceName, direction, name)
+	if len(fileName) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(crashForensics.dir, 0750); err != nil {
+		log.Warn().Err(err).Msgf("failed to create crash forensics dir %s", crashForensics.dir)
+		return nil
+	}
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to open console file of program %s", name)
+		return nil
+	}
+	return f
+}
+
+// captureIncident - writes the incident bundle of the crashed user program i.e. exit status, last lines of
+// the output, BPF related kernel log lines and the maps of the program
+func (b *BPF) captureIncident(ifaceName, direction string) {
+	if len(crashForensics.dir) == 0 {
+		return
+	}
+	incident := models.L3afDNFIncident{
+		Iface:        ifaceName,
+		Direction:    direction,
+		Name:         b.Program.Name,
+		Version:      b.Program.Version,
+		Time:         time.Now().UTC().Format(time.RFC3339),
+		RestartCount: b.RestartCount,
+		ExitCode:     -1,
+	}
+	incident.ID = incidentID(time.Now().UTC(), ifaceName, direction, b.Program.Name)
+	if status, code, ok := b.reapExitStatus(); ok {
+		incident.ExitStatus, incident.ExitCode = status, code
+	}
+
+	files := map[string][]byte{}
+	output, err := appFS.ReadFile(consoleFile(ifaceName, direction, b.Program.Name))
+	if err != nil {
+		output = []byte(fmt.Sprintf("output is not available: %v\n", err))
+	}
+	files["output.log"] = lastLines(output, crashForensics.outputLines)
+	files["dmesg.log"] = bpfKernelLog()
+	if files["maps.json"], err = json.MarshalIndent(b.mapStats(), "", "  "); err != nil {
+		log.Warn().Err(err).Msgf("failed to marshal map stats of program %s", b.Program.Name)
+	}
+	if files["incident.json"], err = json.MarshalIndent(incident, "", "  "); err != nil {
+		log.Error().Err(err).Msgf("failed to marshal incident of program %s", b.Program.Name)
+		return
+	}
+
+	if err := writeIncidentBundle(crashForensics.dir, incident.ID, files); err != nil {
+		log.Error().Err(err).Msgf("failed to write incident bundle of program %s", b.Program.Name)
+		return
+	}
+	log.Warn().Msgf("program %s iface %s direction %s crashed with %s, incident bundle %s",
+		b.Program.Name, ifaceName, direction, incident.ExitStatus, incident.ID)
+	pruneIncidents(crashForensics.dir, crashForensics.maxBundles)
+}
+
+// reapExitStatus - collects the exit status of the exited user program, the process is not waited while it runs
+func (b *BPF) reapExitStatus() (string, int, bool) {
+	if b.Cmd == nil || b.Cmd.Process == nil {
+		return "", -1, false
+	}
+	if b.Cmd.ProcessState == nil {
+		if running, _ := IsProcessRunning(b.Cmd.Process.Pid, b.Program.Name); running {
+			return "", -1, false
+		}
+		if err := b.Cmd.Wait(); err != nil && b.Cmd.ProcessState == nil {
+			log.Warn().Err(err).Msgf("failed to collect the exit status of program %s", b.Program.Name)
+			return "", -1, false
+		}
+	}
+	return b.Cmd.ProcessState.String(), b.Cmd.ProcessState.ExitCode(), true
+}
+
+// incidentID - bundle name of the incident, sortable by time
+func incidentID(t time.Time, ifaceName, direction, name string) string {
+	return t.Format("20060102T150405Z") + "_" + ifaceName + "_" + direction + "_" + name
+}
+
+// lastLines - last n lines of the output, all the lines when n is not positive
+func lastLines(output []byte, n int) []byte {
+	output = bytes.TrimRight(output, "\n")
+	if n <= 0 {
+		return append(output, '\n')
+	}
+	lines := bytes.Split(output, []byte("\n"))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append(bytes.Join(lines, []byte("\n")), '\n')
+}
+
+// bpfKernelLog - BPF related lines of the kernel log
+func bpfKernelLog() []byte {
+	out, err := runNFCommand(execCommand("dmesg"), dmesgTimeout)
+	if err != nil {
+		return []byte(fmt.Sprintf("dmesg failed: %v\n", err))
+	}
+	var buf bytes.Buffer
+	for _, line := range strings.Split(out, "\n") {
+		if dmesgBPFLines.MatchString(line) {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// incidentMapStats - map details of the crashed program
+type incidentMapStats struct {
+	Name       string `json:"name"`
+	ID         uint32 `json:"id"`
+	Type       string `json:"type"`
+	KeySize    uint32 `json:"key_size"`
+	ValueSize  uint32 `json:"value_size"`
+	MaxEntries uint32 `json:"max_entries"`
+	Error      string `json:"error,omitempty"`
+}
+
+// mapStats - details of the config and metrics maps of the program known to l3afd
+func (b *BPF) mapStats() []incidentMapStats {
+	maps := map[string]BPFMap{}
+	for name, m := range b.BpfMaps {
+		maps[name] = m
+	}
+	for _, m := range b.MetricsBpfMaps {
+		maps[m.Name] = m.BPFMap
+	}
+	names := make([]string, 0, len(maps))
+	for name := range maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]incidentMapStats, 0, len(names))
+	for _, name := range names {
+		s := incidentMapStats{Name: name, ID: uint32(maps[name].MapID), Type: maps[name].Type.String()}
+		m, err := bpfAPI.NewMapFromID(maps[name].MapID)
+		if err != nil {
+			s.Error = err.Error()
+			result = append(result, s)
+			continue
+		}
+		if info, err := m.Info(); err != nil {
+			s.Error = err.Error()
+		} else {
+			s.KeySize, s.ValueSize, s.MaxEntries = info.KeySize, info.ValueSize, info.MaxEntries
+		}
+		m.Close()
+		result = append(result, s)
+	}
+	return result
+}
+
+// writeIncidentBundle - writes the files into the gzipped tar bundle and the incident summary next to it
+func writeIncidentBundle(dir, id string, files map[string][]byte) error {
+	if err := appFS.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create dir %s: %w", dir, err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hdr := &tar.Header{Name: id + "/" + name, Mode: 0640, Size: int64(len(files[name])), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	if err := appFS.WriteFile(filepath.Join(dir, id+incidentBundleExt), buf.Bytes(), 0640); err != nil {
+		return err
+	}
+	return appFS.WriteFile(filepath.Join(dir, id+incidentSummaryExt), files["incident.json"], 0640)
+}
+
+// pruneIncidents - removes the oldest bundles beyond max, 0 keeps all the bundles
+func pruneIncidents(dir string, max int) {
+	if max <= 0 {
+		return
+	}
+	incidents, err := listIncidents(dir)
+	if err != nil || len(incidents) <= max {
+		return
+	}
+	for _, incident := range incidents[max:] {
+		for _, ext := range []string{incidentBundleExt, incidentSummaryExt} {
+			if err := appFS.Remove(filepath.Join(dir, incident.ID+ext)); err != nil {
+				log.Warn().Err(err).Msgf("failed to remove incident bundle %s", incident.ID)
+			}
+		}
+	}
+}
+
+// listIncidents - incident summaries of the bundles in the dir, the latest first
+func listIncidents(dir string) ([]models.L3afDNFIncident, error) {
+	entries, err := appFS.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var incidents []models.L3afDNFIncident
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), incidentSummaryExt) {
+			continue
+		}
+		buf, err := appFS.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Warn().Err(err).Msgf("failed to read incident %s", entry.Name())
+			continue
+		}
+		var incident models.L3afDNFIncident
+		if err := json.Unmarshal(buf, &incident); err != nil {
+			log.Warn().Err(err).Msgf("failed to unmarshal incident %s", entry.Name())
+			continue
+		}
+		incidents = append(incidents, incident)
+	}
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].ID > incidents[j].ID })
+	return incidents, nil
+}
+
+// Incidents - crash incidents of the NF processes, the latest first
+func (c *NFConfigs) Incidents() ([]models.L3afDNFIncident, error) {
+	if len(crashForensics.dir) == 0 {
+		return nil, fmt.Errorf("crash forensics is disabled")
+	}
+	return listIncidents(crashForensics.dir)
+}
+
+// IncidentBundle - gzipped tar bundle of the incident
+func (c *NFConfigs) IncidentBundle(id string) ([]byte, error) {
+	if len(crashForensics.dir) == 0 {
+		return nil, fmt.Errorf("crash forensics is disabled")
+	}
+	if len(id) == 0 || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid incident id %q", id)
+	}
+	return appFS.ReadFile(filepath.Join(crashForensics.dir, id+incidentBundleExt))
+}
@@ -0,0 +1,100 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_lastLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		n      int
+		want   string
+	}{
+		{name: "Fewer", output: "a\nb\n", n: 5, want: "a\nb\n"},
+		{name: "Last", output: "a\nb\nc\nd\n", n: 2, want: "c\nd\n"},
+		{name: "NoTrailingNewline", output: "a\nb\nc", n: 1, want: "c\n"},
+		{name: "All", output: "a\nb\nc\n", n: 0, want: "a\nb\nc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(lastLines([]byte(tt.output), tt.n)); got != tt.want {
+				t.Errorf("lastLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncidentBundles(t *testing.T) {
+	useMemFS(t, map[string]string{})
+	dir := "/var/log/l3afd/crash"
+	saved := crashForensics
+	crashForensics = crashForensicsConfig{dir: dir, outputLines: 10, maxBundles: 2}
+	t.Cleanup(func() { crashForensics = saved })
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		id := incidentID(start.Add(time.Duration(i)*time.Minute), "eth0", models.XDPIngressType, "ratelimiting")
+		files := map[string][]byte{
+			"incident.json": []byte(`{"id":"` + id + `","name":"ratelimiting","exit_code":1}`),
+			"output.log":    []byte("panic: boom\n"),
+		}
+		if err := writeIncidentBundle(dir, id, files); err != nil {
+			t.Fatalf("writeIncidentBundle() error = %v", err)
+		}
+		pruneIncidents(dir, crashForensics.maxBundles)
+	}
+
+	c := &NFConfigs{}
+	incidents, err := c.Incidents()
+	if err != nil {
+		t.Fatalf("Incidents() error = %v", err)
+	}
+	if len(incidents) != 2 {
+		t.Fatalf("Incidents() returned %d incidents, want 2", len(incidents))
+	}
+	if want := "20240501T100200Z_eth0_xdpingress_ratelimiting"; incidents[0].ID != want {
+		t.Errorf("latest incident = %s, want %s", incidents[0].ID, want)
+	}
+
+	bundle, err := c.IncidentBundle(incidents[0].ID)
+	if err != nil {
+		t.Fatalf("IncidentBundle() error = %v", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("bundle is not gzipped: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("bundle is not a tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	want := []string{incidents[0].ID + "/incident.json", incidents[0].ID + "/output.log"}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] {
+		t.Errorf("bundle files = %v, want %v", names, want)
+	}
+
+	if _, err := c.IncidentBundle("../../etc/passwd"); err == nil {
+		t.Errorf("IncidentBundle() accepted a path outside the bundles dir")
+	}
+}
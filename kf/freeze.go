@@ -0,0 +1,131 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrConfigFrozen - mutating config operation is rejected during the config freeze
+var ErrConfigFrozen = errors.New("config is frozen")
+
+// configFreeze - freeze state set by the config file at startup and toggled by the admin API
+type configFreeze struct {
+	mu    sync.Mutex
+	state models.L3afDFreeze
+}
+
+var freeze = &configFreeze{}
+
+func (f *configFreeze) get() models.L3afDFreeze {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+func (f *configFreeze) set(state models.L3afDFreeze) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = state
+}
+
+// loadFreeze - freezes the config when enabled in the config file, otherwise restores the freeze toggled by
+// the admin API before the restart
+func (c *NFConfigs) loadFreeze() {
+	freeze.set(models.L3afDFreeze{})
+	if c.hostConfig == nil {
+		return
+	}
+	if c.hostConfig.ConfigFreezeEnabled {
+		log.Warn().Msg("config is frozen by the config file, mutating config operations are rejected")
+		freeze.set(models.L3afDFreeze{
+			Frozen: true,
+			Reason: c.hostConfig.ConfigFreezeReason,
+			Since:  time.Now().UTC().Format(time.RFC3339),
+			By:     "config",
+		})
+		return
+	}
+	if len(c.hostConfig.ConfigFreezeFileName) == 0 {
+		return
+	}
+	buf, err := ReadStateFile(c.hostConfig.ConfigFreezeFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msgf("failed to read config freeze from %s", c.hostConfig.ConfigFreezeFileName)
+		}
+		return
+	}
+	var state models.L3afDFreeze
+	if err := json.Unmarshal(buf, &state); err != nil {
+		log.Error().Err(err).Msgf("failed to unmarshal config freeze of %s", c.hostConfig.ConfigFreezeFileName)
+		return
+	}
+	if state.Frozen {
+		log.Warn().Msgf("config is frozen since %s, mutating config operations are rejected", state.Since)
+	}
+	freeze.set(state)
+}
+
+func (c *NFConfigs) saveFreeze(state models.L3afDFreeze) {
+	if len(c.hostConfig.ConfigFreezeFileName) == 0 {
+		return
+	}
+	buf, err := json.Marshal(state)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal config freeze")
+		return
+	}
+	if err := writeStateFile(c.hostConfig.ConfigFreezeFileName, buf, 0600); err != nil {
+		log.Error().Err(err).Msgf("failed to write config freeze to %s", c.hostConfig.ConfigFreezeFileName)
+	}
+}
+
+// ConfigFreeze - current freeze state
+func (c *NFConfigs) ConfigFreeze() models.L3afDFreeze {
+	return freeze.get()
+}
+
+// SetConfigFreeze - freezes or unfreezes the config, supervision and metrics continue during the freeze
+func (c *NFConfigs) SetConfigFreeze(frozen bool, reason, remote string) models.L3afDFreeze {
+	state := models.L3afDFreeze{}
+	if frozen {
+		state = models.L3afDFreeze{
+			Frozen: true,
+			Reason: reason,
+			Since:  time.Now().UTC().Format(time.RFC3339),
+			By:     remote,
+		}
+		log.Warn().Msgf("config is frozen by %s: %s", remote, reason)
+		c.Audit("config-freeze", remote, map[string]string{"reason": reason})
+	} else {
+		log.Info().Msgf("config is unfrozen by %s", remote)
+		c.Audit("config-unfreeze", remote, map[string]string{"reason": reason})
+	}
+	freeze.set(state)
+	c.saveFreeze(state)
+	return state
+}
+
+// RejectFrozen - returns ErrConfigFrozen and audits the attempt when the config is frozen
+func (c *NFConfigs) RejectFrozen(action, remote string, details map[string]string) error {
+	if !freeze.get().Frozen {
+		return nil
+	}
+	log.Warn().Msgf("%s from %s rejected, config is frozen", action, remote)
+	audited := map[string]string{"action": action}
+	for k, v := range details {
+		audited[k] = v
+	}
+	c.Audit("frozen-reject", remote, audited)
+	return ErrConfigFrozen
+}
@@ -0,0 +1,54 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_ConfigFreeze(t *testing.T) {
+	m := useMemFS(t, map[string]string{})
+	t.Cleanup(func() { freeze.set(models.L3afDFreeze{}) })
+	c := &NFConfigs{hostConfig: &config.Config{
+		ConfigFreezeFileName: "/etc/l3afd/l3af-freeze.json",
+		AuditLogFile:         "/var/log/l3afd/audit.log",
+	}}
+
+	if err := c.RejectFrozen("api", "10.0.0.1:52314", nil); err != nil {
+		t.Fatalf("RejectFrozen() error = %v before the freeze", err)
+	}
+	c.SetConfigFreeze(true, "INC-1234", "10.0.0.1:52314")
+	err := c.RejectFrozen("api", "10.0.0.2:41000", map[string]string{"path": "/l3af/configs/v1/update"})
+	if !errors.Is(err, ErrConfigFrozen) {
+		t.Fatalf("RejectFrozen() error = %v, want ErrConfigFrozen", err)
+	}
+	audit, _ := m.ReadFile(c.hostConfig.AuditLogFile)
+	if !strings.Contains(string(audit), `"action":"frozen-reject"`) || !strings.Contains(string(audit), "/l3af/configs/v1/update") {
+		t.Errorf("rejected attempt is not audited, audit log %s", audit)
+	}
+
+	// freeze toggled by the API is kept across restarts
+	freeze.set(models.L3afDFreeze{})
+	c.loadFreeze()
+	if got := c.ConfigFreeze(); !got.Frozen || got.Reason != "INC-1234" {
+		t.Errorf("ConfigFreeze() = %+v after restart, want frozen for INC-1234", got)
+	}
+
+	c.SetConfigFreeze(false, "", "10.0.0.1:52314")
+	c.loadFreeze()
+	if err := c.RejectFrozen("api", "10.0.0.2:41000", nil); err != nil {
+		t.Errorf("RejectFrozen() error = %v after the freeze is lifted", err)
+	}
+
+	c.hostConfig.ConfigFreezeEnabled = true
+	c.loadFreeze()
+	if got := c.ConfigFreeze(); !got.Frozen || got.By != "config" {
+		t.Errorf("ConfigFreeze() = %+v, want frozen by the config file", got)
+	}
+}
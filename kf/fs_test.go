@@ -0,0 +1,177 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_findBPFObjects(t *testing.T) {
+	useMemFS(t, map[string]string{
+		"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/ratelimiting":              "elf",
+		"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/ratelimiting_kern.o":       "elf",
+		"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/bpf/ratelimiting_tc.o":     "elf",
+		"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/bpf/README.md":             "doc",
+		"/var/l3afd/connection-limit/1.0/l3af_connection_limit/connection_kern.o": "elf",
+	})
+
+	tests := []struct {
+		name    string
+		dir     string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "NestedObjects",
+			dir:  "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting",
+			want: []string{
+				"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/bpf/ratelimiting_tc.o",
+				"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/ratelimiting_kern.o",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "MissingDir",
+			dir:     "/var/l3afd/absent",
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findBPFObjects(tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("findBPFObjects() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findBPFObjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBPF_createUpdateRulesFile(t *testing.T) {
+	m := useMemFS(t, map[string]string{})
+
+	tests := []struct {
+		name       string
+		program    models.BPFProgram
+		nfFilesDir string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:    "RulesFile",
+			program: models.BPFProgram{RulesFile: "rules.txt", Rules: "10.0.0.0/8 drop"},
+			want:    "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/xdpingress/rules.txt",
+			wantErr: false,
+		},
+		{
+			name:       "NFFilesDir",
+			program:    models.BPFProgram{Name: "ratelimiting", RulesFile: "rules.txt", Rules: "10.0.0.0/8 drop"},
+			nfFilesDir: "/run/l3afd/nf",
+			want:       "/run/l3afd/nf/ratelimiting/xdpingress/rules.txt",
+			wantErr:    false,
+		},
+		{
+			name:       "RulesFileOutsideDir",
+			program:    models.BPFProgram{Name: "ratelimiting", RulesFile: "../../etc/rules.txt", Rules: "10.0.0.0/8 drop"},
+			nfFilesDir: "/run/l3afd/nf",
+			want:       "",
+			wantErr:    true,
+		},
+		{
+			name:    "NoRulesFile",
+			program: models.BPFProgram{Rules: "10.0.0.0/8 drop"},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := nfCmdConfig
+			t.Cleanup(func() { nfCmdConfig = saved })
+			nfCmdConfig.nfFilesDir = tt.nfFilesDir
+
+			b := &BPF{Program: tt.program, FilePath: "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting"}
+			got, err := b.createUpdateRulesFile(models.XDPIngressType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("createUpdateRulesFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("createUpdateRulesFile() = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			if data, err := m.ReadFile(got); err != nil || string(data) != tt.program.Rules {
+				t.Errorf("rules file content = %q, err %v, want %q", data, err, tt.program.Rules)
+			}
+		})
+	}
+}
+
+func TestBPF_KFConfigFilePath(t *testing.T) {
+	useMemFS(t, map[string]string{})
+
+	tests := []struct {
+		name       string
+		configFile string
+		nfFilesDir string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "Absolute",
+			configFile: "/etc/ratelimiting/config.json",
+			nfFilesDir: "/run/l3afd/nf",
+			want:       "/etc/ratelimiting/config.json",
+		},
+		{
+			name:       "RelativeInNFFilesDir",
+			configFile: "config.json",
+			nfFilesDir: "/run/l3afd/nf",
+			want:       "/run/l3afd/nf/ratelimiting/xdpingress/config.json",
+		},
+		{
+			name:       "RelativeInArtifactDir",
+			configFile: "config.json",
+			want:       "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/xdpingress/config.json",
+		},
+		{
+			name:       "OutsideDir",
+			configFile: "../config.json",
+			nfFilesDir: "/run/l3afd/nf",
+			wantErr:    true,
+		},
+		{
+			name:    "Empty",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := nfCmdConfig
+			t.Cleanup(func() { nfCmdConfig = saved })
+			nfCmdConfig.nfFilesDir = tt.nfFilesDir
+
+			b := &BPF{
+				Program:  models.BPFProgram{Name: "ratelimiting", ConfigFilePath: tt.configFile},
+				FilePath: "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting",
+			}
+			got, err := b.KFConfigFilePath(models.XDPIngressType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("KFConfigFilePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("KFConfigFilePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
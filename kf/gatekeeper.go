@@ -0,0 +1,217 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// gatekeeperLinkPin - pin of the link of the gatekeeper in its pin dir
+const gatekeeperLinkPin = "link"
+
+// gatekeeperKey - key of the map of the approved binaries, the inode and the kernel device number of the
+// executable as in task->mm->exe_file->f_inode of the gatekeeper
+type gatekeeperKey struct {
+	Ino uint64
+	Dev uint32
+	_   uint32
+}
+
+// bpfGatekeeper - binaries approved to load programs by the LSM BPF gatekeeper, by reference count of the
+// programs running them
+type bpfGatekeeper struct {
+	mu      sync.Mutex
+	allowed ebpfMap
+	refs    map[gatekeeperKey]int
+}
+
+// gatekeeper - nil when the gatekeeper is disabled
+var gatekeeper *bpfGatekeeper
+
+// setBPFGatekeeper - approves l3afd and the allowed binaries and attaches the gatekeeper of l3afd.cfg, the
+// attached gatekeeper of the previous run is reused. The gatekeeper left attached is detached when disabled.
+func setBPFGatekeeper(conf *config.Config) error {
+	gatekeeper = nil
+	if conf == nil {
+		return nil
+	}
+	if !conf.BPFGatekeeperEnabled {
+		return detachGatekeeper(conf.BPFGatekeeperPinDir)
+	}
+
+	allowed, prog, err := loadGatekeeper(conf)
+	if err != nil {
+		return err
+	}
+	if prog != nil {
+		defer prog.Close()
+	}
+	g := &bpfGatekeeper{allowed: allowed, refs: make(map[gatekeeperKey]int)}
+
+	// l3afd loads the root programs and accesses the pinned objects of the chaining
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the l3afd executable: %w", err)
+	}
+	if _, err := g.approve(self, ""); err != nil {
+		return err
+	}
+	for _, binary := range conf.BPFGatekeeperAllowedBinaries {
+		path, digest := binary, ""
+		if i := strings.Index(binary, "="); i >= 0 {
+			path, digest = binary[:i], binary[i+1:]
+		}
+		if _, err := g.approve(strings.TrimSpace(path), strings.TrimSpace(digest)); err != nil {
+			return err
+		}
+	}
+
+	// binaries are approved before the gatekeeper is attached
+	if prog != nil {
+		if err := attachLSM(prog.FD(), filepath.Join(conf.BPFGatekeeperPinDir, gatekeeperLinkPin)); err != nil {
+			return fmt.Errorf("failed to attach the bpf gatekeeper: %w", err)
+		}
+		log.Info().Msgf("bpf gatekeeper %s version %s attached", conf.BPFGatekeeperName, conf.BPFGatekeeperVersion)
+	}
+	gatekeeper = g
+	return nil
+}
+
+// loadGatekeeper - map of the approved binaries of the attached gatekeeper, or the map and the program of the
+// gatekeeper loaded from its artifact, the program is attached once the binaries are approved
+func loadGatekeeper(conf *config.Config) (ebpfMap, *ebpf.Program, error) {
+	mapPin := filepath.Join(conf.BPFGatekeeperPinDir, conf.BPFGatekeeperMap)
+	if fileExists(filepath.Join(conf.BPFGatekeeperPinDir, gatekeeperLinkPin)) {
+		allowed, err := bpfAPI.LoadPinnedMap(mapPin, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load the map of the attached bpf gatekeeper: %w", err)
+		}
+		log.Info().Msgf("bpf gatekeeper is attached already, reusing it")
+		return allowed, nil, nil
+	}
+
+	if len(conf.BPFGatekeeperVersion) == 0 {
+		return nil, nil, fmt.Errorf("version of the bpf gatekeeper artifact is not configured")
+	}
+	b := &BPF{Program: models.BPFProgram{Name: conf.BPFGatekeeperName, Artifact: conf.BPFGatekeeperArtifact, Version: conf.BPFGatekeeperVersion}}
+	if err := b.VerifyAndGetArtifacts(conf); err != nil {
+		return nil, nil, fmt.Errorf("failed to get the bpf gatekeeper artifact: %w", err)
+	}
+	spec, err := ebpf.LoadCollectionSpec(filepath.Join(b.FilePath, conf.BPFGatekeeperObject))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the bpf gatekeeper object: %w", err)
+	}
+	coll, err := ebpf.NewCollection(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the bpf gatekeeper: %w", err)
+	}
+	defer coll.Close()
+	if p, ok := coll.Programs[conf.BPFGatekeeperProgram]; !ok || p.Type() != ebpf.LSM {
+		return nil, nil, fmt.Errorf("bpf gatekeeper object has no lsm program %s", conf.BPFGatekeeperProgram)
+	}
+	if _, ok := coll.Maps[conf.BPFGatekeeperMap]; !ok {
+		return nil, nil, fmt.Errorf("bpf gatekeeper object has no map %s", conf.BPFGatekeeperMap)
+	}
+	allowed, prog := coll.DetachMap(conf.BPFGatekeeperMap), coll.DetachProgram(conf.BPFGatekeeperProgram)
+
+	if err := os.MkdirAll(conf.BPFGatekeeperPinDir, 0700); err != nil {
+		allowed.Close()
+		prog.Close()
+		return nil, nil, fmt.Errorf("failed to create the bpf gatekeeper pin dir: %w", err)
+	}
+	if err := allowed.Pin(mapPin); err != nil {
+		allowed.Close()
+		prog.Close()
+		return nil, nil, fmt.Errorf("failed to pin the map of the bpf gatekeeper: %w", err)
+	}
+	return allowed, prog, nil
+}
+
+// detachGatekeeper - removes the pins of the gatekeeper left attached, the gatekeeper is detached
+func detachGatekeeper(pinDir string) error {
+	if len(pinDir) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(pinDir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := os.RemoveAll(pinDir); err != nil {
+		return fmt.Errorf("failed to detach the bpf gatekeeper: %w", err)
+	}
+	log.Info().Msgf("bpf gatekeeper is disabled, detached")
+	return nil
+}
+
+// approve - approves the binary to load programs, the binary must match the hex sha256 when set
+func (g *bpfGatekeeper) approve(path, digest string) (gatekeeperKey, error) {
+	if len(digest) > 0 {
+		hash, err := binaryHash(path)
+		if err != nil {
+			return gatekeeperKey{}, fmt.Errorf("failed to hash binary %s: %w", path, err)
+		}
+		if !strings.EqualFold(hash, digest) {
+			return gatekeeperKey{}, fmt.Errorf("binary %s sha256 %s does not match the approved sha256", path, hash)
+		}
+	}
+	key, err := executableKey(path)
+	if err != nil {
+		return gatekeeperKey{}, fmt.Errorf("failed to identify binary %s: %w", path, err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.refs[key] == 0 {
+		if err := g.allowed.Update(key, uint8(1), ebpf.UpdateAny); err != nil {
+			return gatekeeperKey{}, fmt.Errorf("failed to approve binary %s: %w", path, err)
+		}
+	}
+	g.refs[key]++
+	return key, nil
+}
+
+// revoke - binary is not approved anymore once no program runs it
+func (g *bpfGatekeeper) revoke(key gatekeeperKey) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.refs[key]--; g.refs[key] > 0 {
+		return
+	}
+	delete(g.refs, key)
+	if err := g.allowed.Delete(key); err != nil {
+		log.Warn().Err(err).Msgf("failed to revoke binary inode %d of the bpf gatekeeper", key.Ino)
+	}
+}
+
+// approveExecutable - approves the binary of the program to load programs before it is started
+func (b *BPF) approveExecutable(path string) error {
+	if gatekeeper == nil {
+		return nil
+	}
+	b.revokeExecutable()
+	key, err := gatekeeper.approve(path, "")
+	if err != nil {
+		return err
+	}
+	b.approvedExe = &key
+	return nil
+}
+
+// revokeExecutable - binary of the stopped program is not approved anymore
+func (b *BPF) revokeExecutable() {
+	if gatekeeper != nil && b.approvedExe != nil {
+		gatekeeper.revoke(*b.approvedExe)
+	}
+	b.approvedExe = nil
+}
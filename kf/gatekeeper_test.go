@@ -0,0 +1,150 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+// fakeGatekeeperMap - map of the approved binaries of the gatekeeper
+type fakeGatekeeperMap struct {
+	entries map[gatekeeperKey]uint8
+	updates int
+}
+
+func (m *fakeGatekeeperMap) Lookup(key, valueOut interface{}) error {
+	v, ok := m.entries[key.(gatekeeperKey)]
+	if !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	*valueOut.(*uint8) = v
+	return nil
+}
+
+func (m *fakeGatekeeperMap) NextKey(key, nextKeyOut interface{}) error {
+	return errors.New("not supported")
+}
+
+func (m *fakeGatekeeperMap) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	m.entries[key.(gatekeeperKey)] = value.(uint8)
+	m.updates++
+	return nil
+}
+
+func (m *fakeGatekeeperMap) Delete(key interface{}) error {
+	if _, ok := m.entries[key.(gatekeeperKey)]; !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	delete(m.entries, key.(gatekeeperKey))
+	return nil
+}
+
+func (m *fakeGatekeeperMap) Info() (*ebpf.MapInfo, error) { return nil, errors.New("not supported") }
+func (m *fakeGatekeeperMap) Close() error                 { return nil }
+
+func Test_executableKey(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "ratelimiting"), filepath.Join(dir, "connection-limit")
+	for _, p := range []string{first, second} {
+		if err := os.WriteFile(p, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(dir, "ratelimiting-link")
+	if err := os.Symlink(first, link); err != nil {
+		t.Fatal(err)
+	}
+
+	firstKey, err := executableKey(first)
+	if err != nil {
+		t.Fatalf("executableKey() error = %v", err)
+	}
+	secondKey, _ := executableKey(second)
+	linkKey, _ := executableKey(link)
+	if firstKey.Ino == 0 || firstKey == secondKey {
+		t.Errorf("executableKey() = %+v and %+v, want distinct keys of the binaries", firstKey, secondKey)
+	}
+	if linkKey != firstKey {
+		t.Errorf("executableKey() of the symlink = %+v, want the key %+v of its target", linkKey, firstKey)
+	}
+	if _, err := executableKey(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("executableKey() of a missing binary succeeded")
+	}
+}
+
+func Test_bpfGatekeeper(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "ratelimiting")
+	data := []byte("#!/bin/sh\nexit 0\n")
+	if err := os.WriteFile(binary, data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	allowed := &fakeGatekeeperMap{entries: make(map[gatekeeperKey]uint8)}
+	g := &bpfGatekeeper{allowed: allowed, refs: make(map[gatekeeperKey]int)}
+	if _, err := g.approve(binary, "0000"); err == nil {
+		t.Fatalf("approve() of a mismatched sha256 succeeded")
+	}
+	if len(allowed.entries) != 0 {
+		t.Fatalf("entries = %v, want the mismatched binary not approved", allowed.entries)
+	}
+
+	key, err := g.approve(binary, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("approve() error = %v", err)
+	}
+	if _, err := g.approve(binary, ""); err != nil {
+		t.Fatalf("approve() error = %v", err)
+	}
+	if allowed.entries[key] != 1 || allowed.updates != 1 {
+		t.Errorf("entries = %v updates %d, want the binary approved once", allowed.entries, allowed.updates)
+	}
+
+	// binary stays approved while a program runs it
+	g.revoke(key)
+	if _, ok := allowed.entries[key]; !ok {
+		t.Errorf("binary revoked while a program runs it")
+	}
+	g.revoke(key)
+	if _, ok := allowed.entries[key]; ok || len(g.refs) != 0 {
+		t.Errorf("entries = %v refs %v, want the binary revoked", allowed.entries, g.refs)
+	}
+}
+
+func TestBPF_approveExecutable(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "ratelimiting")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	b := &BPF{}
+	if err := b.approveExecutable(binary); err != nil || b.approvedExe != nil {
+		t.Fatalf("approveExecutable() = %v approved %v, want nothing approved without the gatekeeper", err, b.approvedExe)
+	}
+
+	allowed := &fakeGatekeeperMap{entries: make(map[gatekeeperKey]uint8)}
+	gatekeeper = &bpfGatekeeper{allowed: allowed, refs: make(map[gatekeeperKey]int)}
+	defer func() { gatekeeper = nil }()
+
+	// restarted program holds a single approval
+	for i := 0; i < 2; i++ {
+		if err := b.approveExecutable(binary); err != nil {
+			t.Fatalf("approveExecutable() error = %v", err)
+		}
+	}
+	if len(allowed.entries) != 1 || gatekeeper.refs[*b.approvedExe] != 1 {
+		t.Errorf("entries = %v refs %v, want the binary approved once", allowed.entries, gatekeeper.refs)
+	}
+	b.revokeExecutable()
+	if len(allowed.entries) != 0 || b.approvedExe != nil {
+		t.Errorf("entries = %v, want the binary revoked", allowed.entries)
+	}
+}
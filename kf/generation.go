@@ -0,0 +1,95 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// generationStore - last applied config generation, persisted so the retries of the control plane are safe
+// across restarts
+type generationStore struct {
+	mu      sync.Mutex
+	applied *models.L3afDApplyGeneration
+}
+
+var appliedGeneration = &generationStore{}
+
+// AppliedGeneration - returns the last applied config generation, false when no generation is applied
+func (c *NFConfigs) AppliedGeneration() (models.L3afDApplyGeneration, bool) {
+	appliedGeneration.mu.Lock()
+	defer appliedGeneration.mu.Unlock()
+	if appliedGeneration.applied == nil {
+		return models.L3afDApplyGeneration{}, false
+	}
+	return *appliedGeneration.applied, true
+}
+
+// CheckGeneration - returns the recorded result when the generation is applied already, and an error when
+// the generation is older than the last applied generation
+func (c *NFConfigs) CheckGeneration(generation uint64) (*models.L3afDApplyGeneration, error) {
+	applied, ok := c.AppliedGeneration()
+	if !ok || generation > applied.Generation {
+		return nil, nil
+	}
+	if generation < applied.Generation {
+		return nil, fmt.Errorf("config generation %d is older than the applied generation %d", generation, applied.Generation)
+	}
+	return &applied, nil
+}
+
+// RecordGeneration - records the result of the apply of the generation and writes it to the persistent store
+func (c *NFConfigs) RecordGeneration(generation uint64, statusCode int, message string) {
+	applied := &models.L3afDApplyGeneration{
+		Generation: generation,
+		StatusCode: statusCode,
+		Message:    message,
+		AppliedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+	appliedGeneration.mu.Lock()
+	appliedGeneration.applied = applied
+	appliedGeneration.mu.Unlock()
+
+	if len(c.hostConfig.ConfigGenerationFileName) == 0 {
+		return
+	}
+	buf, err := json.Marshal(applied)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal config generation")
+		return
+	}
+	if err := writeStateFile(c.hostConfig.ConfigGenerationFileName, buf, 0600); err != nil {
+		log.Error().Err(err).Msgf("failed to write config generation to %s", c.hostConfig.ConfigGenerationFileName)
+	}
+}
+
+// loadAppliedGeneration - reads the last applied config generation of the persistent store
+func (c *NFConfigs) loadAppliedGeneration() {
+	if c.hostConfig == nil || len(c.hostConfig.ConfigGenerationFileName) == 0 {
+		return
+	}
+	buf, err := ReadStateFile(c.hostConfig.ConfigGenerationFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msgf("failed to read config generation from %s", c.hostConfig.ConfigGenerationFileName)
+		}
+		return
+	}
+	var applied models.L3afDApplyGeneration
+	if err := json.Unmarshal(buf, &applied); err != nil {
+		log.Error().Err(err).Msgf("failed to unmarshal config generation of %s", c.hostConfig.ConfigGenerationFileName)
+		return
+	}
+	appliedGeneration.mu.Lock()
+	appliedGeneration.applied = &applied
+	appliedGeneration.mu.Unlock()
+}
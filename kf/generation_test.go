@@ -0,0 +1,65 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+func TestNFConfigs_CheckGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		generation uint64
+		wantReplay bool
+		wantErr    bool
+	}{
+		{
+			name:       "NewGeneration",
+			generation: 8,
+			wantReplay: false,
+			wantErr:    false,
+		},
+		{
+			name:       "Replay",
+			generation: 7,
+			wantReplay: true,
+			wantErr:    false,
+		},
+		{
+			name:       "Stale",
+			generation: 6,
+			wantReplay: false,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := appliedGeneration
+			appliedGeneration = &generationStore{}
+			t.Cleanup(func() { appliedGeneration = saved })
+			useMemFS(t, nil)
+
+			c := &NFConfigs{hostConfig: &config.Config{ConfigGenerationFileName: "/etc/l3afd/l3af-generation.json"}}
+			c.RecordGeneration(7, http.StatusInternalServerError, "failed to deploy ebpf programs")
+
+			// recorded generation is read back from the store after restart
+			appliedGeneration = &generationStore{}
+			c.loadAppliedGeneration()
+
+			got, err := c.CheckGeneration(tt.generation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckGeneration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got != nil) != tt.wantReplay {
+				t.Fatalf("CheckGeneration() = %v, wantReplay %v", got, tt.wantReplay)
+			}
+			if got != nil && (got.StatusCode != http.StatusInternalServerError || got.Message != "failed to deploy ebpf programs") {
+				t.Errorf("CheckGeneration() = %v, want the recorded result", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultHeartbeatInterval - interval of the heartbeat writes when l3afd.cfg does not set one
+	defaultHeartbeatInterval = 5 * time.Second
+	// heartbeatTimeoutIntervals - missed heartbeats the program waits for when the timeout is not set
+	heartbeatTimeoutIntervals = 3
+	// heartbeatTick - period the heartbeats due are written at
+	heartbeatTick = time.Second
+	// heartbeatValueSize - {u64 timestamp_ns; u64 timeout_ns; u32 behavior; u32 pad}
+	heartbeatValueSize = 24
+)
+
+// Behavior of the heartbeat value read by the kernel program
+const (
+	heartbeatBehaviorOpen   uint32 = 0
+	heartbeatBehaviorClosed uint32 = 1
+)
+
+// validateHeartbeat - map is set, the key is a u32, the durations are positive with the timeout longer than the
+// interval and the behavior is known
+func validateHeartbeat(h *models.L3afDNFHeartbeat) error {
+	if h == nil {
+		return nil
+	}
+	if len(h.MapName) == 0 {
+		return fmt.Errorf("heartbeat map name is not set")
+	}
+	if h.Key < 0 || int64(h.Key) > int64(^uint32(0)) {
+		return fmt.Errorf("heartbeat key %d is not a u32", h.Key)
+	}
+	for _, d := range []string{h.Interval, h.Timeout} {
+		if len(d) == 0 {
+			continue
+		}
+		if v, err := time.ParseDuration(d); err != nil || v <= 0 {
+			return fmt.Errorf("heartbeat duration %q is not a positive duration", d)
+		}
+	}
+	if heartbeatTimeout(h) <= heartbeatInterval(h) {
+		return fmt.Errorf("heartbeat timeout %s must be longer than the interval %s", heartbeatTimeout(h), heartbeatInterval(h))
+	}
+	switch h.Behavior {
+	case "", models.HeartbeatFailOpen, models.HeartbeatFailClosed:
+	default:
+		return fmt.Errorf("unknown heartbeat behavior %q, expected %s or %s", h.Behavior,
+			models.HeartbeatFailOpen, models.HeartbeatFailClosed)
+	}
+	return nil
+}
+
+// ValidateHeartbeats - Verifies the heartbeat maps, intervals and behaviors of the programs
+func ValidateHeartbeats(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateHeartbeat(ref.prog.Heartbeat); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// heartbeatInterval - interval of the heartbeat writes of the program
+func heartbeatInterval(h *models.L3afDNFHeartbeat) time.Duration {
+	if d, err := time.ParseDuration(h.Interval); err == nil && d > 0 {
+		return d
+	}
+	if nfCmdConfig.heartbeatInterval > 0 {
+		return nfCmdConfig.heartbeatInterval
+	}
+	return defaultHeartbeatInterval
+}
+
+// heartbeatTimeout - age of the heartbeat the program considers l3afd gone
+func heartbeatTimeout(h *models.L3afDNFHeartbeat) time.Duration {
+	if d, err := time.ParseDuration(h.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return heartbeatTimeoutIntervals * heartbeatInterval(h)
+}
+
+// heartbeatEntry - key and value of the heartbeat at the monotonic timestamp, in the host byte order
+func heartbeatEntry(h *models.L3afDNFHeartbeat, timestamp uint64) ([]byte, []byte) {
+	key := make([]byte, 4)
+	nativeEndian.PutUint32(key, uint32(h.Key))
+	behavior := heartbeatBehaviorOpen
+	if h.Behavior == models.HeartbeatFailClosed {
+		behavior = heartbeatBehaviorClosed
+	}
+	value := make([]byte, heartbeatValueSize)
+	nativeEndian.PutUint64(value[0:8], timestamp)
+	nativeEndian.PutUint64(value[8:16], uint64(heartbeatTimeout(h)))
+	nativeEndian.PutUint32(value[16:20], behavior)
+	return key, value
+}
+
+// writeHeartbeat - writes the heartbeat entry to the heartbeat map of the program
+func writeHeartbeat(m ebpfMap, h *models.L3afDNFHeartbeat, timestamp uint64) error {
+	key, value := heartbeatEntry(h, timestamp)
+	if err := m.Update(key, value, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("failed to write the heartbeat to map %s: %w", h.MapName, err)
+	}
+	return nil
+}
+
+// heartbeat - writes the heartbeat of the program when its interval elapsed, the map is opened once per start of
+// the program and reopened after a failed write, which is retried at the next tick
+func (b *BPF) heartbeat(direction string, now time.Time) {
+	h := b.Program.Heartbeat
+	if h == nil || now.Sub(b.lastHeartbeat) < heartbeatInterval(h) {
+		return
+	}
+	timestamp, err := monotonicNow()
+	if err == nil && b.heartbeatMap == nil {
+		b.heartbeatMap, err = openProgramMap(b, h.MapName)
+	}
+	if err == nil {
+		err = writeHeartbeat(b.heartbeatMap, h, timestamp)
+	}
+	if err != nil {
+		if !b.heartbeatFailed {
+			log.Warn().Err(err).Msgf("heartbeat of program %s direction %s failed", b.Program.Name, direction)
+		}
+		b.heartbeatFailed = true
+		b.closeHeartbeat()
+		stats.Add(1, stats.NFHeartbeatFailures, b.Program.Name, direction)
+		return
+	}
+	if b.heartbeatFailed {
+		log.Info().Msgf("heartbeat of program %s direction %s resumed", b.Program.Name, direction)
+	}
+	b.heartbeatFailed = false
+	b.lastHeartbeat = now
+}
+
+// closeHeartbeat - closes the heartbeat map, the map of the restarted program is opened at the next heartbeat
+func (b *BPF) closeHeartbeat() {
+	if b.heartbeatMap != nil {
+		b.heartbeatMap.Close()
+		b.heartbeatMap = nil
+	}
+	b.lastHeartbeat = time.Time{}
+}
+
+// heartbeatStart - writes the heartbeats of the programs declaring a heartbeat map
+func heartbeatStart(xdpProgs, ingressTCProgs, egressTCProgs map[string]*list.List) {
+	go heartbeatWorker(xdpProgs, models.XDPIngressType)
+	go heartbeatWorker(ingressTCProgs, models.IngressType)
+	go heartbeatWorker(egressTCProgs, models.EgressType)
+}
+
+func heartbeatWorker(bpfProgs map[string]*list.List, direction string) {
+	for range time.NewTicker(heartbeatTick).C {
+		for _, bpfList := range bpfProgs {
+			if bpfList == nil { // no bpf programs are running
+				continue
+			}
+			for e := bpfList.Front(); e != nil; e = e.Next() {
+				bpf := e.Value.(*BPF)
+				if bpf.Program.Heartbeat == nil || bpf.Program.AdminStatus == models.Disabled {
+					continue
+				}
+				bpf.heartbeat(direction, time.Now())
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+// failingMap - map rejecting the updates
+type failingMap struct {
+	fakeHashMap
+	closed bool
+}
+
+func (m *failingMap) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	return errors.New("value size mismatch")
+}
+
+func (m *failingMap) Close() error {
+	m.closed = true
+	return nil
+}
+
+func Test_validateHeartbeat(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat *models.L3afDNFHeartbeat
+		wantErr   bool
+	}{
+		{"none", nil, false},
+		{"defaults", &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat"}, false},
+		{"valid", &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat", Key: 1, Interval: "2s", Timeout: "10s", Behavior: models.HeartbeatFailClosed}, false},
+		{"no map", &models.L3afDNFHeartbeat{Interval: "2s"}, true},
+		{"negative key", &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat", Key: -1}, true},
+		{"invalid interval", &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat", Interval: "often"}, true},
+		{"timeout under interval", &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat", Interval: "10s", Timeout: "5s"}, true},
+		{"unknown behavior", &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat", Behavior: "drop"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateHeartbeat(tt.heartbeat); (err != nil) != tt.wantErr {
+				t.Errorf("validateHeartbeat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_heartbeatEntry(t *testing.T) {
+	h := &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat", Key: 2, Interval: "2s", Behavior: models.HeartbeatFailClosed}
+	key, value := heartbeatEntry(h, 12345)
+	if len(key) != 4 || nativeEndian.Uint32(key) != 2 {
+		t.Errorf("key = %x, want the u32 2", key)
+	}
+	if len(value) != heartbeatValueSize {
+		t.Fatalf("value = %x, want %d bytes", value, heartbeatValueSize)
+	}
+	if nativeEndian.Uint64(value[0:8]) != 12345 {
+		t.Errorf("timestamp = %d, want 12345", nativeEndian.Uint64(value[0:8]))
+	}
+	// timeout defaults to 3 intervals
+	if timeout := time.Duration(nativeEndian.Uint64(value[8:16])); timeout != 6*time.Second {
+		t.Errorf("timeout = %s, want 6s", timeout)
+	}
+	if nativeEndian.Uint32(value[16:20]) != heartbeatBehaviorClosed {
+		t.Errorf("behavior = %d, want fail-closed", nativeEndian.Uint32(value[16:20]))
+	}
+
+	h.Behavior = ""
+	if _, value := heartbeatEntry(h, 1); nativeEndian.Uint32(value[16:20]) != heartbeatBehaviorOpen {
+		t.Errorf("behavior = %d, want fail-open by default", nativeEndian.Uint32(value[16:20]))
+	}
+}
+
+func TestBPF_heartbeat(t *testing.T) {
+	if _, err := monotonicNow(); err != nil {
+		t.Skipf("monotonic clock is not available: %v", err)
+	}
+	m := &fakeHashMap{mapType: ebpf.Array, entries: make(map[string][]byte)}
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", Heartbeat: &models.L3afDNFHeartbeat{MapName: "l3afd_heartbeat", Interval: "5s"}}}
+	b.heartbeatMap = m
+
+	now := time.Now()
+	b.heartbeat(models.XDPIngressType, now)
+	key, _ := heartbeatEntry(b.Program.Heartbeat, 0)
+	first := m.entries[string(key)]
+	if len(first) != heartbeatValueSize || nativeEndian.Uint64(first[0:8]) == 0 {
+		t.Fatalf("heartbeat entry = %x, want the monotonic timestamp written", first)
+	}
+
+	// heartbeat is written once per interval
+	delete(m.entries, string(key))
+	b.heartbeat(models.XDPIngressType, now.Add(time.Second))
+	if _, ok := m.entries[string(key)]; ok {
+		t.Errorf("heartbeat written before its interval elapsed")
+	}
+	b.heartbeat(models.XDPIngressType, now.Add(5*time.Second))
+	if _, ok := m.entries[string(key)]; !ok {
+		t.Errorf("heartbeat not written after its interval elapsed")
+	}
+
+	// failed write closes the map for the next heartbeat to reopen it
+	failing := &failingMap{}
+	b.heartbeatMap = failing
+	b.heartbeat(models.XDPIngressType, now.Add(10*time.Second))
+	if !b.heartbeatFailed || !failing.closed || b.heartbeatMap != nil || !b.lastHeartbeat.IsZero() {
+		t.Errorf("failed heartbeat = failed %v closed %v map %v last %s, want the map closed", b.heartbeatFailed, failing.closed, b.heartbeatMap, b.lastHeartbeat)
+	}
+}
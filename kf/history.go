@@ -0,0 +1,223 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// historyStore - last applied configs, oldest first, persisted so the history survives restarts
+type historyStore struct {
+	mu      sync.Mutex
+	entries []models.L3afDConfigRevision
+}
+
+var configHistory = &historyStore{}
+
+// recordHistory - records the applied configs as a new revision when they differ from the last revision,
+// the oldest revisions beyond the max entries are dropped
+func (c *NFConfigs) recordHistory(bpfProgs []models.L3afBPFPrograms) {
+	if c.hostConfig == nil || c.hostConfig.ConfigHistoryMaxEntries <= 0 {
+		return
+	}
+	buf, err := json.Marshal(bpfProgs)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal configs of the history")
+		return
+	}
+	var configs []models.L3afBPFPrograms
+	if err := json.Unmarshal(buf, &configs); err != nil {
+		log.Error().Err(err).Msg("failed to copy configs of the history")
+		return
+	}
+
+	configHistory.mu.Lock()
+	var revision uint64 = 1
+	if n := len(configHistory.entries); n > 0 {
+		last := configHistory.entries[n-1]
+		if lastBuf, err := json.Marshal(last.Configs); err == nil && bytes.Equal(lastBuf, buf) {
+			configHistory.mu.Unlock()
+			return
+		}
+		revision = last.Revision + 1
+	}
+	configHistory.entries = append(configHistory.entries, models.L3afDConfigRevision{
+		Revision:  revision,
+		AppliedAt: time.Now().UTC().Format(time.RFC3339),
+		Configs:   configs,
+	})
+	if over := len(configHistory.entries) - c.hostConfig.ConfigHistoryMaxEntries; over > 0 {
+		configHistory.entries = configHistory.entries[over:]
+	}
+	entries := configHistory.entries
+	configHistory.mu.Unlock()
+
+	if len(c.hostConfig.ConfigHistoryFileName) == 0 {
+		return
+	}
+	if buf, err = json.Marshal(entries); err != nil {
+		log.Error().Err(err).Msg("failed to marshal config history")
+		return
+	}
+	if err := writeStateFile(c.hostConfig.ConfigHistoryFileName, buf, 0600); err != nil {
+		log.Error().Err(err).Msgf("failed to write config history to %s", c.hostConfig.ConfigHistoryFileName)
+	}
+}
+
+// loadHistory - reads the config history of the persistent store
+func (c *NFConfigs) loadHistory() {
+	configHistory.mu.Lock()
+	defer configHistory.mu.Unlock()
+	configHistory.entries = nil
+	if c.hostConfig == nil || len(c.hostConfig.ConfigHistoryFileName) == 0 {
+		return
+	}
+	buf, err := ReadStateFile(c.hostConfig.ConfigHistoryFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msgf("failed to read config history from %s", c.hostConfig.ConfigHistoryFileName)
+		}
+		return
+	}
+	if err := json.Unmarshal(buf, &configHistory.entries); err != nil {
+		log.Error().Err(err).Msgf("failed to unmarshal config history of %s", c.hostConfig.ConfigHistoryFileName)
+		configHistory.entries = nil
+	}
+}
+
+// ConfigHistory - revisions of the config history without the configs, the latest first
+func (c *NFConfigs) ConfigHistory() []models.L3afDConfigRevision {
+	configHistory.mu.Lock()
+	defer configHistory.mu.Unlock()
+	revisions := make([]models.L3afDConfigRevision, 0, len(configHistory.entries))
+	for i := len(configHistory.entries) - 1; i >= 0; i-- {
+		e := configHistory.entries[i]
+		revisions = append(revisions, models.L3afDConfigRevision{Revision: e.Revision, AppliedAt: e.AppliedAt})
+	}
+	return revisions
+}
+
+// ConfigRevision - revision of the config history with the configs, zero is the latest revision
+func (c *NFConfigs) ConfigRevision(revision uint64) (models.L3afDConfigRevision, error) {
+	configHistory.mu.Lock()
+	defer configHistory.mu.Unlock()
+	n := len(configHistory.entries)
+	if n == 0 {
+		return models.L3afDConfigRevision{}, fmt.Errorf("config history is empty")
+	}
+	if revision == 0 {
+		return configHistory.entries[n-1], nil
+	}
+	for _, e := range configHistory.entries {
+		if e.Revision == revision {
+			return e, nil
+		}
+	}
+	return models.L3afDConfigRevision{}, fmt.Errorf("config revision %d is not in the history", revision)
+}
+
+// DiffConfigRevisions - changes of the programs from a revision to another, zero to is the latest revision
+// and zero from is the revision before to
+func (c *NFConfigs) DiffConfigRevisions(from, to uint64) (models.L3afDConfigDiff, error) {
+	toRev, err := c.ConfigRevision(to)
+	if err != nil {
+		return models.L3afDConfigDiff{}, err
+	}
+	if from == 0 {
+		if toRev.Revision == 1 {
+			return models.L3afDConfigDiff{}, fmt.Errorf("config revision %d has no previous revision", toRev.Revision)
+		}
+		from = toRev.Revision - 1
+	}
+	fromRev, err := c.ConfigRevision(from)
+	if err != nil {
+		return models.L3afDConfigDiff{}, err
+	}
+	return models.L3afDConfigDiff{
+		From:    fromRev.Revision,
+		To:      toRev.Revision,
+		Changes: diffConfigs(fromRev.Configs, toRev.Configs),
+	}, nil
+}
+
+// revisionProgram - program of a config revision keyed by interface, direction and name
+type revisionProgram struct {
+	iface     string
+	direction string
+	name      string
+}
+
+func revisionPrograms(configs []models.L3afBPFPrograms) map[revisionProgram]*models.BPFProgram {
+	progs := make(map[revisionProgram]*models.BPFProgram)
+	for _, cfg := range configs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog != nil {
+				progs[revisionProgram{iface: cfg.Iface, direction: ref.direction, name: ref.prog.Name}] = ref.prog
+			}
+		}
+	}
+	return progs
+}
+
+// diffConfigs - added, removed and changed programs sorted by interface, direction and name, the changed
+// fields are compared in their JSON encoding
+func diffConfigs(from, to []models.L3afBPFPrograms) []models.L3afDConfigChange {
+	fromProgs, toProgs := revisionPrograms(from), revisionPrograms(to)
+	changes := make([]models.L3afDConfigChange, 0)
+	for key, prog := range toProgs {
+		change := models.L3afDConfigChange{Iface: key.iface, Direction: key.direction, Name: key.name}
+		old, ok := fromProgs[key]
+		if !ok {
+			change.Change = models.ConfigChangeAdded
+			changes = append(changes, change)
+			continue
+		}
+		if change.Fields = diffProgramFields(old, prog); len(change.Fields) > 0 {
+			change.Change = models.ConfigChangeChanged
+			changes = append(changes, change)
+		}
+	}
+	for key := range fromProgs {
+		if _, ok := toProgs[key]; !ok {
+			changes = append(changes, models.L3afDConfigChange{Iface: key.iface, Direction: key.direction, Name: key.name, Change: models.ConfigChangeRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Iface != changes[j].Iface {
+			return changes[i].Iface < changes[j].Iface
+		}
+		if changes[i].Direction != changes[j].Direction {
+			return changes[i].Direction < changes[j].Direction
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func diffProgramFields(from, to *models.BPFProgram) []models.L3afDFieldChange {
+	var fromFields, toFields map[string]json.RawMessage
+	fromBuf, _ := json.Marshal(from)
+	toBuf, _ := json.Marshal(to)
+	if json.Unmarshal(fromBuf, &fromFields) != nil || json.Unmarshal(toBuf, &toFields) != nil {
+		return nil
+	}
+	var fields []models.L3afDFieldChange
+	for name, value := range toFields {
+		if !bytes.Equal(fromFields[name], value) {
+			fields = append(fields, models.L3afDFieldChange{Field: name, From: fromFields[name], To: value})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
@@ -0,0 +1,75 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func historyConfigs(progs ...*models.BPFProgram) []models.L3afBPFPrograms {
+	return []models.L3afBPFPrograms{{
+		HostName:    "l3af-test-host",
+		Iface:       "eth0",
+		BpfPrograms: &models.BPFPrograms{XDPIngress: progs},
+	}}
+}
+
+func TestNFConfigs_recordHistory(t *testing.T) {
+	useMemFS(t, map[string]string{})
+	t.Cleanup(func() { configHistory.entries = nil })
+	c := &NFConfigs{hostConfig: &config.Config{
+		ConfigHistoryFileName:   "/etc/l3afd/l3af-history.json",
+		ConfigHistoryMaxEntries: 2,
+	}}
+	c.loadHistory()
+
+	rl := &models.BPFProgram{Name: "ratelimiting", Version: "1.0", SeqID: 1}
+	c.recordHistory(historyConfigs(rl))
+	c.recordHistory(historyConfigs(rl)) // unchanged
+	rl.Version = "1.1"
+	c.recordHistory(historyConfigs(rl))
+	c.recordHistory(historyConfigs(rl, &models.BPFProgram{Name: "connection-limit", Version: "1.0", SeqID: 2}))
+
+	got := c.ConfigHistory()
+	if len(got) != 2 || got[0].Revision != 3 || got[1].Revision != 2 {
+		t.Fatalf("ConfigHistory() = %+v, want revisions 3 and 2", got)
+	}
+	if rev, err := c.ConfigRevision(2); err != nil || rev.Configs[0].BpfPrograms.XDPIngress[0].Version != "1.1" {
+		t.Errorf("ConfigRevision(2) = %+v, %v, want ratelimiting 1.1", rev, err)
+	}
+	if _, err := c.ConfigRevision(1); err == nil {
+		t.Errorf("ConfigRevision(1) error = nil, want the dropped revision not found")
+	}
+
+	// history is kept across restarts
+	configHistory.entries = nil
+	c.loadHistory()
+	if got := c.ConfigHistory(); len(got) != 2 || got[0].Revision != 3 {
+		t.Errorf("ConfigHistory() = %+v after restart, want revisions 3 and 2", got)
+	}
+}
+
+func Test_diffConfigs(t *testing.T) {
+	from := historyConfigs(
+		&models.BPFProgram{Name: "ratelimiting", Version: "1.0", SeqID: 1},
+		&models.BPFProgram{Name: "connection-limit", Version: "1.0", SeqID: 2},
+	)
+	to := historyConfigs(
+		&models.BPFProgram{Name: "ratelimiting", Version: "1.1", SeqID: 1},
+		&models.BPFProgram{Name: "ipfix-flow-exporter", Version: "1.0", SeqID: 2},
+	)
+	want := []models.L3afDConfigChange{
+		{Iface: "eth0", Direction: models.XDPIngressType, Name: "connection-limit", Change: models.ConfigChangeRemoved},
+		{Iface: "eth0", Direction: models.XDPIngressType, Name: "ipfix-flow-exporter", Change: models.ConfigChangeAdded},
+		{Iface: "eth0", Direction: models.XDPIngressType, Name: "ratelimiting", Change: models.ConfigChangeChanged,
+			Fields: []models.L3afDFieldChange{{Field: "version", From: []byte(`"1.0"`), To: []byte(`"1.1"`)}}},
+	}
+	if got := diffConfigs(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffConfigs() = %+v, want %+v", got, want)
+	}
+}
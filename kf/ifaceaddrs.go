@@ -0,0 +1,67 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ifaceAddrArgs - returns the start arguments with the IPv4 and IPv6 addresses of the interface.
+// Packets of ingress direction are destined to the interface addresses, so addresses are passed as
+// --dst-ipv4 and --dst-ipv6, and packets of egress direction are sourced from them, passed as --src-ipv4
+// and --src-ipv6.
+func ifaceAddrArgs(ifaceName, direction string) ([]string, error) {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find iface %s: %w", ifaceName, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of iface %s: %w", ifaceName, err)
+	}
+	return addrArgs(addrs, direction), nil
+}
+
+// addrArgs - formats the addresses as comma separated lists by family, link local addresses are skipped
+func addrArgs(addrs []net.Addr, direction string) []string {
+	prefix := "--dst-"
+	if direction == models.EgressType {
+		prefix = "--src-"
+	}
+
+	v4 := make([]string, 0, len(addrs))
+	v6 := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		var ip net.IP
+		switch a := addr.(type) {
+		case *net.IPNet:
+			ip = a.IP
+		case *net.IPAddr:
+			ip = a.IP
+		default:
+			continue
+		}
+		if ip.IsLinkLocalUnicast() || ip.IsLoopback() {
+			continue
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, ip.String())
+		} else {
+			v6 = append(v6, ip.String())
+		}
+	}
+
+	args := make([]string, 0, 2)
+	if len(v4) > 0 {
+		args = append(args, prefix+"ipv4="+strings.Join(v4, ","))
+	}
+	if len(v6) > 0 {
+		args = append(args, prefix+"ipv6="+strings.Join(v6, ","))
+	}
+	return args
+}
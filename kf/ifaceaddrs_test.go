@@ -0,0 +1,58 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_addrArgs(t *testing.T) {
+	cidr := func(s string) net.Addr {
+		ip, ipNet, _ := net.ParseCIDR(s)
+		ipNet.IP = ip
+		return ipNet
+	}
+	tests := []struct {
+		name      string
+		addrs     []net.Addr
+		direction string
+		want      []string
+	}{
+		{
+			name:      "DualStackIngress",
+			addrs:     []net.Addr{cidr("10.1.1.5/24"), cidr("2001:db8::5/64"), cidr("fe80::1/64")},
+			direction: models.XDPIngressType,
+			want:      []string{"--dst-ipv4=10.1.1.5", "--dst-ipv6=2001:db8::5"},
+		},
+		{
+			name:      "DualStackEgress",
+			addrs:     []net.Addr{cidr("10.1.1.5/24"), cidr("10.1.2.5/24"), cidr("2001:db8::5/64")},
+			direction: models.EgressType,
+			want:      []string{"--src-ipv4=10.1.1.5,10.1.2.5", "--src-ipv6=2001:db8::5"},
+		},
+		{
+			name:      "IPv6Only",
+			addrs:     []net.Addr{&net.IPAddr{IP: net.ParseIP("2001:db8::5")}},
+			direction: models.IngressType,
+			want:      []string{"--dst-ipv6=2001:db8::5"},
+		},
+		{
+			name:      "LinkLocalOnly",
+			addrs:     []net.Addr{cidr("169.254.1.1/16"), cidr("fe80::1/64")},
+			direction: models.IngressType,
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addrArgs(tt.addrs, tt.direction); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addrArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/l3af-project/l3afd/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ifaceChannels - ethtool channel counts of an interface
+type ifaceChannels struct {
+	MaxRx       uint32
+	MaxTx       uint32
+	MaxCombined uint32
+	Rx          uint32
+	Tx          uint32
+	Combined    uint32
+}
+
+// ifaceQueueState - channels and RSS indirection table of the interface before the config was applied
+type ifaceQueueState struct {
+	channels    *ifaceChannels
+	rssIndirect []uint32
+}
+
+type ifaceQueueRegistry struct {
+	mu     sync.Mutex
+	states map[string]*ifaceQueueState // key is iface name
+}
+
+var ifaceQueues = &ifaceQueueRegistry{states: make(map[string]*ifaceQueueState)}
+
+// ApplyIfaceQueues - applies the configured channels and RSS of the interface and saves the previous values
+// to be restored, nothing to do when the interface has no iface-queues config or the config is already applied.
+func ApplyIfaceQueues(ifaceName string, queues config.IfaceQueues) error {
+	ifaceQueues.mu.Lock()
+	defer ifaceQueues.mu.Unlock()
+
+	if _, ok := ifaceQueues.states[ifaceName]; ok {
+		return nil
+	}
+
+	state := &ifaceQueueState{}
+	if queues.CombinedChannels > 0 || queues.RxChannels > 0 || queues.TxChannels > 0 {
+		cur, err := getIfaceChannels(ifaceName)
+		if err != nil {
+			return fmt.Errorf("failed to get channels of iface %s: %w", ifaceName, err)
+		}
+		next, err := desiredChannels(cur, queues)
+		if err != nil {
+			return fmt.Errorf("invalid channels config of iface %s: %w", ifaceName, err)
+		}
+		if *next != *cur {
+			if err := setIfaceChannels(ifaceName, next); err != nil {
+				return fmt.Errorf("failed to set channels of iface %s: %w", ifaceName, err)
+			}
+			state.channels = cur
+			log.Info().Msgf("iface %s channels set to rx %d tx %d combined %d", ifaceName, next.Rx, next.Tx, next.Combined)
+		}
+	}
+
+	if queues.RSSQueues > 0 {
+		cur, err := getRSSIndirection(ifaceName)
+		if err != nil {
+			state.restore(ifaceName)
+			return fmt.Errorf("failed to get RSS indirection table of iface %s: %w", ifaceName, err)
+		}
+		table, err := rssIndirectionTable(len(cur), queues.RSSQueues)
+		if err != nil {
+			state.restore(ifaceName)
+			return fmt.Errorf("invalid RSS config of iface %s: %w", ifaceName, err)
+		}
+		if err := setRSSIndirection(ifaceName, table); err != nil {
+			state.restore(ifaceName)
+			return fmt.Errorf("failed to set RSS indirection table of iface %s: %w", ifaceName, err)
+		}
+		state.rssIndirect = cur
+		log.Info().Msgf("iface %s RSS spread over %d queues", ifaceName, queues.RSSQueues)
+	}
+
+	ifaceQueues.states[ifaceName] = state
+	return nil
+}
+
+// RestoreIfaceQueues - restores the channels and RSS of the interface saved before the config was applied
+func RestoreIfaceQueues(ifaceName string) {
+	ifaceQueues.mu.Lock()
+	defer ifaceQueues.mu.Unlock()
+
+	state, ok := ifaceQueues.states[ifaceName]
+	if !ok {
+		return
+	}
+	state.restore(ifaceName)
+	delete(ifaceQueues.states, ifaceName)
+}
+
+func (s *ifaceQueueState) restore(ifaceName string) {
+	if s.rssIndirect != nil {
+		if err := setRSSIndirection(ifaceName, s.rssIndirect); err != nil {
+			log.Warn().Err(err).Msgf("failed to restore RSS indirection table of iface %s", ifaceName)
+		}
+	}
+	if s.channels != nil {
+		if err := setIfaceChannels(ifaceName, s.channels); err != nil {
+			log.Warn().Err(err).Msgf("failed to restore channels of iface %s", ifaceName)
+		}
+	}
+	log.Info().Msgf("iface %s channels and RSS restored", ifaceName)
+}
+
+// desiredChannels - returns the channel counts after applying the config, validated against the device maximums
+func desiredChannels(cur *ifaceChannels, queues config.IfaceQueues) (*ifaceChannels, error) {
+	next := *cur
+	if queues.CombinedChannels > 0 {
+		if uint32(queues.CombinedChannels) > cur.MaxCombined {
+			return nil, fmt.Errorf("combined channels %d exceeds device maximum %d", queues.CombinedChannels, cur.MaxCombined)
+		}
+		next.Combined = uint32(queues.CombinedChannels)
+	}
+	if queues.RxChannels > 0 {
+		if uint32(queues.RxChannels) > cur.MaxRx {
+			return nil, fmt.Errorf("rx channels %d exceeds device maximum %d", queues.RxChannels, cur.MaxRx)
+		}
+		next.Rx = uint32(queues.RxChannels)
+	}
+	if queues.TxChannels > 0 {
+		if uint32(queues.TxChannels) > cur.MaxTx {
+			return nil, fmt.Errorf("tx channels %d exceeds device maximum %d", queues.TxChannels, cur.MaxTx)
+		}
+		next.Tx = uint32(queues.TxChannels)
+	}
+	return &next, nil
+}
+
+// rssIndirectionTable - spreads the RSS indirection table of the size equally over the first queues,
+// same as ethtool -X <iface> equal N
+func rssIndirectionTable(size, queues int) ([]uint32, error) {
+	if size == 0 {
+		return nil, fmt.Errorf("device does not support RSS indirection table")
+	}
+	if queues > size {
+		return nil, fmt.Errorf("rss queues %d exceeds indirection table size %d", queues, size)
+	}
+	table := make([]uint32, size)
+	for i := range table {
+		table[i] = uint32(i % queues)
+	}
+	return table, nil
+}
@@ -0,0 +1,98 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+func Test_desiredChannels(t *testing.T) {
+	cur := &ifaceChannels{MaxRx: 0, MaxTx: 0, MaxCombined: 8, Combined: 8}
+	tests := []struct {
+		name    string
+		queues  config.IfaceQueues
+		want    *ifaceChannels
+		wantErr bool
+	}{
+		{
+			name:    "Combined",
+			queues:  config.IfaceQueues{CombinedChannels: 4},
+			want:    &ifaceChannels{MaxCombined: 8, Combined: 4},
+			wantErr: false,
+		},
+		{
+			name:    "Unchanged",
+			queues:  config.IfaceQueues{RSSQueues: 2},
+			want:    &ifaceChannels{MaxCombined: 8, Combined: 8},
+			wantErr: false,
+		},
+		{
+			name:    "CombinedExceedsMax",
+			queues:  config.IfaceQueues{CombinedChannels: 16},
+			wantErr: true,
+		},
+		{
+			name:    "RxNotSupported",
+			queues:  config.IfaceQueues{RxChannels: 2},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := desiredChannels(cur, tt.queues)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("desiredChannels() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("desiredChannels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rssIndirectionTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		queues  int
+		want    []uint32
+		wantErr bool
+	}{
+		{
+			name:    "Equal",
+			size:    8,
+			queues:  3,
+			want:    []uint32{0, 1, 2, 0, 1, 2, 0, 1},
+			wantErr: false,
+		},
+		{
+			name:    "NotSupported",
+			size:    0,
+			queues:  2,
+			wantErr: true,
+		},
+		{
+			name:    "QueuesExceedTable",
+			size:    4,
+			queues:  8,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rssIndirectionTable(tt.size, tt.queues)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rssIndirectionTable() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rssIndirectionTable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,9 +9,16 @@ package kf
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"unsafe"
@@ -45,6 +52,122 @@ func DisableLRO(ifaceName string) error {
 	return nil
 }
 
+// getIfaceDriver - returns the driver name of the interface
+// # ethtool -i ens7
+func getIfaceDriver(ifaceName string) (string, error) {
+	ethHandle, err := ethtool.NewEthtool()
+	if err != nil {
+		return "", fmt.Errorf("ethtool failed to get the handle %w", err)
+	}
+	defer ethHandle.Close()
+
+	return ethHandle.DriverName(ifaceName)
+}
+
+// getIfaceChannels - returns the channel counts of the interface
+// # ethtool -l ens7
+func getIfaceChannels(ifaceName string) (*ifaceChannels, error) {
+	ethHandle, err := ethtool.NewEthtool()
+	if err != nil {
+		return nil, fmt.Errorf("ethtool failed to get the handle %w", err)
+	}
+	defer ethHandle.Close()
+
+	channels, err := ethHandle.GetChannels(ifaceName)
+	if err != nil {
+		return nil, err
+	}
+	return &ifaceChannels{
+		MaxRx:       channels.MaxRx,
+		MaxTx:       channels.MaxTx,
+		MaxCombined: channels.MaxCombined,
+		Rx:          channels.RxCount,
+		Tx:          channels.TxCount,
+		Combined:    channels.CombinedCount,
+	}, nil
+}
+
+// setIfaceChannels - sets the channel counts of the interface
+// # ethtool -L ens7 combined 4
+func setIfaceChannels(ifaceName string, channels *ifaceChannels) error {
+	ethHandle, err := ethtool.NewEthtool()
+	if err != nil {
+		return fmt.Errorf("ethtool failed to get the handle %w", err)
+	}
+	defer ethHandle.Close()
+
+	cur, err := ethHandle.GetChannels(ifaceName)
+	if err != nil {
+		return err
+	}
+	cur.RxCount = channels.Rx
+	cur.TxCount = channels.Tx
+	cur.CombinedCount = channels.Combined
+	_, err = ethHandle.SetChannels(ifaceName, cur)
+	return err
+}
+
+// ethtool RSS indirection table commands, see linux/ethtool.h
+const (
+	ethtoolGRXFHINDIR = 0x00000038
+	ethtoolSRXFHINDIR = 0x00000039
+)
+
+// rssIndirIoctl - issues the ethtool rxfh indirection command with the buffer laid out as
+// struct ethtool_rxfh_indir { __u32 cmd; __u32 size; __u32 ring_index[]; }
+func rssIndirIoctl(ifaceName string, buf []uint32) error {
+	if len(ifaceName) >= unix.IFNAMSIZ {
+		return fmt.Errorf("invalid iface name %s", ifaceName)
+	}
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	var ifr struct {
+		name [unix.IFNAMSIZ]byte
+		data uintptr
+	}
+	copy(ifr.name[:], ifaceName)
+	ifr.data = uintptr(unsafe.Pointer(&buf[0]))
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr)))
+	runtime.KeepAlive(buf)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// getRSSIndirection - returns the RSS indirection table of the interface
+// # ethtool -x ens7
+func getRSSIndirection(ifaceName string) ([]uint32, error) {
+	// size 0 returns the table size
+	buf := []uint32{ethtoolGRXFHINDIR, 0}
+	if err := rssIndirIoctl(ifaceName, buf); err != nil {
+		return nil, err
+	}
+	size := buf[1]
+	if size == 0 {
+		return []uint32{}, nil
+	}
+	buf = make([]uint32, 2+size)
+	buf[0], buf[1] = ethtoolGRXFHINDIR, size
+	if err := rssIndirIoctl(ifaceName, buf); err != nil {
+		return nil, err
+	}
+	return buf[2:], nil
+}
+
+// setRSSIndirection - sets the RSS indirection table of the interface
+// # ethtool -X ens7 equal 4
+func setRSSIndirection(ifaceName string, table []uint32) error {
+	buf := make([]uint32, 2+len(table))
+	buf[0], buf[1] = ethtoolSRXFHINDIR, uint32(len(table))
+	copy(buf[2:], table)
+	return rssIndirIoctl(ifaceName, buf)
+}
+
 // prLimit set the memory and cpu limits for the bpf program
 func prLimit(pid int, limit uintptr, rlimit *unix.Rlimit) error {
 	_, _, errno := unix.RawSyscall6(unix.SYS_PRLIMIT64,
@@ -86,12 +209,48 @@ func (b *BPF) SetPrLimits() error {
 		}
 	}
 
+	if b.Program.CoreDumps {
+		rlimit.Cur = unix.RLIM_INFINITY
+		rlimit.Max = unix.RLIM_INFINITY
+		if err := prLimit(b.Cmd.Process.Pid, unix.RLIMIT_CORE, &rlimit); err != nil {
+			log.Error().Err(err).Msgf("Failed to set core limits - %s", b.Program.Name)
+		}
+	}
+
 	return nil
 }
 
-// ProcessTerminate - Send sigterm to the process
+// setProcessGroup - NF process leads its own process group, so the helpers it forks are stopped along with it
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// assignNFJob - process group of the NF process is set before it is started
+func assignNFJob(cmd *exec.Cmd) {
+}
+
+// signalNFProcess - signals the process group led by the NF process, or the process only when it was not
+// started in its own group. os.ErrProcessDone is returned when no process of the group is left.
+func signalNFProcess(cmd *exec.Cmd, sig syscall.Signal) error {
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setpgid {
+		return cmd.Process.Signal(sig)
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return nil
+}
+
+// ProcessTerminate - Send sigterm to the process group
 func (b *BPF) ProcessTerminate() error {
-	if err := b.Cmd.Process.Signal(syscall.SIGTERM); err != nil {
+	// process of a crash is already waited by the incident capture
+	if err := signalNFProcess(b.Cmd, syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
 		return fmt.Errorf("BPFProgram %s SIGTERM failed with error: %w", b.Program.Name, err)
 	}
 	return nil
@@ -104,12 +263,16 @@ func VerifyNMountBPFFS() error {
 	fstype := "bpf"
 	flags := 0
 
-	mnts, err := ioutil.ReadFile("/proc/mounts")
+	mnts, err := appFS.ReadFile("/proc/mounts")
 	if err != nil {
 		return fmt.Errorf("failed to read procfs: %v", err)
 	}
 
 	if !strings.Contains(string(mnts), dstPath) {
+		// bpf filesystem mounted in the container is not shared with the host and the pins vanish with it
+		if inContainer {
+			return fmt.Errorf("bpf filesystem of the host is not mounted on %s in the container", dstPath)
+		}
 		log.Warn().Msg("bpf filesystem is not mounted going to mount")
 		if err = syscall.Mount(srcPath, dstPath, fstype, uintptr(flags), ""); err != nil {
 			return fmt.Errorf("unable to mount %s at %s: %s", srcPath, dstPath, err)
@@ -135,7 +298,7 @@ func GetPlatform() (string, error) {
 }
 
 func IsProcessRunning(pid int, name string) (bool, error) {
-	procState, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	procState, err := appFS.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
 	if err != nil {
 		return false, fmt.Errorf("BPF Program not running %s because of error: %w", name, err)
 	}
@@ -150,3 +313,635 @@ func IsProcessRunning(pid int, name string) (bool, error) {
 
 	return true, nil
 }
+
+// processCmdline - executable path and command line arguments of the process
+func processCmdline(pid int) (string, []string, error) {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return "", nil, err
+	}
+	cmdline, err := appFS.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return "", nil, err
+	}
+	return exe, strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"), nil
+}
+
+// getKernelRelease - returns the release of the running kernel e.g. 5.4.0-1029-aws
+func getKernelRelease() (string, error) {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return "", fmt.Errorf("uname failed with error: %w", err)
+	}
+	return string(bytes.TrimRight(uname.Release[:], "\x00")), nil
+}
+
+// readProcessUsage - reads resource usage of the process from procfs
+func readProcessUsage(pid int) (*processUsage, error) {
+	procStat, err := appFS.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proc stat of process %d: %w", pid, err)
+	}
+	usage, err := parseProcStat(string(procStat), unix.Getpagesize())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proc stat of process %d: %w", pid, err)
+	}
+	fds, err := appFS.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read open fds of process %d: %w", pid, err)
+	}
+	usage.OpenFDs = len(fds)
+	return usage, nil
+}
+
+// dupFD - duplicates the fd with close on exec flag
+func dupFD(fd int) (int, error) {
+	return unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+}
+
+// readXDPStatistics - sums the statistics of the AF_XDP sockets of the process
+func readXDPStatistics(pid int) (*xdpStatistics, error) {
+	pidFD, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return nil, fmt.Errorf("pidfd open of process %d failed: %w", pid, err)
+	}
+	defer unix.Close(pidFD)
+
+	fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+	fds, err := appFS.ReadDir(fdDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read open fds of process %d: %w", pid, err)
+	}
+
+	total := &xdpStatistics{}
+	sockets := 0
+	for _, f := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, f.Name()))
+		if err != nil || !strings.HasPrefix(link, "socket:") {
+			continue
+		}
+		targetFD, err := strconv.Atoi(f.Name())
+		if err != nil {
+			continue
+		}
+		fd, err := unix.PidfdGetfd(pidFD, targetFD, 0)
+		if err != nil {
+			return nil, fmt.Errorf("pidfd getfd of process %d failed: %w", pid, err)
+		}
+		var xs xdpStatistics
+		size := uint32(unsafe.Sizeof(xs))
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.SOL_XDP, unix.XDP_STATISTICS,
+			uintptr(unsafe.Pointer(&xs)), uintptr(unsafe.Pointer(&size)), 0)
+		unix.Close(fd)
+		if errno != 0 { // not an AF_XDP socket
+			continue
+		}
+		sockets++
+		total.RxDropped += xs.RxDropped
+		total.RxInvalidDescs += xs.RxInvalidDescs
+		total.TxInvalidDescs += xs.TxInvalidDescs
+		total.RxRingFull += xs.RxRingFull
+		total.RxFillRingEmptyDescs += xs.RxFillRingEmptyDescs
+		total.TxRingEmptyDescs += xs.TxRingEmptyDescs
+	}
+
+	if sockets == 0 {
+		return nil, fmt.Errorf("no AF_XDP sockets found in process %d", pid)
+	}
+	return total, nil
+}
+
+// processExePath - returns the path to read the binary of the running process
+func processExePath(pid int) (string, error) {
+	return fmt.Sprintf("/proc/%d/exe", pid), nil
+}
+
+// isTmpfs - returns true when the directory is on a tmpfs mount
+func isTmpfs(dir string) (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return false, fmt.Errorf("failed to statfs %s: %w", dir, err)
+	}
+	return st.Type == unix.TMPFS_MAGIC, nil
+}
+
+// mountTmpfs - mounts a private tmpfs accessible only by l3afd on the directory
+func mountTmpfs(dir, size string) error {
+	data := "mode=0700"
+	if len(size) > 0 {
+		data += ",size=" + size
+	}
+	if err := unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, data); err != nil {
+		return fmt.Errorf("failed to mount tmpfs on %s: %w", dir, err)
+	}
+	return nil
+}
+
+// watchLinkUp - calls up with the interface name on the netlink events of the links coming up, until the
+// context is done
+func watchLinkUp(ctx context.Context, up func(ifaceName string)) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: unix.RTMGRP_LINK}); err != nil {
+		return fmt.Errorf("failed to subscribe to link events: %w", err)
+	}
+	// receive times out, so the context is checked
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: 1}); err != nil {
+		return fmt.Errorf("failed to set netlink socket timeout: %w", err)
+	}
+
+	buf := make([]byte, 64*1024)
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR || err == unix.ENOBUFS {
+				continue
+			}
+			return fmt.Errorf("failed to receive link events: %w", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to parse link events")
+			continue
+		}
+		for i := range msgs {
+			m := &msgs[i]
+			if m.Header.Type != unix.RTM_NEWLINK || len(m.Data) < unix.SizeofIfInfomsg {
+				continue
+			}
+			info := (*unix.IfInfomsg)(unsafe.Pointer(&m.Data[0]))
+			if info.Flags&unix.IFF_UP == 0 || info.Flags&unix.IFF_RUNNING == 0 {
+				continue
+			}
+			attrs, err := syscall.ParseNetlinkRouteAttr(m)
+			if err != nil {
+				continue
+			}
+			for _, attr := range attrs {
+				if attr.Attr.Type == unix.IFLA_IFNAME {
+					up(string(bytes.TrimRight(attr.Value, "\x00")))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// xdpAttachment - program IDs attached to the interface by XDP mode
+// # ip -d link show ens7
+func xdpAttachment(ifaceName string) (map[string]int, error) {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return nil, err
+	}
+	rib, err := syscall.NetlinkRIB(unix.RTM_GETLINK, unix.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump links: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse links: %w", err)
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		if m.Header.Type != unix.RTM_NEWLINK || len(m.Data) < unix.SizeofIfInfomsg {
+			continue
+		}
+		info := (*unix.IfInfomsg)(unsafe.Pointer(&m.Data[0]))
+		if int(info.Index) != iface.Index {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse link attributes of iface %s: %w", ifaceName, err)
+		}
+		for _, attr := range attrs {
+			if attr.Attr.Type&^unix.NLA_F_NESTED == unix.IFLA_XDP {
+				return parseXDPAttachment(attr.Value), nil
+			}
+		}
+		return map[string]int{}, nil
+	}
+	return nil, fmt.Errorf("link of iface %s is not found", ifaceName)
+}
+
+// tcAttachment - IDs of the bpf programs of the filters of the clsact hook of the direction of the interface,
+// in the order of the filter priorities
+// # tc filter show dev ens7 ingress
+func tcAttachment(ifaceName, direction string) ([]int, error) {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	// dump request of the filters of the hook, the netlink header followed by the tcmsg
+	req := make([]byte, unix.NLMSG_HDRLEN+sizeofTcMsg)
+	nativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	nativeEndian.PutUint16(req[4:6], unix.RTM_GETTFILTER)
+	nativeEndian.PutUint16(req[6:8], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	nativeEndian.PutUint32(req[8:12], 1)
+	tcm := req[unix.NLMSG_HDRLEN:]
+	tcm[0] = unix.AF_UNSPEC
+	nativeEndian.PutUint32(tcm[4:8], uint32(iface.Index))
+	nativeEndian.PutUint32(tcm[12:16], tcParent(direction))
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to dump tc filters of iface %s: %w", ifaceName, err)
+	}
+
+	var ids []int
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return nil, fmt.Errorf("failed to receive tc filters of iface %s: %w", ifaceName, err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tc filters of iface %s: %w", ifaceName, err)
+		}
+		for i := range msgs {
+			m := &msgs[i]
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return ids, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := int32(nativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+						return nil, fmt.Errorf("failed to dump tc filters of iface %s: %w", ifaceName, syscall.Errno(-errno))
+					}
+				}
+				return ids, nil
+			case unix.RTM_NEWTFILTER:
+				if len(m.Data) > sizeofTcMsg {
+					if id := parseTCFilterProgID(m.Data[sizeofTcMsg:]); id > 0 {
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+	}
+}
+
+// memlockLimit - soft RLIMIT_MEMLOCK of l3afd, unix.RLIM_INFINITY when unlimited
+func memlockLimit() (uint64, error) {
+	var rlim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &rlim); err != nil {
+		return 0, fmt.Errorf("failed to get memlock limit: %w", err)
+	}
+	return rlim.Cur, nil
+}
+
+// diskFree - bytes available to unprivileged users on the filesystem of the directory
+func diskFree(dir string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, fmt.Errorf("failed to statfs %s: %w", dir, err)
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+// sameNetNS - network namespace of the path e.g. /host/proc/1/ns/net is the network namespace of l3afd
+func sameNetNS(path string) (bool, error) {
+	var own, other unix.Stat_t
+	if err := unix.Stat("/proc/self/ns/net", &own); err != nil {
+		return false, fmt.Errorf("failed to stat own network namespace: %w", err)
+	}
+	if err := unix.Stat(path, &other); err != nil {
+		return false, fmt.Errorf("failed to stat network namespace %s: %w", path, err)
+	}
+	return own.Dev == other.Dev && own.Ino == other.Ino, nil
+}
+
+// raiseMemlock - raises the soft RLIMIT_MEMLOCK of l3afd to the limit, and the hard limit when it is lower,
+// raising the hard limit requires CAP_SYS_RESOURCE
+func raiseMemlock(limit uint64) error {
+	var rlim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &rlim); err != nil {
+		return fmt.Errorf("failed to get memlock limit: %w", err)
+	}
+	if rlim.Cur >= limit {
+		return nil
+	}
+	rlim.Cur = limit
+	if rlim.Max < limit {
+		rlim.Max = limit
+	}
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &rlim); err != nil {
+		return fmt.Errorf("failed to raise memlock limit to %d bytes: %w", limit, err)
+	}
+	return nil
+}
+
+// ioprioWhoProcess - IOPRIO_WHO_PROCESS of ioprio_set, the id is a thread id
+const ioprioWhoProcess = 1
+
+// SetScheduling - sets the nice value, cpu affinity and ionice class of the threads of the user program,
+// threads created later inherit the settings
+func (b *BPF) SetScheduling(ifaceName string) error {
+	if b.Cmd == nil || b.Cmd.Process == nil {
+		return errors.New("no Process to set scheduling")
+	}
+	prog := &b.Program
+	if prog.Nice == 0 && len(prog.CPUAffinity) == 0 && len(prog.IONiceClass) == 0 {
+		return nil
+	}
+	if err := validateScheduling(prog); err != nil {
+		return err
+	}
+	tids, err := processThreads(b.Cmd.Process.Pid)
+	if err != nil {
+		return err
+	}
+
+	var cpuSet unix.CPUSet
+	affinity, err := resolveCPUAffinity(ifaceName, prog.CPUAffinity)
+	if err != nil {
+		return fmt.Errorf("failed to resolve cpu affinity of program %s: %w", prog.Name, err)
+	}
+	if len(affinity) > 0 {
+		cpus, err := parseCPUList(affinity)
+		if err != nil {
+			return fmt.Errorf("cpu affinity %q of program %s: %w", affinity, prog.Name, err)
+		}
+		for _, cpu := range cpus {
+			cpuSet.Set(cpu)
+		}
+	} else if prog.CPUAffinity == cpuAffinitySameNode {
+		log.Info().Msgf("NIC of iface %s has no numa node, cpu affinity of program %s is not set", ifaceName, prog.Name)
+	}
+	ioprio := ioniceClasses[prog.IONiceClass]<<13 | prog.IONiceLevel
+
+	var errs []string
+	for _, tid := range tids {
+		if prog.Nice != 0 {
+			if err := unix.Setpriority(unix.PRIO_PROCESS, tid, prog.Nice); err != nil {
+				errs = append(errs, fmt.Sprintf("nice of thread %d: %v", tid, err))
+			}
+		}
+		if len(affinity) > 0 {
+			if err := unix.SchedSetaffinity(tid, &cpuSet); err != nil {
+				errs = append(errs, fmt.Sprintf("cpu affinity of thread %d: %v", tid, err))
+			}
+		}
+		if len(prog.IONiceClass) > 0 {
+			if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+				errs = append(errs, fmt.Sprintf("ionice of thread %d: %v", tid, errno))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to set scheduling of program %s: %s", prog.Name, strings.Join(errs, ", "))
+	}
+	log.Info().Msgf("program %s scheduling set, nice %d cpu affinity %q ionice %s %d", prog.Name, prog.Nice, affinity, prog.IONiceClass, prog.IONiceLevel)
+	return nil
+}
+
+// listenControlSocket - listens on the control socket of the program, the messages keep their boundaries so
+// the map fds arrive with their config message
+func listenControlSocket(path string) (net.Listener, error) {
+	return net.Listen("unixpacket", path)
+}
+
+// controlRights - SCM_RIGHTS control message passing the fds
+func controlRights(fds []int) []byte {
+	return unix.UnixRights(fds...)
+}
+
+// setMountNamespace - command runs in a new mount namespace
+func setMountNamespace(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+}
+
+// Landlock access rights of the exposed paths, files take the file rights only
+const (
+	landlockReadOnly  = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockReadWrite = 1<<13 - 1
+	landlockFile      = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_READ_FILE
+)
+
+// enterSandbox - builds the root of the sandbox on a tmpfs of the mount namespace with the exposed paths bind
+// mounted, a new /proc and /tmp, pivots into it, applies the Landlock rules and executes the command
+func enterSandbox(spec sandboxSpec, path string, argv []string) error {
+	// Landlock and no_new_privs apply to the thread executing the command
+	runtime.LockOSThread()
+
+	// mounts of the sandbox do not propagate to the host
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make the mounts private: %w", err)
+	}
+	if err := unix.Mount("tmpfs", spec.Root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount the sandbox root %s: %w", spec.Root, err)
+	}
+
+	// /proc and /tmp are mounted first so the exposed paths under /tmp are not hidden by the tmpfs
+	procDir := filepath.Join(spec.Root, "proc")
+	if err := os.MkdirAll(procDir, 0555); err != nil {
+		return err
+	}
+	if err := unix.Mount("proc", procDir, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		// proc of the pid namespace of l3afd is masked e.g. in a container
+		if err := unix.Mount("/proc", procDir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to mount /proc in the sandbox: %w", err)
+		}
+	}
+	tmpDir := filepath.Join(spec.Root, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", tmpDir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to mount /tmp in the sandbox: %w", err)
+	}
+
+	readOnly := make(map[string]bool, len(spec.ReadOnly))
+	paths := append([]string{}, spec.ReadWrite...)
+	for _, p := range spec.ReadOnly {
+		readOnly[p] = true
+		paths = append(paths, p)
+	}
+	// parents are mounted before their children
+	sort.Strings(paths)
+	var exposed []string
+	for _, p := range paths {
+		ok, err := bindSandboxPath(spec.Root, p, readOnly[p])
+		if err != nil {
+			return err
+		}
+		if ok {
+			exposed = append(exposed, p)
+		}
+	}
+
+	oldRoot := filepath.Join(spec.Root, ".oldroot")
+	if err := os.MkdirAll(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(spec.Root, oldRoot); err != nil {
+		return fmt.Errorf("failed to pivot into the sandbox root: %w", err)
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return err
+	}
+	if err := unix.Unmount("/.oldroot", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to detach the host root: %w", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return err
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("failed to remount the sandbox root read only: %w", err)
+	}
+	if err := unix.Chdir(spec.Dir); err != nil {
+		return fmt.Errorf("failed to change to the dir %s of the command: %w", spec.Dir, err)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no new privileges: %w", err)
+	}
+	if spec.Landlock {
+		if err := restrictLandlock(append(exposed, "/proc", "/tmp"), readOnly); err != nil {
+			return err
+		}
+	}
+	return unix.Exec(path, argv, os.Environ())
+}
+
+// bindSandboxPath - bind mounts the path into the sandbox root, the missing paths are not exposed
+func bindSandboxPath(root, path string, readOnly bool) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	target := filepath.Join(root, path)
+	if info.IsDir() {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return false, err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return false, err
+		}
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0644)
+			if err != nil {
+				return false, err
+			}
+			f.Close()
+		}
+	}
+	if err := unix.Mount(path, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return false, fmt.Errorf("failed to expose %s in the sandbox: %w", path, err)
+	}
+	if readOnly {
+		if err := unix.Mount("", target, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+			return false, fmt.Errorf("failed to expose %s read only in the sandbox: %w", path, err)
+		}
+	}
+	return true, nil
+}
+
+// restrictLandlock - restricts the filesystem access of the thread to the paths, kernels without Landlock
+// are not restricted
+func restrictLandlock(paths []string, readOnly map[string]bool) error {
+	attr := unix.LandlockRulesetAttr{Access_fs: landlockReadWrite}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno == unix.ENOSYS || errno == unix.EOPNOTSUPP {
+		return nil
+	} else if errno != 0 {
+		return fmt.Errorf("failed to create the landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	for _, p := range paths {
+		pathFD, err := unix.Open(p, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open %s for the landlock rule: %w", p, err)
+		}
+		access := uint64(landlockReadWrite)
+		if readOnly[p] {
+			access = landlockReadOnly
+		}
+		var st unix.Stat_t
+		if err := unix.Fstat(pathFD, &st); err == nil && st.Mode&unix.S_IFMT != unix.S_IFDIR {
+			access &= landlockFile
+		}
+		rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(pathFD)}
+		_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, fd, unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		unix.Close(pathFD)
+		if errno != 0 {
+			return fmt.Errorf("failed to add the landlock rule of %s: %w", p, errno)
+		}
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to restrict the sandbox by landlock: %w", errno)
+	}
+	return nil
+}
+
+// executableKey - inode and kernel device number of the executable, the device number is encoded as the
+// kernel encodes s_dev, the major number above the 20 bits of the minor number
+func executableKey(path string) (gatekeeperKey, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return gatekeeperKey{}, err
+	}
+	dev := uint64(st.Dev)
+	return gatekeeperKey{Ino: st.Ino, Dev: unix.Major(dev)<<20 | unix.Minor(dev)}, nil
+}
+
+// attachLSM - attaches the LSM program to its hook by a raw tracepoint link and pins the link, the program
+// stays attached until the pin is removed
+func attachLSM(progFD int, linkPin string) error {
+	// bpf_attr of BPF_RAW_TRACEPOINT_OPEN, the hook of the LSM program is its attach btf id
+	attr := struct {
+		name   uint64
+		progFD uint32
+		_      uint32
+	}{progFD: uint32(progFD)}
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_RAW_TRACEPOINT_OPEN, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+	if errno != 0 {
+		return fmt.Errorf("failed to open the lsm link: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	path, err := unix.BytePtrFromString(linkPin)
+	if err != nil {
+		return err
+	}
+	// bpf_attr of BPF_OBJ_PIN
+	pin := struct {
+		pathname uint64
+		bpfFD    uint32
+		flags    uint32
+	}{pathname: uint64(uintptr(unsafe.Pointer(path))), bpfFD: uint32(fd)}
+	_, _, errno = unix.Syscall(unix.SYS_BPF, unix.BPF_OBJ_PIN, uintptr(unsafe.Pointer(&pin)), unsafe.Sizeof(pin))
+	runtime.KeepAlive(path)
+	if errno != 0 {
+		return fmt.Errorf("failed to pin the lsm link to %s: %w", linkPin, errno)
+	}
+	return nil
+}
+
+// monotonicNow - nanoseconds of CLOCK_MONOTONIC, the clock of bpf_ktime_get_ns
+func monotonicNow() (uint64, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, err
+	}
+	return uint64(ts.Nano()), nil
+}
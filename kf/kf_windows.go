@@ -8,9 +8,18 @@
 package kf
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/windows"
 )
 
 // DisableLRO - XDP programs are failing when Large Receive Offload is enabled, to fix this we use to manually disable.
@@ -18,11 +27,69 @@ func DisableLRO(ifaceName string) error {
 	return nil
 }
 
-// Set process resource limits only non-zero value
+func getIfaceDriver(ifaceName string) (string, error) {
+	return "", fmt.Errorf("getIfaceDriver - platform not supported")
+}
+
+func processExePath(pid int) (string, error) {
+	return "", fmt.Errorf("processExePath - platform not supported")
+}
+
+func isTmpfs(dir string) (bool, error) {
+	return false, fmt.Errorf("isTmpfs - platform not supported")
+}
+
+func watchLinkUp(ctx context.Context, up func(ifaceName string)) error {
+	return fmt.Errorf("watchLinkUp - platform not supported")
+}
+
+func xdpAttachment(ifaceName string) (map[string]int, error) {
+	return nil, fmt.Errorf("xdpAttachment - platform not supported")
+}
+
+func tcAttachment(ifaceName, direction string) ([]int, error) {
+	return nil, fmt.Errorf("tcAttachment - platform not supported")
+}
+
+func mountTmpfs(dir, size string) error {
+	return fmt.Errorf("mountTmpfs - platform not supported")
+}
+
+func getIfaceChannels(ifaceName string) (*ifaceChannels, error) {
+	return nil, fmt.Errorf("getIfaceChannels - platform not supported")
+}
+
+func setIfaceChannels(ifaceName string, channels *ifaceChannels) error {
+	return fmt.Errorf("setIfaceChannels - platform not supported")
+}
+
+func getRSSIndirection(ifaceName string) ([]uint32, error) {
+	return nil, fmt.Errorf("getRSSIndirection - platform not supported")
+}
+
+func setRSSIndirection(ifaceName string, table []uint32) error {
+	return fmt.Errorf("setRSSIndirection - platform not supported")
+}
+
+// Set process resource limits only non-zero value, the memory limit is set on the job object of the process
 func (b *BPF) SetPrLimits() error {
 	if b.Cmd == nil {
 		return errors.New("no Process to set limits")
 	}
+	if b.Program.Memory == 0 {
+		return nil
+	}
+	job, ok := nfJobs.get(b.Cmd.Process.Pid)
+	if !ok {
+		return fmt.Errorf("no job object of program %s to set limits", b.Program.Name)
+	}
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+	info.ProcessMemoryLimit = uintptr(b.Program.Memory)
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		log.Error().Err(err).Msgf("Failed to set Memory limits - %s", b.Program.Name)
+	}
 	return nil
 }
 
@@ -43,10 +110,156 @@ func IsProcessRunning(pid int, name string) (bool, error) {
 	return true, nil
 }
 
-// ProcessTerminate - Kills the process
+// setProcessGroup - process groups are not supported on windows, the NF process is assigned to a job object
+// once it is started
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// jobRegistry - job objects of the NF processes keyed by pid, the job holds the NF process and the helpers
+// it creates, mirroring the process group on unix
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[int]windows.Handle
+}
+
+var nfJobs = &jobRegistry{jobs: make(map[int]windows.Handle)}
+
+func (r *jobRegistry) get(pid int) (windows.Handle, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[pid]
+	return job, ok
+}
+
+func (r *jobRegistry) add(pid int, job windows.Handle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// pid of an exited process is reused
+	if old, ok := r.jobs[pid]; ok {
+		windows.CloseHandle(old)
+	}
+	r.jobs[pid] = job
+}
+
+func (r *jobRegistry) remove(pid int) (windows.Handle, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[pid]
+	delete(r.jobs, pid)
+	return job, ok
+}
+
+// assignNFJob - assigns the started NF process to a new job object, the process runs without a job when the
+// assignment fails
+func assignNFJob(cmd *exec.Cmd) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to create job object of process %d", cmd.Process.Pid)
+		return
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		log.Warn().Err(err).Msgf("failed to open process %d", cmd.Process.Pid)
+		return
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		windows.CloseHandle(job)
+		log.Warn().Err(err).Msgf("failed to assign process %d to a job object", cmd.Process.Pid)
+		return
+	}
+	nfJobs.add(cmd.Process.Pid, job)
+}
+
+// signalNFProcess - terminates the job object of the NF process, or kills the process only when it has no
+// job, signals are not supported on windows
+func signalNFProcess(cmd *exec.Cmd, sig syscall.Signal) error {
+	job, ok := nfJobs.remove(cmd.Process.Pid)
+	if !ok {
+		return cmd.Process.Kill()
+	}
+	defer windows.CloseHandle(job)
+	if err := windows.TerminateJobObject(job, 1); err != nil {
+		return fmt.Errorf("failed to terminate job object of process %d: %w", cmd.Process.Pid, err)
+	}
+	return nil
+}
+
+// ProcessTerminate - Kills the processes of the job object
 func (b *BPF) ProcessTerminate() error {
-	if err := b.Cmd.Process.Kill(); err != nil {
+	if err := signalNFProcess(b.Cmd, syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
 		return fmt.Errorf("BPFProgram %s kill failed with error: %w", b.Program.Name, err)
 	}
 	return nil
 }
+
+func processCmdline(pid int) (string, []string, error) {
+	return "", nil, errors.New("process command line is not supported on windows")
+}
+
+func getKernelRelease() (string, error) {
+	return "", errors.New("kernel release is not supported on windows")
+}
+
+func readProcessUsage(pid int) (*processUsage, error) {
+	return nil, errors.New("process usage is not supported on windows")
+}
+
+func dupFD(fd int) (int, error) {
+	return 0, errors.New("dup fd is not supported on windows")
+}
+
+func readXDPStatistics(pid int) (*xdpStatistics, error) {
+	return nil, errors.New("AF_XDP is not supported on windows")
+}
+
+func memlockLimit() (uint64, error) {
+	return 0, fmt.Errorf("memlockLimit - platform not supported")
+}
+
+func diskFree(dir string) (uint64, error) {
+	return 0, fmt.Errorf("diskFree - platform not supported")
+}
+
+func sameNetNS(path string) (bool, error) {
+	return false, fmt.Errorf("sameNetNS - platform not supported")
+}
+
+func raiseMemlock(limit uint64) error {
+	return fmt.Errorf("raiseMemlock - platform not supported")
+}
+
+func (b *BPF) SetScheduling(ifaceName string) error {
+	if b.Program.Nice == 0 && len(b.Program.CPUAffinity) == 0 && len(b.Program.IONiceClass) == 0 {
+		return nil
+	}
+	return fmt.Errorf("SetScheduling - platform not supported")
+}
+
+func listenControlSocket(path string) (net.Listener, error) {
+	return nil, errors.New("control socket is not supported on windows")
+}
+
+func controlRights(fds []int) []byte {
+	return nil
+}
+
+func setMountNamespace(cmd *exec.Cmd) {
+}
+
+func enterSandbox(spec sandboxSpec, path string, argv []string) error {
+	return errors.New("sandbox is not supported on windows")
+}
+
+func executableKey(path string) (gatekeeperKey, error) {
+	return gatekeeperKey{}, errors.New("bpf gatekeeper is not supported on windows")
+}
+
+func attachLSM(progFD int, linkPin string) error {
+	return errors.New("bpf gatekeeper is not supported on windows")
+}
+
+func monotonicNow() (uint64, error) {
+	return 0, errors.New("heartbeat maps are not supported on windows")
+}
@@ -5,22 +5,26 @@ package kf
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 	"strings"
 
+	"github.com/l3af-project/l3afd/routes"
+
 	"github.com/rs/zerolog/log"
 )
 
 var kfcfgs *NFConfigs
 
-func SetupKFDebug(ebpfChainDebugAddr string, kfConfigs *NFConfigs) {
+func SetupKFDebug(ebpfChainDebugAddr string, allowedCIDRs []*net.IPNet, kfConfigs *NFConfigs) {
 	kfcfgs = kfConfigs
 	go func() {
-		http.HandleFunc("/kfs/", ViewHandler)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/kfs/", ViewHandler)
 
 		// We just need to start a server.
 		log.Info().Msg("Starting KF debug server")
-		if err := http.ListenAndServe(ebpfChainDebugAddr, nil); err != nil {
+		if err := http.ListenAndServe(ebpfChainDebugAddr, routes.AllowCIDRs(allowedCIDRs, mux)); err != nil {
 			log.Fatal().Err(err).Msg("failed to start KF chain debug server")
 		}
 	}()
@@ -6,9 +6,11 @@ package kf
 
 import (
 	"container/list"
+	"sort"
 	"time"
 
 	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
 
 	"github.com/rs/zerolog/log"
 )
@@ -16,40 +18,135 @@ import (
 type kfMetrics struct {
 	Chain     bool
 	Intervals int
+
+	// SamplePeriod - period of the monitor map samples, SampleBudget - time the samples of a period may
+	// take, samples overrunning the budget are skipped until the next period
+	SamplePeriod time.Duration
+	SampleBudget time.Duration
 }
 
-func NewpKFMetrics(chain bool, interval int) *kfMetrics {
+func NewpKFMetrics(chain bool, interval int, samplePeriod, sampleBudget time.Duration) *kfMetrics {
 	m := &kfMetrics{
-		Chain:     chain,
-		Intervals: interval,
+		Chain:        chain,
+		Intervals:    interval,
+		SamplePeriod: samplePeriod,
+		SampleBudget: sampleBudget,
 	}
 	return m
 }
 
+// timeNow - clock of the sampling budget, replaced in tests
+var timeNow = time.Now
+
+// sampleMonitorMap - samples the monitor map element of the program, replaced in tests
+var sampleMonitorMap = func(b *BPF, element models.L3afDNFMetricsMap, intervals int) error {
+	return b.monitorMap(element, intervals)
+}
+
+// monitorTask - monitor map element of a program instance due for a sample
+type monitorTask struct {
+	bpf     *BPF
+	element models.L3afDNFMetricsMap
+	key     monitorKey
+	due     time.Time
+}
+
+// monitorKey - monitor map element of a program instance
+type monitorKey struct {
+	bpf    *BPF
+	mapKey string
+}
+
 func (c *kfMetrics) kfMetricsStart(xdpProgs, ingressTCProgs, egressTCProgs map[string]*list.List) {
 	go c.kfMetricsWorker(xdpProgs, models.XDPIngressType)
 	go c.kfMetricsWorker(ingressTCProgs, models.IngressType)
 	go c.kfMetricsWorker(egressTCProgs, models.EgressType)
 }
 
+// period - sample period, a second by default
+func (c *kfMetrics) period() time.Duration {
+	if c.SamplePeriod > 0 {
+		return c.SamplePeriod
+	}
+	return time.Second
+}
+
+// budget - sample budget of a period, the whole period by default
+func (c *kfMetrics) budget() time.Duration {
+	if c.SampleBudget > 0 && c.SampleBudget < c.period() {
+		return c.SampleBudget
+	}
+	return c.period()
+}
+
 func (c *kfMetrics) kfMetricsWorker(bpfProgs map[string]*list.List, direction string) {
-	for range time.NewTicker(1 * time.Second).C {
-		for ifaceName, bpfList := range bpfProgs {
-			if bpfList == nil { // no bpf programs are running
+	next := make(map[monitorKey]time.Time)
+	for tick := range time.NewTicker(c.period()).C {
+		next = c.sampleDue(bpfProgs, next, tick)
+	}
+}
+
+// sampleDue - samples the monitor maps due at the tick, the most overdue first, until the budget of the
+// period is spent. Returns the next due time of the monitor maps.
+func (c *kfMetrics) sampleDue(bpfProgs map[string]*list.List, next map[monitorKey]time.Time, tick time.Time) map[monitorKey]time.Time {
+	tasks := c.monitorTasks(bpfProgs, next)
+	scheduled := make(map[monitorKey]time.Time, len(tasks))
+	due := make([]monitorTask, 0, len(tasks))
+	for _, t := range tasks {
+		scheduled[t.key] = t.due
+		if !t.due.After(tick) {
+			due = append(due, t)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].due.Before(due[j].due) })
+
+	deadline := tick.Add(c.budget())
+	for i, t := range due {
+		now := timeNow()
+		if now.After(deadline) {
+			for _, s := range due[i:] {
+				stats.Incr(stats.NFMonitorMapSkipped, s.bpf.Program.Name, monitorMetricName(s.element))
+			}
+			log.Warn().Msgf("monitor maps sampling overran its budget %s, %d samples skipped", c.budget(), len(due)-i)
+			break
+		}
+		if !t.due.IsZero() {
+			stats.Set(now.Sub(t.due).Seconds(), stats.NFMonitorMapLag, t.bpf.Program.Name, monitorMetricName(t.element))
+		}
+		if err := sampleMonitorMap(t.bpf, t.element, c.Intervals); err != nil {
+			log.Error().Err(err).Msgf("pMonitor monitor maps failed - %s", t.bpf.Program.Name)
+		}
+		scheduled[t.key] = tick.Add(c.period())
+	}
+	return scheduled
+}
+
+// monitorTasks - monitor map elements of the running programs with their due time, elements never sampled
+// are due immediately
+func (c *kfMetrics) monitorTasks(bpfProgs map[string]*list.List, next map[monitorKey]time.Time) []monitorTask {
+	var tasks []monitorTask
+	for _, bpfList := range bpfProgs {
+		if bpfList == nil { // no bpf programs are running
+			continue
+		}
+		for e := bpfList.Front(); e != nil; e = e.Next() {
+			bpf := e.Value.(*BPF)
+			if c.Chain && bpf.Program.SeqID == 0 { // do not monitor root program
 				continue
 			}
-			for e := bpfList.Front(); e != nil; e = e.Next() {
-				bpf := e.Value.(*BPF)
-				if c.Chain && bpf.Program.SeqID == 0 { // do not monitor root program
-					continue
-				}
-				if bpf.Program.AdminStatus == models.Disabled {
+			if bpf.Program.AdminStatus == models.Disabled {
+				continue
+			}
+			for _, configured := range bpf.Program.MonitorMaps {
+				if !monitorMapFilter.exported(bpf.Program.Name, configured.Name) {
 					continue
 				}
-				if err := bpf.MonitorMaps(ifaceName, c.Intervals); err != nil {
-					log.Error().Err(err).Msgf("pMonitor monitor maps failed - %s", bpf.Program.Name)
+				for _, element := range bpf.monitorElements(configured) {
+					key := monitorKey{bpf: bpf, mapKey: monitorElementKey(element)}
+					tasks = append(tasks, monitorTask{bpf: bpf, element: element, key: key, due: next[key]})
 				}
 			}
 		}
 	}
+	return tasks
 }
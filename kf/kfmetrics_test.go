@@ -7,6 +7,9 @@ import (
 	"container/list"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
 )
 
 func TestNewpKFMetrics(t *testing.T) {
@@ -35,7 +38,7 @@ func TestNewpKFMetrics(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := NewpKFMetrics(tt.args.chain, tt.args.interval)
+			got := NewpKFMetrics(tt.args.chain, tt.args.interval, 0, 0)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewKFMetrics() = %v, want %v", got, tt.want)
 			}
@@ -79,3 +82,55 @@ func Test_KFMetrics_Start(t *testing.T) {
 		})
 	}
 }
+
+func Test_kfMetrics_sampleDue(t *testing.T) {
+	tests := []struct {
+		name        string
+		budget      time.Duration
+		wantSampled [][]string // sampled elements per tick, skipped elements are sampled first at the next tick
+	}{
+		{
+			name:        "WithinBudget",
+			budget:      0,
+			wantSampled: [][]string{{"a", "b", "c"}, {"a", "b", "c"}, {"a", "b", "c"}},
+		},
+		{
+			name:        "OverBudget",
+			budget:      300 * time.Millisecond,
+			wantSampled: [][]string{{"a", "b"}, {"c", "a"}, {"b", "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// every sample takes 200ms
+			start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+			var now time.Time
+			savedNow, savedSample := timeNow, sampleMonitorMap
+			t.Cleanup(func() { timeNow, sampleMonitorMap = savedNow, savedSample })
+			timeNow = func() time.Time { return now }
+			var sampled []string
+			sampleMonitorMap = func(b *BPF, element models.L3afDNFMetricsMap, intervals int) error {
+				sampled = append(sampled, element.Name)
+				now = now.Add(200 * time.Millisecond)
+				return nil
+			}
+
+			bpfList := list.New()
+			bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "ratelimiting", SeqID: 1, AdminStatus: models.Enabled,
+				MonitorMaps: []models.L3afDNFMetricsMap{{Name: "a"}, {Name: "b"}, {Name: "c"}}}})
+			bpfProgs := map[string]*list.List{"eth0": bpfList}
+
+			c := NewpKFMetrics(true, 10, 2*time.Second, tt.budget)
+			next := make(map[monitorKey]time.Time)
+			for i, want := range tt.wantSampled {
+				tick := start.Add(time.Duration(i) * 2 * time.Second)
+				now = tick
+				sampled = []string{}
+				next = c.sampleDue(bpfProgs, next, tick)
+				if !reflect.DeepEqual(sampled, want) {
+					t.Errorf("sampleDue() tick %d sampled = %v, want %v", i, sampled, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pendingLink - config of an interface queued until the link comes up
+type pendingLink struct {
+	config   models.L3afBPFPrograms
+	queuedAt time.Time
+}
+
+// linkQueue - configs of the interfaces with the link down at apply time
+type linkQueue struct {
+	mu      sync.Mutex
+	pending map[string]pendingLink
+}
+
+var pendingLinks = &linkQueue{pending: make(map[string]pendingLink)}
+
+// reset - drops the queued configs, every apply carries the full config
+func (q *linkQueue) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = make(map[string]pendingLink)
+}
+
+func (q *linkQueue) queue(config models.L3afBPFPrograms) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[config.Iface] = pendingLink{config: config, queuedAt: time.Now()}
+}
+
+func (q *linkQueue) take(ifaceName string) (models.L3afBPFPrograms, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p, ok := q.pending[ifaceName]
+	delete(q.pending, ifaceName)
+	return p.config, ok
+}
+
+func (q *linkQueue) queued(ifaceName string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.pending[ifaceName]
+	return ok
+}
+
+// list - queued configs sorted by interface
+func (q *linkQueue) list() []pendingLink {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	links := make([]pendingLink, 0, len(q.pending))
+	for _, p := range q.pending {
+		links = append(links, p)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].config.Iface < links[j].config.Iface })
+	return links
+}
+
+// linkUp - operational state of the interface is up, unknown state of the virtual interfaces is considered
+// up, as is an interface without the state in sysfs
+func linkUp(ifaceName string) bool {
+	state, err := appFS.ReadFile(fmt.Sprintf("/sys/class/net/%s/operstate", ifaceName))
+	if err != nil {
+		return true
+	}
+	switch strings.TrimSpace(string(state)) {
+	case "up", "unknown":
+		return true
+	}
+	return false
+}
+
+// queueLinkDown - queues the config of the interface when its link is down, the config is attached when the
+// link comes up
+func (c *NFConfigs) queueLinkDown(config models.L3afBPFPrograms) bool {
+	if !c.hostConfig.AttachOnLinkUp || !c.hostInterfaces[config.Iface] || linkUp(config.Iface) {
+		return false
+	}
+	log.Warn().Msgf("link of interface %s is down, config is queued until the link comes up", config.Iface)
+	pendingLinks.queue(config)
+	return true
+}
+
+// attachPendingLink - deploys the queued config of the interface whose link came up
+func (c *NFConfigs) attachPendingLink(ifaceName string) {
+	config, ok := pendingLinks.take(ifaceName)
+	if !ok {
+		return
+	}
+	log.Info().Msgf("link of interface %s is up, attaching the queued config", ifaceName)
+	if err := c.Deploy(config.Iface, config.HostName, config.BpfPrograms); err != nil {
+		log.Error().Err(err).Msgf("failed to attach the queued config of interface %s", ifaceName)
+	}
+	if c.ifaces == nil {
+		c.ifaces = make(map[string]string)
+	}
+	c.ifaces[ifaceName] = ifaceName
+	if err := c.SaveConfigsToConfigStore(); err != nil {
+		log.Error().Err(err).Msgf("failed to save configs after attaching interface %s", ifaceName)
+	}
+}
+
+// attachPendingLinks - attaches the queued configs of the interfaces whose link is up, in case the netlink
+// event is missed
+func (c *NFConfigs) attachPendingLinks() {
+	for _, p := range pendingLinks.list() {
+		if linkUp(p.config.Iface) {
+			c.attachPendingLink(p.config.Iface)
+		}
+	}
+}
+
+// linkWatcher - attaches the queued configs on the netlink events of the links coming up
+func (c *NFConfigs) linkWatcher() {
+	if err := watchLinkUp(c.ctx, c.attachPendingLink); err != nil {
+		log.Warn().Err(err).Msg("link events are not available, queued configs are attached by the reconciler")
+	}
+}
+
+// LinkStatus - link state of the interfaces in the config
+func (c *NFConfigs) LinkStatus() []models.L3afDLinkStatus {
+	status := make([]models.L3afDLinkStatus, 0, len(c.ifaces))
+	for _, p := range pendingLinks.list() {
+		status = append(status, models.L3afDLinkStatus{
+			Iface:    p.config.Iface,
+			Status:   models.LinkWaitingForLink,
+			QueuedAt: p.queuedAt.Format(time.RFC3339),
+		})
+	}
+	for ifaceName := range c.ifaces {
+		if !pendingLinks.queued(ifaceName) {
+			status = append(status, models.L3afDLinkStatus{Iface: ifaceName, Status: models.LinkAttached})
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Iface < status[j].Iface })
+	return status
+}
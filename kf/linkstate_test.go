@@ -0,0 +1,133 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_linkUp(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "Up",
+			files: map[string]string{"/sys/class/net/eth0/operstate": "up\n"},
+			want:  true,
+		},
+		{
+			name:  "Down",
+			files: map[string]string{"/sys/class/net/eth0/operstate": "down\n"},
+			want:  false,
+		},
+		{
+			name:  "LowerLayerDown",
+			files: map[string]string{"/sys/class/net/eth0/operstate": "lowerlayerdown\n"},
+			want:  false,
+		},
+		{
+			name:  "Unknown",
+			files: map[string]string{"/sys/class/net/eth0/operstate": "unknown\n"},
+			want:  true,
+		},
+		{
+			name:  "NoState",
+			files: map[string]string{},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t, tt.files)
+			if got := linkUp("eth0"); got != tt.want {
+				t.Errorf("linkUp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNFConfigs_attachOnLinkUp(t *testing.T) {
+	tests := []struct {
+		name           string
+		attachOnLinkUp bool
+		operstate      string
+		wantQueued     []models.L3afDLinkStatus
+		wantAttached   []models.L3afDLinkStatus
+	}{
+		{
+			name:           "LinkDown",
+			attachOnLinkUp: true,
+			operstate:      "down",
+			wantQueued:     []models.L3afDLinkStatus{{Iface: "eth0", Status: models.LinkWaitingForLink}},
+			wantAttached:   []models.L3afDLinkStatus{{Iface: "eth0", Status: models.LinkAttached}},
+		},
+		{
+			name:           "LinkUp",
+			attachOnLinkUp: true,
+			operstate:      "up",
+			wantQueued:     []models.L3afDLinkStatus{{Iface: "eth0", Status: models.LinkAttached}},
+			wantAttached:   []models.L3afDLinkStatus{{Iface: "eth0", Status: models.LinkAttached}},
+		},
+		{
+			name:           "Disabled",
+			attachOnLinkUp: false,
+			operstate:      "down",
+			wantQueued:     []models.L3afDLinkStatus{{Iface: "eth0", Status: models.LinkAttached}},
+			wantAttached:   []models.L3afDLinkStatus{{Iface: "eth0", Status: models.LinkAttached}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := useMemFS(t, map[string]string{"/sys/class/net/eth0/operstate": tt.operstate + "\n"})
+			t.Cleanup(pendingLinks.reset)
+
+			c := &NFConfigs{
+				hostName:       "l3af-local-test",
+				hostInterfaces: map[string]bool{"eth0": true},
+				hostConfig: &config.Config{
+					AttachOnLinkUp:          tt.attachOnLinkUp,
+					L3afConfigStoreFileName: "/etc/l3afd/l3af-config.json",
+				},
+				IngressXDPBpfs: make(map[string]*list.List),
+				IngressTCBpfs:  make(map[string]*list.List),
+				EgressTCBpfs:   make(map[string]*list.List),
+				mu:             new(sync.Mutex),
+			}
+			cfg := []models.L3afBPFPrograms{{HostName: "l3af-local-test", Iface: "eth0", BpfPrograms: &models.BPFPrograms{}}}
+			if err := c.DeployeBPFPrograms(cfg); err != nil {
+				t.Fatalf("DeployeBPFPrograms() error = %v", err)
+			}
+			if got := stripQueuedAt(c.LinkStatus()); !reflect.DeepEqual(got, tt.wantQueued) {
+				t.Errorf("LinkStatus() after apply = %v, want %v", got, tt.wantQueued)
+			}
+			if saved, err := m.ReadFile("/etc/l3afd/l3af-config.json"); err != nil || !strings.Contains(string(saved), `"iface": "eth0"`) {
+				t.Errorf("queued config is not saved to the config store: %s %v", saved, err)
+			}
+
+			if err := m.WriteFile("/sys/class/net/eth0/operstate", []byte("up\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c.attachPendingLinks()
+			if got := stripQueuedAt(c.LinkStatus()); !reflect.DeepEqual(got, tt.wantAttached) {
+				t.Errorf("LinkStatus() after link up = %v, want %v", got, tt.wantAttached)
+			}
+		})
+	}
+}
+
+func stripQueuedAt(status []models.L3afDLinkStatus) []models.L3afDLinkStatus {
+	for i := range status {
+		status[i].QueuedAt = ""
+	}
+	return status
+}
@@ -0,0 +1,569 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Outputs of the l3afd logs
+const (
+	LogOutputConsole  = "console"
+	LogOutputSyslog   = "syslog"
+	LogOutputJournald = "journald"
+)
+
+// ErrInvalidLogLevel - log level or module of the level override is unknown
+var ErrInvalidLogLevel = errors.New("invalid log level")
+
+// logModules - modules of the level overrides, the packages of the module and its sub packages e.g.
+// apis/handlers log with the level of the module
+var logModules = []string{"kf", "apis", "stats"}
+
+const l3afdPackagePrefix = "github.com/l3af-project/l3afd/"
+
+// syslogIdentifier - app name of the syslog messages and identifier of the journal entries
+const syslogIdentifier = "l3afd"
+
+// syslogFacilities - facility codes of RFC5424
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// logLevels - level of the logs, the level overrides of the modules and the programs with debug enabled,
+// the zerolog global level is the lowest of them and the events of a module below its level are discarded
+// by the hook
+type logLevels struct {
+	mu            sync.RWMutex
+	level         zerolog.Level
+	modules       map[string]zerolog.Level
+	debugPrograms map[string]bool
+	debugLogger   zerolog.Logger // logger of the program debug events, without the module level hook
+}
+
+var logging = &logLevels{
+	level:         zerolog.InfoLevel,
+	modules:       make(map[string]zerolog.Level),
+	debugPrograms: make(map[string]bool),
+	debugLogger:   zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339Nano}).With().Timestamp().Logger(),
+}
+
+func (l *logLevels) set(level zerolog.Level, modules map[string]zerolog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+	l.modules = modules
+	l.setGlobalLevel()
+}
+
+func (l *logLevels) setDebugPrograms(names []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debugPrograms = make(map[string]bool)
+	for _, name := range names {
+		l.debugPrograms[name] = true
+	}
+	l.setGlobalLevel()
+}
+
+// setGlobalLevel - lowest of the levels, debug while the debug of a program is enabled. Called with the lock held.
+func (l *logLevels) setGlobalLevel() {
+	lowest := l.level
+	for _, lvl := range l.modules {
+		if lvl < lowest {
+			lowest = lvl
+		}
+	}
+	if len(l.debugPrograms) > 0 && lowest > zerolog.DebugLevel {
+		lowest = zerolog.DebugLevel
+	}
+	zerolog.SetGlobalLevel(lowest)
+}
+
+// programLogger - logger of the debug events of the program, false when the debug of the program is disabled
+func (l *logLevels) programLogger(name string) (zerolog.Logger, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.debugLogger, l.debugPrograms[name]
+}
+
+// debugProgram - debug event of a lifecycle operation of the program e.g. map lookups and chain updates,
+// logged regardless of the log level while the debug of the program is enabled
+func debugProgram(name string) *zerolog.Event {
+	logger, ok := logging.programLogger(name)
+	if !ok {
+		return log.Debug()
+	}
+	return logger.Debug().Str("program", name)
+}
+
+func (l *logLevels) get() models.L3afDLogLevels {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	levels := models.L3afDLogLevels{Level: l.level.String(), Modules: make(map[string]string)}
+	for module, lvl := range l.modules {
+		levels.Modules[module] = lvl.String()
+	}
+	return levels
+}
+
+// enabled - event of the level is logged by the module
+func (l *logLevels) enabled(level zerolog.Level, module func() string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.modules) == 0 {
+		return level >= l.level
+	}
+	if lvl, ok := l.modules[module()]; ok {
+		return level >= lvl
+	}
+	return level >= l.level
+}
+
+// moduleLevelHook - discards the events below the level of the module logging them
+type moduleLevelHook struct{}
+
+func (moduleLevelHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if !logging.enabled(level, callerModule) {
+		e.Discard()
+	}
+}
+
+// callerModule - l3afd module of the first caller outside zerolog, empty for main and the dependencies
+func callerModule() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		fn := frame.Function
+		if !strings.HasPrefix(fn, "github.com/rs/zerolog") && !strings.HasSuffix(fn, "moduleLevelHook.Run") {
+			return packageModule(fn)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// packageModule - module of the function name e.g. kf of github.com/l3af-project/l3afd/kf.(*BPF).Start
+func packageModule(fn string) string {
+	if !strings.HasPrefix(fn, l3afdPackagePrefix) {
+		return ""
+	}
+	fn = fn[len(l3afdPackagePrefix):]
+	if i := strings.IndexAny(fn, "/."); i >= 0 {
+		fn = fn[:i]
+	}
+	return fn
+}
+
+// parseLogLevel - zerolog level of the name, trace to panic
+func parseLogLevel(name string) (zerolog.Level, error) {
+	level, err := zerolog.ParseLevel(strings.ToLower(strings.TrimSpace(name)))
+	if err != nil || len(strings.TrimSpace(name)) == 0 || level > zerolog.PanicLevel {
+		return zerolog.NoLevel, fmt.Errorf("%w %q", ErrInvalidLogLevel, name)
+	}
+	return level, nil
+}
+
+// parseModuleLevels - level overrides of module=level entries
+func parseModuleLevels(entries []string) (map[string]zerolog.Level, error) {
+	modules := make(map[string]zerolog.Level)
+	for _, entry := range entries {
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%w %q, module=level expected", ErrInvalidLogLevel, entry)
+		}
+		module := strings.TrimSpace(kv[0])
+		if !isLogModule(module) {
+			return nil, fmt.Errorf("%w, unknown module %q", ErrInvalidLogLevel, module)
+		}
+		level, err := parseLogLevel(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		modules[module] = level
+	}
+	return modules, nil
+}
+
+func isLogModule(module string) bool {
+	for _, m := range logModules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupLogging - sends the l3afd logs to the outputs of l3afd.cfg with the level and the level overrides of
+// the modules, the level set by L3AF_LOG_LEVEL is kept when the level is not configured
+func SetupLogging(conf *config.Config) error {
+	level := zerolog.GlobalLevel()
+	if len(conf.LogLevel) > 0 {
+		var err error
+		if level, err = parseLogLevel(conf.LogLevel); err != nil {
+			return err
+		}
+	}
+	modules, err := parseModuleLevels(conf.LogModuleLevels)
+	if err != nil {
+		return err
+	}
+
+	var writers []io.Writer
+	for _, output := range conf.LogOutputs {
+		switch output {
+		case LogOutputConsole:
+			writers = append(writers, zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339Nano})
+		case LogOutputSyslog:
+			w, err := newSyslogWriter(conf.LogSyslogNetwork, conf.LogSyslogAddress, conf.LogSyslogFacility)
+			if err != nil {
+				return err
+			}
+			writers = append(writers, w)
+		case LogOutputJournald:
+			writers = append(writers, newJournaldWriter(conf.LogJournaldSocket))
+		default:
+			return fmt.Errorf("unknown log output %q", output)
+		}
+	}
+	if len(writers) == 0 {
+		writers = append(writers, zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339Nano})
+	}
+
+	w := zerolog.MultiLevelWriter(writers...)
+	logging.mu.Lock()
+	logging.debugLogger = zerolog.New(w).With().Timestamp().Logger()
+	logging.mu.Unlock()
+	logging.set(level, modules)
+	log.Logger = zerolog.New(w).With().Timestamp().Logger().Hook(moduleLevelHook{})
+	log.Info().Msgf("logs sent to %s with level %s", strings.Join(conf.LogOutputs, ","), level)
+	return nil
+}
+
+// LogLevels - level of the logs and the level overrides of the modules
+func LogLevels() models.L3afDLogLevels {
+	return logging.get()
+}
+
+// SetLogLevels - changes the level of the logs when set and the level overrides of the modules in the
+// request, an empty module level removes the override
+func (c *NFConfigs) SetLogLevels(req models.L3afDLogLevels, remote string) (models.L3afDLogLevels, error) {
+	current := logging.get()
+	level, err := parseLogLevel(current.Level)
+	if err != nil {
+		level = zerolog.InfoLevel
+	}
+	if len(req.Level) > 0 {
+		if level, err = parseLogLevel(req.Level); err != nil {
+			return current, err
+		}
+	}
+	var entries []string
+	for module, lvl := range current.Modules {
+		if _, ok := req.Modules[module]; !ok {
+			entries = append(entries, module+"="+lvl)
+		}
+	}
+	for module, lvl := range req.Modules {
+		if !isLogModule(module) {
+			return current, fmt.Errorf("%w, unknown module %q", ErrInvalidLogLevel, module)
+		}
+		if len(lvl) > 0 {
+			entries = append(entries, module+"="+lvl)
+		}
+	}
+	modules, err := parseModuleLevels(entries)
+	if err != nil {
+		return current, err
+	}
+
+	logging.set(level, modules)
+	sort.Strings(entries)
+	c.Audit("log-levels", remote, map[string]string{"level": level.String(), "modules": strings.Join(entries, ",")})
+	log.Info().Msgf("log level set to %s, module levels %s", level, strings.Join(entries, ","))
+	return logging.get(), nil
+}
+
+// LogLevel - level of the logs and the programs with debug enabled
+func LogLevel() models.L3afDLogLevel {
+	logging.mu.RLock()
+	defer logging.mu.RUnlock()
+	level := models.L3afDLogLevel{Level: logging.level.String(), DebugPrograms: make([]string, 0, len(logging.debugPrograms))}
+	for name := range logging.debugPrograms {
+		level.DebugPrograms = append(level.DebugPrograms, name)
+	}
+	sort.Strings(level.DebugPrograms)
+	return level
+}
+
+// SetLogLevel - changes the level of the logs when set and replaces the programs with debug enabled when
+// set, the faulty state of a program is debugged without restarting l3afd
+func (c *NFConfigs) SetLogLevel(req models.L3afDLogLevel, remote string) (models.L3afDLogLevel, error) {
+	if len(req.Level) > 0 {
+		level, err := parseLogLevel(req.Level)
+		if err != nil {
+			return LogLevel(), err
+		}
+		logging.mu.RLock()
+		modules := logging.modules
+		logging.mu.RUnlock()
+		logging.set(level, modules)
+	}
+	if req.DebugPrograms != nil {
+		for _, name := range req.DebugPrograms {
+			if len(strings.TrimSpace(name)) == 0 {
+				return LogLevel(), fmt.Errorf("%w, empty program name", ErrInvalidLogLevel)
+			}
+		}
+		logging.setDebugPrograms(req.DebugPrograms)
+	}
+
+	level := LogLevel()
+	c.Audit("log-level", remote, map[string]string{"level": level.Level, "debug_programs": strings.Join(level.DebugPrograms, ",")})
+	log.Info().Msgf("log level set to %s, debug of programs %s", level.Level, strings.Join(level.DebugPrograms, ","))
+	return level, nil
+}
+
+// logField - field of the zerolog event, values other than strings are kept in JSON
+type logField struct {
+	name  string
+	value string
+}
+
+// parseLogEvent - message and the fields sorted by name of the zerolog JSON event, the level and the time
+// are left to the outputs
+func parseLogEvent(p []byte) (string, []logField) {
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(p, &raw); err != nil {
+		return string(bytes.TrimSpace(p)), nil
+	}
+	var msg string
+	var fields []logField
+	for name, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			s = string(value)
+		}
+		switch name {
+		case zerolog.MessageFieldName:
+			msg = s
+		case zerolog.LevelFieldName, zerolog.TimestampFieldName:
+		default:
+			fields = append(fields, logField{name: name, value: s})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return msg, fields
+}
+
+// syslogSeverity - severity of RFC5424 of the level
+func syslogSeverity(level zerolog.Level) int {
+	switch level {
+	case zerolog.PanicLevel:
+		return 1
+	case zerolog.FatalLevel:
+		return 2
+	case zerolog.ErrorLevel:
+		return 3
+	case zerolog.WarnLevel:
+		return 4
+	case zerolog.InfoLevel:
+		return 6
+	case zerolog.DebugLevel, zerolog.TraceLevel:
+		return 7
+	default:
+		return 5
+	}
+}
+
+// datagramConn - connection of the log output, dialed on the first write and again after a failed write
+type datagramConn struct {
+	mu      sync.Mutex
+	network string
+	address string
+	conn    net.Conn
+}
+
+func (d *datagramConn) send(msg []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if d.conn == nil {
+			if d.conn, err = net.DialTimeout(d.network, d.address, time.Second); err != nil {
+				d.conn = nil
+				continue
+			}
+		}
+		if _, err = d.conn.Write(msg); err == nil {
+			return nil
+		}
+		d.conn.Close()
+		d.conn = nil
+	}
+	return err
+}
+
+// syslogWriter - zerolog writer of RFC5424 messages, the fields of the event are the structured data
+type syslogWriter struct {
+	conn     *datagramConn
+	facility int
+	hostname string
+	procID   string
+}
+
+func newSyslogWriter(network, address, facility string) (*syslogWriter, error) {
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || len(hostname) == 0 {
+		hostname = "-"
+	}
+	return &syslogWriter{
+		conn:     &datagramConn{network: network, address: address},
+		facility: code,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.InfoLevel, p)
+}
+
+func (w *syslogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	msg := w.format(level, time.Now(), p)
+	switch w.conn.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		// octet counting framing of RFC6587 on the stream transports
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return len(p), w.conn.send(msg)
+}
+
+// format - <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [l3afd@32473 field="value"...] MSG
+func (w *syslogWriter) format(level zerolog.Level, now time.Time, p []byte) []byte {
+	text, fields := parseLogEvent(p)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - ", w.facility*8+syslogSeverity(level),
+		now.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), w.hostname, syslogIdentifier, w.procID)
+	if len(fields) == 0 {
+		buf.WriteString("-")
+	} else {
+		buf.WriteString("[l3afd@32473")
+		for _, f := range fields {
+			buf.WriteString(" " + syslogParamName(f.name) + `="` + syslogParamEscaper.Replace(f.value) + `"`)
+		}
+		buf.WriteString("]")
+	}
+	if len(text) > 0 {
+		buf.WriteString(" " + text)
+	}
+	return buf.Bytes()
+}
+
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogParamName - PARAM-NAME of RFC5424, printable ASCII except =, space, ] and " of up to 32 characters
+func syslogParamName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	return string(b)
+}
+
+// journaldWriter - zerolog writer of the native journal protocol, the fields of the event are the journal
+// fields in upper case e.g. ERROR
+type journaldWriter struct {
+	conn *datagramConn
+}
+
+func newJournaldWriter(socket string) *journaldWriter {
+	return &journaldWriter{conn: &datagramConn{network: "unixgram", address: socket}}
+}
+
+func (w *journaldWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.InfoLevel, p)
+}
+
+func (w *journaldWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	return len(p), w.conn.send(journaldEntry(level, p))
+}
+
+// journaldEntry - entry of the native journal protocol, values with new lines are sent with their length
+func journaldEntry(level zerolog.Level, p []byte) []byte {
+	text, fields := parseLogEvent(p)
+	var buf bytes.Buffer
+	add := func(name, value string) {
+		if !strings.Contains(value, "\n") {
+			buf.WriteString(name + "=" + value + "\n")
+			return
+		}
+		buf.WriteString(name + "\n")
+		size := make([]byte, 8)
+		binary.LittleEndian.PutUint64(size, uint64(len(value)))
+		buf.Write(size)
+		buf.WriteString(value + "\n")
+	}
+	add("MESSAGE", text)
+	add("PRIORITY", strconv.Itoa(syslogSeverity(level)))
+	add("SYSLOG_IDENTIFIER", syslogIdentifier)
+	for _, f := range fields {
+		if name := journaldFieldName(f.name); len(name) > 0 {
+			add(name, f.value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// journaldFieldName - journal field name of upper case letters, digits and underscores not starting with an
+// underscore or a digit, empty when nothing is left
+func journaldFieldName(name string) string {
+	b := []byte(strings.ToUpper(name))
+	for i, c := range b {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	name = strings.TrimLeft(string(b), "_0123456789")
+	switch name {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER":
+		return "L3AFD_" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
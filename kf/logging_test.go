@@ -0,0 +1,181 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog"
+)
+
+func Test_packageModule(t *testing.T) {
+	tests := map[string]string{
+		"github.com/l3af-project/l3afd/kf.(*BPF).Start":               "kf",
+		"github.com/l3af-project/l3afd/apis/handlers.GetConfig.func1": "apis",
+		"github.com/l3af-project/l3afd/stats.SetupMetrics":            "stats",
+		"main.main": "",
+		"github.com/l3af-project/l3afd-plugins/kf.Start": "",
+		"github.com/cilium/ebpf.(*Map).Lookup":           "",
+	}
+	for fn, want := range tests {
+		if got := packageModule(fn); got != want {
+			t.Errorf("packageModule(%s) = %q, want %q", fn, got, want)
+		}
+	}
+	if got := callerModule(); got != "kf" {
+		t.Errorf("callerModule() = %q, want kf", got)
+	}
+}
+
+func TestModuleLevelHook(t *testing.T) {
+	t.Cleanup(func() { logging.set(zerolog.InfoLevel, make(map[string]zerolog.Level)) })
+	c := &NFConfigs{hostConfig: &config.Config{}}
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Hook(moduleLevelHook{})
+
+	logging.set(zerolog.WarnLevel, nil)
+	logger.Info().Msg("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("info event logged at warn level: %s", buf.String())
+	}
+
+	levels, err := c.SetLogLevels(models.L3afDLogLevels{Modules: map[string]string{"kf": "debug", "stats": "error"}}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("SetLogLevels() error = %v", err)
+	}
+	if levels.Level != "warn" || levels.Modules["kf"] != "debug" || levels.Modules["stats"] != "error" {
+		t.Errorf("SetLogLevels() = %+v, want warn with kf debug and stats error", levels)
+	}
+	if zerolog.GlobalLevel() != zerolog.DebugLevel {
+		t.Errorf("global level = %s, want the lowest module level", zerolog.GlobalLevel())
+	}
+	logger.Debug().Msg("kept")
+	if !strings.Contains(buf.String(), "kept") {
+		t.Errorf("debug event of kf is not logged with the kf override")
+	}
+
+	// empty module level removes the override
+	if levels, _ = c.SetLogLevels(models.L3afDLogLevels{Level: "info", Modules: map[string]string{"kf": ""}}, "10.0.0.1"); len(levels.Modules) != 1 || levels.Level != "info" {
+		t.Errorf("SetLogLevels() = %+v, want info with the stats override", levels)
+	}
+	buf.Reset()
+	logger.Debug().Msg("dropped")
+	if buf.Len() != 0 {
+		t.Errorf("debug event of kf logged after the override is removed")
+	}
+
+	for _, req := range []models.L3afDLogLevels{{Level: "verbose"}, {Modules: map[string]string{"routes": "debug"}}, {Modules: map[string]string{"kf": "disabled"}}} {
+		if _, err := c.SetLogLevels(req, ""); !errors.Is(err, ErrInvalidLogLevel) {
+			t.Errorf("SetLogLevels(%+v) error = %v, want ErrInvalidLogLevel", req, err)
+		}
+	}
+}
+
+func TestSyslogWriter(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp listener not available: %v", err)
+	}
+	defer server.Close()
+
+	w, err := newSyslogWriter("udp", server.LocalAddr().String(), "local0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.hostname, w.procID = "edge-1a", "42"
+	event := []byte(`{"level":"error","iface":"eth0","error":"quote \" and ]","time":1,"message":"start failed"}`)
+	got := string(w.format(zerolog.ErrorLevel, time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC), event))
+	want := `<131>1 2026-10-16T09:30:00.000000Z edge-1a l3afd 42 - [l3afd@32473 error="quote \" and \]" iface="eth0"] start failed`
+	if got != want {
+		t.Errorf("format() = %s, want %s", got, want)
+	}
+
+	if _, err := w.WriteLevel(zerolog.WarnLevel, []byte(`{"message":"sent"}`)); err != nil {
+		t.Fatalf("WriteLevel() error = %v", err)
+	}
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("syslog message not received: %v", err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<132>1 ") || !strings.HasSuffix(msg, " - - sent") {
+		t.Errorf("received %s, want warning of local0 without structured data", msg)
+	}
+
+	if _, err := newSyslogWriter("udp", "127.0.0.1:514", "local9"); err == nil {
+		t.Errorf("newSyslogWriter() error = nil, want unknown facility")
+	}
+}
+
+func Test_journaldEntry(t *testing.T) {
+	got := journaldEntry(zerolog.InfoLevel, []byte(`{"level":"info","message":"line1\nline2","iface":"eth0","_uid":"0","bpf-prog":"ratelimiting","count":3}`))
+
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len("line1\nline2")))
+	want := "MESSAGE\n" + string(size) + "line1\nline2\n" +
+		"PRIORITY=6\nSYSLOG_IDENTIFIER=l3afd\n" +
+		"UID=0\nBPF_PROG=ratelimiting\nCOUNT=3\nIFACE=eth0\n"
+	if string(got) != want {
+		t.Errorf("journaldEntry() = %q, want %q", got, want)
+	}
+	if name := journaldFieldName("message"); name != "L3AFD_MESSAGE" {
+		t.Errorf("journaldFieldName(message) = %s, want L3AFD_MESSAGE", name)
+	}
+}
+
+func TestDebugProgram(t *testing.T) {
+	var buf bytes.Buffer
+	logging.mu.Lock()
+	debugLogger := logging.debugLogger
+	logging.debugLogger = zerolog.New(&buf)
+	logging.mu.Unlock()
+	t.Cleanup(func() {
+		logging.mu.Lock()
+		logging.debugLogger = debugLogger
+		logging.mu.Unlock()
+		logging.setDebugPrograms(nil)
+		logging.set(zerolog.InfoLevel, make(map[string]zerolog.Level))
+	})
+	c := &NFConfigs{hostConfig: &config.Config{}}
+	logging.set(zerolog.InfoLevel, nil)
+
+	debugProgram("ratelimiting").Msg("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("debug event logged while the debug of the program is disabled: %s", buf.String())
+	}
+
+	level, err := c.SetLogLevel(models.L3afDLogLevel{Level: "warn", DebugPrograms: []string{"ratelimiting"}}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("SetLogLevel() error = %v", err)
+	}
+	if level.Level != "warn" || len(level.DebugPrograms) != 1 || level.DebugPrograms[0] != "ratelimiting" {
+		t.Errorf("SetLogLevel() = %+v, want warn with the debug of ratelimiting", level)
+	}
+	debugProgram("ratelimiting").Msg("chain updated")
+	if !strings.Contains(buf.String(), `"program":"ratelimiting"`) || !strings.Contains(buf.String(), "chain updated") {
+		t.Errorf("debug event of the program = %s, want it logged at the warn level", buf.String())
+	}
+
+	// absent programs are unchanged, an empty list disables the debug
+	if level, _ = c.SetLogLevel(models.L3afDLogLevel{Level: "info"}, ""); len(level.DebugPrograms) != 1 {
+		t.Errorf("SetLogLevel() = %+v, want the debug programs unchanged", level)
+	}
+	if level, _ = c.SetLogLevel(models.L3afDLogLevel{DebugPrograms: []string{}}, ""); len(level.DebugPrograms) != 0 || zerolog.GlobalLevel() != zerolog.InfoLevel {
+		t.Errorf("SetLogLevel() = %+v global level %s, want the debug disabled at info", level, zerolog.GlobalLevel())
+	}
+	if _, err := c.SetLogLevel(models.L3afDLogLevel{Level: "loud"}, ""); !errors.Is(err, ErrInvalidLogLevel) {
+		t.Errorf("SetLogLevel() error = %v, want ErrInvalidLogLevel", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+// DefaultMapDumpLimit - number of entries returned by a map dump when the limit is not set
+const DefaultMapDumpLimit = 1000
+
+// MapDumpFilter - selects the entries of the map dump, keys are compared as raw bytes in the map layout
+type MapDumpFilter struct {
+	Prefix   []byte // key starts with the prefix
+	Start    []byte // key is greater than or equal to start
+	End      []byte // key is less than end
+	Limit    int    // maximum number of entries returned
+	Continue []byte // continuation token of the previous dump, key to resume the iteration after
+}
+
+// MapDump - opened eBPF map of a running program to be dumped
+type MapDump struct {
+	m ebpfMap
+}
+
+// OpenMapDump - opens the map of the program running on the interface in the direction
+func (c *NFConfigs) OpenMapDump(iface, direction, program, mapName string) (*MapDump, error) {
+	if len(mapName) == 0 {
+		return nil, fmt.Errorf("map name is empty")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bpf, err := c.findBPF(iface, direction, program)
+	if err != nil {
+		return nil, err
+	}
+	m, err := openProgramMap(bpf, mapName)
+	if err != nil {
+		return nil, err
+	}
+	return &MapDump{m: m}, nil
+}
+
+// Close - releases the map
+func (d *MapDump) Close() error {
+	return d.m.Close()
+}
+
+// Dump - calls fn for the map entries matching the filter in the iteration order of the kernel. The
+// returned continuation token is set when the limit is reached, it is the hex key to pass as
+// filter.Continue to resume the dump. Hash map iteration restarts from the first key when the
+// continuation key is deleted between the calls.
+func (d *MapDump) Dump(filter MapDumpFilter, fn func(models.L3afDMapEntry) error) (string, error) {
+	info, err := d.m.Info()
+	if err != nil {
+		return "", fmt.Errorf("fetching map info failed %v", err)
+	}
+	switch info.Type {
+	case ebpf.PerCPUHash, ebpf.PerCPUArray, ebpf.LRUCPUHash:
+		return "", fmt.Errorf("per-CPU map type %s is not supported", info.Type)
+	case ebpf.ProgramArray, ebpf.PerfEventArray, ebpf.ArrayOfMaps, ebpf.HashOfMaps, ebpf.RingBuf:
+		return "", fmt.Errorf("map type %s is not supported", info.Type)
+	}
+	if len(filter.Continue) > 0 && len(filter.Continue) != int(info.KeySize) {
+		return "", fmt.Errorf("continuation token is not a key of size %d", info.KeySize)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultMapDumpLimit
+	}
+
+	var cur interface{}
+	if len(filter.Continue) > 0 {
+		cur = filter.Continue
+	}
+	count := 0
+	for {
+		var key []byte
+		if err := d.m.NextKey(cur, &key); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				return "", nil
+			}
+			return "", fmt.Errorf("map iteration failed %v", err)
+		}
+		cur = key
+
+		if !filter.match(key) {
+			continue
+		}
+		var value []byte
+		if err := d.m.Lookup(key, &value); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				// deleted during the dump
+				continue
+			}
+			return "", fmt.Errorf("map lookup failed %v", err)
+		}
+		if err := fn(models.L3afDMapEntry{Key: hex.EncodeToString(key), Value: hex.EncodeToString(value)}); err != nil {
+			return "", err
+		}
+		count++
+		if count == filter.Limit {
+			return hex.EncodeToString(key), nil
+		}
+	}
+}
+
+func (f MapDumpFilter) match(key []byte) bool {
+	if !bytes.HasPrefix(key, f.Prefix) {
+		return false
+	}
+	if len(f.Start) > 0 && bytes.Compare(key, f.Start) < 0 {
+		return false
+	}
+	if len(f.End) > 0 && bytes.Compare(key, f.End) >= 0 {
+		return false
+	}
+	return true
+}
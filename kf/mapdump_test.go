@@ -0,0 +1,157 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+// fakeHashMap - map with byte keys iterated in key order
+type fakeHashMap struct {
+	mapType ebpf.MapType
+	entries map[string][]byte
+}
+
+func (m *fakeHashMap) keys() [][]byte {
+	var keys [][]byte
+	for k := range m.entries {
+		keys = append(keys, []byte(k))
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return keys
+}
+
+func (m *fakeHashMap) Lookup(key, valueOut interface{}) error {
+	v, ok := m.entries[string(key.([]byte))]
+	if !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	*valueOut.(*[]byte) = v
+	return nil
+}
+
+func (m *fakeHashMap) NextKey(key, nextKeyOut interface{}) error {
+	for _, k := range m.keys() {
+		if key == nil || bytes.Compare(k, key.([]byte)) > 0 {
+			*nextKeyOut.(*[]byte) = k
+			return nil
+		}
+	}
+	return ebpf.ErrKeyNotExist
+}
+
+func (m *fakeHashMap) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	m.entries[string(key.([]byte))] = value.([]byte)
+	return nil
+}
+
+func (m *fakeHashMap) Delete(key interface{}) error {
+	if _, ok := m.entries[string(key.([]byte))]; !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	delete(m.entries, string(key.([]byte)))
+	return nil
+}
+
+func (m *fakeHashMap) Info() (*ebpf.MapInfo, error) {
+	return &ebpf.MapInfo{Type: m.mapType, KeySize: 2, ValueSize: 1}, nil
+}
+
+func (m *fakeHashMap) Close() error { return nil }
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestMapDump_Dump(t *testing.T) {
+	entries := map[string][]byte{
+		string(mustHex("0a01")): {1},
+		string(mustHex("0a02")): {2},
+		string(mustHex("0b01")): {3},
+		string(mustHex("0c01")): {4},
+	}
+	tests := []struct {
+		name     string
+		mapType  ebpf.MapType
+		filter   MapDumpFilter
+		wantKeys []string
+		wantNext string
+		wantErr  bool
+	}{
+		{
+			name:     "All",
+			mapType:  ebpf.Hash,
+			wantKeys: []string{"0a01", "0a02", "0b01", "0c01"},
+		},
+		{
+			name:     "Prefix",
+			mapType:  ebpf.Hash,
+			filter:   MapDumpFilter{Prefix: mustHex("0a")},
+			wantKeys: []string{"0a01", "0a02"},
+		},
+		{
+			name:     "Range",
+			mapType:  ebpf.Hash,
+			filter:   MapDumpFilter{Start: mustHex("0a02"), End: mustHex("0c01")},
+			wantKeys: []string{"0a02", "0b01"},
+		},
+		{
+			name:     "Limit",
+			mapType:  ebpf.Hash,
+			filter:   MapDumpFilter{Limit: 2},
+			wantKeys: []string{"0a01", "0a02"},
+			wantNext: "0a02",
+		},
+		{
+			name:     "Continue",
+			mapType:  ebpf.Hash,
+			filter:   MapDumpFilter{Limit: 2, Continue: mustHex("0a02")},
+			wantKeys: []string{"0b01", "0c01"},
+			wantNext: "0c01",
+		},
+		{
+			name:    "InvalidContinue",
+			mapType: ebpf.Hash,
+			filter:  MapDumpFilter{Continue: mustHex("0a")},
+			wantErr: true,
+		},
+		{
+			name:    "PerCPU",
+			mapType: ebpf.PerCPUHash,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &MapDump{m: &fakeHashMap{mapType: tt.mapType, entries: entries}}
+			var keys []string
+			next, err := d.Dump(tt.filter, func(e models.L3afDMapEntry) error {
+				keys = append(keys, e.Key)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Dump() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("Dump() keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if next != tt.wantNext {
+				t.Errorf("Dump() next = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"unsafe"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	MapEntryOverwrite = "overwrite"
+	MapEntryPreserve  = "preserve"
+)
+
+// mapOverride - map entry written by the map entry API, prev is nil when the key did not exist
+type mapOverride struct {
+	iface     string
+	direction string
+	program   string
+	mapName   string
+	key       []byte
+	value     []byte
+	prev      []byte
+}
+
+type mapOverrideRegistry struct {
+	mu      sync.Mutex
+	entries map[string]*mapOverride // key is iface/direction/program/map/hex key
+}
+
+var mapOverrides = &mapOverrideRegistry{entries: make(map[string]*mapOverride)}
+
+// nativeEndian - byte order of the host, integer keys and values are stored in the host byte order
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// UpdateMapEntry - writes the entry to the map of the program running on the interface. The entry is
+// recorded in the audit log and reconciled on the next config apply by the map-entries reconcile policy.
+func (c *NFConfigs) UpdateMapEntry(iface, program, mapName, key string, entry models.L3afDMapEntryUpdate, remote string) error {
+	keyBytes, err := encodeMapValue(entry.KeyType, key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	valueBytes, err := encodeMapValue(entry.ValueType, entry.Value)
+	if err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bpf, direction, err := c.findBPFOnIface(iface, program)
+	if err != nil {
+		return err
+	}
+	m, err := openProgramMap(bpf, mapName)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	info, err := m.Info()
+	if err != nil {
+		return fmt.Errorf("fetching map info failed %v", err)
+	}
+	switch info.Type {
+	case ebpf.Hash, ebpf.Array, ebpf.LRUHash, ebpf.LPMTrie:
+	default:
+		return fmt.Errorf("map type %s is not supported", info.Type)
+	}
+	if len(keyBytes) != int(info.KeySize) {
+		return fmt.Errorf("key size %d does not match map key size %d", len(keyBytes), info.KeySize)
+	}
+	if len(valueBytes) != int(info.ValueSize) {
+		return fmt.Errorf("value size %d does not match map value size %d", len(valueBytes), info.ValueSize)
+	}
+
+	prev, err := lookupMapEntry(m, keyBytes)
+	if err != nil {
+		return err
+	}
+	if err := m.Update(keyBytes, valueBytes, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("failed to update map %s of program %s: %w", mapName, program, err)
+	}
+
+	id := fmt.Sprintf("%s/%s/%s/%s/%x", iface, direction, program, mapName, keyBytes)
+	mapOverrides.mu.Lock()
+	if o, ok := mapOverrides.entries[id]; ok {
+		// value before the first update is restored
+		prev = o.prev
+	}
+	mapOverrides.entries[id] = &mapOverride{
+		iface:     iface,
+		direction: direction,
+		program:   program,
+		mapName:   mapName,
+		key:       keyBytes,
+		value:     valueBytes,
+		prev:      prev,
+	}
+	mapOverrides.mu.Unlock()
+
+	c.Audit("map-entry-update", remote, map[string]string{
+		"iface":     iface,
+		"direction": direction,
+		"program":   program,
+		"map":       mapName,
+		"key":       hex.EncodeToString(keyBytes),
+		"value":     hex.EncodeToString(valueBytes),
+		"prev":      hex.EncodeToString(prev),
+	})
+	log.Info().Msgf("map %s entry %x of program %s iface %s updated", mapName, keyBytes, program, iface)
+	return nil
+}
+
+// reconcileMapEntries - applies the reconcile policy to the map entries updated since the last config apply
+func (c *NFConfigs) reconcileMapEntries() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mapOverrides.mu.Lock()
+	defer mapOverrides.mu.Unlock()
+
+	policy := c.hostConfig.MapEntryReconcilePolicy
+	for id, o := range mapOverrides.entries {
+		bpf, err := c.findBPF(o.iface, o.direction, o.program)
+		if err != nil {
+			log.Info().Msgf("program %s of map entry %s is removed", o.program, id)
+			delete(mapOverrides.entries, id)
+			continue
+		}
+		m, err := openProgramMap(bpf, o.mapName)
+		if err != nil {
+			log.Warn().Err(err).Msgf("failed to reconcile map entry %s", id)
+			continue
+		}
+		keep, err := reconcileMapEntry(m, o, policy)
+		m.Close()
+		if err != nil {
+			log.Warn().Err(err).Msgf("failed to reconcile map entry %s", id)
+			continue
+		}
+		if !keep {
+			delete(mapOverrides.entries, id)
+		}
+		c.Audit("map-entry-reconcile", "", map[string]string{
+			"iface":     o.iface,
+			"direction": o.direction,
+			"program":   o.program,
+			"map":       o.mapName,
+			"key":       hex.EncodeToString(o.key),
+			"policy":    policy,
+		})
+	}
+}
+
+// reconcileMapEntry - overwrite restores the previous value unless the config apply changed the entry,
+// preserve writes the entry again. Returns whether the entry is kept for the next config apply.
+func reconcileMapEntry(m ebpfMap, o *mapOverride, policy string) (bool, error) {
+	cur, err := lookupMapEntry(m, o.key)
+	if err != nil {
+		return true, err
+	}
+
+	switch policy {
+	case MapEntryPreserve:
+		if !bytes.Equal(cur, o.value) {
+			if err := m.Update(o.key, o.value, ebpf.UpdateAny); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	case MapEntryOverwrite:
+		if !bytes.Equal(cur, o.value) {
+			return false, nil
+		}
+		if o.prev == nil {
+			if err := m.Delete(o.key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return true, err
+			}
+			return false, nil
+		}
+		if err := m.Update(o.key, o.prev, ebpf.UpdateAny); err != nil {
+			return true, err
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown map entry reconcile policy %s", policy)
+	}
+}
+
+// lookupMapEntry - returns the value of the key, nil when the key does not exist
+func lookupMapEntry(m ebpfMap, key []byte) ([]byte, error) {
+	var value []byte
+	if err := m.Lookup(key, &value); err != nil {
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("map lookup failed %v", err)
+	}
+	return value, nil
+}
+
+// findBPFOnIface - returns the program running on the interface and its direction
+func (c *NFConfigs) findBPFOnIface(iface, program string) (*BPF, string, error) {
+	for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+		if bpf, err := c.findBPF(iface, direction, program); err == nil {
+			return bpf, direction, nil
+		}
+	}
+	return nil, "", fmt.Errorf("program %s is not running on iface %s", program, iface)
+}
+
+// openProgramMap - opens the map of the program by name, or by pinned path for TC programs
+func openProgramMap(bpf *BPF, mapName string) (ebpfMap, error) {
+	bpfMap, err := bpf.GetBPFMap(mapName)
+	if err != nil {
+		return nil, fmt.Errorf("map %s of program %s not found: %w", mapName, bpf.Program.Name, err)
+	}
+	m, err := bpfAPI.NewMapFromID(bpfMap.MapID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open map %s of program %s: %w", mapName, bpf.Program.Name, err)
+	}
+	return m, nil
+}
+
+// encodeMapValue - encodes the typed key or value in the map layout, integers are in the host byte
+// order unless big endian (be) types are used
+func encodeMapValue(typ, s string) ([]byte, error) {
+	bits := map[string]int{"u8": 8, "u16": 16, "u32": 32, "u64": 64, "be16": 16, "be32": 32, "be64": 64}
+	if size, ok := bits[typ]; ok {
+		v, err := strconv.ParseUint(s, 0, size)
+		if err != nil {
+			return nil, err
+		}
+		order := nativeEndian
+		if typ[0] == 'b' {
+			order = binary.BigEndian
+		}
+		buf := make([]byte, 8)
+		switch size {
+		case 8:
+			return []byte{byte(v)}, nil
+		case 16:
+			order.PutUint16(buf, uint16(v))
+		case 32:
+			order.PutUint32(buf, uint32(v))
+		case 64:
+			order.PutUint64(buf, v)
+		}
+		return buf[:size/8], nil
+	}
+
+	switch typ {
+	case "ipv4":
+		ip := net.ParseIP(s).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("%s is not an IPv4 address", s)
+		}
+		return ip, nil
+	case "ipv6":
+		ip := net.ParseIP(s)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("%s is not an IPv6 address", s)
+		}
+		return ip.To16(), nil
+	case "mac":
+		return net.ParseMAC(s)
+	case "hex", "":
+		return hex.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+}
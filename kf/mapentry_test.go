@@ -0,0 +1,119 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_encodeMapValue(t *testing.T) {
+	u32 := make([]byte, 4)
+	nativeEndian.PutUint32(u32, 10)
+	tests := []struct {
+		name    string
+		typ     string
+		value   string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "u32", typ: "u32", value: "10", want: u32},
+		{name: "be16", typ: "be16", value: "443", want: []byte{0x01, 0xbb}},
+		{name: "u8Overflow", typ: "u8", value: "256", wantErr: true},
+		{name: "ipv4", typ: "ipv4", value: "10.0.0.1", want: []byte{10, 0, 0, 1}},
+		{name: "ipv4Invalid", typ: "ipv4", value: "fe80::1", wantErr: true},
+		{name: "ipv6", typ: "ipv6", value: "fe80::1", want: []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{name: "mac", typ: "mac", value: "02:00:00:00:00:01", want: []byte{2, 0, 0, 0, 0, 1}},
+		{name: "hex", typ: "hex", value: "0a0b", want: []byte{10, 11}},
+		{name: "UnknownType", typ: "u128", value: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeMapValue(tt.typ, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeMapValue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeMapValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reconcileMapEntry(t *testing.T) {
+	key := []byte{10, 0, 0, 1}
+	tests := []struct {
+		name     string
+		policy   string
+		current  []byte
+		prev     []byte
+		want     []byte
+		wantKeep bool
+		wantErr  bool
+	}{
+		{
+			name:     "OverwriteRestoresPrevious",
+			policy:   MapEntryOverwrite,
+			current:  []byte{1},
+			prev:     []byte{0},
+			want:     []byte{0},
+			wantKeep: false,
+		},
+		{
+			name:     "OverwriteDeletesNewKey",
+			policy:   MapEntryOverwrite,
+			current:  []byte{1},
+			prev:     nil,
+			want:     nil,
+			wantKeep: false,
+		},
+		{
+			name:     "OverwriteKeepsConfigChange",
+			policy:   MapEntryOverwrite,
+			current:  []byte{2},
+			prev:     []byte{0},
+			want:     []byte{2},
+			wantKeep: false,
+		},
+		{
+			name:     "PreserveWritesAgain",
+			policy:   MapEntryPreserve,
+			current:  nil,
+			prev:     nil,
+			want:     []byte{1},
+			wantKeep: true,
+		},
+		{
+			name:     "UnknownPolicy",
+			policy:   "merge",
+			current:  []byte{1},
+			want:     []byte{1},
+			wantKeep: false,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeHashMap{mapType: ebpf.Hash, entries: make(map[string][]byte)}
+			if tt.current != nil {
+				m.entries[string(key)] = tt.current
+			}
+			o := &mapOverride{key: key, value: []byte{1}, prev: tt.prev}
+			keep, err := reconcileMapEntry(m, o, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("reconcileMapEntry() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if keep != tt.wantKeep {
+				t.Errorf("reconcileMapEntry() keep = %v, want %v", keep, tt.wantKeep)
+			}
+			if got := m.entries[string(key)]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reconcileMapEntry() entry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
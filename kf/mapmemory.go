@@ -0,0 +1,47 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// VerifyMapMemory - Verifies the declared BPF map memory of the program is within the per program budget,
+// the map memory of all the programs on the host is within the host budget and the map memory of the programs
+// of its tenant is within the tenant quota.
+func (c *NFConfigs) VerifyMapMemory(bpf *BPF) error {
+	if c.hostConfig.MaxProgramMapMemory > 0 && bpf.MapMemory > uint64(c.hostConfig.MaxProgramMapMemory) {
+		return fmt.Errorf("program %s declares %d bytes of map memory, program budget is %d bytes",
+			bpf.Program.Name, bpf.MapMemory, c.hostConfig.MaxProgramMapMemory)
+	}
+
+	quota := c.hostConfig.TenantQuotas[bpf.Program.Tenant]
+	if c.hostConfig.MaxMapMemory <= 0 && quota.MaxMapMemory <= 0 {
+		return nil
+	}
+
+	total, tenantTotal := bpf.MapMemory, bpf.MapMemory
+	for _, bpfLists := range []map[string]*list.List{c.IngressXDPBpfs, c.IngressTCBpfs, c.EgressTCBpfs} {
+		for _, bpfList := range bpfLists {
+			if bpfList == nil {
+				continue
+			}
+			for e := bpfList.Front(); e != nil; e = e.Next() {
+				if data := e.Value.(*BPF); data != bpf {
+					total += data.MapMemory
+					if data.Program.Tenant == bpf.Program.Tenant {
+						tenantTotal += data.MapMemory
+					}
+				}
+			}
+		}
+	}
+
+	if c.hostConfig.MaxMapMemory > 0 && total > uint64(c.hostConfig.MaxMapMemory) {
+		return fmt.Errorf("program %s requires host map memory %d bytes, host budget is %d bytes",
+			bpf.Program.Name, total, c.hostConfig.MaxMapMemory)
+	}
+	return verifyTenantMapMemory(c.hostConfig.TenantQuotas, bpf.Program.Tenant, tenantTotal)
+}
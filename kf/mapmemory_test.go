@@ -0,0 +1,82 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_VerifyMapMemory(t *testing.T) {
+	running := list.New()
+	running.PushBack(&BPF{Program: models.BPFProgram{Name: "connection-tracker"}, MapMemory: 600})
+	running.PushBack(&BPF{Program: models.BPFProgram{Name: "flow-exporter", Tenant: "edge"}, MapMemory: 300})
+	quotas := map[string]config.TenantQuota{"edge": {MaxMapMemory: 1000}}
+
+	tests := []struct {
+		name     string
+		hostConf *config.Config
+		bpf      *BPF
+		wantErr  bool
+	}{
+		{
+			name:     "Unlimited",
+			hostConf: &config.Config{},
+			bpf:      &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, MapMemory: 4096},
+			wantErr:  false,
+		},
+		{
+			name:     "WithinBudgets",
+			hostConf: &config.Config{MaxProgramMapMemory: 500, MaxMapMemory: 1300},
+			bpf:      &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, MapMemory: 400},
+			wantErr:  false,
+		},
+		{
+			name:     "ProgramBudgetExceeded",
+			hostConf: &config.Config{MaxProgramMapMemory: 500},
+			bpf:      &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, MapMemory: 501},
+			wantErr:  true,
+		},
+		{
+			name:     "HostBudgetExceeded",
+			hostConf: &config.Config{MaxMapMemory: 1300},
+			bpf:      &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, MapMemory: 401},
+			wantErr:  true,
+		},
+		{
+			name:     "WithinTenantQuota",
+			hostConf: &config.Config{TenantQuotas: quotas},
+			bpf:      &BPF{Program: models.BPFProgram{Name: "ratelimiting", Tenant: "edge"}, MapMemory: 700},
+			wantErr:  false,
+		},
+		{
+			name:     "TenantQuotaExceeded",
+			hostConf: &config.Config{TenantQuotas: quotas},
+			bpf:      &BPF{Program: models.BPFProgram{Name: "ratelimiting", Tenant: "edge"}, MapMemory: 701},
+			wantErr:  true,
+		},
+		{
+			name:     "OtherTenantNotCounted",
+			hostConf: &config.Config{TenantQuotas: quotas},
+			bpf:      &BPF{Program: models.BPFProgram{Name: "ratelimiting", Tenant: "core"}, MapMemory: 2000},
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NFConfigs{
+				IngressXDPBpfs: map[string]*list.List{"dummy": running},
+				IngressTCBpfs:  make(map[string]*list.List),
+				EgressTCBpfs:   make(map[string]*list.List),
+				hostConfig:     tt.hostConf,
+			}
+			if err := cfg.VerifyMapMemory(tt.bpf); (err != nil) != tt.wantErr {
+				t.Errorf("NFConfigs.VerifyMapMemory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// memFS - in-memory fileSystem for the tests, paths are absolute host paths
+type memFS struct {
+	mu    sync.Mutex
+	files fstest.MapFS
+}
+
+func newMemFS(files map[string]string) *memFS {
+	m := &memFS{files: make(fstest.MapFS)}
+	for name, data := range files {
+		m.files[memKey(name)] = &fstest.MapFile{Data: []byte(data), Mode: 0644}
+	}
+	return m
+}
+
+// useMemFS - replaces appFS with the in-memory filesystem for the test
+func useMemFS(t *testing.T, files map[string]string) *memFS {
+	m := newMemFS(files)
+	saved := appFS
+	appFS = m
+	t.Cleanup(func() { appFS = saved })
+	return m
+}
+
+func memKey(name string) string {
+	return strings.TrimPrefix(name, "/")
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.Open(memKey(name))
+}
+
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.Stat(memKey(name))
+}
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.ReadFile(memKey(name))
+}
+
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.ReadDir(memKey(name))
+}
+
+func (m *memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[memKey(name)] = &fstest.MapFile{Data: append([]byte(nil), data...), Mode: perm}
+	return nil
+}
+
+func (m *memFS) OpenFile(name string, flag int, perm fs.FileMode) (io.WriteCloser, error) {
+	f := &memFile{fs: m, name: name, perm: perm}
+	if flag&os.O_APPEND != 0 {
+		if data, err := m.ReadFile(name); err == nil {
+			f.Write(data)
+		}
+	}
+	return f, nil
+}
+
+func (m *memFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[memKey(path)] = &fstest.MapFile{Mode: fs.ModeDir | perm}
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[memKey(name)]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(m.files, memKey(name))
+	return nil
+}
+
+func (m *memFS) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memKey(path)
+	for name := range m.files {
+		if name == key || strings.HasPrefix(name, key+"/") {
+			delete(m.files, name)
+		}
+	}
+	return nil
+}
+
+// Rename - renames the file or the dir along with its tree
+func (m *memFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldKey, newKey := memKey(oldpath), memKey(newpath)
+	moved := false
+	for name, f := range m.files {
+		if name == oldKey || strings.HasPrefix(name, oldKey+"/") {
+			delete(m.files, name)
+			m.files[newKey+strings.TrimPrefix(name, oldKey)] = f
+			moved = true
+		}
+	}
+	if !moved {
+		return &fs.PathError{Op: "rename", Path: oldpath, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+func (m *memFS) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[memKey(name)]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	f.Mode = f.Mode&fs.ModeType | mode.Perm()
+	return nil
+}
+
+// memFile - file opened for writing, content is stored at close
+type memFile struct {
+	bytes.Buffer
+	fs   *memFS
+	name string
+	perm fs.FileMode
+}
+
+func (f *memFile) Close() error {
+	return f.fs.WriteFile(f.name, f.Bytes(), f.perm)
+}
@@ -0,0 +1,117 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/l3af-project/l3afd/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// memlockManaged - memlock limit of l3afd, inherited by the user programs, is raised for the maps of the
+// programs, false on kernels accounting the maps to the memory cgroup
+var memlockManaged bool
+
+// raiseMemlockLimit - raises the memlock limit of l3afd, replaced by the tests
+var raiseMemlockLimit = raiseMemlock
+
+// memcgAccounting - kernels 5.11 and newer account the BPF maps to the memory cgroup instead of the memlock
+// limit
+func memcgAccounting() bool {
+	release, err := getKernelRelease()
+	if err != nil {
+		return false
+	}
+	major, minor, err := kernelVersion(release)
+	return err == nil && (major > 5 || (major == 5 && minor >= 11))
+}
+
+// setMemlock - raises the memlock limit of l3afd to the configured limit, unlimited when the limit is 0
+func setMemlock(conf *config.Config) {
+	memlockManaged = false
+	memlockUsage.reset()
+	if conf == nil || !conf.MemlockEnabled {
+		return
+	}
+	if memcgAccounting() {
+		log.Info().Msg("BPF maps are accounted to the memory cgroup, memlock limit is not managed")
+		return
+	}
+	if _, err := memlockLimit(); err != nil {
+		log.Warn().Err(err).Msg("memlock limit is not managed")
+		return
+	}
+	memlockManaged = true
+	limit := memlockUnlimited
+	if conf.MemlockLimit > 0 {
+		limit = uint64(conf.MemlockLimit)
+	}
+	if err := raiseMemlockLimit(limit); err != nil {
+		log.Error().Err(err).Msgf("failed to raise the memlock limit of l3afd, programs with map_memory are checked against the current limit")
+	}
+}
+
+// memlockRegistry - locked memory of the maps of the started programs, the memlock limit is per user and
+// the programs of l3afd share it
+type memlockRegistry struct {
+	mu    sync.Mutex
+	bytes map[string]uint64 // key is iface, direction and program name
+}
+
+var memlockUsage = &memlockRegistry{bytes: make(map[string]uint64)}
+
+func memlockKey(ifaceName, direction, name string) string {
+	return ifaceName + "/" + direction + "/" + name
+}
+
+func (m *memlockRegistry) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes = make(map[string]uint64)
+}
+
+// reserve - raises the memlock limit to the locked memory of the running programs and the program, the
+// program isn't started when the host limit is insufficient instead of the loader failing with EPERM
+func (m *memlockRegistry) reserve(ifaceName, direction, name string, size uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memlockKey(ifaceName, direction, name)
+	var locked uint64
+	for k, v := range m.bytes {
+		if k != key {
+			locked += v
+		}
+	}
+	if err := raiseMemlockLimit(locked + size); err != nil {
+		limit, _ := memlockLimit()
+		return fmt.Errorf("program %s requires %d bytes of locked memory for its maps, running programs lock %d bytes and the memlock limit is %d bytes, raise the hard limit e.g. LimitMEMLOCK=infinity of the l3afd unit: %w",
+			name, size, locked, limit, err)
+	}
+	m.bytes[key] = size
+	return nil
+}
+
+// usage - locked memory reserved for the maps of the program
+func (m *memlockRegistry) usage(ifaceName, direction, name string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytes[memlockKey(ifaceName, direction, name)]
+}
+
+func (m *memlockRegistry) release(ifaceName, direction, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.bytes, memlockKey(ifaceName, direction, name))
+}
+
+// reserveMemlock - reserves the locked memory of the maps of the program before it is started
+func (b *BPF) reserveMemlock(ifaceName, direction string) error {
+	if !memlockManaged || b.Program.MapMemory <= 0 {
+		return nil
+	}
+	return memlockUsage.reserve(ifaceName, direction, b.slotName(), uint64(b.Program.MapMemory))
+}
@@ -0,0 +1,59 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestBPF_reserveMemlock(t *testing.T) {
+	var hostLimit, raised uint64 = 96 << 20, 0
+	saved := raiseMemlockLimit
+	raiseMemlockLimit = func(limit uint64) error {
+		if limit > hostLimit {
+			return errors.New("operation not permitted")
+		}
+		raised = limit
+		return nil
+	}
+	memlockManaged = true
+	t.Cleanup(func() {
+		raiseMemlockLimit = saved
+		memlockManaged = false
+		memlockUsage.reset()
+	})
+
+	rl := &BPF{Program: models.BPFProgram{Name: "ratelimiting", MapMemory: 64 << 20}}
+	if err := rl.reserveMemlock("eth0", models.XDPIngressType); err != nil {
+		t.Fatalf("reserveMemlock() error = %v", err)
+	}
+	if raised != 64<<20 {
+		t.Errorf("memlock limit raised to %d, want %d", raised, 64<<20)
+	}
+
+	// restart of the program doesn't count its own maps twice
+	if err := rl.reserveMemlock("eth0", models.XDPIngressType); err != nil {
+		t.Fatalf("reserveMemlock() error = %v on restart", err)
+	}
+
+	ct := &BPF{Program: models.BPFProgram{Name: "connection-tracker", MapMemory: 48 << 20}}
+	err := ct.reserveMemlock("eth0", models.XDPIngressType)
+	if err == nil || !strings.Contains(err.Error(), "running programs lock 67108864 bytes") {
+		t.Fatalf("reserveMemlock() error = %v, want insufficient memlock limit", err)
+	}
+
+	memlockUsage.release("eth0", models.XDPIngressType, "ratelimiting")
+	if err := ct.reserveMemlock("eth0", models.XDPIngressType); err != nil {
+		t.Errorf("reserveMemlock() error = %v after the program is stopped", err)
+	}
+
+	// programs without map memory are not checked
+	if err := (&BPF{Program: models.BPFProgram{Name: "tc-root"}}).reserveMemlock("eth0", models.IngressType); err != nil {
+		t.Errorf("reserveMemlock() error = %v without map memory", err)
+	}
+}
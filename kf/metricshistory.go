@@ -0,0 +1,159 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ErrInvalidHistoryQuery is returned when the metric or the since time of a metrics history query is not valid
+var ErrInvalidHistoryQuery = errors.New("invalid metrics history query")
+
+// metricSeriesKey - monitor map metric of a program
+type metricSeriesKey struct {
+	program string
+	metric  string
+}
+
+// metricSample - sampled value of a monitor map metric
+type metricSample struct {
+	at    time.Time
+	value float64
+}
+
+// metricSeries - ring of the last samples of a metric, next is the slot of the next sample
+type metricSeries struct {
+	samples []metricSample
+	next    int
+	full    bool
+}
+
+// metricsHistoryStore - samples of the monitor map metrics retained in memory for the local queries, so the
+// counters survive short outages of the scrape pipeline
+type metricsHistoryStore struct {
+	mu         sync.Mutex
+	retention  time.Duration
+	maxSamples int
+	series     map[metricSeriesKey]*metricSeries
+}
+
+var metricsHistory = &metricsHistoryStore{}
+
+// setMetricsHistory - sets the retention and the samples kept per metric of l3afd.cfg, history is disabled
+// when either is 0
+func setMetricsHistory(conf *config.Config) {
+	h := &metricsHistoryStore{series: make(map[metricSeriesKey]*metricSeries)}
+	if conf != nil && conf.MonitorMapsHistoryRetention > 0 && conf.MonitorMapsHistorySamples > 0 {
+		h.retention = conf.MonitorMapsHistoryRetention
+		h.maxSamples = conf.MonitorMapsHistorySamples
+	}
+	metricsHistory = h
+}
+
+// record - records the sample of the metric of the program, the oldest sample is overwritten when the ring
+// of the metric is full
+func (h *metricsHistoryStore) record(program, metric string, value float64, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.retention <= 0 || h.maxSamples <= 0 {
+		return
+	}
+	key := metricSeriesKey{program: program, metric: metric}
+	s, ok := h.series[key]
+	if !ok {
+		s = &metricSeries{}
+		h.series[key] = s
+	}
+	sample := metricSample{at: at, value: value}
+	if !s.full {
+		s.samples = append(s.samples, sample)
+		if len(s.samples) == h.maxSamples {
+			s.full = true
+		}
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+}
+
+// reset - drops the series of the program, of all the programs when the program is empty
+func (h *metricsHistoryStore) reset(program string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key := range h.series {
+		if len(program) == 0 || key.program == program {
+			delete(h.series, key)
+		}
+	}
+}
+
+// inOrder - samples of the series oldest first
+func (s *metricSeries) inOrder() []metricSample {
+	if !s.full {
+		return s.samples
+	}
+	return append(append([]metricSample{}, s.samples[s.next:]...), s.samples[:s.next]...)
+}
+
+// query - samples of the metric taken after since, of all the programs when the program is empty. The series
+// with no samples in the retention are dropped, i.e. the metrics of the programs no longer running.
+func (h *metricsHistoryStore) query(metric, program string, since, now time.Time) []models.L3afDMetricHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	expired := now.Add(-h.retention)
+	if since.Before(expired) {
+		since = expired
+	}
+	result := make([]models.L3afDMetricHistory, 0)
+	for key, s := range h.series {
+		samples := s.inOrder()
+		if len(samples) == 0 || !samples[len(samples)-1].at.After(expired) {
+			delete(h.series, key)
+			continue
+		}
+		if key.metric != metric || (len(program) > 0 && key.program != program) {
+			continue
+		}
+		history := models.L3afDMetricHistory{Program: key.program, Metric: key.metric, Samples: make([]models.L3afDMetricSample, 0)}
+		for _, sample := range samples {
+			if sample.at.After(since) {
+				history.Samples = append(history.Samples, models.L3afDMetricSample{
+					Time:  sample.at.UTC().Format(time.RFC3339Nano),
+					Value: sample.value,
+				})
+			}
+		}
+		result = append(result, history)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Program < result[j].Program })
+	return result
+}
+
+// MetricsHistory - retained samples of the monitor map metric taken after since, since is a time in RFC 3339
+// format or a duration before now e.g. 15m, the whole retention when empty
+func MetricsHistory(metric, program, since string) ([]models.L3afDMetricHistory, error) {
+	if len(metric) == 0 {
+		return nil, fmt.Errorf("%w: metric is required", ErrInvalidHistoryQuery)
+	}
+	now := timeNow()
+	var from time.Time
+	if len(since) > 0 {
+		if d, err := time.ParseDuration(since); err == nil {
+			from = now.Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			from = t
+		} else {
+			return nil, fmt.Errorf("%w: since %q is not a time in RFC 3339 format or a duration", ErrInvalidHistoryQuery, since)
+		}
+	}
+	return metricsHistory.query(metric, program, from, now), nil
+}
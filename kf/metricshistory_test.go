@@ -0,0 +1,61 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+func TestMetricsHistory(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+	setMetricsHistory(&config.Config{MonitorMapsHistoryRetention: time.Hour, MonitorMapsHistorySamples: 3})
+	defer setMetricsHistory(nil)
+
+	const metric = "rl_drop_count_map_0_scalar"
+	for i := 0; i < 5; i++ {
+		metricsHistory.record("ratelimiting", metric, float64(i), now.Add(time.Duration(i-5)*time.Minute))
+	}
+	metricsHistory.record("connection-limit", metric, 7, now.Add(-2*time.Hour))
+	metricsHistory.record("ratelimiting", "rl_ports_map_0_scalar", 1, now)
+
+	history, err := MetricsHistory(metric, "", "")
+	if err != nil {
+		t.Fatalf("MetricsHistory() error = %v", err)
+	}
+	// ring keeps the last 3 samples, expired series are dropped
+	if len(history) != 1 || history[0].Program != "ratelimiting" || len(history[0].Samples) != 3 {
+		t.Fatalf("MetricsHistory() = %+v, want the last 3 samples of ratelimiting", history)
+	}
+	if s := history[0].Samples; s[0].Value != 2 || s[2].Value != 4 || s[2].Time != "2026-10-16T09:29:00Z" {
+		t.Errorf("samples = %+v, want 2 to 4 oldest first", s)
+	}
+
+	if history, _ := MetricsHistory(metric, "ratelimiting", "150s"); len(history) != 1 || len(history[0].Samples) != 2 {
+		t.Errorf("MetricsHistory() since 150s = %+v, want 2 samples", history)
+	}
+	if history, _ := MetricsHistory(metric, "ratelimiting", "2026-10-16T09:28:30Z"); len(history) != 1 || len(history[0].Samples) != 1 {
+		t.Errorf("MetricsHistory() since 09:28:30 = %+v, want 1 sample", history)
+	}
+	if history, _ := MetricsHistory(metric, "connection-limit", ""); len(history) != 0 {
+		t.Errorf("MetricsHistory() = %+v, want no history of the other program", history)
+	}
+
+	for _, q := range [][2]string{{"", ""}, {metric, "yesterday"}} {
+		if _, err := MetricsHistory(q[0], "", q[1]); !errors.Is(err, ErrInvalidHistoryQuery) {
+			t.Errorf("MetricsHistory(%q, since %q) error = %v, want ErrInvalidHistoryQuery", q[0], q[1], err)
+		}
+	}
+
+	setMetricsHistory(&config.Config{})
+	metricsHistory.record("ratelimiting", metric, 1, now)
+	if history, _ := MetricsHistory(metric, "", ""); len(history) != 0 {
+		t.Errorf("MetricsHistory() = %+v, want no history when disabled", history)
+	}
+}
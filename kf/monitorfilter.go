@@ -0,0 +1,150 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// ErrMetricsCardinality is returned when the monitor maps of the config exceed the series limits
+var ErrMetricsCardinality = errors.New("monitor map series limit exceeded")
+
+// monitorFilter - monitor maps exported as metrics, matched by <program>/<map> patterns, and the series limits
+// bounding the cardinality of the metrics
+type monitorFilter struct {
+	allow            []string // exported maps, all the maps when empty
+	deny             []string // maps never exported, deny wins over allow
+	maxSeriesPerProg int      // series of a program, 0 is unlimited
+	seriesBudget     int      // series of the node, 0 is unlimited
+}
+
+var monitorMapFilter = &monitorFilter{}
+
+// newMonitorFilter - filter of the monitor maps of l3afd.cfg, the patterns are verified
+func newMonitorFilter(conf *config.Config) (*monitorFilter, error) {
+	f := &monitorFilter{}
+	if conf == nil {
+		return f, nil
+	}
+	for _, pattern := range append(append([]string{}, conf.MonitorMapsAllow...), conf.MonitorMapsDeny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid monitor map pattern %q: %w", pattern, err)
+		}
+	}
+	f.allow = conf.MonitorMapsAllow
+	f.deny = conf.MonitorMapsDeny
+	f.maxSeriesPerProg = conf.MonitorMapsMaxSeriesPerProgram
+	f.seriesBudget = conf.MonitorMapsSeriesBudget
+	return f, nil
+}
+
+// setMonitorFilter - configures the monitor maps exported as metrics from l3afd.cfg
+func setMonitorFilter(conf *config.Config) error {
+	f, err := newMonitorFilter(conf)
+	if err != nil {
+		return err
+	}
+	monitorMapFilter = f
+	return nil
+}
+
+// exported - monitor map of the program is exported as metrics
+func (f *monitorFilter) exported(program, mapName string) bool {
+	name := program + "/" + mapName
+	for _, pattern := range f.deny {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, pattern := range f.allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// programSeries - series of the exported monitor maps of the program, a wildcard of keys counts the most keys
+// sampled per element
+func (f *monitorFilter) programSeries(prog *models.BPFProgram) int {
+	series := 0
+	for _, element := range prog.MonitorMaps {
+		if !f.exported(prog.Name, element.Name) {
+			continue
+		}
+		keys := 1
+		if len(element.Keys) > 0 {
+			parsed, all, err := parseMonitorKeys(element.Keys)
+			if err != nil {
+				continue
+			}
+			keys = len(parsed)
+			if all {
+				keys = maxMonitorKeys
+			}
+		}
+		fields := len(element.Value)
+		if fields == 0 {
+			fields = 1
+		}
+		series += keys * fields
+	}
+	return series
+}
+
+// ValidateMonitorMapSeries - Verifies the series of the exported monitor maps of the enabled programs are
+// within the series per program and the series budget of the node, the running programs of the interfaces
+// missing in the config count towards the budget. This is checked before applying any change, so a
+// misconfigured program does not flood the metrics backend.
+func (c *NFConfigs) ValidateMonitorMapSeries(bpfProgs []models.L3afBPFPrograms) error {
+	f := monitorMapFilter
+	if f.maxSeriesPerProg <= 0 && f.seriesBudget <= 0 {
+		return nil
+	}
+
+	total := 0
+	inConfig := make(map[string]bool)
+	for _, cfg := range bpfProgs {
+		inConfig[cfg.Iface] = true
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil || !loadedStatus(ref.prog.AdminStatus) {
+				continue
+			}
+			series := f.programSeries(ref.prog)
+			if f.maxSeriesPerProg > 0 && series > f.maxSeriesPerProg {
+				return fmt.Errorf("%w: program %s on iface %s exports %d series, max allowed %d",
+					ErrMetricsCardinality, ref.prog.Name, cfg.Iface, series, f.maxSeriesPerProg)
+			}
+			total += series
+		}
+	}
+
+	for _, bpfs := range []map[string]*list.List{c.IngressXDPBpfs, c.IngressTCBpfs, c.EgressTCBpfs} {
+		for iface, l := range bpfs {
+			if inConfig[iface] || l == nil {
+				continue
+			}
+			for e := l.Front(); e != nil; e = e.Next() {
+				bpf := e.Value.(*BPF)
+				if loadedStatus(bpf.Program.AdminStatus) {
+					total += f.programSeries(&bpf.Program)
+				}
+			}
+		}
+	}
+
+	if f.seriesBudget > 0 && total > f.seriesBudget {
+		return fmt.Errorf("%w: %d series of the monitor maps requested, budget %d", ErrMetricsCardinality, total, f.seriesBudget)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestMonitorFilter_exported(t *testing.T) {
+	f, err := newMonitorFilter(&config.Config{MonitorMapsAllow: []string{"ratelimiting/*", "*/drop_count"}, MonitorMapsDeny: []string{"ratelimiting/debug_*"}})
+	if err != nil {
+		t.Fatalf("newMonitorFilter() error = %v", err)
+	}
+	tests := []struct {
+		program, mapName string
+		want             bool
+	}{
+		{"ratelimiting", "rl_drop_count", true},
+		{"ratelimiting", "debug_hits", false},
+		{"connlimit", "drop_count", true},
+		{"connlimit", "cl_conn_count", false},
+	}
+	for _, tt := range tests {
+		if got := f.exported(tt.program, tt.mapName); got != tt.want {
+			t.Errorf("exported(%s, %s) = %v, want %v", tt.program, tt.mapName, got, tt.want)
+		}
+	}
+	if _, err := newMonitorFilter(&config.Config{MonitorMapsDeny: []string{"ratelimiting/["}}); err == nil {
+		t.Errorf("newMonitorFilter() error = nil, want invalid pattern")
+	}
+}
+
+func TestValidateMonitorMapSeries(t *testing.T) {
+	t.Cleanup(func() { monitorMapFilter = &monitorFilter{} })
+	monitorMapFilter = &monitorFilter{deny: []string{"*/debug_*"}, maxSeriesPerProg: 300, seriesBudget: 400}
+
+	field := models.L3afDNFMetricsField{Name: "packets", Type: "u64"}
+	prog := &models.BPFProgram{Name: "ratelimiting", AdminStatus: models.Enabled, MonitorMaps: []models.L3afDNFMetricsMap{
+		{Name: "rl_drop_count", Keys: "0-3", Value: []models.L3afDNFMetricsField{field, field}}, // 8 series
+		{Name: "rl_recv_count", Key: 0},          // 1 series
+		{Name: "debug_hits", Keys: "*"},          // denied
+		{Name: "rl_ports", Keys: monitorAllKeys}, // 256 series
+	}}
+	if got := monitorMapFilter.programSeries(prog); got != 265 {
+		t.Errorf("programSeries() = %d, want 265", got)
+	}
+
+	running := list.New()
+	running.PushBack(&BPF{Program: models.BPFProgram{Name: "connlimit", AdminStatus: models.Enabled, MonitorMaps: []models.L3afDNFMetricsMap{{Name: "cl_ports", Keys: "*"}}}})
+	c := &NFConfigs{IngressXDPBpfs: map[string]*list.List{"eth1": running}, IngressTCBpfs: map[string]*list.List{}, EgressTCBpfs: map[string]*list.List{}}
+	cfg := []models.L3afBPFPrograms{{Iface: "eth0", BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{prog}}}}
+
+	// running programs of the other interfaces count towards the budget
+	if err := c.ValidateMonitorMapSeries(cfg); !errors.Is(err, ErrMetricsCardinality) {
+		t.Errorf("ValidateMonitorMapSeries() error = %v, want the budget exceeded", err)
+	}
+	c.IngressXDPBpfs = map[string]*list.List{}
+	if err := c.ValidateMonitorMapSeries(cfg); err != nil {
+		t.Errorf("ValidateMonitorMapSeries() error = %v, want nil", err)
+	}
+
+	monitorMapFilter.maxSeriesPerProg = 100
+	if err := c.ValidateMonitorMapSeries(cfg); !errors.Is(err, ErrMetricsCardinality) {
+		t.Errorf("ValidateMonitorMapSeries() error = %v, want the series per program exceeded", err)
+	}
+}
+
+func Test_kfMetrics_monitorTasks_filtered(t *testing.T) {
+	t.Cleanup(func() { monitorMapFilter = &monitorFilter{} })
+	monitorMapFilter = &monitorFilter{deny: []string{"ratelimiting/b"}}
+
+	bpfList := list.New()
+	bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "ratelimiting", SeqID: 1, AdminStatus: models.Enabled,
+		MonitorMaps: []models.L3afDNFMetricsMap{{Name: "a"}, {Name: "b"}}}})
+	tasks := NewpKFMetrics(true, 10, time.Second, 0).monitorTasks(map[string]*list.List{"eth0": bpfList}, nil)
+	if len(tasks) != 1 || tasks[0].element.Name != "a" {
+		t.Errorf("monitorTasks() = %+v, want the denied map not sampled", tasks)
+	}
+}
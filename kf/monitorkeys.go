@@ -0,0 +1,146 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// monitorAllKeys - monitor map keys wildcard, all the keys of the map are sampled
+const monitorAllKeys = "*"
+
+// maxMonitorKeys - keys sampled per monitor map element, bounds the series of a wildcard
+const maxMonitorKeys = 256
+
+// parseMonitorKeys - parses comma separated keys and key ranges, all is true for the wildcard
+func parseMonitorKeys(spec string) (keys []int, all bool, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == monitorAllKeys {
+		return nil, true, nil
+	}
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		from, to := item, item
+		if i := strings.Index(item, "-"); i > 0 {
+			from, to = item[:i], item[i+1:]
+		}
+		first, err := strconv.Atoi(from)
+		if err != nil || first < 0 {
+			return nil, false, fmt.Errorf("invalid monitor map key %q", item)
+		}
+		last, err := strconv.Atoi(to)
+		if err != nil || last < first {
+			return nil, false, fmt.Errorf("invalid monitor map key range %q", item)
+		}
+		if last-first >= maxMonitorKeys {
+			return nil, false, fmt.Errorf("monitor map key range %q exceeds %d keys", item, maxMonitorKeys)
+		}
+		for k := first; k <= last; k++ {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	if len(keys) > maxMonitorKeys {
+		return nil, false, fmt.Errorf("monitor map keys %q exceed %d keys", spec, maxMonitorKeys)
+	}
+	sort.Ints(keys)
+	return keys, false, nil
+}
+
+// ValidateMonitorMaps - Verifies the keys and value layouts of the monitor maps of the enabled programs
+func ValidateMonitorMaps(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil || !loadedStatus(ref.prog.AdminStatus) {
+				continue
+			}
+			for _, element := range ref.prog.MonitorMaps {
+				if err := validateMonitorValue(element.Value); err != nil {
+					return fmt.Errorf("program %s on iface %s monitor map %s: %w", ref.prog.Name, cfg.Iface, element.Name, err)
+				}
+				if len(element.Keys) == 0 {
+					continue
+				}
+				if _, _, err := parseMonitorKeys(element.Keys); err != nil {
+					return fmt.Errorf("program %s on iface %s monitor map %s: %w", ref.prog.Name, cfg.Iface, element.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// monitorElements - monitor map element per key of the element keys and field of the value layout,
+// wildcard is expanded with the keys present in the map
+func (b *BPF) monitorElements(element models.L3afDNFMetricsMap) []models.L3afDNFMetricsMap {
+	if len(element.Keys) == 0 {
+		return monitorFieldElements(element)
+	}
+	keys, all, err := parseMonitorKeys(element.Keys)
+	if err != nil {
+		log.Error().Err(err).Msgf("program %s monitor map %s", b.Program.Name, element.Name)
+		return nil
+	}
+	if all {
+		if keys, err = b.monitorMapKeys(element.Name); err != nil {
+			log.Warn().Err(err).Msgf("failed to list the keys of program %s monitor map %s", b.Program.Name, element.Name)
+			return nil
+		}
+	}
+	elements := make([]models.L3afDNFMetricsMap, 0, len(keys)*len(monitorFieldElements(element)))
+	for _, k := range keys {
+		keyed := models.L3afDNFMetricsMap{Name: element.Name, Key: k, Aggregator: element.Aggregator, Value: element.Value}
+		elements = append(elements, monitorFieldElements(keyed)...)
+	}
+	return elements
+}
+
+// monitorMapKeys - keys present in the monitor map of the program
+func (b *BPF) monitorMapKeys(mapName string) ([]int, error) {
+	bpfMap, err := b.GetBPFMap(mapName)
+	if err != nil {
+		return nil, err
+	}
+	m, err := bpfAPI.NewMapFromID(bpfMap.MapID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open map %s: %w", mapName, err)
+	}
+	defer m.Close()
+	return mapKeys(m, maxMonitorKeys)
+}
+
+// mapKeys - integer keys of the map in iteration order, up to the limit
+func mapKeys(m ebpfMap, limit int) ([]int, error) {
+	var keys []int
+	var key, next int
+	var cur interface{} // first key when nil
+	for {
+		if err := m.NextKey(cur, unsafe.Pointer(&next)); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				return keys, nil
+			}
+			return nil, err
+		}
+		if len(keys) == limit {
+			log.Warn().Msgf("map has more than %d keys, remaining keys are not monitored", limit)
+			return keys, nil
+		}
+		keys = append(keys, next)
+		key = next
+		cur = unsafe.Pointer(&key)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseMonitorKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []int
+		wantAll bool
+		wantErr bool
+	}{
+		{name: "Range", spec: "0-3", want: []int{0, 1, 2, 3}},
+		{name: "RangesAndKeys", spec: "8, 0-2,1", want: []int{0, 1, 2, 8}},
+		{name: "Wildcard", spec: "*", wantAll: true},
+		{name: "InvalidKey", spec: "a", wantErr: true},
+		{name: "NegativeKey", spec: "-1", wantErr: true},
+		{name: "ReversedRange", spec: "5-2", wantErr: true},
+		{name: "TooManyKeys", spec: "0-1000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, all, err := parseMonitorKeys(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMonitorKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if all != tt.wantAll {
+				t.Errorf("parseMonitorKeys() all = %v, want %v", all, tt.wantAll)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMonitorKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mapKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[int]int
+		limit   int
+		want    []int
+	}{
+		{name: "Empty", entries: map[int]int{}, limit: 4},
+		{name: "All", entries: map[int]int{7: 1, 2: 1, 5: 1}, limit: 4, want: []int{2, 5, 7}},
+		{name: "Limit", entries: map[int]int{1: 1, 2: 1, 3: 1}, limit: 2, want: []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapKeys(&fakeMap{entries: tt.entries}, tt.limit)
+			if err != nil {
+				t.Fatalf("mapKeys() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
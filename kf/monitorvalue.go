@@ -0,0 +1,85 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// monitorFieldSizes - byte size of the monitor map value field types
+var monitorFieldSizes = map[string]int{"u8": 1, "u16": 2, "u32": 4, "u64": 8, "be16": 2, "be32": 4, "be64": 8}
+
+// validateMonitorValue - verifies the fields of the value layout of a monitor map
+func validateMonitorValue(fields []models.L3afDNFMetricsField) error {
+	names := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if len(f.Name) == 0 {
+			return fmt.Errorf("monitor map value field at offset %d has no name", f.Offset)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate monitor map value field %s", f.Name)
+		}
+		names[f.Name] = true
+		if _, ok := monitorFieldSizes[f.Type]; !ok {
+			return fmt.Errorf("monitor map value field %s has unknown type %q", f.Name, f.Type)
+		}
+		if f.Offset < 0 {
+			return fmt.Errorf("monitor map value field %s has negative offset %d", f.Name, f.Offset)
+		}
+	}
+	return nil
+}
+
+// decodeMonitorField - decodes the field from the map value, integers are in the host byte order unless
+// big endian (be) types are used
+func decodeMonitorField(value []byte, f models.L3afDNFMetricsField) (float64, error) {
+	size, ok := monitorFieldSizes[f.Type]
+	if !ok {
+		return 0, fmt.Errorf("unknown type %s of field %s", f.Type, f.Name)
+	}
+	if f.Offset < 0 || f.Offset+size > len(value) {
+		return 0, fmt.Errorf("field %s at offset %d overruns the value of %d bytes", f.Name, f.Offset, len(value))
+	}
+	b := value[f.Offset : f.Offset+size]
+	order := nativeEndian
+	if f.Type[0] == 'b' {
+		order = binary.BigEndian
+	}
+	switch size {
+	case 1:
+		return float64(b[0]), nil
+	case 2:
+		return float64(order.Uint16(b)), nil
+	case 4:
+		return float64(order.Uint32(b)), nil
+	default:
+		return float64(order.Uint64(b)), nil
+	}
+}
+
+// monitorFieldElements - monitor map element per field of the value layout
+func monitorFieldElements(element models.L3afDNFMetricsMap) []models.L3afDNFMetricsMap {
+	if len(element.Value) <= 1 {
+		return []models.L3afDNFMetricsMap{element}
+	}
+	elements := make([]models.L3afDNFMetricsMap, 0, len(element.Value))
+	for _, f := range element.Value {
+		e := element
+		e.Value = []models.L3afDNFMetricsField{f}
+		elements = append(elements, e)
+	}
+	return elements
+}
+
+// monitorField - field of the monitor map element, nil when the value is an integer
+func monitorField(element models.L3afDNFMetricsMap) *models.L3afDNFMetricsField {
+	if len(element.Value) == 0 {
+		return nil
+	}
+	f := element.Value[0]
+	return &f
+}
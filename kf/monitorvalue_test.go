@@ -0,0 +1,86 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_decodeMonitorField(t *testing.T) {
+	value := make([]byte, 20)
+	nativeEndian.PutUint64(value[0:], 1500)
+	nativeEndian.PutUint64(value[8:], 96000)
+	binary.BigEndian.PutUint32(value[16:], 7)
+
+	tests := []struct {
+		name    string
+		field   models.L3afDNFMetricsField
+		want    float64
+		wantErr bool
+	}{
+		{name: "Packets", field: models.L3afDNFMetricsField{Name: "packets", Offset: 0, Type: "u64"}, want: 1500},
+		{name: "Bytes", field: models.L3afDNFMetricsField{Name: "bytes", Offset: 8, Type: "u64"}, want: 96000},
+		{name: "BigEndian", field: models.L3afDNFMetricsField{Name: "drops", Offset: 16, Type: "be32"}, want: 7},
+		{name: "Overrun", field: models.L3afDNFMetricsField{Name: "drops", Offset: 16, Type: "u64"}, wantErr: true},
+		{name: "UnknownType", field: models.L3afDNFMetricsField{Name: "drops", Offset: 16, Type: "f32"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeMonitorField(value, tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeMonitorField() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeMonitorField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateMonitorValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []models.L3afDNFMetricsField
+		wantErr bool
+	}{
+		{name: "Integer"},
+		{name: "Struct", fields: []models.L3afDNFMetricsField{{Name: "packets", Type: "u64"}, {Name: "bytes", Offset: 8, Type: "u64"}}},
+		{name: "NoName", fields: []models.L3afDNFMetricsField{{Type: "u64"}}, wantErr: true},
+		{name: "Duplicate", fields: []models.L3afDNFMetricsField{{Name: "packets", Type: "u64"}, {Name: "packets", Offset: 8, Type: "u64"}}, wantErr: true},
+		{name: "UnknownType", fields: []models.L3afDNFMetricsField{{Name: "packets", Type: "string"}}, wantErr: true},
+		{name: "NegativeOffset", fields: []models.L3afDNFMetricsField{{Name: "packets", Offset: -1, Type: "u8"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMonitorValue(tt.fields); (err != nil) != tt.wantErr {
+				t.Errorf("validateMonitorValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_monitorMetricNameFields(t *testing.T) {
+	element := models.L3afDNFMetricsMap{
+		Name:       "rl_stats_map",
+		Key:        1,
+		Aggregator: "scalar",
+		Value:      []models.L3afDNFMetricsField{{Name: "packets", Type: "u64"}, {Name: "bytes", Offset: 8, Type: "u64"}},
+	}
+	elements := monitorFieldElements(element)
+	if len(elements) != 2 {
+		t.Fatalf("monitorFieldElements() returned %d elements, want 2", len(elements))
+	}
+	want := []string{"rl_stats_map_1_scalar_packets", "rl_stats_map_1_scalar_bytes"}
+	for i, e := range elements {
+		if got := monitorMetricName(e); got != want[i] {
+			t.Errorf("monitorMetricName() = %s, want %s", got, want[i])
+		}
+	}
+	if monitorElementKey(elements[0]) == monitorElementKey(elements[1]) {
+		t.Errorf("monitorElementKey() of the fields is not unique")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+// maxNFCommandOutput - output of the NF commands kept for the logs and the status check
+const maxNFCommandOutput = 64 * 1024
+
+// nfCommandKillWait - wait for the output of the killed command
+const nfCommandKillWait = time.Second
+
+// defaultNFCommandEnv - clean environment of the NF commands
+var defaultNFCommandEnv = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+
+// nfCommandConfig - timeouts and environment of the NF commands and overall deadline of the program
+// start, zero timeout means no timeout
+type nfCommandConfig struct {
+	startTimeout      time.Duration
+	stopTimeout       time.Duration
+	statusTimeout     time.Duration
+	startDeadline     time.Duration
+	stopGrace         time.Duration // time the user program may take to exit after SIGTERM, program stop grace period overrides
+	env               []string
+	nfFilesDir        string                       // directory of the rules and KF config files, artifact directory when empty
+	directionArgs     map[string]map[string]string // default args of the directions of the arg schema version 2
+	controlDir        string                       // directory of the control sockets of the arg schema version 3
+	heartbeatInterval time.Duration                // interval of the heartbeat writes, program heartbeat interval overrides
+	standbyMapDir     string                       // directory of the staging prog maps of the standby programs
+	consumedMapDir    string                       // directory the consumed maps declaring no pin path are re-pinned into
+	taskTimeout       time.Duration                // timeout of the runs of the scheduled tasks, task timeout overrides
+}
+
+var nfCmdConfig = nfCommandConfig{env: defaultNFCommandEnv}
+
+// setNFCommandConfig - configures the NF commands from l3afd.cfg
+func setNFCommandConfig(conf *config.Config) {
+	if conf == nil {
+		return
+	}
+	nfCmdConfig = nfCommandConfig{
+		startTimeout:      conf.NFCommandStartTimeout,
+		stopTimeout:       conf.NFCommandStopTimeout,
+		statusTimeout:     conf.NFCommandStatusTimeout,
+		startDeadline:     conf.NFStartDeadline,
+		stopGrace:         conf.NFStopGracePeriod,
+		env:               append(append([]string{}, defaultNFCommandEnv...), conf.NFCommandEnv...),
+		nfFilesDir:        conf.NFFilesDir,
+		directionArgs:     conf.DirectionDefaultArgs,
+		controlDir:        conf.NFControlSocketDir,
+		heartbeatInterval: conf.NFHeartbeatInterval,
+		standbyMapDir:     conf.NFStandbyMapDir,
+		consumedMapDir:    conf.NFConsumedMapDir,
+		taskTimeout:       conf.NFTaskTimeout,
+	}
+}
+
+// newNFCommand - builds the NF command from the absolute path, so the command is never resolved with
+// PATH. Command runs in its artifact directory with the clean environment, in its own process group.
+func newNFCommand(cmdPath string, args ...string) (*exec.Cmd, error) {
+	if !filepath.IsAbs(cmdPath) {
+		return nil, fmt.Errorf("command %s is not an absolute path", cmdPath)
+	}
+	cmd := execCommand(cmdPath, args...)
+	cmd.Dir = filepath.Dir(cmdPath)
+	cmd.Env = append(cmd.Env, nfCmdConfig.env...)
+	setProcessGroup(cmd)
+	return cmd, nil
+}
+
+// runNFCommand - runs the command to completion capturing its output, the command is killed when
+// it does not complete in the timeout
+func runNFCommand(cmd *exec.Cmd, timeout time.Duration) (string, error) {
+	return runNFCommandContext(context.Background(), cmd, timeout)
+}
+
+// runNFCommandContext - runs the command as runNFCommand, the command is also killed when the context is done
+func runNFCommandContext(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) (string, error) {
+	out := &limitedBuffer{max: maxNFCommandOutput}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	assignNFJob(cmd)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var err error
+	select {
+	case err := <-done:
+		return out.String(), err
+	case <-expired:
+		err = fmt.Errorf("command %s timed out after %s", cmd.Path, timeout)
+	case <-ctx.Done():
+		err = fmt.Errorf("command %s is canceled: %w", cmd.Path, ctx.Err())
+	}
+	_ = signalNFProcess(cmd, syscall.SIGKILL)
+	// children of the command outside its process group holding the output open keep Wait blocked
+	select {
+	case <-done:
+	case <-time.After(nfCommandKillWait):
+	}
+	return out.String(), err
+}
+
+// limitedBuffer - buffer dropping the writes beyond max bytes, output of a killed command is still
+// written while it is read
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n := b.max - b.buf.Len(); n < len(p) {
+		if n > 0 {
+			b.buf.Write(p[:n])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,131 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func Test_newNFCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmdPath string
+		wantDir string
+		wantErr bool
+	}{
+		{
+			name:    "AbsolutePath",
+			cmdPath: "/var/l3afd/ratelimiting/latest/l3af_ratelimiting/ratelimiting",
+			wantDir: "/var/l3afd/ratelimiting/latest/l3af_ratelimiting",
+			wantErr: false,
+		},
+		{
+			name:    "RelativePath",
+			cmdPath: "ratelimiting",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := newNFCommand(tt.cmdPath, "--iface=lo")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newNFCommand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if cmd.Dir != tt.wantDir {
+				t.Errorf("newNFCommand() dir = %v, want %v", cmd.Dir, tt.wantDir)
+			}
+			if len(cmd.Env) != len(nfCmdConfig.env) {
+				t.Errorf("newNFCommand() env = %v, want %v", cmd.Env, nfCmdConfig.env)
+			}
+		})
+	}
+}
+
+func Test_runNFCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		wantOut string
+		wantErr bool
+	}{
+		{
+			name:    "Output",
+			script:  "echo -n RUNNING",
+			timeout: 10 * time.Second,
+			wantOut: "RUNNING",
+			wantErr: false,
+		},
+		{
+			name:    "ExitStatus",
+			script:  "echo -n failed >&2; exit 1",
+			timeout: 10 * time.Second,
+			wantOut: "failed",
+			wantErr: true,
+		},
+		{
+			name:    "Timeout",
+			script:  "sleep 10",
+			timeout: 100 * time.Millisecond,
+			wantOut: "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runNFCommand(exec.Command("/bin/sh", "-c", tt.script), tt.timeout)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runNFCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if out != tt.wantOut {
+				t.Errorf("runNFCommand() output = %q, want %q", out, tt.wantOut)
+			}
+		})
+	}
+}
+
+func Test_runNFCommandProcessGroup(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	saved := nfCmdConfig
+	nfCmdConfig.env = nil
+	t.Cleanup(func() { nfCmdConfig = saved })
+
+	cmd, err := newNFCommand("/bin/sh", "-c", "sleep 10 & wait")
+	if err != nil {
+		t.Fatalf("newNFCommand() error = %v", err)
+	}
+	start := time.Now()
+	if _, err := runNFCommand(cmd, 100*time.Millisecond); err == nil {
+		t.Fatalf("runNFCommand() error = nil, want timeout")
+	}
+	// the forked sleep is killed along with the shell, so its output doesn't keep Wait blocked
+	if elapsed := time.Since(start); elapsed >= nfCommandKillWait {
+		t.Errorf("runNFCommand() took %s, forked child is not killed with the process group", elapsed)
+	}
+}
+
+func Test_limitedBuffer(t *testing.T) {
+	b := &limitedBuffer{max: 4}
+	if n, err := b.Write([]byte("RUN")); n != 3 || err != nil {
+		t.Errorf("Write() = %d, %v", n, err)
+	}
+	if n, err := b.Write([]byte("NING")); n != 4 || err != nil {
+		t.Errorf("Write() = %d, %v", n, err)
+	}
+	if b.String() != "RUNN" {
+		t.Errorf("limitedBuffer = %q, want %q", b.String(), "RUNN")
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"reflect"
 	"strings"
@@ -18,6 +17,7 @@ import (
 
 	"github.com/l3af-project/l3afd/config"
 	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
 
 	"github.com/rs/zerolog/log"
 )
@@ -37,16 +37,26 @@ type NFConfigs struct {
 	hostConfig   *config.Config
 	processMon   *pCheck
 	kfMetricsMon *kfMetrics
+	usageMon     *pUsage
 
 	// keep track of interfaces
 	ifaces map[string]string
 
+	// BTF of the running kernel for CO-RE programs
+	btfPath string
+
+	// active/standby peering state
+	peer *peering
+
+	// trace of the config apply in progress
+	traceID string
+
 	mu *sync.Mutex
 }
 
 var shutdownInterval = 900 * time.Millisecond
 
-func NewNFConfigs(ctx context.Context, host string, hostConf *config.Config, pMon *pCheck, metricsMon *kfMetrics) (*NFConfigs, error) {
+func NewNFConfigs(ctx context.Context, host string, hostConf *config.Config, pMon *pCheck, metricsMon *kfMetrics, usageMon *pUsage) (*NFConfigs, error) {
 	nfConfigs := &NFConfigs{
 		ctx:            ctx,
 		hostName:       host,
@@ -56,6 +66,48 @@ func NewNFConfigs(ctx context.Context, host string, hostConf *config.Config, pMo
 		EgressTCBpfs:   make(map[string]*list.List),
 		mu:             new(sync.Mutex),
 	}
+	setNFCommandConfig(hostConf)
+	setXDPOffload(hostConf)
+	setCrashForensics(hostConf)
+	setCoreDumps(hostConf)
+	setMemlock(hostConf)
+	setMetricsHistory(hostConf)
+	if err := setContainerMode(hostConf); err != nil {
+		return nil, fmt.Errorf("container mode: %w", err)
+	}
+	if err := setApplyWindow(hostConf); err != nil {
+		return nil, err
+	}
+	if err := setStateEncryption(hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up state encryption: %w", err)
+	}
+	if err := setupNFFilesDir(hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up nf files directory: %w", err)
+	}
+	if err := setWebhooks(ctx, host, hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up webhooks: %w", err)
+	}
+	if err := setMonitorFilter(hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up monitor map filter: %w", err)
+	}
+	if err := setNodeLabels(hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up node labels: %w", err)
+	}
+	if err := setArtifactAllowlist(hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up artifact allowlist: %w", err)
+	}
+	if err := setNFSandbox(hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up nf sandbox: %w", err)
+	}
+	if err := setBPFGatekeeper(hostConf); err != nil {
+		return nil, fmt.Errorf("failed to set up bpf gatekeeper: %w", err)
+	}
+	nfConfigs.loadPausedPrograms()
+	nfConfigs.loadDisabledPrograms()
+	nfConfigs.loadFreeze()
+	nfConfigs.loadAppliedGeneration()
+	nfConfigs.loadHistory()
+	nfConfigs.loadPinOwners()
 
 	var err error
 	if nfConfigs.hostInterfaces, err = getHostInterfaces(); err != nil {
@@ -65,9 +117,32 @@ func NewNFConfigs(ctx context.Context, host string, hostConf *config.Config, pMo
 	}
 
 	nfConfigs.processMon = pMon
+	nfConfigs.processMon.mu = nfConfigs.mu
 	nfConfigs.processMon.pCheckStart(nfConfigs.IngressXDPBpfs, nfConfigs.IngressTCBpfs, nfConfigs.EgressTCBpfs)
 	nfConfigs.kfMetricsMon = metricsMon
 	nfConfigs.kfMetricsMon.kfMetricsStart(nfConfigs.IngressXDPBpfs, nfConfigs.IngressTCBpfs, nfConfigs.EgressTCBpfs)
+	nfConfigs.usageMon = usageMon
+	nfConfigs.usageMon.pUsageStart(nfConfigs.IngressXDPBpfs, nfConfigs.IngressTCBpfs, nfConfigs.EgressTCBpfs)
+	heartbeatStart(nfConfigs.IngressXDPBpfs, nfConfigs.IngressTCBpfs, nfConfigs.EgressTCBpfs)
+	if hostConf != nil && hostConf.AttachOnLinkUp {
+		go nfConfigs.linkWatcher()
+	}
+	if hostConf != nil && hostConf.ReconcileInterval > 0 {
+		go nfConfigs.reconcileLoop(hostConf.ReconcileInterval)
+	}
+	if hostConf != nil && hostConf.ChainHitsInterval > 0 {
+		go nfConfigs.chainHitsLoop(hostConf.ChainHitsInterval)
+	}
+	if hostConf != nil && hostConf.ApplyWindowCheckInterval > 0 {
+		go nfConfigs.applyWindowLoop(hostConf.ApplyWindowCheckInterval)
+	}
+	if hostConf != nil && hostConf.BpfChainingEnabled {
+		go nfConfigs.shadowLoop()
+	}
+	if hostConf != nil {
+		go nfConfigs.queueLoop()
+		go nfConfigs.taskLoop()
+	}
 	return nfConfigs, nil
 }
 
@@ -78,6 +153,8 @@ func (c *NFConfigs) Close(ctx context.Context) error {
 	doneCh := make(chan struct{})
 	var wg sync.WaitGroup
 
+	packetTaps.stopAll()
+
 	// wait for waitGroup to shut down
 	go func() {
 		wg.Wait()
@@ -143,11 +220,19 @@ func (c *NFConfigs) VerifyAndStartXDPRootProgram(ifaceName, direction string) er
 		if err := DisableLRO(ifaceName); err != nil {
 			return fmt.Errorf("failed to disable lro %w", err)
 		}
+		if queues, ok := c.hostConfig.IfaceQueues[ifaceName]; ok {
+			if err := ApplyIfaceQueues(ifaceName, queues); err != nil {
+				return fmt.Errorf("failed to apply iface queues %w", err)
+			}
+		}
+		// channels and RSS of the interface are restored when the root program is not attached
 		if err := VerifyNMountBPFFS(); err != nil {
+			RestoreIfaceQueues(ifaceName)
 			return fmt.Errorf("failed to mount bpf file system")
 		}
 		rootBpf, err := LoadRootProgram(ifaceName, direction, models.XDPType, c.hostConfig)
 		if err != nil {
+			RestoreIfaceQueues(ifaceName)
 			return fmt.Errorf("failed to load %s xdp root program: %w", direction, err)
 		}
 		log.Info().Msg("ingress xdp root program attached")
@@ -193,6 +278,7 @@ func (c *NFConfigs) PushBackAndStartBPF(bpfProg *models.BPFProgram, ifaceName, d
 
 	log.Info().Msgf("PushBackAndStartBPF : iface %s, direction %s", ifaceName, direction)
 	bpf := NewBpfProgram(c.ctx, *bpfProg, c.hostConfig.BPFLogDir, c.hostConfig.DataCenter)
+	bpf.BTFPath = c.btfPath
 	var bpfList *list.List
 
 	switch direction {
@@ -221,17 +307,64 @@ func (c *NFConfigs) DownloadAndStartBPFProgram(element *list.Element, ifaceName,
 
 	bpf := element.Value.(*BPF)
 
-	if element.Prev() != nil {
-		prevBPF := element.Prev().Value.(*BPF)
+	// predecessor linked in the chain, bypassed and standby predecessors are skipped
+	if prev, _ := chainNeighbours(element); prev != nil {
+		prevBPF := prev.Value.(*BPF)
 		bpf.PrevMapName = prevBPF.Program.MapName
 		log.Info().Msgf("DownloadAndStartBPFProgram : program name %s previous prorgam map name: %s", bpf.Program.Name, bpf.PrevMapName)
 	}
 
+	if bpf.beginStart(nfCmdConfig.startDeadline) {
+		defer bpf.endStart()
+	}
+
+	kept := enableBPFProgram(bpf, ifaceName, direction)
+
+	if err := c.downloadAndStart(bpf, ifaceName, direction); err != nil {
+		return err
+	}
+
+	// successor can be linked to the predecessor while the program was bypassed
+	if _, next := chainNeighbours(element); next != nil && !bpf.standby() {
+		next.Value.(*BPF).PrevMapName = bpf.Program.MapName
+	}
+
+	if kept != nil {
+		c.restoreDisabledBPFProgram(bpf, kept, ifaceName, direction)
+	}
+
+	return nil
+}
+
+// downloadAndStart - downloads and inspects the artifact of the program and starts it
+func (c *NFConfigs) downloadAndStart(bpf *BPF, ifaceName, direction string) error {
+	bpf.enterStartPhase(StartPhaseDownload)
 	if err := bpf.VerifyAndGetArtifacts(c.hostConfig); err != nil {
 		return fmt.Errorf("failed to get artifacts %s with error: %w", bpf.Program.Artifact, err)
 	}
+	if bpf.startExpired() {
+		return bpf.abortStart(ifaceName, direction, c.hostConfig.BpfChainingEnabled)
+	}
+
+	bpf.enterStartPhase(StartPhaseInspect)
+	if err := bpf.VerifyBPFObjects(c.hostConfig.BpfChainingEnabled); err != nil {
+		return fmt.Errorf("eBPF object inspection failed with error: %w", err)
+	}
+
+	if err := bpf.verifyPinPaths(ifaceName, direction); err != nil {
+		return err
+	}
 
+	if err := c.VerifyMapMemory(bpf); err != nil {
+		return fmt.Errorf("map memory budget exceeded: %w", err)
+	}
+	stats.Set(float64(bpf.MapMemory), stats.NFMapMemory, bpf.Program.Name, direction)
+
+	bpf.traceID = c.traceID
 	if err := bpf.Start(ifaceName, direction, c.hostConfig.BpfChainingEnabled); err != nil {
+		if bpf.startExpired() {
+			return bpf.abortStart(ifaceName, direction, c.hostConfig.BpfChainingEnabled)
+		}
 		return fmt.Errorf("failed to start bpf program %s with error: %w", bpf.Program.Name, err)
 	}
 
@@ -305,23 +438,56 @@ func (c *NFConfigs) VerifyNUpdateBPFProgram(bpfProg *models.BPFProgram, ifaceNam
 			continue
 		}
 
-		if reflect.DeepEqual(data.Program, *bpfProg) {
+		// program in standby by its config stays activated by the API until the config is enabled or disabled
+		if bpfProg.AdminStatus == models.Standby && data.activated {
+			activated := *bpfProg
+			activated.AdminStatus = models.Enabled
+			bpfProg = &activated
+		} else if bpfProg.AdminStatus != models.Standby {
+			data.activated = false
+		}
+
+		if reflect.DeepEqual(*data.configProgram(), *bpfProg) {
 			// Nothing to do
+			pendingApplies.drop(ifaceName, direction, bpfProg.Name)
 			return nil
 		}
 
+		// Admin status change between enabled and standby - the running program is linked or unlinked in place
+		if data.Program.AdminStatus != bpfProg.AdminStatus && loadedStatus(data.Program.AdminStatus) && loadedStatus(bpfProg.AdminStatus) {
+			var err error
+			switch {
+			case bpfProg.AdminStatus == models.Standby:
+				err = standbyBPF(e, ifaceName, direction)
+			case data.Degraded:
+				// bypassed program is linked once it is restarted
+				data.Program.AdminStatus = bpfProg.AdminStatus
+			default:
+				err = activateBPF(e, ifaceName, direction)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to change admin_status of BPF %s iface %s direction %s to %s: %w", bpfProg.Name, ifaceName, direction, bpfProg.AdminStatus, err)
+			}
+		}
+
 		// Admin status change - disabled
 		if data.Program.AdminStatus != bpfProg.AdminStatus {
+			pendingApplies.drop(ifaceName, direction, bpfProg.Name)
 			log.Info().Msgf("verifyNUpdateBPFProgram :admin_status change detected - disabling the program %s", data.Program.Name)
+			// bypassed and standby programs are not linked into the chain
+			prev, next := chainNeighbours(e)
+			linked := !data.bypassed()
 			data.Program.AdminStatus = bpfProg.AdminStatus
+			// config, artifact and preserved maps are kept, so enabling the program again is instant
+			c.disableBPFProgram(data, bpfProg, ifaceName, direction)
 			if err := data.Stop(ifaceName, direction, c.hostConfig.BpfChainingEnabled); err != nil {
 				return fmt.Errorf("failed to stop to on admin_status change BPF %s iface %s direction %s admin_status %s", bpfProg.Name, ifaceName, direction, bpfProg.AdminStatus)
 			}
 			tmpNextBPF := e.Next()
 			tmpPreviousBPF := e.Prev()
 			bpfList.Remove(e)
-			if tmpNextBPF != nil && tmpNextBPF.Prev() != nil { // relink the next element
-				if err := c.LinkBPFPrograms(tmpNextBPF.Prev().Value.(*BPF), tmpNextBPF.Value.(*BPF)); err != nil {
+			if linked && next != nil && prev != nil { // relink the next element
+				if err := c.LinkBPFPrograms(prev.Value.(*BPF), next.Value.(*BPF)); err != nil {
 					log.Error().Err(err).Msg("admin status disabled - failed LinkBPFPrograms")
 					return fmt.Errorf("admin status disabled - failed LinkBPFPrograms %w", err)
 				}
@@ -354,28 +520,50 @@ func (c *NFConfigs) VerifyNUpdateBPFProgram(bpfProg *models.BPFProgram, ifaceNam
 			return nil
 		}
 
+		// Version and seq id changes wait for the apply window
+		deferred := deferDisruptiveUpdate(&data.Program, bpfProg, ifaceName, direction)
+
 		// Version Change
-		if data.Program.Version != bpfProg.Version || !reflect.DeepEqual(data.Program.StartArgs, bpfProg.StartArgs) {
+		if !deferred && (data.Program.Version != bpfProg.Version || !reflect.DeepEqual(data.Program.StartArgs, bpfProg.StartArgs)) {
 			log.Info().Msgf("VerifyNUpdateBPFProgram : version update initiated - current version %s new version %s", data.Program.Version, bpfProg.Version)
 
+			// new version is swapped into the chain in place of the running version, without an outage window
+			if bpfProg.BlueGreen != nil && c.hostConfig.BpfChainingEnabled && !data.bypassed() {
+				return c.blueGreenUpgrade(e, bpfProg, ifaceName, direction)
+			}
+
 			if err := data.Stop(ifaceName, direction, c.hostConfig.BpfChainingEnabled); err != nil {
 				return fmt.Errorf("failed to stop older version of network function BPF %s iface %s direction %s version %s", bpfProg.Name, ifaceName, direction, bpfProg.Version)
 			}
 
 			data.Program = *bpfProg
+			data.slot = ""
 
 			if err := c.DownloadAndStartBPFProgram(e, ifaceName, direction); err != nil {
 				return fmt.Errorf("failed to download and start newer version of network function BPF %s version %s iface %s direction %s", bpfProg.Name, bpfProg.Version, ifaceName, direction)
 			}
 
-			// update if not a last program
-			if e.Next() != nil {
-				data.PutNextProgFDFromID(e.Next().Value.(*BPF).ProgID)
+			// update if not a last program, program in standby is linked on activation
+			if _, next := chainNeighbours(e); next != nil && !data.standby() {
+				data.PutNextProgFDFromID(next.Value.(*BPF).ProgID)
 			}
 
 			return nil
 		}
 
+		// scheduling reset to the defaults is applied by the start of a new version only
+		if schedulingReset(&data.Program, bpfProg) && data.Program.Version == bpfProg.Version {
+			return fmt.Errorf("scheduling of BPF %s iface %s direction %s is reset to the defaults by a version update only", bpfProg.Name, ifaceName, direction)
+		}
+
+		// config updated without a restart is sent to the program over its control socket
+		current := data.Program
+		defer func() {
+			if !reflect.DeepEqual(current, data.Program) {
+				data.updateControl()
+			}
+		}()
+
 		// monitor maps change
 		if !reflect.DeepEqual(data.Program.MonitorMaps, bpfProg.MonitorMaps) {
 			log.Info().Msgf("monitor map list is mismatch - updated")
@@ -386,7 +574,7 @@ func (c *NFConfigs) VerifyNUpdateBPFProgram(bpfProg *models.BPFProgram, ifaceNam
 		data.Program.CfgVersion = bpfProg.CfgVersion
 
 		// Seq ID Change
-		if data.Program.SeqID != bpfProg.SeqID {
+		if !deferred && data.Program.SeqID != bpfProg.SeqID {
 			log.Info().Msgf("VerifyNUpdateBPFProgram : seq id change detected %s current seq id %d new seq id %d", data.Program.Name, data.Program.SeqID, bpfProg.SeqID)
 
 			// Update seq id
@@ -404,6 +592,57 @@ func (c *NFConfigs) VerifyNUpdateBPFProgram(bpfProg *models.BPFProgram, ifaceNam
 			data.Update(ifaceName, direction)
 		}
 
+		// rules validator change - validates the rules from now on
+		data.Program.RulesValidator = bpfProg.RulesValidator
+		data.Program.RulesSchema = bpfProg.RulesSchema
+
+		// scheduled tasks change - the changed tasks are rescheduled by the task loop
+		data.Program.Tasks = bpfProg.Tasks
+
+		// watchdog change - new thresholds apply from the next check, a threshold exceeded again is acted on
+		if !reflect.DeepEqual(data.Program.Watchdog, bpfProg.Watchdog) {
+			log.Info().Msgf("watchdog of program %s is updated", data.Program.Name)
+			data.Program.Watchdog = bpfProg.Watchdog
+			data.watchdogTrip = ""
+		}
+
+		// scheduling change - applied to the threads of the running user program
+		if data.Program.Nice != bpfProg.Nice || data.Program.CPUAffinity != bpfProg.CPUAffinity ||
+			data.Program.IONiceClass != bpfProg.IONiceClass || data.Program.IONiceLevel != bpfProg.IONiceLevel {
+			log.Info().Msgf("scheduling of program %s is updated", data.Program.Name)
+			data.Program.Nice = bpfProg.Nice
+			data.Program.CPUAffinity = bpfProg.CPUAffinity
+			data.Program.IONiceClass = bpfProg.IONiceClass
+			data.Program.IONiceLevel = bpfProg.IONiceLevel
+			if data.Cmd != nil {
+				if err := data.SetScheduling(ifaceName); err != nil {
+					log.Warn().Err(err).Msg("failed to set scheduling")
+				}
+			}
+		}
+
+		// heartbeat change - the map is reopened and the heartbeat written at the next tick
+		if !reflect.DeepEqual(data.Program.Heartbeat, bpfProg.Heartbeat) {
+			log.Info().Msgf("heartbeat of program %s is updated", data.Program.Name)
+			data.Program.Heartbeat = bpfProg.Heartbeat
+			data.closeHeartbeat()
+			data.heartbeatFailed = false
+		}
+
+		// on failure policy change - applies from the next failure of the user process
+		data.Program.OnFailure = bpfProg.OnFailure
+
+		// reset counters change - zeroed by the next stats reset
+		data.Program.ResetCounters = bpfProg.ResetCounters
+
+		// rules change - entries are applied as a delta to the rules map and file without a restart
+		if data.Program.Rules != bpfProg.Rules {
+			patch := diffRuleEntries(data.Program.Rules, bpfProg.Rules)
+			if err := data.applyRulesDelta(direction, bpfProg.Rules, patch.Add, patch.Remove); err != nil {
+				return fmt.Errorf("failed to update rules of BPF %s iface %s direction %s: %w", bpfProg.Name, ifaceName, direction, err)
+			}
+		}
+
 		return nil
 	}
 
@@ -428,64 +667,61 @@ func (c *NFConfigs) MoveToLocation(element *list.Element, bpfList *list.List) er
 		return nil
 	}
 
-	for e := bpfList.Front(); e != nil; e = e.Next() {
-		data := e.Value.(*BPF)
-
-		if data.Program.SeqID >= bpf.Program.SeqID && data.Program.Name != bpf.Program.Name {
-			if element.Next() != nil && element.Prev() != nil {
-				if err := c.LinkBPFPrograms(element.Prev().Value.(*BPF), element.Next().Value.(*BPF)); err != nil {
-					log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms before move")
-					return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms before move %w", err)
-				}
-			} else if element.Next() == nil && element.Prev() != nil {
-				if err := element.Prev().Value.(*BPF).RemoveNextProgFD(); err != nil {
-					log.Error().Err(err).Msg("failed to remove program fd in map")
-					return fmt.Errorf("failed to remove program fd in map %w", err)
-				}
+	// bypassed and standby programs are not linked into the chain, they are moved in the list only
+	linked := !bpf.bypassed()
+	if linked {
+		prev, next := chainNeighbours(element)
+		if next != nil && prev != nil {
+			if err := c.LinkBPFPrograms(prev.Value.(*BPF), next.Value.(*BPF)); err != nil {
+				log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms before move")
+				return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms before move %w", err)
 			}
-
-			bpfList.MoveBefore(element, e)
-
-			if err := c.LinkBPFPrograms(element.Prev().Value.(*BPF), element.Value.(*BPF)); err != nil {
-				log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms after move element to with prev prog")
-				return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms after move element to with prev prog %w", err)
-			}
-
-			if element.Next() != nil {
-				if err := c.LinkBPFPrograms(element.Value.(*BPF), element.Next().Value.(*BPF)); err != nil {
-					log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms after move element to with next prog")
-					return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms after move element to with next prog %w", err)
-				}
+		} else if next == nil && prev != nil {
+			if err := prev.Value.(*BPF).RemoveNextProgFD(); err != nil {
+				log.Error().Err(err).Msg("failed to remove program fd in map")
+				return fmt.Errorf("failed to remove program fd in map %w", err)
 			}
-			log.Info().Msgf("MoveToLocation : Moved - %s", element.Value.(*BPF).Program.Name)
-			return nil
 		}
 	}
 
-	log.Info().Msg("element seq id greater than last element in the list move to back of the list")
-	if element.Next() != nil && element.Prev() != nil {
-		if err := c.LinkBPFPrograms(element.Prev().Value.(*BPF), element.Next().Value.(*BPF)); err != nil {
-			log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms before MoveToBack element to with prev prog")
-			return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms before MoveToBack element to with prev prog %w", err)
+	var mark *list.Element
+	for e := bpfList.Front(); e != nil; e = e.Next() {
+		data := e.Value.(*BPF)
+		if data.Program.SeqID >= bpf.Program.SeqID && data.Program.Name != bpf.Program.Name {
+			mark = e
+			break
 		}
 	}
+	if mark != nil {
+		bpfList.MoveBefore(element, mark)
+	} else {
+		log.Info().Msg("element seq id greater than last element in the list move to back of the list")
+		bpfList.MoveToBack(element)
+	}
+	if !linked {
+		log.Info().Msgf("MoveToLocation : Moved unlinked - %s", bpf.Program.Name)
+		return nil
+	}
 
-	bpfList.MoveToBack(element)
-	if element.Prev() != nil {
-		if err := c.LinkBPFPrograms(element.Prev().Value.(*BPF), element.Value.(*BPF)); err != nil {
-			log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms after MoveToBack element to with prev prog")
-			return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms after MoveToBack element to with prev prog %w", err)
+	prev, next := chainNeighbours(element)
+	if next != nil {
+		if err := c.LinkBPFPrograms(bpf, next.Value.(*BPF)); err != nil {
+			log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms after move element to with next prog")
+			return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms after move element to with next prog %w", err)
 		}
+	} else if err := bpf.RemoveNextProgFD(); err != nil {
+		log.Error().Err(err).Msg("failed to remove MoveToBack program fd in map")
+		return fmt.Errorf("failed to remove MoveToBack program fd in map %w", err)
 	}
 
-	if element.Next() == nil {
-		if err := element.Value.(*BPF).RemoveNextProgFD(); err != nil {
-			log.Error().Err(err).Msg("failed to remove MoveToBack program fd in map")
-			return fmt.Errorf("failed to remove MoveToBack program fd in map %w", err)
+	if prev != nil {
+		if err := c.LinkBPFPrograms(prev.Value.(*BPF), bpf); err != nil {
+			log.Error().Err(err).Msg("MoveToLocation - failed LinkBPFPrograms after move element to with prev prog")
+			return fmt.Errorf("MoveToLocation - failed LinkBPFPrograms after move element to with prev prog %w", err)
 		}
 	}
 
-	log.Info().Msgf("MoveToLocation : MoveToBack Moved - %s", element.Value.(*BPF).Program.Name)
+	log.Info().Msgf("MoveToLocation : Moved - %s", bpf.Program.Name)
 	return nil
 }
 
@@ -502,6 +738,7 @@ func (c *NFConfigs) InsertAndStartBPFProgram(bpfProg *models.BPFProgram, ifaceNa
 	}
 
 	bpf := NewBpfProgram(c.ctx, *bpfProg, c.hostConfig.BPFLogDir, c.hostConfig.DataCenter)
+	bpf.BTFPath = c.btfPath
 
 	switch direction {
 	case models.XDPIngressType:
@@ -527,8 +764,9 @@ func (c *NFConfigs) InsertAndStartBPFProgram(bpfProg *models.BPFProgram, ifaceNa
 				return fmt.Errorf("failed to download and start network function %s version %s iface %s direction %s", bpfProg.Name, bpfProg.Version, ifaceName, direction)
 			}
 
-			if tmpBPF.Next() != nil {
-				if err := c.LinkBPFPrograms(tmpBPF.Value.(*BPF), tmpBPF.Next().Value.(*BPF)); err != nil {
+			// program in standby is linked on activation
+			if _, next := chainNeighbours(tmpBPF); next != nil && !bpf.standby() {
+				if err := c.LinkBPFPrograms(tmpBPF.Value.(*BPF), next.Value.(*BPF)); err != nil {
 					log.Error().Err(err).Msg("InsertAndStartBPFProgram - failed LinkBPFPrograms after InsertBefore element to with next prog")
 					return fmt.Errorf("InsertAndStartBPFProgram - failed LinkBPFPrograms after InsertBefore element to with next prog %w", err)
 				}
@@ -560,6 +798,7 @@ func (c *NFConfigs) StopRootProgram(ifaceName, direction string) error {
 		}
 		c.IngressXDPBpfs[ifaceName].Remove(c.IngressXDPBpfs[ifaceName].Front())
 		c.IngressXDPBpfs[ifaceName] = nil
+		RestoreIfaceQueues(ifaceName)
 	case models.IngressType:
 		if c.IngressTCBpfs[ifaceName] == nil {
 			log.Warn().Msgf("tc root program %s not running", direction)
@@ -642,12 +881,46 @@ func (c *NFConfigs) Deploy(ifaceName, hostName string, bpfProgs *models.BPFProgr
 		return errOut
 	}
 
+	orderedProgs, err := orderBPFPrograms(ifaceName, bpfProgs)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for _, bpfProg := range bpfProgs.XDPIngress {
+	// programs of an apply group are applied in all their directions or in none
+	groups := c.snapshotApplyGroups(ifaceName, orderedProgs)
+	for _, ref := range orderedProgs {
+		if err := c.deployBPFProgram(ref.prog, ifaceName, ref.direction); err != nil {
+			if rbErr := c.rollbackApplyGroup(ifaceName, groups, ref); rbErr != nil {
+				log.Error().Err(rbErr).Msgf("apply group of program %s is not rolled back", ref.prog.Name)
+			}
+			return err
+		}
+		if m := groups.member(ref); m != nil {
+			m.applied = true
+		}
+	}
+
+	return nil
+}
+
+// deployBPFProgram - starts the root program and the bpf program if the chain is empty,
+// otherwise verifies and updates the bpf program.
+func (c *NFConfigs) deployBPFProgram(bpfProg *models.BPFProgram, ifaceName, direction string) error {
+	if bpfProg.AdminStatus == models.Disabled {
+		c.keepDisabledBPFProgram(bpfProg, ifaceName, direction)
+	}
+	if deployPausedBPFProgram(bpfProg, ifaceName, direction) {
+		return nil
+	}
+
+	switch direction {
+	case models.XDPIngressType:
 		if c.IngressXDPBpfs[ifaceName] == nil {
-			if bpfProg.AdminStatus == models.Enabled {
+			if loadedStatus(bpfProg.AdminStatus) {
 				c.IngressXDPBpfs[ifaceName] = list.New()
 				if err := c.VerifyAndStartXDPRootProgram(ifaceName, models.XDPIngressType); err != nil {
 					c.IngressXDPBpfs[ifaceName] = nil
@@ -661,11 +934,9 @@ func (c *NFConfigs) Deploy(ifaceName, hostName string, bpfProgs *models.BPFProgr
 		} else if err := c.VerifyNUpdateBPFProgram(bpfProg, ifaceName, models.XDPIngressType); err != nil {
 			return fmt.Errorf("failed to update xdp BPF Program: %w", err)
 		}
-	}
-
-	for _, bpfProg := range bpfProgs.TCIngress {
+	case models.IngressType:
 		if c.IngressTCBpfs[ifaceName] == nil {
-			if bpfProg.AdminStatus == models.Enabled {
+			if loadedStatus(bpfProg.AdminStatus) {
 				c.IngressTCBpfs[ifaceName] = list.New()
 				if err := c.VerifyAndStartTCRootProgram(ifaceName, models.IngressType); err != nil {
 					c.IngressTCBpfs[ifaceName] = nil
@@ -678,15 +949,13 @@ func (c *NFConfigs) Deploy(ifaceName, hostName string, bpfProgs *models.BPFProgr
 		} else if err := c.VerifyNUpdateBPFProgram(bpfProg, ifaceName, models.IngressType); err != nil {
 			return fmt.Errorf("failed to update BPF Program: %w", err)
 		}
-	}
-
-	for _, bpfProg := range bpfProgs.TCEgress {
+	case models.EgressType:
 		if c.EgressTCBpfs[ifaceName] == nil {
-			if bpfProg.AdminStatus == models.Enabled {
+			if loadedStatus(bpfProg.AdminStatus) {
 				c.EgressTCBpfs[ifaceName] = list.New()
 				if err := c.VerifyAndStartTCRootProgram(ifaceName, models.EgressType); err != nil {
 					c.EgressTCBpfs[ifaceName] = nil
-					return fmt.Errorf("failed to chain ingress tc bpf programs: %w", err)
+					return fmt.Errorf("failed to chain egress tc bpf programs: %w", err)
 				}
 				if err := c.PushBackAndStartBPF(bpfProg, ifaceName, models.EgressType); err != nil {
 					return fmt.Errorf("failed to update BPF Program: %w", err)
@@ -695,29 +964,193 @@ func (c *NFConfigs) Deploy(ifaceName, hostName string, bpfProgs *models.BPFProgr
 		} else if err := c.VerifyNUpdateBPFProgram(bpfProg, ifaceName, models.EgressType); err != nil {
 			return fmt.Errorf("failed to update BPF Program: %w", err)
 		}
+	default:
+		return fmt.Errorf("unknown direction type %s", direction)
 	}
 
 	return nil
 }
 
+// validateConfig - configs with the interface groups, chain templates and node selectors expanded and the seq ids
+// assigned, after the validations run before a config is deployed or prepared
+func (c *NFConfigs) validateConfig(bpfProgs []models.L3afBPFPrograms) ([]models.L3afBPFPrograms, error) {
+	var err error
+	if bpfProgs, err = c.ExpandIfaceGroups(bpfProgs); err != nil {
+		return nil, fmt.Errorf("interface group expansion failed: %w", err)
+	}
+
+	if bpfProgs, err = c.ExpandChainTemplates(bpfProgs); err != nil {
+		return nil, fmt.Errorf("chain template expansion failed: %w", err)
+	}
+
+	if bpfProgs, err = c.SelectNodePrograms(bpfProgs); err != nil {
+		return nil, fmt.Errorf("node selector validation failed: %w", err)
+	}
+
+	if err := c.ValidateTenants(bpfProgs); err != nil {
+		return nil, fmt.Errorf("tenant validation failed: %w", err)
+	}
+
+	if err := ValidateDependencies(bpfProgs); err != nil {
+		return nil, fmt.Errorf("dependency validation failed: %w", err)
+	}
+
+	if err := c.ValidateProgramArgs(bpfProgs); err != nil {
+		return nil, fmt.Errorf("program argument validation failed: %w", err)
+	}
+
+	if err := AssignSeqIDs(bpfProgs); err != nil {
+		return nil, fmt.Errorf("seq id assignment failed: %w", err)
+	}
+
+	if err := c.ValidateChainLimits(bpfProgs); err != nil {
+		return nil, fmt.Errorf("chain limit validation failed: %w", err)
+	}
+
+	if err := c.ValidatePinPaths(bpfProgs); err != nil {
+		return nil, fmt.Errorf("pin path validation failed: %w", err)
+	}
+
+	if err := ValidateMonitorMaps(bpfProgs); err != nil {
+		return nil, fmt.Errorf("monitor map validation failed: %w", err)
+	}
+
+	if err := c.ValidateMonitorMapSeries(bpfProgs); err != nil {
+		return nil, fmt.Errorf("monitor map series validation failed: %w", err)
+	}
+
+	if err := ValidateApplyWindows(bpfProgs); err != nil {
+		return nil, fmt.Errorf("apply window validation failed: %w", err)
+	}
+
+	if err := ValidateScheduling(bpfProgs); err != nil {
+		return nil, fmt.Errorf("scheduling validation failed: %w", err)
+	}
+
+	if err := ValidateWatchdogs(bpfProgs); err != nil {
+		return nil, fmt.Errorf("watchdog validation failed: %w", err)
+	}
+	if err := ValidateHeartbeats(bpfProgs); err != nil {
+		return nil, fmt.Errorf("heartbeat validation failed: %w", err)
+	}
+	if err := ValidateOnFailure(bpfProgs); err != nil {
+		return nil, fmt.Errorf("on failure policy validation failed: %w", err)
+	}
+
+	if err := ValidateResetCounters(bpfProgs); err != nil {
+		return nil, fmt.Errorf("reset counters validation failed: %w", err)
+	}
+
+	if err := c.ValidateStandby(bpfProgs); err != nil {
+		return nil, fmt.Errorf("standby validation failed: %w", err)
+	}
+
+	if err := c.ValidateBlueGreen(bpfProgs); err != nil {
+		return nil, fmt.Errorf("blue/green validation failed: %w", err)
+	}
+
+	if err := c.ValidateShadows(bpfProgs); err != nil {
+		return nil, fmt.Errorf("shadow validation failed: %w", err)
+	}
+
+	if err := c.ValidateRootCapabilities(bpfProgs); err != nil {
+		return nil, fmt.Errorf("root capabilities validation failed: %w", err)
+	}
+	if err := c.ValidateXDPModes(bpfProgs); err != nil {
+		return nil, fmt.Errorf("xdp modes validation failed: %w", err)
+	}
+	if err := c.ValidateQueues(bpfProgs); err != nil {
+		return nil, fmt.Errorf("queues validation failed: %w", err)
+	}
+	if err := ValidateTasks(bpfProgs); err != nil {
+		return nil, fmt.Errorf("scheduled tasks validation failed: %w", err)
+	}
+
+	if err := ValidateArtifacts(bpfProgs); err != nil {
+		return nil, fmt.Errorf("artifact validation failed: %w", err)
+	}
+
+	if err := ValidateRules(bpfProgs); err != nil {
+		return nil, fmt.Errorf("rules validation failed: %w", err)
+	}
+
+	// artifacts are inspected once the config is otherwise valid
+	if err := c.ValidateTenantMapMemory(bpfProgs); err != nil {
+		return nil, fmt.Errorf("tenant map memory validation failed: %w", err)
+	}
+
+	return bpfProgs, nil
+}
+
 // DeployeBPFPrograms - Starts eBPF programs on the node if they are not running
 func (c *NFConfigs) DeployeBPFPrograms(bpfProgs []models.L3afBPFPrograms) error {
+	return c.DeployeBPFProgramsInTrace(NewTraceID(), bpfProgs)
+}
+
+// DeployeBPFProgramsInTrace - Applies the config in the trace of the caller, the trace id is the exemplar of
+// the apply duration and of the start count of the programs started by the apply
+func (c *NFConfigs) DeployeBPFProgramsInTrace(traceID string, bpfProgs []models.L3afBPFPrograms) (err error) {
+	log.Info().Msgf("applying config in trace %s", traceID)
+	c.mu.Lock()
+	c.traceID = traceID
+	c.mu.Unlock()
+	defer func(started time.Time) {
+		c.mu.Lock()
+		c.traceID = ""
+		c.mu.Unlock()
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		stats.ObserveWithExemplar(time.Since(started), stats.ConfigApplyDuration, traceID, result)
+	}(time.Now())
+
+	if bpfProgs, err = c.validateConfig(bpfProgs); err != nil {
+		return err
+	}
+
+	pendingLinks.reset()
+	pendingApplies.reset()
 	for _, bpfProg := range bpfProgs {
+		if c.queueLinkDown(bpfProg) {
+			continue
+		}
+		// programs of the interface the failed self test rolls back to
+		var prev models.L3afBPFPrograms
+		if c.hostConfig.ChainSelfTestEnabled {
+			prev = c.snapshotIfacePrograms(bpfProg.Iface)
+		}
 		if err := c.Deploy(bpfProg.Iface, bpfProg.HostName, bpfProg.BpfPrograms); err != nil {
+			notifyEvent(EventApplyFailed, bpfProg.Iface, "", "", err.Error())
 			if err := c.SaveConfigsToConfigStore(); err != nil {
 				return fmt.Errorf("deploy eBPF Programs failed to save configs %w", err)
 			}
 			return fmt.Errorf("failed to deploy BPF program on iface %s with error: %w", bpfProg.Iface, err)
 		}
+
+		if c.hostConfig.ChainSelfTestEnabled {
+			if err := c.ChainSelfTest(bpfProg.Iface); err != nil {
+				notifyEvent(EventApplyFailed, bpfProg.Iface, "", "", err.Error())
+				if rbErr := c.rollbackIfacePrograms(prev); rbErr != nil {
+					log.Error().Err(rbErr).Msgf("programs of iface %s are not rolled back after the failed chain self test", bpfProg.Iface)
+				}
+				if err := c.SaveConfigsToConfigStore(); err != nil {
+					return fmt.Errorf("deploy eBPF Programs failed to save configs %w", err)
+				}
+				return fmt.Errorf("chain self test failed: %w", err)
+			}
+		}
 		c.ifaces = map[string]string{bpfProg.Iface: bpfProg.Iface}
 	}
 
 	if err := c.RemoveMissingNetIfacesNBPFProgsInConfig(bpfProgs); err != nil {
 		log.Warn().Err(err).Msgf("Remove missing interfaces and BPF programs in the config failed with error ")
 	}
+	c.reconcileMapEntries()
 	if err := c.SaveConfigsToConfigStore(); err != nil {
 		return fmt.Errorf("deploy eBPF Programs failed to save configs %w", err)
 	}
+	c.recordHistory(bpfProgs)
 	return nil
 }
 
@@ -727,18 +1160,26 @@ func (c *NFConfigs) SaveConfigsToConfigStore() error {
 	var bpfProgs []models.L3afBPFPrograms
 
 	for _, iface := range c.ifaces {
+		if pendingLinks.queued(iface) {
+			continue
+		}
 		log.Info().Msgf("SaveConfigsToConfigStore - %s", iface)
 		bpfPrograms := c.EBPFPrograms(iface)
 		bpfProgs = append(bpfProgs, bpfPrograms)
 	}
 
+	// queued configs are applied again after restart
+	for _, p := range pendingLinks.list() {
+		bpfProgs = append(bpfProgs, p.config)
+	}
+
 	file, err := json.MarshalIndent(bpfProgs, "", " ")
 	if err != nil {
 		log.Error().Err(err).Msgf("failed to marshal configs to save")
 		return fmt.Errorf("failed to marshal configs %w", err)
 	}
 
-	if err = ioutil.WriteFile(c.hostConfig.L3afConfigStoreFileName, file, 0644); err != nil {
+	if err = writeStateFile(c.hostConfig.L3afConfigStoreFileName, file, 0600); err != nil {
 		log.Error().Err(err).Msgf("failed write to file operation")
 		return fmt.Errorf("failed to save configs %w", err)
 	}
@@ -761,7 +1202,7 @@ func (c *NFConfigs) EBPFPrograms(iface string) models.L3afBPFPrograms {
 			e = e.Next()
 		}
 		for ; e != nil; e = e.Next() {
-			BPFProgram.BpfPrograms.XDPIngress = append(BPFProgram.BpfPrograms.XDPIngress, &e.Value.(*BPF).Program)
+			BPFProgram.BpfPrograms.XDPIngress = append(BPFProgram.BpfPrograms.XDPIngress, e.Value.(*BPF).configProgram())
 		}
 	}
 	bpfList = c.IngressTCBpfs[iface]
@@ -771,7 +1212,7 @@ func (c *NFConfigs) EBPFPrograms(iface string) models.L3afBPFPrograms {
 			e = e.Next()
 		}
 		for ; e != nil; e = e.Next() {
-			BPFProgram.BpfPrograms.TCIngress = append(BPFProgram.BpfPrograms.TCIngress, &e.Value.(*BPF).Program)
+			BPFProgram.BpfPrograms.TCIngress = append(BPFProgram.BpfPrograms.TCIngress, e.Value.(*BPF).configProgram())
 		}
 	}
 	bpfList = c.EgressTCBpfs[iface]
@@ -781,10 +1222,18 @@ func (c *NFConfigs) EBPFPrograms(iface string) models.L3afBPFPrograms {
 			e = e.Next()
 		}
 		for ; e != nil; e = e.Next() {
-			BPFProgram.BpfPrograms.TCEgress = append(BPFProgram.BpfPrograms.TCEgress, &e.Value.(*BPF).Program)
+			BPFProgram.BpfPrograms.TCEgress = append(BPFProgram.BpfPrograms.TCEgress, e.Value.(*BPF).configProgram())
 		}
 	}
 
+	BPFProgram.BpfPrograms.XDPIngress = append(BPFProgram.BpfPrograms.XDPIngress, pausedBPFPrograms(iface, models.XDPIngressType)...)
+	BPFProgram.BpfPrograms.TCIngress = append(BPFProgram.BpfPrograms.TCIngress, pausedBPFPrograms(iface, models.IngressType)...)
+	BPFProgram.BpfPrograms.TCEgress = append(BPFProgram.BpfPrograms.TCEgress, pausedBPFPrograms(iface, models.EgressType)...)
+
+	BPFProgram.BpfPrograms.XDPIngress = append(BPFProgram.BpfPrograms.XDPIngress, disabledBPFPrograms(iface, models.XDPIngressType)...)
+	BPFProgram.BpfPrograms.TCIngress = append(BPFProgram.BpfPrograms.TCIngress, disabledBPFPrograms(iface, models.IngressType)...)
+	BPFProgram.BpfPrograms.TCEgress = append(BPFProgram.BpfPrograms.TCEgress, disabledBPFPrograms(iface, models.EgressType)...)
+
 	return BPFProgram
 }
 
@@ -803,41 +1252,21 @@ func (c *NFConfigs) EBPFProgramsAll() []models.L3afBPFPrograms {
 // RemoveMissingNetIfacesNBPFProgsInConfig - Stops running eBPF programs which are missing in the config
 func (c *NFConfigs) RemoveMissingNetIfacesNBPFProgsInConfig(bpfProgCfgs []models.L3afBPFPrograms) error {
 
+	c.removeMissingPausedPrograms(bpfProgCfgs)
+	c.removeMissingDisabledPrograms(bpfProgCfgs)
+
 	tempIfaces := map[string]bool{}
 	wg := sync.WaitGroup{}
 	for _, bpfProg := range bpfProgCfgs {
 		tempIfaces[bpfProg.Iface] = true
 		if ifaceName, ok := c.ifaces[bpfProg.Iface]; ok {
-			_, ok := c.IngressXDPBpfs[ifaceName]
-			if ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					if err := c.RemoveMissingBPFProgramsInConfig(bpfProg, ifaceName, models.XDPIngressType); err != nil {
-						log.Error().Err(err).Msgf("Failed to stop missing program for network interface %s direction Ingress", ifaceName)
-					}
-				}()
-			}
-			_, ok = c.IngressTCBpfs[ifaceName]
-			if ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					if err := c.RemoveMissingBPFProgramsInConfig(bpfProg, ifaceName, models.IngressType); err != nil {
-						log.Error().Err(err).Msgf("Failed to stop missing program for network interface %s direction Ingress", ifaceName)
-					}
-				}()
-			}
-			_, ok = c.EgressTCBpfs[ifaceName]
-			if ok {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					if err := c.RemoveMissingBPFProgramsInConfig(bpfProg, ifaceName, models.EgressType); err != nil {
-						log.Error().Err(err).Msgf("Failed to stop missing program for network interface %s direction Ingress", ifaceName)
-					}
-				}()
-			}
+			wg.Add(1)
+			go func(bpfProg models.L3afBPFPrograms, ifaceName string) {
+				defer wg.Done()
+				if err := c.RemoveMissingBPFProgramsInConfig(bpfProg, ifaceName); err != nil {
+					log.Error().Err(err).Msgf("Failed to stop missing program for network interface %s", ifaceName)
+				}
+			}(bpfProg, ifaceName)
 		}
 	}
 	wg.Wait()
@@ -845,6 +1274,9 @@ func (c *NFConfigs) RemoveMissingNetIfacesNBPFProgsInConfig(bpfProgCfgs []models
 	for _, ifaceName := range c.ifaces {
 		if _, ok := tempIfaces[ifaceName]; !ok {
 			log.Info().Msgf("Missing Network Interface %s in the configs, stopping", ifaceName)
+			if err := c.RemoveMissingBPFProgramsInConfig(models.L3afBPFPrograms{Iface: ifaceName}, ifaceName); err != nil {
+				log.Error().Err(err).Msgf("Failed to stop the programs for interface %s", ifaceName)
+			}
 			if err := c.StopNRemoveAllBPFPrograms(ifaceName, models.XDPIngressType); err != nil {
 				log.Error().Err(err).Msgf("Failed to stop all the program in the direction xdp ingress for interface %s", ifaceName)
 			}
@@ -862,61 +1294,100 @@ func (c *NFConfigs) RemoveMissingNetIfacesNBPFProgsInConfig(bpfProgCfgs []models
 }
 
 // RemoveMissingBPFProgramsInConfig - This method to stop the eBPF programs which are not listed in the config.
-func (c *NFConfigs) RemoveMissingBPFProgramsInConfig(bpfProg models.L3afBPFPrograms, ifaceName, direction string) error {
+// The programs of all the directions are stopped in reverse dependency order, dependents before their dependencies.
+func (c *NFConfigs) RemoveMissingBPFProgramsInConfig(bpfProg models.L3afBPFPrograms, ifaceName string) error {
+	configured := make(map[string]bool)
+	for _, ref := range configProgramRefs(bpfProg.BpfPrograms) {
+		if ref.prog != nil {
+			configured[ref.direction+"/"+ref.prog.Name] = true
+		}
+	}
 
-	var bpfProgArr []*models.BPFProgram
-	var bpfList *list.List
-	switch direction {
-	case models.XDPIngressType:
-		bpfProgArr = bpfProg.BpfPrograms.XDPIngress
-		bpfList = c.IngressXDPBpfs[ifaceName]
-	case models.IngressType:
-		bpfProgArr = bpfProg.BpfPrograms.TCIngress
-		bpfList = c.IngressTCBpfs[ifaceName]
-	case models.EgressType:
-		bpfProgArr = bpfProg.BpfPrograms.TCEgress
-		bpfList = c.EgressTCBpfs[ifaceName]
-	default: // we should never reach here
-		return fmt.Errorf("unknown direction type %s", direction)
+	missing := &models.BPFPrograms{}
+	for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+		bpfs, _ := c.bpfLists(direction)
+		bpfList := bpfs[ifaceName]
+		if bpfList == nil {
+			continue
+		}
+		e := bpfList.Front()
+		if e != nil && c.hostConfig.BpfChainingEnabled {
+			e = e.Next()
+		}
+		for ; e != nil; e = e.Next() {
+			prog := e.Value.(*BPF).Program
+			if configured[direction+"/"+prog.Name] {
+				continue
+			}
+			prog.AdminStatus = models.Disabled
+			switch direction {
+			case models.XDPIngressType:
+				missing.XDPIngress = append(missing.XDPIngress, &prog)
+			case models.IngressType:
+				missing.TCIngress = append(missing.TCIngress, &prog)
+			case models.EgressType:
+				missing.TCEgress = append(missing.TCEgress, &prog)
+			}
+		}
 	}
 
+	refs, err := orderBPFPrograms(ifaceName, missing)
+	if err != nil {
+		return fmt.Errorf("failed to order the missing programs of iface %s: %w", ifaceName, err)
+	}
+	for _, ref := range refs {
+		if err := c.removeMissingBPFProgram(ref.prog.Name, ifaceName, ref.direction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeMissingBPFProgram - stops the program missing in the config and removes it from the chain, the root
+// program is stopped once no programs are left
+func (c *NFConfigs) removeMissingBPFProgram(name, ifaceName, direction string) error {
+	bpfs, err := c.bpfLists(direction)
+	if err != nil {
+		return err
+	}
+	bpfList := bpfs[ifaceName]
+	if bpfList == nil {
+		return nil
+	}
 	e := bpfList.Front()
 	if e != nil && c.hostConfig.BpfChainingEnabled {
 		e = e.Next()
 	}
 	for ; e != nil; e = e.Next() {
 		prog := e.Value.(*BPF)
-		Found := false
-		for _, bpfConfigProg := range bpfProgArr {
-			if bpfConfigProg.Name == prog.Program.Name {
-				Found = true
-				break
-			}
+		if prog.Program.Name != name {
+			continue
 		}
-		if !Found {
-			log.Info().Msgf("eBPF Program not found in config stopping - %s direction %s", prog.Program.Name, direction)
-			prog.Program.AdminStatus = models.Disabled
-			if err := prog.Stop(ifaceName, direction, c.hostConfig.BpfChainingEnabled); err != nil {
-				return fmt.Errorf("failed to stop to on removed config BPF %s iface %s direction %s", prog.Program.Name, ifaceName, models.XDPIngressType)
-			}
-			tmpNextBPF := e.Next()
-			tmpPreviousBPF := e.Prev()
-			bpfList.Remove(e)
-			if tmpNextBPF != nil && tmpNextBPF.Prev() != nil { // relink the next element
-				if err := c.LinkBPFPrograms(tmpNextBPF.Prev().Value.(*BPF), tmpNextBPF.Value.(*BPF)); err != nil {
-					log.Error().Err(err).Msgf("missing config - failed LinkBPFPrograms")
-					return fmt.Errorf("missing config - failed LinkBPFPrograms %w", err)
-				}
+		log.Info().Msgf("eBPF Program not found in config stopping - %s direction %s", prog.Program.Name, direction)
+		// bypassed and standby programs are not linked into the chain
+		prev, next := chainNeighbours(e)
+		linked := !prog.bypassed()
+		prog.Program.AdminStatus = models.Disabled
+		if err := prog.Stop(ifaceName, direction, c.hostConfig.BpfChainingEnabled); err != nil {
+			return fmt.Errorf("failed to stop to on removed config BPF %s iface %s direction %s", prog.Program.Name, ifaceName, direction)
+		}
+		tmpPreviousBPF := e.Prev()
+		bpfList.Remove(e)
+		if linked && next != nil && prev != nil { // relink the next element
+			if err := c.LinkBPFPrograms(prev.Value.(*BPF), next.Value.(*BPF)); err != nil {
+				log.Error().Err(err).Msgf("missing config - failed LinkBPFPrograms")
+				return fmt.Errorf("missing config - failed LinkBPFPrograms %w", err)
 			}
-			// Check if list contains root program only then stop the root program.
-			if tmpPreviousBPF.Prev() == nil && tmpPreviousBPF.Next() == nil {
-				log.Info().Msgf("no network functions are running, stopping root program")
+		}
+		// Check if list contains root program only then stop the root program.
+		if c.hostConfig.BpfChainingEnabled && tmpPreviousBPF != nil && tmpPreviousBPF.Prev() == nil && tmpPreviousBPF.Next() == nil {
+			log.Info().Msgf("no network functions are running, stopping root program")
 
-				if err := c.StopRootProgram(ifaceName, direction); err != nil {
-					return fmt.Errorf("failed to stop to root program of iface %s direction XDP Ingress", ifaceName)
-				}
+			if err := c.StopRootProgram(ifaceName, direction); err != nil {
+				return fmt.Errorf("failed to stop to root program of iface %s direction %s", ifaceName, direction)
 			}
 		}
+		return nil
 	}
 	return nil
 }
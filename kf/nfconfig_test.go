@@ -7,7 +7,9 @@ import (
 	"container/list"
 	"context"
 	"os"
+	"os/exec"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -23,6 +25,7 @@ var (
 	hostInterfaces  map[string]bool
 	pMon            *pCheck
 	mMon            *kfMetrics
+	uMon            *pUsage
 	//	val             []byte
 	valVerChange    *models.BPFPrograms
 	valStatusChange *models.BPFPrograms
@@ -39,7 +42,8 @@ func setupDBTest() {
 	hostInterfaces = make(map[string]bool)
 	hostInterfaces["enp0s3"] = true
 	pMon = NewpCheck(3, true, 10)
-	mMon = NewpKFMetrics(true, 30)
+	mMon = NewpKFMetrics(true, 30, 0, 0)
+	uMon = NewpUsage(true, 0)
 
 	ingressXDPBpfs = make(map[string]*list.List)
 	ingressTCBpfs = make(map[string]*list.List)
@@ -134,6 +138,7 @@ func TestNewNFConfigs(t *testing.T) {
 		hostConf *config.Config
 		pMon     *pCheck
 		mMon     *kfMetrics
+		uMon     *pUsage
 		ctx      context.Context
 	}
 	setupDBTest()
@@ -149,7 +154,8 @@ func TestNewNFConfigs(t *testing.T) {
 				host:     machineHostname,
 				hostConf: nil,
 				pMon:     pMon,
-				mMon:     mMon},
+				mMon:     mMon,
+				uMon:     uMon},
 			want: &NFConfigs{hostName: machineHostname,
 				hostInterfaces: hostIfaces,
 				IngressXDPBpfs: ingressXDPBpfs,
@@ -158,6 +164,7 @@ func TestNewNFConfigs(t *testing.T) {
 				hostConfig:     nil,
 				processMon:     pMon,
 				kfMetricsMon:   mMon,
+				usageMon:       uMon,
 				mu:             new(sync.Mutex),
 			},
 			wantErr: false,
@@ -165,7 +172,7 @@ func TestNewNFConfigs(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := NewNFConfigs(tt.args.ctx, tt.args.host, tt.args.hostConf, tt.args.pMon, tt.args.mMon)
+			got, err := NewNFConfigs(tt.args.ctx, tt.args.host, tt.args.hostConf, tt.args.pMon, tt.args.mMon, tt.args.uMon)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewNFConfigs() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -402,3 +409,127 @@ func TestNFConfigs_Close(t *testing.T) {
 		})
 	}
 }
+
+func TestNFConfigs_RemoveMissingBPFProgramsInConfig(t *testing.T) {
+	var stopped []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--name=") {
+				stopped = append(stopped, strings.TrimPrefix(arg, "--name="))
+			}
+		}
+		return exec.Command(GetTestExecutablePathName())
+	}
+	defer func() { execCommand = exec.Command }()
+
+	program := func(name string, deps ...string) *BPF {
+		return &BPF{Program: models.BPFProgram{Name: name, CmdStop: GetTestExecutableName(), AdminStatus: models.Enabled,
+			Dependencies: deps, StopArgs: map[string]interface{}{"name": name}}, FilePath: GetTestExecutablePath()}
+	}
+	xdp, ingress, egress := list.New(), list.New(), list.New()
+	xdp.PushBack(program("ratelimiting"))
+	ingress.PushBack(program("connection-tracker"))
+	egress.PushBack(program("flow-exporter", "connection-tracker"))
+	cfg := &NFConfigs{
+		hostConfig:     &config.Config{},
+		IngressXDPBpfs: map[string]*list.List{"dummy": xdp},
+		IngressTCBpfs:  map[string]*list.List{"dummy": ingress},
+		EgressTCBpfs:   map[string]*list.List{"dummy": egress},
+	}
+	bpfProg := models.L3afBPFPrograms{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+		XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", AdminStatus: models.Enabled}},
+	}}
+
+	if err := cfg.RemoveMissingBPFProgramsInConfig(bpfProg, "dummy"); err != nil {
+		t.Fatalf("NFConfigs.RemoveMissingBPFProgramsInConfig() error = %v", err)
+	}
+	// dependent is stopped before its dependency of the other direction
+	if want := []string{"flow-exporter", "connection-tracker"}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("NFConfigs.RemoveMissingBPFProgramsInConfig() stopped %v, want %v", stopped, want)
+	}
+	if xdp.Len() != 1 || ingress.Len() != 0 || egress.Len() != 0 {
+		t.Errorf("NFConfigs.RemoveMissingBPFProgramsInConfig() left %d, %d and %d programs, want the configured program only",
+			xdp.Len(), ingress.Len(), egress.Len())
+	}
+}
+
+// updateRunningProgram - applies the update to the running program of the config, the update is applied without a
+// restart and the running program matches the update
+func updateRunningProgram(t *testing.T, running *BPF, update models.BPFProgram) {
+	t.Helper()
+	bpfList := list.New()
+	bpfList.PushBack(running)
+	cfg := &NFConfigs{
+		hostConfig:     &config.Config{},
+		IngressXDPBpfs: map[string]*list.List{"dummy": bpfList},
+		IngressTCBpfs:  map[string]*list.List{},
+		EgressTCBpfs:   map[string]*list.List{},
+	}
+	if err := cfg.VerifyNUpdateBPFProgram(&update, "dummy", models.XDPIngressType); err != nil {
+		t.Fatalf("NFConfigs.VerifyNUpdateBPFProgram() error = %v", err)
+	}
+	if !reflect.DeepEqual(*running.configProgram(), update) {
+		t.Errorf("NFConfigs.VerifyNUpdateBPFProgram() program = %+v, want %+v", running.Program, update)
+	}
+}
+
+func TestNFConfigs_VerifyNUpdateBPFProgram_watchdog(t *testing.T) {
+	prog := models.BPFProgram{Name: "ratelimiting", Version: "1.0", AdminStatus: models.Enabled,
+		Watchdog: &models.L3afDNFWatchdog{MaxRSS: 100 << 20, Action: models.WatchdogActionLog}}
+	running := &BPF{Program: prog, watchdogTrip: watchdogRSS}
+	update := prog
+	update.Watchdog = &models.L3afDNFWatchdog{MaxRSS: 200 << 20, Action: models.WatchdogActionRestart}
+	updateRunningProgram(t, running, update)
+	if running.watchdogTrip != "" {
+		t.Errorf("NFConfigs.VerifyNUpdateBPFProgram() watchdog trip = %q, want the watchdog re-armed", running.watchdogTrip)
+	}
+}
+
+func TestNFConfigs_VerifyNUpdateBPFProgram_scheduling(t *testing.T) {
+	prog := models.BPFProgram{Name: "ratelimiting", Version: "1.0", AdminStatus: models.Enabled, Nice: 5}
+	update := prog
+	update.Nice = 10
+	update.CPUAffinity = "2-5"
+	update.IONiceClass = "idle"
+	updateRunningProgram(t, &BPF{Program: prog}, update)
+
+	// reset to the default nice is applied by a version update only
+	reset := prog
+	reset.Nice = 0
+	bpfList := list.New()
+	bpfList.PushBack(&BPF{Program: prog})
+	cfg := &NFConfigs{hostConfig: &config.Config{}, IngressXDPBpfs: map[string]*list.List{"dummy": bpfList}}
+	if err := cfg.VerifyNUpdateBPFProgram(&reset, "dummy", models.XDPIngressType); err == nil {
+		t.Errorf("NFConfigs.VerifyNUpdateBPFProgram() error = nil, want the scheduling reset rejected")
+	}
+}
+
+func TestNFConfigs_VerifyNUpdateBPFProgram_heartbeat(t *testing.T) {
+	prog := models.BPFProgram{Name: "ratelimiting", Version: "1.0", AdminStatus: models.Enabled,
+		Heartbeat: &models.L3afDNFHeartbeat{MapName: "hb", Interval: "5s"}}
+	running := &BPF{Program: prog, heartbeatMap: &fakeMap{entries: map[int]int{}}, lastHeartbeat: time.Now(), heartbeatFailed: true}
+	update := prog
+	update.Heartbeat = &models.L3afDNFHeartbeat{MapName: "hb", Interval: "1s", Behavior: models.HeartbeatFailClosed}
+	updateRunningProgram(t, running, update)
+	if running.heartbeatMap != nil || !running.lastHeartbeat.IsZero() || running.heartbeatFailed {
+		t.Errorf("NFConfigs.VerifyNUpdateBPFProgram() heartbeat is not re-armed")
+	}
+}
+
+func TestNFConfigs_VerifyNUpdateBPFProgram_onFailure(t *testing.T) {
+	prog := models.BPFProgram{Name: "ratelimiting", Version: "1.0", AdminStatus: models.Enabled}
+	update := prog
+	update.OnFailure = models.OnFailureFailOpen
+	running := &BPF{Program: prog}
+	updateRunningProgram(t, running, update)
+	if !running.failOpen() {
+		t.Errorf("NFConfigs.VerifyNUpdateBPFProgram() program is not fail-open")
+	}
+}
+
+func TestNFConfigs_VerifyNUpdateBPFProgram_resetCounters(t *testing.T) {
+	prog := models.BPFProgram{Name: "ratelimiting", Version: "1.0", AdminStatus: models.Enabled}
+	update := prog
+	update.ResetCounters = []models.L3afDNFCounter{{Map: "rl_drop_count_map", Keys: "0-3"}}
+	updateRunningProgram(t, &BPF{Program: prog}, update)
+}
@@ -0,0 +1,101 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// setupNFFilesDir - creates the directory of the files handed to the NFs and mounts a private tmpfs on
+// it when enabled, so large rule sets don't wear the flash storage of the edge devices
+func setupNFFilesDir(conf *config.Config) error {
+	if conf == nil || len(conf.NFFilesDir) == 0 {
+		return nil
+	}
+	if err := appFS.MkdirAll(conf.NFFilesDir, 0700); err != nil {
+		return fmt.Errorf("failed to create nf files directory %s: %w", conf.NFFilesDir, err)
+	}
+	if !conf.NFFilesMountTmpfs {
+		return nil
+	}
+
+	// tmpfs is kept across the restarts of l3afd
+	tmpfs, err := isTmpfs(conf.NFFilesDir)
+	if err != nil {
+		return err
+	}
+	if tmpfs {
+		log.Info().Msgf("nf files directory %s is already on tmpfs", conf.NFFilesDir)
+		return nil
+	}
+	if strings.ContainsAny(conf.NFFilesTmpfsSize, ", ") {
+		return fmt.Errorf("invalid tmpfs size %q", conf.NFFilesTmpfsSize)
+	}
+	if err := mountTmpfs(conf.NFFilesDir, conf.NFFilesTmpfsSize); err != nil {
+		return err
+	}
+	log.Info().Msgf("mounted tmpfs of size %s on nf files directory %s", conf.NFFilesTmpfsSize, conf.NFFilesDir)
+	return nil
+}
+
+// nfFilesDir - directory of the files handed to the NF, per program and direction in the nf files
+// directory or the artifact directory of the program
+func (b *BPF) nfFilesDir(direction string) (string, error) {
+	dir := filepath.Join(b.FilePath, direction)
+	if len(nfCmdConfig.nfFilesDir) > 0 {
+		dir = filepath.Join(nfCmdConfig.nfFilesDir, b.Program.Name, direction)
+		if err := appFS.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create nf files directory %s: %w", dir, err)
+		}
+	}
+
+	// plaintext rules and configs are kept off the persistent storage when the state is encrypted
+	if stateAEAD != nil {
+		if len(nfCmdConfig.nfFilesDir) == 0 {
+			return "", fmt.Errorf("nf-files dir on tmpfs is required when encryption is enabled")
+		}
+		tmpfs, err := isTmpfs(dir)
+		if err != nil {
+			return "", err
+		}
+		if !tmpfs {
+			return "", fmt.Errorf("nf files directory %s is not on tmpfs", dir)
+		}
+	}
+	return dir, nil
+}
+
+// KFConfigFilePath - location of the KF config file of the program. Relative config file path is placed
+// in the nf files directory, absolute path is used as is.
+func (b *BPF) KFConfigFilePath(direction string) (string, error) {
+	if len(b.Program.ConfigFilePath) == 0 {
+		return "", fmt.Errorf("config file path of the program %s is empty", b.Program.Name)
+	}
+	if filepath.IsAbs(b.Program.ConfigFilePath) {
+		return b.Program.ConfigFilePath, nil
+	}
+	if err := validateNFFileName(b.Program.ConfigFilePath); err != nil {
+		return "", err
+	}
+	dir, err := b.nfFilesDir(direction)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, b.Program.ConfigFilePath), nil
+}
+
+// validateNFFileName - file name must stay in the nf files directory of the program
+func validateNFFileName(name string) error {
+	clean := filepath.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("file name %s is outside of the nf files directory", name)
+	}
+	return nil
+}
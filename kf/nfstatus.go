@@ -0,0 +1,25 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// parseNFStatus - parses the JSON status printed by the status command, ok is false when the output
+// is not a JSON status object and the legacy RUNNING string is expected
+func parseNFStatus(out string) (status *models.L3afDNFStatus, running bool, ok bool) {
+	out = strings.TrimSpace(out)
+	if !strings.HasPrefix(out, "{") {
+		return nil, false, false
+	}
+	status = &models.L3afDNFStatus{}
+	if err := json.Unmarshal([]byte(out), status); err != nil || len(status.State) == 0 {
+		return nil, false, false
+	}
+	return status, strings.EqualFold(status.State, bpfStatus), true
+}
@@ -0,0 +1,73 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_parseNFStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		out         string
+		wantStatus  *models.L3afDNFStatus
+		wantRunning bool
+		wantOK      bool
+	}{
+		{
+			name: "Running",
+			out:  `{"state":"RUNNING","version":"1.2.0","counters":{"drops":10}}` + "\n",
+			wantStatus: &models.L3afDNFStatus{
+				State:    "RUNNING",
+				Version:  "1.2.0",
+				Counters: map[string]float64{"drops": 10},
+			},
+			wantRunning: true,
+			wantOK:      true,
+		},
+		{
+			name: "Degraded",
+			out:  `{"state":"DEGRADED","message":"map full"}`,
+			wantStatus: &models.L3afDNFStatus{
+				State:   "DEGRADED",
+				Message: "map full",
+			},
+			wantRunning: false,
+			wantOK:      true,
+		},
+		{
+			name:   "Legacy",
+			out:    "RUNNING",
+			wantOK: false,
+		},
+		{
+			name:   "InvalidJSON",
+			out:    `{"state":`,
+			wantOK: false,
+		},
+		{
+			name:   "NoState",
+			out:    `{"version":"1.2.0"}`,
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, running, ok := parseNFStatus(tt.out)
+			if ok != tt.wantOK {
+				t.Errorf("parseNFStatus() ok = %v, want %v", ok, tt.wantOK)
+				return
+			}
+			if running != tt.wantRunning {
+				t.Errorf("parseNFStatus() running = %v, want %v", running, tt.wantRunning)
+			}
+			if !reflect.DeepEqual(status, tt.wantStatus) {
+				t.Errorf("parseNFStatus() status = %v, want %v", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// XDP attach modes
+const (
+	XDPModeGeneric = "generic"
+	XDPModeNative  = "native"
+	XDPModeOffload = "offload"
+)
+
+// nativeXDPDrivers - NIC drivers supporting native XDP
+var nativeXDPDrivers = map[string]bool{
+	"bnxt_en":    true,
+	"dpaa2-eth":  true,
+	"ena":        true,
+	"fec":        true,
+	"hv_netvsc":  true,
+	"i40e":       true,
+	"ice":        true,
+	"igb":        true,
+	"igc":        true,
+	"ixgbe":      true,
+	"ixgbevf":    true,
+	"mlx4_en":    true,
+	"mlx5_core":  true,
+	"mvneta":     true,
+	"mvpp2":      true,
+	"netsec":     true,
+	"nfp":        true,
+	"nicvf":      true,
+	"qede":       true,
+	"sfc":        true,
+	"stmmac":     true,
+	"tun":        true,
+	"veth":       true,
+	"virtio_net": true,
+}
+
+// offloadXDPDrivers - NIC drivers supporting XDP offload to the NIC, extended by the xdp-offload drivers of l3afd.cfg
+var offloadXDPDrivers = map[string]bool{
+	"nfp": true,
+}
+
+// NodeFacts - returns the facts of the node used by the control plane to schedule NF versions
+func (c *NFConfigs) NodeFacts(version string) *models.L3afDNodeFacts {
+	facts := &models.L3afDNodeFacts{
+		HostName:         c.hostName,
+		L3afdVersion:     version,
+		Arch:             runtime.GOARCH,
+		BTFAvailable:     len(c.btfPath) > 0,
+		ChainingEnabled:  c.hostConfig.BpfChainingEnabled,
+		Labels:           c.NodeLabels(),
+		Interfaces:       make([]models.L3afDIfaceFacts, 0),
+		NetworkFunctions: make([]models.L3afDNFFacts, 0),
+	}
+
+	var err error
+	if facts.KernelRelease, err = getKernelRelease(); err != nil {
+		log.Warn().Err(err).Msg("node facts failed to get kernel release")
+	}
+	if facts.Platform, err = GetPlatform(); err != nil {
+		log.Warn().Err(err).Msg("node facts failed to get platform")
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		log.Warn().Err(err).Msg("node facts failed to get interfaces")
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		driver, err := getIfaceDriver(iface.Name)
+		if err != nil {
+			log.Debug().Err(err).Msgf("node facts failed to get driver of iface %s", iface.Name)
+		}
+		attached, err := xdpAttachment(iface.Name)
+		if err != nil {
+			log.Debug().Err(err).Msgf("node facts failed to get xdp attachment of iface %s", iface.Name)
+		}
+		facts.Interfaces = append(facts.Interfaces, models.L3afDIfaceFacts{
+			Name:         iface.Name,
+			Driver:       driver,
+			XDPModes:     xdpModes(driver),
+			XDPAttached:  attachedModes(attached),
+			MTU:          iface.MTU,
+			HardwareAddr: iface.HardwareAddr.String(),
+		})
+	}
+
+	// the heartbeat walks the chains outside of the api calls changing them
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	runModes := make(map[string]map[string]string)
+	for ifaceName := range c.IngressXDPBpfs {
+		runModes[ifaceName] = c.ifaceXDPModes(ifaceName)
+	}
+	for _, bpfProgs := range c.EBPFProgramsAll() {
+		directions := []struct {
+			name  string
+			progs []*models.BPFProgram
+		}{
+			{models.XDPIngressType, bpfProgs.BpfPrograms.XDPIngress},
+			{models.IngressType, bpfProgs.BpfPrograms.TCIngress},
+			{models.EgressType, bpfProgs.BpfPrograms.TCEgress},
+		}
+		for _, d := range directions {
+			for _, prog := range d.progs {
+				nf := models.L3afDNFFacts{
+					Iface:       bpfProgs.Iface,
+					Direction:   d.name,
+					Name:        prog.Name,
+					Version:     prog.Version,
+					SeqID:       prog.SeqID,
+					AdminStatus: prog.AdminStatus,
+				}
+				if d.name == models.XDPIngressType {
+					nf.XDPMode = runModes[bpfProgs.Iface][prog.Name]
+				}
+				facts.NetworkFunctions = append(facts.NetworkFunctions, nf)
+			}
+		}
+	}
+	sort.SliceStable(facts.NetworkFunctions, func(i, j int) bool {
+		return facts.NetworkFunctions[i].Iface < facts.NetworkFunctions[j].Iface
+	})
+
+	return facts
+}
+
+// StartHeartbeat - posts the node facts to the configured heartbeat url at every heartbeat interval
+func (c *NFConfigs) StartHeartbeat(ctx context.Context, version string) {
+	if len(c.hostConfig.HeartbeatURL) == 0 || c.hostConfig.HeartbeatInterval <= 0 {
+		log.Info().Msg("node facts heartbeat is disabled")
+		return
+	}
+
+	timeOut := time.Duration(c.hostConfig.HttpClientTimeout) * time.Second
+	client := &http.Client{Timeout: timeOut}
+
+	go func() {
+		ticker := time.NewTicker(c.hostConfig.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			if err := c.postHeartbeat(ctx, client, version); err != nil {
+				log.Warn().Err(err).Msgf("node facts heartbeat to %s failed", c.hostConfig.HeartbeatURL)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *NFConfigs) postHeartbeat(ctx context.Context, client *http.Client, version string) error {
+	body, err := json.Marshal(c.NodeFacts(version))
+	if err != nil {
+		return fmt.Errorf("failed to marshal node facts: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.hostConfig.HeartbeatURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post request returned unexpected status code: %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// xdpModes - returns the XDP attach modes supported by the driver, generic mode is supported by all the drivers
+func xdpModes(driver string) []string {
+	modes := []string{XDPModeGeneric}
+	if nativeXDPDrivers[driver] {
+		modes = append(modes, XDPModeNative)
+	}
+	if offloadCapable(driver) {
+		modes = append(modes, XDPModeOffload)
+	}
+	return modes
+}
@@ -0,0 +1,45 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_xdpModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		want   []string
+	}{
+		{
+			name:   "Native",
+			driver: "ixgbe",
+			want:   []string{XDPModeGeneric, XDPModeNative},
+		},
+		{
+			name:   "Offload",
+			driver: "nfp",
+			want:   []string{XDPModeGeneric, XDPModeNative, XDPModeOffload},
+		},
+		{
+			name:   "GenericOnly",
+			driver: "e1000",
+			want:   []string{XDPModeGeneric},
+		},
+		{
+			name:   "UnknownDriver",
+			driver: "",
+			want:   []string{XDPModeGeneric},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := xdpModes(tt.driver); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("xdpModes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidNodeSelector is returned when a node selector expression of a program is not valid
+var ErrInvalidNodeSelector = errors.New("invalid node selector")
+
+// Labels of the node derived from its facts
+const (
+	NodeLabelHostName = "hostname"
+	NodeLabelArch     = "arch"
+	NodeLabelPlatform = "platform"
+	NodeLabelKernel   = "kernel"
+	NodeLabelBTF      = "btf"
+)
+
+// selector operators, the two character operators are matched first
+var selectorOps = []string{">=", "<=", "!=", "=", ">", "<"}
+
+// nodeSelector - parsed node selector expression, the key without an operator matches the nodes having the
+// label and !key the nodes without it
+type nodeSelector struct {
+	key    string
+	op     string
+	values []string // alternatives of the = and != operators separated by |
+}
+
+// configuredNodeLabels - labels of the node in l3afd.cfg
+var configuredNodeLabels = make(map[string]string)
+
+// setNodeLabels - sets the labels of the node of l3afd.cfg, the labels are key=value
+func setNodeLabels(conf *config.Config) error {
+	labels := make(map[string]string)
+	if conf != nil {
+		for _, label := range conf.NodeLabels {
+			kv := strings.SplitN(label, "=", 2)
+			key := strings.TrimSpace(kv[0])
+			if len(kv) != 2 || len(key) == 0 {
+				return fmt.Errorf("invalid node label %q, want key=value", label)
+			}
+			labels[key] = strings.TrimSpace(kv[1])
+		}
+	}
+	configuredNodeLabels = labels
+	return nil
+}
+
+// parseNodeSelector - parses the node selector expression e.g. region=us-west|us-east, kernel>=5.10 or !canary
+func parseNodeSelector(expr string) (nodeSelector, error) {
+	expr = strings.TrimSpace(expr)
+	for _, op := range selectorOps {
+		i := strings.Index(expr, op)
+		if i < 0 {
+			continue
+		}
+		s := nodeSelector{key: strings.TrimSpace(expr[:i]), op: op}
+		value := strings.TrimSpace(expr[i+len(op):])
+		if len(s.key) == 0 || len(value) == 0 {
+			return s, fmt.Errorf("%w %q: key and value are required", ErrInvalidNodeSelector, expr)
+		}
+		switch op {
+		case "=", "!=":
+			for _, v := range strings.Split(value, "|") {
+				s.values = append(s.values, strings.TrimSpace(v))
+			}
+		default:
+			if len(versionParts(value)) == 0 {
+				return s, fmt.Errorf("%w %q: %s requires a version", ErrInvalidNodeSelector, expr, op)
+			}
+			s.values = []string{value}
+		}
+		return s, nil
+	}
+
+	s := nodeSelector{key: expr}
+	if strings.HasPrefix(expr, "!") {
+		s = nodeSelector{key: strings.TrimSpace(expr[1:]), op: "!"}
+	}
+	if len(s.key) == 0 || strings.ContainsAny(s.key, " |") {
+		return s, fmt.Errorf("%w %q", ErrInvalidNodeSelector, expr)
+	}
+	return s, nil
+}
+
+// matches - the labels of the node match the selector, a missing label matches only != and !key
+func (s nodeSelector) matches(labels map[string]string) bool {
+	value, ok := labels[s.key]
+	switch s.op {
+	case "":
+		return ok
+	case "!":
+		return !ok
+	case "=", "!=":
+		found := false
+		for _, v := range s.values {
+			if ok && v == value {
+				found = true
+			}
+		}
+		return found == (s.op == "=")
+	}
+	if !ok || len(versionParts(value)) == 0 {
+		return false
+	}
+	cmp := compareVersions(value, s.values[0])
+	switch s.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp < 0
+	}
+}
+
+// versionParts - numeric parts of the version e.g. 5.15.0-76-generic is 5 15 0 76, nil when the version does
+// not start with a number
+func versionParts(v string) []int {
+	if len(v) == 0 || v[0] < '0' || v[0] > '9' {
+		return nil
+	}
+	var parts []int
+	for _, f := range strings.FieldsFunc(v, func(r rune) bool { return r < '0' || r > '9' }) {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// compareVersions - compares the version to the parts of the selector version, so kernel 5.15.0-76 equals
+// 5.15, the missing parts are 0
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pb); i++ {
+		x, y := 0, pb[i]
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// NodeLabels - labels of the node matched by the node selectors of the programs, the facts of the node
+// overridden by the labels of l3afd.cfg
+func (c *NFConfigs) NodeLabels() map[string]string {
+	labels := map[string]string{
+		NodeLabelHostName: c.hostName,
+		NodeLabelArch:     runtime.GOARCH,
+		NodeLabelBTF:      strconv.FormatBool(len(c.btfPath) > 0),
+	}
+	if release, err := getKernelRelease(); err == nil {
+		labels[NodeLabelKernel] = release
+	} else {
+		log.Warn().Err(err).Msg("kernel release of the node labels is not available")
+	}
+	if platform, err := GetPlatform(); err == nil {
+		labels[NodeLabelPlatform] = platform
+	} else {
+		log.Warn().Err(err).Msg("platform of the node labels is not available")
+	}
+	for k, v := range configuredNodeLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// ValidateNodeSelectors - Verifies the node selector expressions of the programs
+func ValidateNodeSelectors(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			for _, expr := range ref.prog.NodeSelector {
+				if _, err := parseNodeSelector(expr); err != nil {
+					return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SelectNodePrograms - configs of the fleet config applied by this node. The configs of the fleet host name
+// are taken by every node unless the node has its own config of the interface, the configs of the other nodes
+// are skipped, and the programs whose node selectors do not all match the labels of the node are left out.
+func (c *NFConfigs) SelectNodePrograms(bpfProgs []models.L3afBPFPrograms) ([]models.L3afBPFPrograms, error) {
+	if err := ValidateNodeSelectors(bpfProgs); err != nil {
+		return nil, err
+	}
+
+	hostIfaces := make(map[string]bool)
+	fleet := false
+	for _, cfg := range bpfProgs {
+		switch cfg.HostName {
+		case models.FleetHostName:
+			fleet = true
+		case c.hostName:
+			hostIfaces[cfg.Iface] = true
+		}
+	}
+
+	var labels map[string]string
+	selected := make([]models.L3afBPFPrograms, 0, len(bpfProgs))
+	for _, cfg := range bpfProgs {
+		switch {
+		case cfg.HostName == models.FleetHostName:
+			if hostIfaces[cfg.Iface] {
+				continue
+			}
+			cfg.HostName = c.hostName
+		case fleet && cfg.HostName != c.hostName:
+			// configs of the other nodes in the fleet config
+			continue
+		}
+		if cfg.BpfPrograms == nil {
+			selected = append(selected, cfg)
+			continue
+		}
+
+		progs := &models.BPFPrograms{}
+		for _, d := range []struct {
+			src []*models.BPFProgram
+			dst *[]*models.BPFProgram
+		}{
+			{cfg.BpfPrograms.XDPIngress, &progs.XDPIngress},
+			{cfg.BpfPrograms.TCIngress, &progs.TCIngress},
+			{cfg.BpfPrograms.TCEgress, &progs.TCEgress},
+		} {
+			for _, prog := range d.src {
+				if prog != nil && len(prog.NodeSelector) > 0 {
+					if labels == nil {
+						labels = c.NodeLabels()
+					}
+					if !selectorsMatch(prog.NodeSelector, labels) {
+						log.Info().Msgf("program %s on iface %s is not selected by the node selector %s",
+							prog.Name, cfg.Iface, strings.Join(prog.NodeSelector, ", "))
+						continue
+					}
+				}
+				*d.dst = append(*d.dst, prog)
+			}
+		}
+		cfg.BpfPrograms = progs
+		selected = append(selected, cfg)
+	}
+	if fleet {
+		log.Info().Msgf("fleet config selected %d interface configs for host %s", len(selected), c.hostName)
+	}
+	return selected, nil
+}
+
+// selectorsMatch - all the node selector expressions match the labels
+func selectorsMatch(exprs []string, labels map[string]string) bool {
+	for _, expr := range exprs {
+		s, err := parseNodeSelector(expr)
+		if err != nil || !s.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
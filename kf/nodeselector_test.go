@@ -0,0 +1,104 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_nodeSelector(t *testing.T) {
+	labels := map[string]string{"region": "us-west", "hw-model": "r740", "kernel": "5.15.0-76-generic"}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"region=us-west", true},
+		{"region=us-east|us-west", true},
+		{"region!=us-west", false},
+		{"rack!=a1", true},
+		{"rack=a1", false},
+		{"hw-model", true},
+		{"!canary", true},
+		{"!hw-model", false},
+		{"kernel>=5.10", true},
+		{"kernel>=5.15.1", false},
+		{"kernel<5.16", true},
+		{"kernel>5.15", false},
+		{"kernel<=5.15.0", true},
+		{"region>=5.10", false},
+	}
+	for _, tt := range tests {
+		s, err := parseNodeSelector(tt.expr)
+		if err != nil {
+			t.Errorf("parseNodeSelector(%s) error = %v", tt.expr, err)
+			continue
+		}
+		if got := s.matches(labels); got != tt.want {
+			t.Errorf("%s matches = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "=us-west", "region=", "kernel>=new", "!", "hw model"} {
+		if _, err := parseNodeSelector(expr); !errors.Is(err, ErrInvalidNodeSelector) {
+			t.Errorf("parseNodeSelector(%q) error = %v, want ErrInvalidNodeSelector", expr, err)
+		}
+	}
+}
+
+func TestNFConfigs_SelectNodePrograms(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command("/bin/sh", "-c", "echo focal") }
+	defer func() { execCommand = exec.Command }()
+	if err := setNodeLabels(&config.Config{NodeLabels: []string{"region=us-west", "kernel=5.15.0"}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { setNodeLabels(nil) })
+	c := &NFConfigs{hostName: "edge-1a", hostConfig: &config.Config{}}
+
+	progs := func(selectors ...[]string) *models.BPFPrograms {
+		p := &models.BPFPrograms{}
+		for i, s := range selectors {
+			p.XDPIngress = append(p.XDPIngress, &models.BPFProgram{Name: string(rune('a' + i)), NodeSelector: s})
+		}
+		return p
+	}
+	fleet := []models.L3afBPFPrograms{
+		{HostName: models.FleetHostName, Iface: "eth0", BpfPrograms: progs(nil, []string{"region=us-east"}, []string{"kernel>=5.10", "platform=focal"})},
+		{HostName: models.FleetHostName, Iface: "eth1", BpfPrograms: progs(nil)},
+		{HostName: "edge-1a", Iface: "eth1", BpfPrograms: progs(nil, nil)},
+		{HostName: "edge-2b", Iface: "eth2", BpfPrograms: progs(nil)},
+	}
+	got, err := c.SelectNodePrograms(fleet)
+	if err != nil {
+		t.Fatalf("SelectNodePrograms() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Iface != "eth0" || got[0].HostName != "edge-1a" || got[1].Iface != "eth1" {
+		t.Fatalf("SelectNodePrograms() = %+v, want eth0 of the fleet and the eth1 config of the node", got)
+	}
+	if xdp := got[0].BpfPrograms.XDPIngress; len(xdp) != 2 || xdp[0].Name != "a" || xdp[1].Name != "c" {
+		t.Errorf("selected programs of eth0 = %d, want a and c", len(xdp))
+	}
+	if len(got[1].BpfPrograms.XDPIngress) != 2 {
+		t.Errorf("eth1 config of the fleet is applied over the config of the node")
+	}
+	if len(fleet[0].BpfPrograms.XDPIngress) != 3 || fleet[0].HostName != models.FleetHostName {
+		t.Errorf("fleet config is modified by the selection")
+	}
+
+	invalid := []models.L3afBPFPrograms{{HostName: "edge-1a", Iface: "eth0", BpfPrograms: progs([]string{"kernel>=latest"})}}
+	if _, err := c.SelectNodePrograms(invalid); !errors.Is(err, ErrInvalidNodeSelector) {
+		t.Errorf("SelectNodePrograms() error = %v, want ErrInvalidNodeSelector", err)
+	}
+	if err := setNodeLabels(&config.Config{NodeLabels: []string{"region"}}); err == nil {
+		t.Errorf("setNodeLabels() error = nil, want invalid label")
+	}
+}
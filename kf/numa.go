@@ -0,0 +1,78 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// cpuAffinitySameNode - cpu affinity of the CPUs of the NUMA node of the NIC of the interface
+const cpuAffinitySameNode = "same-node"
+
+// noNUMANode - numa_node of a device without a NUMA node e.g. a virtual interface or a single socket server
+const noNUMANode = -1
+
+// ifaceNUMANode - NUMA node of the NIC of the interface, noNUMANode when the device has none
+func ifaceNUMANode(ifaceName string) (int, error) {
+	buf, err := appFS.ReadFile(fmt.Sprintf("/sys/class/net/%s/device/numa_node", ifaceName))
+	if err != nil {
+		// virtual interfaces have no device
+		return noNUMANode, nil
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return noNUMANode, fmt.Errorf("invalid numa node %q of iface %s", strings.TrimSpace(string(buf)), ifaceName)
+	}
+	if node < 0 {
+		return noNUMANode, nil
+	}
+	return node, nil
+}
+
+// numaNodeCPUs - CPU list of the NUMA node e.g. 0-11,24-35
+func numaNodeCPUs(node int) (string, error) {
+	buf, err := appFS.ReadFile(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", node))
+	if err != nil {
+		return "", fmt.Errorf("failed to read cpus of numa node %d: %w", node, err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// resolveCPUAffinity - CPU list of the cpu affinity of a program on the interface, same-node is resolved to
+// the CPUs of the NUMA node of the NIC and is empty when the NIC has no NUMA node
+func resolveCPUAffinity(ifaceName, affinity string) (string, error) {
+	if affinity != cpuAffinitySameNode {
+		return affinity, nil
+	}
+	node, err := ifaceNUMANode(ifaceName)
+	if err != nil || node == noNUMANode {
+		return "", err
+	}
+	return numaNodeCPUs(node)
+}
+
+// IfaceNUMA - NUMA node of the NICs of the host interfaces and the CPUs of the node
+func (c *NFConfigs) IfaceNUMA() []models.L3afDIfaceNUMA {
+	ifaces := make([]models.L3afDIfaceNUMA, 0, len(c.hostInterfaces))
+	for ifaceName := range c.hostInterfaces {
+		numa := models.L3afDIfaceNUMA{Iface: ifaceName, NUMANode: noNUMANode}
+		node, err := ifaceNUMANode(ifaceName)
+		if err != nil {
+			numa.Error = err.Error()
+		} else if node != noNUMANode {
+			numa.NUMANode = node
+			if numa.CPUs, err = numaNodeCPUs(node); err != nil {
+				numa.Error = err.Error()
+			}
+		}
+		ifaces = append(ifaces, numa)
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].Iface < ifaces[j].Iface })
+	return ifaces
+}
@@ -0,0 +1,59 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestResolveCPUAffinity(t *testing.T) {
+	useMemFS(t, map[string]string{
+		"/sys/class/net/ens7/device/numa_node":   "1\n",
+		"/sys/class/net/eth0/device/numa_node":   "-1\n",
+		"/sys/devices/system/node/node1/cpulist": "12-23,36-47\n",
+		"/sys/devices/system/node/node0/cpulist": "0-11,24-35\n",
+		"/sys/class/net/ens8/device/numa_node":   "x\n",
+		"/sys/class/net/ens9/device/numa_node":   "0\n",
+	})
+	tests := []struct {
+		iface, affinity string
+		want            string
+		wantErr         bool
+	}{
+		{iface: "ens7", affinity: cpuAffinitySameNode, want: "12-23,36-47"},
+		{iface: "ens9", affinity: cpuAffinitySameNode, want: "0-11,24-35"},
+		{iface: "ens7", affinity: "2-5", want: "2-5"},
+		{iface: "eth0", affinity: cpuAffinitySameNode, want: ""},
+		{iface: "veth0", affinity: cpuAffinitySameNode, want: ""},
+		{iface: "ens8", affinity: cpuAffinitySameNode, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveCPUAffinity(tt.iface, tt.affinity)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveCPUAffinity(%s, %s) error = %v, wantErr %v", tt.iface, tt.affinity, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveCPUAffinity(%s, %s) = %q, want %q", tt.iface, tt.affinity, got, tt.want)
+		}
+	}
+}
+
+func TestNFConfigs_IfaceNUMA(t *testing.T) {
+	useMemFS(t, map[string]string{
+		"/sys/class/net/ens7/device/numa_node":   "1\n",
+		"/sys/devices/system/node/node1/cpulist": "12-23\n",
+	})
+	c := &NFConfigs{hostInterfaces: map[string]bool{"ens7": true, "lo": true}}
+	want := []models.L3afDIfaceNUMA{
+		{Iface: "ens7", NUMANode: 1, CPUs: "12-23"},
+		{Iface: "lo", NUMANode: noNUMANode},
+	}
+	if got := c.IfaceNUMA(); !reflect.DeepEqual(got, want) {
+		t.Errorf("IfaceNUMA() = %+v, want %+v", got, want)
+	}
+}
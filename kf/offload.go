@@ -0,0 +1,254 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OffloadFallbackFail - fallback failing the start of the program of the offload XDP mode on interfaces not
+// supporting offload
+const OffloadFallbackFail = "fail"
+
+// rootCapabilityOffload - capability of the root programs offloaded to the NIC, the chained programs of the
+// offload XDP mode require it
+const rootCapabilityOffload = "hw-offload"
+
+// IFLA_XDP nested attributes of the links, see linux/if_link.h
+const (
+	iflaXDPDrvProgID = 5
+	iflaXDPSkbProgID = 6
+	iflaXDPHwProgID  = 7
+)
+
+// xdpOffloadDrivers - NIC drivers supporting XDP offload of l3afd.cfg besides offloadXDPDrivers
+var xdpOffloadDrivers = map[string]bool{}
+
+// xdpOffloadFallback - fallback of the programs of the offload XDP mode not setting one
+var xdpOffloadFallback = XDPModeNative
+
+// ifaceDriverName - driver of the interface, replaced by the tests
+var ifaceDriverName = getIfaceDriver
+
+// setXDPOffload - configures the offload drivers and the default fallback from l3afd.cfg
+func setXDPOffload(conf *config.Config) {
+	xdpOffloadDrivers = map[string]bool{}
+	xdpOffloadFallback = XDPModeNative
+	if conf == nil {
+		return
+	}
+	for _, driver := range conf.XDPOffloadDrivers {
+		if driver = strings.TrimSpace(driver); len(driver) > 0 {
+			xdpOffloadDrivers[driver] = true
+		}
+	}
+	if len(conf.XDPOffloadFallback) > 0 {
+		xdpOffloadFallback = conf.XDPOffloadFallback
+	}
+}
+
+// offloadCapable - driver supports XDP offload to the NIC
+func offloadCapable(driver string) bool {
+	return offloadXDPDrivers[driver] || xdpOffloadDrivers[driver]
+}
+
+// validateXDPMode - XDP mode is set for the XDP programs only and the mode and the offload fallback are known
+func validateXDPMode(prog *models.BPFProgram, direction string) error {
+	if len(prog.XDPMode) == 0 {
+		if len(prog.OffloadFallback) > 0 {
+			return fmt.Errorf("offload fallback %s is set without the offload xdp mode", prog.OffloadFallback)
+		}
+		return nil
+	}
+	if direction != models.XDPIngressType {
+		return fmt.Errorf("xdp mode %s is set for direction %s", prog.XDPMode, direction)
+	}
+	switch prog.XDPMode {
+	case XDPModeGeneric, XDPModeNative:
+		if len(prog.OffloadFallback) > 0 {
+			return fmt.Errorf("offload fallback %s is set without the offload xdp mode", prog.OffloadFallback)
+		}
+	case XDPModeOffload:
+		switch prog.OffloadFallback {
+		case "", XDPModeNative, XDPModeGeneric, OffloadFallbackFail:
+		default:
+			return fmt.Errorf("unknown offload fallback %s, must be native, generic or fail", prog.OffloadFallback)
+		}
+	default:
+		return fmt.Errorf("unknown xdp mode %s, must be generic, native or offload", prog.XDPMode)
+	}
+	return nil
+}
+
+// ValidateXDPModes - Verifies the XDP modes of the programs, the chained programs of the offload XDP mode
+// require the root program offloaded to the NIC
+func (c *NFConfigs) ValidateXDPModes(bpfProgs []models.L3afBPFPrograms) error {
+	chain := c.hostConfig != nil && c.hostConfig.BpfChainingEnabled
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateXDPMode(ref.prog, ref.direction); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+			if !chain || ref.prog.XDPMode != XDPModeOffload || !loadedStatus(ref.prog.AdminStatus) {
+				continue
+			}
+			offloaded := false
+			for _, capability := range rootCapabilities(c.hostConfig, cfg.Iface, ref.direction) {
+				if strings.TrimSpace(capability) == rootCapabilityOffload {
+					offloaded = true
+				}
+			}
+			if !offloaded {
+				return fmt.Errorf("program %s on iface %s is of the offload xdp mode, the root program does not provide capability %s",
+					ref.prog.Name, cfg.Iface, rootCapabilityOffload)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveXDPMode - XDP mode the program is started in on the interface. The program of the offload XDP mode
+// falls back to its offload fallback on interfaces not supporting offload, native falls back to generic on
+// the drivers supporting no native XDP either.
+func resolveXDPMode(prog *models.BPFProgram, ifaceName string) (string, error) {
+	if prog.XDPMode != XDPModeOffload {
+		return prog.XDPMode, nil
+	}
+	driver, err := ifaceDriverName(ifaceName)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to get driver of iface %s, xdp offload is not supported", ifaceName)
+	}
+	if offloadCapable(driver) {
+		return XDPModeOffload, nil
+	}
+
+	fallback := prog.OffloadFallback
+	if len(fallback) == 0 {
+		fallback = xdpOffloadFallback
+	}
+	if fallback == OffloadFallbackFail {
+		return "", fmt.Errorf("driver %q of iface %s does not support xdp offload of the program %s", driver, ifaceName, prog.Name)
+	}
+	if fallback == XDPModeNative && !nativeXDPDrivers[driver] {
+		fallback = XDPModeGeneric
+	}
+	log.Warn().Msgf("driver %q of iface %s does not support xdp offload, program %s falls back to xdp mode %s", driver, ifaceName, prog.Name, fallback)
+	return fallback, nil
+}
+
+// xdpImplicitArgs - sets the XDP mode the program is started in to the implicit args, the offloaded programs
+// are loaded for the device of the interface index
+func (b *BPF) xdpImplicitArgs(implicit *models.L3afDNFImplicitArgs, ifaceName, direction string) error {
+	b.xdpMode = ""
+	if direction != models.XDPIngressType || len(b.Program.XDPMode) == 0 {
+		return nil
+	}
+	mode, err := resolveXDPMode(&b.Program, ifaceName)
+	if err != nil {
+		return err
+	}
+	if mode == XDPModeOffload {
+		iface, err := net.InterfaceByName(ifaceName)
+		if err != nil {
+			return fmt.Errorf("failed to get index of iface %s: %w", ifaceName, err)
+		}
+		implicit.Ifindex = iface.Index
+	}
+	implicit.XDPMode = mode
+	b.xdpMode = mode
+	return nil
+}
+
+// parseXDPAttachment - program IDs attached to the interface by XDP mode, from the IFLA_XDP attribute of the link
+func parseXDPAttachment(attr []byte) map[string]int {
+	attached := make(map[string]int)
+	for len(attr) >= 4 {
+		l := int(nativeEndian.Uint16(attr[0:2]))
+		if l < 4 || l > len(attr) {
+			break
+		}
+		if l >= 8 {
+			id := int(nativeEndian.Uint32(attr[4:8]))
+			switch nativeEndian.Uint16(attr[2:4]) {
+			case iflaXDPDrvProgID:
+				attached[XDPModeNative] = id
+			case iflaXDPSkbProgID:
+				attached[XDPModeGeneric] = id
+			case iflaXDPHwProgID:
+				attached[XDPModeOffload] = id
+			}
+		}
+		// attributes are aligned to 4 bytes
+		l = (l + 3) &^ 3
+		if l > len(attr) {
+			break
+		}
+		attr = attr[l:]
+	}
+	return attached
+}
+
+// attachedModes - XDP modes a program is attached in
+func attachedModes(attached map[string]int) []string {
+	modes := make([]string, 0, len(attached))
+	for mode, id := range attached {
+		if id > 0 {
+			modes = append(modes, mode)
+		}
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+// xdpRunMode - XDP mode the program runs in, the mode the program is attached in, the mode of the root program
+// for the programs tail called by the root, or the mode the program is started in when the kernel does not
+// report its attachment
+func xdpRunMode(attached map[string]int, progID, rootID int, resolved string) string {
+	for _, id := range []int{progID, rootID} {
+		if id <= 0 {
+			continue
+		}
+		for _, mode := range []string{XDPModeOffload, XDPModeNative, XDPModeGeneric} {
+			if attached[mode] == id {
+				return mode
+			}
+		}
+	}
+	return resolved
+}
+
+// ifaceXDPModes - XDP modes the programs of the interface run in by program name, the caller holds the configs lock
+func (c *NFConfigs) ifaceXDPModes(ifaceName string) map[string]string {
+	bpfList := c.IngressXDPBpfs[ifaceName]
+	if bpfList == nil || bpfList.Len() == 0 {
+		return nil
+	}
+	attached, err := xdpAttachment(ifaceName)
+	if err != nil {
+		log.Debug().Err(err).Msgf("failed to get xdp attachment of iface %s", ifaceName)
+	}
+	rootID := 0
+	if c.hostConfig.BpfChainingEnabled {
+		rootID = bpfList.Front().Value.(*BPF).ProgID
+	}
+	modes := make(map[string]string)
+	for e := bpfList.Front(); e != nil; e = e.Next() {
+		b := e.Value.(*BPF)
+		if mode := xdpRunMode(attached, b.ProgID, rootID, b.xdpMode); len(mode) > 0 {
+			modes[b.Program.Name] = mode
+		}
+	}
+	return modes
+}
@@ -0,0 +1,168 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_validateXDPMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		prog      models.BPFProgram
+		direction string
+		wantErr   bool
+	}{
+		{"none", models.BPFProgram{}, models.IngressType, false},
+		{"native", models.BPFProgram{XDPMode: XDPModeNative}, models.XDPIngressType, false},
+		{"offload", models.BPFProgram{XDPMode: XDPModeOffload, OffloadFallback: OffloadFallbackFail}, models.XDPIngressType, false},
+		{"tc program", models.BPFProgram{XDPMode: XDPModeGeneric}, models.IngressType, true},
+		{"unknown mode", models.BPFProgram{XDPMode: "hw"}, models.XDPIngressType, true},
+		{"unknown fallback", models.BPFProgram{XDPMode: XDPModeOffload, OffloadFallback: XDPModeOffload}, models.XDPIngressType, true},
+		{"fallback without offload", models.BPFProgram{XDPMode: XDPModeNative, OffloadFallback: XDPModeGeneric}, models.XDPIngressType, true},
+		{"fallback without mode", models.BPFProgram{OffloadFallback: XDPModeGeneric}, models.XDPIngressType, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateXDPMode(&tt.prog, tt.direction); (err != nil) != tt.wantErr {
+				t.Errorf("validateXDPMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNFConfigs_ValidateXDPModes(t *testing.T) {
+	progs := func(iface string) []models.L3afBPFPrograms {
+		return []models.L3afBPFPrograms{{
+			Iface: iface,
+			BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "offload-acl", AdminStatus: models.Enabled, XDPMode: XDPModeOffload}},
+			},
+		}}
+	}
+	c := &NFConfigs{hostConfig: rootFlavorConfig()}
+	if err := c.ValidateXDPModes(progs("eth2")); err != nil {
+		t.Errorf("ValidateXDPModes() error = %v, want the program chained to the offloaded root", err)
+	}
+	if err := c.ValidateXDPModes(progs("eth0")); err == nil {
+		t.Errorf("ValidateXDPModes() error = nil, want the program chained to the root in the driver rejected")
+	}
+	c.hostConfig.BpfChainingEnabled = false
+	if err := c.ValidateXDPModes(progs("eth0")); err != nil {
+		t.Errorf("ValidateXDPModes() error = %v, want the program attaching itself offloaded", err)
+	}
+
+	prog, err := rootProgram(rootFlavorConfig(), "eth2", models.XDPIngressType, models.XDPType)
+	if err != nil || prog.XDPMode != XDPModeOffload {
+		t.Errorf("rootProgram() xdp mode = %s error = %v, want the root of the smartnic flavor offloaded", prog.XDPMode, err)
+	}
+}
+
+func Test_resolveXDPMode(t *testing.T) {
+	savedDriver := ifaceDriverName
+	t.Cleanup(func() {
+		ifaceDriverName = savedDriver
+		setXDPOffload(nil)
+	})
+	drivers := map[string]string{"nic0": "nfp", "nic1": "ixgbe", "nic2": "r8169", "nic3": "sfc"}
+	ifaceDriverName = func(ifaceName string) (string, error) { return drivers[ifaceName], nil }
+	setXDPOffload(&config.Config{XDPOffloadDrivers: []string{" sfc"}, XDPOffloadFallback: XDPModeNative})
+
+	tests := []struct {
+		name    string
+		prog    models.BPFProgram
+		iface   string
+		want    string
+		wantErr bool
+	}{
+		{"native", models.BPFProgram{XDPMode: XDPModeNative}, "nic2", XDPModeNative, false},
+		{"offload", models.BPFProgram{XDPMode: XDPModeOffload}, "nic0", XDPModeOffload, false},
+		{"offload driver of the config", models.BPFProgram{XDPMode: XDPModeOffload}, "nic3", XDPModeOffload, false},
+		{"native fallback", models.BPFProgram{XDPMode: XDPModeOffload}, "nic1", XDPModeNative, false},
+		{"native fallback without native xdp", models.BPFProgram{XDPMode: XDPModeOffload}, "nic2", XDPModeGeneric, false},
+		{"generic fallback", models.BPFProgram{XDPMode: XDPModeOffload, OffloadFallback: XDPModeGeneric}, "nic1", XDPModeGeneric, false},
+		{"fail", models.BPFProgram{XDPMode: XDPModeOffload, OffloadFallback: OffloadFallbackFail}, "nic1", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveXDPMode(&tt.prog, tt.iface)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveXDPMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveXDPMode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	setXDPOffload(&config.Config{XDPOffloadFallback: OffloadFallbackFail})
+	if _, err := resolveXDPMode(&models.BPFProgram{XDPMode: XDPModeOffload}, "nic3"); err == nil {
+		t.Errorf("resolveXDPMode() error = nil, want the fail fallback of l3afd.cfg")
+	}
+	if got := xdpModes("sfc"); !reflect.DeepEqual(got, []string{XDPModeGeneric, XDPModeNative}) {
+		t.Errorf("xdpModes() = %v, want the offload driver of the config reset", got)
+	}
+}
+
+func Test_implicitArgFlagsXDPMode(t *testing.T) {
+	flags := implicitArgFlags(models.L3afDNFImplicitArgs{
+		SchemaVersion: ArgSchemaV1,
+		Iface:         "nic0",
+		Direction:     models.XDPIngressType,
+		XDPMode:       XDPModeOffload,
+		Ifindex:       4,
+	})
+	want := []string{"--iface=nic0", "--direction=xdpingress", "--xdp-mode=offload", "--ifindex=4"}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("implicitArgFlags() = %v, want %v", flags, want)
+	}
+}
+
+func Test_parseXDPAttachment(t *testing.T) {
+	attr := func(typ uint16, value []byte) []byte {
+		b := make([]byte, 4, 8)
+		nativeEndian.PutUint16(b[0:2], uint16(4+len(value)))
+		nativeEndian.PutUint16(b[2:4], typ)
+		b = append(b, value...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	id := func(v uint32) []byte {
+		b := make([]byte, 4)
+		nativeEndian.PutUint32(b, v)
+		return b
+	}
+	var raw []byte
+	raw = append(raw, attr(2, []byte{4})...) // IFLA_XDP_ATTACHED multi
+	raw = append(raw, attr(iflaXDPHwProgID, id(31))...)
+	raw = append(raw, attr(iflaXDPSkbProgID, id(12))...)
+
+	attached := parseXDPAttachment(raw)
+	if !reflect.DeepEqual(attached, map[string]int{XDPModeOffload: 31, XDPModeGeneric: 12}) {
+		t.Errorf("parseXDPAttachment() = %v, want the offloaded and the generic programs", attached)
+	}
+	if got := attachedModes(attached); !reflect.DeepEqual(got, []string{XDPModeGeneric, XDPModeOffload}) {
+		t.Errorf("attachedModes() = %v", got)
+	}
+	if got := parseXDPAttachment(raw[:6]); len(got) != 0 {
+		t.Errorf("parseXDPAttachment() = %v, want the truncated attribute ignored", got)
+	}
+
+	// chained program runs in the mode of the root, unreported program in its resolved mode
+	if got := xdpRunMode(attached, 31, 31, XDPModeOffload); got != XDPModeOffload {
+		t.Errorf("xdpRunMode() = %s, want offload of the attached program", got)
+	}
+	if got := xdpRunMode(attached, 40, 12, XDPModeOffload); got != XDPModeGeneric {
+		t.Errorf("xdpRunMode() = %s, want generic of the root", got)
+	}
+	if got := xdpRunMode(nil, 40, 0, XDPModeNative); got != XDPModeNative {
+		t.Errorf("xdpRunMode() = %s, want the resolved mode", got)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+// pinnedObjectFile - opens the pinned map or program as a file to pass its fd to the user program, l3afd
+// holds the privileges to open the pins so the user program does not need them
+var pinnedObjectFile = func(kind, pinPath string) (*os.File, error) {
+	var pinnedFD int
+	switch kind {
+	case models.PassFDProgram:
+		prog, err := ebpf.LoadPinnedProgram(pinPath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to access pinned program %s: %w", pinPath, err)
+		}
+		defer prog.Close()
+		pinnedFD = prog.FD()
+	default:
+		m, err := ebpf.LoadPinnedMap(pinPath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to access pinned map %s: %w", pinPath, err)
+		}
+		defer m.Close()
+		pinnedFD = m.FD()
+	}
+	fd, err := dupFD(pinnedFD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to duplicate fd of %s: %w", pinPath, err)
+	}
+	return os.NewFile(uintptr(fd), pinPath), nil
+}
+
+// validatePassFDs - verifies the pinned maps and programs passed to the user program, the names are start
+// args so they must not clash with the start args of the program or the args set by l3afd
+func validatePassFDs(prog *models.BPFProgram) error {
+	names := make(map[string]bool, len(prog.PassFDs))
+	for _, p := range prog.PassFDs {
+		if !argNameRegexp.MatchString(p.Name) {
+			return fmt.Errorf("pass_fds name %q is invalid", p.Name)
+		}
+		if reservedArgs[p.Name] || names[p.Name] {
+			return fmt.Errorf("pass_fds name %s is already used", p.Name)
+		}
+		if _, ok := prog.StartArgs[p.Name]; ok {
+			return fmt.Errorf("pass_fds name %s is also a start arg", p.Name)
+		}
+		names[p.Name] = true
+		if p.Type != models.PassFDMap && p.Type != models.PassFDProgram {
+			return fmt.Errorf("pass_fds %s type %q is not map or program", p.Name, p.Type)
+		}
+		if !filepath.IsAbs(p.PinPath) || filepath.Clean(p.PinPath) != p.PinPath {
+			return fmt.Errorf("pass_fds %s pin path %q is not a clean absolute path", p.Name, p.PinPath)
+		}
+	}
+	return nil
+}
+
+// openPassedFDs - opens the pinned maps and programs inherited by the user program, the fd numbers start at
+// firstFD and are passed as the --<name>=<fd> start args
+func openPassedFDs(passFDs []models.L3afDNFPassedFD, firstFD int) ([]string, []*os.File, error) {
+	args := make([]string, 0, len(passFDs))
+	files := make([]*os.File, 0, len(passFDs))
+	for i, p := range passFDs {
+		f, err := pinnedObjectFile(p.Type, p.PinPath)
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, fmt.Errorf("failed to open %s %s: %w", p.Type, p.Name, err)
+		}
+		files = append(files, f)
+		args = append(args, "--"+p.Name+"="+strconv.Itoa(firstFD+i))
+	}
+	return args, files, nil
+}
+
+// closeFiles - closes the copies of the fds inherited by the user program
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_validatePassFDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		passFDs []models.L3afDNFPassedFD
+		wantErr bool
+	}{
+		{"map and program", []models.L3afDNFPassedFD{
+			{Name: "counters-fd", Type: models.PassFDMap, PinPath: "/sys/fs/bpf/counters"},
+			{Name: "tail-fd", Type: models.PassFDProgram, PinPath: "/sys/fs/bpf/tail"},
+		}, false},
+		{"invalid name", []models.L3afDNFPassedFD{{Name: "-fd", Type: models.PassFDMap, PinPath: "/sys/fs/bpf/counters"}}, true},
+		{"reserved name", []models.L3afDNFPassedFD{{Name: "map-name", Type: models.PassFDMap, PinPath: "/sys/fs/bpf/counters"}}, true},
+		{"start arg", []models.L3afDNFPassedFD{{Name: "cmd", Type: models.PassFDMap, PinPath: "/sys/fs/bpf/counters"}}, true},
+		{"duplicate", []models.L3afDNFPassedFD{
+			{Name: "counters-fd", Type: models.PassFDMap, PinPath: "/sys/fs/bpf/counters"},
+			{Name: "counters-fd", Type: models.PassFDMap, PinPath: "/sys/fs/bpf/counters2"},
+		}, true},
+		{"unknown type", []models.L3afDNFPassedFD{{Name: "link-fd", Type: "link", PinPath: "/sys/fs/bpf/link"}}, true},
+		{"relative pin path", []models.L3afDNFPassedFD{{Name: "counters-fd", Type: models.PassFDMap, PinPath: "counters"}}, true},
+		{"unclean pin path", []models.L3afDNFPassedFD{{Name: "counters-fd", Type: models.PassFDMap, PinPath: "/sys/fs/bpf/../counters"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := &models.BPFProgram{Name: "ratelimiting", StartArgs: models.L3afDNFArgs{"cmd": "start"}, PassFDs: tt.passFDs}
+			if err := validatePassFDs(prog); (err != nil) != tt.wantErr {
+				t.Errorf("validatePassFDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_openPassedFDs(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	savedPinnedObjectFile := pinnedObjectFile
+	pinnedObjectFile = func(kind, pinPath string) (*os.File, error) { return os.Open(pinPath) }
+	defer func() { pinnedObjectFile = savedPinnedObjectFile }()
+
+	passFDs := []models.L3afDNFPassedFD{
+		{Name: "counters-fd", Type: models.PassFDMap, PinPath: filepath.Join(dir, "counters")},
+		{Name: "tail-fd", Type: models.PassFDProgram, PinPath: filepath.Join(dir, "tail")},
+	}
+	for _, p := range passFDs {
+		if err := os.WriteFile(p.PinPath, []byte(p.Type), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// fds follow the xsk map fd of the AF_XDP programs
+	args, files, err := openPassedFDs(passFDs, xskMapFD+1)
+	if err != nil {
+		t.Fatalf("openPassedFDs() error = %v", err)
+	}
+	defer closeFiles(files)
+	if want := []string{"--counters-fd=4", "--tail-fd=5"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("openPassedFDs() args = %v, want %v", args, want)
+	}
+
+	xsk, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xsk.Close()
+	cmd := exec.Command("/bin/sh", "-c", "cat <&4; cat <&5")
+	cmd.ExtraFiles = append([]*os.File{xsk}, files...)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "mapprogram" {
+		t.Errorf("inherited fds read %q, want the map then the program", out)
+	}
+
+	passFDs = append(passFDs, models.L3afDNFPassedFD{Name: "missing-fd", Type: models.PassFDMap, PinPath: filepath.Join(dir, "missing")})
+	if _, _, err := openPassedFDs(passFDs, xskMapFD); err == nil {
+		t.Errorf("openPassedFDs() error = nil, want missing pin")
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pausedRegistry - programs detached from the chain and stopped by the admin API. Paused programs stay in the
+// config and config applies keep them paused until they are resumed.
+type pausedRegistry struct {
+	mu       sync.Mutex
+	programs map[models.L3afDPausedProgram]*models.BPFProgram // config of the program, nil until the config is applied after restart
+}
+
+var pausedPrograms = &pausedRegistry{programs: make(map[models.L3afDPausedProgram]*models.BPFProgram)}
+
+func (r *pausedRegistry) get(key models.L3afDPausedProgram) (*models.BPFProgram, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prog, ok := r.programs[key]
+	return prog, ok
+}
+
+func (r *pausedRegistry) set(key models.L3afDPausedProgram, prog *models.BPFProgram) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.programs[key] = prog
+}
+
+func (r *pausedRegistry) delete(key models.L3afDPausedProgram) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.programs, key)
+}
+
+// list - paused programs sorted by interface, direction and name
+func (r *pausedRegistry) list() []models.L3afDPausedProgram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]models.L3afDPausedProgram, 0, len(r.programs))
+	for key := range r.programs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Iface != keys[j].Iface {
+			return keys[i].Iface < keys[j].Iface
+		}
+		if keys[i].Direction != keys[j].Direction {
+			return keys[i].Direction < keys[j].Direction
+		}
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// PauseBPFProgram - detaches the program from the chain by linking its neighbours and stops its process,
+// the program is kept in the config and stays paused until it is resumed
+func (c *NFConfigs) PauseBPFProgram(iface, direction, name, remote string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := models.L3afDPausedProgram{Iface: iface, Direction: direction, Name: name}
+	if _, ok := pausedPrograms.get(key); ok {
+		return fmt.Errorf("program %s is already paused on iface %s direction %s", name, iface, direction)
+	}
+
+	bpfLists, err := c.bpfLists(direction)
+	if err != nil {
+		return err
+	}
+	bpfList := bpfLists[iface]
+	var e *list.Element
+	if bpfList != nil {
+		for e = bpfList.Front(); e != nil; e = e.Next() {
+			if e.Value.(*BPF).Program.Name == name {
+				break
+			}
+		}
+	}
+	if e == nil {
+		return fmt.Errorf("program %s is not running on iface %s direction %s", name, iface, direction)
+	}
+	if c.hostConfig.BpfChainingEnabled && e == bpfList.Front() {
+		return fmt.Errorf("root program %s can't be paused", name)
+	}
+
+	bpf := e.Value.(*BPF)
+	if err := bpf.Stop(iface, direction, c.hostConfig.BpfChainingEnabled); err != nil {
+		return fmt.Errorf("failed to stop program %s iface %s direction %s: %w", name, iface, direction, err)
+	}
+
+	// bypassed and standby programs are not linked into the chain
+	prev, next := chainNeighbours(e)
+	linked := !bpf.bypassed()
+	bpfList.Remove(e)
+	switch {
+	case bpfList.Len() == 0:
+		// chaining is disabled, root program is kept running otherwise
+		bpfLists[iface] = nil
+	case !linked:
+	case prev != nil && next != nil:
+		if err := c.LinkBPFPrograms(prev.Value.(*BPF), next.Value.(*BPF)); err != nil {
+			return fmt.Errorf("failed to link the neighbours of paused program %s: %w", name, err)
+		}
+	case prev != nil:
+		if err := prev.Value.(*BPF).RemoveNextProgFD(); err != nil {
+			return fmt.Errorf("failed to unlink paused program %s: %w", name, err)
+		}
+	}
+
+	pausedPrograms.set(key, bpf.configProgram())
+	c.savePausedPrograms()
+	log.Warn().Msgf("program %s paused on iface %s direction %s", name, iface, direction)
+	c.Audit("nf-pause", remote, map[string]string{"iface": iface, "direction": direction, "program": name})
+	return nil
+}
+
+// ResumeBPFProgram - starts the paused program and splices it into the chain at its sequence position
+func (c *NFConfigs) ResumeBPFProgram(iface, direction, name, remote string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := models.L3afDPausedProgram{Iface: iface, Direction: direction, Name: name}
+	prog, ok := pausedPrograms.get(key)
+	if !ok {
+		return fmt.Errorf("program %s is not paused on iface %s direction %s", name, iface, direction)
+	}
+	if prog == nil {
+		return fmt.Errorf("config of paused program %s is not applied on iface %s direction %s", name, iface, direction)
+	}
+
+	pausedPrograms.delete(key)
+	c.savePausedPrograms()
+	c.Audit("nf-resume", remote, map[string]string{"iface": iface, "direction": direction, "program": name})
+	if err := c.deployBPFProgram(prog, iface, direction); err != nil {
+		return fmt.Errorf("failed to resume program %s: %w", name, err)
+	}
+	log.Info().Msgf("program %s resumed on iface %s direction %s", name, iface, direction)
+	return nil
+}
+
+// PausedPrograms - returns the paused programs
+func (c *NFConfigs) PausedPrograms() []models.L3afDPausedProgram {
+	return pausedPrograms.list()
+}
+
+// deployPausedBPFProgram - keeps the paused program paused on config apply, the config of the program is
+// updated and it is applied when the program is resumed. Disabled program is not paused anymore.
+func deployPausedBPFProgram(bpfProg *models.BPFProgram, iface, direction string) bool {
+	key := models.L3afDPausedProgram{Iface: iface, Direction: direction, Name: bpfProg.Name}
+	if _, ok := pausedPrograms.get(key); !ok {
+		return false
+	}
+	if !loadedStatus(bpfProg.AdminStatus) {
+		log.Info().Msgf("paused program %s is disabled on iface %s direction %s", bpfProg.Name, iface, direction)
+		pausedPrograms.delete(key)
+		return true
+	}
+	prog := *bpfProg
+	pausedPrograms.set(key, &prog)
+	log.Info().Msgf("program %s is paused on iface %s direction %s, config is applied on resume", bpfProg.Name, iface, direction)
+	return true
+}
+
+// removeMissingPausedPrograms - paused programs missing in the config are not paused anymore
+func (c *NFConfigs) removeMissingPausedPrograms(bpfProgCfgs []models.L3afBPFPrograms) {
+	inConfig := make(map[models.L3afDPausedProgram]bool)
+	for _, cfg := range bpfProgCfgs {
+		if cfg.BpfPrograms == nil {
+			continue
+		}
+		directions := map[string][]*models.BPFProgram{
+			models.XDPIngressType: cfg.BpfPrograms.XDPIngress,
+			models.IngressType:    cfg.BpfPrograms.TCIngress,
+			models.EgressType:     cfg.BpfPrograms.TCEgress,
+		}
+		for direction, progs := range directions {
+			for _, prog := range progs {
+				if prog != nil {
+					inConfig[models.L3afDPausedProgram{Iface: cfg.Iface, Direction: direction, Name: prog.Name}] = true
+				}
+			}
+		}
+	}
+
+	removed := false
+	for _, key := range pausedPrograms.list() {
+		if !inConfig[key] {
+			log.Info().Msgf("paused program %s not found in config on iface %s direction %s", key.Name, key.Iface, key.Direction)
+			pausedPrograms.delete(key)
+			removed = true
+		}
+	}
+	if removed {
+		c.savePausedPrograms()
+	}
+}
+
+// pausedBPFPrograms - configs of the paused programs of the interface in the direction
+func pausedBPFPrograms(iface, direction string) []*models.BPFProgram {
+	pausedPrograms.mu.Lock()
+	defer pausedPrograms.mu.Unlock()
+	var progs []*models.BPFProgram
+	for key, prog := range pausedPrograms.programs {
+		if key.Iface == iface && key.Direction == direction && prog != nil {
+			progs = append(progs, prog)
+		}
+	}
+	sort.Slice(progs, func(i, j int) bool { return progs[i].SeqID < progs[j].SeqID })
+	return progs
+}
+
+// savePausedPrograms - writes the paused programs to the persistent store
+func (c *NFConfigs) savePausedPrograms() {
+	if len(c.hostConfig.PausedProgramsFileName) == 0 {
+		return
+	}
+	buf, err := json.Marshal(pausedPrograms.list())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal paused programs")
+		return
+	}
+	if err := writeStateFile(c.hostConfig.PausedProgramsFileName, buf, 0600); err != nil {
+		log.Error().Err(err).Msgf("failed to write paused programs to %s", c.hostConfig.PausedProgramsFileName)
+	}
+}
+
+// loadPausedPrograms - reads the paused programs of the persistent store, programs are kept paused when
+// the configs are applied at startup
+func (c *NFConfigs) loadPausedPrograms() {
+	if c.hostConfig == nil || len(c.hostConfig.PausedProgramsFileName) == 0 {
+		return
+	}
+	buf, err := ReadStateFile(c.hostConfig.PausedProgramsFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msgf("failed to read paused programs from %s", c.hostConfig.PausedProgramsFileName)
+		}
+		return
+	}
+	var keys []models.L3afDPausedProgram
+	if err := json.Unmarshal(buf, &keys); err != nil {
+		log.Error().Err(err).Msgf("failed to unmarshal paused programs of %s", c.hostConfig.PausedProgramsFileName)
+		return
+	}
+	for _, key := range keys {
+		if _, ok := pausedPrograms.get(key); !ok {
+			pausedPrograms.set(key, nil)
+		}
+	}
+}
+
+// bpfLists - program lists of the interfaces in the direction
+func (c *NFConfigs) bpfLists(direction string) (map[string]*list.List, error) {
+	switch direction {
+	case models.XDPIngressType:
+		return c.IngressXDPBpfs, nil
+	case models.IngressType:
+		return c.IngressTCBpfs, nil
+	case models.EgressType:
+		return c.EgressTCBpfs, nil
+	}
+	return nil, fmt.Errorf("unknown direction %s", direction)
+}
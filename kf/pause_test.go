@@ -0,0 +1,90 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func usePausedPrograms(t *testing.T, keys ...models.L3afDPausedProgram) {
+	saved := pausedPrograms
+	pausedPrograms = &pausedRegistry{programs: make(map[models.L3afDPausedProgram]*models.BPFProgram)}
+	for _, key := range keys {
+		pausedPrograms.set(key, &models.BPFProgram{Name: key.Name, AdminStatus: models.Enabled})
+	}
+	t.Cleanup(func() { pausedPrograms = saved })
+}
+
+func Test_deployPausedBPFProgram(t *testing.T) {
+	paused := models.L3afDPausedProgram{Iface: "eth0", Direction: models.XDPIngressType, Name: "ratelimiting"}
+	tests := []struct {
+		name       string
+		prog       *models.BPFProgram
+		want       bool
+		wantPaused bool
+	}{
+		{
+			name:       "StaysPaused",
+			prog:       &models.BPFProgram{Name: "ratelimiting", Version: "2.0", AdminStatus: models.Enabled},
+			want:       true,
+			wantPaused: true,
+		},
+		{
+			name:       "Disabled",
+			prog:       &models.BPFProgram{Name: "ratelimiting", AdminStatus: models.Disabled},
+			want:       true,
+			wantPaused: false,
+		},
+		{
+			name:       "NotPaused",
+			prog:       &models.BPFProgram{Name: "connection-limit", AdminStatus: models.Enabled},
+			want:       false,
+			wantPaused: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePausedPrograms(t, paused)
+			if got := deployPausedBPFProgram(tt.prog, "eth0", models.XDPIngressType); got != tt.want {
+				t.Errorf("deployPausedBPFProgram() = %v, want %v", got, tt.want)
+			}
+			prog, ok := pausedPrograms.get(paused)
+			if ok != tt.wantPaused {
+				t.Errorf("deployPausedBPFProgram() paused = %v, want %v", ok, tt.wantPaused)
+			}
+			if ok && tt.want && prog.Version != tt.prog.Version {
+				t.Errorf("deployPausedBPFProgram() config version = %s, want %s", prog.Version, tt.prog.Version)
+			}
+		})
+	}
+}
+
+func TestNFConfigs_removeMissingPausedPrograms(t *testing.T) {
+	rl := models.L3afDPausedProgram{Iface: "eth0", Direction: models.XDPIngressType, Name: "ratelimiting"}
+	cl := models.L3afDPausedProgram{Iface: "eth0", Direction: models.IngressType, Name: "connection-limit"}
+	usePausedPrograms(t, rl, cl)
+	useMemFS(t, nil)
+	c := &NFConfigs{hostConfig: &config.Config{PausedProgramsFileName: "/etc/l3afd/l3af-paused.json"}}
+
+	c.removeMissingPausedPrograms([]models.L3afBPFPrograms{
+		{Iface: "eth0", BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{{Name: "ratelimiting"}}}},
+	})
+	if got, want := c.PausedPrograms(), []models.L3afDPausedProgram{rl}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PausedPrograms() = %v, want %v", got, want)
+	}
+
+	// paused programs are loaded from the store without config
+	usePausedPrograms(t)
+	c.loadPausedPrograms()
+	if prog, ok := pausedPrograms.get(rl); !ok || prog != nil {
+		t.Errorf("loadPausedPrograms() = %v, %v, want nil, true", prog, ok)
+	}
+	if got := pausedBPFPrograms("eth0", models.XDPIngressType); len(got) != 0 {
+		t.Errorf("pausedBPFPrograms() = %v, want none before the config is applied", got)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 65535
+	pcapLinkTypeEth  = 1
+)
+
+// pcapWriter writes packets in the libpcap file format
+type pcapWriter struct {
+	w io.Writer
+}
+
+// newPcapWriter - writes the pcap global header and returns the writer
+func newPcapWriter(w io.Writer) (*pcapWriter, error) {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(hdr[6:8], pcapVersionMinor)
+	binary.LittleEndian.PutUint32(hdr[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:24], pcapLinkTypeEth)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &pcapWriter{w: w}, nil
+}
+
+// writePacket - writes the packet record, packets longer than the snap length are truncated
+func (p *pcapWriter) writePacket(ts time.Time, data []byte) error {
+	origLen := len(data)
+	if len(data) > pcapSnapLen {
+		data = data[:pcapSnapLen]
+	}
+	hdr := make([]byte, 16)
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(hdr[12:16], uint32(origLen))
+	if _, err := p.w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := p.w.Write(data)
+	return err
+}
@@ -0,0 +1,252 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Peering roles of the active/standby pair
+const (
+	PeerRoleActive  = "active"
+	PeerRoleStandby = "standby"
+)
+
+// peering - state of the standby node following the configs applied on the active peer
+type peering struct {
+	mu          sync.Mutex
+	role        string
+	client      *http.Client
+	peerConfigs []models.L3afBPFPrograms // last configs of the active peer
+	prepared    bool                     // artifacts of the peer configs are downloaded and validated
+	lastSeen    time.Time
+	lastErr     error
+}
+
+// StartPeering - starts following the active peer when the node is the standby of the pair. Standby downloads
+// the artifacts and validates the configs applied on the active peer, so the configs are deployed in seconds
+// at the activation.
+func (c *NFConfigs) StartPeering(ctx context.Context) error {
+	conf := c.hostConfig
+	if !conf.PeeringEnabled {
+		return nil
+	}
+
+	switch conf.PeeringRole {
+	case PeerRoleActive, PeerRoleStandby:
+	default:
+		return fmt.Errorf("unknown peering role %s, supported roles are %s and %s", conf.PeeringRole, PeerRoleActive, PeerRoleStandby)
+	}
+
+	p := &peering{role: conf.PeeringRole, lastSeen: time.Now()}
+	c.peer = p
+	if p.role == PeerRoleActive {
+		log.Info().Msg("peering role is active, configs are served to the standby peer")
+		return nil
+	}
+
+	if len(conf.PeeringPeerURL) == 0 {
+		return fmt.Errorf("peer url is required for the standby role")
+	}
+
+	client, err := newPeerClient(conf)
+	if err != nil {
+		return err
+	}
+	p.client = client
+
+	log.Info().Msgf("peering role is standby, following active peer %s", conf.PeeringPeerURL)
+	go c.followPeer(ctx)
+	return nil
+}
+
+// newPeerClient - http client of the l3afd API of the peer nodes, the node certificate is used as the client
+// certificate with mTLS
+func newPeerClient(conf *config.Config) (*http.Client, error) {
+	timeOut := time.Duration(conf.HttpClientTimeout) * time.Second
+	transport := &http.Transport{ResponseHeaderTimeout: timeOut}
+	if conf.MTLSEnabled {
+		// node certificate is used as the client certificate of the peer config API
+		caCert, err := os.ReadFile(path.Join(conf.MTLSCertDir, conf.MTLSCACertFilename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read peer CA: %w", err)
+		}
+		caCertPool := x509.NewCertPool()
+		caCertPool.AppendCertsFromPEM(caCert)
+		cert, err := tls.LoadX509KeyPair(path.Join(conf.MTLSCertDir, conf.MTLSServerCertFilename), path.Join(conf.MTLSCertDir, conf.MTLSServerKeyFilename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load peer client certificate: %w", err)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:      caCertPool,
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   conf.MTLSMinVersion,
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: timeOut}, nil
+}
+
+// followPeer - polls the configs of the active peer until the standby is activated
+func (c *NFConfigs) followPeer(ctx context.Context) {
+	ticker := time.NewTicker(c.hostConfig.PeeringPollInterval)
+	defer ticker.Stop()
+	for {
+		if c.syncPeer(ctx) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncPeer - fetches and prepares the configs of the active peer, activates the standby when the peer is
+// unreachable for the failover timeout. Returns true when the standby is activated.
+func (c *NFConfigs) syncPeer(ctx context.Context) bool {
+	p := c.peer
+	bpfProgs, err := c.fetchPeerConfigs(ctx)
+
+	p.mu.Lock()
+	if p.role != PeerRoleStandby {
+		p.mu.Unlock()
+		return true
+	}
+	if err != nil {
+		p.lastErr = err
+		down := time.Since(p.lastSeen)
+		p.mu.Unlock()
+		log.Warn().Err(err).Msgf("active peer is unreachable for %s", down.Round(time.Second))
+
+		if c.hostConfig.PeeringFailoverTimeout > 0 && down > c.hostConfig.PeeringFailoverTimeout {
+			log.Warn().Msgf("active peer is down for more than failover timeout %s, activating", c.hostConfig.PeeringFailoverTimeout)
+			if err := c.ActivatePeering(); err != nil {
+				log.Error().Err(err).Msg("standby activation failed")
+				return false
+			}
+			return true
+		}
+		return false
+	}
+	p.lastSeen = time.Now()
+	p.lastErr = nil
+	changed := !reflect.DeepEqual(bpfProgs, p.peerConfigs)
+	p.mu.Unlock()
+
+	if !changed {
+		return false
+	}
+
+	log.Info().Msgf("active peer configs changed, preparing %d interfaces", len(bpfProgs))
+	prepErr := c.PrepareBPFPrograms(bpfProgs)
+	if prepErr != nil {
+		log.Error().Err(prepErr).Msg("failed to prepare the active peer configs")
+	}
+
+	p.mu.Lock()
+	p.peerConfigs = bpfProgs
+	p.prepared = prepErr == nil
+	p.lastErr = prepErr
+	p.mu.Unlock()
+	return false
+}
+
+// fetchPeerConfigs - returns the configs applied on the active peer
+func (c *NFConfigs) fetchPeerConfigs(ctx context.Context) ([]models.L3afBPFPrograms, error) {
+	url := strings.TrimSuffix(c.hostConfig.PeeringPeerURL, "/") + "/l3af/configs/v1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.peer.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get request returned unexpected status code: %d (%s), %d was expected", resp.StatusCode, http.StatusText(resp.StatusCode), http.StatusOK)
+	}
+
+	var bpfProgs []models.L3afBPFPrograms
+	if err := json.NewDecoder(resp.Body).Decode(&bpfProgs); err != nil {
+		return nil, fmt.Errorf("failed to decode peer configs: %w", err)
+	}
+	for i := range bpfProgs {
+		bpfProgs[i].HostName = c.hostName
+	}
+	return bpfProgs, nil
+}
+
+// ActivatePeering - activates the standby, the last configs of the active peer are deployed
+func (c *NFConfigs) ActivatePeering() error {
+	p := c.peer
+	if p == nil {
+		return fmt.Errorf("peering is not enabled")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.role != PeerRoleStandby {
+		return fmt.Errorf("peering role is %s, only standby is activated", p.role)
+	}
+	if p.peerConfigs == nil {
+		return fmt.Errorf("configs of the active peer are not known yet")
+	}
+
+	start := time.Now()
+	if err := c.DeployeBPFPrograms(p.peerConfigs); err != nil {
+		p.lastErr = err
+		return fmt.Errorf("failed to deploy the active peer configs: %w", err)
+	}
+	p.role = PeerRoleActive
+	p.lastErr = nil
+	log.Info().Msgf("standby activated in %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// PeeringStatus - returns the peering state of the node
+func (c *NFConfigs) PeeringStatus() models.L3afDPeeringStatus {
+	p := c.peer
+	if p == nil {
+		return models.L3afDPeeringStatus{}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := models.L3afDPeeringStatus{
+		Enabled:  true,
+		Role:     p.role,
+		PeerURL:  c.hostConfig.PeeringPeerURL,
+		Prepared: p.prepared,
+	}
+	if p.role == PeerRoleStandby {
+		status.LastSeen = p.lastSeen.UTC().Format(time.RFC3339)
+	}
+	for _, bpfProg := range p.peerConfigs {
+		if bpfProg.BpfPrograms != nil {
+			status.PeerPrograms += len(bpfProg.BpfPrograms.XDPIngress) + len(bpfProg.BpfPrograms.TCIngress) + len(bpfProg.BpfPrograms.TCEgress)
+		}
+	}
+	if p.lastErr != nil {
+		status.Error = p.lastErr.Error()
+	}
+	return status
+}
@@ -0,0 +1,235 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrPinPathConflict is returned when a pin path is declared by more than one program or pinned by a
+// program unknown to l3afd
+var ErrPinPathConflict = errors.New("pin path conflict")
+
+// pinOwner - program instance owning the pin path
+type pinOwner struct {
+	iface     string
+	direction string
+	program   string
+}
+
+func (o pinOwner) String() string {
+	return fmt.Sprintf("program %s iface %s direction %s", o.program, o.iface, o.direction)
+}
+
+// pinRegistry - owners of the pin paths of the started programs
+type pinRegistry struct {
+	mu     sync.Mutex
+	owners map[string]pinOwner
+}
+
+var pinOwners = &pinRegistry{owners: make(map[string]pinOwner)}
+
+func (r *pinRegistry) get(path string) (pinOwner, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owner, ok := r.owners[path]
+	return owner, ok
+}
+
+func (r *pinRegistry) claim(paths []string, owner pinOwner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, path := range paths {
+		r.owners[path] = owner
+	}
+}
+
+// release - drops the owner of the vanished pins, pins left behind by the program stay owned by it
+func (r *pinRegistry) release(owner pinOwner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for path, o := range r.owners {
+		if o != owner {
+			continue
+		}
+		if _, err := appFS.Stat(path); os.IsNotExist(err) {
+			delete(r.owners, path)
+		}
+	}
+}
+
+// programPinPaths - pin paths declared by the program, the chaining maps of the blue/green slots, the shared
+// maps and the re-pinned consumed maps
+func programPinPaths(prog *models.BPFProgram) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(path string) {
+		if len(path) > 0 && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	add(prog.MapName)
+	if prog.BlueGreen != nil {
+		add(prog.BlueGreen.MapName)
+	}
+	for _, path := range prog.SharedMaps {
+		add(path)
+	}
+	for _, cm := range prog.ConsumedMaps {
+		add(cm.PinPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// configProgramRefs - programs of the interface config in all the directions
+func configProgramRefs(bpfProgs *models.BPFPrograms) []bpfProgramRef {
+	if bpfProgs == nil {
+		return nil
+	}
+	var refs []bpfProgramRef
+	for _, prog := range bpfProgs.XDPIngress {
+		refs = append(refs, bpfProgramRef{direction: models.XDPIngressType, prog: prog})
+	}
+	for _, prog := range bpfProgs.TCIngress {
+		refs = append(refs, bpfProgramRef{direction: models.IngressType, prog: prog})
+	}
+	for _, prog := range bpfProgs.TCEgress {
+		refs = append(refs, bpfProgramRef{direction: models.EgressType, prog: prog})
+	}
+	return refs
+}
+
+// sharedPin - program declares the pin path along with other programs
+func sharedPin(prog *models.BPFProgram, path string) bool {
+	for _, p := range prog.SharedPins {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePinPaths - Verifies no two enabled programs declare the same pin path unless both mark it shared,
+// and no program declares the pin path of a root program. This is checked before applying any change.
+func (c *NFConfigs) ValidatePinPaths(bpfProgs []models.L3afBPFPrograms) error {
+	type declaration struct {
+		owner pinOwner
+		prog  *models.BPFProgram
+	}
+	declared := make(map[string][]declaration)
+	for _, cfg := range bpfProgs {
+		refs := configProgramRefs(cfg.BpfPrograms)
+		for _, ref := range refs {
+			if ref.prog == nil || !loadedStatus(ref.prog.AdminStatus) {
+				continue
+			}
+			owner := pinOwner{iface: cfg.Iface, direction: ref.direction, program: ref.prog.Name}
+			for _, path := range programPinPaths(ref.prog) {
+				declared[path] = append(declared[path], declaration{owner: owner, prog: ref.prog})
+			}
+		}
+	}
+
+	roots := map[string]bool{}
+	if c.hostConfig.BpfChainingEnabled {
+		for _, mapName := range rootMapNames(c.hostConfig) {
+			roots[mapName] = true
+		}
+	}
+
+	paths := make([]string, 0, len(declared))
+	for path := range declared {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		decls := declared[path]
+		if roots[path] {
+			return fmt.Errorf("%w: %s declares %s of the root program", ErrPinPathConflict, decls[0].owner, path)
+		}
+		if len(decls) < 2 {
+			continue
+		}
+		for _, d := range decls {
+			if !sharedPin(d.prog, path) {
+				return fmt.Errorf("%w: %s and %s declare %s, mark it in shared_pins of both programs to share it",
+					ErrPinPathConflict, decls[0].owner, decls[1].owner, path)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyPinPaths - Verifies the pin paths of the program are not owned by another program and not pinned by
+// a program unknown to l3afd, before the program is started
+func (b *BPF) verifyPinPaths(ifaceName, direction string) error {
+	owner := pinOwner{iface: ifaceName, direction: direction, program: b.Program.Name}
+	for _, path := range programPinPaths(&b.Program) {
+		if sharedPin(&b.Program, path) {
+			continue
+		}
+		if o, ok := pinOwners.get(path); ok {
+			if o != owner {
+				return fmt.Errorf("%w: %s is pinned by %s", ErrPinPathConflict, path, o)
+			}
+			continue
+		}
+		if _, err := appFS.Stat(path); err == nil {
+			return fmt.Errorf("%w: %s is pinned by a program unknown to l3afd", ErrPinPathConflict, path)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to verify pin path %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// claimPinPaths - records the program as the owner of its pin paths
+func (b *BPF) claimPinPaths(ifaceName, direction string) {
+	pinOwners.claim(programPinPaths(&b.Program), pinOwner{iface: ifaceName, direction: direction, program: b.Program.Name})
+}
+
+// releasePinPaths - program doesn't own its vanished pin paths anymore
+func (b *BPF) releasePinPaths(ifaceName, direction string) {
+	pinOwners.release(pinOwner{iface: ifaceName, direction: direction, program: b.Program.Name})
+}
+
+// loadPinOwners - programs of the config store own their pin paths, so pins left by the programs before
+// l3afd restarted are not considered foreign
+func (c *NFConfigs) loadPinOwners() {
+	if c.hostConfig == nil || len(c.hostConfig.L3afConfigStoreFileName) == 0 {
+		return
+	}
+	data, err := ReadStateFile(c.hostConfig.L3afConfigStoreFileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msg("failed to read the config store for the pin owners")
+		}
+		return
+	}
+	var bpfProgs []models.L3afBPFPrograms
+	if err := json.Unmarshal(data, &bpfProgs); err != nil {
+		log.Warn().Err(err).Msg("failed to unmarshal the config store for the pin owners")
+		return
+	}
+	for _, cfg := range bpfProgs {
+		refs := configProgramRefs(cfg.BpfPrograms)
+		for _, ref := range refs {
+			if ref.prog == nil {
+				continue
+			}
+			pinOwners.claim(programPinPaths(ref.prog), pinOwner{iface: cfg.Iface, direction: ref.direction, program: ref.prog.Name})
+		}
+	}
+}
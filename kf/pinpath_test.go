@@ -0,0 +1,160 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_ValidatePinPaths(t *testing.T) {
+	rl := func(iface string) models.L3afBPFPrograms {
+		return models.L3afBPFPrograms{Iface: iface, BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{
+			{Name: "ratelimiting", AdminStatus: models.Enabled, MapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+				SharedMaps: map[string]string{"flows": "/sys/fs/bpf/flows"}},
+		}}}
+	}
+	tests := []struct {
+		name    string
+		cfg     []models.L3afBPFPrograms
+		wantErr bool
+	}{
+		{
+			name:    "Unique",
+			cfg:     []models.L3afBPFPrograms{rl("eth0")},
+			wantErr: false,
+		},
+		{
+			name:    "SameMapOnTwoIfaces",
+			cfg:     []models.L3afBPFPrograms{rl("eth0"), rl("eth1")},
+			wantErr: true,
+		},
+		{
+			name: "SharedMapPinOfAnotherProgram",
+			cfg: []models.L3afBPFPrograms{rl("eth0"), {Iface: "eth0", BpfPrograms: &models.BPFPrograms{TCIngress: []*models.BPFProgram{
+				{Name: "connection-limit", AdminStatus: models.Enabled, MapName: "/sys/fs/bpf/flows"},
+			}}}},
+			wantErr: true,
+		},
+		{
+			name: "MarkedShared",
+			cfg: func() []models.L3afBPFPrograms {
+				a, b := rl("eth0"), rl("eth1")
+				a.BpfPrograms.XDPIngress[0].SharedPins = []string{"/sys/fs/bpf/xdp_rl_ingress_next_prog", "/sys/fs/bpf/flows"}
+				b.BpfPrograms.XDPIngress[0].SharedPins = []string{"/sys/fs/bpf/xdp_rl_ingress_next_prog", "/sys/fs/bpf/flows"}
+				return []models.L3afBPFPrograms{a, b}
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "MarkedSharedByOne",
+			cfg: func() []models.L3afBPFPrograms {
+				a, b := rl("eth0"), rl("eth1")
+				a.BpfPrograms.XDPIngress[0].SharedPins = []string{"/sys/fs/bpf/xdp_rl_ingress_next_prog", "/sys/fs/bpf/flows"}
+				return []models.L3afBPFPrograms{a, b}
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "RootProgramMap",
+			cfg: []models.L3afBPFPrograms{{Iface: "eth0", BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{
+				{Name: "ratelimiting", AdminStatus: models.Enabled, MapName: "/sys/fs/bpf/xdp_root_array"},
+			}}}},
+			wantErr: true,
+		},
+		{
+			name: "DisabledDuplicate",
+			cfg: func() []models.L3afBPFPrograms {
+				b := rl("eth1")
+				b.BpfPrograms.XDPIngress[0].AdminStatus = models.Disabled
+				return []models.L3afBPFPrograms{rl("eth0"), b}
+			}(),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &NFConfigs{hostConfig: &config.Config{BpfChainingEnabled: true, XDPRootProgramMapName: "/sys/fs/bpf/xdp_root_array"}}
+			err := c.ValidatePinPaths(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePinPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrPinPathConflict) {
+				t.Errorf("ValidatePinPaths() error = %v, want ErrPinPathConflict", err)
+			}
+		})
+	}
+}
+
+func TestBPF_verifyPinPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		pinned  bool
+		owner   *pinOwner
+		shared  bool
+		wantErr bool
+	}{
+		{
+			name:    "NotPinned",
+			wantErr: false,
+		},
+		{
+			name:    "ForeignPin",
+			pinned:  true,
+			wantErr: true,
+		},
+		{
+			name:    "ForeignPinShared",
+			pinned:  true,
+			shared:  true,
+			wantErr: false,
+		},
+		{
+			name:    "OwnPin",
+			pinned:  true,
+			owner:   &pinOwner{iface: "eth0", direction: models.XDPIngressType, program: "ratelimiting"},
+			wantErr: false,
+		},
+		{
+			name:    "OtherProgramPin",
+			pinned:  true,
+			owner:   &pinOwner{iface: "eth1", direction: models.XDPIngressType, program: "ratelimiting"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			if tt.pinned {
+				files["/sys/fs/bpf/xdp_rl_ingress_next_prog"] = ""
+			}
+			useMemFS(t, files)
+			saved := pinOwners
+			pinOwners = &pinRegistry{owners: make(map[string]pinOwner)}
+			t.Cleanup(func() { pinOwners = saved })
+			if tt.owner != nil {
+				pinOwners.claim([]string{"/sys/fs/bpf/xdp_rl_ingress_next_prog"}, *tt.owner)
+			}
+
+			b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", MapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog"}}
+			if tt.shared {
+				b.Program.SharedPins = []string{"/sys/fs/bpf/xdp_rl_ingress_next_prog"}
+			}
+			err := b.verifyPinPaths("eth0", models.XDPIngressType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyPinPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// pin stays owned until it vanishes
+			b.claimPinPaths("eth0", models.XDPIngressType)
+			b.releasePinPaths("eth0", models.XDPIngressType)
+			if _, ok := pinOwners.get("/sys/fs/bpf/xdp_rl_ingress_next_prog"); ok != tt.pinned {
+				t.Errorf("releasePinPaths() owned = %v, want %v", ok, tt.pinned)
+			}
+		})
+	}
+}
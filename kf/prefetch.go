@@ -0,0 +1,102 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PrefetchArtifacts - downloads the artifacts into the artifact cache in parallel without starting the
+// programs, so the rollout of the configs is not waiting for the downloads. Results are in the order
+// of the artifacts.
+func (c *NFConfigs) PrefetchArtifacts(artifacts []models.L3afDArtifact) []models.L3afDPrefetchResult {
+	results := make([]models.L3afDPrefetchResult, len(artifacts))
+
+	platform, platformErr := GetPlatform()
+
+	parallelism := c.hostConfig.ArtifactPrefetchParallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	sem := make(chan struct{}, parallelism)
+
+	// duplicate entries are downloaded once
+	var wg sync.WaitGroup
+	first := make(map[string]int)
+	for i, a := range artifacts {
+		results[i] = models.L3afDPrefetchResult{Name: a.Name, Version: a.Version, Artifact: a.Artifact}
+		if err := validateArtifact(a); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if len(a.Platform) > 0 && a.Platform != platform {
+			if platformErr != nil {
+				results[i].Error = fmt.Sprintf("failed to find the platform of the node: %v", platformErr)
+			} else {
+				results[i].Error = fmt.Sprintf("platform %s does not match the node platform %s", a.Platform, platform)
+			}
+			continue
+		}
+		key := filepath.Join(a.Name, a.Version, a.Artifact)
+		if _, ok := first[key]; ok {
+			continue
+		}
+		first[key] = i
+
+		wg.Add(1)
+		go func(i int, a models.L3afDArtifact) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i].Cached, results[i].Error = c.prefetchArtifact(a)
+		}(i, a)
+	}
+	wg.Wait()
+
+	for i, a := range artifacts {
+		if j, ok := first[filepath.Join(a.Name, a.Version, a.Artifact)]; ok && j != i && len(results[i].Error) == 0 {
+			results[i].Cached, results[i].Error = results[j].Cached, results[j].Error
+		}
+	}
+	return results
+}
+
+// prefetchArtifact - downloads the artifact when it is not in the cache
+func (c *NFConfigs) prefetchArtifact(a models.L3afDArtifact) (bool, string) {
+	bpf := &BPF{Program: models.BPFProgram{Name: a.Name, Version: a.Version, Artifact: a.Artifact}}
+	fPath := filepath.Join(c.hostConfig.BPFDir, a.Name, a.Version, strings.Split(a.Artifact, ".")[0])
+	if _, err := appFS.Stat(fPath); err == nil {
+		return true, ""
+	} else if !os.IsNotExist(err) {
+		return false, err.Error()
+	}
+
+	if err := bpf.GetArtifacts(c.hostConfig); err != nil {
+		log.Warn().Err(err).Msgf("prefetch of artifact %s of program %s version %s failed", a.Artifact, a.Name, a.Version)
+		return false, err.Error()
+	}
+	log.Info().Msgf("prefetched artifact %s of program %s version %s", a.Artifact, a.Name, a.Version)
+	return false, ""
+}
+
+// validateArtifact - artifact fields are used in the download url and the cache path
+func validateArtifact(a models.L3afDArtifact) error {
+	for field, v := range map[string]string{"name": a.Name, "version": a.Version, "artifact": a.Artifact} {
+		if len(v) == 0 {
+			return fmt.Errorf("%s is empty", field)
+		}
+		if strings.Contains(v, "..") || strings.ContainsAny(v, `/\`) {
+			return fmt.Errorf("%s %s is not a valid path element", field, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_PrefetchArtifacts(t *testing.T) {
+	platform, err := GetPlatform()
+	if err != nil {
+		t.Skipf("platform not found: %v", err)
+	}
+	tests := []struct {
+		name      string
+		artifacts []models.L3afDArtifact
+		want      []models.L3afDPrefetchResult
+	}{
+		{
+			name: "Cached",
+			artifacts: []models.L3afDArtifact{
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz", Platform: platform},
+			},
+			want: []models.L3afDPrefetchResult{
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz", Cached: true},
+			},
+		},
+		{
+			name: "Duplicate",
+			artifacts: []models.L3afDArtifact{
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz"},
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz"},
+			},
+			want: []models.L3afDPrefetchResult{
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz", Cached: true},
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz", Cached: true},
+			},
+		},
+		{
+			name: "PlatformMismatch",
+			artifacts: []models.L3afDArtifact{
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz", Platform: "other"},
+			},
+			want: []models.L3afDPrefetchResult{
+				{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz",
+					Error: "platform other does not match the node platform " + platform},
+			},
+		},
+		{
+			name: "InvalidVersion",
+			artifacts: []models.L3afDArtifact{
+				{Name: "ratelimiting", Version: "../1.0", Artifact: "l3af_ratelimiting.tar.gz"},
+			},
+			want: []models.L3afDPrefetchResult{
+				{Name: "ratelimiting", Version: "../1.0", Artifact: "l3af_ratelimiting.tar.gz",
+					Error: "version ../1.0 is not a valid path element"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t, map[string]string{
+				"/dev/shm/ratelimiting/1.0/l3af_ratelimiting/ratelimiting": "binary",
+			})
+			c := &NFConfigs{hostConfig: &config.Config{BPFDir: "/dev/shm", ArtifactPrefetchParallelism: 2}}
+			if got := c.PrefetchArtifacts(tt.artifacts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PrefetchArtifacts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
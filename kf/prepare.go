@@ -0,0 +1,47 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// PrepareBPFPrograms - downloads the artifacts and validates the configs without starting the programs,
+// so the configs are deployed later with the artifacts already in the cache.
+func (c *NFConfigs) PrepareBPFPrograms(bpfProgs []models.L3afBPFPrograms) error {
+	bpfProgs, err := c.validateConfig(bpfProgs)
+	if err != nil {
+		return err
+	}
+
+	for _, bpfProg := range bpfProgs {
+		for _, ref := range configProgramRefs(bpfProg.BpfPrograms) {
+			if ref.prog == nil || !loadedStatus(ref.prog.AdminStatus) {
+				continue
+			}
+			if _, err := c.prepareBPFProgram(ref.prog); err != nil {
+				return fmt.Errorf("failed to prepare program %s version %s iface %s: %w", ref.prog.Name, ref.prog.Version, bpfProg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// prepareBPFProgram - downloads the artifact and inspects the eBPF objects of the program, returns the declared
+// map memory of the program
+func (c *NFConfigs) prepareBPFProgram(prog *models.BPFProgram) (uint64, error) {
+	bpf := NewBpfProgram(c.ctx, *prog, c.hostConfig.BPFLogDir, c.hostConfig.DataCenter)
+	if err := bpf.VerifyAndGetArtifacts(c.hostConfig); err != nil {
+		return 0, fmt.Errorf("failed to get artifacts %s with error: %w", prog.Artifact, err)
+	}
+	if err := bpf.VerifyBPFObjects(c.hostConfig.BpfChainingEnabled); err != nil {
+		return 0, fmt.Errorf("eBPF object inspection failed with error: %w", err)
+	}
+	if c.hostConfig.MaxProgramMapMemory > 0 && bpf.MapMemory > uint64(c.hostConfig.MaxProgramMapMemory) {
+		return 0, fmt.Errorf("program declares %d bytes of map memory, program budget is %d bytes", bpf.MapMemory, c.hostConfig.MaxProgramMapMemory)
+	}
+	return bpf.MapMemory, nil
+}
@@ -6,6 +6,8 @@ package kf
 
 import (
 	"container/list"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/l3af-project/l3afd/models"
@@ -18,6 +20,7 @@ type pCheck struct {
 	MaxRetryCount     int
 	Chain             bool
 	retryMonitorDelay time.Duration
+	mu                *sync.Mutex // Configs lock, the restarts of pCheck and of the reconciler do not overlap
 }
 
 func NewpCheck(rc int, chain bool, interval time.Duration) *pCheck {
@@ -37,33 +40,72 @@ func (c *pCheck) pCheckStart(xdpProgs, ingressTCProgs, egressTCProgs map[string]
 
 func (c *pCheck) pMonitorWorker(bpfProgs map[string]*list.List, direction string) {
 	for range time.NewTicker(c.retryMonitorDelay).C {
-		for ifaceName, bpfList := range bpfProgs {
-			if bpfList == nil { // no bpf programs are running
+		c.pMonitor(bpfProgs, direction)
+	}
+}
+
+// pMonitor - restarts the programs of the direction not running, under the configs lock when it is set
+func (c *pCheck) pMonitor(bpfProgs map[string]*list.List, direction string) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	for ifaceName, bpfList := range bpfProgs {
+		if bpfList == nil { // no bpf programs are running
+			continue
+		}
+		for e := bpfList.Front(); e != nil; e = e.Next() {
+			bpf := e.Value.(*BPF)
+			if c.Chain && bpf.Program.SeqID == 0 { // do not monitor root program
+				continue
+			}
+			// fail-open programs are restarted while they are bypassed
+			if bpf.Program.AdminStatus == models.Disabled || (bpf.Degraded && !bpf.failedOpen) {
 				continue
 			}
-			for e := bpfList.Front(); e != nil; e = e.Next() {
-				bpf := e.Value.(*BPF)
-				if c.Chain && bpf.Program.SeqID == 0 { // do not monitor root program
-					continue
+			isRunning, _ := bpf.isRunning()
+			if isRunning {
+				stats.Set(1.0, stats.NFRunning, bpf.Program.Name, direction)
+				continue
+			}
+			// fail-open program is removed from the chain as soon as its user process dies
+			if c.Chain && bpf.failOpen() && !bpf.Degraded && !bpf.standby() {
+				if err := failOpenBPF(e, ifaceName, direction); err != nil {
+					log.Error().Err(err).Msgf("pMonitor failed to bypass fail-open BPF Program %s", bpf.Program.Name)
 				}
-				if bpf.Program.AdminStatus == models.Disabled {
-					continue
+			}
+			// Not running trying to restart
+			if bpf.RestartCount < c.MaxRetryCount && loadedStatus(bpf.Program.AdminStatus) {
+				bpf.collectCoreDumps(ifaceName, direction)
+				bpf.captureIncident(ifaceName, direction)
+				bpf.RestartCount++
+				bpf.recordRestart(time.Now())
+				bpf.traceID = NewTraceID()
+				log.Warn().Msgf("pMonitor BPF Program is not running. Restart attempt: %d, program name: %s, iface: %s, trace: %s",
+					bpf.RestartCount, bpf.Program.Name, ifaceName, bpf.traceID)
+				if err := bpf.Start(ifaceName, direction, c.Chain); err != nil {
+					log.Error().Err(err).Msgf("pMonitor BPF Program start failed for program %s", bpf.Program.Name)
+					notifyEvent(EventProgramRestarted, ifaceName, direction, bpf.Program.Name,
+						fmt.Sprintf("restart attempt %d of %d failed: %v", bpf.RestartCount, c.MaxRetryCount, err))
+				} else {
+					notifyEvent(EventProgramRestarted, ifaceName, direction, bpf.Program.Name,
+						fmt.Sprintf("program is not running, restarted by attempt %d of %d", bpf.RestartCount, c.MaxRetryCount))
+					if err := restoreBPF(e, ifaceName, direction); err != nil {
+						log.Error().Err(err).Msgf("pMonitor failed to restore fail-open BPF Program %s", bpf.Program.Name)
+					}
 				}
-				isRunning, _ := bpf.isRunning()
-				if isRunning {
-					stats.Set(1.0, stats.NFRunning, bpf.Program.Name, direction)
-					continue
+			} else {
+				if bpf.Cmd != nil && bpf.Cmd.ProcessState == nil { // last crash is captured once
+					bpf.collectCoreDumps(ifaceName, direction)
+					bpf.captureIncident(ifaceName, direction)
+					notifyEvent(EventProgramFailed, ifaceName, direction, bpf.Program.Name,
+						fmt.Sprintf("program is not running after %d restart attempts", bpf.RestartCount))
 				}
-				// Not running trying to restart
-				if bpf.RestartCount < c.MaxRetryCount && bpf.Program.AdminStatus == models.Enabled {
-					bpf.RestartCount++
-					log.Warn().Msgf("pMonitor BPF Program is not running. Restart attempt: %d, program name: %s, iface: %s",
-						bpf.RestartCount, bpf.Program.Name, ifaceName)
-					if err := bpf.Start(ifaceName, direction, c.Chain); err != nil {
-						log.Error().Err(err).Msgf("pMonitor BPF Program start failed for program %s", bpf.Program.Name)
+				stats.Set(0.0, stats.NFRunning, bpf.Program.Name, direction)
+				if c.Chain && bpf.Program.BypassOnFailure && !bpf.standby() {
+					if err := bypassBPF(e, ifaceName, direction, "restarts are exhausted"); err != nil {
+						log.Error().Err(err).Msgf("pMonitor failed to bypass BPF Program %s", bpf.Program.Name)
 					}
-				} else {
-					stats.Set(0.0, stats.NFRunning, bpf.Program.Name, direction)
 				}
 			}
 		}
@@ -0,0 +1,132 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package kf provides primitives for NF process monitoring.
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// clockTicks is USER_HZ, cpu times in /proc are reported in clock ticks
+const clockTicks = 100
+
+// processUsage defines resource usage of the NF user process
+type processUsage struct {
+	RSSBytes   uint64
+	CPUSeconds float64
+	OpenFDs    int
+	Threads    int
+}
+
+type pUsage struct {
+	Chain    bool
+	interval time.Duration
+}
+
+func NewpUsage(chain bool, interval time.Duration) *pUsage {
+	u := &pUsage{
+		Chain:    chain,
+		interval: interval,
+	}
+	return u
+}
+
+func (u *pUsage) pUsageStart(xdpProgs, ingressTCProgs, egressTCProgs map[string]*list.List) {
+	if u.interval <= 0 {
+		log.Info().Msg("NF process usage metrics are disabled")
+		return
+	}
+	go u.pUsageWorker(xdpProgs, models.XDPIngressType)
+	go u.pUsageWorker(ingressTCProgs, models.IngressType)
+	go u.pUsageWorker(egressTCProgs, models.EgressType)
+}
+
+func (u *pUsage) pUsageWorker(bpfProgs map[string]*list.List, direction string) {
+	for range time.NewTicker(u.interval).C {
+		for ifaceName, bpfList := range bpfProgs {
+			if bpfList == nil { // no bpf programs are running
+				continue
+			}
+			for e := bpfList.Front(); e != nil; e = e.Next() {
+				bpf := e.Value.(*BPF)
+				if u.Chain && bpf.Program.SeqID == 0 { // do not monitor root program
+					continue
+				}
+				if bpf.Program.AdminStatus == models.Disabled || bpf.Cmd == nil || bpf.Cmd.Process == nil {
+					continue
+				}
+				usage, err := readProcessUsage(bpf.Cmd.Process.Pid)
+				if err != nil {
+					log.Debug().Err(err).Msgf("pUsage failed to read process usage - %s", bpf.Program.Name)
+					continue
+				}
+				stats.Set(float64(usage.RSSBytes), stats.NFProcessRSS, bpf.Program.Name, direction)
+				stats.Set(usage.CPUSeconds, stats.NFProcessCPUSeconds, bpf.Program.Name, direction)
+				stats.Set(float64(usage.OpenFDs), stats.NFProcessOpenFDs, bpf.Program.Name, direction)
+				stats.Set(float64(usage.Threads), stats.NFProcessThreads, bpf.Program.Name, direction)
+				bpf.checkWatchdog(e, ifaceName, direction, u.Chain, usage, time.Now())
+
+				if bpf.Program.AFXDP != nil {
+					xs, err := readXDPStatistics(bpf.Cmd.Process.Pid)
+					if err != nil {
+						log.Debug().Err(err).Msgf("pUsage failed to read AF_XDP statistics - %s", bpf.Program.Name)
+						continue
+					}
+					stats.SetValue(float64(xs.RxDropped), stats.NFAFXDPStats, bpf.Program.Name, "rx_dropped")
+					stats.SetValue(float64(xs.RxInvalidDescs), stats.NFAFXDPStats, bpf.Program.Name, "rx_invalid_descs")
+					stats.SetValue(float64(xs.TxInvalidDescs), stats.NFAFXDPStats, bpf.Program.Name, "tx_invalid_descs")
+					stats.SetValue(float64(xs.RxRingFull), stats.NFAFXDPStats, bpf.Program.Name, "rx_ring_full")
+					stats.SetValue(float64(xs.RxFillRingEmptyDescs), stats.NFAFXDPStats, bpf.Program.Name, "rx_fill_ring_empty_descs")
+					stats.SetValue(float64(xs.TxRingEmptyDescs), stats.NFAFXDPStats, bpf.Program.Name, "tx_ring_empty_descs")
+				}
+			}
+		}
+	}
+}
+
+// parseProcStat - parses cpu times, threads and rss pages from /proc/<pid>/stat content
+func parseProcStat(procStat string, pageSize int) (*processUsage, error) {
+	// process name can contain spaces and parentheses, fields are parsed after the last ')'
+	idx := strings.LastIndex(procStat, ")")
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid proc stat format")
+	}
+	// fields starting from the state i.e. third field of the proc stat
+	fields := strings.Fields(procStat[idx+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("expected minimum 22 proc stat fields and got %d", len(fields))
+	}
+
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse utime: %w", err)
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stime: %w", err)
+	}
+	threads, err := strconv.Atoi(fields[17])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse num_threads: %w", err)
+	}
+	rss, err := strconv.ParseUint(fields[21], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rss: %w", err)
+	}
+
+	return &processUsage{
+		RSSBytes:   rss * uint64(pageSize),
+		CPUSeconds: float64(utime+stime) / clockTicks,
+		Threads:    threads,
+	}, nil
+}
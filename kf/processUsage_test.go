@@ -0,0 +1,53 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseProcStat(t *testing.T) {
+	tests := []struct {
+		name     string
+		procStat string
+		want     *processUsage
+		wantErr  bool
+	}{
+		{
+			name:     "Valid",
+			procStat: "1234 (ratelimiting) S 1 1234 1234 0 -1 4194560 1200 0 0 0 250 150 0 0 20 0 4 0 1000 120000000 512 18446744073709551615",
+			want:     &processUsage{RSSBytes: 512 * 4096, CPUSeconds: 4, Threads: 4},
+			wantErr:  false,
+		},
+		{
+			name:     "NameWithSpaces",
+			procStat: "1234 (rate limit) S) R 1 1234 1234 0 -1 4194560 1200 0 0 0 50 50 0 0 20 0 1 0 1000 120000000 10 18446744073709551615",
+			want:     &processUsage{RSSBytes: 10 * 4096, CPUSeconds: 1, Threads: 1},
+			wantErr:  false,
+		},
+		{
+			name:     "Truncated",
+			procStat: "1234 (ratelimiting) S 1 1234",
+			wantErr:  true,
+		},
+		{
+			name:     "InvalidFormat",
+			procStat: "",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProcStat(tt.procStat, 4096)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseProcStat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProcStat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// queueTick - period the per-queue instances are synced with the configs at
+const queueTick = 5 * time.Second
+
+// queueSlot - slot of the per-queue instance serving the queue
+func queueSlot(queue int) string {
+	return "q" + strconv.Itoa(queue)
+}
+
+// validateQueues - queues are set for the XDP programs only, the queue ids are unique and the AF_XDP sockets
+// serve the queues of the program
+func validateQueues(prog *models.BPFProgram, direction string) error {
+	q := prog.Queues
+	if q == nil {
+		return nil
+	}
+	if direction != models.XDPIngressType {
+		return fmt.Errorf("queues are set for direction %s", direction)
+	}
+	if len(q.QueueIDs) == 0 {
+		return fmt.Errorf("no queue ids are configured")
+	}
+	seen := make(map[int]bool, len(q.QueueIDs))
+	for _, id := range q.QueueIDs {
+		if id < 0 {
+			return fmt.Errorf("invalid queue id %d", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("queue id %d is repeated", id)
+		}
+		seen[id] = true
+	}
+	if prog.AFXDP != nil && len(prog.AFXDP.QueueIDs) > 0 && !reflect.DeepEqual(prog.AFXDP.QueueIDs, q.QueueIDs) {
+		return fmt.Errorf("af_xdp queue ids %v differ from the queue ids %v of the program", prog.AFXDP.QueueIDs, q.QueueIDs)
+	}
+	if !q.InstancePerQueue {
+		return nil
+	}
+	if !prog.UserProgramDaemon {
+		return fmt.Errorf("instance per queue requires a user program daemon")
+	}
+	if prog.BlueGreen != nil || prog.Shadow != nil {
+		return fmt.Errorf("instance per queue is not supported with blue/green upgrades or shadow mode")
+	}
+	for _, cm := range prog.ConsumedMaps {
+		if len(cm.PinPath) > 0 {
+			return fmt.Errorf("consumed map %s is re-pinned at %s, which is not supported with instance per queue", cm.Name, cm.PinPath)
+		}
+	}
+	return nil
+}
+
+// ValidateQueues - Verifies the RX queues of the programs
+func (c *NFConfigs) ValidateQueues(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateQueues(ref.prog, ref.direction); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRxQueues - queues are of the RX channels of the interface, not checked when the driver reports no channels
+func checkRxQueues(channels *ifaceChannels, queues []int) error {
+	count := int(channels.Combined + channels.Rx)
+	if count == 0 {
+		return nil
+	}
+	for _, id := range queues {
+		if id >= count {
+			return fmt.Errorf("queue id %d exceeds the %d rx queues of the interface", id, count)
+		}
+	}
+	return nil
+}
+
+// rxQueues - RX queues the program serves, the first queue when an instance is started per queue, the queue of
+// the instance for the per-queue instances
+func (b *BPF) rxQueues() []int {
+	q := b.Program.Queues
+	if q == nil || len(q.QueueIDs) == 0 {
+		return nil
+	}
+	if q.InstancePerQueue {
+		return q.QueueIDs[:1]
+	}
+	return q.QueueIDs
+}
+
+// queueImplicitArgs - sets the RX queues the program serves to the implicit args, after verifying the interface
+// has the queues
+func (b *BPF) queueImplicitArgs(implicit *models.L3afDNFImplicitArgs, ifaceName string) error {
+	queues := b.rxQueues()
+	if len(queues) == 0 {
+		return nil
+	}
+	channels, err := getIfaceChannels(ifaceName)
+	if err != nil {
+		log.Debug().Err(err).Msgf("failed to get channels of iface %s, queues of program %s are not verified", ifaceName, b.Program.Name)
+	} else if err := checkRxQueues(channels, queues); err != nil {
+		return fmt.Errorf("iface %s: %w", ifaceName, err)
+	}
+	implicit.RxQueues = queues
+	return nil
+}
+
+// afxdpConfig - AF_XDP config of the program with the sockets of the queues the program serves
+func (b *BPF) afxdpConfig() *models.L3afDNFAFXDP {
+	afxdp := *b.Program.AFXDP
+	if queues := b.rxQueues(); len(queues) > 0 {
+		afxdp.QueueIDs = queues
+	}
+	return &afxdp
+}
+
+// queueProgram - config of the per-queue instance of the program serving the queue. The instance is not chained,
+// the packets of its queue reach it through the program or the AF_XDP sockets of the queue.
+func queueProgram(prog *models.BPFProgram, queue int) models.BPFProgram {
+	inst := *prog
+	inst.Queues = &models.L3afDNFQueues{QueueIDs: []int{queue}}
+	inst.AdminStatus = models.Enabled
+	inst.MapName = ""
+	inst.MapArgs = nil
+	inst.SharedMaps = nil
+	inst.Heartbeat = nil
+	inst.BlueGreen = nil
+	inst.Shadow = nil
+	return inst
+}
+
+// startQueueInstance - starts the instance of the program serving the queue, from the artifact of the program
+func (c *NFConfigs) startQueueInstance(b *BPF, queue int, ifaceName, direction string) error {
+	inst := NewBpfProgram(c.ctx, queueProgram(b.configProgram(), queue), c.hostConfig.BPFLogDir, c.hostConfig.DataCenter)
+	inst.BTFPath = c.btfPath
+	inst.FilePath = b.FilePath
+	inst.slot = queueSlot(queue)
+	inst.traceID = c.traceID
+	if err := inst.Start(ifaceName, direction, c.hostConfig.BpfChainingEnabled); err != nil {
+		if inst.Cmd != nil {
+			stopSlot(inst, b, ifaceName, direction)
+		}
+		return fmt.Errorf("failed to start instance of queue %d: %w", queue, err)
+	}
+	if b.queueInstances == nil {
+		b.queueInstances = make(map[int]*BPF)
+	}
+	b.queueInstances[queue] = inst
+	stats.SetGauge(1.0, stats.NFQueueInstances, b.Program.Name, direction, strconv.Itoa(queue))
+	log.Info().Msgf("instance of program %s iface %s direction %s serving queue %d started", b.Program.Name, ifaceName, direction, queue)
+	return nil
+}
+
+// stopQueueInstance - stops the instance of the program serving the queue
+func (b *BPF) stopQueueInstance(queue int, ifaceName, direction string) {
+	inst, ok := b.queueInstances[queue]
+	if !ok {
+		return
+	}
+	log.Info().Msgf("stopping instance of program %s serving queue %d", b.Program.Name, queue)
+	stopSlot(inst, b, ifaceName, direction)
+	delete(b.queueInstances, queue)
+	stats.SetGauge(0.0, stats.NFQueueInstances, b.Program.Name, direction, strconv.Itoa(queue))
+}
+
+// stopQueueInstances - stops all the per-queue instances of the program
+func (b *BPF) stopQueueInstances(ifaceName, direction string) {
+	for queue := range b.queueInstances {
+		b.stopQueueInstance(queue, ifaceName, direction)
+	}
+}
+
+// syncQueueInstances - starts, restarts or stops the per-queue instances of the program to follow its config.
+// Instances found not running are restarted, instances failing to start are retried at the next sync.
+func (c *NFConfigs) syncQueueInstances(e *list.Element, ifaceName, direction string) {
+	b := e.Value.(*BPF)
+	q := b.Program.Queues
+	var queues []int
+	if q != nil && q.InstancePerQueue && loadedStatus(b.Program.AdminStatus) && b.Cmd != nil && len(q.QueueIDs) > 1 {
+		queues = q.QueueIDs[1:]
+	}
+	desired := make(map[int]bool, len(queues))
+	for _, queue := range queues {
+		desired[queue] = true
+	}
+
+	for queue, inst := range b.queueInstances {
+		if !desired[queue] || inst.FilePath != b.FilePath || !reflect.DeepEqual(inst.Program, queueProgram(b.configProgram(), queue)) {
+			b.stopQueueInstance(queue, ifaceName, direction)
+			continue
+		}
+		if running, err := inst.isRunning(); !running {
+			log.Warn().Err(err).Msgf("instance of program %s iface %s direction %s serving queue %d is not running, restarting it",
+				b.Program.Name, ifaceName, direction, queue)
+			b.stopQueueInstance(queue, ifaceName, direction)
+			stats.Add(1, stats.NFQueueRestarts, b.Program.Name, direction, strconv.Itoa(queue))
+		}
+	}
+
+	for _, queue := range queues {
+		if _, ok := b.queueInstances[queue]; ok {
+			continue
+		}
+		if err := c.startQueueInstance(b, queue, ifaceName, direction); err != nil {
+			log.Warn().Err(err).Msgf("program %s iface %s direction %s does not serve queue %d", b.Program.Name, ifaceName, direction, queue)
+		}
+	}
+	if len(queues) > 0 && b.Program.AFXDP != nil {
+		b.queueAFXDPStats()
+	}
+}
+
+// queueAFXDPStats - publishes the statistics of the AF_XDP sockets of each per-queue instance by its queue
+func (b *BPF) queueAFXDPStats() {
+	for _, s := range b.queueInstanceStatus() {
+		if !s.Running {
+			continue
+		}
+		xs, err := readXDPStatistics(s.PID)
+		if err != nil {
+			log.Debug().Err(err).Msgf("failed to read AF_XDP statistics of program %s queue %d", b.Program.Name, s.QueueID)
+			continue
+		}
+		queue := strconv.Itoa(s.QueueID)
+		stats.SetGauge(float64(xs.RxDropped), stats.NFQueueAFXDPStats, b.Program.Name, queue, "rx_dropped")
+		stats.SetGauge(float64(xs.RxInvalidDescs), stats.NFQueueAFXDPStats, b.Program.Name, queue, "rx_invalid_descs")
+		stats.SetGauge(float64(xs.TxInvalidDescs), stats.NFQueueAFXDPStats, b.Program.Name, queue, "tx_invalid_descs")
+		stats.SetGauge(float64(xs.RxRingFull), stats.NFQueueAFXDPStats, b.Program.Name, queue, "rx_ring_full")
+		stats.SetGauge(float64(xs.RxFillRingEmptyDescs), stats.NFQueueAFXDPStats, b.Program.Name, queue, "rx_fill_ring_empty_descs")
+		stats.SetGauge(float64(xs.TxRingEmptyDescs), stats.NFQueueAFXDPStats, b.Program.Name, queue, "tx_ring_empty_descs")
+	}
+}
+
+// QueueInstances - syncs the per-queue instances of the programs with their configs
+func (c *NFConfigs) QueueInstances() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for ifaceName, bpfList := range c.IngressXDPBpfs {
+		if bpfList == nil {
+			continue
+		}
+		for e := bpfList.Front(); e != nil; e = e.Next() {
+			c.syncQueueInstances(e, ifaceName, models.XDPIngressType)
+		}
+	}
+}
+
+// queueLoop - periodic sync of the per-queue instances
+func (c *NFConfigs) queueLoop() {
+	ticker := time.NewTicker(queueTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.QueueInstances()
+		}
+	}
+}
+
+// queueInstanceStatus - per-queue instances of the program, the program serving the first queue included
+func (b *BPF) queueInstanceStatus() []models.L3afDQueueInstance {
+	q := b.Program.Queues
+	if q == nil || !q.InstancePerQueue {
+		return nil
+	}
+	status := make([]models.L3afDQueueInstance, 0, len(q.QueueIDs))
+	for i, queue := range q.QueueIDs {
+		inst := b
+		if i > 0 {
+			inst = b.queueInstances[queue]
+		}
+		s := models.L3afDQueueInstance{QueueID: queue}
+		if inst != nil && inst.Cmd != nil && inst.Cmd.Process != nil {
+			s.Running = true
+			s.PID = inst.Cmd.Process.Pid
+		}
+		status = append(status, s)
+	}
+	return status
+}
@@ -0,0 +1,125 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_validateQueues(t *testing.T) {
+	perQueue := &models.L3afDNFQueues{QueueIDs: []int{0, 1}, InstancePerQueue: true}
+	tests := []struct {
+		name      string
+		prog      models.BPFProgram
+		direction string
+		wantErr   bool
+	}{
+		{"none", models.BPFProgram{}, models.IngressType, false},
+		{"queues", models.BPFProgram{Queues: &models.L3afDNFQueues{QueueIDs: []int{2, 3}}}, models.XDPIngressType, false},
+		{"instance per queue", models.BPFProgram{UserProgramDaemon: true, Queues: perQueue}, models.XDPIngressType, false},
+		{"af_xdp queues", models.BPFProgram{
+			UserProgramDaemon: true,
+			AFXDP:             &models.L3afDNFAFXDP{XSKMapName: "/sys/fs/bpf/xsks_map", QueueIDs: []int{0, 1}},
+			Queues:            perQueue,
+		}, models.XDPIngressType, false},
+		{"tc program", models.BPFProgram{Queues: &models.L3afDNFQueues{QueueIDs: []int{0}}}, models.IngressType, true},
+		{"no queues", models.BPFProgram{Queues: &models.L3afDNFQueues{}}, models.XDPIngressType, true},
+		{"negative queue", models.BPFProgram{Queues: &models.L3afDNFQueues{QueueIDs: []int{-1}}}, models.XDPIngressType, true},
+		{"repeated queue", models.BPFProgram{Queues: &models.L3afDNFQueues{QueueIDs: []int{1, 1}}}, models.XDPIngressType, true},
+		{"af_xdp queues differ", models.BPFProgram{
+			AFXDP:  &models.L3afDNFAFXDP{XSKMapName: "/sys/fs/bpf/xsks_map", QueueIDs: []int{0}},
+			Queues: &models.L3afDNFQueues{QueueIDs: []int{0, 1}},
+		}, models.XDPIngressType, true},
+		{"no daemon", models.BPFProgram{Queues: perQueue}, models.XDPIngressType, true},
+		{"shadow", models.BPFProgram{UserProgramDaemon: true, Queues: perQueue, Shadow: &models.L3afDNFShadow{Version: "2.0"}}, models.XDPIngressType, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateQueues(&tt.prog, tt.direction); (err != nil) != tt.wantErr {
+				t.Errorf("validateQueues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_checkRxQueues(t *testing.T) {
+	if err := checkRxQueues(&ifaceChannels{Combined: 4}, []int{0, 3}); err != nil {
+		t.Errorf("checkRxQueues() error = %v", err)
+	}
+	if err := checkRxQueues(&ifaceChannels{Combined: 2, Rx: 1}, []int{3}); err == nil {
+		t.Errorf("checkRxQueues() error = nil, want the queue out of the rx queues rejected")
+	}
+	if err := checkRxQueues(&ifaceChannels{}, []int{7}); err != nil {
+		t.Errorf("checkRxQueues() error = %v, want the queues not checked without channels", err)
+	}
+}
+
+func TestBPF_rxQueues(t *testing.T) {
+	prog := models.BPFProgram{
+		Name:              "xsk-lb",
+		MapName:           "/sys/fs/bpf/xsk_lb_next_prog",
+		UserProgramDaemon: true,
+		AFXDP:             &models.L3afDNFAFXDP{XSKMapName: "/sys/fs/bpf/xsks_map"},
+		Queues:            &models.L3afDNFQueues{QueueIDs: []int{2, 3, 5}, InstancePerQueue: true},
+		SharedMaps:        map[string]string{"backends": "/sys/fs/bpf/xsk_lb_backends"},
+	}
+	b := &BPF{Program: prog}
+	if got := b.rxQueues(); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("rxQueues() = %v, want the first queue served by the program", got)
+	}
+	if got := b.afxdpConfig(); !reflect.DeepEqual(got.QueueIDs, []int{2}) || prog.AFXDP.QueueIDs != nil {
+		t.Errorf("afxdpConfig() queue ids = %v, want the sockets of the first queue", got.QueueIDs)
+	}
+
+	inst := &BPF{Program: queueProgram(&prog, 5), slot: queueSlot(5)}
+	if inst.Program.MapName != "" || inst.Program.SharedMaps != nil || inst.slotName() != "xsk-lb-q5" {
+		t.Errorf("queueProgram() = %+v slot %s, want the instance not chained", inst.Program, inst.slotName())
+	}
+	if got := inst.rxQueues(); !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("rxQueues() = %v, want the queue of the instance", got)
+	}
+
+	b.Program.Queues.InstancePerQueue = false
+	if got := b.rxQueues(); !reflect.DeepEqual(got, []int{2, 3, 5}) {
+		t.Errorf("rxQueues() = %v, want all the queues", got)
+	}
+	flags := implicitArgFlags(models.L3afDNFImplicitArgs{SchemaVersion: ArgSchemaV1, Iface: "eth0", Direction: models.XDPIngressType, RxQueues: b.rxQueues()})
+	if want := []string{"--iface=eth0", "--direction=xdpingress", "--rx-queues=2,3,5"}; !reflect.DeepEqual(flags, want) {
+		t.Errorf("implicitArgFlags() = %v, want %v", flags, want)
+	}
+}
+
+func TestNFConfigs_syncQueueInstances(t *testing.T) {
+	prog := models.BPFProgram{
+		Name:              "xsk-lb",
+		AdminStatus:       models.Enabled,
+		UserProgramDaemon: true,
+		Queues:            &models.L3afDNFQueues{QueueIDs: []int{0, 1}, InstancePerQueue: true},
+	}
+	b := &BPF{Program: prog, Cmd: &exec.Cmd{Process: &os.Process{Pid: 41}}}
+	b.queueInstances = map[int]*BPF{
+		// running instance, the process of the test
+		1: {Program: queueProgram(&prog, 1), slot: queueSlot(1), Cmd: &exec.Cmd{Process: &os.Process{Pid: os.Getpid()}}},
+		// instance of the queue removed from the config
+		4: {Program: queueProgram(&prog, 4), slot: queueSlot(4)},
+	}
+	want := []models.L3afDQueueInstance{{QueueID: 0, Running: true, PID: 41}, {QueueID: 1, Running: true, PID: os.Getpid()}}
+	if got := b.queueInstanceStatus(); !reflect.DeepEqual(got, want) {
+		t.Errorf("queueInstanceStatus() = %+v, want %+v", got, want)
+	}
+
+	running := b.queueInstances[1]
+	c := &NFConfigs{}
+	e := list.New().PushBack(b)
+	c.syncQueueInstances(e, "eth0", models.XDPIngressType)
+	if len(b.queueInstances) != 1 || b.queueInstances[1] != running {
+		t.Errorf("syncQueueInstances() instances = %v, want the instance of the removed queue stopped", b.queueInstances)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// reconcileLoop - periodic full resync of the running programs with the config, in addition to the event
+// driven config handling
+func (c *NFConfigs) reconcileLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.attachPendingLinks()
+			if repairs := c.Reconcile(); repairs > 0 {
+				log.Warn().Msgf("reconciler repaired %d drifts", repairs)
+			}
+		}
+	}
+}
+
+// Reconcile - verifies the programs of the config are running, pinned and chained and repairs the drift,
+// returns the count of the repairs
+func (c *NFConfigs) Reconcile() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	repairs := 0
+	for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+		bpfs, _ := c.bpfLists(direction)
+		for ifaceName, bpfList := range bpfs {
+			if bpfList == nil {
+				continue
+			}
+			repairs += c.reconcileList(bpfList, ifaceName, direction)
+		}
+	}
+	return repairs
+}
+
+// reconcileList - repairs the programs of the interface in the direction, root program is not repaired
+func (c *NFConfigs) reconcileList(bpfList *list.List, ifaceName, direction string) int {
+	chain := c.hostConfig.BpfChainingEnabled
+	repairs := 0
+	for e := bpfList.Front(); e != nil; e = e.Next() {
+		bpf := e.Value.(*BPF)
+		if chain && bpf.Program.SeqID == 0 {
+			continue
+		}
+		if bpf.Program.AdminStatus != models.Enabled || bpf.Degraded {
+			continue
+		}
+
+		// killed process or removed pin, program is restarted within the restarts of pCheck
+		reason := ""
+		pinRemoved := false
+		if running, _ := bpf.isRunning(); !running {
+			reason = "process is not running"
+		} else if chain && len(bpf.Program.MapName) > 0 {
+			if _, err := appFS.Stat(bpf.Program.MapName); err != nil {
+				reason = "chaining map pin is removed"
+				pinRemoved = true
+			}
+		}
+		if len(reason) > 0 {
+			if c.processMon != nil && bpf.RestartCount >= c.processMon.MaxRetryCount {
+				log.Debug().Msgf("reconciler skipping program %s iface %s direction %s, restarts are exhausted", bpf.Program.Name, ifaceName, direction)
+				continue
+			}
+			if pinRemoved {
+				if err := bpf.Stop(ifaceName, direction, chain); err != nil {
+					log.Warn().Err(err).Msgf("reconciler failed to stop program %s", bpf.Program.Name)
+				}
+			}
+			bpf.RestartCount++
+			bpf.recordRestart(time.Now())
+			bpf.traceID = NewTraceID()
+			log.Warn().Msgf("reconciler restarting program %s iface %s direction %s trace %s, %s", bpf.Program.Name, ifaceName, direction, bpf.traceID, reason)
+			if err := bpf.Start(ifaceName, direction, chain); err != nil {
+				log.Error().Err(err).Msgf("reconciler failed to restart program %s", bpf.Program.Name)
+				continue
+			}
+			repairs++
+			stats.Incr(stats.NFReconcileRepairs, bpf.Program.Name, direction)
+			notifyEvent(EventChainRepaired, ifaceName, direction, bpf.Program.Name, "program restarted by the reconciler, "+reason)
+			stats.Incr(stats.ChainRepairs, ifaceName, direction)
+		}
+
+		if !chain {
+			continue
+		}
+
+		// predecessor must point to the program, bypassed and standby predecessors are skipped
+		prev, _ := chainNeighbours(e)
+		if prev == nil || bpf.ProgID == 0 {
+			continue
+		}
+		prevBPF := prev.Value.(*BPF)
+		if len(prevBPF.Program.MapName) == 0 {
+			continue
+		}
+		bpf.PrevMapName = prevBPF.Program.MapName
+		if progID, err := bpf.GetProgID(); err == nil && progID == bpf.ProgID {
+			continue
+		}
+		log.Warn().Msgf("reconciler relinking program %s to %s iface %s direction %s", bpf.Program.Name, prevBPF.Program.Name, ifaceName, direction)
+		if err := c.LinkBPFPrograms(prevBPF, bpf); err != nil {
+			log.Error().Err(err).Msgf("reconciler failed to relink program %s", bpf.Program.Name)
+			continue
+		}
+		repairs++
+		stats.Incr(stats.NFReconcileRepairs, bpf.Program.Name, direction)
+		notifyEvent(EventChainRepaired, ifaceName, direction, bpf.Program.Name, "program relinked to "+prevBPF.Program.Name+" by the reconciler")
+		stats.Incr(stats.ChainRepairs, ifaceName, direction)
+	}
+	return repairs
+}
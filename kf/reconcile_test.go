@@ -0,0 +1,133 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestNFConfigs_Reconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		rlMap       map[int]int // entries of the chaining map of rl pointing to cl
+		clDegraded  bool
+		clStatus    string
+		wantRepairs int
+		wantRLMap   map[int]int
+	}{
+		{
+			name:        "InSync",
+			rlMap:       map[int]int{0: 12},
+			clStatus:    models.Enabled,
+			wantRepairs: 0,
+			wantRLMap:   map[int]int{0: 12},
+		},
+		{
+			name:        "LinkRemoved",
+			rlMap:       map[int]int{},
+			clStatus:    models.Enabled,
+			wantRepairs: 1,
+			wantRLMap:   map[int]int{0: 112},
+		},
+		{
+			name:        "LinkStale",
+			rlMap:       map[int]int{0: 9},
+			clStatus:    models.Enabled,
+			wantRepairs: 1,
+			wantRLMap:   map[int]int{0: 112},
+		},
+		{
+			name:        "Degraded",
+			rlMap:       map[int]int{},
+			clDegraded:  true,
+			clStatus:    models.Enabled,
+			wantRepairs: 0,
+			wantRLMap:   map[int]int{},
+		},
+		{
+			name:        "Disabled",
+			rlMap:       map[int]int{},
+			clStatus:    models.Disabled,
+			wantRepairs: 0,
+			wantRLMap:   map[int]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// root -> rl -> cl, lookup of the chaining map returns the program ID like the kernel
+			useMemFS(t, map[string]string{
+				"/sys/fs/bpf/root_next_prog": "",
+				"/sys/fs/bpf/rl_next_prog":   "",
+			})
+			rlMap := &fakeMap{entries: tt.rlMap}
+			useFakeEBPF(t, &fakeEBPF{
+				maps: map[string]*fakeMap{
+					"/sys/fs/bpf/root_next_prog": {entries: map[int]int{0: 11}},
+					"/sys/fs/bpf/rl_next_prog":   rlMap,
+				},
+				programs: map[ebpf.ProgramID]bool{9: true, 11: true, 12: true},
+			})
+
+			bpfList := list.New()
+			bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "root", MapName: "/sys/fs/bpf/root_next_prog", AdminStatus: models.Enabled}, ProgID: 10})
+			bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "rl", SeqID: 1, MapName: "/sys/fs/bpf/rl_next_prog", AdminStatus: models.Enabled},
+				ProgID: 11, PrevMapName: "/sys/fs/bpf/root_next_prog"})
+			bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "cl", SeqID: 2, AdminStatus: tt.clStatus},
+				ProgID: 12, PrevMapName: "/sys/fs/bpf/rl_next_prog", Degraded: tt.clDegraded})
+
+			c := &NFConfigs{
+				hostConfig:     &config.Config{BpfChainingEnabled: true},
+				IngressXDPBpfs: map[string]*list.List{"eth0": bpfList},
+				IngressTCBpfs:  map[string]*list.List{},
+				EgressTCBpfs:   map[string]*list.List{},
+				mu:             new(sync.Mutex),
+			}
+			if got := c.Reconcile(); got != tt.wantRepairs {
+				t.Errorf("Reconcile() = %d, want %d", got, tt.wantRepairs)
+			}
+			if !reflect.DeepEqual(rlMap.entries, tt.wantRLMap) {
+				t.Errorf("Reconcile() rl map = %v, want %v", rlMap.entries, tt.wantRLMap)
+			}
+		})
+	}
+}
+
+func TestNFConfigs_Reconcile_restarts(t *testing.T) {
+	tests := []struct {
+		name             string
+		restartCount     int
+		wantRestartCount int
+	}{
+		{name: "Restarted", restartCount: 1, wantRestartCount: 2},
+		{name: "RestartsExhausted", restartCount: 3, wantRestartCount: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bpf := &BPF{Program: models.BPFProgram{Name: "rl", SeqID: 1, UserProgramDaemon: true, AdminStatus: models.Enabled},
+				RestartCount: tt.restartCount}
+			bpfList := list.New()
+			bpfList.PushBack(bpf)
+			c := &NFConfigs{
+				hostConfig:     &config.Config{},
+				IngressXDPBpfs: map[string]*list.List{"eth0": bpfList},
+				IngressTCBpfs:  map[string]*list.List{},
+				EgressTCBpfs:   map[string]*list.List{},
+				mu:             new(sync.Mutex),
+				processMon:     &pCheck{MaxRetryCount: 3},
+			}
+			c.Reconcile()
+			if bpf.RestartCount != tt.wantRestartCount {
+				t.Errorf("Reconcile() restart count = %d, want %d", bpf.RestartCount, tt.wantRestartCount)
+			}
+		})
+	}
+}
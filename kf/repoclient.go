@@ -0,0 +1,95 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+// newKFRepoClient - http client of the artifact downloads. Proxies of the environment are honored unless
+// the proxy is configured, the CA bundle is trusted along with the system roots and the certificates of the
+// repo are verified against the pins.
+func newKFRepoClient(conf *config.Config) (*http.Client, error) {
+	timeOut := time.Duration(conf.HttpClientTimeout) * time.Second
+	transport := &http.Transport{
+		ResponseHeaderTimeout: timeOut,
+		Proxy:                 http.ProxyFromEnvironment,
+	}
+
+	if len(conf.KFRepoProxy) > 0 {
+		proxyURL, err := url.Parse(conf.KFRepoProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KF repo proxy %s: %w", conf.KFRepoProxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if len(conf.KFRepoCABundle) > 0 || len(conf.KFRepoPinnedCertSHA256) > 0 {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if len(conf.KFRepoCABundle) > 0 {
+		pool, err := repoCertPool(conf.KFRepoCABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	if len(conf.KFRepoPinnedCertSHA256) > 0 {
+		verify, err := pinnedCertVerifier(conf.KFRepoPinnedCertSHA256)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.VerifyPeerCertificate = verify
+	}
+	return &http.Client{Transport: transport, Timeout: timeOut}, nil
+}
+
+// repoCertPool - system roots along with the certificates of the CA bundle
+func repoCertPool(caBundle string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KF repo CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in KF repo CA bundle %s", caBundle)
+	}
+	return pool, nil
+}
+
+// pinnedCertVerifier - accepts the verified chain when one of its certificates matches a pin, so the repo
+// certificate or the CA of an intercepting proxy can be pinned
+func pinnedCertVerifier(pins []string) (func([][]byte, [][]*x509.Certificate) error, error) {
+	pinned := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pin = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+		if b, err := hex.DecodeString(pin); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid pinned certificate SHA-256 %s", pin)
+		}
+		pinned[pin] = true
+	}
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			sum := sha256.Sum256(raw)
+			if pinned[hex.EncodeToString(sum[:])] {
+				return nil
+			}
+		}
+		return fmt.Errorf("KF repo certificate does not match the pinned certificates")
+	}, nil
+}
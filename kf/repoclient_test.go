@@ -0,0 +1,87 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+func Test_newKFRepoClient(t *testing.T) {
+	repo := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("artifact"))
+	}))
+	defer repo.Close()
+
+	der := repo.Certificate().Raw
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(der)
+	pin := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		conf    config.Config
+		wantErr bool
+	}{
+		{name: "UntrustedRepo", conf: config.Config{}, wantErr: true},
+		{name: "CABundle", conf: config.Config{KFRepoCABundle: caBundle}, wantErr: false},
+		{name: "Pinned", conf: config.Config{KFRepoCABundle: caBundle, KFRepoPinnedCertSHA256: []string{strings.ToUpper(pin)}}, wantErr: false},
+		{name: "PinMismatch", conf: config.Config{KFRepoCABundle: caBundle, KFRepoPinnedCertSHA256: []string{strings.Repeat("0", 64)}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newKFRepoClient(&tt.conf)
+			if err != nil {
+				t.Fatalf("newKFRepoClient() error = %v", err)
+			}
+			resp, err := client.Get(repo.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newKFRepoClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("artifact"))
+	}))
+	defer proxy.Close()
+
+	client, err := newKFRepoClient(&config.Config{KFRepoProxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("newKFRepoClient() error = %v", err)
+	}
+	resp, err := client.Get("http://kf-repo.example.com/ratelimiting/1.0/focal/l3af_ratelimiting.tar.gz")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://kf-repo.example.com/ratelimiting/1.0/focal/l3af_ratelimiting.tar.gz" {
+		t.Errorf("proxy received %q, want the artifact url", proxied)
+	}
+}
+
+func Test_pinnedCertVerifierInvalidPin(t *testing.T) {
+	if _, err := pinnedCertVerifier([]string{"not-a-sha256"}); err == nil {
+		t.Errorf("pinnedCertVerifier() error = nil, want invalid pin")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// kfRepoName - name of the KF repo url among its mirrors
+const kfRepoName = "kf-repo"
+
+// kfRepo - KF repo url or one of its mirrors
+type kfRepo struct {
+	name     string
+	url      string
+	priority int
+}
+
+// repoHealth - KF repos failing the downloads, a failed repo is tried after the healthy repos until the time
+var repoHealth = struct {
+	sync.Mutex
+	failedUntil map[string]time.Time
+}{failedUntil: make(map[string]time.Time)}
+
+// kfRepos - KF repo url and its mirrors in the failover order, the healthy repos by priority then the failed
+// repos by priority. Repos of equal priority are ordered by name.
+func kfRepos(conf *config.Config, now time.Time) []kfRepo {
+	repos := make([]kfRepo, 0, 1+len(conf.KFRepoMirrors))
+	if len(conf.KFRepoURL) > 0 {
+		repos = append(repos, kfRepo{name: kfRepoName, url: conf.KFRepoURL, priority: conf.KFRepoPriority})
+	}
+	for name, m := range conf.KFRepoMirrors {
+		repos = append(repos, kfRepo{name: name, url: m.URL, priority: m.Priority})
+	}
+
+	repoHealth.Lock()
+	defer repoHealth.Unlock()
+	failed := make(map[string]bool, len(repos))
+	for _, r := range repos {
+		failed[r.name] = now.Before(repoHealth.failedUntil[r.url])
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if failed[repos[i].name] != failed[repos[j].name] {
+			return failed[repos[j].name]
+		}
+		if repos[i].priority != repos[j].priority {
+			return repos[i].priority < repos[j].priority
+		}
+		return repos[i].name < repos[j].name
+	})
+	return repos
+}
+
+// markRepo - records the result of the download from the repo, the failed repo is tried last for the backoff
+func markRepo(conf *config.Config, repo kfRepo, err error, now time.Time) {
+	repoHealth.Lock()
+	defer repoHealth.Unlock()
+	if err == nil {
+		delete(repoHealth.failedUntil, repo.url)
+		return
+	}
+	stats.Add(1, stats.KFRepoFailures, repo.name)
+	repoHealth.failedUntil[repo.url] = now.Add(conf.KFRepoFailoverBackoff)
+}
+
+// fetchFromRepos - downloads the artifact of the program from the KF repo url or its mirrors in the failover
+// order, the next repo is tried when the download fails
+func fetchFromRepos(conf *config.Config, prog *models.BPFProgram) ([]byte, string, error) {
+	platform, err := GetPlatform()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find KF repo download path: %w", err)
+	}
+	repos := kfRepos(conf, timeNow())
+	if len(repos) == 0 {
+		return nil, "", fmt.Errorf("KF repo url is not configured")
+	}
+
+	var errs []string
+	for _, repo := range repos {
+		data, tag, err := fetchFromRepo(conf, repo, platform, prog)
+		markRepo(conf, repo, err, timeNow())
+		if err == nil {
+			if repo.name != kfRepoName {
+				log.Info().Msgf("artifact %s of program %s downloaded from mirror %s", prog.Artifact, prog.Name, repo.name)
+			}
+			return data, tag, nil
+		}
+		log.Warn().Err(err).Msgf("download of artifact %s from repo %s failed", prog.Artifact, repo.name)
+		errs = append(errs, fmt.Sprintf("%s: %v", repo.name, err))
+	}
+	return nil, "", fmt.Errorf("artifact %s is not available from the KF repos: %s", prog.Artifact, strings.Join(errs, "; "))
+}
+
+func fetchFromRepo(conf *config.Config, repo kfRepo, platform string, prog *models.BPFProgram) ([]byte, string, error) {
+	location, err := repoArtifactURL(repo.url, platform, prog)
+	if err != nil {
+		return nil, "", err
+	}
+	store, err := newRepoArtifactStore(conf, repo.url)
+	if err != nil {
+		return nil, "", err
+	}
+	log.Info().Msgf("Downloading - %s", location)
+	return store.Fetch(location)
+}
+
+// modifiedInRepos - checks the artifact against the version tag of the cached artifact in the first repo of
+// the failover order answering the check
+func modifiedInRepos(conf *config.Config, prog *models.BPFProgram, tag string) (bool, error) {
+	platform, err := GetPlatform()
+	if err != nil {
+		return false, fmt.Errorf("failed to find KF repo download path: %w", err)
+	}
+	var errs []string
+	for _, repo := range kfRepos(conf, timeNow()) {
+		location, err := repoArtifactURL(repo.url, platform, prog)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", repo.name, err))
+			continue
+		}
+		store, err := newRepoArtifactStore(conf, repo.url)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", repo.name, err))
+			continue
+		}
+		modified, err := store.Modified(location, tag)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", repo.name, err))
+			continue
+		}
+		return modified, nil
+	}
+	return false, fmt.Errorf("no KF repo answered the check: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,85 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func repoNames(repos []kfRepo) []string {
+	names := make([]string, 0, len(repos))
+	for _, r := range repos {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+func Test_fetchFromRepos(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command("/bin/sh", "-c", "echo focal") }
+	defer func() { execCommand = exec.Command }()
+	repoHealth.failedUntil = make(map[string]time.Time)
+	defer func() { repoHealth.failedUntil = make(map[string]time.Time) }()
+
+	primaryDown := true
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if primaryDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/l3af/ratelimiting/1.0/focal/l3af_ratelimiting.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("mirror"))
+	}))
+	defer mirror.Close()
+
+	conf := &config.Config{KFRepoURL: primary.URL, KFRepoFailoverBackoff: time.Minute, KFRepoMirrors: map[string]config.KFRepoMirror{
+		"us-west": {URL: mirror.URL + "/l3af", Priority: 10},
+		"us-east": {URL: "http://127.0.0.1:1/l3af", Priority: 20},
+	}}
+	now := time.Now()
+	if got := repoNames(kfRepos(conf, now)); len(got) != 3 || got[0] != kfRepoName || got[1] != "us-west" {
+		t.Fatalf("kfRepos() = %v, want the KF repo then the mirrors by priority", got)
+	}
+
+	prog := &models.BPFProgram{Name: "ratelimiting", Version: "1.0", Artifact: "l3af_ratelimiting.tar.gz"}
+	data, _, err := fetchFromRepos(conf, prog)
+	if err != nil || string(data) != "mirror" {
+		t.Fatalf("fetchFromRepos() = %q, %v, want the artifact of the mirror", data, err)
+	}
+	if got := repoNames(kfRepos(conf, now)); got[0] != "us-west" || got[2] != kfRepoName {
+		t.Errorf("kfRepos() = %v, want the failed KF repo last", got)
+	}
+	if got := repoNames(kfRepos(conf, now.Add(2*time.Minute))); got[0] != kfRepoName {
+		t.Errorf("kfRepos() = %v after the backoff, want the KF repo first", got)
+	}
+
+	// mirror is preferred until the backoff of the KF repo expires
+	primaryDown = false
+	if data, _, err := fetchFromRepos(conf, prog); err != nil || string(data) != "mirror" {
+		t.Errorf("fetchFromRepos() = %q, %v, want the artifact of the healthy mirror", data, err)
+	}
+
+	prog.Version = "2.0"
+	primaryDown = true
+	if _, _, err := fetchFromRepos(conf, prog); err == nil {
+		t.Errorf("fetchFromRepos() error = nil, want the artifact missing in all the repos")
+	}
+}
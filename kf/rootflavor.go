@@ -0,0 +1,141 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// rootFlavor - flavor of the root programs of the interface, the name is empty for the interfaces of no flavor
+func rootFlavor(conf *config.Config, ifaceName string) (string, config.RootFlavor) {
+	for name, flavor := range conf.RootFlavors {
+		for _, iface := range flavor.Ifaces {
+			if strings.TrimSpace(iface) == ifaceName {
+				return name, flavor
+			}
+		}
+	}
+	return "", config.RootFlavor{}
+}
+
+// flavorValue - value of the root program flavor, the value of the root program groups when empty
+func flavorValue(value, defaultValue string) string {
+	if len(value) > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// rootProgram - config of the root program of the interface in the direction, by the flavor of the interface
+func rootProgram(conf *config.Config, ifaceName, direction, progType string) (models.BPFProgram, error) {
+	_, flavor := rootFlavor(conf, ifaceName)
+	prog := models.BPFProgram{
+		AdminStatus: models.Enabled,
+		SeqID:       0,
+		StartArgs:   map[string]interface{}{},
+		StopArgs:    map[string]interface{}{},
+		StatusArgs:  map[string]interface{}{},
+	}
+	switch progType {
+	case models.XDPType:
+		prog.Name = conf.XDPRootProgramName
+		prog.Artifact = flavorValue(flavor.XDPArtifact, conf.XDPRootProgramArtifact)
+		prog.MapName = flavorValue(flavor.XDPMapName, conf.XDPRootProgramMapName)
+		prog.Version = flavorValue(flavor.XDPVersion, conf.XDPRootProgramVersion)
+		prog.UserProgramDaemon = conf.XDPRootProgramUserProgramDaemon
+		prog.CmdStart = flavorValue(flavor.XDPCommand, conf.XDPRootProgramCommand)
+		// root program of the offload capability is offloaded to the NIC along with the chained programs
+		for _, capability := range rootCapabilities(conf, ifaceName, direction) {
+			if strings.TrimSpace(capability) == rootCapabilityOffload {
+				prog.XDPMode = XDPModeOffload
+			}
+		}
+	case models.TCType:
+		prog.Name = conf.TCRootProgramName
+		prog.Artifact = flavorValue(flavor.TCArtifact, conf.TCRootProgramArtifact)
+		prog.Version = flavorValue(flavor.TCVersion, conf.TCRootProgramVersion)
+		prog.UserProgramDaemon = conf.TCRootProgramUserProgramDaemon
+		prog.CmdStart = flavorValue(flavor.TCCommand, conf.TCRootProgramCommand)
+		if direction == models.IngressType {
+			prog.MapName = flavorValue(flavor.TCIngressMapName, conf.TCRootProgramIngressMapName)
+		} else if direction == models.EgressType {
+			prog.MapName = flavorValue(flavor.TCEgressMapName, conf.TCRootProgramEgressMapName)
+		}
+	default:
+		return prog, fmt.Errorf("unknown direction %s for root program in iface %s", direction, ifaceName)
+	}
+	prog.CmdStop = prog.CmdStart
+	return prog, nil
+}
+
+// rootCapabilities - capabilities of the root program of the interface in the direction
+func rootCapabilities(conf *config.Config, ifaceName, direction string) []string {
+	name, flavor := rootFlavor(conf, ifaceName)
+	if direction == models.XDPIngressType {
+		if len(name) > 0 && flavor.XDPCapabilities != nil {
+			return flavor.XDPCapabilities
+		}
+		return conf.XDPRootProgramCapabilities
+	}
+	if len(name) > 0 && flavor.TCCapabilities != nil {
+		return flavor.TCCapabilities
+	}
+	return conf.TCRootProgramCapabilities
+}
+
+// rootMapNames - prog maps of the root programs of all the flavors, the programs must not declare them
+func rootMapNames(conf *config.Config) []string {
+	names := []string{conf.XDPRootProgramMapName, conf.TCRootProgramIngressMapName, conf.TCRootProgramEgressMapName}
+	flavors := make([]string, 0, len(conf.RootFlavors))
+	for name := range conf.RootFlavors {
+		flavors = append(flavors, name)
+	}
+	sort.Strings(flavors)
+	for _, name := range flavors {
+		flavor := conf.RootFlavors[name]
+		for _, mapName := range []string{flavor.XDPMapName, flavor.TCIngressMapName, flavor.TCEgressMapName} {
+			if len(mapName) > 0 {
+				names = append(names, mapName)
+			}
+		}
+	}
+	return names
+}
+
+// ValidateRootCapabilities - Verifies the root programs of the interfaces provide the capabilities the chained
+// programs require
+func (c *NFConfigs) ValidateRootCapabilities(bpfProgs []models.L3afBPFPrograms) error {
+	chain := c.hostConfig != nil && c.hostConfig.BpfChainingEnabled
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil || len(ref.prog.RequiresRoot) == 0 || !loadedStatus(ref.prog.AdminStatus) {
+				continue
+			}
+			if !chain {
+				return fmt.Errorf("program %s on iface %s requires root capabilities, which require bpf chaining", ref.prog.Name, cfg.Iface)
+			}
+			provided := make(map[string]bool)
+			for _, capability := range rootCapabilities(c.hostConfig, cfg.Iface, ref.direction) {
+				provided[strings.TrimSpace(capability)] = true
+			}
+			for _, capability := range ref.prog.RequiresRoot {
+				if provided[capability] {
+					continue
+				}
+				flavor, _ := rootFlavor(c.hostConfig, cfg.Iface)
+				if len(flavor) == 0 {
+					flavor = "default"
+				}
+				return fmt.Errorf("program %s on iface %s direction %s requires root capability %s, not provided by root flavor %s",
+					ref.prog.Name, cfg.Iface, ref.direction, capability, flavor)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func rootFlavorConfig() *config.Config {
+	return &config.Config{
+		BpfChainingEnabled:          true,
+		XDPRootProgramName:          "xdp_root",
+		XDPRootProgramArtifact:      "l3af_xdp_root.tar.gz",
+		XDPRootProgramMapName:       "/sys/fs/bpf/xdp_root_array",
+		XDPRootProgramCommand:       "xdp_root",
+		XDPRootProgramVersion:       "1.01",
+		TCRootProgramName:           "tc_root",
+		TCRootProgramArtifact:       "l3af_tc_root.tar.gz",
+		TCRootProgramIngressMapName: "/sys/fs/bpf/tc/globals/tc_ingress_root_array",
+		TCRootProgramEgressMapName:  "/sys/fs/bpf/tc/globals/tc_egress_root_array",
+		TCRootProgramCommand:        "tc_root",
+		TCRootProgramVersion:        "1.0",
+		TCRootProgramCapabilities:   []string{"tc-counters"},
+		RootFlavors: map[string]config.RootFlavor{
+			"smartnic": {
+				Ifaces:          []string{"eth2", " eth3"},
+				XDPArtifact:     "l3af_xdp_root_offload.tar.gz",
+				XDPVersion:      "1.0",
+				XDPCommand:      "xdp_root_offload",
+				XDPMapName:      "/sys/fs/bpf/xdp_root_offload_array",
+				XDPCapabilities: []string{"hw-offload"},
+			},
+		},
+	}
+}
+
+func Test_rootProgram(t *testing.T) {
+	conf := rootFlavorConfig()
+
+	prog, err := rootProgram(conf, "eth0", models.XDPIngressType, models.XDPType)
+	if err != nil {
+		t.Fatalf("rootProgram() error = %v", err)
+	}
+	if prog.Artifact != "l3af_xdp_root.tar.gz" || prog.MapName != "/sys/fs/bpf/xdp_root_array" || prog.CmdStop != "xdp_root" {
+		t.Errorf("rootProgram() = %+v, want the root program of the xdp-root-program group", prog)
+	}
+
+	prog, err = rootProgram(conf, "eth3", models.XDPIngressType, models.XDPType)
+	if err != nil {
+		t.Fatalf("rootProgram() error = %v", err)
+	}
+	if prog.Name != "xdp_root" || prog.Artifact != "l3af_xdp_root_offload.tar.gz" || prog.Version != "1.0" ||
+		prog.MapName != "/sys/fs/bpf/xdp_root_offload_array" || prog.CmdStart != "xdp_root_offload" || prog.CmdStop != "xdp_root_offload" {
+		t.Errorf("rootProgram() = %+v, want the root program of the smartnic flavor", prog)
+	}
+
+	// empty values of the flavor are the values of the tc-root-program group
+	prog, err = rootProgram(conf, "eth2", models.EgressType, models.TCType)
+	if err != nil {
+		t.Fatalf("rootProgram() error = %v", err)
+	}
+	if prog.Artifact != "l3af_tc_root.tar.gz" || prog.MapName != "/sys/fs/bpf/tc/globals/tc_egress_root_array" {
+		t.Errorf("rootProgram() = %+v, want the tc root program of the tc-root-program group", prog)
+	}
+
+	if _, err := rootProgram(conf, "eth0", models.XDPIngressType, "bogus"); err == nil {
+		t.Errorf("rootProgram() error = nil, want the unknown program type rejected")
+	}
+}
+
+func Test_rootCapabilities(t *testing.T) {
+	conf := rootFlavorConfig()
+	if got := rootCapabilities(conf, "eth2", models.XDPIngressType); !reflect.DeepEqual(got, []string{"hw-offload"}) {
+		t.Errorf("rootCapabilities() = %v, want the capabilities of the flavor", got)
+	}
+	if got := rootCapabilities(conf, "eth2", models.IngressType); !reflect.DeepEqual(got, []string{"tc-counters"}) {
+		t.Errorf("rootCapabilities() = %v, want the capabilities of the tc-root-program group", got)
+	}
+	if got := rootCapabilities(conf, "eth0", models.XDPIngressType); len(got) != 0 {
+		t.Errorf("rootCapabilities() = %v, want none", got)
+	}
+	want := []string{"/sys/fs/bpf/xdp_root_array", "/sys/fs/bpf/tc/globals/tc_ingress_root_array",
+		"/sys/fs/bpf/tc/globals/tc_egress_root_array", "/sys/fs/bpf/xdp_root_offload_array"}
+	if got := rootMapNames(conf); !reflect.DeepEqual(got, want) {
+		t.Errorf("rootMapNames() = %v, want %v", got, want)
+	}
+}
+
+func TestNFConfigs_ValidateRootCapabilities(t *testing.T) {
+	progs := func(iface, adminStatus string) []models.L3afBPFPrograms {
+		return []models.L3afBPFPrograms{{
+			Iface: iface,
+			BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "offload-acl", AdminStatus: adminStatus, RequiresRoot: []string{"hw-offload"}}},
+			},
+		}}
+	}
+	c := &NFConfigs{hostConfig: rootFlavorConfig()}
+	if err := c.ValidateRootCapabilities(progs("eth2", models.Enabled)); err != nil {
+		t.Errorf("ValidateRootCapabilities() error = %v, want the capability of the smartnic root", err)
+	}
+	if err := c.ValidateRootCapabilities(progs("eth0", models.Enabled)); err == nil {
+		t.Errorf("ValidateRootCapabilities() error = nil, want the program of the default root rejected")
+	}
+	if err := c.ValidateRootCapabilities(progs("eth0", models.Disabled)); err != nil {
+		t.Errorf("ValidateRootCapabilities() error = %v, want the disabled program not verified", err)
+	}
+	c.hostConfig.BpfChainingEnabled = false
+	if err := c.ValidateRootCapabilities(progs("eth2", models.Enabled)); err == nil {
+		t.Errorf("ValidateRootCapabilities() error = nil, want the program rejected without chaining")
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// ruleEntries - entries of the rules, one entry per line, blank lines are skipped
+func ruleEntries(rules string) []string {
+	var entries []string
+	for _, line := range strings.Split(rules, "\n") {
+		if entry := strings.TrimSpace(line); len(entry) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// joinRuleEntries - rules of the entries, one entry per line
+func joinRuleEntries(entries []string) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	return strings.Join(entries, "\n") + "\n"
+}
+
+// patchRules - rules with the entries of the patch removed and added, in the order of the rules followed by
+// the added entries, along with the entries actually added and removed
+func patchRules(rules string, patch models.L3afDRulesPatch) (string, []string, []string) {
+	remove := make(map[string]bool, len(patch.Remove))
+	for _, entry := range patch.Remove {
+		if entry = strings.TrimSpace(entry); len(entry) > 0 {
+			remove[entry] = true
+		}
+	}
+	var kept, added, removed []string
+	present := make(map[string]bool)
+	for _, entry := range ruleEntries(rules) {
+		if remove[entry] {
+			removed = append(removed, entry)
+			continue
+		}
+		present[entry] = true
+		kept = append(kept, entry)
+	}
+	for _, entry := range patch.Add {
+		if entry = strings.TrimSpace(entry); len(entry) > 0 && !present[entry] {
+			present[entry] = true
+			kept = append(kept, entry)
+			added = append(added, entry)
+		}
+	}
+	return joinRuleEntries(kept), added, removed
+}
+
+// diffRuleEntries - patch of the entries changing the rules from to the rules to
+func diffRuleEntries(from, to string) models.L3afDRulesPatch {
+	var patch models.L3afDRulesPatch
+	fromEntries, toEntries := make(map[string]bool), make(map[string]bool)
+	for _, entry := range ruleEntries(from) {
+		fromEntries[entry] = true
+	}
+	for _, entry := range ruleEntries(to) {
+		toEntries[entry] = true
+		if !fromEntries[entry] {
+			patch.Add = append(patch.Add, entry)
+		}
+	}
+	for _, entry := range ruleEntries(from) {
+		if !toEntries[entry] {
+			patch.Remove = append(patch.Remove, entry)
+		}
+	}
+	return patch
+}
+
+// ruleMapEntry - key and value of the rule entry <key> [<value>] in the map layout, the value is zero when
+// the entry has none
+func ruleMapEntry(entry, keyType, valueType string, info *ebpf.MapInfo) ([]byte, []byte, error) {
+	fields := strings.Fields(entry)
+	if len(fields) > 2 {
+		return nil, nil, fmt.Errorf("rule %q is not <key> [<value>]", entry)
+	}
+	key, err := encodeMapValue(keyType, fields[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key of rule %q: %w", entry, err)
+	}
+	if len(key) != int(info.KeySize) {
+		return nil, nil, fmt.Errorf("key size %d of rule %q does not match map key size %d", len(key), entry, info.KeySize)
+	}
+	value := make([]byte, info.ValueSize)
+	if len(fields) == 2 {
+		if value, err = encodeMapValue(valueType, fields[1]); err != nil {
+			return nil, nil, fmt.Errorf("invalid value of rule %q: %w", entry, err)
+		}
+		if len(value) != int(info.ValueSize) {
+			return nil, nil, fmt.Errorf("value size %d of rule %q does not match map value size %d", len(value), entry, info.ValueSize)
+		}
+	}
+	return key, value, nil
+}
+
+// applyRulesToMap - writes the added entries to the map and deletes the removed entries, all the entries are
+// encoded before the map is changed
+func applyRulesToMap(m ebpfMap, keyType, valueType string, added, removed []string) error {
+	info, err := m.Info()
+	if err != nil {
+		return fmt.Errorf("fetching map info failed %v", err)
+	}
+	switch info.Type {
+	case ebpf.Hash, ebpf.LRUHash, ebpf.LPMTrie:
+	default:
+		return fmt.Errorf("map type %s is not supported for rules", info.Type)
+	}
+
+	removeKeys := make([][]byte, 0, len(removed))
+	for _, entry := range removed {
+		key, _, err := ruleMapEntry(entry, keyType, valueType, info)
+		if err != nil {
+			return err
+		}
+		removeKeys = append(removeKeys, key)
+	}
+	addKeys, addValues := make([][]byte, 0, len(added)), make([][]byte, 0, len(added))
+	for _, entry := range added {
+		key, value, err := ruleMapEntry(entry, keyType, valueType, info)
+		if err != nil {
+			return err
+		}
+		addKeys = append(addKeys, key)
+		addValues = append(addValues, value)
+	}
+
+	for _, key := range removeKeys {
+		if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("failed to delete rule key %x: %w", key, err)
+		}
+	}
+	for i, key := range addKeys {
+		if err := m.Update(key, addValues[i], ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("failed to add rule key %x: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// loadRulesMap - writes all the rule entries to the rules map of the started program
+func (b *BPF) loadRulesMap() error {
+	entries := ruleEntries(b.Program.Rules)
+	if len(b.Program.RulesMap) == 0 || len(entries) == 0 {
+		return nil
+	}
+	m, err := openProgramMap(b, b.Program.RulesMap)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if err := applyRulesToMap(m, b.Program.RulesKeyType, b.Program.RulesValueType, entries, nil); err != nil {
+		return fmt.Errorf("failed to load rules map %s of program %s: %w", b.Program.RulesMap, b.Program.Name, err)
+	}
+	return nil
+}
+
+// updateRulesFile - appends the added entries to the rules file when no entry is removed, the file is
+// replaced otherwise
+func updateRulesFile(fileName, rules string, added, removed []string) error {
+	if len(removed) == 0 && fileExists(fileName) {
+		f, err := appFS.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open rules file %s: %w", fileName, err)
+		}
+		_, err = f.Write([]byte(joinRuleEntries(added)))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to append to rules file %s: %w", fileName, err)
+		}
+		return nil
+	}
+
+	// readers of the rules file never see a partial file
+	tmpName := filepath.Join(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp")
+	if err := appFS.WriteFile(tmpName, []byte(rules), 0600); err != nil {
+		return fmt.Errorf("failed to write rules file %s: %w", tmpName, err)
+	}
+	if err := appFS.Rename(tmpName, fileName); err != nil {
+		return fmt.Errorf("failed to replace rules file %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// applyRulesDelta - applies the added and removed entries to the rules map and the rules file of the running
+// program without restarting it, the rules are validated before anything is changed
+func (b *BPF) applyRulesDelta(direction, rules string, added, removed []string) error {
+	if err := b.validateRules(rules); err != nil {
+		return err
+	}
+
+	if len(b.Program.RulesMap) > 0 {
+		m, err := openProgramMap(b, b.Program.RulesMap)
+		if err != nil {
+			return err
+		}
+		err = applyRulesToMap(m, b.Program.RulesKeyType, b.Program.RulesValueType, added, removed)
+		m.Close()
+		if err != nil {
+			return fmt.Errorf("failed to update rules map %s of program %s: %w", b.Program.RulesMap, b.Program.Name, err)
+		}
+	}
+
+	if len(b.Program.RulesFile) > 1 {
+		if err := validateNFFileName(b.Program.RulesFile); err != nil {
+			return err
+		}
+		dir, err := b.nfFilesDir(direction)
+		if err != nil {
+			return err
+		}
+		if err := updateRulesFile(filepath.Join(dir, b.Program.RulesFile), rules, added, removed); err != nil {
+			return err
+		}
+	}
+
+	b.Program.Rules = rules
+	log.Info().Msgf("rules of program %s direction %s updated, %d entries added %d removed", b.Program.Name, direction, len(added), len(removed))
+	return nil
+}
+
+// PatchRules - adds and removes the rule entries of the program running on the interface without restarting
+// it, the change is recorded in the audit log and saved to the config store
+func (c *NFConfigs) PatchRules(iface, direction, program string, patch models.L3afDRulesPatch, remote string) (models.L3afDRulesPatchResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bpf, err := c.findBPF(iface, direction, program)
+	if err != nil {
+		return models.L3afDRulesPatchResult{}, err
+	}
+	rules, added, removed := patchRules(bpf.Program.Rules, patch)
+	result := models.L3afDRulesPatchResult{Added: added, Removed: removed, Rules: len(ruleEntries(rules))}
+	if len(added) == 0 && len(removed) == 0 {
+		return result, nil
+	}
+	if err := bpf.applyRulesDelta(direction, rules, added, removed); err != nil {
+		return models.L3afDRulesPatchResult{}, err
+	}
+	bpf.updateControl()
+
+	c.Audit("rules-patch", remote, map[string]string{
+		"iface":     iface,
+		"direction": direction,
+		"program":   program,
+		"added":     strings.Join(added, ","),
+		"removed":   strings.Join(removed, ","),
+	})
+	if err := c.SaveConfigsToConfigStore(); err != nil {
+		log.Error().Err(err).Msgf("failed to save configs after the rules patch of program %s", program)
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_patchRules(t *testing.T) {
+	rules := "10.0.0.1 1\n\n10.0.0.2 1\n10.0.0.3 2\n"
+	got, added, removed := patchRules(rules, models.L3afDRulesPatch{
+		Add:    []string{"10.0.0.4 1", "10.0.0.1 1", " "},
+		Remove: []string{"10.0.0.2 1", "10.0.0.9 1"},
+	})
+	if want := "10.0.0.1 1\n10.0.0.3 2\n10.0.0.4 1\n"; got != want {
+		t.Errorf("patchRules() rules = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(added, []string{"10.0.0.4 1"}) || !reflect.DeepEqual(removed, []string{"10.0.0.2 1"}) {
+		t.Errorf("patchRules() added = %v removed = %v", added, removed)
+	}
+
+	patch := diffRuleEntries(rules, got)
+	if !reflect.DeepEqual(patch.Add, []string{"10.0.0.4 1"}) || !reflect.DeepEqual(patch.Remove, []string{"10.0.0.2 1"}) {
+		t.Errorf("diffRuleEntries() = %+v", patch)
+	}
+}
+
+func Test_applyRulesToMap(t *testing.T) {
+	m := &fakeHashMap{mapType: ebpf.Hash, entries: map[string][]byte{
+		string([]byte{0x00, 0x50}): {1},
+		string([]byte{0x01, 0xbb}): {1},
+	}}
+	if err := applyRulesToMap(m, "be16", "u8", []string{"8080 2", "22"}, []string{"443", "53"}); err != nil {
+		t.Fatalf("applyRulesToMap() error = %v", err)
+	}
+	want := map[string][]byte{
+		string([]byte{0x00, 0x50}): {1},
+		string([]byte{0x1f, 0x90}): {2},
+		string([]byte{0x00, 0x16}): {0},
+	}
+	if !reflect.DeepEqual(m.entries, want) {
+		t.Errorf("map entries = %v, want %v", m.entries, want)
+	}
+
+	// invalid entries leave the map unchanged
+	if err := applyRulesToMap(m, "be16", "u8", []string{"9000 1", "70000 1"}, nil); err == nil {
+		t.Errorf("applyRulesToMap() error = nil, want invalid key")
+	}
+	if _, ok := m.entries[string([]byte{0x23, 0x28})]; ok {
+		t.Errorf("map is changed by the invalid rules")
+	}
+}
+
+func Test_updateRulesFile(t *testing.T) {
+	fileName := "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/xdpingress/rules.txt"
+	tests := []struct {
+		name    string
+		rules   string
+		added   []string
+		removed []string
+		want    string
+	}{
+		{name: "Append", rules: "a\nb\nc\n", added: []string{"c"}, want: "a\nb\nc\n"},
+		{name: "Replace", rules: "a\nc\n", added: []string{"c"}, removed: []string{"b"}, want: "a\nc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t, map[string]string{fileName: "a\nb\n"})
+			if err := updateRulesFile(fileName, tt.rules, tt.added, tt.removed); err != nil {
+				t.Fatalf("updateRulesFile() error = %v", err)
+			}
+			got, err := appFS.ReadFile(fileName)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("rules file = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// Built-in rules validators, command validators are prefixed with rulesValidatorCommand
+const (
+	rulesValidatorJSON       = "json"
+	rulesValidatorJSONSchema = "json-schema"
+	rulesValidatorCIDRList   = "cidr-list"
+	rulesValidatorCommand    = "command:"
+)
+
+// rulesValidatorTimeout - time the validation command may take before it is killed and the rules rejected
+const rulesValidatorTimeout = 30 * time.Second
+
+// ErrInvalidRules - rules of the program are rejected by its rules validator
+var ErrInvalidRules = errors.New("invalid rules")
+
+// validateRulesConfig - Verifies the rules validator of the program and its schema
+func validateRulesConfig(prog *models.BPFProgram) error {
+	switch v := prog.RulesValidator; {
+	case len(v) == 0:
+		if len(prog.RulesSchema) > 0 {
+			return fmt.Errorf("rules schema requires the %s rules validator", rulesValidatorJSONSchema)
+		}
+	case v == rulesValidatorJSON, v == rulesValidatorCIDRList:
+	case v == rulesValidatorJSONSchema:
+		if len(prog.RulesSchema) == 0 {
+			return fmt.Errorf("%s rules validator requires the rules schema", rulesValidatorJSONSchema)
+		}
+		var schema interface{}
+		if err := json.Unmarshal(prog.RulesSchema, &schema); err != nil {
+			return fmt.Errorf("rules schema is not valid json: %v", err)
+		}
+		if err := checkJSONSchema(schema, "#"); err != nil {
+			return fmt.Errorf("rules schema: %v", err)
+		}
+	case strings.HasPrefix(v, rulesValidatorCommand):
+		fields := strings.Fields(strings.TrimPrefix(v, rulesValidatorCommand))
+		if len(fields) == 0 {
+			return fmt.Errorf("rules validator command is empty")
+		}
+		if filepath.IsAbs(fields[0]) {
+			return fmt.Errorf("rules validator command %s is not in the artifact directory", fields[0])
+		}
+		if err := validateNFFileName(fields[0]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown rules validator %q, expected %s, %s, %s or %s<command>", v,
+			rulesValidatorJSON, rulesValidatorJSONSchema, rulesValidatorCIDRList, rulesValidatorCommand)
+	}
+	return nil
+}
+
+// checkRules - runs the built-in rules validator of the program against the rules, validation commands
+// need the artifact and are run by validateRules
+func checkRules(prog *models.BPFProgram, rules string) error {
+	var err error
+	switch prog.RulesValidator {
+	case rulesValidatorJSON:
+		var v interface{}
+		err = json.Unmarshal([]byte(rules), &v)
+	case rulesValidatorJSONSchema:
+		var schema, v interface{}
+		if err = json.Unmarshal(prog.RulesSchema, &schema); err != nil {
+			return fmt.Errorf("rules schema is not valid json: %v", err)
+		}
+		if err = json.Unmarshal([]byte(rules), &v); err == nil {
+			err = validateJSONSchema(schema, v, "#")
+		}
+	case rulesValidatorCIDRList:
+		err = checkCIDRList(rules)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidRules, prog.RulesValidator, err)
+	}
+	return nil
+}
+
+// ValidateRules - Verifies the rules validators of the programs and runs the built-in validators against
+// the rules, so malformed rules are rejected before any program is changed
+func ValidateRules(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateRulesConfig(ref.prog); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+			if len(ref.prog.Rules) == 0 {
+				continue
+			}
+			if err := checkRules(ref.prog, ref.prog.Rules); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRules - runs the rules validator of the program against the rules before they are written to
+// the rules file or the rules map, the validation command gets the rules on stdin and rejects them with
+// a non-zero exit
+func (b *BPF) validateRules(rules string) error {
+	if len(b.Program.RulesValidator) == 0 {
+		return nil
+	}
+	if err := validateRulesConfig(&b.Program); err != nil {
+		return fmt.Errorf("rules validator of program %s: %w", b.Program.Name, err)
+	}
+	if !strings.HasPrefix(b.Program.RulesValidator, rulesValidatorCommand) {
+		if err := checkRules(&b.Program, rules); err != nil {
+			return fmt.Errorf("program %s: %w", b.Program.Name, err)
+		}
+		return nil
+	}
+
+	if len(b.FilePath) == 0 {
+		return fmt.Errorf("rules validator command of program %s requires the artifact", b.Program.Name)
+	}
+	fields := strings.Fields(strings.TrimPrefix(b.Program.RulesValidator, rulesValidatorCommand))
+	cmd, err := newNFCommand(filepath.Join(b.FilePath, fields[0]), fields[1:]...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(rules)
+	if out, err := runNFCommand(cmd, rulesValidatorTimeout); err != nil {
+		return fmt.Errorf("program %s: %w: %s: %v: %s", b.Program.Name, ErrInvalidRules, fields[0], err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// checkCIDRList - first field of each entry is an IP address or a CIDR, # starts a comment line
+func checkCIDRList(rules string) error {
+	for i, line := range strings.Split(rules, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if strings.Contains(fields[0], "/") {
+			if _, _, err := net.ParseCIDR(fields[0]); err != nil {
+				return fmt.Errorf("line %d: invalid CIDR %q", i+1, fields[0])
+			}
+			continue
+		}
+		if net.ParseIP(fields[0]) == nil {
+			return fmt.Errorf("line %d: invalid IP address %q", i+1, fields[0])
+		}
+	}
+	return nil
+}
+
+// checkJSONSchema - schema only uses the keywords supported by validateJSONSchema
+func checkJSONSchema(schema interface{}, path string) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: schema is not an object or a boolean", path)
+	}
+	for k, v := range s {
+		switch k {
+		case "$schema", "$id", "$comment", "title", "description", "enum", "required":
+		case "type":
+			if _, err := schemaTypes(v); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		case "properties":
+			props, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: properties is not an object", path)
+			}
+			for name, prop := range props {
+				if err := checkJSONSchema(prop, path+"/properties/"+name); err != nil {
+					return err
+				}
+			}
+		case "items", "additionalProperties":
+			if err := checkJSONSchema(v, path+"/"+k); err != nil {
+				return err
+			}
+		case "minimum", "maximum", "minLength", "maxLength", "minItems", "maxItems":
+			if _, ok := v.(float64); !ok {
+				return fmt.Errorf("%s: %s is not a number", path, k)
+			}
+		case "pattern":
+			p, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s: pattern is not a string", path)
+			}
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("%s: invalid pattern: %v", path, err)
+			}
+		default:
+			return fmt.Errorf("%s: schema keyword %s is not supported", path, k)
+		}
+	}
+	return nil
+}
+
+// schemaTypes - types of the type keyword, a type name or a list of type names
+func schemaTypes(v interface{}) ([]string, error) {
+	var types []string
+	switch t := v.(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, e := range t {
+			name, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("type is not a string or a list of strings")
+			}
+			types = append(types, name)
+		}
+	default:
+		return nil, fmt.Errorf("type is not a string or a list of strings")
+	}
+	for _, name := range types {
+		switch name {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return nil, fmt.Errorf("unknown type %q", name)
+		}
+	}
+	return types, nil
+}
+
+// jsonTypeOf - schema type of the decoded json value, whole numbers are integers
+func jsonTypeOf(v interface{}) string {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if t == math.Trunc(t) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// validateJSONSchema - validates the decoded json value against the subset of json schema verified by
+// checkJSONSchema, the path of the first invalid value is reported
+func validateJSONSchema(schema, v interface{}, path string) error {
+	if b, ok := schema.(bool); ok {
+		if !b {
+			return fmt.Errorf("%s: value is not allowed", path)
+		}
+		return nil
+	}
+	s, _ := schema.(map[string]interface{})
+
+	if t, ok := s["type"]; ok {
+		types, err := schemaTypes(t)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		got, match := jsonTypeOf(v), false
+		for _, want := range types {
+			if want == got || (want == "number" && got == "integer") {
+				match = true
+			}
+		}
+		if !match {
+			return fmt.Errorf("%s: %s is not of type %s", path, got, strings.Join(types, " or "))
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		match := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				match = true
+			}
+		}
+		if !match {
+			return fmt.Errorf("%s: value is not one of the enum values", path)
+		}
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, ok := t[name]; !ok {
+						return fmt.Errorf("%s: required property %s is missing", path, name)
+					}
+				}
+			}
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		names := make([]string, 0, len(t))
+		for name := range t {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := props[name]; ok {
+				if err := validateJSONSchema(prop, t[name], path+"/"+name); err != nil {
+					return err
+				}
+			} else if additional, ok := s["additionalProperties"]; ok {
+				if err := validateJSONSchema(additional, t[name], path+"/"+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if n, ok := s["minItems"].(float64); ok && float64(len(t)) < n {
+			return fmt.Errorf("%s: %d items are fewer than %v", path, len(t), n)
+		}
+		if n, ok := s["maxItems"].(float64); ok && float64(len(t)) > n {
+			return fmt.Errorf("%s: %d items are more than %v", path, len(t), n)
+		}
+		if items, ok := s["items"]; ok {
+			for i, e := range t {
+				if err := validateJSONSchema(items, e, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(t)
+		if min, ok := s["minLength"].(float64); ok && float64(n) < min {
+			return fmt.Errorf("%s: length %d is shorter than %v", path, n, min)
+		}
+		if max, ok := s["maxLength"].(float64); ok && float64(n) > max {
+			return fmt.Errorf("%s: length %d is longer than %v", path, n, max)
+		}
+		if p, ok := s["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %v", path, err)
+			}
+			if !re.MatchString(t) {
+				return fmt.Errorf("%s: %q does not match pattern %s", path, t, p)
+			}
+		}
+	case float64:
+		if min, ok := s["minimum"].(float64); ok && t < min {
+			return fmt.Errorf("%s: %v is less than the minimum %v", path, t, min)
+		}
+		if max, ok := s["maximum"].(float64); ok && t > max {
+			return fmt.Errorf("%s: %v is more than the maximum %v", path, t, max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestValidateRules(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"required": ["ports"],
+		"properties": {
+			"ports": {"type": "array", "minItems": 1, "items": {"type": "integer", "minimum": 1, "maximum": 65535}},
+			"action": {"enum": ["drop", "pass"]},
+			"name": {"type": "string", "pattern": "^[a-z]+$"}
+		},
+		"additionalProperties": false
+	}`)
+	tests := []struct {
+		name    string
+		prog    models.BPFProgram
+		wantErr bool
+	}{
+		{name: "NoValidator", prog: models.BPFProgram{Rules: "not json"}},
+		{name: "JSON", prog: models.BPFProgram{RulesValidator: "json", Rules: `{"a": 1}`}},
+		{name: "InvalidJSON", prog: models.BPFProgram{RulesValidator: "json", Rules: `{"a": 1`}, wantErr: true},
+		{name: "Schema", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: schema, Rules: `{"ports": [80, 443], "action": "drop", "name": "web"}`}},
+		{name: "SchemaRequired", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: schema, Rules: `{"action": "drop"}`}, wantErr: true},
+		{name: "SchemaMaximum", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: schema, Rules: `{"ports": [70000]}`}, wantErr: true},
+		{name: "SchemaInteger", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: schema, Rules: `{"ports": [80.5]}`}, wantErr: true},
+		{name: "SchemaEnum", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: schema, Rules: `{"ports": [80], "action": "reject"}`}, wantErr: true},
+		{name: "SchemaPattern", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: schema, Rules: `{"ports": [80], "name": "Web1"}`}, wantErr: true},
+		{name: "SchemaAdditional", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: schema, Rules: `{"ports": [80], "extra": true}`}, wantErr: true},
+		{name: "SchemaMissing", prog: models.BPFProgram{RulesValidator: "json-schema", Rules: `{}`}, wantErr: true},
+		{name: "SchemaUnsupported", prog: models.BPFProgram{RulesValidator: "json-schema", RulesSchema: json.RawMessage(`{"oneOf": []}`)}, wantErr: true},
+		{name: "CIDRList", prog: models.BPFProgram{RulesValidator: "cidr-list", Rules: "# blocked\n10.0.0.0/8 1\n192.168.1.1\n\n2001:db8::/32\n"}},
+		{name: "InvalidCIDR", prog: models.BPFProgram{RulesValidator: "cidr-list", Rules: "10.0.0.0/33\n"}, wantErr: true},
+		{name: "InvalidIP", prog: models.BPFProgram{RulesValidator: "cidr-list", Rules: "10.0.0.256\n"}, wantErr: true},
+		{name: "Command", prog: models.BPFProgram{RulesValidator: "command:validate_rules --strict", Rules: "anything"}},
+		{name: "CommandOutside", prog: models.BPFProgram{RulesValidator: "command:../bin/validate"}, wantErr: true},
+		{name: "CommandAbsolute", prog: models.BPFProgram{RulesValidator: "command:/bin/true"}, wantErr: true},
+		{name: "Unknown", prog: models.BPFProgram{RulesValidator: "yaml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.prog.Name = "ratelimiting"
+			bpfProgs := []models.L3afBPFPrograms{{Iface: "eth0", BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{&tt.prog}}}}
+			if err := ValidateRules(bpfProgs); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBPF_validateRules(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nif grep -q bad; then echo \"bad entry\" >&2; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(dir, "validate_rules"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	b := &BPF{FilePath: dir, Program: models.BPFProgram{Name: "ratelimiting", RulesValidator: "command:validate_rules"}}
+	if err := b.validateRules("good\n"); err != nil {
+		t.Errorf("validateRules() error = %v", err)
+	}
+	if err := b.validateRules("good\nbad\n"); !errors.Is(err, ErrInvalidRules) {
+		t.Errorf("validateRules() error = %v, want %v", err, ErrInvalidRules)
+	}
+
+	// rejected rules leave the rules of the program unchanged
+	b.Program.RulesValidator = "cidr-list"
+	b.Program.Rules = "10.0.0.1\n"
+	if err := b.applyRulesDelta(models.XDPIngressType, "10.0.0.1\nbad\n", []string{"bad"}, nil); !errors.Is(err, ErrInvalidRules) {
+		t.Errorf("applyRulesDelta() error = %v, want %v", err, ErrInvalidRules)
+	}
+	if b.Program.Rules != "10.0.0.1\n" {
+		t.Errorf("rules = %q, want unchanged", b.Program.Rules)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+// SandboxHelperName - name l3afd is executed with to set up the sandbox of a user program and execute it,
+// see RunSandboxHelper
+const SandboxHelperName = "l3afd-sandbox"
+
+// sandboxSelf - executable of l3afd running the sandbox helper
+var sandboxSelf = "/proc/self/exe"
+
+// sandboxDevices - devices exposed to the sandboxed programs
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+// nfSandboxConfig - paths exposed to all the sandboxed programs, nil when the sandbox is disabled
+type nfSandboxConfig struct {
+	landlock  bool
+	root      string
+	readOnly  []string
+	readWrite []string
+}
+
+var nfSandbox *nfSandboxConfig
+
+// sandboxSpec - sandbox of a user program, passed to the sandbox helper
+type sandboxSpec struct {
+	Root      string   `json:"root"`       // empty mount point of the root of the sandbox
+	ReadOnly  []string `json:"read_only"`  // paths exposed read only
+	ReadWrite []string `json:"read_write"` // paths exposed read write
+	Dir       string   `json:"dir"`        // working dir of the program
+	Landlock  bool     `json:"landlock"`   // Landlock rules of the exposed paths are applied when supported
+}
+
+// setNFSandbox - configures the sandbox of the user programs from l3afd.cfg, the paths must be absolute
+func setNFSandbox(conf *config.Config) error {
+	nfSandbox = nil
+	if conf == nil || !conf.NFSandboxEnabled {
+		return nil
+	}
+	for _, p := range append(append([]string{conf.NFSandboxRoot}, conf.NFSandboxReadOnlyPaths...), conf.NFSandboxReadWritePaths...) {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("sandbox path %q is not absolute", p)
+		}
+	}
+	if err := os.MkdirAll(conf.NFSandboxRoot, 0700); err != nil {
+		return fmt.Errorf("failed to create sandbox root %s: %w", conf.NFSandboxRoot, err)
+	}
+	nfSandbox = &nfSandboxConfig{
+		landlock:  conf.NFSandboxLandlock,
+		root:      filepath.Clean(conf.NFSandboxRoot),
+		readOnly:  conf.NFSandboxReadOnlyPaths,
+		readWrite: conf.NFSandboxReadWritePaths,
+	}
+	return nil
+}
+
+// sandboxSpec - sandbox of the program exposing its artifact dir, rules file and BTF read only, and its log
+// dir, control socket and the dirs of its pins read write. Paths exposed read write are not exposed read only.
+func (b *BPF) sandboxSpec(cmdDir string, implicit models.L3afDNFImplicitArgs) sandboxSpec {
+	rw := make(map[string]bool)
+	for _, p := range nfSandbox.readWrite {
+		rw[filepath.Clean(p)] = true
+	}
+	for _, p := range sandboxDevices {
+		rw[p] = true
+	}
+	for _, p := range []string{implicit.LogDir, implicit.ControlSocket} {
+		if filepath.IsAbs(p) {
+			rw[filepath.Clean(p)] = true
+		}
+	}
+	// pins created by the program are exposed by their dirs
+	for _, p := range append(programPinPaths(&b.Program), implicit.MapName) {
+		if filepath.IsAbs(p) {
+			rw[filepath.Dir(filepath.Clean(p))] = true
+		}
+	}
+	if len(b.Program.ConsumedMaps) > 0 {
+		if dir := consumedMapDir(implicit.Iface, implicit.Direction, b.slotName()); filepath.IsAbs(dir) {
+			rw[dir] = true
+		}
+	}
+
+	ro := make(map[string]bool)
+	for _, p := range append([]string{cmdDir, implicit.RulesFile, implicit.BTFPath}, nfSandbox.readOnly...) {
+		if filepath.IsAbs(p) && !rw[filepath.Clean(p)] {
+			ro[filepath.Clean(p)] = true
+		}
+	}
+	return sandboxSpec{
+		Root:      nfSandbox.root,
+		ReadOnly:  sortedPaths(ro),
+		ReadWrite: sortedPaths(rw),
+		Dir:       cmdDir,
+		Landlock:  nfSandbox.landlock,
+	}
+}
+
+func sortedPaths(paths map[string]bool) []string {
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// sandboxCommand - runs the command by the sandbox helper in a new mount namespace, the helper executes the
+// command in place so the pid, the process group and the inherited fds of the command are kept
+func sandboxCommand(cmd *exec.Cmd, spec sandboxSpec) error {
+	doc, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the sandbox spec: %w", err)
+	}
+	cmd.Args = append([]string{SandboxHelperName, string(doc), cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = sandboxSelf
+	setMountNamespace(cmd)
+	return nil
+}
+
+// sandbox - runs the user program in its sandbox when the sandbox is enabled
+func (b *BPF) sandbox(cmd *exec.Cmd, implicit models.L3afDNFImplicitArgs) error {
+	if nfSandbox == nil {
+		return nil
+	}
+	return sandboxCommand(cmd, b.sandboxSpec(cmd.Dir, implicit))
+}
+
+// RunSandboxHelper - sets up the sandbox of the spec and executes the command, the args are the spec, the
+// command and its args. Returns the exit code when the sandbox or the execution failed.
+func RunSandboxHelper(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "%s: usage %s <spec> <command> [args...]\n", SandboxHelperName, SandboxHelperName)
+		return 2
+	}
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(args[0]), &spec); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid spec: %v\n", SandboxHelperName, err)
+		return 2
+	}
+	if err := enterSandbox(spec, args[1], args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", SandboxHelperName, err)
+		return 1
+	}
+	return 0
+}
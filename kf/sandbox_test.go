@@ -0,0 +1,92 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// TestMain - test binary executed as the sandbox helper runs the helper
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == SandboxHelperName {
+		os.Exit(RunSandboxHelper(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+func TestBPF_sandboxSpec(t *testing.T) {
+	defer func() { nfSandbox = nil }()
+	nfSandbox = &nfSandboxConfig{root: "/var/run/l3afd/sandbox", landlock: true, readOnly: []string{"/usr", "/var/log/l3afd/ratelimiting"}, readWrite: []string{"/srv/shared/"}}
+
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", MapName: "/sys/fs/bpf/ratelimiting/xdp_rl_ingress_next_prog",
+		ConsumedMaps: []models.L3afDNFConsumedMap{{PinPath: "/sys/fs/bpf/shared/counters"}}}}
+	implicit := models.L3afDNFImplicitArgs{LogDir: "/var/log/l3afd/ratelimiting", RulesFile: "/var/l3afd/nf/rules.txt",
+		MapName: "/sys/fs/bpf/root/xdp_root_array", ControlSocket: "/var/run/l3afd/nf/eth0-xdpingress-ratelimiting.sock"}
+	spec := b.sandboxSpec("/var/l3afd/ratelimiting/1.0/l3af_ratelimiting", implicit)
+
+	wantRO := []string{"/usr", "/var/l3afd/nf/rules.txt", "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting"}
+	if !reflect.DeepEqual(spec.ReadOnly, wantRO) {
+		t.Errorf("sandboxSpec() read only = %v, want %v", spec.ReadOnly, wantRO)
+	}
+	wantRW := append(append([]string{}, sandboxDevices...), "/srv/shared", "/sys/fs/bpf/ratelimiting", "/sys/fs/bpf/root", "/sys/fs/bpf/shared",
+		"/var/log/l3afd/ratelimiting", "/var/run/l3afd/nf/eth0-xdpingress-ratelimiting.sock")
+	if !reflect.DeepEqual(spec.ReadWrite, sortedPaths(toSet(wantRW))) {
+		t.Errorf("sandboxSpec() read write = %v, want %v", spec.ReadWrite, wantRW)
+	}
+	if spec.Root != nfSandbox.root || spec.Dir != "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting" || !spec.Landlock {
+		t.Errorf("sandboxSpec() = %+v", spec)
+	}
+}
+
+func toSet(paths []string) map[string]bool {
+	set := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		set[p] = true
+	}
+	return set
+}
+
+func Test_sandboxCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	exposed := filepath.Join(dir, "exposed")
+	hidden := filepath.Join(dir, "hidden")
+	for _, d := range []string{exposed, hidden, filepath.Join(dir, "root")} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(exposed, "config"), []byte("exposed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hidden, "secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := sandboxSpec{Root: filepath.Join(dir, "root"), ReadOnly: []string{"/bin", "/lib", "/lib64", "/usr", exposed},
+		ReadWrite: sandboxDevices, Dir: exposed, Landlock: true}
+	cmd := exec.Command("/bin/sh", "-c", "cat config; cat "+filepath.Join(hidden, "secret")+" 2>/dev/null; touch config 2>/dev/null || echo ' read only'")
+	if err := sandboxCommand(cmd, spec); err != nil {
+		t.Fatalf("sandboxCommand() error = %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), SandboxHelperName) {
+			t.Skipf("sandbox is not permitted: %s", out)
+		}
+		t.Fatalf("sandboxed command failed: %v %s", err, out)
+	}
+	if string(out) != "exposed read only\n" {
+		t.Errorf("sandboxed command output %q, want the exposed config only and read only", out)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// maxCPUs - CPUs of an affinity mask, CPU_SETSIZE of the kernel
+const maxCPUs = 1024
+
+// ionice classes of the I/O scheduler
+const (
+	ioniceRealtime   = "realtime"
+	ioniceBestEffort = "best-effort"
+	ioniceIdle       = "idle"
+)
+
+// ioniceClasses - IOPRIO_CLASS of the ionice class names
+var ioniceClasses = map[string]int{ioniceRealtime: 1, ioniceBestEffort: 2, ioniceIdle: 3}
+
+// parseCPUList - parses comma separated CPUs and CPU ranges e.g. 2-5,8
+func parseCPUList(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	var cpus []int
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		from, to := item, item
+		if i := strings.Index(item, "-"); i > 0 {
+			from, to = item[:i], item[i+1:]
+		}
+		first, err := strconv.Atoi(from)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid cpu %q", item)
+		}
+		last, err := strconv.Atoi(to)
+		if err != nil || last < first {
+			return nil, fmt.Errorf("invalid cpu range %q", item)
+		}
+		if last >= maxCPUs {
+			return nil, fmt.Errorf("cpu %d exceeds %d cpus", last, maxCPUs)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// validateScheduling - nice is -20 to 19, ionice level is 0 to 7 of the realtime and best-effort classes
+func validateScheduling(prog *models.BPFProgram) error {
+	if prog.Nice < -20 || prog.Nice > 19 {
+		return fmt.Errorf("nice %d is not between -20 and 19", prog.Nice)
+	}
+	if len(prog.CPUAffinity) > 0 && prog.CPUAffinity != cpuAffinitySameNode {
+		if _, err := parseCPUList(prog.CPUAffinity); err != nil {
+			return fmt.Errorf("cpu affinity %q: %w", prog.CPUAffinity, err)
+		}
+	}
+	if len(prog.IONiceClass) == 0 {
+		if prog.IONiceLevel != 0 {
+			return fmt.Errorf("ionice level %d requires an ionice class", prog.IONiceLevel)
+		}
+		return nil
+	}
+	if _, ok := ioniceClasses[prog.IONiceClass]; !ok {
+		return fmt.Errorf("unknown ionice class %q, expected %s, %s or %s", prog.IONiceClass, ioniceRealtime, ioniceBestEffort, ioniceIdle)
+	}
+	if prog.IONiceLevel < 0 || prog.IONiceLevel > 7 {
+		return fmt.Errorf("ionice level %d is not between 0 and 7", prog.IONiceLevel)
+	}
+	return nil
+}
+
+// ValidateScheduling - Verifies the nice, cpu affinity and ionice settings of the programs
+func ValidateScheduling(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateScheduling(ref.prog); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// schedulingReset - update resets a scheduling setting of the running program to the default, which is applied by
+// the start of the program only
+func schedulingReset(running, prog *models.BPFProgram) bool {
+	return (running.Nice != 0 && prog.Nice == 0) || (len(running.CPUAffinity) > 0 && len(prog.CPUAffinity) == 0) ||
+		(len(running.IONiceClass) > 0 && len(prog.IONiceClass) == 0)
+}
+
+// processThreads - thread ids of the process, nice, affinity and ionice are per thread on linux
+func processThreads(pid int) ([]int, error) {
+	entries, err := appFS.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads of process %d: %w", pid, err)
+	}
+	tids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
@@ -0,0 +1,80 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{spec: "3", want: []int{3}},
+		{spec: "2-5,8", want: []int{2, 3, 4, 5, 8}},
+		{spec: "8, 0-1, 1", want: []int{0, 1, 8}},
+		{spec: "5-2", wantErr: true},
+		{spec: "1024", wantErr: true},
+		{spec: "a", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCPUList(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCPUList(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCPUList(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestValidateScheduling(t *testing.T) {
+	tests := []struct {
+		name    string
+		prog    models.BPFProgram
+		wantErr bool
+	}{
+		{name: "defaults", prog: models.BPFProgram{Name: "ratelimiting"}},
+		{name: "pinned", prog: models.BPFProgram{Name: "ratelimiting", Nice: -5, CPUAffinity: "4-15", IONiceClass: "best-effort", IONiceLevel: 2}},
+		{name: "same node", prog: models.BPFProgram{Name: "xsk-forwarder", CPUAffinity: "same-node"}},
+		{name: "deprioritized", prog: models.BPFProgram{Name: "log-shipper", Nice: 10, IONiceClass: "idle"}},
+		{name: "nice out of range", prog: models.BPFProgram{Name: "ratelimiting", Nice: 20}, wantErr: true},
+		{name: "invalid cpus", prog: models.BPFProgram{Name: "ratelimiting", CPUAffinity: "4-"}, wantErr: true},
+		{name: "unknown class", prog: models.BPFProgram{Name: "ratelimiting", IONiceClass: "low"}, wantErr: true},
+		{name: "level without class", prog: models.BPFProgram{Name: "ratelimiting", IONiceLevel: 3}, wantErr: true},
+		{name: "level out of range", prog: models.BPFProgram{Name: "ratelimiting", IONiceClass: "realtime", IONiceLevel: 8}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := tt.prog
+			cfg := []models.L3afBPFPrograms{{
+				Iface:       "eth0",
+				BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{&prog}},
+			}}
+			if err := ValidateScheduling(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateScheduling() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessThreads(t *testing.T) {
+	useMemFS(t, map[string]string{
+		"/proc/4242/task/4242/stat": "",
+		"/proc/4242/task/4250/stat": "",
+	})
+	got, err := processThreads(4242)
+	if err != nil {
+		t.Fatalf("processThreads() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, []int{4242, 4250}) {
+		t.Errorf("processThreads() = %v, want [4242 4250]", got)
+	}
+}
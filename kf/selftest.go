@@ -0,0 +1,232 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// tcmsg and TCA attributes of the tc filters, see linux/rtnetlink.h and linux/pkt_cls.h, and the nested flag of
+// the netlink attributes
+const (
+	sizeofTcMsg      = 20
+	tcaKind          = 1
+	tcaOptions       = 2
+	tcaBPFID         = 11
+	tcHClsactIngress = 0xFFFFFFF2
+	tcHClsactEgress  = 0xFFFFFFF3
+	nlaFNested       = 0x8000
+)
+
+// ChainSelfTest - Injects a test packet into the chain entry program of the interface using BPF_PROG_TEST_RUN
+// and verifies the average chain verdict latency is within the configured threshold. The entry program is the
+// program attached to the hook, the root program tail calling the chain when chaining is enabled. The test runs
+// update the maps of the programs of the chain as the packets would.
+func (c *NFConfigs) ChainSelfTest(ifaceName string) error {
+	chains := map[string]*list.List{
+		models.XDPIngressType: c.IngressXDPBpfs[ifaceName],
+		models.IngressType:    c.IngressTCBpfs[ifaceName],
+		models.EgressType:     c.EgressTCBpfs[ifaceName],
+	}
+
+	for direction, bpfList := range chains {
+		if bpfList == nil || bpfList.Len() == 0 {
+			continue
+		}
+		progID, err := chainEntryProgID(ifaceName, direction)
+		if err != nil {
+			return fmt.Errorf("chain self test on iface %s direction %s failed: %w", ifaceName, direction, err)
+		}
+		if progID == 0 {
+			log.Debug().Msgf("chain self test - no chain entry program on iface %s direction %s", ifaceName, direction)
+			continue
+		}
+
+		latency, err := measureChainLatency(progID, c.hostConfig.ChainSelfTestRepeat)
+		if err != nil {
+			return fmt.Errorf("chain self test on iface %s direction %s failed: %w", ifaceName, direction, err)
+		}
+		log.Info().Msgf("chain self test - iface %s direction %s latency %s", ifaceName, direction, latency)
+		stats.Set(latency.Seconds(), stats.NFChainLatency, ifaceName, direction)
+
+		if c.hostConfig.ChainSelfTestMaxLatency > 0 && latency > c.hostConfig.ChainSelfTestMaxLatency {
+			return fmt.Errorf("chain latency %s on iface %s direction %s exceeds threshold %s",
+				latency, ifaceName, direction, c.hostConfig.ChainSelfTestMaxLatency)
+		}
+	}
+	return nil
+}
+
+// snapshotIfacePrograms - copies of the programs of the interface, the chain the failed self test rolls back to
+func (c *NFConfigs) snapshotIfacePrograms(ifaceName string) models.L3afBPFPrograms {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := c.EBPFPrograms(ifaceName)
+	for _, progs := range []*[]*models.BPFProgram{&snapshot.BpfPrograms.XDPIngress, &snapshot.BpfPrograms.TCIngress, &snapshot.BpfPrograms.TCEgress} {
+		for i, prog := range *progs {
+			p := *prog
+			(*progs)[i] = &p
+		}
+	}
+	return snapshot
+}
+
+// rollbackIfacePrograms - deploys the programs of the interface of the snapshot again, the programs new to the
+// interface are stopped and removed from the chain
+func (c *NFConfigs) rollbackIfacePrograms(snapshot models.L3afBPFPrograms) error {
+	if err := c.Deploy(snapshot.Iface, snapshot.HostName, snapshot.BpfPrograms); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.RemoveMissingBPFProgramsInConfig(snapshot, snapshot.Iface); err != nil {
+		return err
+	}
+	log.Info().Msgf("programs of iface %s are rolled back", snapshot.Iface)
+	return nil
+}
+
+// chainEntryProgID - returns ID of the program attached to the hook of the direction of the interface, programs
+// offloaded to the NIC can't be test run
+func chainEntryProgID(ifaceName, direction string) (int, error) {
+	if direction == models.XDPIngressType {
+		attached, err := xdpAttachment(ifaceName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get xdp attachment: %w", err)
+		}
+		return xdpEntryProgID(attached), nil
+	}
+	ids, err := tcAttachment(ifaceName, direction)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tc attachment: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+// xdpEntryProgID - ID of the program attached in native or generic XDP mode
+func xdpEntryProgID(attached map[string]int) int {
+	for _, mode := range []string{XDPModeNative, XDPModeGeneric} {
+		if id := attached[mode]; id > 0 {
+			return id
+		}
+	}
+	return 0
+}
+
+// tcParent - parent of the clsact hook of the direction
+func tcParent(direction string) uint32 {
+	if direction == models.EgressType {
+		return tcHClsactEgress
+	}
+	return tcHClsactIngress
+}
+
+// netlinkAttrs - netlink attributes by type, nested flags are cleared
+func netlinkAttrs(b []byte) map[uint16][]byte {
+	attrs := make(map[uint16][]byte)
+	for len(b) >= 4 {
+		l := int(nativeEndian.Uint16(b[0:2]))
+		if l < 4 || l > len(b) {
+			break
+		}
+		attrs[nativeEndian.Uint16(b[2:4])&^nlaFNested] = b[4:l]
+		// attributes are aligned to 4 bytes
+		l = (l + 3) &^ 3
+		if l > len(b) {
+			break
+		}
+		b = b[l:]
+	}
+	return attrs
+}
+
+// parseTCFilterProgID - ID of the bpf program of the tc filter from its attributes, 0 for the other filter kinds
+func parseTCFilterProgID(b []byte) int {
+	attrs := netlinkAttrs(b)
+	if kind := attrs[tcaKind]; string(bytes.TrimRight(kind, "\x00")) != "bpf" {
+		return 0
+	}
+	if id := netlinkAttrs(attrs[tcaOptions])[tcaBPFID]; len(id) >= 4 {
+		return int(nativeEndian.Uint32(id[0:4]))
+	}
+	return 0
+}
+
+// measureChainLatency - returns the kernel measured average run time of the program and the tail called programs
+func measureChainLatency(progID, repeat int) (time.Duration, error) {
+	prog, err := ebpf.NewProgramFromID(ebpf.ProgramID(progID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get program from ID %d: %w", progID, err)
+	}
+	defer prog.Close()
+
+	if repeat < 1 {
+		repeat = 1
+	}
+	_, latency, err := prog.Benchmark(selfTestPacket(), repeat, nil)
+	if err != nil {
+		return 0, fmt.Errorf("test run of program ID %d failed: %w", progID, err)
+	}
+	return latency, nil
+}
+
+// selfTestPacket - returns ethernet frame of IPv4 UDP packet from 192.0.2.1:40000 to 192.0.2.2:53 (RFC 5737 test addresses)
+func selfTestPacket() []byte {
+	const (
+		ethHdrLen = 14
+		ipHdrLen  = 20
+		udpHdrLen = 8
+		payload   = 22
+	)
+	pkt := make([]byte, ethHdrLen+ipHdrLen+udpHdrLen+payload)
+
+	// ethernet header, locally administered mac addresses
+	copy(pkt[0:6], []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x02})
+	copy(pkt[6:12], []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01})
+	binary.BigEndian.PutUint16(pkt[12:14], 0x0800)
+
+	// ipv4 header
+	ip := pkt[ethHdrLen:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], ipHdrLen+udpHdrLen+payload)
+	ip[8] = 64 // ttl
+	ip[9] = 17 // udp
+	copy(ip[12:16], []byte{192, 0, 2, 1})
+	copy(ip[16:20], []byte{192, 0, 2, 2})
+	binary.BigEndian.PutUint16(ip[10:12], ipv4Checksum(ip[:ipHdrLen]))
+
+	// udp header, checksum is optional for ipv4
+	udp := ip[ipHdrLen:]
+	binary.BigEndian.PutUint16(udp[0:2], 40000)
+	binary.BigEndian.PutUint16(udp[2:4], 53)
+	binary.BigEndian.PutUint16(udp[4:6], udpHdrLen+payload)
+	return pkt
+}
+
+// ipv4Checksum - internet checksum of the ipv4 header
+func ipv4Checksum(hdr []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(hdr); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(hdr[i : i+2]))
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,129 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_xdpEntryProgID(t *testing.T) {
+	tests := []struct {
+		name     string
+		attached map[string]int
+		want     int
+	}{
+		{name: "NotAttached", attached: map[string]int{}, want: 0},
+		{name: "Native", attached: map[string]int{XDPModeNative: 12}, want: 12},
+		{name: "Generic", attached: map[string]int{XDPModeGeneric: 15}, want: 15},
+		{name: "Offloaded", attached: map[string]int{XDPModeOffload: 18}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := xdpEntryProgID(tt.attached); got != tt.want {
+				t.Errorf("xdpEntryProgID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// netlinkAttr - netlink attribute of the type, padded to 4 bytes
+func netlinkAttr(typ uint16, value []byte) []byte {
+	attr := make([]byte, 4+len(value))
+	nativeEndian.PutUint16(attr[0:2], uint16(len(attr)))
+	nativeEndian.PutUint16(attr[2:4], typ)
+	copy(attr[4:], value)
+	for len(attr)%4 != 0 {
+		attr = append(attr, 0)
+	}
+	return attr
+}
+
+func Test_parseTCFilterProgID(t *testing.T) {
+	id := make([]byte, 4)
+	nativeEndian.PutUint32(id, 42)
+	options := netlinkAttr(tcaOptions|nlaFNested, netlinkAttr(tcaBPFID, id))
+
+	tests := []struct {
+		name  string
+		attrs []byte
+		want  int
+	}{
+		{name: "Empty", attrs: nil, want: 0},
+		{name: "BPF", attrs: append(netlinkAttr(tcaKind, []byte("bpf\x00")), options...), want: 42},
+		{name: "OtherKind", attrs: append(netlinkAttr(tcaKind, []byte("u32\x00")), options...), want: 0},
+		{name: "NoOptions", attrs: netlinkAttr(tcaKind, []byte("bpf\x00")), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTCFilterProgID(tt.attrs); got != tt.want {
+				t.Errorf("parseTCFilterProgID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selfTestPacket(t *testing.T) {
+	pkt := selfTestPacket()
+	if len(pkt) != 64 {
+		t.Errorf("selfTestPacket() length = %d, want 64", len(pkt))
+	}
+	// checksum of the header including the checksum field is zero
+	if sum := ipv4Checksum(pkt[14:34]); sum != 0 {
+		t.Errorf("selfTestPacket() invalid ipv4 header checksum %#x", sum)
+	}
+}
+
+func TestNFConfigs_rollbackIfacePrograms(t *testing.T) {
+	var stopped []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--name=") {
+				stopped = append(stopped, strings.TrimPrefix(arg, "--name="))
+			}
+		}
+		return exec.Command(GetTestExecutablePathName())
+	}
+	defer func() { execCommand = exec.Command }()
+
+	program := func(name string) *BPF {
+		return &BPF{Program: models.BPFProgram{Name: name, Version: "1.0", CmdStop: GetTestExecutableName(), AdminStatus: models.Enabled,
+			StopArgs: map[string]interface{}{"name": name}}, FilePath: GetTestExecutablePath()}
+	}
+	running := program("ratelimiting")
+	xdp := list.New()
+	xdp.PushBack(running)
+	c := &NFConfigs{
+		hostName:       "l3af-local-test",
+		hostInterfaces: map[string]bool{"dummy": true},
+		hostConfig:     &config.Config{},
+		IngressXDPBpfs: map[string]*list.List{"dummy": xdp},
+		IngressTCBpfs:  map[string]*list.List{},
+		EgressTCBpfs:   map[string]*list.List{},
+		mu:             new(sync.Mutex),
+	}
+
+	snapshot := c.snapshotIfacePrograms("dummy")
+	running.Program.Version = "2.0"
+	if got := snapshot.BpfPrograms.XDPIngress[0].Version; got != "1.0" {
+		t.Fatalf("snapshotIfacePrograms() version = %s, want the copy of the running program", got)
+	}
+	running.Program.Version = "1.0"
+
+	// program added by the apply failing the self test is removed
+	xdp.PushBack(program("connection-limit"))
+	if err := c.rollbackIfacePrograms(snapshot); err != nil {
+		t.Fatalf("rollbackIfacePrograms() error = %v", err)
+	}
+	if xdp.Len() != 1 || xdp.Front().Value.(*BPF) != running || !reflect.DeepEqual(stopped, []string{"connection-limit"}) {
+		t.Errorf("rollbackIfacePrograms() left %d programs and stopped %v, want connection-limit removed", xdp.Len(), stopped)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+// priorityRanks - chain order of the priority classes, programs without class are normal
+var priorityRanks = map[string]int{
+	models.PriorityFirst:  0,
+	models.PriorityEarly:  1,
+	"":                    2,
+	models.PriorityNormal: 2,
+	models.PriorityLate:   3,
+	models.PriorityLast:   4,
+}
+
+// AssignSeqIDs - Computes the sequence ids of the enabled programs of every interface and direction from
+// the priority classes, the programs to be chained after and the configured sequence ids, and numbers them
+// without gaps starting from 1. This is done before applying any change.
+func AssignSeqIDs(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		if cfg.BpfPrograms == nil {
+			continue
+		}
+		directions := map[string][]*models.BPFProgram{
+			models.XDPIngressType: cfg.BpfPrograms.XDPIngress,
+			models.IngressType:    cfg.BpfPrograms.TCIngress,
+			models.EgressType:     cfg.BpfPrograms.TCEgress,
+		}
+		for direction, progs := range directions {
+			if err := assignChainSeqIDs(progs); err != nil {
+				return fmt.Errorf("failed to assign seq ids on iface %s direction %s: %w", cfg.Iface, direction, err)
+			}
+		}
+	}
+	return nil
+}
+
+// assignChainSeqIDs - orders the enabled programs of a chain by priority class, then configured seq id with
+// unset seq ids last, then config order. Programs are moved after the programs they are configured to follow,
+// which must not be in a later priority class.
+func assignChainSeqIDs(progs []*models.BPFProgram) error {
+	var chain []*models.BPFProgram
+	disabled := make(map[string]bool)
+	for _, prog := range progs {
+		if prog == nil {
+			continue
+		}
+		if _, ok := priorityRanks[prog.Priority]; !ok {
+			return fmt.Errorf("program %s has unknown priority class %s", prog.Name, prog.Priority)
+		}
+		if !loadedStatus(prog.AdminStatus) {
+			disabled[prog.Name] = true
+			continue
+		}
+		chain = append(chain, prog)
+	}
+
+	sort.SliceStable(chain, func(i, j int) bool {
+		ri, rj := priorityRanks[chain[i].Priority], priorityRanks[chain[j].Priority]
+		if ri != rj {
+			return ri < rj
+		}
+		si, sj := chain[i].SeqID, chain[j].SeqID
+		if si == 0 || sj == 0 {
+			return si != 0 && sj == 0
+		}
+		return si < sj
+	})
+
+	inChain := make(map[string]bool, len(chain))
+	for _, prog := range chain {
+		inChain[prog.Name] = true
+	}
+	for _, prog := range chain {
+		for _, name := range prog.After {
+			if !inChain[name] && !disabled[name] {
+				return fmt.Errorf("program %s is configured after %s which is not in the chain", prog.Name, name)
+			}
+		}
+	}
+
+	// stable topological order, the first program in the sorted order with all predecessors placed is next
+	ordered := make([]*models.BPFProgram, 0, len(chain))
+	placed := make(map[string]bool, len(chain))
+	for len(ordered) < len(chain) {
+		next := -1
+		for i, prog := range chain {
+			if placed[prog.Name] {
+				continue
+			}
+			ready := true
+			for _, name := range prog.After {
+				if inChain[name] && !placed[name] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return fmt.Errorf("cyclic after order between the programs")
+		}
+		prog := chain[next]
+		if n := len(ordered); n > 0 && priorityRanks[ordered[n-1].Priority] > priorityRanks[prog.Priority] {
+			return fmt.Errorf("program %s of priority class %s can't be chained after program %s of priority class %s",
+				prog.Name, priorityClass(prog), ordered[n-1].Name, priorityClass(ordered[n-1]))
+		}
+		placed[prog.Name] = true
+		ordered = append(ordered, prog)
+	}
+
+	for i, prog := range ordered {
+		prog.SeqID = i + 1
+	}
+	return nil
+}
+
+func priorityClass(prog *models.BPFProgram) string {
+	if len(prog.Priority) == 0 {
+		return models.PriorityNormal
+	}
+	return prog.Priority
+}
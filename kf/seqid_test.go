@@ -0,0 +1,87 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_assignChainSeqIDs(t *testing.T) {
+	prog := func(name string, seqID int, priority string, after ...string) *models.BPFProgram {
+		return &models.BPFProgram{Name: name, SeqID: seqID, Priority: priority, After: after, AdminStatus: models.Enabled}
+	}
+	tests := []struct {
+		name    string
+		progs   []*models.BPFProgram
+		want    map[string]int
+		wantErr bool
+	}{
+		{
+			name:  "NormalizeGaps",
+			progs: []*models.BPFProgram{prog("c", 10, ""), prog("a", 2, ""), prog("b", 5, "")},
+			want:  map[string]int{"a": 1, "b": 2, "c": 3},
+		},
+		{
+			name:  "PriorityClass",
+			progs: []*models.BPFProgram{prog("log", 0, models.PriorityLast), prog("fw", 0, models.PriorityFirst), prog("rl", 1, "")},
+			want:  map[string]int{"fw": 1, "rl": 2, "log": 3},
+		},
+		{
+			name:  "UnsetSeqIDLast",
+			progs: []*models.BPFProgram{prog("b", 0, ""), prog("a", 3, "")},
+			want:  map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name:  "After",
+			progs: []*models.BPFProgram{prog("b", 0, "", "a"), prog("a", 0, "")},
+			want:  map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name:  "AfterDisabled",
+			progs: []*models.BPFProgram{prog("b", 0, "", "a"), {Name: "a", AdminStatus: models.Disabled}},
+			want:  map[string]int{"a": 0, "b": 1},
+		},
+		{
+			name:    "AfterMissing",
+			progs:   []*models.BPFProgram{prog("b", 0, "", "a")},
+			wantErr: true,
+		},
+		{
+			name:    "AfterLaterClass",
+			progs:   []*models.BPFProgram{prog("a", 0, models.PriorityLate), prog("b", 0, models.PriorityEarly, "a")},
+			wantErr: true,
+		},
+		{
+			name:    "Cycle",
+			progs:   []*models.BPFProgram{prog("a", 0, "", "b"), prog("b", 0, "", "a")},
+			wantErr: true,
+		},
+		{
+			name:    "UnknownPriority",
+			progs:   []*models.BPFProgram{prog("a", 0, "urgent")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := assignChainSeqIDs(tt.progs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("assignChainSeqIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := make(map[string]int)
+			for _, p := range tt.progs {
+				got[p.Name] = p.SeqID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("assignChainSeqIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// slotShadow - slot of the candidate version evaluated in shadow mode
+	slotShadow = "shadow"
+	// defaultShadowInterval - interval of the evaluations when the shadow config does not set one
+	defaultShadowInterval = 10 * time.Second
+	// defaultShadowSamples - packets run through both versions at each evaluation when the shadow config does
+	// not set them
+	defaultShadowSamples = 16
+	// shadowTick - period the evaluations due are run at
+	shadowTick = time.Second
+)
+
+// validateShadow - candidate version is set, its chaining map is pinned apart from the maps of the program and
+// the samples and the interval are valid
+func validateShadow(prog *models.BPFProgram) error {
+	sh := prog.Shadow
+	if sh == nil {
+		return nil
+	}
+	if len(sh.Version) == 0 {
+		return fmt.Errorf("shadow version is not set")
+	}
+	if len(sh.MapName) == 0 {
+		return fmt.Errorf("shadow map name is not set")
+	}
+	if sh.MapName == prog.MapName || (prog.BlueGreen != nil && sh.MapName == prog.BlueGreen.MapName) {
+		return fmt.Errorf("shadow map name %s is the map name of the program", sh.MapName)
+	}
+	if filepath.Base(sh.MapName) != filepath.Base(prog.MapName) {
+		return fmt.Errorf("shadow map name %s is not pinned as map %s of the eBPF objects", sh.MapName, filepath.Base(prog.MapName))
+	}
+	if sh.Samples < 0 {
+		return fmt.Errorf("invalid shadow samples %d", sh.Samples)
+	}
+	if len(sh.Interval) > 0 {
+		if d, err := time.ParseDuration(sh.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("shadow interval %q is not a positive duration", sh.Interval)
+		}
+	}
+	for _, cm := range prog.ConsumedMaps {
+		if len(cm.PinPath) > 0 {
+			return fmt.Errorf("consumed map %s is re-pinned at %s, which is not supported in shadow mode", cm.Name, cm.PinPath)
+		}
+	}
+	return nil
+}
+
+// ValidateShadows - Verifies the shadow configs of the programs, the candidate versions are run in a staging
+// slot so bpf chaining is required
+func (c *NFConfigs) ValidateShadows(bpfProgs []models.L3afBPFPrograms) error {
+	chain := c.hostConfig != nil && c.hostConfig.BpfChainingEnabled
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil || ref.prog.Shadow == nil {
+				continue
+			}
+			if !chain {
+				return fmt.Errorf("program %s on iface %s has a shadow version, which requires bpf chaining", ref.prog.Name, cfg.Iface)
+			}
+			if err := validateShadow(ref.prog); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// shadowInterval - interval of the evaluations of the candidate version
+func shadowInterval(sh *models.L3afDNFShadow) time.Duration {
+	if d, err := time.ParseDuration(sh.Interval); err == nil && d > 0 {
+		return d
+	}
+	return defaultShadowInterval
+}
+
+// shadowSamples - packets run through both versions at each evaluation
+func shadowSamples(sh *models.L3afDNFShadow) int {
+	if sh.Samples > 0 {
+		return sh.Samples
+	}
+	return defaultShadowSamples
+}
+
+// shadowProgram - config of the candidate version of the program, the maps shared with the other programs and
+// the heartbeat are left to the program
+func shadowProgram(prog *models.BPFProgram) models.BPFProgram {
+	candidate := *prog
+	candidate.Version = prog.Shadow.Version
+	if len(prog.Shadow.Artifact) > 0 {
+		candidate.Artifact = prog.Shadow.Artifact
+	}
+	candidate.MapName = prog.Shadow.MapName
+	candidate.AdminStatus = models.Enabled
+	candidate.Shadow = nil
+	candidate.BlueGreen = nil
+	candidate.SharedMaps = nil
+	candidate.Heartbeat = nil
+	return candidate
+}
+
+// startShadow - starts the candidate version of the program with its program inserted into a staging prog map,
+// so it receives no packets of the chain
+func (c *NFConfigs) startShadow(b *BPF, ifaceName, direction string) error {
+	candidate := NewBpfProgram(c.ctx, shadowProgram(b.configProgram()), c.hostConfig.BPFLogDir, c.hostConfig.DataCenter)
+	candidate.BTFPath = c.btfPath
+	candidate.slot = slotShadow
+
+	staging := standbyMapPath(ifaceName, direction, candidate.slotName())
+	if err := createStandbyMap(staging); err != nil {
+		return fmt.Errorf("failed to create the staging map of the shadow version: %w", err)
+	}
+	candidate.PrevMapName = staging
+	candidate.standbyMap = staging
+
+	if candidate.beginStart(nfCmdConfig.startDeadline) {
+		defer candidate.endStart()
+	}
+	if err := c.downloadAndStart(candidate, ifaceName, direction); err != nil {
+		if candidate.Cmd != nil {
+			stopSlot(candidate, b, ifaceName, direction)
+		}
+		candidate.removeStandbyMap()
+		return fmt.Errorf("failed to start shadow version %s: %w", candidate.Program.Version, err)
+	}
+	b.shadow = candidate
+	b.shadowNext = 0
+	log.Info().Msgf("shadow version %s of program %s iface %s direction %s started", candidate.Program.Version, b.Program.Name, ifaceName, direction)
+	return nil
+}
+
+// stopShadow - stops the candidate version of the program
+func (b *BPF) stopShadow(ifaceName, direction string) {
+	if b.shadow == nil {
+		return
+	}
+	log.Info().Msgf("stopping shadow version %s of program %s", b.shadow.Program.Version, b.Program.Name)
+	stopSlot(b.shadow, b, ifaceName, direction)
+	b.shadow = nil
+	b.shadowNext = 0
+}
+
+// compareShadow - runs the sample packets through the program and its candidate version and counts the verdicts
+// diverging. The successor of the program is linked to the candidate as well, so the verdicts are the verdicts
+// of the chain from the position of the program. Returns the count of the samples compared and diverged.
+func (b *BPF) compareShadow(e *list.Element, ifaceName, direction string) (compared, diverged int, err error) {
+	nextID := 0
+	if _, next := chainNeighbours(e); next != nil {
+		nextID = next.Value.(*BPF).ProgID
+	}
+	if nextID != b.shadowNext {
+		if nextID > 0 {
+			err = b.shadow.PutNextProgFDFromID(nextID)
+		} else {
+			err = b.shadow.RemoveNextProgFD()
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to link the successor to the shadow version: %w", err)
+		}
+		b.shadowNext = nextID
+	}
+
+	samples, _ := samplePackets(ifaceName, shadowSamples(b.Program.Shadow))
+	for i, sample := range samples {
+		verdict, err := testRunProgram(b.ProgID, sample)
+		if err != nil {
+			return compared, diverged, fmt.Errorf("test run of sample %d failed: %w", i, err)
+		}
+		shadowVerdict, err := testRunProgram(b.shadow.ProgID, sample)
+		if err != nil {
+			return compared, diverged, fmt.Errorf("test run of sample %d by the shadow version failed: %w", i, err)
+		}
+		compared++
+		if verdict != shadowVerdict {
+			diverged++
+			debugProgram(b.Program.Name).Msgf("shadow version %s verdict %d diverged from verdict %d on sample %d", b.shadow.Program.Version, shadowVerdict, verdict, i)
+		}
+	}
+	stats.Add(float64(compared), stats.NFShadowSamples, b.Program.Name, direction)
+	stats.Add(float64(diverged), stats.NFShadowDivergence, b.Program.Name, direction)
+	if diverged > 0 {
+		log.Info().Msgf("shadow version %s of program %s iface %s direction %s diverged on %d of %d samples",
+			b.shadow.Program.Version, b.Program.Name, ifaceName, direction, diverged, compared)
+	}
+	return compared, diverged, nil
+}
+
+// syncShadow - starts, restarts or stops the candidate version of the program to follow its config, and
+// evaluates it when its interval elapsed. A candidate failing to start is retried at the next interval.
+func (c *NFConfigs) syncShadow(e *list.Element, ifaceName, direction string, now time.Time) {
+	b := e.Value.(*BPF)
+	sh := b.Program.Shadow
+	live := sh != nil && loadedStatus(b.Program.AdminStatus) && !b.Degraded && b.ProgID > 0
+	if b.shadow != nil {
+		running, _ := b.shadow.isRunning()
+		if !live || !running || !reflect.DeepEqual(b.shadow.Program, shadowProgram(b.configProgram())) {
+			b.stopShadow(ifaceName, direction)
+		}
+	}
+	if !live || now.Sub(b.lastShadowRun) < shadowInterval(sh) {
+		return
+	}
+	b.lastShadowRun = now
+
+	if b.shadow == nil {
+		if err := c.startShadow(b, ifaceName, direction); err != nil {
+			log.Warn().Err(err).Msgf("shadow version %s of program %s iface %s direction %s is not evaluated", sh.Version, b.Program.Name, ifaceName, direction)
+		}
+		return
+	}
+	if _, _, err := b.compareShadow(e, ifaceName, direction); err != nil {
+		log.Warn().Err(err).Msgf("evaluation of shadow version %s of program %s iface %s direction %s failed", sh.Version, b.Program.Name, ifaceName, direction)
+	}
+}
+
+// Shadows - evaluates the candidate versions of the programs in shadow mode
+func (c *NFConfigs) Shadows(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+		bpfs, _ := c.bpfLists(direction)
+		for ifaceName, bpfList := range bpfs {
+			if bpfList == nil {
+				continue
+			}
+			for e := bpfList.Front(); e != nil; e = e.Next() {
+				c.syncShadow(e, ifaceName, direction, now)
+			}
+		}
+	}
+}
+
+// shadowLoop - periodic evaluation of the candidate versions in shadow mode
+func (c *NFConfigs) shadowLoop() {
+	ticker := time.NewTicker(shadowTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.Shadows(time.Now())
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_validateShadow(t *testing.T) {
+	mapName := "/sys/fs/bpf/xdp_rl_ingress_next_prog"
+	shadowMap := "/sys/fs/bpf/shadow/xdp_rl_ingress_next_prog"
+	tests := []struct {
+		name    string
+		prog    models.BPFProgram
+		wantErr bool
+	}{
+		{"none", models.BPFProgram{MapName: mapName}, false},
+		{"shadow", models.BPFProgram{MapName: mapName, Shadow: &models.L3afDNFShadow{Version: "2.0", MapName: shadowMap, Samples: 8, Interval: "30s"}}, false},
+		{"no version", models.BPFProgram{MapName: mapName, Shadow: &models.L3afDNFShadow{MapName: shadowMap}}, true},
+		{"no map", models.BPFProgram{MapName: mapName, Shadow: &models.L3afDNFShadow{Version: "2.0"}}, true},
+		{"map of the program", models.BPFProgram{MapName: mapName, Shadow: &models.L3afDNFShadow{Version: "2.0", MapName: mapName}}, true},
+		{"map of the green slot", models.BPFProgram{
+			MapName:   mapName,
+			BlueGreen: &models.L3afDNFBlueGreen{MapName: shadowMap},
+			Shadow:    &models.L3afDNFShadow{Version: "2.0", MapName: shadowMap},
+		}, true},
+		{"map not of the objects", models.BPFProgram{MapName: mapName, Shadow: &models.L3afDNFShadow{Version: "2.0", MapName: "/sys/fs/bpf/xdp_rl_shadow_next_prog"}}, true},
+		{"negative samples", models.BPFProgram{MapName: mapName, Shadow: &models.L3afDNFShadow{Version: "2.0", MapName: shadowMap, Samples: -1}}, true},
+		{"invalid interval", models.BPFProgram{MapName: mapName, Shadow: &models.L3afDNFShadow{Version: "2.0", MapName: shadowMap, Interval: "-5s"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateShadow(&tt.prog); (err != nil) != tt.wantErr {
+				t.Errorf("validateShadow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNFConfigs_ValidateShadows(t *testing.T) {
+	progs := []models.L3afBPFPrograms{{
+		Iface: "eth0",
+		BpfPrograms: &models.BPFPrograms{
+			XDPIngress: []*models.BPFProgram{{
+				Name:    "ratelimiting",
+				MapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+				Shadow:  &models.L3afDNFShadow{Version: "2.0", MapName: "/sys/fs/bpf/shadow/xdp_rl_ingress_next_prog"},
+			}},
+		},
+	}}
+	c := &NFConfigs{hostConfig: &config.Config{BpfChainingEnabled: true}}
+	if err := c.ValidateShadows(progs); err != nil {
+		t.Errorf("ValidateShadows() error = %v, want the shadow version chained", err)
+	}
+	c.hostConfig.BpfChainingEnabled = false
+	if err := c.ValidateShadows(progs); err == nil {
+		t.Errorf("ValidateShadows() error = nil, want the shadow version rejected without chaining")
+	}
+}
+
+func Test_shadowProgram(t *testing.T) {
+	prog := models.BPFProgram{
+		Name:        "ratelimiting",
+		Version:     "1.0",
+		Artifact:    "l3af_ratelimiting.tar.gz",
+		MapName:     "/sys/fs/bpf/xdp_rl_ingress_next_prog",
+		AdminStatus: models.Standby,
+		SharedMaps:  map[string]string{"rl_config": "/sys/fs/bpf/rl_config_map"},
+		Shadow:      &models.L3afDNFShadow{Version: "2.0", MapName: "/sys/fs/bpf/shadow/xdp_rl_ingress_next_prog"},
+	}
+	got := shadowProgram(&prog)
+	if got.Version != "2.0" || got.Artifact != prog.Artifact || got.MapName != prog.Shadow.MapName {
+		t.Errorf("shadowProgram() version %s artifact %s map %s, want the candidate version", got.Version, got.Artifact, got.MapName)
+	}
+	if got.AdminStatus != models.Enabled || got.Shadow != nil || got.SharedMaps != nil {
+		t.Errorf("shadowProgram() = %+v, want the enabled candidate without the shared maps", got)
+	}
+
+	prog.Shadow.Artifact = "l3af_ratelimiting_next.tar.gz"
+	if got := shadowProgram(&prog); got.Artifact != prog.Shadow.Artifact {
+		t.Errorf("shadowProgram() artifact = %s, want %s", got.Artifact, prog.Shadow.Artifact)
+	}
+	if shadowInterval(prog.Shadow) != defaultShadowInterval || shadowSamples(prog.Shadow) != defaultShadowSamples {
+		t.Errorf("shadowInterval() = %s shadowSamples() = %d, want the defaults", shadowInterval(prog.Shadow), shadowSamples(prog.Shadow))
+	}
+}
+
+func TestBPF_compareShadow(t *testing.T) {
+	savedTestRun := testRunProgram
+	t.Cleanup(func() { testRunProgram = savedTestRun })
+
+	// rl is followed by lb, the candidate of rl is running with the program ID 22
+	shadowMap := &fakeMap{entries: map[int]int{}}
+	useFakeEBPF(t, &fakeEBPF{
+		maps:     map[string]*fakeMap{"/sys/fs/bpf/shadow/rl_next_prog": shadowMap},
+		programs: map[ebpf.ProgramID]bool{13: true},
+	})
+
+	bpfList := list.New()
+	rl := &BPF{Program: models.BPFProgram{
+		Name:        "rl",
+		MapName:     "/sys/fs/bpf/rl_next_prog",
+		AdminStatus: models.Enabled,
+		Shadow:      &models.L3afDNFShadow{Version: "2.0", MapName: "/sys/fs/bpf/shadow/rl_next_prog", Samples: 4},
+	}, ProgID: 12}
+	rl.shadow = &BPF{Program: shadowProgram(&rl.Program), ProgID: 22, slot: slotShadow}
+	e := bpfList.PushBack(rl)
+	bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: "lb", AdminStatus: models.Enabled}, ProgID: 13})
+
+	// candidate drops the packets passed by the program
+	runs := 0
+	testRunProgram = func(progID int, packet []byte) (uint32, error) {
+		runs++
+		if progID == 22 && runs > 4 {
+			return verdicts["XDP_DROP"], nil
+		}
+		return verdicts["XDP_PASS"], nil
+	}
+	compared, diverged, err := rl.compareShadow(e, "sh0", models.XDPIngressType)
+	if err != nil {
+		t.Fatalf("compareShadow() error = %v", err)
+	}
+	if compared != 4 || diverged != 2 {
+		t.Errorf("compareShadow() compared %d diverged %d, want 4 and 2", compared, diverged)
+	}
+	if !reflect.DeepEqual(shadowMap.entries, map[int]int{0: 113}) || rl.shadowNext != 13 {
+		t.Errorf("compareShadow() candidate map = %v, want the successor linked to the candidate", shadowMap.entries)
+	}
+}
+
+func TestNFConfigs_syncShadowInterval(t *testing.T) {
+	savedTestRun := testRunProgram
+	t.Cleanup(func() { testRunProgram = savedTestRun })
+	useFakeEBPF(t, &fakeEBPF{maps: map[string]*fakeMap{"/sys/fs/bpf/shadow/rl_next_prog": {entries: map[int]int{}}}})
+
+	runs := 0
+	testRunProgram = func(progID int, packet []byte) (uint32, error) {
+		runs++
+		return verdicts["XDP_PASS"], nil
+	}
+
+	rl := &BPF{Program: models.BPFProgram{
+		Name:        "rl",
+		MapName:     "/sys/fs/bpf/rl_next_prog",
+		AdminStatus: models.Enabled,
+		Shadow:      &models.L3afDNFShadow{Version: "2.0", MapName: "/sys/fs/bpf/shadow/rl_next_prog", Samples: 1, Interval: "1m"},
+	}, ProgID: 12}
+	rl.shadow = &BPF{Program: shadowProgram(&rl.Program), ProgID: 22, slot: slotShadow}
+	e := list.New().PushBack(rl)
+
+	c := &NFConfigs{hostConfig: &config.Config{BpfChainingEnabled: true}}
+	now := time.Now()
+	c.syncShadow(e, "sh0", models.XDPIngressType, now)
+	c.syncShadow(e, "sh0", models.XDPIngressType, now.Add(30*time.Second))
+	if runs != 2 {
+		t.Errorf("syncShadow() test runs = %d, want the candidate evaluated once in the interval", runs)
+	}
+	c.syncShadow(e, "sh0", models.XDPIngressType, now.Add(time.Minute))
+	if runs != 4 {
+		t.Errorf("syncShadow() test runs = %d, want the candidate evaluated again after the interval", runs)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// sharedMap - pinned map shared by a program with other programs on the same interface
+type sharedMap struct {
+	pinPath string
+	// reference to the map held while consumers are running,
+	// this keeps the map alive even if the sharing program removes the pinned file
+	ebpfMap *ebpf.Map
+	// consumer programs, value is re-pinned path of the map for the consumer
+	consumers map[string]string
+	// sharing program stopped while consumers are running, the map is removed once the last consumer releases it
+	orphaned bool
+}
+
+type sharedMapRegistry struct {
+	mu   sync.Mutex
+	maps map[string]*sharedMap // key is iface, program name and logical map name
+}
+
+var sharedMaps = &sharedMapRegistry{maps: make(map[string]*sharedMap)}
+
+func sharedMapKey(ifaceName, progName, mapName string) string {
+	return ifaceName + "/" + progName + "/" + mapName
+}
+
+func consumerKey(ifaceName, direction, progName string) string {
+	return ifaceName + "/" + direction + "/" + progName
+}
+
+// consumedMapDir - l3afd owned bpffs dir the consumed maps of the consumer program are re-pinned into when they
+// declare no pin path, so the consumer never depends on the pin of the sharing program
+func consumedMapDir(ifaceName, direction, progName string) string {
+	return filepath.Join(nfCmdConfig.consumedMapDir, ifaceName+"_"+direction+"_"+progName)
+}
+
+// register - records the maps shared by the program once it is started
+func (r *sharedMapRegistry) register(ifaceName, progName string, maps map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, pinPath := range maps {
+		key := sharedMapKey(ifaceName, progName, name)
+		if sm, ok := r.maps[key]; ok {
+			sm.pinPath = pinPath
+			sm.orphaned = false
+			continue
+		}
+		log.Info().Msgf("program %s shares map %s pinned at %s", progName, name, pinPath)
+		r.maps[key] = &sharedMap{pinPath: pinPath, consumers: make(map[string]string)}
+	}
+}
+
+// unregister - removes the maps shared by the stopped program, maps still in use by consumers are retained as
+// orphaned until they are released
+func (r *sharedMapRegistry) unregister(ifaceName, progName string, maps map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name := range maps {
+		key := sharedMapKey(ifaceName, progName, name)
+		sm, ok := r.maps[key]
+		if !ok {
+			continue
+		}
+		if len(sm.consumers) > 0 {
+			log.Warn().Msgf("program %s stopped, shared map %s is retained for %d consumers", progName, name, len(sm.consumers))
+			sm.orphaned = true
+			continue
+		}
+		delete(r.maps, key)
+	}
+}
+
+// acquire - takes a reference of the shared map for the consumer program and returns the pinned map path to use
+func (r *sharedMapRegistry) acquire(ifaceName, direction, progName string, cm models.L3afDNFConsumedMap) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := sharedMapKey(ifaceName, cm.Program, cm.Name)
+	sm, ok := r.maps[key]
+	if !ok {
+		return "", fmt.Errorf("map %s is not shared by any running program %s on iface %s", cm.Name, cm.Program, ifaceName)
+	}
+
+	consumer := consumerKey(ifaceName, direction, progName)
+	if pinPath, ok := sm.consumers[consumer]; ok {
+		return pinPath, nil
+	}
+	if sm.orphaned {
+		return "", fmt.Errorf("map %s is not shared by any running program %s on iface %s", cm.Name, cm.Program, ifaceName)
+	}
+
+	if sm.ebpfMap == nil {
+		ebpfMap, err := ebpf.LoadPinnedMap(sm.pinPath, nil)
+		if err != nil {
+			return "", fmt.Errorf("unable to access shared map %s of program %s pinned at %s: %w", cm.Name, cm.Program, sm.pinPath, err)
+		}
+		sm.ebpfMap = ebpfMap
+	}
+
+	// the map is re-pinned for the consumer, into the l3afd owned dir when the consumer declares no pin path
+	pinPath := cm.PinPath
+	if len(pinPath) == 0 {
+		dir := consumedMapDir(ifaceName, direction, progName)
+		if err := appFS.MkdirAll(dir, 0700); err != nil {
+			r.releaseMap(key, sm)
+			return "", fmt.Errorf("failed to create the consumed map dir %s: %w", dir, err)
+		}
+		pinPath = filepath.Join(dir, cm.Program+"_"+cm.Name)
+		// stale pin of a previous run of l3afd
+		if err := appFS.Remove(pinPath); err != nil && !os.IsNotExist(err) {
+			r.releaseMap(key, sm)
+			return "", fmt.Errorf("failed to remove the stale consumed map %s: %w", pinPath, err)
+		}
+	}
+	cloneMap, err := sm.ebpfMap.Clone()
+	if err != nil {
+		r.releaseMap(key, sm)
+		return "", fmt.Errorf("failed to clone shared map %s of program %s: %w", cm.Name, cm.Program, err)
+	}
+	defer cloneMap.Close()
+	if err := cloneMap.Pin(pinPath); err != nil {
+		r.releaseMap(key, sm)
+		return "", fmt.Errorf("failed to re-pin shared map %s of program %s at %s: %w", cm.Name, cm.Program, pinPath, err)
+	}
+
+	sm.consumers[consumer] = pinPath
+	log.Info().Msgf("program %s acquired shared map %s of program %s, consumers %d", progName, cm.Name, cm.Program, len(sm.consumers))
+	return pinPath, nil
+}
+
+// release - drops all the shared map references of the consumer program
+func (r *sharedMapRegistry) release(ifaceName, direction, progName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	consumer := consumerKey(ifaceName, direction, progName)
+	for key, sm := range r.maps {
+		pinPath, ok := sm.consumers[consumer]
+		if !ok {
+			continue
+		}
+		if len(pinPath) > 0 {
+			if err := appFS.Remove(pinPath); err != nil && !os.IsNotExist(err) {
+				log.Warn().Err(err).Msgf("failed to remove re-pinned shared map %s", pinPath)
+			}
+		}
+		delete(sm.consumers, consumer)
+		log.Info().Msgf("program %s released shared map %s, consumers %d", progName, key, len(sm.consumers))
+		r.releaseMap(key, sm)
+	}
+	// the dir of the consumer is removed once empty
+	_ = appFS.Remove(consumedMapDir(ifaceName, direction, progName))
+}
+
+// releaseMap - closes the map reference when there are no consumers, the orphaned map is removed
+func (r *sharedMapRegistry) releaseMap(key string, sm *sharedMap) {
+	if len(sm.consumers) > 0 {
+		return
+	}
+	if sm.ebpfMap != nil {
+		if err := sm.ebpfMap.Close(); err != nil {
+			log.Warn().Err(err).Msgf("failed to close shared map %s", sm.pinPath)
+		}
+		sm.ebpfMap = nil
+	}
+	if sm.orphaned {
+		log.Info().Msgf("orphaned shared map %s released by its last consumer", key)
+		delete(r.maps, key)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_sharedMapRegistry_acquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		shared   map[string]string
+		orphaned bool
+		cm       models.L3afDNFConsumedMap
+		wantErr  bool
+	}{
+		{
+			name:    "NotShared",
+			shared:  nil,
+			cm:      models.L3afDNFConsumedMap{Name: "flows", Program: "connection-tracker"},
+			wantErr: true,
+		},
+		{
+			name:    "SharedByOtherProgram",
+			shared:  map[string]string{"flows": "/sys/fs/bpf/flows"},
+			cm:      models.L3afDNFConsumedMap{Name: "flows", Program: "ratelimiting"},
+			wantErr: true,
+		},
+		{
+			name:     "SharingProgramStopped",
+			shared:   map[string]string{"flows": "/sys/fs/bpf/flows"},
+			orphaned: true,
+			cm:       models.L3afDNFConsumedMap{Name: "flows", Program: "connection-tracker"},
+			wantErr:  true,
+		},
+		{
+			name:    "PinnedMapAbsent",
+			shared:  map[string]string{"flows": "/sys/fs/bpf/l3afd-test-absent-map"},
+			cm:      models.L3afDNFConsumedMap{Name: "flows", Program: "connection-tracker"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &sharedMapRegistry{maps: make(map[string]*sharedMap)}
+			r.register("dummy", "connection-tracker", tt.shared)
+			if tt.orphaned {
+				r.maps[sharedMapKey("dummy", "connection-tracker", "flows")].consumers[consumerKey("dummy", models.IngressType, "ratelimiting")] = ""
+				r.unregister("dummy", "connection-tracker", tt.shared)
+			}
+			_, err := r.acquire("dummy", models.IngressType, "flow-exporter", tt.cm)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sharedMapRegistry.acquire() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_sharedMapRegistry_register(t *testing.T) {
+	shared := map[string]string{"flows": "/sys/fs/bpf/flows"}
+	r := &sharedMapRegistry{maps: make(map[string]*sharedMap)}
+	r.register("dummy", "connection-tracker", shared)
+	sm := r.maps[sharedMapKey("dummy", "connection-tracker", "flows")]
+	sm.consumers[consumerKey("dummy", models.IngressType, "flow-exporter")] = ""
+	r.unregister("dummy", "connection-tracker", shared)
+
+	// restart of the sharing program before its consumers released the map shares the map again
+	r.register("dummy", "connection-tracker", map[string]string{"flows": "/sys/fs/bpf/connection-tracker/flows"})
+	if sm.orphaned || sm.pinPath != "/sys/fs/bpf/connection-tracker/flows" {
+		t.Errorf("sharedMapRegistry.register() = %+v, want the map shared again", sm)
+	}
+	r.release("dummy", models.IngressType, "flow-exporter")
+	if len(r.maps) != 1 {
+		t.Errorf("sharedMapRegistry.release() removed the map shared again")
+	}
+}
+
+func Test_sharedMapRegistry_unregister(t *testing.T) {
+	tests := []struct {
+		name      string
+		consumers map[string]string
+		wantKept  bool
+	}{
+		{
+			name:      "NoConsumers",
+			consumers: map[string]string{},
+			wantKept:  false,
+		},
+		{
+			name:      "InUseByConsumer",
+			consumers: map[string]string{consumerKey("dummy", models.IngressType, "flow-exporter"): ""},
+			wantKept:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shared := map[string]string{"flows": "/sys/fs/bpf/flows"}
+			r := &sharedMapRegistry{maps: make(map[string]*sharedMap)}
+			r.register("dummy", "connection-tracker", shared)
+			r.maps[sharedMapKey("dummy", "connection-tracker", "flows")].consumers = tt.consumers
+			r.unregister("dummy", "connection-tracker", shared)
+			sm, ok := r.maps[sharedMapKey("dummy", "connection-tracker", "flows")]
+			if ok != tt.wantKept || (ok && !sm.orphaned) {
+				t.Errorf("sharedMapRegistry.unregister() kept = %v, want %v orphaned", ok, tt.wantKept)
+			}
+			// the orphaned map is removed by the release of its last consumer
+			r.release("dummy", models.IngressType, "flow-exporter")
+			if len(r.maps) != 0 {
+				t.Errorf("sharedMapRegistry.release() left %d shared maps", len(r.maps))
+			}
+		})
+	}
+}
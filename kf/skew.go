@@ -0,0 +1,123 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// binaryHash - returns the hex sha256 of the file
+func binaryHash(path string) (string, error) {
+	f, err := appFS.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// versionSkew - returns the drift of the running program from the configured artifact and version,
+// empty when the program matches. The binary on disk and the binary of the running process are
+// compared with the binary of the artifact at start, the version reported by the JSON status
+// command is compared with the configured version.
+func (b *BPF) versionSkew() string {
+	var drift []string
+
+	if len(b.BinaryHash) > 0 {
+		cmd := filepath.Join(b.FilePath, b.Program.CmdStart)
+		if hash, err := binaryHash(cmd); err != nil {
+			drift = append(drift, fmt.Sprintf("binary %s is not readable", cmd))
+		} else if hash != b.BinaryHash {
+			drift = append(drift, fmt.Sprintf("binary %s is modified", cmd))
+		}
+
+		if b.Program.UserProgramDaemon && b.Cmd != nil && b.Cmd.Process != nil {
+			if exe, err := processExePath(b.Cmd.Process.Pid); err == nil {
+				if hash, err := binaryHash(exe); err == nil && hash != b.BinaryHash {
+					drift = append(drift, fmt.Sprintf("running binary of pid %d differs from the artifact", b.Cmd.Process.Pid))
+				}
+			}
+		}
+	}
+
+	if b.NFStatus != nil && len(b.NFStatus.Version) > 0 && b.Program.Version != "latest" &&
+		b.NFStatus.Version != b.Program.Version {
+		drift = append(drift, fmt.Sprintf("reported version %s, configured version %s", b.NFStatus.Version, b.Program.Version))
+	}
+
+	return strings.Join(drift, "; ")
+}
+
+// StartVersionSkewCheck - verifies the running programs match the configured artifacts and versions at
+// every check interval. Drift is flagged in the program status and the NFVersionSkew metric.
+func (c *NFConfigs) StartVersionSkewCheck(ctx context.Context) {
+	if c.hostConfig.VersionSkewCheckInterval <= 0 {
+		log.Info().Msg("version skew check is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.hostConfig.VersionSkewCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			c.checkVersionSkew()
+		}
+	}()
+}
+
+func (c *NFConfigs) checkVersionSkew() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for direction, bpfLists := range map[string]map[string]*list.List{
+		models.XDPIngressType: c.IngressXDPBpfs,
+		models.IngressType:    c.IngressTCBpfs,
+		models.EgressType:     c.EgressTCBpfs,
+	} {
+		for ifaceName, bpfList := range bpfLists {
+			if bpfList == nil {
+				continue
+			}
+			for e := bpfList.Front(); e != nil; e = e.Next() {
+				bpf := e.Value.(*BPF)
+				if !loadedStatus(bpf.Program.AdminStatus) {
+					continue
+				}
+				skew := bpf.versionSkew()
+				if skew != bpf.VersionSkew && len(skew) > 0 {
+					log.Warn().Msgf("program %s iface %s direction %s drifted from the config: %s", bpf.Program.Name, ifaceName, direction, skew)
+				}
+				bpf.VersionSkew = skew
+				value := 0.0
+				if len(skew) > 0 {
+					value = 1.0
+				}
+				stats.Set(value, stats.NFVersionSkew, bpf.Program.Name, direction)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestBPF_versionSkew(t *testing.T) {
+	sum := sha256.Sum256([]byte("ratelimiting v1"))
+	hash := hex.EncodeToString(sum[:])
+	tests := []struct {
+		name     string
+		binary   string
+		hash     string
+		version  string
+		status   *models.L3afDNFStatus
+		wantSkew bool
+	}{
+		{
+			name:     "NoSkew",
+			binary:   "ratelimiting v1",
+			hash:     hash,
+			version:  "1.0",
+			status:   &models.L3afDNFStatus{State: "RUNNING", Version: "1.0"},
+			wantSkew: false,
+		},
+		{
+			name:     "BinaryModified",
+			binary:   "ratelimiting hotfix",
+			hash:     hash,
+			version:  "1.0",
+			wantSkew: true,
+		},
+		{
+			name:     "ReportedVersion",
+			binary:   "ratelimiting v1",
+			hash:     hash,
+			version:  "1.0",
+			status:   &models.L3afDNFStatus{State: "RUNNING", Version: "1.1"},
+			wantSkew: true,
+		},
+		{
+			name:     "LatestVersion",
+			binary:   "ratelimiting v1",
+			hash:     hash,
+			version:  "latest",
+			status:   &models.L3afDNFStatus{State: "RUNNING", Version: "1.1"},
+			wantSkew: false,
+		},
+		{
+			name:     "NoHashAtStart",
+			binary:   "ratelimiting hotfix",
+			hash:     "",
+			version:  "1.0",
+			wantSkew: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t, map[string]string{"/var/l3afd/ratelimiting/1.0/l3af_ratelimiting/ratelimiting": tt.binary})
+			b := &BPF{
+				Program:    models.BPFProgram{Name: "ratelimiting", CmdStart: "ratelimiting", Version: tt.version},
+				FilePath:   "/var/l3afd/ratelimiting/1.0/l3af_ratelimiting",
+				BinaryHash: tt.hash,
+				NFStatus:   tt.status,
+			}
+			if skew := b.versionSkew(); (len(skew) > 0) != tt.wantSkew {
+				t.Errorf("versionSkew() = %q, wantSkew %v", skew, tt.wantSkew)
+			}
+		})
+	}
+}
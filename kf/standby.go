@@ -0,0 +1,188 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// loadedStatus - program of the admin status is running, linked into the chain or in standby
+func loadedStatus(adminStatus string) bool {
+	return adminStatus == models.Enabled || adminStatus == models.Standby
+}
+
+// standby - program is running but not linked into the chain until it is activated
+func (b *BPF) standby() bool {
+	return b.Program.AdminStatus == models.Standby
+}
+
+// ValidateStandby - Verifies the chaining is enabled for the programs in standby, they are activated by linking
+// them into the chain
+func (c *NFConfigs) ValidateStandby(bpfProgs []models.L3afBPFPrograms) error {
+	chain := c.hostConfig != nil && c.hostConfig.BpfChainingEnabled
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog != nil && ref.prog.AdminStatus == models.Standby && !chain {
+				return fmt.Errorf("program %s on iface %s is in standby, which requires bpf chaining", ref.prog.Name, cfg.Iface)
+			}
+		}
+	}
+	return nil
+}
+
+// standbyMapPath - staging prog map of the standby program, the program inserts its program into it in place of
+// the map of its predecessor
+func standbyMapPath(ifaceName, direction, name string) string {
+	return filepath.Join(nfCmdConfig.standbyMapDir, ifaceName+"_"+direction+"_"+name)
+}
+
+// createStandbyMap - creates and pins the single entry staging prog map, the pinned map is reused
+func createStandbyMap(path string) error {
+	if fileExists(path) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create the standby map dir: %w", err)
+	}
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create standby map: %w", err)
+	}
+	defer m.Close()
+	if err := m.Pin(path); err != nil {
+		return fmt.Errorf("failed to pin standby map %s: %w", path, err)
+	}
+	return nil
+}
+
+// stageStandby - points the program to its staging map, so its start and restarts do not link it into the chain
+func (b *BPF) stageStandby(ifaceName, direction string) error {
+	path := standbyMapPath(ifaceName, direction, b.Program.Name)
+	if err := createStandbyMap(path); err != nil {
+		return err
+	}
+	b.PrevMapName = path
+	b.standbyMap = path
+	return nil
+}
+
+// removeStandbyMap - removes the staging map of the stopped or activated program
+func (b *BPF) removeStandbyMap() {
+	if len(b.standbyMap) == 0 {
+		return
+	}
+	if err := os.Remove(b.standbyMap); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msgf("failed to remove standby map %s of program %s", b.standbyMap, b.Program.Name)
+	}
+	b.standbyMap = ""
+}
+
+// activateBPF - links the program in standby into the chain at its sequence position, the program is running
+// already so the chain is updated within milliseconds
+func activateBPF(e *list.Element, ifaceName, direction string) error {
+	bpf := e.Value.(*BPF)
+	if bpf.Degraded {
+		return fmt.Errorf("program %s is bypassed, it can't be activated", bpf.Program.Name)
+	}
+	if bpf.ProgID == 0 {
+		return fmt.Errorf("program %s is not loaded", bpf.Program.Name)
+	}
+	started := time.Now()
+	if err := linkBPF(e); err != nil {
+		return fmt.Errorf("failed to activate program %s: %w", bpf.Program.Name, err)
+	}
+	bpf.Program.AdminStatus = models.Enabled
+	bpf.removeStandbyMap()
+	log.Info().Msgf("program %s iface %s direction %s is activated in %s", bpf.Program.Name, ifaceName, direction, time.Since(started))
+	return nil
+}
+
+// standbyBPF - unlinks the running program from the chain, the program keeps running in standby
+func standbyBPF(e *list.Element, ifaceName, direction string) error {
+	bpf := e.Value.(*BPF)
+	if !bpf.Degraded {
+		if err := unlinkBPF(e); err != nil {
+			return fmt.Errorf("failed to put program %s in standby: %w", bpf.Program.Name, err)
+		}
+	}
+	bpf.Program.AdminStatus = models.Standby
+	bpf.activated = false
+	if err := bpf.stageStandby(ifaceName, direction); err != nil {
+		log.Warn().Err(err).Msgf("program %s in standby is restarted into the map of its predecessor", bpf.Program.Name)
+	}
+	log.Info().Msgf("program %s iface %s direction %s is in standby", bpf.Program.Name, ifaceName, direction)
+	return nil
+}
+
+// findBPFElement - list element of the program running on the interface in the direction
+func (c *NFConfigs) findBPFElement(iface, direction, name string) (*list.Element, error) {
+	bpfLists, err := c.bpfLists(direction)
+	if err != nil {
+		return nil, err
+	}
+	if bpfList := bpfLists[iface]; bpfList != nil {
+		for e := bpfList.Front(); e != nil; e = e.Next() {
+			if e.Value.(*BPF).Program.Name == name {
+				return e, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("program %s is not running on iface %s direction %s", name, iface, direction)
+}
+
+// ActivateBPFProgram - links the program in standby into the chain. The program stays activated on config
+// applies until its config is enabled or disabled.
+func (c *NFConfigs) ActivateBPFProgram(iface, direction, name, remote string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, err := c.findBPFElement(iface, direction, name)
+	if err != nil {
+		return err
+	}
+	bpf := e.Value.(*BPF)
+	if !bpf.standby() {
+		return fmt.Errorf("program %s is not in standby on iface %s direction %s", name, iface, direction)
+	}
+	if err := activateBPF(e, iface, direction); err != nil {
+		return err
+	}
+	bpf.activated = true
+	c.Audit("nf-activate", remote, map[string]string{"iface": iface, "direction": direction, "program": name})
+	return nil
+}
+
+// StandbyBPFProgram - unlinks the activated program from the chain, it keeps running in standby
+func (c *NFConfigs) StandbyBPFProgram(iface, direction, name, remote string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, err := c.findBPFElement(iface, direction, name)
+	if err != nil {
+		return err
+	}
+	bpf := e.Value.(*BPF)
+	if !bpf.activated {
+		return fmt.Errorf("program %s is not activated on iface %s direction %s", name, iface, direction)
+	}
+	if err := standbyBPF(e, iface, direction); err != nil {
+		return err
+	}
+	c.Audit("nf-standby", remote, map[string]string{"iface": iface, "direction": direction, "program": name})
+	return nil
+}
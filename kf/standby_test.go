@@ -0,0 +1,158 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_loadedStatus(t *testing.T) {
+	if !loadedStatus(models.Enabled) || !loadedStatus(models.Standby) {
+		t.Errorf("loadedStatus() = false, want the enabled and standby programs loaded")
+	}
+	if loadedStatus(models.Disabled) || loadedStatus("") {
+		t.Errorf("loadedStatus() = true, want the disabled program not loaded")
+	}
+}
+
+func TestNFConfigs_ValidateStandby(t *testing.T) {
+	progs := []models.L3afBPFPrograms{{
+		Iface: "eth0",
+		BpfPrograms: &models.BPFPrograms{
+			XDPIngress: []*models.BPFProgram{{Name: "scrubber", AdminStatus: models.Standby}},
+		},
+	}}
+	c := &NFConfigs{hostConfig: &config.Config{BpfChainingEnabled: true}}
+	if err := c.ValidateStandby(progs); err != nil {
+		t.Errorf("ValidateStandby() error = %v, want the standby program chained", err)
+	}
+	c.hostConfig.BpfChainingEnabled = false
+	if err := c.ValidateStandby(progs); err == nil {
+		t.Errorf("ValidateStandby() error = nil, want the standby program rejected without chaining")
+	}
+}
+
+func TestNFConfigs_ActivateBPFProgram(t *testing.T) {
+	dir := t.TempDir()
+	savedCmdConfig := nfCmdConfig
+	nfCmdConfig.standbyMapDir = dir
+	t.Cleanup(func() { nfCmdConfig = savedCmdConfig })
+
+	// root -> rl -> lb while the scrubber cl is in standby, cl is running with the program ID 12
+	rootMap := &fakeMap{entries: map[int]int{0: 111}}
+	rlMap := &fakeMap{entries: map[int]int{0: 113}}
+	clMap := &fakeMap{entries: map[int]int{}}
+	useFakeEBPF(t, &fakeEBPF{
+		maps: map[string]*fakeMap{
+			"/sys/fs/bpf/root_next_prog": rootMap,
+			"/sys/fs/bpf/rl_next_prog":   rlMap,
+			"/sys/fs/bpf/cl_next_prog":   clMap,
+		},
+		programs: map[ebpf.ProgramID]bool{11: true, 12: true, 13: true},
+	})
+
+	bpfList := list.New()
+	var elements []*list.Element
+	for i, name := range []string{"root", "rl", "cl", "lb"} {
+		b := &BPF{Program: models.BPFProgram{Name: name, MapName: "/sys/fs/bpf/" + name + "_next_prog", AdminStatus: models.Enabled}, ProgID: 10 + i}
+		if i > 0 {
+			b.PrevMapName = elements[i-1].Value.(*BPF).Program.MapName
+		}
+		elements = append(elements, bpfList.PushBack(b))
+	}
+	cl, lb := elements[2].Value.(*BPF), elements[3].Value.(*BPF)
+	staging := filepath.Join(dir, "eth0_xdpingress_cl")
+	if err := os.WriteFile(staging, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cl.Program.AdminStatus = models.Standby
+	cl.PrevMapName = staging
+	cl.standbyMap = staging
+	lb.PrevMapName = "/sys/fs/bpf/rl_next_prog"
+
+	c := &NFConfigs{
+		hostConfig:     &config.Config{BpfChainingEnabled: true},
+		IngressXDPBpfs: map[string]*list.List{"eth0": bpfList},
+		mu:             new(sync.Mutex),
+	}
+	if err := c.StandbyBPFProgram("eth0", models.XDPIngressType, "cl", "test"); err == nil {
+		t.Errorf("StandbyBPFProgram() error = nil, want the program not activated by the API rejected")
+	}
+	if err := c.ActivateBPFProgram("eth0", models.XDPIngressType, "rl", "test"); err == nil {
+		t.Errorf("ActivateBPFProgram() error = nil, want the enabled program rejected")
+	}
+
+	if err := c.ActivateBPFProgram("eth0", models.XDPIngressType, "cl", "test"); err != nil {
+		t.Fatalf("ActivateBPFProgram() error = %v", err)
+	}
+	if cl.standby() || !cl.activated {
+		t.Errorf("ActivateBPFProgram() admin status %s activated %v, want the program activated", cl.Program.AdminStatus, cl.activated)
+	}
+	if !reflect.DeepEqual(rlMap.entries, map[int]int{0: 112}) || !reflect.DeepEqual(clMap.entries, map[int]int{0: 113}) {
+		t.Errorf("ActivateBPFProgram() rl map = %v cl map = %v, want rl -> cl -> lb", rlMap.entries, clMap.entries)
+	}
+	if cl.PrevMapName != "/sys/fs/bpf/rl_next_prog" || lb.PrevMapName != "/sys/fs/bpf/cl_next_prog" {
+		t.Errorf("ActivateBPFProgram() prev maps = %s and %s, want the chain relinked", cl.PrevMapName, lb.PrevMapName)
+	}
+	if fileExists(staging) || len(cl.standbyMap) > 0 {
+		t.Errorf("ActivateBPFProgram() staging map %s is not removed", staging)
+	}
+
+	// staging map pinned again stands in for the created map
+	if err := os.WriteFile(staging, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StandbyBPFProgram("eth0", models.XDPIngressType, "cl", "test"); err != nil {
+		t.Fatalf("StandbyBPFProgram() error = %v", err)
+	}
+	if !cl.standby() || cl.activated {
+		t.Errorf("StandbyBPFProgram() admin status %s activated %v, want the program in standby", cl.Program.AdminStatus, cl.activated)
+	}
+	if !reflect.DeepEqual(rlMap.entries, map[int]int{0: 113}) || lb.PrevMapName != "/sys/fs/bpf/rl_next_prog" {
+		t.Errorf("StandbyBPFProgram() rl map = %v lb prev map = %s, want rl -> lb", rlMap.entries, lb.PrevMapName)
+	}
+	if cl.PrevMapName != staging {
+		t.Errorf("StandbyBPFProgram() prev map = %s, want the staging map %s", cl.PrevMapName, staging)
+	}
+}
+
+func TestNFConfigs_MoveToLocationStandby(t *testing.T) {
+	// maps of the chain are not touched by the move of the program in standby
+	rlMap := &fakeMap{entries: map[int]int{0: 113}}
+	useFakeEBPF(t, &fakeEBPF{maps: map[string]*fakeMap{"/sys/fs/bpf/rl_next_prog": rlMap}})
+
+	bpfList := list.New()
+	for i, name := range []string{"root", "rl", "cl", "lb"} {
+		bpfList.PushBack(&BPF{Program: models.BPFProgram{Name: name, SeqID: i, MapName: "/sys/fs/bpf/" + name + "_next_prog", AdminStatus: models.Enabled}})
+	}
+	e := bpfList.Front().Next().Next()
+	cl := e.Value.(*BPF)
+	cl.Program.AdminStatus = models.Standby
+	cl.Program.SeqID = 5
+
+	c := &NFConfigs{hostConfig: &config.Config{BpfChainingEnabled: true}}
+	if err := c.MoveToLocation(e, bpfList); err != nil {
+		t.Fatalf("MoveToLocation() error = %v", err)
+	}
+	var names []string
+	for e := bpfList.Front(); e != nil; e = e.Next() {
+		names = append(names, e.Value.(*BPF).Program.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"root", "rl", "lb", "cl"}) {
+		t.Errorf("MoveToLocation() chain = %v, want the program moved to the back", names)
+	}
+	if !reflect.DeepEqual(rlMap.entries, map[int]int{0: 113}) {
+		t.Errorf("MoveToLocation() rl map = %v, want the chain not relinked", rlMap.entries)
+	}
+}
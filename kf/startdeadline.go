@@ -0,0 +1,110 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Start phases of the program recorded for the start deadline
+const (
+	StartPhaseDownload = "download"
+	StartPhaseInspect  = "inspect"
+	StartPhaseLoad     = "load"
+	StartPhasePin      = "pin"
+	StartPhaseMapArgs  = "map-args"
+	StartPhaseProgID   = "prog-id"
+)
+
+// beginStart - sets the overall deadline of the start when no start is in progress, returns whether
+// the deadline was set so the caller ends the start. Zero timeout means no deadline.
+func (b *BPF) beginStart(timeout time.Duration) bool {
+	if !b.startDeadline.IsZero() || timeout <= 0 {
+		return false
+	}
+	b.startDeadline = time.Now().Add(timeout)
+	b.startPhase = ""
+	b.StartFailure = ""
+	return true
+}
+
+// endStart - clears the deadline of the start
+func (b *BPF) endStart() {
+	b.startDeadline = time.Time{}
+}
+
+// enterStartPhase - records the phase the start is in, the time taken by the previous phase is observed
+func (b *BPF) enterStartPhase(phase string) {
+	b.finishStartPhase()
+	b.startPhase = phase
+	b.phaseStarted = time.Now()
+}
+
+// finishStartPhase - observes the time taken by the phase the start is in, the phase is kept for the
+// failure of the start
+func (b *BPF) finishStartPhase() {
+	if len(b.startPhase) > 0 && !b.phaseStarted.IsZero() {
+		stats.Observe(time.Since(b.phaseStarted), stats.NFPhaseDuration, b.Program.Name, "start", b.startPhase)
+	}
+	b.phaseStarted = time.Time{}
+}
+
+// startExpired - the start deadline is exceeded, wait loops of the start stop retrying
+func (b *BPF) startExpired() bool {
+	return !b.startDeadline.IsZero() && time.Now().After(b.startDeadline)
+}
+
+// startTimeLeft - returns the timeout capped by the time left to the start deadline
+func (b *BPF) startTimeLeft(timeout time.Duration) time.Duration {
+	if b.startDeadline.IsZero() {
+		return timeout
+	}
+	left := time.Until(b.startDeadline)
+	if left <= 0 {
+		left = time.Millisecond
+	}
+	if timeout <= 0 || left < timeout {
+		return left
+	}
+	return timeout
+}
+
+// abortStart - kills the partially started program, removes its chaining entries and pins and marks the
+// program failed with the phase the start stalled in, so the chain is not left half wired
+func (b *BPF) abortStart(ifaceName, direction string, chain bool) error {
+	b.StartFailure = fmt.Sprintf("start timed out in phase %s", b.startPhase)
+	log.Error().Msgf("program %s iface %s direction %s %s, aborting the start", b.Program.Name, ifaceName, direction, b.StartFailure)
+
+	if b.Cmd != nil && b.Cmd.Process != nil {
+		if err := signalNFProcess(b.Cmd, syscall.SIGKILL); err != nil {
+			log.Warn().Err(err).Msgf("failed to kill the program %s", b.Program.Name)
+		}
+		b.Cmd = nil
+	}
+
+	if len(b.PrevMapName) > 0 {
+		if err := b.RemovePrevProgFD(); err != nil {
+			log.Warn().Err(err).Msgf("failed to remove the chaining entry of the program %s", b.Program.Name)
+		}
+	}
+
+	if chain && len(b.Program.MapName) > 0 {
+		if err := appFS.Remove(b.Program.MapName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warn().Err(err).Msgf("failed to remove the pinned map %s", b.Program.MapName)
+		}
+	}
+
+	sharedMaps.release(ifaceName, direction, b.slotName())
+	stats.Set(0.0, stats.NFRunning, b.Program.Name, direction)
+	notifyEvent(EventProgramFailed, ifaceName, direction, b.Program.Name, b.StartFailure)
+	return fmt.Errorf("program %s %s", b.Program.Name, b.StartFailure)
+}
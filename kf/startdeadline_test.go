@@ -0,0 +1,107 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBPF_startTimeLeft(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Duration
+		timeout  time.Duration
+		wantMax  time.Duration
+		wantMin  time.Duration
+	}{
+		{
+			name:    "NoDeadline",
+			timeout: time.Minute,
+			wantMax: time.Minute,
+			wantMin: time.Minute,
+		},
+		{
+			name:     "CappedByDeadline",
+			deadline: 10 * time.Second,
+			timeout:  time.Minute,
+			wantMax:  10 * time.Second,
+			wantMin:  9 * time.Second,
+		},
+		{
+			name:     "NoTimeout",
+			deadline: 10 * time.Second,
+			timeout:  0,
+			wantMax:  10 * time.Second,
+			wantMin:  9 * time.Second,
+		},
+		{
+			name:     "TimeoutBeforeDeadline",
+			deadline: time.Minute,
+			timeout:  10 * time.Second,
+			wantMax:  10 * time.Second,
+			wantMin:  10 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &BPF{}
+			b.beginStart(tt.deadline)
+			got := b.startTimeLeft(tt.timeout)
+			if got > tt.wantMax || got < tt.wantMin {
+				t.Errorf("startTimeLeft() = %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestBPF_abortStart(t *testing.T) {
+	m := useMemFS(t, map[string]string{"/sys/fs/bpf/xdp_rl_ingress_next_prog": ""})
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", MapName: "/sys/fs/bpf/xdp_rl_ingress_next_prog"}}
+	b.beginStart(time.Nanosecond)
+	b.enterStartPhase(StartPhasePin)
+	time.Sleep(time.Millisecond)
+
+	if !b.startExpired() {
+		t.Fatalf("startExpired() = false, want true")
+	}
+	if err := b.abortStart("enp0s3", models.XDPIngressType, true); err == nil {
+		t.Errorf("abortStart() error = nil, want start timeout error")
+	}
+	if b.StartFailure != "start timed out in phase pin" {
+		t.Errorf("abortStart() StartFailure = %q", b.StartFailure)
+	}
+	if _, err := m.Stat("/sys/fs/bpf/xdp_rl_ingress_next_prog"); err == nil {
+		t.Errorf("abortStart() pinned map is not removed")
+	}
+}
+
+func TestBPF_enterStartPhase(t *testing.T) {
+	saved := stats.NFPhaseDuration
+	t.Cleanup(func() { stats.NFPhaseDuration = saved })
+	stats.NFPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "phase_seconds"},
+		[]string{"network_function", "operation", "phase"})
+
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting"}}
+	b.enterStartPhase(StartPhaseLoad)
+	b.enterStartPhase(StartPhasePin)
+	b.finishStartPhase()
+	b.finishStartPhase()
+
+	if got := testutil.CollectAndCount(stats.NFPhaseDuration); got != 2 {
+		t.Errorf("observed phases = %d, want load and pin", got)
+	}
+	if !b.phaseStarted.IsZero() {
+		t.Errorf("phaseStarted is set after the phase is finished")
+	}
+	if b.startPhase != StartPhasePin {
+		t.Errorf("startPhase = %s after the phase is finished, want %s kept for the failure", b.startPhase, StartPhasePin)
+	}
+}
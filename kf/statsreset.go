@@ -0,0 +1,233 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoProgramsToReset is returned when no running program matches the program of the stats reset
+var ErrNoProgramsToReset = errors.New("no running programs match the stats reset")
+
+// validateResetCounters - map is set and the keys are keys, key ranges or the wildcard
+func validateResetCounters(counters []models.L3afDNFCounter) error {
+	for _, counter := range counters {
+		if len(counter.Map) == 0 {
+			return fmt.Errorf("reset counters map name is not set")
+		}
+		if _, _, err := parseMonitorKeys(counter.Keys); err != nil {
+			return fmt.Errorf("reset counters of map %s: %w", counter.Map, err)
+		}
+	}
+	return nil
+}
+
+// ValidateResetCounters - Verifies the maps and keys of the reset counters of the programs
+func ValidateResetCounters(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateResetCounters(ref.prog.ResetCounters); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// zeroCounters - zeroes the values of the keys of the map, of all the keys for the wildcard. Integer keys are in
+// the host byte order of the key size of the map, the keys not in a hash map are skipped. Returns the number of
+// the values zeroed.
+func zeroCounters(m ebpfMap, spec string) (int, error) {
+	info, err := m.Info()
+	if err != nil {
+		return 0, fmt.Errorf("fetching map info failed %v", err)
+	}
+	var zero interface{} = make([]byte, info.ValueSize)
+	switch info.Type {
+	case ebpf.Hash, ebpf.Array, ebpf.LRUHash:
+	case ebpf.PerCPUHash, ebpf.PerCPUArray, ebpf.LRUCPUHash:
+		// values of the CPUs missing in the slice are zeroed
+		zero = [][]byte{}
+	default:
+		return 0, fmt.Errorf("map type %s is not supported", info.Type)
+	}
+
+	keys, all, err := parseMonitorKeys(spec)
+	if err != nil {
+		return 0, err
+	}
+	var keyBytes [][]byte
+	if all {
+		var cur interface{}
+		for {
+			var key []byte
+			if err := m.NextKey(cur, &key); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					break
+				}
+				return 0, fmt.Errorf("map iteration failed %v", err)
+			}
+			keyBytes = append(keyBytes, key)
+			cur = key
+		}
+	}
+	for _, k := range keys {
+		key, err := encodeMapValue(fmt.Sprintf("u%d", info.KeySize*8), strconv.Itoa(k))
+		if err != nil {
+			return 0, fmt.Errorf("key %d is not a key of size %d: %v", k, info.KeySize, err)
+		}
+		keyBytes = append(keyBytes, key)
+	}
+
+	count := 0
+	for _, key := range keyBytes {
+		if err := m.Update(key, zero, ebpf.UpdateExist); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				continue
+			}
+			return count, fmt.Errorf("failed to zero key %x: %w", key, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// resetCounterKey - key is one of the keys of the counters spec
+func resetCounterKey(spec string, key int) bool {
+	keys, all, err := parseMonitorKeys(spec)
+	if err != nil {
+		return false
+	}
+	if all {
+		return true
+	}
+	i := sort.SearchInts(keys, key)
+	return i < len(keys) && keys[i] == key
+}
+
+// resetCounters - zeroes the reset counters of the program and drops the samples of its monitor maps, so the
+// rates start over. Returns the number of the values zeroed.
+func (b *BPF) resetCounters() (int, error) {
+	var zeroed []models.L3afDNFCounter
+	var errs []string
+	count := 0
+	for _, counter := range b.Program.ResetCounters {
+		m, err := openProgramMap(b, counter.Map)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		n, err := zeroCounters(m, counter.Keys)
+		m.Close()
+		count += n
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("map %s: %v", counter.Map, err))
+			continue
+		}
+		zeroed = append(zeroed, counter)
+	}
+
+	for _, m := range b.MetricsBpfMaps {
+		if m.Values != nil {
+			for i, r := 0, m.Values; i < m.Values.Len(); i, r = i+1, r.Next() {
+				r.Value = nil
+			}
+		}
+		for _, counter := range zeroed {
+			if counter.Map == m.Name && resetCounterKey(counter.Keys, m.key) {
+				m.lastValue = 0
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return count, fmt.Errorf("failed to reset the counters of program %s: %s", b.Program.Name, strings.Join(errs, "; "))
+	}
+	return count, nil
+}
+
+// ResetStats - zeroes the reset counters of the running programs and resets their monitor map samples, metrics
+// history and counter metrics, scoped to the iface, direction and program when set. The counter metrics are
+// labeled by program and direction, they are reset for the program on all the interfaces.
+func (c *NFConfigs) ResetStats(iface, direction, program, remote string) (models.L3afDStatsReset, error) {
+	result := models.L3afDStatsReset{Programs: []string{}}
+	directions := []string{models.XDPIngressType, models.IngressType, models.EgressType}
+	if len(direction) > 0 {
+		if _, err := c.bpfLists(direction); err != nil {
+			return result, err
+		}
+		directions = []string{direction}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chain := c.hostConfig != nil && c.hostConfig.BpfChainingEnabled
+	scoped := len(iface) > 0 || len(direction) > 0 || len(program) > 0
+	reasons := []string{watchdogRSS, watchdogCPU, watchdogRestarts}
+	var errs []string
+	for _, dir := range directions {
+		bpfs, _ := c.bpfLists(dir)
+		for ifaceName, bpfList := range bpfs {
+			if bpfList == nil || (len(iface) > 0 && ifaceName != iface) {
+				continue
+			}
+			e := bpfList.Front()
+			if e != nil && chain {
+				// root program
+				e = e.Next()
+			}
+			for ; e != nil; e = e.Next() {
+				bpf := e.Value.(*BPF)
+				if len(program) > 0 && bpf.Program.Name != program {
+					continue
+				}
+				n, err := bpf.resetCounters()
+				if err != nil {
+					errs = append(errs, err.Error())
+				}
+				result.Counters += n
+				result.Programs = append(result.Programs, ifaceName+"/"+dir+"/"+bpf.Program.Name)
+				if scoped {
+					stats.ResetNFCounters(bpf.Program.Name, []string{dir}, reasons)
+					metricsHistory.reset(bpf.Program.Name)
+				}
+			}
+		}
+	}
+	if len(program) > 0 && len(result.Programs) == 0 {
+		return result, fmt.Errorf("program %s: %w", program, ErrNoProgramsToReset)
+	}
+	if !scoped {
+		stats.ResetNFCounters("", nil, nil)
+		metricsHistory.reset("")
+	}
+	sort.Strings(result.Programs)
+
+	c.Audit("stats-reset", remote, map[string]string{
+		"iface":     iface,
+		"direction": direction,
+		"program":   program,
+		"programs":  strings.Join(result.Programs, ","),
+		"counters":  strconv.Itoa(result.Counters),
+	})
+	log.Info().Msgf("stats of %d programs reset, %d map counters zeroed", len(result.Programs), result.Counters)
+	if len(errs) > 0 {
+		return result, errors.New(strings.Join(errs, "; "))
+	}
+	return result, nil
+}
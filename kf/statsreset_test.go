@@ -0,0 +1,109 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+// counterMap - hash map honoring the update of the existing keys only
+type counterMap struct {
+	fakeHashMap
+}
+
+func (m *counterMap) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	if _, ok := m.entries[string(key.([]byte))]; !ok && flags == ebpf.UpdateExist {
+		return ebpf.ErrKeyNotExist
+	}
+	return m.fakeHashMap.Update(key, value, flags)
+}
+
+func Test_validateResetCounters(t *testing.T) {
+	tests := []struct {
+		name     string
+		counters []models.L3afDNFCounter
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"keys", []models.L3afDNFCounter{{Map: "rl_drop_count_map", Keys: "0-3,8"}}, false},
+		{"wildcard", []models.L3afDNFCounter{{Map: "rl_recv_count_map", Keys: "*"}}, false},
+		{"no map", []models.L3afDNFCounter{{Keys: "0"}}, true},
+		{"no keys", []models.L3afDNFCounter{{Map: "rl_drop_count_map"}}, true},
+		{"invalid range", []models.L3afDNFCounter{{Map: "rl_drop_count_map", Keys: "4-1"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateResetCounters(tt.counters); (err != nil) != tt.wantErr {
+				t.Errorf("validateResetCounters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_zeroCounters(t *testing.T) {
+	key := func(k uint16) string {
+		b := make([]byte, 2)
+		nativeEndian.PutUint16(b, k)
+		return string(b)
+	}
+	tests := []struct {
+		name      string
+		mapType   ebpf.MapType
+		keys      string
+		wantCount int
+		wantZero  []uint16
+		wantErr   bool
+	}{
+		{"all keys", ebpf.Hash, "*", 3, []uint16{1, 2, 300}, false},
+		{"keys", ebpf.Hash, "1,300", 2, []uint16{1, 300}, false},
+		{"missing keys skipped", ebpf.Hash, "2-4", 1, []uint16{2}, false},
+		{"key exceeds key size", ebpf.Hash, "70000", 0, nil, true},
+		{"unsupported map", ebpf.ProgramArray, "*", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &counterMap{fakeHashMap{mapType: tt.mapType, entries: map[string][]byte{
+				key(1): {7}, key(2): {8}, key(300): {9},
+			}}}
+			count, err := zeroCounters(m, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("zeroCounters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if count != tt.wantCount {
+				t.Errorf("zeroCounters() = %d, want %d", count, tt.wantCount)
+			}
+			if len(m.entries) != 3 {
+				t.Errorf("entries = %v, want no keys created", m.entries)
+			}
+			zeroed := 0
+			for _, v := range m.entries {
+				if bytes.Equal(v, []byte{0}) {
+					zeroed++
+				}
+			}
+			for _, k := range tt.wantZero {
+				if !bytes.Equal(m.entries[key(k)], []byte{0}) {
+					t.Errorf("key %d = %v, want zeroed", k, m.entries[key(k)])
+				}
+			}
+			if zeroed != len(tt.wantZero) {
+				t.Errorf("%d values zeroed, want %d", zeroed, len(tt.wantZero))
+			}
+		})
+	}
+}
+
+func Test_resetCounterKey(t *testing.T) {
+	if !resetCounterKey("*", 42) || !resetCounterKey("0-3,8", 8) {
+		t.Errorf("resetCounterKey() = false, want the key of the counters")
+	}
+	if resetCounterKey("0-3,8", 5) || resetCounterKey("bogus", 0) {
+		t.Errorf("resetCounterKey() = true, want the key not of the counters")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stopGracePeriod - time the user program may take to exit after SIGTERM, the program grace period overrides
+// the l3afd default. Zero waits for the program to exit.
+func (b *BPF) stopGracePeriod() time.Duration {
+	if b.Program.StopGracePeriod > 0 {
+		return time.Duration(b.Program.StopGracePeriod) * time.Second
+	}
+	return nfCmdConfig.stopGrace
+}
+
+// waitTerminated - waits for the terminated user program to exit, the program is killed with SIGKILL when it
+// doesn't exit in the grace period. Returns whether the program is killed.
+func (b *BPF) waitTerminated(direction string, grace time.Duration) bool {
+	done := make(chan error, 1)
+	go func() { done <- b.Cmd.Wait() }()
+
+	var timeout <-chan time.Time
+	if grace > 0 {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Error().Err(err).Msgf("cmd wait at stopping bpf program %s errored", b.Program.Name)
+		}
+		// forked helpers outliving the program keep its maps referenced
+		if err := signalNFProcess(b.Cmd, syscall.SIGKILL); err == nil {
+			log.Warn().Msgf("helpers of program %s outlived it, killed its process group", b.Program.Name)
+		}
+		return false
+	case <-timeout:
+	}
+
+	log.Warn().Msgf("program %s didn't exit in %s after SIGTERM, killing it", b.Program.Name, grace)
+	if err := signalNFProcess(b.Cmd, syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		log.Error().Err(err).Msgf("failed to kill the program %s", b.Program.Name)
+		return false
+	}
+	<-done
+	stats.Incr(stats.NFForcedKillCount, b.Program.Name, direction)
+	return true
+}
+
+// removeKilledPins - removes the pinned map of the killed program, the program didn't get to remove it
+func (b *BPF) removeKilledPins(chain bool) {
+	if !chain || len(b.Program.MapName) == 0 {
+		return
+	}
+	if err := appFS.Remove(b.Program.MapName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Warn().Err(err).Msgf("failed to remove the pinned map %s of the killed program", b.Program.MapName)
+	}
+}
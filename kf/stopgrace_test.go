@@ -0,0 +1,75 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestBPF_stopGracePeriod(t *testing.T) {
+	saved := nfCmdConfig
+	nfCmdConfig.stopGrace = 30 * time.Second
+	t.Cleanup(func() { nfCmdConfig = saved })
+
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting"}}
+	if got := b.stopGracePeriod(); got != 30*time.Second {
+		t.Errorf("stopGracePeriod() = %s, want the l3afd default 30s", got)
+	}
+	b.Program.StopGracePeriod = 5
+	if got := b.stopGracePeriod(); got != 5*time.Second {
+		t.Errorf("stopGracePeriod() = %s, want the program grace period 5s", got)
+	}
+}
+
+func TestBPF_waitTerminated(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	tests := []struct {
+		name       string
+		script     string
+		wantKilled bool
+	}{
+		{name: "Exits", script: "exit 0", wantKilled: false},
+		{name: "IgnoresSIGTERM", script: "trap '' TERM; sleep 10", wantKilled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("/bin/sh", "-c", tt.script)
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("failed to start %q: %v", tt.script, err)
+			}
+			b := &BPF{Program: models.BPFProgram{Name: "ratelimiting"}, Cmd: cmd}
+			start := time.Now()
+			if killed := b.waitTerminated(models.XDPIngressType, 200*time.Millisecond); killed != tt.wantKilled {
+				t.Errorf("waitTerminated() = %v, want %v", killed, tt.wantKilled)
+			}
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("waitTerminated() took %s", time.Since(start))
+			}
+			if cmd.ProcessState == nil {
+				t.Errorf("waitTerminated() didn't reap the process")
+			}
+		})
+	}
+}
+
+func TestBPF_removeKilledPins(t *testing.T) {
+	m := useMemFS(t, map[string]string{"/sys/fs/bpf/ratelimiting": ""})
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", MapName: "/sys/fs/bpf/ratelimiting"}}
+
+	b.removeKilledPins(false)
+	if _, err := m.Stat(b.Program.MapName); err != nil {
+		t.Fatalf("pinned map is removed without chaining, error = %v", err)
+	}
+	b.removeKilledPins(true)
+	if _, err := m.Stat(b.Program.MapName); err == nil {
+		t.Errorf("pinned map of the killed program is not removed")
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/bpf"
+)
+
+const (
+	tapCollectorDialTimeout = 10 * time.Second
+	tapPerfBufferPages      = 64
+	tapRecentSamples        = 64 // samples kept for the validation of the blue/green upgrades
+)
+
+// packetTap - captures the packet samples of an interface published by the root program into the tap perf event map
+type packetTap struct {
+	config  models.L3afDTap
+	ebpfMap *ebpf.Map
+	reader  *perf.Reader
+	filter  *bpf.VM
+	writers []io.WriteCloser
+	done    chan struct{}
+
+	mu     sync.Mutex
+	recent [][]byte // latest samples, the oldest first
+}
+
+type tapRegistry struct {
+	mu   sync.Mutex
+	taps map[string]*packetTap // key is iface name
+}
+
+var packetTaps = &tapRegistry{taps: make(map[string]*packetTap)}
+
+// StartTap - Starts packet tap on the interface, the tap of the interface is replaced if already running
+func (c *NFConfigs) StartTap(tapCfg models.L3afDTap) error {
+	if len(tapCfg.Iface) == 0 {
+		return fmt.Errorf("iface name is empty")
+	}
+	// iface names the pinned tap map path
+	if strings.ContainsAny(tapCfg.Iface, `/\`) || !c.hostInterfaces[tapCfg.Iface] {
+		return fmt.Errorf("%s interface name not found in the host", tapCfg.Iface)
+	}
+	if len(tapCfg.Collector) > 0 && !tapCollectorAllowed(c.hostConfig.TapCollectors, tapCfg.Collector) {
+		return fmt.Errorf("tap collector %s is not allowed by the tap collectors of l3afd.cfg", tapCfg.Collector)
+	}
+	if len(tapCfg.PcapFile) == 0 && len(tapCfg.Collector) == 0 {
+		return fmt.Errorf("pcap file or collector is required to start tap on iface %s", tapCfg.Iface)
+	}
+	if tapCfg.SampleRate < 0 {
+		return fmt.Errorf("invalid sample rate %d", tapCfg.SampleRate)
+	}
+
+	var filter *bpf.VM
+	if len(tapCfg.Filter) > 0 {
+		insts, err := parseTapFilter(tapCfg.Filter)
+		if err != nil {
+			return fmt.Errorf("invalid tap filter: %w", err)
+		}
+		if filter, err = bpf.NewVM(insts); err != nil {
+			return fmt.Errorf("invalid tap filter: %w", err)
+		}
+	}
+
+	packetTaps.mu.Lock()
+	defer packetTaps.mu.Unlock()
+
+	if t, ok := packetTaps.taps[tapCfg.Iface]; ok {
+		t.stop()
+		delete(packetTaps.taps, tapCfg.Iface)
+	}
+
+	mapName := filepath.Join(c.hostConfig.TapMapDir, tapCfg.Iface+"_tap_events")
+	ebpfMap, err := ebpf.LoadPinnedMap(mapName, nil)
+	if err != nil {
+		return fmt.Errorf("unable to access tap perf event map %s: %w", mapName, err)
+	}
+
+	t := &packetTap{
+		config:  tapCfg,
+		ebpfMap: ebpfMap,
+		filter:  filter,
+		done:    make(chan struct{}),
+	}
+
+	if t.reader, err = perf.NewReader(ebpfMap, tapPerfBufferPages*os.Getpagesize()); err != nil {
+		t.close()
+		return fmt.Errorf("failed to create tap perf reader on iface %s: %w", tapCfg.Iface, err)
+	}
+
+	if len(tapCfg.PcapFile) > 0 {
+		pcapFile, err := tapPcapPath(c.hostConfig.TapPcapDir, tapCfg.PcapFile)
+		if err != nil {
+			t.close()
+			return err
+		}
+		if err := appFS.MkdirAll(c.hostConfig.TapPcapDir, 0700); err != nil {
+			t.close()
+			return fmt.Errorf("failed to create pcap dir %s: %w", c.hostConfig.TapPcapDir, err)
+		}
+		file, err := appFS.OpenFile(pcapFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			t.close()
+			return fmt.Errorf("failed to create pcap file %s: %w", pcapFile, err)
+		}
+		t.writers = append(t.writers, file)
+	}
+
+	if len(tapCfg.Collector) > 0 {
+		conn, err := net.DialTimeout("tcp", tapCfg.Collector, tapCollectorDialTimeout)
+		if err != nil {
+			t.close()
+			return fmt.Errorf("failed to connect to tap collector %s: %w", tapCfg.Collector, err)
+		}
+		t.writers = append(t.writers, conn)
+	}
+
+	pcaps := make([]*pcapWriter, 0, len(t.writers))
+	for _, w := range t.writers {
+		p, err := newPcapWriter(w)
+		if err != nil {
+			t.close()
+			return fmt.Errorf("failed to write pcap header: %w", err)
+		}
+		pcaps = append(pcaps, p)
+	}
+
+	go t.run(pcaps)
+	packetTaps.taps[tapCfg.Iface] = t
+	log.Info().Msgf("packet tap started on iface %s", tapCfg.Iface)
+	return nil
+}
+
+// tapPcapPath - path of the pcap file of the tap in the pcap dir of l3afd.cfg, the pcap file of the API is a
+// file name so the tap never writes outside of the pcap dir
+func tapPcapPath(pcapDir, name string) (string, error) {
+	if len(pcapDir) == 0 {
+		return "", fmt.Errorf("pcap dir is not configured, pcap files are not written")
+	}
+	if filepath.IsAbs(name) || strings.Contains(name, "..") || name != filepath.Base(name) {
+		return "", fmt.Errorf("pcap file %s must be a file name in the pcap dir", name)
+	}
+	return filepath.Join(pcapDir, filepath.Base(name)), nil
+}
+
+// tapCollectorAllowed - collector is one of the tap collectors of l3afd.cfg
+func tapCollectorAllowed(collectors []string, collector string) bool {
+	for _, allowed := range collectors {
+		if strings.TrimSpace(allowed) == collector {
+			return true
+		}
+	}
+	return false
+}
+
+// StopTap - Stops packet tap on the interface
+func (c *NFConfigs) StopTap(ifaceName string) error {
+	packetTaps.mu.Lock()
+	defer packetTaps.mu.Unlock()
+
+	t, ok := packetTaps.taps[ifaceName]
+	if !ok {
+		return fmt.Errorf("no packet tap is running on iface %s", ifaceName)
+	}
+	t.stop()
+	delete(packetTaps.taps, ifaceName)
+	log.Info().Msgf("packet tap stopped on iface %s", ifaceName)
+	return nil
+}
+
+// Taps - returns configs of the running packet taps
+func (c *NFConfigs) Taps() []models.L3afDTap {
+	packetTaps.mu.Lock()
+	defer packetTaps.mu.Unlock()
+
+	taps := make([]models.L3afDTap, 0, len(packetTaps.taps))
+	for _, t := range packetTaps.taps {
+		taps = append(taps, t.config)
+	}
+	sort.Slice(taps, func(i, j int) bool { return taps[i].Iface < taps[j].Iface })
+	return taps
+}
+
+// recentSamples - latest packet samples of the tap of the interface up to the count, the latest first
+func (r *tapRegistry) recentSamples(ifaceName string, count int) [][]byte {
+	r.mu.Lock()
+	t, ok := r.taps[ifaceName]
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	samples := make([][]byte, 0, count)
+	for i := len(t.recent) - 1; i >= 0 && len(samples) < count; i-- {
+		samples = append(samples, t.recent[i])
+	}
+	return samples
+}
+
+// keep - keeps the copy of the sample in the latest samples
+func (t *packetTap) keep(sample []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.recent) == tapRecentSamples {
+		t.recent = t.recent[1:]
+	}
+	t.recent = append(t.recent, append([]byte(nil), sample...))
+}
+
+// stopAll - stops all the running packet taps
+func (r *tapRegistry) stopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ifaceName, t := range r.taps {
+		t.stop()
+		delete(r.taps, ifaceName)
+	}
+}
+
+// run - reads the packet samples until the tap is stopped
+func (t *packetTap) run(pcaps []*pcapWriter) {
+	defer close(t.done)
+
+	var count int
+	for {
+		record, err := t.reader.Read()
+		if err != nil {
+			if perf.IsClosed(err) {
+				return
+			}
+			log.Warn().Err(err).Msgf("packet tap failed to read on iface %s", t.config.Iface)
+			continue
+		}
+		if record.LostSamples > 0 {
+			log.Debug().Msgf("packet tap lost %d samples on iface %s", record.LostSamples, t.config.Iface)
+			continue
+		}
+
+		count++
+		if !t.sampled(count, record.RawSample) {
+			continue
+		}
+
+		t.keep(record.RawSample)
+		ts := time.Now()
+		for i := 0; i < len(pcaps); i++ {
+			if err := pcaps[i].writePacket(ts, record.RawSample); err != nil {
+				log.Error().Err(err).Msgf("packet tap failed to write on iface %s, removing the writer", t.config.Iface)
+				pcaps = append(pcaps[:i], pcaps[i+1:]...)
+				i--
+			}
+		}
+	}
+}
+
+// sampled - packet is selected by the sample rate and the filter
+func (t *packetTap) sampled(count int, packet []byte) bool {
+	if t.config.SampleRate > 1 && count%t.config.SampleRate != 0 {
+		return false
+	}
+	if t.filter == nil {
+		return true
+	}
+	n, err := t.filter.Run(packet)
+	return err == nil && n > 0
+}
+
+// stop - stops reading the samples and closes the outputs
+func (t *packetTap) stop() {
+	if t.reader != nil {
+		t.reader.Close()
+		<-t.done
+		t.reader = nil
+	}
+	t.close()
+}
+
+func (t *packetTap) close() {
+	if t.reader != nil {
+		t.reader.Close()
+	}
+	for _, w := range t.writers {
+		if err := w.Close(); err != nil {
+			log.Warn().Err(err).Msgf("packet tap failed to close output on iface %s", t.config.Iface)
+		}
+	}
+	t.writers = nil
+	if t.ebpfMap != nil {
+		t.ebpfMap.Close()
+		t.ebpfMap = nil
+	}
+}
+
+// parseTapFilter - parses classic BPF filter in the tcpdump -ddd format with lines joined by commas
+// e.g. "4,40 0 0 12,21 0 1 2048,6 0 0 262144,6 0 0 0"
+func parseTapFilter(filter string) ([]bpf.Instruction, error) {
+	fields := strings.Split(strings.TrimSpace(filter), ",")
+	count, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid instruction count %q", fields[0])
+	}
+	if count != len(fields)-1 {
+		return nil, fmt.Errorf("instruction count %d does not match %d instructions", count, len(fields)-1)
+	}
+
+	raw := make([]bpf.RawInstruction, 0, count)
+	for _, field := range fields[1:] {
+		var op uint16
+		var jt, jf uint8
+		var k uint32
+		if n, err := fmt.Sscanf(strings.TrimSpace(field), "%d %d %d %d", &op, &jt, &jf, &k); err != nil || n != 4 {
+			return nil, fmt.Errorf("invalid instruction %q", field)
+		}
+		raw = append(raw, bpf.RawInstruction{Op: op, Jt: jt, Jf: jf, K: k})
+	}
+
+	insts, ok := bpf.Disassemble(raw)
+	if !ok {
+		return nil, fmt.Errorf("filter contains unknown instructions")
+	}
+	return insts, nil
+}
@@ -0,0 +1,138 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"golang.org/x/net/bpf"
+)
+
+func Test_parseTapFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    int
+		wantErr bool
+	}{
+		{name: "IPv4Only", filter: "4,40 0 0 12,21 0 1 2048,6 0 0 262144,6 0 0 0", want: 4, wantErr: false},
+		{name: "CountMismatch", filter: "3,40 0 0 12,6 0 0 0", wantErr: true},
+		{name: "InvalidCount", filter: "four,6 0 0 0", wantErr: true},
+		{name: "InvalidInstruction", filter: "1,6 0 0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTapFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTapFilter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("parseTapFilter() = %d instructions, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func Test_packetTap_sampled(t *testing.T) {
+	insts, err := parseTapFilter("4,40 0 0 12,21 0 1 2048,6 0 0 262144,6 0 0 0")
+	if err != nil {
+		t.Fatalf("parseTapFilter() error = %v", err)
+	}
+	ipv4Filter, err := bpf.NewVM(insts)
+	if err != nil {
+		t.Fatalf("bpf.NewVM() error = %v", err)
+	}
+	ipv4 := selfTestPacket()
+	arp := append([]byte{}, ipv4...)
+	binary.BigEndian.PutUint16(arp[12:14], 0x0806)
+
+	tests := []struct {
+		name       string
+		sampleRate int
+		filter     *bpf.VM
+		count      int
+		packet     []byte
+		want       bool
+	}{
+		{name: "AllPackets", sampleRate: 0, count: 1, packet: arp, want: true},
+		{name: "NotSampled", sampleRate: 10, count: 9, packet: ipv4, want: false},
+		{name: "Sampled", sampleRate: 10, count: 20, packet: ipv4, want: true},
+		{name: "FilterMatch", sampleRate: 1, filter: ipv4Filter, count: 1, packet: ipv4, want: true},
+		{name: "FilterMismatch", sampleRate: 1, filter: ipv4Filter, count: 1, packet: arp, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tap := &packetTap{config: models.L3afDTap{SampleRate: tt.sampleRate}, filter: tt.filter}
+			if got := tap.sampled(tt.count, tt.packet); got != tt.want {
+				t.Errorf("packetTap.sampled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pcapWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p, err := newPcapWriter(buf)
+	if err != nil {
+		t.Fatalf("newPcapWriter() error = %v", err)
+	}
+	packet := selfTestPacket()
+	if err := p.writePacket(time.Unix(1650000000, 5000), packet); err != nil {
+		t.Fatalf("pcapWriter.writePacket() error = %v", err)
+	}
+
+	out := buf.Bytes()
+	if len(out) != 24+16+len(packet) {
+		t.Fatalf("pcap length = %d, want %d", len(out), 24+16+len(packet))
+	}
+	if magic := binary.LittleEndian.Uint32(out[0:4]); magic != pcapMagic {
+		t.Errorf("pcap magic = %#x, want %#x", magic, pcapMagic)
+	}
+	if usec := binary.LittleEndian.Uint32(out[28:32]); usec != 5 {
+		t.Errorf("pcap record usec = %d, want 5", usec)
+	}
+	if capLen := binary.LittleEndian.Uint32(out[32:36]); int(capLen) != len(packet) {
+		t.Errorf("pcap record length = %d, want %d", capLen, len(packet))
+	}
+}
+
+func Test_tapPcapPath(t *testing.T) {
+	if got, err := tapPcapPath("/var/log/l3afd/tap", "enp0s3.pcap"); err != nil || got != "/var/log/l3afd/tap/enp0s3.pcap" {
+		t.Errorf("tapPcapPath() = %s, %v, want the file in the pcap dir", got, err)
+	}
+	for _, name := range []string{"/etc/shadow", "../shadow", "tap/../../shadow", "sub/enp0s3.pcap", ".."} {
+		if _, err := tapPcapPath("/var/log/l3afd/tap", name); err == nil {
+			t.Errorf("tapPcapPath(%q) error = nil, want the path rejected", name)
+		}
+	}
+	if _, err := tapPcapPath("", "enp0s3.pcap"); err == nil {
+		t.Errorf("tapPcapPath() error = nil, want the pcap file rejected without the pcap dir")
+	}
+}
+
+func TestNFConfigs_StartTap_rejected(t *testing.T) {
+	c := &NFConfigs{
+		hostInterfaces: map[string]bool{"enp0s3": true},
+		hostConfig:     &config.Config{TapMapDir: "/sys/fs/bpf/tap", TapCollectors: []string{"10.10.10.10:9000"}},
+	}
+	for _, tapCfg := range []models.L3afDTap{
+		{Iface: "../../etc", PcapFile: "enp0s3.pcap"},
+		{Iface: "eth9", PcapFile: "enp0s3.pcap"},
+		{Iface: "enp0s3", Collector: "192.0.2.1:9000"},
+	} {
+		if err := c.StartTap(tapCfg); err == nil {
+			t.Errorf("StartTap(%+v) error = nil, want the tap rejected", tapCfg)
+		}
+	}
+	if !tapCollectorAllowed(c.hostConfig.TapCollectors, "10.10.10.10:9000") {
+		t.Errorf("tapCollectorAllowed() = false, want the collector of l3afd.cfg allowed")
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// taskTick - period the scheduled tasks due are started at
+const taskTick = time.Second
+
+// taskJitter - random delay up to the jitter, replaced by the tests
+var taskJitter = func(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(jitter)))
+}
+
+// nfTask - schedule and last run of a scheduled task of the program. The run fields are written by the run
+// completing outside of the configs lock.
+type nfTask struct {
+	config  models.L3afDNFTask
+	nextRun time.Time
+
+	mu         sync.Mutex
+	running    bool
+	cancel     context.CancelFunc // Kills the running command
+	lastRun    time.Time
+	lastResult string
+}
+
+// validateTask - name and command of the artifact are set and the durations are positive, the jitter shorter
+// than the interval
+func validateTask(task *models.L3afDNFTask) error {
+	if len(task.Name) == 0 {
+		return fmt.Errorf("task name is not set")
+	}
+	if len(task.Command) == 0 {
+		return fmt.Errorf("task %s command is not set", task.Name)
+	}
+	if filepath.IsAbs(task.Command) || strings.HasPrefix(filepath.Clean(task.Command), "..") {
+		return fmt.Errorf("task %s command %s is not a command of the artifact", task.Name, task.Command)
+	}
+	interval, err := time.ParseDuration(task.Interval)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("task %s interval %q is not a positive duration", task.Name, task.Interval)
+	}
+	if len(task.Jitter) > 0 {
+		jitter, err := time.ParseDuration(task.Jitter)
+		if err != nil || jitter < 0 {
+			return fmt.Errorf("task %s jitter %q is not a duration", task.Name, task.Jitter)
+		}
+		if jitter >= interval {
+			return fmt.Errorf("task %s jitter %s must be shorter than the interval %s", task.Name, jitter, interval)
+		}
+	}
+	if len(task.Timeout) > 0 {
+		if timeout, err := time.ParseDuration(task.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("task %s timeout %q is not a positive duration", task.Name, task.Timeout)
+		}
+	}
+	return nil
+}
+
+// ValidateTasks - Verifies the scheduled tasks of the programs, the task names are unique in a program
+func ValidateTasks(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			names := make(map[string]bool, len(ref.prog.Tasks))
+			for i := range ref.prog.Tasks {
+				task := &ref.prog.Tasks[i]
+				if err := validateTask(task); err != nil {
+					return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+				}
+				if names[task.Name] {
+					return fmt.Errorf("program %s on iface %s: task %s is repeated", ref.prog.Name, cfg.Iface, task.Name)
+				}
+				names[task.Name] = true
+			}
+		}
+	}
+	return nil
+}
+
+// taskDelay - delay of the next run of the task, the interval and a random delay up to the jitter
+func taskDelay(task *models.L3afDNFTask) time.Duration {
+	interval, _ := time.ParseDuration(task.Interval)
+	jitter, _ := time.ParseDuration(task.Jitter)
+	return interval + taskJitter(jitter)
+}
+
+// taskTimeout - timeout of a run of the task
+func taskTimeout(task *models.L3afDNFTask) time.Duration {
+	if d, err := time.ParseDuration(task.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return nfCmdConfig.taskTimeout
+}
+
+// taskCommand - command of the task run in the artifact directory of the program, with the --iface and
+// --direction flags followed by the args of the task
+func (b *BPF) taskCommand(task *models.L3afDNFTask, ifaceName, direction string) ([]string, error) {
+	cmd := filepath.Join(b.FilePath, task.Command)
+	if err := assertExecutable(cmd); err != nil {
+		return nil, fmt.Errorf("no executable permissions on %s - error %w", task.Command, err)
+	}
+	keys := make([]string, 0, len(task.Args))
+	for k := range task.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := []string{cmd, "--iface=" + ifaceName, "--direction=" + direction}
+	for _, k := range keys {
+		args = append(args, "--"+k+"="+task.Args[k])
+	}
+	return args, nil
+}
+
+// run - runs the task of the program to completion and records its result, a run outliving its timeout is killed
+func (t *nfTask) run(ctx context.Context, argv []string, progName, ifaceName, direction string) {
+	task := t.config
+	started := time.Now()
+	result := models.TaskResultSuccess
+	cmd, err := newNFCommand(argv[0], argv[1:]...)
+	var out string
+	if err == nil {
+		out, err = runNFCommandContext(ctx, cmd, taskTimeout(&task))
+	}
+	elapsed := time.Since(started)
+	if err != nil {
+		result = models.TaskResultFailure
+		if timeout := taskTimeout(&task); timeout > 0 && elapsed >= timeout && !errors.Is(err, context.Canceled) {
+			result = models.TaskResultTimeout
+		}
+		log.Warn().Err(err).Msgf("task %s of program %s iface %s direction %s failed: %s", task.Name, progName, ifaceName, direction, out)
+	} else {
+		log.Debug().Msgf("task %s of program %s iface %s direction %s completed in %s", task.Name, progName, ifaceName, direction, elapsed)
+	}
+	stats.Add(1, stats.NFTaskRuns, progName, direction, task.Name, result)
+	stats.SetGauge(elapsed.Seconds(), stats.NFTaskDuration, progName, direction, task.Name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running = false
+	t.cancel = nil
+	t.lastResult = result
+}
+
+// startTask - starts the run of the task due, the run of a task still running is skipped
+func (b *BPF) startTask(ctx context.Context, t *nfTask, ifaceName, direction string, now time.Time) {
+	t.nextRun = now.Add(taskDelay(&t.config))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running {
+		log.Warn().Msgf("task %s of program %s iface %s direction %s is still running, run skipped", t.config.Name, b.Program.Name, ifaceName, direction)
+		t.lastResult = models.TaskResultSkipped
+		stats.Add(1, stats.NFTaskRuns, b.Program.Name, direction, t.config.Name, models.TaskResultSkipped)
+		return
+	}
+	argv, err := b.taskCommand(&t.config, ifaceName, direction)
+	if err != nil {
+		log.Warn().Err(err).Msgf("task %s of program %s iface %s direction %s is not run", t.config.Name, b.Program.Name, ifaceName, direction)
+		t.lastRun = now
+		t.lastResult = models.TaskResultFailure
+		stats.Add(1, stats.NFTaskRuns, b.Program.Name, direction, t.config.Name, models.TaskResultFailure)
+		return
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	t.running = true
+	t.cancel = cancel
+	t.lastRun = now
+	go func(progName string) {
+		defer cancel()
+		t.run(runCtx, argv, progName, ifaceName, direction)
+	}(b.Program.Name)
+}
+
+// stop - kills the running command of the task
+func (t *nfTask) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
+// stopTasks - stops the scheduled tasks of the program, the running commands are killed
+func (b *BPF) stopTasks() {
+	for name, t := range b.tasks {
+		t.stop()
+		delete(b.tasks, name)
+	}
+}
+
+// syncTasks - follows the tasks of the config of the running program and starts the runs due. Tasks of a
+// changed config are rescheduled, the first run of a task is an interval after it is scheduled.
+func (c *NFConfigs) syncTasks(b *BPF, ifaceName, direction string, now time.Time) {
+	if !loadedStatus(b.Program.AdminStatus) || len(b.FilePath) == 0 || (b.Program.UserProgramDaemon && b.Cmd == nil) {
+		b.stopTasks()
+		return
+	}
+	configured := make(map[string]bool, len(b.Program.Tasks))
+	for _, task := range b.Program.Tasks {
+		configured[task.Name] = true
+		t, ok := b.tasks[task.Name]
+		if ok && reflect.DeepEqual(t.config, task) {
+			continue
+		}
+		if ok {
+			t.stop()
+		}
+		if b.tasks == nil {
+			b.tasks = make(map[string]*nfTask)
+		}
+		b.tasks[task.Name] = &nfTask{config: task, nextRun: now.Add(taskDelay(&task))}
+	}
+	for name, t := range b.tasks {
+		if !configured[name] {
+			t.stop()
+			delete(b.tasks, name)
+		}
+	}
+	for _, t := range b.tasks {
+		if !now.Before(t.nextRun) {
+			b.startTask(c.ctx, t, ifaceName, direction, now)
+		}
+	}
+}
+
+// ScheduledTasks - starts the scheduled tasks of the programs due
+func (c *NFConfigs) ScheduledTasks() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, direction := range []string{models.XDPIngressType, models.IngressType, models.EgressType} {
+		bpfs, _ := c.bpfLists(direction)
+		for ifaceName, bpfList := range bpfs {
+			if bpfList == nil {
+				continue
+			}
+			for e := bpfList.Front(); e != nil; e = e.Next() {
+				c.syncTasks(e.Value.(*BPF), ifaceName, direction, now)
+			}
+		}
+	}
+}
+
+// taskLoop - periodic start of the scheduled tasks due
+func (c *NFConfigs) taskLoop() {
+	ticker := time.NewTicker(taskTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.ScheduledTasks()
+		}
+	}
+}
+
+// taskStatus - scheduled tasks of the program by name
+func (b *BPF) taskStatus() []models.L3afDTaskStatus {
+	if len(b.tasks) == 0 {
+		return nil
+	}
+	status := make([]models.L3afDTaskStatus, 0, len(b.tasks))
+	for name, t := range b.tasks {
+		t.mu.Lock()
+		s := models.L3afDTaskStatus{
+			Name:       name,
+			Running:    t.running,
+			LastResult: t.lastResult,
+			NextRun:    t.nextRun.UTC().Format(time.RFC3339),
+		}
+		if !t.lastRun.IsZero() {
+			s.LastRun = t.lastRun.UTC().Format(time.RFC3339)
+		}
+		t.mu.Unlock()
+		status = append(status, s)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status
+}
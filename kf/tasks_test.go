@@ -0,0 +1,145 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_validateTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    models.L3afDNFTask
+		wantErr bool
+	}{
+		{"valid", models.L3afDNFTask{Name: "rotate", Command: "rotate_state", Interval: "1h", Jitter: "5m", Timeout: "30s"}, false},
+		{"command of a subdir", models.L3afDNFTask{Name: "resolve", Command: "bin/resolve", Interval: "10m"}, false},
+		{"no name", models.L3afDNFTask{Command: "rotate_state", Interval: "1h"}, true},
+		{"no command", models.L3afDNFTask{Name: "rotate", Interval: "1h"}, true},
+		{"absolute command", models.L3afDNFTask{Name: "rotate", Command: "/usr/bin/rotate", Interval: "1h"}, true},
+		{"command out of the artifact", models.L3afDNFTask{Name: "rotate", Command: "../rotate", Interval: "1h"}, true},
+		{"no interval", models.L3afDNFTask{Name: "rotate", Command: "rotate_state"}, true},
+		{"jitter of the interval", models.L3afDNFTask{Name: "rotate", Command: "rotate_state", Interval: "1h", Jitter: "1h"}, true},
+		{"negative timeout", models.L3afDNFTask{Name: "rotate", Command: "rotate_state", Interval: "1h", Timeout: "-1s"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTask(&tt.task); (err != nil) != tt.wantErr {
+				t.Errorf("validateTask() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	progs := []models.L3afBPFPrograms{{
+		Iface: "eth0",
+		BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tasks: []models.L3afDNFTask{
+			{Name: "rotate", Command: "rotate_state", Interval: "1h"},
+			{Name: "rotate", Command: "rotate_state", Interval: "2h"},
+		}}}},
+	}}
+	if err := ValidateTasks(progs); err == nil {
+		t.Errorf("ValidateTasks() error = nil, want the repeated task rejected")
+	}
+}
+
+func Test_taskCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "rotate_state"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	b := &BPF{FilePath: dir}
+	task := models.L3afDNFTask{Name: "rotate", Command: "rotate_state", Args: map[string]string{"keep": "3", "dir": "/var/lib/rl"}}
+	got, err := b.taskCommand(&task, "eth0", models.XDPIngressType)
+	if err != nil {
+		t.Fatalf("taskCommand() error = %v", err)
+	}
+	want := []string{filepath.Join(dir, "rotate_state"), "--iface=eth0", "--direction=xdpingress", "--dir=/var/lib/rl", "--keep=3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("taskCommand() = %v, want %v", got, want)
+	}
+	task.Command = "missing"
+	if _, err := b.taskCommand(&task, "eth0", models.XDPIngressType); err == nil {
+		t.Errorf("taskCommand() error = nil, want the missing command rejected")
+	}
+}
+
+// waitTask - waits for the run of the task to complete
+func waitTask(t *testing.T, task *nfTask) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		task.mu.Lock()
+		running := task.running
+		task.mu.Unlock()
+		if !running {
+			return
+		}
+	}
+	t.Fatalf("task %s did not complete", task.config.Name)
+}
+
+func TestNFConfigs_syncTasks(t *testing.T) {
+	savedJitter := taskJitter
+	t.Cleanup(func() { taskJitter = savedJitter })
+	taskJitter = func(time.Duration) time.Duration { return 0 }
+
+	dir := t.TempDir()
+	for name, script := range map[string]string{"rotate_state": "#!/bin/sh\nexit 0\n", "resolve": "#!/bin/sh\nsleep 5\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &NFConfigs{ctx: context.Background()}
+	b := &BPF{FilePath: dir, Program: models.BPFProgram{
+		Name:        "ratelimiting",
+		AdminStatus: models.Enabled,
+		Tasks: []models.L3afDNFTask{
+			{Name: "rotate", Command: "rotate_state", Interval: "1m"},
+			{Name: "resolve", Command: "resolve", Interval: "1m", Timeout: "10s"},
+			{Name: "expire", Command: "resolve", Interval: "1m", Timeout: "200ms"},
+		},
+	}}
+
+	now := time.Now()
+	c.syncTasks(b, "eth0", models.XDPIngressType, now)
+	if len(b.tasks) != 3 || b.tasks["rotate"].running || !b.tasks["rotate"].nextRun.Equal(now.Add(time.Minute)) {
+		t.Fatalf("syncTasks() tasks = %v, want the tasks scheduled an interval later", b.tasks)
+	}
+
+	c.syncTasks(b, "eth0", models.XDPIngressType, now.Add(time.Minute))
+	waitTask(t, b.tasks["rotate"])
+	waitTask(t, b.tasks["expire"])
+	status := b.taskStatus()
+	if len(status) != 3 || status[0].Name != "expire" || status[0].LastResult != models.TaskResultTimeout ||
+		!status[1].Running || status[2].LastResult != models.TaskResultSuccess || len(status[2].LastRun) == 0 {
+		t.Errorf("taskStatus() = %+v, want expire timed out, resolve running and rotate succeeded", status)
+	}
+
+	// run still running at its next run is skipped, the removed task is killed
+	resolve := b.tasks["resolve"]
+	c.syncTasks(b, "eth0", models.XDPIngressType, now.Add(2*time.Minute))
+	waitTask(t, b.tasks["rotate"])
+	waitTask(t, b.tasks["expire"])
+	if b.taskStatus()[1].LastResult != models.TaskResultSkipped {
+		t.Errorf("syncTasks() resolve status = %+v, want the run skipped", b.taskStatus()[1])
+	}
+	b.Program.Tasks = b.Program.Tasks[:1]
+	c.syncTasks(b, "eth0", models.XDPIngressType, now.Add(2*time.Minute))
+	waitTask(t, resolve)
+	if _, ok := b.tasks["resolve"]; ok || resolve.lastResult != models.TaskResultFailure {
+		t.Errorf("syncTasks() resolve result = %s, want the removed task killed", resolve.lastResult)
+	}
+
+	b.Program.AdminStatus = models.Disabled
+	c.syncTasks(b, "eth0", models.XDPIngressType, now.Add(5*time.Minute))
+	if len(b.tasks) != 0 {
+		t.Errorf("syncTasks() tasks = %v, want the tasks of the disabled program stopped", b.tasks)
+	}
+}
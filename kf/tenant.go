@@ -0,0 +1,165 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// tenantUsage - resources requested by a tenant in the config
+type tenantUsage struct {
+	programs int
+}
+
+// ValidateTenants - Verifies the programs in the config are not claimed by other tenants
+// and per tenant program and cpu quotas are not exceeded. This is checked before applying any change,
+// the map memory quota is verified by ValidateTenantMapMemory once the eBPF objects of the programs are inspected.
+func (c *NFConfigs) ValidateTenants(bpfProgs []models.L3afBPFPrograms) error {
+	usage := make(map[string]*tenantUsage)
+
+	for _, cfg := range bpfProgs {
+		if cfg.BpfPrograms == nil {
+			continue
+		}
+		directions := map[string][]*models.BPFProgram{
+			models.XDPIngressType: cfg.BpfPrograms.XDPIngress,
+			models.IngressType:    cfg.BpfPrograms.TCIngress,
+			models.EgressType:     cfg.BpfPrograms.TCEgress,
+		}
+		for direction, progs := range directions {
+			for _, prog := range progs {
+				if prog == nil {
+					continue
+				}
+				if err := c.verifyTenantOwnership(prog, cfg.Iface, direction); err != nil {
+					return err
+				}
+				if !loadedStatus(prog.AdminStatus) {
+					continue
+				}
+				if err := verifyTenantCPU(c.hostConfig.TenantQuotas, prog); err != nil {
+					return err
+				}
+				u, ok := usage[prog.Tenant]
+				if !ok {
+					u = &tenantUsage{}
+					usage[prog.Tenant] = u
+				}
+				u.programs++
+			}
+		}
+	}
+
+	for tenant, u := range usage {
+		quota, ok := c.hostConfig.TenantQuotas[tenant]
+		if !ok {
+			continue
+		}
+		if quota.MaxPrograms > 0 && u.programs > quota.MaxPrograms {
+			return fmt.Errorf("tenant %s quota exceeded: %d programs requested, max allowed %d", tenant, u.programs, quota.MaxPrograms)
+		}
+	}
+
+	return nil
+}
+
+// ValidateTenantMapMemory - Verifies the declared map memory of the programs of each tenant in the config is
+// within the tenant quota, before any program is started. The artifacts of the programs of the tenants with a map
+// memory quota not running in the version of the config are downloaded and inspected.
+func (c *NFConfigs) ValidateTenantMapMemory(bpfProgs []models.L3afBPFPrograms) error {
+	limited := false
+	for _, quota := range c.hostConfig.TenantQuotas {
+		limited = limited || quota.MaxMapMemory > 0
+	}
+	if !limited {
+		return nil
+	}
+
+	usage := make(map[string]uint64)
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil || !loadedStatus(ref.prog.AdminStatus) || c.hostConfig.TenantQuotas[ref.prog.Tenant].MaxMapMemory <= 0 {
+				continue
+			}
+			mapMemory, err := c.programMapMemory(ref.prog, cfg.Iface, ref.direction)
+			if err != nil {
+				return fmt.Errorf("program %s version %s iface %s: %w", ref.prog.Name, ref.prog.Version, cfg.Iface, err)
+			}
+			usage[ref.prog.Tenant] += mapMemory
+		}
+	}
+
+	for tenant, memory := range usage {
+		if err := verifyTenantMapMemory(c.hostConfig.TenantQuotas, tenant, memory); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// programMapMemory - declared map memory of the program of the config, of the running program of the same version
+// or of the eBPF objects of its artifact
+func (c *NFConfigs) programMapMemory(prog *models.BPFProgram, ifaceName, direction string) (uint64, error) {
+	if bpf, err := c.findBPF(ifaceName, direction, prog.Name); err == nil && bpf.Program.Version == prog.Version && bpf.MapMemory > 0 {
+		return bpf.MapMemory, nil
+	}
+	return c.prepareBPFProgram(prog)
+}
+
+// verifyTenantOwnership - program running on the interface can't be claimed by another tenant
+func (c *NFConfigs) verifyTenantOwnership(prog *models.BPFProgram, ifaceName, direction string) error {
+	var bpfList *list.List
+	switch direction {
+	case models.XDPIngressType:
+		bpfList = c.IngressXDPBpfs[ifaceName]
+	case models.IngressType:
+		bpfList = c.IngressTCBpfs[ifaceName]
+	case models.EgressType:
+		bpfList = c.EgressTCBpfs[ifaceName]
+	}
+	if bpfList == nil {
+		return nil
+	}
+
+	for e := bpfList.Front(); e != nil; e = e.Next() {
+		data := e.Value.(*BPF)
+		if data.Program.Name != prog.Name {
+			continue
+		}
+		if data.Program.Tenant != prog.Tenant {
+			log.Warn().Msgf("program %s iface %s direction %s is owned by tenant %q", prog.Name, ifaceName, direction, data.Program.Tenant)
+			return fmt.Errorf("program %s on iface %s direction %s is owned by tenant %q, can not be claimed by tenant %q", prog.Name, ifaceName, direction, data.Program.Tenant, prog.Tenant)
+		}
+		return nil
+	}
+	return nil
+}
+
+// verifyTenantCPU - cpu limit of a program should be within the tenant's quota
+func verifyTenantCPU(quotas map[string]config.TenantQuota, prog *models.BPFProgram) error {
+	quota, ok := quotas[prog.Tenant]
+	if !ok || quota.MaxCPU == 0 {
+		return nil
+	}
+	// no cpu limit on the program is same as unlimited
+	if prog.CPU == 0 || prog.CPU > quota.MaxCPU {
+		return fmt.Errorf("tenant %s quota exceeded: program %s cpu limit %d, max allowed %d", prog.Tenant, prog.Name, prog.CPU, quota.MaxCPU)
+	}
+	return nil
+}
+
+// verifyTenantMapMemory - declared map memory of the programs of a tenant should be within the tenant's quota
+func verifyTenantMapMemory(quotas map[string]config.TenantQuota, tenant string, memory uint64) error {
+	quota, ok := quotas[tenant]
+	if !ok || quota.MaxMapMemory <= 0 || memory <= uint64(quota.MaxMapMemory) {
+		return nil
+	}
+	return fmt.Errorf("tenant %s quota exceeded: %d bytes of map memory declared, max allowed %d", tenant, memory, quota.MaxMapMemory)
+}
@@ -0,0 +1,119 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestNFConfigs_ValidateTenants(t *testing.T) {
+	quotas := map[string]config.TenantQuota{
+		"edge": {MaxPrograms: 1, MaxMapMemory: 1024, MaxCPU: 10},
+	}
+	runningXDP := list.New()
+	runningXDP.PushBack(&BPF{Program: models.BPFProgram{Name: "ratelimiting", Tenant: "edge"}})
+
+	tests := []struct {
+		name     string
+		bpfProgs []models.L3afBPFPrograms
+		wantErr  bool
+	}{
+		{
+			name: "WithinQuota",
+			bpfProgs: []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tenant: "edge", AdminStatus: models.Enabled, CPU: 10, Memory: 1024}},
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "MaxProgramsExceeded",
+			bpfProgs: []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tenant: "edge", AdminStatus: models.Enabled, CPU: 10}},
+				TCIngress:  []*models.BPFProgram{{Name: "connection-limit", Tenant: "edge", AdminStatus: models.Enabled, CPU: 10}},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "DisabledProgramNotCounted",
+			bpfProgs: []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tenant: "edge", AdminStatus: models.Enabled, CPU: 10}},
+				TCIngress:  []*models.BPFProgram{{Name: "connection-limit", Tenant: "edge", AdminStatus: models.Disabled}},
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "UserProgramMemoryNotCounted",
+			bpfProgs: []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tenant: "edge", AdminStatus: models.Enabled, CPU: 10, Memory: 2048}},
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "NoCPULimit",
+			bpfProgs: []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tenant: "edge", AdminStatus: models.Enabled}},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "ClaimedByOtherTenant",
+			bpfProgs: []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tenant: "core", AdminStatus: models.Enabled}},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "TenantWithoutQuota",
+			bpfProgs: []models.L3afBPFPrograms{{Iface: "eth1", BpfPrograms: &models.BPFPrograms{
+				XDPIngress: []*models.BPFProgram{{Name: "ratelimiting", Tenant: "core", AdminStatus: models.Enabled}},
+				TCIngress:  []*models.BPFProgram{{Name: "connection-limit", Tenant: "core", AdminStatus: models.Enabled}},
+			}}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &NFConfigs{
+				IngressXDPBpfs: map[string]*list.List{"dummy": runningXDP},
+				IngressTCBpfs:  make(map[string]*list.List),
+				EgressTCBpfs:   make(map[string]*list.List),
+				hostConfig:     &config.Config{TenantQuotas: quotas},
+			}
+			if err := cfg.ValidateTenants(tt.bpfProgs); (err != nil) != tt.wantErr {
+				t.Errorf("NFConfigs.ValidateTenants() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNFConfigs_ValidateTenantMapMemory(t *testing.T) {
+	runningXDP := list.New()
+	runningXDP.PushBack(&BPF{Program: models.BPFProgram{Name: "ratelimiting", Version: "1.0", Tenant: "edge"}, MapMemory: 4096})
+	runningTC := list.New()
+	runningTC.PushBack(&BPF{Program: models.BPFProgram{Name: "connection-limit", Version: "1.0", Tenant: "edge"}, MapMemory: 4096})
+	c := &NFConfigs{
+		hostConfig:     &config.Config{TenantQuotas: map[string]config.TenantQuota{"edge": {MaxMapMemory: 6000}}},
+		IngressXDPBpfs: map[string]*list.List{"dummy": runningXDP},
+		IngressTCBpfs:  map[string]*list.List{"dummy": runningTC},
+		EgressTCBpfs:   map[string]*list.List{},
+	}
+
+	rl := &models.BPFProgram{Name: "ratelimiting", Version: "1.0", Tenant: "edge", AdminStatus: models.Enabled}
+	cl := &models.BPFProgram{Name: "connection-limit", Version: "1.0", Tenant: "edge", AdminStatus: models.Enabled}
+	within := []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{XDPIngress: []*models.BPFProgram{rl}}}}
+	if err := c.ValidateTenantMapMemory(within); err != nil {
+		t.Errorf("ValidateTenantMapMemory() error = %v, want the config within the quota", err)
+	}
+	exceeded := []models.L3afBPFPrograms{{Iface: "dummy", BpfPrograms: &models.BPFPrograms{
+		XDPIngress: []*models.BPFProgram{rl},
+		TCIngress:  []*models.BPFProgram{cl},
+	}}}
+	if err := c.ValidateTenantMapMemory(exceeded); err == nil {
+		t.Errorf("ValidateTenantMapMemory() error = nil, want the tenant map memory quota exceeded")
+	}
+}
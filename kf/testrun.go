@@ -0,0 +1,128 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/rs/zerolog/log"
+)
+
+// verdicts - return codes of XDP and TC programs by name
+var verdicts = map[string]uint32{
+	"XDP_ABORTED":       0,
+	"XDP_DROP":          1,
+	"XDP_PASS":          2,
+	"XDP_TX":            3,
+	"XDP_REDIRECT":      4,
+	"TC_ACT_UNSPEC":     0xffffffff,
+	"TC_ACT_OK":         0,
+	"TC_ACT_RECLASSIFY": 1,
+	"TC_ACT_SHOT":       2,
+	"TC_ACT_PIPE":       3,
+	"TC_ACT_STOLEN":     4,
+	"TC_ACT_QUEUED":     5,
+	"TC_ACT_REPEAT":     6,
+	"TC_ACT_REDIRECT":   7,
+}
+
+// RunTestVectors - loads the eBPF programs of the artifact without pinning or attaching them,
+// runs the configured test packets via BPF_PROG_TEST_RUN and verifies the verdicts.
+func (b *BPF) RunTestVectors(specs map[string]*ebpf.CollectionSpec) error {
+	colls := make(map[string]*ebpf.Collection)
+	defer func() {
+		for _, coll := range colls {
+			coll.Close()
+		}
+	}()
+
+	for _, tv := range b.Program.TestVectors {
+		object, progName, err := findTestProgram(specs, tv.Program, b.Program.ProgType)
+		if err != nil {
+			return fmt.Errorf("test vector %s: %w", tv.Name, err)
+		}
+		want, err := parseVerdict(tv.Verdict)
+		if err != nil {
+			return fmt.Errorf("test vector %s: %w", tv.Name, err)
+		}
+		packet, err := hex.DecodeString(strings.Join(strings.Fields(tv.Packet), ""))
+		if err != nil {
+			return fmt.Errorf("test vector %s has invalid packet hex: %w", tv.Name, err)
+		}
+
+		coll, ok := colls[object]
+		if !ok {
+			// maps are never pinned to avoid interfering with the running programs
+			spec := specs[object].Copy()
+			for _, m := range spec.Maps {
+				m.Pinning = ebpf.PinNone
+			}
+			if coll, err = ebpf.NewCollection(spec); err != nil {
+				return fmt.Errorf("failed to load eBPF object %s: %w", object, err)
+			}
+			colls[object] = coll
+		}
+
+		got, _, err := coll.Programs[progName].Test(packet)
+		if err != nil {
+			return fmt.Errorf("test vector %s test run of %s failed: %w", tv.Name, progName, err)
+		}
+		if got != want {
+			return fmt.Errorf("test vector %s program %s returned verdict %d, expected %s", tv.Name, progName, got, tv.Verdict)
+		}
+		log.Info().Msgf("test vector %s of program %s passed", tv.Name, b.Program.Name)
+	}
+	return nil
+}
+
+// findTestProgram - returns the object and eBPF program to test,
+// first program of the program type is used when the program name is not configured
+func findTestProgram(specs map[string]*ebpf.CollectionSpec, progName, progType string) (string, string, error) {
+	objects := make([]string, 0, len(specs))
+	for object := range specs {
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+
+	for _, object := range objects {
+		names := make([]string, 0, len(specs[object].Programs))
+		for name := range specs[object].Programs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if len(progName) > 0 {
+				if name == progName {
+					return object, name, nil
+				}
+				continue
+			}
+			if len(progType) == 0 || hookType(specs[object].Programs[name].Type) == progType {
+				return object, name, nil
+			}
+		}
+	}
+
+	if len(progName) > 0 {
+		return "", "", fmt.Errorf("eBPF program %s is not found in the artifact", progName)
+	}
+	return "", "", fmt.Errorf("no %s eBPF program is found in the artifact", progType)
+}
+
+// parseVerdict - converts verdict name e.g. XDP_DROP or number to the program return code
+func parseVerdict(verdict string) (uint32, error) {
+	if v, ok := verdicts[strings.ToUpper(strings.TrimSpace(verdict))]; ok {
+		return v, nil
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(verdict), 0, 64)
+	if err != nil || v < -1 || v > 0xffffffff {
+		return 0, fmt.Errorf("unknown verdict %q", verdict)
+	}
+	return uint32(v), nil
+}
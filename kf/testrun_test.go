@@ -0,0 +1,72 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"testing"
+
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/cilium/ebpf"
+)
+
+func Test_parseVerdict(t *testing.T) {
+	tests := []struct {
+		name    string
+		verdict string
+		want    uint32
+		wantErr bool
+	}{
+		{name: "XDPName", verdict: "XDP_DROP", want: 1, wantErr: false},
+		{name: "LowerCaseName", verdict: "tc_act_shot", want: 2, wantErr: false},
+		{name: "Unspec", verdict: "TC_ACT_UNSPEC", want: 0xffffffff, wantErr: false},
+		{name: "Number", verdict: "3", want: 3, wantErr: false},
+		{name: "Unknown", verdict: "XDP_MAYBE", want: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVerdict(tt.verdict)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseVerdict() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseVerdict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findTestProgram(t *testing.T) {
+	specs := map[string]*ebpf.CollectionSpec{
+		"firewall_kern.o": {Programs: map[string]*ebpf.ProgramSpec{
+			"xdp_firewall": {Type: ebpf.XDP},
+			"tc_firewall":  {Type: ebpf.SchedCLS},
+		}},
+	}
+	tests := []struct {
+		name     string
+		progName string
+		progType string
+		want     string
+		wantErr  bool
+	}{
+		{name: "ByName", progName: "tc_firewall", progType: models.XDPType, want: "tc_firewall", wantErr: false},
+		{name: "ByType", progName: "", progType: models.XDPType, want: "xdp_firewall", wantErr: false},
+		{name: "AbsentName", progName: "xdp_ratelimiting", progType: models.XDPType, wantErr: true},
+		{name: "AbsentType", progName: "", progType: "kprobe", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := findTestProgram(specs, tt.progName, tt.progType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("findTestProgram() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("findTestProgram() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// traceParentRegexp - W3C traceparent header i.e. <version>-<trace id>-<parent id>-<flags>
+var traceParentRegexp = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}`)
+
+// TraceIDFromParent - trace id of the W3C traceparent header set by the tracing of the caller, empty when the
+// header is not valid
+func TraceIDFromParent(header string) string {
+	m := traceParentRegexp.FindStringSubmatch(strings.TrimSpace(header))
+	if m == nil || m[1] == "ff" || m[2] == strings.Repeat("0", 32) {
+		return ""
+	}
+	// version 00 has no fields after the flags
+	if m[1] == "00" && len(strings.TrimSpace(header)) != len(m[0]) {
+		return ""
+	}
+	return m[2]
+}
+
+// NewTraceID - random trace id of the config applies and the restarts not traced by the caller
+func NewTraceID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%032x", timeNow().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,45 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTraceIDFromParent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"future version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"empty", "", ""},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ""},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", ""},
+		{"trailing fields of version 00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TraceIDFromParent(tt.header); got != tt.want {
+				t.Errorf("TraceIDFromParent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTraceID(t *testing.T) {
+	id := NewTraceID()
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(id) {
+		t.Errorf("NewTraceID() = %q, want 32 hex digits", id)
+	}
+	if other := NewTraceID(); other == id {
+		t.Errorf("NewTraceID() = %q twice", id)
+	}
+	if TraceIDFromParent("00-"+id+"-00f067aa0ba902b7-01") != id {
+		t.Errorf("trace id %q is not a valid traceparent trace id", id)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"container/list"
+	"fmt"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+	"github.com/l3af-project/l3afd/stats"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Thresholds of the watchdog, reason label of the watchdog trips
+const (
+	watchdogRSS      = "rss"
+	watchdogCPU      = "cpu"
+	watchdogRestarts = "restarts"
+)
+
+// defaultRestartWindow - window of the max restarts of the watchdog
+const defaultRestartWindow = time.Hour
+
+// usageSample - cpu time of the user process at the time of the usage check
+type usageSample struct {
+	pid        int
+	cpuSeconds float64
+	at         time.Time
+}
+
+// validateWatchdog - thresholds are not negative, the restart window is a duration and the action is known
+func validateWatchdog(w *models.L3afDNFWatchdog) error {
+	if w == nil {
+		return nil
+	}
+	if w.MaxRSS < 0 || w.MaxCPUPercent < 0 || w.MaxRestarts < 0 {
+		return fmt.Errorf("watchdog thresholds must not be negative")
+	}
+	if len(w.RestartWindow) > 0 {
+		if d, err := time.ParseDuration(w.RestartWindow); err != nil || d <= 0 {
+			return fmt.Errorf("watchdog restart window %q is not a positive duration", w.RestartWindow)
+		}
+	}
+	switch w.Action {
+	case "", models.WatchdogActionLog, models.WatchdogActionRestart, models.WatchdogActionBypass, models.WatchdogActionAlert:
+	default:
+		return fmt.Errorf("unknown watchdog action %q, expected %s, %s, %s or %s", w.Action,
+			models.WatchdogActionLog, models.WatchdogActionRestart, models.WatchdogActionBypass, models.WatchdogActionAlert)
+	}
+	return nil
+}
+
+// ValidateWatchdogs - Verifies the watchdog thresholds and actions of the programs
+func ValidateWatchdogs(bpfProgs []models.L3afBPFPrograms) error {
+	for _, cfg := range bpfProgs {
+		for _, ref := range configProgramRefs(cfg.BpfPrograms) {
+			if ref.prog == nil {
+				continue
+			}
+			if err := validateWatchdog(ref.prog.Watchdog); err != nil {
+				return fmt.Errorf("program %s on iface %s: %w", ref.prog.Name, cfg.Iface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// restartWindow - window of the max restarts of the watchdog
+func restartWindow(w *models.L3afDNFWatchdog) time.Duration {
+	if d, err := time.ParseDuration(w.RestartWindow); err == nil && d > 0 {
+		return d
+	}
+	return defaultRestartWindow
+}
+
+// recordRestart - records the restart by the process monitoring for the restart rate of the watchdog, the
+// restarts out of the window are dropped
+func (b *BPF) recordRestart(now time.Time) {
+	w := b.Program.Watchdog
+	if w == nil || w.MaxRestarts == 0 {
+		b.restarts = nil
+		return
+	}
+	window := restartWindow(w)
+	kept := b.restarts[:0]
+	for _, t := range b.restarts {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	b.restarts = append(kept, now)
+}
+
+// watchdogExceeded - threshold of the watchdog exceeded by the user process and the description, empty when
+// the usage is under the thresholds. CPU usage is measured since the previous check of the same process.
+func (b *BPF) watchdogExceeded(usage *processUsage, pid int, now time.Time) (string, string) {
+	w := b.Program.Watchdog
+	prev := b.usageSample
+	b.usageSample = usageSample{pid: pid, cpuSeconds: usage.CPUSeconds, at: now}
+
+	if w.MaxRSS > 0 && usage.RSSBytes > uint64(w.MaxRSS) {
+		return watchdogRSS, fmt.Sprintf("resident memory %d bytes exceeds %d bytes", usage.RSSBytes, w.MaxRSS)
+	}
+	if w.MaxCPUPercent > 0 && prev.pid == pid && now.After(prev.at) {
+		percent := (usage.CPUSeconds - prev.cpuSeconds) / now.Sub(prev.at).Seconds() * 100
+		if percent > w.MaxCPUPercent {
+			return watchdogCPU, fmt.Sprintf("cpu usage %.1f%% exceeds %.1f%%", percent, w.MaxCPUPercent)
+		}
+	}
+	if w.MaxRestarts > 0 {
+		window := restartWindow(w)
+		restarts := 0
+		for _, t := range b.restarts {
+			if now.Sub(t) < window {
+				restarts++
+			}
+		}
+		if restarts > w.MaxRestarts {
+			return watchdogRestarts, fmt.Sprintf("%d restarts in %s exceed %d", restarts, window, w.MaxRestarts)
+		}
+	}
+	return "", ""
+}
+
+// checkWatchdog - takes the action of the watchdog when the usage of the user process exceeds a threshold.
+// The action is taken once per trip, i.e. again only after the usage went back under the thresholds or
+// another threshold is exceeded. Returns the exceeded threshold.
+func (b *BPF) checkWatchdog(e *list.Element, ifaceName, direction string, chain bool, usage *processUsage, now time.Time) string {
+	if b.Program.Watchdog == nil || b.Degraded || b.Cmd == nil || b.Cmd.Process == nil {
+		return ""
+	}
+	reason, message := b.watchdogExceeded(usage, b.Cmd.Process.Pid, now)
+	if len(reason) == 0 || reason == b.watchdogTrip {
+		b.watchdogTrip = reason
+		return reason
+	}
+	b.watchdogTrip = reason
+	stats.Add(1, stats.NFWatchdogTrips, b.Program.Name, direction, reason)
+
+	action := b.Program.Watchdog.Action
+	if len(action) == 0 {
+		action = models.WatchdogActionLog
+	}
+	log.Warn().Msgf("watchdog of program %s iface %s direction %s: %s, action %s", b.Program.Name, ifaceName, direction, message, action)
+
+	switch action {
+	case models.WatchdogActionRestart:
+		// restarting does not bring the restart rate down
+		if reason == watchdogRestarts {
+			break
+		}
+		// process monitoring restarts the terminated program
+		if err := b.ProcessTerminate(); err != nil {
+			log.Error().Err(err).Msgf("watchdog failed to terminate program %s", b.Program.Name)
+		}
+	case models.WatchdogActionBypass:
+		if !chain {
+			log.Warn().Msgf("watchdog of program %s: programs are not chained, the program is not bypassed", b.Program.Name)
+			break
+		}
+		if err := bypassBPF(e, ifaceName, direction, "watchdog "+message); err != nil {
+			log.Error().Err(err).Msgf("watchdog failed to bypass program %s", b.Program.Name)
+			break
+		}
+		if err := b.ProcessTerminate(); err != nil {
+			log.Error().Err(err).Msgf("watchdog failed to terminate bypassed program %s", b.Program.Name)
+		}
+	case models.WatchdogActionAlert:
+		notifyEvent(EventProgramWatchdog, ifaceName, direction, b.Program.Name, message)
+	}
+	return reason
+}
@@ -0,0 +1,107 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/models"
+)
+
+func Test_validateWatchdog(t *testing.T) {
+	tests := []struct {
+		name     string
+		watchdog *models.L3afDNFWatchdog
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"valid", &models.L3afDNFWatchdog{MaxRSS: 1 << 30, MaxCPUPercent: 150, MaxRestarts: 3, RestartWindow: "30m", Action: models.WatchdogActionBypass}, false},
+		{"default action", &models.L3afDNFWatchdog{MaxRSS: 1 << 30}, false},
+		{"negative", &models.L3afDNFWatchdog{MaxCPUPercent: -1}, true},
+		{"invalid window", &models.L3afDNFWatchdog{MaxRestarts: 3, RestartWindow: "hourly"}, true},
+		{"unknown action", &models.L3afDNFWatchdog{MaxRSS: 1 << 30, Action: "kill"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateWatchdog(tt.watchdog); (err != nil) != tt.wantErr {
+				t.Errorf("validateWatchdog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBPF_watchdogExceeded(t *testing.T) {
+	now := time.Now()
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", Watchdog: &models.L3afDNFWatchdog{
+		MaxRSS: 100 << 20, MaxCPUPercent: 50, MaxRestarts: 2, RestartWindow: "10m"}}}
+
+	if reason, _ := b.watchdogExceeded(&processUsage{RSSBytes: 200 << 20}, 10, now); reason != watchdogRSS {
+		t.Errorf("watchdogExceeded() = %q, want %q", reason, watchdogRSS)
+	}
+	// 1 cpu second in 10 seconds is 10%
+	if reason, _ := b.watchdogExceeded(&processUsage{RSSBytes: 50 << 20, CPUSeconds: 1}, 10, now.Add(10*time.Second)); reason != "" {
+		t.Errorf("watchdogExceeded() = %q, want no threshold exceeded", reason)
+	}
+	if reason, msg := b.watchdogExceeded(&processUsage{RSSBytes: 50 << 20, CPUSeconds: 9}, 10, now.Add(20*time.Second)); reason != watchdogCPU {
+		t.Errorf("watchdogExceeded() = %q %s, want %q", reason, msg, watchdogCPU)
+	}
+	// cpu time of the restarted process is not compared to the previous process
+	if reason, _ := b.watchdogExceeded(&processUsage{CPUSeconds: 100}, 11, now.Add(30*time.Second)); reason != "" {
+		t.Errorf("watchdogExceeded() = %q for the restarted process, want no threshold exceeded", reason)
+	}
+
+	b.recordRestart(now.Add(-20 * time.Minute))
+	b.recordRestart(now.Add(-time.Minute))
+	b.recordRestart(now.Add(31 * time.Second))
+	if len(b.restarts) != 2 {
+		t.Errorf("restarts = %v, want the restarts out of the window dropped", b.restarts)
+	}
+	b.recordRestart(now.Add(32 * time.Second))
+	if reason, _ := b.watchdogExceeded(&processUsage{CPUSeconds: 100}, 11, now.Add(40*time.Second)); reason != watchdogRestarts {
+		t.Errorf("watchdogExceeded() = %q, want %q", reason, watchdogRestarts)
+	}
+}
+
+func TestBPF_checkWatchdog(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to start sleep: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	now := time.Now()
+	b := &BPF{Program: models.BPFProgram{Name: "ratelimiting", Watchdog: &models.L3afDNFWatchdog{MaxRSS: 100 << 20}}, Cmd: cmd}
+	over := &processUsage{RSSBytes: 200 << 20}
+	if reason := b.checkWatchdog(nil, "eth0", models.XDPIngressType, false, over, now); reason != watchdogRSS || b.watchdogTrip != watchdogRSS {
+		t.Fatalf("checkWatchdog() = %q trip %q, want %q", reason, b.watchdogTrip, watchdogRSS)
+	}
+	if reason := b.checkWatchdog(nil, "eth0", models.XDPIngressType, false, &processUsage{RSSBytes: 50 << 20}, now); reason != "" || b.watchdogTrip != "" {
+		t.Errorf("checkWatchdog() = %q trip %q, want the trip reset under the thresholds", reason, b.watchdogTrip)
+	}
+
+	// restart action terminates the program for the process monitoring to restart it
+	b.Program.Watchdog.Action = models.WatchdogActionRestart
+	b.checkWatchdog(nil, "eth0", models.XDPIngressType, false, over, now)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("program exited without the termination signal")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("program is not terminated by the restart action")
+	}
+
+	// bypass is not possible without chaining, the trip is taken once
+	b.Program.Watchdog.Action = models.WatchdogActionBypass
+	b.watchdogTrip = ""
+	b.checkWatchdog(nil, "eth0", models.XDPIngressType, false, over, now)
+	if b.Degraded {
+		t.Errorf("program is bypassed without chaining")
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Events of the state change notifications
+const (
+	EventProgramFailed    = "program-failed"
+	EventProgramRestarted = "program-restarted"
+	EventProgramBypassed  = "program-bypassed"
+	EventProgramWatchdog  = "program-watchdog"
+	EventChainRepaired    = "chain-repaired"
+	EventApplyFailed      = "apply-failed"
+)
+
+// Headers of the webhook requests
+const (
+	webhookSignatureHeader = "X-L3afd-Signature"
+	webhookEventHeader     = "X-L3afd-Event"
+	webhookDeliveryHeader  = "X-L3afd-Delivery"
+)
+
+// maxWebhookRetryBackoff - longest wait between the retries of a delivery
+const maxWebhookRetryBackoff = 10 * time.Minute
+
+// webhookDelivery - notification posted to a target, retried until it is accepted or the retries are exhausted
+type webhookDelivery struct {
+	target   string
+	id       string
+	event    string
+	body     []byte
+	attempts int
+	next     time.Time
+}
+
+// webhookNotifier - posts the notifications to the webhook targets from a retry queue, so a slow or
+// unavailable target never blocks the state changes
+type webhookNotifier struct {
+	mu            sync.Mutex
+	host          string
+	targets       []string
+	events        map[string]bool // events sent, all the events when empty
+	secret        []byte          // HMAC-SHA256 key of the signature, requests are not signed when empty
+	client        *http.Client
+	maxQueue      int
+	maxRetries    int
+	retryInterval time.Duration
+	queue         []*webhookDelivery
+	wake          chan struct{}
+}
+
+var webhooks = &webhookNotifier{}
+
+// newWebhookNotifier - notifier of the webhook targets of l3afd.cfg, nil when no target is configured
+func newWebhookNotifier(host string, conf *config.Config) (*webhookNotifier, error) {
+	if conf == nil || len(conf.WebhookTargets) == 0 {
+		return nil, nil
+	}
+	n := &webhookNotifier{
+		host:          host,
+		targets:       conf.WebhookTargets,
+		events:        make(map[string]bool),
+		client:        &http.Client{Timeout: conf.WebhookTimeout},
+		maxQueue:      conf.WebhookQueueSize,
+		maxRetries:    conf.WebhookMaxRetries,
+		retryInterval: conf.WebhookRetryInterval,
+		wake:          make(chan struct{}, 1),
+	}
+	for _, event := range conf.WebhookEvents {
+		switch event {
+		case EventProgramFailed, EventProgramRestarted, EventProgramBypassed, EventProgramWatchdog, EventChainRepaired, EventApplyFailed:
+			n.events[event] = true
+		default:
+			return nil, fmt.Errorf("unknown webhook event %q", event)
+		}
+	}
+	if len(conf.WebhookSecretFile) > 0 {
+		secret, err := os.ReadFile(conf.WebhookSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook secret: %w", err)
+		}
+		if n.secret = bytes.TrimSpace(secret); len(n.secret) == 0 {
+			return nil, fmt.Errorf("webhook secret file %s is empty", conf.WebhookSecretFile)
+		}
+	}
+	if n.maxQueue <= 0 {
+		n.maxQueue = 1
+	}
+	if n.retryInterval <= 0 {
+		n.retryInterval = time.Second
+	}
+	return n, nil
+}
+
+// setWebhooks - configures the webhook targets from l3afd.cfg and starts the delivery of the notifications
+func setWebhooks(ctx context.Context, host string, conf *config.Config) error {
+	n, err := newWebhookNotifier(host, conf)
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		webhooks = &webhookNotifier{}
+		return nil
+	}
+	webhooks = n
+	go n.run(ctx)
+	return nil
+}
+
+// notifyEvent - queues the notification of the state change for the webhook targets
+func notifyEvent(event, iface, direction, program, message string) {
+	webhooks.notify(event, iface, direction, program, message, time.Now())
+}
+
+func (n *webhookNotifier) notify(event, iface, direction, program, message string, now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.targets) == 0 || (len(n.events) > 0 && !n.events[event]) {
+		return
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Warn().Err(err).Msgf("failed to generate the delivery id of %s notification", event)
+	}
+	notification := models.L3afDNotification{
+		ID:        hex.EncodeToString(id),
+		Time:      now.UTC().Format(time.RFC3339),
+		Host:      n.host,
+		Event:     event,
+		Iface:     iface,
+		Direction: direction,
+		Program:   program,
+		Message:   message,
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to marshal %s notification", event)
+		return
+	}
+	for _, target := range n.targets {
+		if len(n.queue) >= n.maxQueue {
+			dropped := n.queue[0]
+			n.queue = n.queue[1:]
+			log.Warn().Msgf("webhook queue is full, %s notification %s to %s is dropped", dropped.event, dropped.id, dropped.target)
+		}
+		n.queue = append(n.queue, &webhookDelivery{target: target, id: notification.ID, event: event, body: body, next: now})
+	}
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run - delivers the queued notifications until the context is done
+func (n *webhookNotifier) run(ctx context.Context) {
+	for {
+		wait := n.deliverDue(ctx, time.Now())
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-n.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// deliverDue - posts the deliveries due at now in the queue order and requeues the failed ones with a
+// doubling backoff, returns the wait until the next delivery is due
+func (n *webhookNotifier) deliverDue(ctx context.Context, now time.Time) time.Duration {
+	n.mu.Lock()
+	var due, pending []*webhookDelivery
+	for _, d := range n.queue {
+		if d.next.After(now) {
+			pending = append(pending, d)
+		} else {
+			due = append(due, d)
+		}
+	}
+	n.queue = pending
+	n.mu.Unlock()
+
+	var failed []*webhookDelivery
+	for _, d := range due {
+		err := n.post(ctx, d)
+		if err == nil {
+			continue
+		}
+		d.attempts++
+		if d.attempts > n.maxRetries {
+			log.Error().Err(err).Msgf("%s notification %s to %s is dropped after %d attempts", d.event, d.id, d.target, d.attempts)
+			continue
+		}
+		backoff := n.retryInterval << uint(d.attempts-1)
+		if backoff <= 0 || backoff > maxWebhookRetryBackoff {
+			backoff = maxWebhookRetryBackoff
+		}
+		d.next = now.Add(backoff)
+		log.Warn().Err(err).Msgf("%s notification %s to %s failed, retrying in %s", d.event, d.id, d.target, backoff)
+		failed = append(failed, d)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	// failed deliveries go before the notifications queued in the meantime
+	n.queue = append(failed, n.queue...)
+	if len(n.queue) > n.maxQueue {
+		n.queue = n.queue[len(n.queue)-n.maxQueue:]
+	}
+	wait := maxWebhookRetryBackoff
+	for _, d := range n.queue {
+		if w := d.next.Sub(now); w < wait {
+			wait = w
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// post - posts the notification to the target, signed with the HMAC-SHA256 of the body when the secret is set
+func (n *webhookNotifier) post(ctx context.Context, d *webhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.target, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, d.event)
+	req.Header.Set(webhookDeliveryHeader, d.id)
+	if len(n.secret) > 0 {
+		req.Header.Set(webhookSignatureHeader, webhookSignature(n.secret, d.body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post request returned unexpected status code: %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// webhookSignature - sha256=<hex HMAC-SHA256 of the body>
+func webhookSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,107 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kf
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/models"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	secretFile := t.TempDir() + "/secret"
+	if err := os.WriteFile(secretFile, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []models.L3afDNotification
+	calls := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(webhookSignatureHeader), webhookSignature([]byte("s3cr3t"), body); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var n models.L3afDNotification
+		if err := json.Unmarshal(body, &n); err != nil {
+			t.Errorf("invalid notification %s: %v", body, err)
+		}
+		if r.Header.Get(webhookEventHeader) != n.Event || r.Header.Get(webhookDeliveryHeader) != n.ID {
+			t.Errorf("headers %v do not match notification %+v", r.Header, n)
+		}
+		received = append(received, n)
+	}))
+	defer target.Close()
+
+	conf := &config.Config{
+		WebhookTargets:       []string{target.URL},
+		WebhookEvents:        []string{EventProgramFailed, EventChainRepaired},
+		WebhookSecretFile:    secretFile,
+		WebhookTimeout:       time.Second,
+		WebhookQueueSize:     10,
+		WebhookMaxRetries:    2,
+		WebhookRetryInterval: time.Minute,
+	}
+	n, err := newWebhookNotifier("edge-1a", conf)
+	if err != nil {
+		t.Fatalf("newWebhookNotifier() error = %v", err)
+	}
+
+	now := time.Now()
+	n.notify(EventProgramFailed, "eth0", "xdpingress", "ratelimiting", "start timed out", now)
+	n.notify(EventProgramRestarted, "eth0", "xdpingress", "ratelimiting", "filtered out", now)
+
+	// failed delivery is retried after the retry interval
+	if wait := n.deliverDue(context.Background(), now); wait != time.Minute || len(received) != 0 {
+		t.Fatalf("deliverDue() wait = %s received = %d, want retry in 1m", wait, len(received))
+	}
+	if n.deliverDue(context.Background(), now.Add(30*time.Second)); calls != 1 {
+		t.Errorf("delivery is retried before the retry interval")
+	}
+	n.deliverDue(context.Background(), now.Add(time.Minute))
+	if len(received) != 1 || received[0].Event != EventProgramFailed || received[0].Host != "edge-1a" || received[0].Program != "ratelimiting" {
+		t.Fatalf("received = %+v, want the program-failed notification", received)
+	}
+
+	if _, err := newWebhookNotifier("edge-1a", &config.Config{WebhookTargets: []string{target.URL}, WebhookEvents: []string{"program-exploded"}}); err == nil {
+		t.Errorf("newWebhookNotifier() error = nil, want unknown event")
+	}
+}
+
+func TestWebhookNotifier_retriesExhausted(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	n, err := newWebhookNotifier("edge-1a", &config.Config{WebhookTargets: []string{target.URL}, WebhookQueueSize: 2,
+		WebhookMaxRetries: 1, WebhookRetryInterval: time.Second, WebhookTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		n.notify(EventChainRepaired, "eth0", "ingress", "ratelimiting", "relinked", now)
+	}
+	if len(n.queue) != 2 {
+		t.Errorf("queue length = %d, want the oldest dropped at the queue size", len(n.queue))
+	}
+	n.deliverDue(context.Background(), now)
+	n.deliverDue(context.Background(), now.Add(time.Second))
+	if len(n.queue) != 0 {
+		t.Errorf("queue length = %d, want the deliveries dropped after the retries", len(n.queue))
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ResourceCRD       = "crd"
+	ResourceConfigMap = "configmap"
+
+	// ConfigMaps holding NF configs are labelled with configMapLabel=true
+	configMapLabel = "l3af.io/config"
+	// configMapKey is the ConfigMap data key holding the []models.L3afBPFPrograms json
+	configMapKey = "l3af-config.json"
+	// nodeSelectorAnnotation is the node selector of a ConfigMap in k1=v1,k2=v2 format
+	nodeSelectorAnnotation = "l3af.io/node-selector"
+
+	crdGroupVersion = "l3af.io/v1alpha1"
+	crdPlural       = "l3afnodeconfigs"
+
+	retryInterval = 10 * time.Second
+)
+
+// errChanged stops the watch stream to resync the configs
+var errChanged = errors.New("watched objects changed")
+
+// nodeConfig - NF configs of the nodes matching the node selector, decoded from a L3afNodeConfig
+// custom resource or a ConfigMap
+type nodeConfig struct {
+	Metadata     objectMeta
+	NodeSelector map[string]string
+	BpfPrograms  []models.L3afBPFPrograms
+	err          error // invalid object
+}
+
+// nodeStatus - status of the node written to status.nodes.<node> of the L3afNodeConfig
+type nodeStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration"`
+	Applied            bool   `json:"applied"`
+	Message            string `json:"message,omitempty"`
+	LastUpdateTime     string `json:"lastUpdateTime"`
+}
+
+type watcher struct {
+	client    *client
+	kfcfg     *kf.NFConfigs
+	resource  string
+	namespace string
+	path      string // list and watch path of the objects
+	node      string
+	resync    time.Duration
+
+	applied  []models.L3afBPFPrograms // last applied configs
+	statuses map[string]nodeStatus    // last reported status by object name
+}
+
+// StartWatcher - starts watching the NF configs of the node in the configured namespace
+func StartWatcher(ctx context.Context, conf *config.Config, kfcfg *kf.NFConfigs) error {
+	if len(conf.KubernetesNodeName) == 0 {
+		return fmt.Errorf("kubernetes node name is not configured, set node-name or NODE_NAME environment variable")
+	}
+
+	w := &watcher{
+		kfcfg:     kfcfg,
+		resource:  conf.KubernetesResource,
+		namespace: conf.KubernetesNamespace,
+		node:      conf.KubernetesNodeName,
+		resync:    conf.KubernetesResyncInterval,
+		statuses:  make(map[string]nodeStatus),
+	}
+
+	switch w.resource {
+	case ResourceCRD:
+		w.path = fmt.Sprintf("/apis/%s/namespaces/%s/%s", crdGroupVersion, url.PathEscape(conf.KubernetesNamespace), crdPlural)
+	case ResourceConfigMap:
+		w.path = fmt.Sprintf("/api/v1/namespaces/%s/configmaps?labelSelector=%s", url.PathEscape(conf.KubernetesNamespace), url.QueryEscape(configMapLabel+"=true"))
+	default:
+		return fmt.Errorf("unknown kubernetes resource %s, supported resources are %s and %s", w.resource, ResourceCRD, ResourceConfigMap)
+	}
+
+	var err error
+	if w.client, err = newInClusterClient(); err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	log.Info().Msgf("watching %s %s for NF configs of node %s", conf.KubernetesNamespace, w.resource, w.node)
+	go w.run(ctx)
+	return nil
+}
+
+// run - lists and applies the configs, then watches until a change or the resync interval
+func (w *watcher) run(ctx context.Context) {
+	for {
+		resourceVersion, err := w.sync(ctx)
+		if err == nil {
+			err = w.client.watch(ctx, w.path, resourceVersion, w.resync, func(event watchEvent) error {
+				log.Debug().Msgf("kubernetes %s %s event", w.resource, event.Type)
+				return errChanged
+			})
+		}
+
+		if err != nil && !errors.Is(err, errChanged) {
+			log.Error().Err(err).Msgf("kubernetes %s watch failed, retrying in %s", w.resource, retryInterval)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// sync - applies the configs of the objects matching the node and reports the status,
+// returns the resource version of the list to watch from
+func (w *watcher) sync(ctx context.Context) (string, error) {
+	var node struct {
+		Metadata objectMeta `json:"metadata"`
+	}
+	if err := w.client.getJSON(ctx, "/api/v1/nodes/"+url.PathEscape(w.node), &node); err != nil {
+		return "", fmt.Errorf("failed to get node %s: %w", w.node, err)
+	}
+
+	configs, resourceVersion, err := w.list(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s: %w", w.resource, err)
+	}
+
+	matched := make([]*nodeConfig, 0, len(configs))
+	for _, cfg := range configs {
+		if matchNodeSelector(cfg.NodeSelector, node.Metadata.Labels) {
+			matched = append(matched, cfg)
+		}
+	}
+
+	bpfProgs, errs := mergeNodeConfigs(matched, w.node)
+
+	var applyErr error
+	for _, cfg := range matched {
+		// invalid object would remove the running programs it configures, last applied configs are kept
+		if cfg.err != nil {
+			applyErr = fmt.Errorf("%s %s is invalid, keeping the last applied configs", w.resource, cfg.Metadata.Name)
+			break
+		}
+	}
+	if applyErr == nil && !reflect.DeepEqual(bpfProgs, w.applied) {
+		log.Info().Msgf("applying NF configs of %d %s objects", len(matched), w.resource)
+		if applyErr = w.kfcfg.RejectFrozen("kubernetes-apply", "kubernetes", map[string]string{"resource": w.resource}); applyErr != nil {
+			log.Warn().Msgf("NF configs from kubernetes %s are not applied during the config freeze", w.resource)
+		} else if applyErr = w.kfcfg.DeployeBPFPrograms(bpfProgs); applyErr != nil {
+			log.Error().Err(applyErr).Msgf("failed to apply NF configs from kubernetes %s", w.resource)
+		} else {
+			w.applied = bpfProgs
+		}
+	}
+
+	if w.resource == ResourceCRD {
+		w.reportStatus(ctx, matched, errs, applyErr)
+	}
+	return resourceVersion, nil
+}
+
+// list - returns the NF configs of the namespace and the resource version of the list
+func (w *watcher) list(ctx context.Context) ([]*nodeConfig, string, error) {
+	configs := make([]*nodeConfig, 0)
+	switch w.resource {
+	case ResourceCRD:
+		var list struct {
+			Metadata listMeta `json:"metadata"`
+			Items    []struct {
+				Metadata objectMeta `json:"metadata"`
+				Spec     struct {
+					NodeSelector map[string]string        `json:"nodeSelector"`
+					BpfPrograms  []models.L3afBPFPrograms `json:"bpfPrograms"`
+				} `json:"spec"`
+			} `json:"items"`
+		}
+		if err := w.client.getJSON(ctx, w.path, &list); err != nil {
+			return nil, "", err
+		}
+		for _, item := range list.Items {
+			configs = append(configs, &nodeConfig{
+				Metadata:     item.Metadata,
+				NodeSelector: item.Spec.NodeSelector,
+				BpfPrograms:  item.Spec.BpfPrograms,
+			})
+		}
+		return configs, list.Metadata.ResourceVersion, nil
+	default:
+		var list struct {
+			Metadata listMeta `json:"metadata"`
+			Items    []struct {
+				Metadata objectMeta        `json:"metadata"`
+				Data     map[string]string `json:"data"`
+			} `json:"items"`
+		}
+		if err := w.client.getJSON(ctx, w.path, &list); err != nil {
+			return nil, "", err
+		}
+		for _, item := range list.Items {
+			cfg := &nodeConfig{Metadata: item.Metadata}
+			cfg.NodeSelector, cfg.err = parseNodeSelector(item.Metadata.Annotations[nodeSelectorAnnotation])
+			if cfg.err == nil {
+				if err := json.Unmarshal([]byte(item.Data[configMapKey]), &cfg.BpfPrograms); err != nil {
+					cfg.err = fmt.Errorf("invalid %s: %w", configMapKey, err)
+				}
+			}
+			if cfg.err != nil {
+				log.Error().Err(cfg.err).Msgf("ignoring ConfigMap %s", item.Metadata.Name)
+			}
+			configs = append(configs, cfg)
+		}
+		return configs, list.Metadata.ResourceVersion, nil
+	}
+}
+
+// reportStatus - writes the node status to the matched custom resources, and removes the node status from
+// the custom resources which are no longer matching the node
+func (w *watcher) reportStatus(ctx context.Context, matched []*nodeConfig, errs map[string]error, applyErr error) {
+	reported := make(map[string]bool, len(matched))
+	for _, cfg := range matched {
+		name := cfg.Metadata.Name
+		reported[name] = true
+
+		status := nodeStatus{ObservedGeneration: cfg.Metadata.Generation, Applied: true}
+		if err := errs[name]; err != nil {
+			status.Applied, status.Message = false, err.Error()
+		} else if applyErr != nil {
+			status.Applied, status.Message = false, applyErr.Error()
+		}
+
+		prev, ok := w.statuses[name]
+		prev.LastUpdateTime = ""
+		if ok && prev == status {
+			continue
+		}
+		status.LastUpdateTime = time.Now().UTC().Format(time.RFC3339)
+		if err := w.patchStatus(ctx, name, &status); err != nil {
+			log.Warn().Err(err).Msgf("failed to update status of %s %s", crdPlural, name)
+			continue
+		}
+		status.LastUpdateTime = ""
+		w.statuses[name] = status
+	}
+
+	for name := range w.statuses {
+		if reported[name] {
+			continue
+		}
+		if err := w.patchStatus(ctx, name, nil); err != nil {
+			log.Warn().Err(err).Msgf("failed to remove node status of %s %s", crdPlural, name)
+			continue
+		}
+		delete(w.statuses, name)
+	}
+}
+
+// patchStatus - merge patches status.nodes.<node> of the custom resource, nil status removes the node
+func (w *watcher) patchStatus(ctx context.Context, name string, status *nodeStatus) error {
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"nodes": map[string]interface{}{w.node: status},
+		},
+	}
+	path := fmt.Sprintf("/apis/%s/namespaces/%s/%s/%s/status", crdGroupVersion, url.PathEscape(w.namespace), crdPlural, url.PathEscape(name))
+	return w.client.mergePatch(ctx, path, patch)
+}
+
+// mergeNodeConfigs - merges the configs of the matched objects sorted by name. An interface can be
+// configured only by one object, configs of an object conflicting with an earlier object are skipped.
+// Returns the merged configs and the errors by object name.
+func mergeNodeConfigs(configs []*nodeConfig, node string) ([]models.L3afBPFPrograms, map[string]error) {
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Metadata.Name < configs[j].Metadata.Name })
+
+	errs := make(map[string]error)
+	owners := make(map[string]string) // iface to object name
+	bpfProgs := make([]models.L3afBPFPrograms, 0)
+	for _, cfg := range configs {
+		if cfg.err != nil {
+			errs[cfg.Metadata.Name] = cfg.err
+			continue
+		}
+		conflict := false
+		for _, p := range cfg.BpfPrograms {
+			if owner, ok := owners[p.Iface]; ok {
+				errs[cfg.Metadata.Name] = fmt.Errorf("iface %s is already configured by %s", p.Iface, owner)
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			continue
+		}
+		for _, p := range cfg.BpfPrograms {
+			owners[p.Iface] = cfg.Metadata.Name
+			if len(p.HostName) == 0 {
+				p.HostName = node
+			}
+			bpfProgs = append(bpfProgs, p)
+		}
+	}
+	return bpfProgs, errs
+}
+
+// matchNodeSelector - all the selector labels must match the node labels, empty selector matches all nodes
+func matchNodeSelector(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNodeSelector - parses node selector in k1=v1,k2=v2 format
+func parseNodeSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if len(term) == 0 {
+			continue
+		}
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("invalid node selector term %q", term)
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return labels, nil
+}
@@ -9,8 +9,8 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +19,7 @@ import (
 	"github.com/l3af-project/l3afd/apis/handlers"
 	"github.com/l3af-project/l3afd/config"
 	"github.com/l3af-project/l3afd/kf"
+	"github.com/l3af-project/l3afd/kubernetes"
 	"github.com/l3af-project/l3afd/models"
 	"github.com/l3af-project/l3afd/pidfile"
 	"github.com/l3af-project/l3afd/stats"
@@ -58,21 +59,58 @@ func setupLogging() {
 }
 
 func main() {
+	// l3afd executed as the sandbox helper of a user program
+	if filepath.Base(os.Args[0]) == kf.SandboxHelperName {
+		os.Exit(kf.RunSandboxHelper(os.Args[1:]))
+	}
 	setupLogging()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	log.Info().Msgf("%s started.", daemonName)
 
 	var confPath string
+	var doctor bool
+	var service string
 	flag.StringVar(&confPath, "config", "config/l3afd.cfg", "config path")
+	flag.BoolVar(&doctor, "doctor", false, "run the preflight diagnostics, print the report and exit")
+	flag.StringVar(&service, "service", "", "install or uninstall the windows service of l3afd with the config path and exit")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), cliUsage)
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 	initVersion()
+	if len(service) > 0 {
+		if err := manageService(service, confPath); err != nil {
+			log.Fatal().Err(err).Msgf("Unable to %s the service", service)
+		}
+		return
+	}
+
 	conf, err := config.ReadConfig(confPath)
 	if err != nil {
 		log.Fatal().Err(err).Msgf("Unable to parse config %q", confPath)
 	}
 
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(conf, flag.Args()))
+	}
+	log.Info().Msgf("%s started.", daemonName)
+
+	if err = kf.SetupLogging(conf); err != nil {
+		log.Fatal().Err(err).Msg("Unable to set up logging")
+	}
+
+	if doctor {
+		os.Exit(runDoctor(conf))
+	}
+
+	// stop of the service is accepted only after the startup completes
+	var kfConfigs *kf.NFConfigs
+	serviceStarted := startService(conf, func() uint32 {
+		return shutdownService(conf, kfConfigs)
+	})
+
 	if err = pidfile.CheckPIDConflict(conf.PIDFilename); err != nil {
 		log.Fatal().Err(err).Msgf("The PID file: %s, is in an unacceptable state", conf.PIDFilename)
 	}
@@ -84,15 +122,28 @@ func main() {
 		log.Fatal().Err(err).Msg("The unsupported kernel version please upgrade")
 	}
 
+	if conf.DoctorOnStartup {
+		if err = checkDoctor(conf); err != nil {
+			if conf.DoctorFailOnError {
+				log.Fatal().Err(err).Msg("L3afd preflight diagnostics failed")
+			}
+			log.Error().Err(err).Msg("L3afd preflight diagnostics failed")
+		}
+	}
+
 	if err = registerL3afD(conf); err != nil {
 		log.Error().Err(err).Msg("L3afd registration failed")
 	}
 
-	kfConfigs, err := SetupNFConfigs(ctx, conf)
+	kfConfigs, err = SetupNFConfigs(ctx, conf)
 	if err != nil {
 		log.Fatal().Err(err).Msg("L3afd failed to start")
 	}
 
+	if err := kfConfigs.ProbeBTF(); err != nil {
+		log.Warn().Err(err).Msg("kernel BTF is not available, programs requiring CO-RE will not be started")
+	}
+
 	t, err := ReadConfigsFromConfigStore(conf)
 	if err != nil {
 		log.Error().Err(err).Msg("L3afd failed to read configs from store")
@@ -108,12 +159,43 @@ func main() {
 		log.Fatal().Err(err).Msg("L3afd failed to initialise configs")
 	}
 
+	kfConfigs.StartHeartbeat(ctx, ShortVersion())
+
+	kfConfigs.StartVersionSkewCheck(ctx)
+
+	if err := kfConfigs.StartPeering(ctx); err != nil {
+		log.Fatal().Err(err).Msg("L3afd failed to start peering")
+	}
+
+	if conf.KubernetesEnabled {
+		if err := kubernetes.StartWatcher(ctx, conf, kfConfigs); err != nil {
+			log.Fatal().Err(err).Msg("L3afd failed to start kubernetes watcher")
+		}
+	}
+
 	if conf.EBPFChainDebugEnabled {
-		kf.SetupKFDebug(conf.EBPFChainDebugAddr, kfConfigs)
+		kf.SetupKFDebug(conf.EBPFChainDebugAddr, conf.EBPFChainDebugAllowedCIDRs, kfConfigs)
 	}
+	serviceStarted()
 	select {}
 }
 
+// shutdownService - stops the programs on the stop of the service, the exit code is 1 when the programs
+// fail to stop
+func shutdownService(conf *config.Config, kfConfigs *kf.NFConfigs) uint32 {
+	var exitCode uint32
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+	if err := kfConfigs.Close(ctx); err != nil {
+		log.Error().Err(err).Msg("stopping all network functions failed")
+		exitCode = 1
+	}
+	if err := pidfile.RemovePID(conf.PIDFilename); err != nil {
+		log.Warn().Err(err).Msg("Could not cleanup PID file")
+	}
+	return exitCode
+}
+
 func SetupNFConfigs(ctx context.Context, conf *config.Config) (*kf.NFConfigs, error) {
 	// Get Hostname
 	machineHostname, err := os.Hostname()
@@ -122,12 +204,13 @@ func SetupNFConfigs(ctx context.Context, conf *config.Config) (*kf.NFConfigs, er
 	}
 
 	// setup Metrics endpoint
-	stats.SetupMetrics(machineHostname, daemonName, conf.MetricsAddr)
+	stats.SetupMetrics(machineHostname, daemonName, conf)
 
 	pMon := kf.NewpCheck(conf.MaxNFReStartCount, conf.BpfChainingEnabled, conf.KFPollInterval)
-	kfM := kf.NewpKFMetrics(conf.BpfChainingEnabled, conf.NMetricSamples)
+	kfM := kf.NewpKFMetrics(conf.BpfChainingEnabled, conf.NMetricSamples, conf.MonitorMapsPeriod, conf.MonitorMapsBudget)
+	pUsage := kf.NewpUsage(conf.BpfChainingEnabled, conf.ProcessMetricsInterval)
 
-	nfConfigs, err := kf.NewNFConfigs(ctx, machineHostname, conf, pMon, kfM)
+	nfConfigs, err := kf.NewNFConfigs(ctx, machineHostname, conf, pMon, kfM, pUsage)
 	if err != nil {
 		return nil, fmt.Errorf("error in NewNFConfigs setup: %v", err)
 	}
@@ -172,7 +255,7 @@ func checkKernelVersion(conf *config.Config) error {
 }
 
 func getKernelVersion() (string, error) {
-	osVersion, err := ioutil.ReadFile("/proc/version")
+	osVersion, err := os.ReadFile("/proc/version")
 	if err != nil {
 		return "", fmt.Errorf("failed to read procfs: %v", err)
 	}
@@ -185,6 +268,40 @@ func getKernelVersion() (string, error) {
 	return kernelVersion, nil
 }
 
+// runDoctor - prints the report of the preflight diagnostics, the exit code is 1 when a check fails
+func runDoctor(conf *config.Config) int {
+	report := kf.RunDoctorFromStore(conf)
+	buf, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal the diagnostics report")
+		return 1
+	}
+	fmt.Println(string(buf))
+	if report.Status == models.DoctorFail {
+		return 1
+	}
+	return 0
+}
+
+// checkDoctor - logs the findings of the preflight diagnostics, returns an error when a check fails
+func checkDoctor(conf *config.Config) error {
+	report := kf.RunDoctorFromStore(conf)
+	var failed []string
+	for _, check := range report.Checks {
+		switch check.Status {
+		case models.DoctorFail:
+			log.Error().Msgf("preflight check %s failed: %s", check.Name, check.Message)
+			failed = append(failed, check.Name)
+		case models.DoctorWarn:
+			log.Warn().Msgf("preflight check %s: %s", check.Name, check.Message)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed checks %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
 func ReadConfigsFromConfigStore(conf *config.Config) ([]models.L3afBPFPrograms, error) {
 
 	// check for persistent file
@@ -193,16 +310,8 @@ func ReadConfigsFromConfigStore(conf *config.Config) ([]models.L3afBPFPrograms,
 		return nil, nil
 	}
 
-	file, err := os.OpenFile(conf.L3afConfigStoreFileName, os.O_RDONLY, os.ModePerm)
-	defer func() {
-		_ = file.Close()
-	}()
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to open persistent file (%s): %v", conf.L3afConfigStoreFileName, err)
-	}
-
-	byteValue, err := ioutil.ReadAll(file)
+	// persistent file is decrypted in memory when encryption is enabled
+	byteValue, err := kf.ReadStateFile(conf.L3afConfigStoreFileName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read persistent file (%s): %v", conf.L3afConfigStoreFileName, err)
 	}
@@ -4,8 +4,17 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/l3af-project/l3afd/config"
 )
@@ -21,3 +30,65 @@ func TestTestConfigValid(t *testing.T) {
 		t.Errorf("Unable to read l3afd config: %s", err)
 	}
 }
+
+func TestCLIClient(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "l3afd.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix socket not available: %v", err)
+	}
+	var applied []byte
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/l3af/configs/v1/effective":
+			io.WriteString(w, `[{"iface":"eth0","bpf_programs":[{"direction":"xdpingress","state":"root","program":{"name":"xdp-root","version":"1.0"}},
+				{"direction":"xdpingress","state":"running","program":{"name":"ratelimiting","version":"1.1","seq_id":1}}]}]`)
+		case r.URL.Path == "/l3af/configs/v1/update" && r.Method == http.MethodPost:
+			applied, _ = io.ReadAll(r.Body)
+		case r.URL.Path == "/l3af/maps/v1/eth0/xdpingress/ratelimiting":
+			if r.URL.Query().Get("map") != "rl_config_map" || r.URL.Query().Get("prefix") != "0a" {
+				t.Errorf("map dump query = %s, want the map and the prefix", r.URL.RawQuery)
+			}
+			io.WriteString(w, `{"entries":[],"continue":""}`)
+		case r.URL.Path == "/l3af/chains/v1/eth1/graph":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "no programs are running on the iface eth1")
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	var out bytes.Buffer
+	c := newCLIClient(socketPath, time.Second, &out)
+	if err := c.run([]string{"status"}); err != nil {
+		t.Fatalf("status error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[2], "eth0   xdpingress  1    ratelimiting  1.1      running") {
+		t.Errorf("status = %q, want the header, the root and ratelimiting", out.String())
+	}
+
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`[{"host_name":"l3af-test-host","iface":"eth0"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run([]string{"apply", "-f", configFile}); err != nil || !strings.Contains(string(applied), `"iface":"eth0"`) {
+		t.Errorf("apply error = %v body %s, want the config posted", err, applied)
+	}
+
+	out.Reset()
+	if err := c.run([]string{"maps", "dump", "-iface", "eth0", "-direction", "xdpingress", "-program", "ratelimiting", "-map", "rl_config_map", "-prefix", "0a"}); err != nil {
+		t.Errorf("maps dump error = %v", err)
+	}
+
+	if err := c.run([]string{"chain", "show", "eth1"}); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("chain show error = %v, want the 404 of the daemon", err)
+	}
+	for _, args := range [][]string{{}, {"maps"}, {"apply"}, {"chain", "show", "-format", "svg", "eth0"}} {
+		if err := c.run(args); !errors.Is(err, errCLIUsage) {
+			t.Errorf("run(%v) error = %v, want errCLIUsage", args, err)
+		}
+	}
+}
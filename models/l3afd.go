@@ -3,10 +3,13 @@
 
 package models
 
+import "encoding/json"
+
 // l3afd constants
 const (
 	Enabled  = "enabled"
 	Disabled = "disabled"
+	Standby  = "standby"
 
 	StartType = "start"
 	StopType  = "stop"
@@ -19,50 +22,233 @@ const (
 	XDPIngressType = "xdpingress"
 )
 
+// Priority classes of the programs in the chain, in chain order
+const (
+	PriorityFirst  = "first"
+	PriorityEarly  = "early"
+	PriorityNormal = "normal"
+	PriorityLate   = "late"
+	PriorityLast   = "last"
+)
+
+// External stop modes of the instances of a program running outside l3afd
+const (
+	ExternalStopKill     = "kill"
+	ExternalStopDryRun   = "dry-run"
+	ExternalStopDisabled = "disabled"
+)
+
 type L3afDNFArgs map[string]interface{}
 
 // BPFProgram defines BPF Program for specific host
 type BPFProgram struct {
-	ID                int                 `json:"id"`                  // Program id
-	Name              string              `json:"name"`                // Name of the BPF program
-	SeqID             int                 `json:"seq_id"`              // Sequence position in the chain
-	Artifact          string              `json:"artifact"`            // Artifact file name
-	MapName           string              `json:"map_name"`            // BPF map to store next program fd
-	CmdStart          string              `json:"cmd_start"`           // Program start command
-	CmdStop           string              `json:"cmd_stop"`            // Program stop command
-	CmdStatus         string              `json:"cmd_status"`          // Program status command
-	CmdConfig         string              `json:"cmd_config"`          // Program config providing command
-	Version           string              `json:"version"`             // Program version
-	UserProgramDaemon bool                `json:"user_program_daemon"` // User program daemon or not
-	IsPlugin          bool                `json:"is_plugin"`           // User program is plugin or not
-	CPU               int                 `json:"cpu"`                 // User program cpu limits
-	Memory            int                 `json:"memory"`              // User program memory limits
-	AdminStatus       string              `json:"admin_status"`        // Program admin status enabled or disabled
-	ProgType          string              `json:"prog_type"`           // Program type XDP or TC
-	RulesFile         string              `json:"rules_file"`          // Config rules file name
-	Rules             string              `json:"rules"`               // Config rules
-	ConfigFilePath    string              `json:"config_file_path"`    // Config file location
-	CfgVersion        int                 `json:"cfg_version"`         // Config version
-	StartArgs         L3afDNFArgs         `json:"start_args"`          // Map of arguments to start command
-	StopArgs          L3afDNFArgs         `json:"stop_args"`           // Map of arguments to stop command
-	StatusArgs        L3afDNFArgs         `json:"status_args"`         // Map of arguments to status command
-	MapArgs           L3afDNFArgs         `json:"map_args"`            // Config BPF Map of arguments
-	ConfigArgs        L3afDNFArgs         `json:"config_args"`         // Map of arguments to config command
-	MonitorMaps       []L3afDNFMetricsMap `json:"monitor_maps"`        // Metrics BPF maps
+	ID                int                  `json:"id"`                  // Program id
+	Name              string               `json:"name"`                // Name of the BPF program
+	SeqID             int                  `json:"seq_id"`              // Sequence position in the chain
+	Artifact          string               `json:"artifact"`            // Artifact file name
+	MapName           string               `json:"map_name"`            // BPF map to store next program fd
+	CmdStart          string               `json:"cmd_start"`           // Program start command
+	CmdStop           string               `json:"cmd_stop"`            // Program stop command
+	CmdStatus         string               `json:"cmd_status"`          // Program status command
+	CmdConfig         string               `json:"cmd_config"`          // Program config providing command
+	Version           string               `json:"version"`             // Program version
+	UserProgramDaemon bool                 `json:"user_program_daemon"` // User program daemon or not
+	IsPlugin          bool                 `json:"is_plugin"`           // User program is plugin or not
+	CPU               int                  `json:"cpu"`                 // User program cpu limits
+	Memory            int                  `json:"memory"`              // User program memory limits
+	AdminStatus       string               `json:"admin_status"`        // Program admin status enabled, disabled or standby, loaded but not linked into the chain until activated
+	ProgType          string               `json:"prog_type"`           // Program type XDP or TC
+	RulesFile         string               `json:"rules_file"`          // Config rules file name
+	Rules             string               `json:"rules"`               // Config rules
+	ConfigFilePath    string               `json:"config_file_path"`    // Config file location
+	CfgVersion        int                  `json:"cfg_version"`         // Config version
+	StartArgs         L3afDNFArgs          `json:"start_args"`          // Map of arguments to start command
+	StopArgs          L3afDNFArgs          `json:"stop_args"`           // Map of arguments to stop command
+	StatusArgs        L3afDNFArgs          `json:"status_args"`         // Map of arguments to status command
+	MapArgs           L3afDNFArgs          `json:"map_args"`            // Config BPF Map of arguments
+	ConfigArgs        L3afDNFArgs          `json:"config_args"`         // Map of arguments to config command
+	MonitorMaps       []L3afDNFMetricsMap  `json:"monitor_maps"`        // Metrics BPF maps
+	Tenant            string               `json:"tenant"`              // Tenant owning the program
+	Dependencies      []string             `json:"dependencies"`        // Names of the programs required to be running before this program
+	SharedMaps        map[string]string    `json:"shared_maps"`         // Pinned maps shared with other programs, logical name to pin path
+	ConsumedMaps      []L3afDNFConsumedMap `json:"consumed_maps"`       // Pinned maps of other programs used by this program
+	RequiresCORE      bool                 `json:"requires_core"`       // Program uses CO-RE relocations and requires kernel BTF
+	TestVectors       []L3afDNFTestVector  `json:"test_vectors"`        // Test packets run through the program before it is started
+	AFXDP             *L3afDNFAFXDP        `json:"af_xdp"`              // AF_XDP sockets config of the user program
+	IfaceAddrs        bool                 `json:"iface_addrs"`         // Pass IPv4 and IPv6 addresses of the interface to the start command
+	Priority          string               `json:"priority"`            // Priority class of the position in the chain, seq id is assigned by l3afd
+	After             []string             `json:"after"`               // Names of the programs chained before this program in the same direction
+	BypassOnFailure   bool                 `json:"bypass_on_failure"`   // Bypass the program in the chain when the restarts are exhausted
+	SharedPins        []string             `json:"shared_pins"`         // Pin paths the program declares along with other programs
+	CoreDumps         bool                 `json:"core_dumps"`          // Enable core dumps of the user program and collect them for post-mortem debugging
+	StopGracePeriod   int                  `json:"stop_grace_period"`   // Seconds the user program may take to exit after SIGTERM before it is killed, l3afd default when 0
+	ExternalStop      string               `json:"external_stop"`       // Instances running outside l3afd are killed, only logged in dry-run or left running when disabled, kill by default
+	ApplyWindow       string               `json:"apply_window"`        // Window of the version upgrades and chain reorders e.g. Mon-Fri 02:00-04:00, l3afd window when empty
+	MapMemory         int                  `json:"map_memory"`          // Bytes of locked memory of the BPF maps of the program, memlock limit inherited by the user program is raised to it
+	Nice              int                  `json:"nice"`                // Nice value of the user program, -20 to 19
+	CPUAffinity       string               `json:"cpu_affinity"`        // CPUs the user program runs on e.g. 2-5,8 or same-node for the NUMA node of the NIC, all the CPUs when empty
+	IONiceClass       string               `json:"ionice_class"`        // I/O scheduling class of the user program, realtime, best-effort or idle
+	IONiceLevel       int                  `json:"ionice_level"`        // I/O priority of the realtime and best-effort classes, 0 highest to 7 lowest
+	RulesMap          string               `json:"rules_map"`           // Map the rule entries are written to, <key> [<value>] per rule, pinned path for TC programs
+	RulesKeyType      string               `json:"rules_key_type"`      // Type of the rule keys, u8, u16, u32, u64, be16, be32, be64, ipv4, ipv6, mac or hex
+	RulesValueType    string               `json:"rules_value_type"`    // Type of the rule values, zero value when a rule has no value
+	RulesValidator    string               `json:"rules_validator"`     // Validator of the rules, json, json-schema, cidr-list or command:<command of the artifact>, rules are not validated when empty
+	RulesSchema       json.RawMessage      `json:"rules_schema"`        // JSON schema of the rules of the json-schema rules validator
+	PreserveMaps      []string             `json:"preserve_maps"`       // Maps whose entries are kept while the program is disabled and restored when it is enabled again
+	NodeSelector      []string             `json:"node_selector"`       // Expressions on the node labels e.g. region=us-west or kernel>=5.10, the program is applied on the nodes matching all of them
+	ArgSchemaVersion  int                  `json:"arg_schema_version"`  // Contract of the args l3afd passes to the start and stop commands, 1 flags, 2 JSON document on stdin, 3 control socket, 1 when 0
+	PassFDs           []L3afDNFPassedFD    `json:"pass_fds"`            // Pinned maps and programs opened by l3afd and inherited by the user program, so it needs no CAP_BPF to open them
+	Watchdog          *L3afDNFWatchdog     `json:"watchdog"`            // Resource thresholds of the user program and the action taken when one is exceeded
+	Heartbeat         *L3afDNFHeartbeat    `json:"heartbeat"`           // Map l3afd writes its liveness timestamp to, the kernel program fails open or closed when it is stale
+	OnFailure         string               `json:"on_failure"`          // fail-closed keeps the kernel program in the chain when the user process dies, fail-open bypasses it until the process is restarted, fail-closed when empty
+	Group             string               `json:"group"`               // Apply group of the programs of the interface applied atomically, in all their directions or in none
+	ResetCounters     []L3afDNFCounter     `json:"reset_counters"`      // Map counters zeroed by the stats reset of the program
+	BlueGreen         *L3afDNFBlueGreen    `json:"blue_green"`          // Version upgrades start the new version alongside the running one and swap it into the chain, stop then start when nil
+	Shadow            *L3afDNFShadow       `json:"shadow"`              // Candidate version evaluated on copies of the packets of the program, its verdicts are ignored
+	RequiresRoot      []string             `json:"requires_root"`       // Capabilities the root program of the interface must provide e.g. hw-offload
+	XDPMode           string               `json:"xdp_mode"`            // XDP attach mode generic, native or offload of the XDP program, mode of the loader of the program when empty
+	OffloadFallback   string               `json:"offload_fallback"`    // Mode native or generic the program of the offload XDP mode is started in on interfaces not supporting offload, or fail, xdp-offload fallback of l3afd.cfg when empty
+	Queues            *L3afDNFQueues       `json:"queues"`              // RX queues of the NIC the program serves, all the queues when nil
+	Tasks             []L3afDNFTask        `json:"tasks"`               // Commands of the artifact l3afd runs periodically while the program is running
 }
 
 // L3afDNFMetricsMap defines BPF map
 type L3afDNFMetricsMap struct {
-	Name       string `json:"name"`       // BPF map name
-	Key        int    `json:"key"`        // Index of the bpf map
-	Aggregator string `json:"aggregator"` // Aggregation function names
+	Name       string                `json:"name"`       // BPF map name
+	Key        int                   `json:"key"`        // Index of the bpf map
+	Keys       string                `json:"keys"`       // Key ranges e.g. 0-15 or 0-3,8, or * for all the keys of the map, overrides key
+	Aggregator string                `json:"aggregator"` // Aggregation function names
+	Value      []L3afDNFMetricsField `json:"value"`      // Fields of the struct value, one metric per field, the value is an integer by default
+}
+
+// L3afDNFMetricsField defines a field of the struct value of a metrics BPF map
+type L3afDNFMetricsField struct {
+	Name   string `json:"name"`   // Field name, appended to the metric name
+	Offset int    `json:"offset"` // Byte offset of the field in the value
+	Type   string `json:"type"`   // u8, u16, u32, u64, be16, be32 or be64
+}
+
+// L3afDNFCounter defines counters of a BPF map of the program the stats reset zeroes
+type L3afDNFCounter struct {
+	Map  string `json:"map"`  // BPF map name, pinned path for TC programs
+	Keys string `json:"keys"` // Keys and key ranges e.g. 0-15 or 0-3,8, or * for all the keys of the map
+}
+
+// L3afDNFBlueGreen defines the blue/green version upgrades of the program
+type L3afDNFBlueGreen struct {
+	MapName         string `json:"map_name"`         // BPF map to store next program fd of the version running in the green slot
+	ValidateSamples int    `json:"validate_samples"` // Packets test run through the new version before the swap, sampled by the packet tap of the interface when running, the test runs update the maps of the program, no validation when 0
+}
+
+// L3afDNFShadow defines the candidate version of the program evaluated in shadow mode
+type L3afDNFShadow struct {
+	Version  string `json:"version"`  // Candidate version of the program
+	Artifact string `json:"artifact"` // Artifact of the candidate version, the artifact of the program when empty
+	MapName  string `json:"map_name"` // BPF map to store next program fd of the candidate, pinned apart from the map of the program
+	Samples  int    `json:"samples"`  // Packets run through both versions at each evaluation, sampled by the packet tap of the interface when running, 16 when 0
+	Interval string `json:"interval"` // Interval of the evaluations e.g. 30s, 10s when empty
+}
+
+// L3afDNFQueues defines the RX queues of the NIC the program serves, for the programs sharding on the RX queue
+type L3afDNFQueues struct {
+	QueueIDs         []int `json:"queue_ids"`          // RX queues of the interface served by the program, the packets of the other queues are passed to the next program
+	InstancePerQueue bool  `json:"instance_per_queue"` // An instance of the user program is started per queue serving its queue only, the instances of the queues after the first are not chained
+}
+
+// L3afDNFTask defines a maintenance command of the artifact l3afd runs periodically while the program is running
+type L3afDNFTask struct {
+	Name     string            `json:"name"`     // Name of the task, unique in the program
+	Command  string            `json:"command"`  // Command of the artifact relative to the artifact directory
+	Args     map[string]string `json:"args"`     // Args of the command passed as --key=value after the --iface and --direction flags
+	Interval string            `json:"interval"` // Interval of the runs e.g. 1h
+	Jitter   string            `json:"jitter"`   // Random delay up to the jitter added to each interval e.g. 5m, spreading the runs of the fleet, none when empty
+	Timeout  string            `json:"timeout"`  // Timeout of a run e.g. 30s, task-timeout of l3afd.cfg when empty
 }
 
+// L3afDNFConsumedMap defines pinned map shared by another program
+type L3afDNFConsumedMap struct {
+	Name    string `json:"name"`     // Logical name of the map shared by the program
+	Program string `json:"program"`  // Name of the program sharing the map
+	Arg     string `json:"arg"`      // Start argument to pass the pinned map path
+	PinPath string `json:"pin_path"` // Optional path to re-pin the map for this program, consumed-map-dir of l3afd.cfg by default
+}
+
+// L3afDNFTestVector defines test packet and expected verdict of the program
+type L3afDNFTestVector struct {
+	Name    string `json:"name"`    // Test vector name
+	Program string `json:"program"` // eBPF program name in the object, first program of the program type by default
+	Packet  string `json:"packet"`  // Input packet in hex including ethernet header
+	Verdict string `json:"verdict"` // Expected verdict name e.g. XDP_DROP, TC_ACT_SHOT or return code
+}
+
+// Actions of the watchdog of the user program
+const (
+	WatchdogActionLog     = "log"
+	WatchdogActionRestart = "restart"
+	WatchdogActionBypass  = "bypass"
+	WatchdogActionAlert   = "alert"
+)
+
+// L3afDNFWatchdog defines resource thresholds of the user program evaluated by the process usage monitoring
+type L3afDNFWatchdog struct {
+	MaxRSS        int64   `json:"max_rss"`         // Bytes of resident memory, no limit when 0
+	MaxCPUPercent float64 `json:"max_cpu_percent"` // CPU usage over the monitoring interval in percent of a CPU, no limit when 0
+	MaxRestarts   int     `json:"max_restarts"`    // Restarts by the process monitoring in the restart window, no limit when 0
+	RestartWindow string  `json:"restart_window"`  // Window of the max restarts e.g. 30m, 1h when empty
+	Action        string  `json:"action"`          // log, restart, bypass or alert, log when empty
+}
+
+// Policies of the kernel program of the chain when its user process dies
+const (
+	OnFailureFailOpen   = "fail-open"
+	OnFailureFailClosed = "fail-closed"
+)
+
+// Behaviors of the kernel program when the heartbeat of l3afd is stale
+const (
+	HeartbeatFailOpen   = "fail-open"
+	HeartbeatFailClosed = "fail-closed"
+)
+
+// L3afDNFHeartbeat defines the heartbeat entry l3afd writes periodically, so the kernel program detects l3afd and
+// its policy updates are gone. The value is {u64 timestamp_ns; u64 timeout_ns; u32 behavior; u32 pad}, the
+// timestamp of CLOCK_MONOTONIC as bpf_ktime_get_ns and the behavior 0 fail-open or 1 fail-closed.
+type L3afDNFHeartbeat struct {
+	MapName  string `json:"map_name"` // Array or hash map of the heartbeat, pinned path for TC programs
+	Key      int    `json:"key"`      // u32 key of the heartbeat entry
+	Interval string `json:"interval"` // Interval of the heartbeat writes e.g. 5s, l3afd default when empty
+	Timeout  string `json:"timeout"`  // Age of the heartbeat the program considers l3afd gone e.g. 30s, 3 intervals when empty
+	Behavior string `json:"behavior"` // fail-open or fail-closed, fail-open when empty
+}
+
+// Types of the pinned objects passed to the user program
+const (
+	PassFDMap     = "map"
+	PassFDProgram = "program"
+)
+
+// L3afDNFPassedFD defines a pinned map or program whose fd is inherited by the user program
+type L3afDNFPassedFD struct {
+	Name    string `json:"name"`     // Start argument passing the fd number, name of the fd on the control socket
+	Type    string `json:"type"`     // map or program
+	PinPath string `json:"pin_path"` // Pin path of the map or program
+}
+
+// L3afDNFAFXDP defines AF_XDP sockets of the user program
+type L3afDNFAFXDP struct {
+	XSKMapName string `json:"xsk_map_name"` // Pinned xsk map the root program redirects the packets to
+	QueueIDs   []int  `json:"queue_ids"`    // Interface queues served by the user program sockets
+}
+
+// FleetHostName is the host name of the configs of a fleet config applied by every node
+const FleetHostName = "*"
+
 // L3afBPFPrograms defines configs for a node
 type L3afBPFPrograms struct {
-	HostName    string       `json:"host_name"`    // Host name or pod name
-	Iface       string       `json:"iface"`        // Interface name
-	BpfPrograms *BPFPrograms `json:"bpf_programs"` // List of bpf programs
+	HostName    string       `json:"host_name"`          // Host name or pod name
+	Iface       string       `json:"iface"`              // Interface name
+	BpfPrograms *BPFPrograms `json:"bpf_programs"`       // List of bpf programs
+	Template    string       `json:"template,omitempty"` // Chain template of l3afd.cfg the programs override
 }
 
 // BPFPrograms for a node
@@ -71,3 +257,422 @@ type BPFPrograms struct {
 	TCIngress  []*BPFProgram `json:"tc_ingress"`  // list of tc ingress bpf programs
 	TCEgress   []*BPFProgram `json:"tc_egress"`   // list of tc egress bpf programs
 }
+
+// L3afDTap defines packet tap of an interface
+type L3afDTap struct {
+	Iface      string `json:"iface"`       // Interface name
+	SampleRate int    `json:"sample_rate"` // Capture one of every sample_rate packets, all packets by default
+	Filter     string `json:"filter"`      // Classic BPF filter in tcpdump -ddd format joined by commas
+	PcapFile   string `json:"pcap_file"`   // Pcap file name in the tap pcap-dir of l3afd.cfg to write the packets
+	Collector  string `json:"collector"`   // Collector address to stream the packets in pcap format, one of the tap collectors of l3afd.cfg
+}
+
+// L3afDNodeFacts defines node facts reported to the control plane in the heartbeat
+type L3afDNodeFacts struct {
+	HostName         string            `json:"host_name"`         // Host name
+	L3afdVersion     string            `json:"l3afd_version"`     // l3afd version
+	KernelRelease    string            `json:"kernel_release"`    // Kernel release e.g. 5.15.0-76-generic
+	Platform         string            `json:"platform"`          // Linux distribution code name
+	Arch             string            `json:"arch"`              // CPU architecture
+	BTFAvailable     bool              `json:"btf_available"`     // BTF of the running kernel is available for CO-RE programs
+	ChainingEnabled  bool              `json:"chaining_enabled"`  // Programs are chained with the root programs
+	Labels           map[string]string `json:"labels"`            // Labels of the node matched by the node selectors of the programs
+	Interfaces       []L3afDIfaceFacts `json:"interfaces"`        // Network interfaces of the node
+	NetworkFunctions []L3afDNFFacts    `json:"network_functions"` // Configured network functions
+}
+
+// L3afDIfaceFacts defines facts of a network interface
+type L3afDIfaceFacts struct {
+	Name         string   `json:"name"`          // Interface name
+	Driver       string   `json:"driver"`        // NIC driver name
+	XDPModes     []string `json:"xdp_modes"`     // XDP attach modes supported by the driver, generic, native and offload
+	XDPAttached  []string `json:"xdp_attached"`  // XDP modes a program is attached in on the interface
+	MTU          int      `json:"mtu"`           // Interface MTU
+	HardwareAddr string   `json:"hardware_addr"` // Interface MAC address
+}
+
+// L3afDNFFacts defines a configured network function of the node
+type L3afDNFFacts struct {
+	Iface       string `json:"iface"`              // Interface name
+	Direction   string `json:"direction"`          // xdpingress, ingress or egress
+	Name        string `json:"name"`               // Name of the BPF program
+	Version     string `json:"version"`            // Program version
+	SeqID       int    `json:"seq_id"`             // Sequence position in the chain
+	AdminStatus string `json:"admin_status"`       // Program admin status enabled, disabled or standby
+	XDPMode     string `json:"xdp_mode,omitempty"` // XDP mode offload, native or generic the program runs in, omitted for TC programs
+}
+
+// L3afDPeeringStatus defines peering state of the node in the active/standby pair
+type L3afDPeeringStatus struct {
+	Enabled      bool   `json:"enabled"`       // Peering is enabled
+	Role         string `json:"role"`          // active or standby
+	PeerURL      string `json:"peer_url"`      // Config API url of the active peer followed by the standby
+	LastSeen     string `json:"last_seen"`     // Last time the configs of the active peer are fetched
+	PeerPrograms int    `json:"peer_programs"` // Number of programs configured on the active peer
+	Prepared     bool   `json:"prepared"`      // Artifacts of the active peer configs are downloaded and validated
+	Error        string `json:"error"`         // Last error of following the active peer or activation
+}
+
+// L3afDFault defines a fault injected into the NF lifecycle for testing
+type L3afDFault struct {
+	Type      string `json:"type"`      // fail-download, delay-map-pin or kill-nf
+	Program   string `json:"program"`   // Name of the BPF program
+	Iface     string `json:"iface"`     // Interface name of the program to kill
+	Direction string `json:"direction"` // Direction of the program to kill
+	Delay     int    `json:"delay"`     // Map pin delay in seconds
+	Count     int    `json:"count"`     // Number of lifecycle operations the fault is applied to, 1 by default
+}
+
+// L3afDMapEntry defines an entry of the eBPF map dump, key and value are hex encoded
+type L3afDMapEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// L3afDRulesPatch defines the rule entries added to and removed from the rules of a program, one entry per line
+type L3afDRulesPatch struct {
+	Add    []string `json:"add"`    // Entries appended to the rules, existing entries are skipped
+	Remove []string `json:"remove"` // Entries removed from the rules, missing entries are skipped
+}
+
+// L3afDRulesPatchResult defines the rule entries changed by a rules patch
+type L3afDRulesPatchResult struct {
+	Added   []string `json:"added"`   // Entries added to the rules
+	Removed []string `json:"removed"` // Entries removed from the rules
+	Rules   int      `json:"rules"`   // Entries of the rules after the patch
+}
+
+// L3afDMapEntryUpdate defines the typed value of a single map entry update and the type of the key
+type L3afDMapEntryUpdate struct {
+	KeyType   string `json:"key_type"`   // u8, u16, u32, u64, be16, be32, be64, ipv4, ipv6, mac or hex
+	ValueType string `json:"value_type"` // u8, u16, u32, u64, be16, be32, be64, ipv4, ipv6, mac or hex
+	Value     string `json:"value"`      // Value of the entry
+}
+
+// L3afDNFStatus defines the structured status printed in JSON by the status command of the program
+type L3afDNFStatus struct {
+	State    string             `json:"state"`    // RUNNING when the program is healthy
+	Version  string             `json:"version"`  // Version reported by the program
+	Counters map[string]float64 `json:"counters"` // Counters reported by the program
+	Message  string             `json:"message"`  // Health detail reported by the program
+}
+
+// L3afDNFBuildInfo defines the build manifest packaged in the artifact of the program
+type L3afDNFBuildInfo struct {
+	Commit       string `json:"build_commit"`  // Source commit the artifact is built from
+	Compiler     string `json:"compiler"`      // Compiler of the user program
+	ClangVersion string `json:"clang_version"` // Clang version compiling the eBPF objects
+	BuildDate    string `json:"build_date"`    // Build date of the artifact
+}
+
+// L3afDArtifact defines an artifact of a program version to prefetch into the artifact cache
+type L3afDArtifact struct {
+	Name     string `json:"name"`     // Name of the BPF program
+	Version  string `json:"version"`  // Program version
+	Artifact string `json:"artifact"` // Artifact file name
+	Platform string `json:"platform"` // Platform of the artifact, platform of the node by default
+}
+
+// L3afDPrefetchResult defines the result of an artifact prefetch
+type L3afDPrefetchResult struct {
+	Name     string `json:"name"`     // Name of the BPF program
+	Version  string `json:"version"`  // Program version
+	Artifact string `json:"artifact"` // Artifact file name
+	Cached   bool   `json:"cached"`   // Artifact was already in the cache
+	Error    string `json:"error"`    // Error of the download, empty on success
+}
+
+// L3afDPausedProgram defines a program detached from the chain and stopped by the admin API
+type L3afDPausedProgram struct {
+	Iface     string `json:"iface"`     // Interface name
+	Direction string `json:"direction"` // Direction xdpingress, ingress or egress
+	Name      string `json:"name"`      // Name of the BPF program
+}
+
+// L3afDApplyGeneration defines the last applied config generation and the result of the apply
+type L3afDApplyGeneration struct {
+	Generation uint64 `json:"generation"`  // Generation of the config push
+	StatusCode int    `json:"status_code"` // HTTP status code of the apply
+	Message    string `json:"message"`     // Response message of the apply
+	AppliedAt  string `json:"applied_at"`  // Time of the apply in RFC 3339 format
+}
+
+// L3afDNFIncident defines a crash of the NF process captured in an incident bundle
+type L3afDNFIncident struct {
+	ID           string `json:"id"`            // Incident id, name of the bundle
+	Iface        string `json:"iface"`         // Interface name
+	Direction    string `json:"direction"`     // Direction xdpingress, ingress or egress
+	Name         string `json:"name"`          // Name of the BPF program
+	Version      string `json:"version"`       // Program version
+	Time         string `json:"time"`          // Time the crash is detected in RFC 3339 format
+	ExitStatus   string `json:"exit_status"`   // Exit status e.g. exit status 1 or signal: segmentation fault
+	ExitCode     int    `json:"exit_code"`     // Exit code, -1 when killed by a signal or unknown
+	RestartCount int    `json:"restart_count"` // Restarts of the program before the crash
+}
+
+// Link states of the interfaces in the config
+const (
+	LinkAttached       = "attached"
+	LinkWaitingForLink = "waiting for link"
+)
+
+// L3afDLinkStatus defines the link state of an interface in the config
+type L3afDLinkStatus struct {
+	Iface    string `json:"iface"`     // Interface name
+	Status   string `json:"status"`    // attached or waiting for link
+	QueuedAt string `json:"queued_at"` // Time the config was queued for the link in RFC 3339 format, empty when attached
+}
+
+// States of the programs in the effective config
+const (
+	EffectiveRunning        = "running"
+	EffectiveRoot           = "root"
+	EffectiveBypassed       = "bypassed"
+	EffectivePaused         = "paused"
+	EffectiveStandby        = "standby"
+	EffectiveWaitingForLink = "waiting for link"
+)
+
+// L3afDEffectiveConfig defines the config l3afd acts on for an interface
+type L3afDEffectiveConfig struct {
+	HostName    string                  `json:"host_name"`    // Host name
+	Iface       string                  `json:"iface"`        // Interface name
+	Platform    string                  `json:"platform"`     // Platform of the artifact downloads
+	LinkStatus  string                  `json:"link_status"`  // attached or waiting for link
+	BpfPrograms []L3afDEffectiveProgram `json:"bpf_programs"` // Programs in chain order per direction followed by the paused programs
+}
+
+// L3afDEffectiveProgram defines a program of the effective config with the defaults resolved
+type L3afDEffectiveProgram struct {
+	Direction      string               `json:"direction"`                 // xdpingress, ingress or egress
+	State          string               `json:"state"`                     // running, root, bypassed, paused or waiting for link
+	ArtifactURL    string               `json:"artifact_url"`              // Download url of the artifact
+	FilePath       string               `json:"file_path"`                 // Dir of the extracted artifact, empty when not started
+	Program        BPFProgram           `json:"program"`                   // Config with the assigned seq id and the l3afd defaults of the unset fields
+	PendingUpdate  *BPFProgram          `json:"pending_update"`            // Update queued until the apply window opens, null when none
+	XDPMode        string               `json:"xdp_mode,omitempty"`        // XDP mode offload, native or generic the program runs in, omitted for TC programs
+	QueueInstances []L3afDQueueInstance `json:"queue_instances,omitempty"` // Per-queue instances of the program started per queue, the program serving the first queue
+	Tasks          []L3afDTaskStatus    `json:"tasks,omitempty"`           // Scheduled tasks of the running program
+	MapMemory      uint64               `json:"map_memory,omitempty"`      // Declared BPF map memory of the eBPF objects of the running program in bytes
+	MemlockBytes   uint64               `json:"memlock_bytes,omitempty"`   // Locked memory reserved for the maps of the running program under the managed memlock limit in bytes
+}
+
+// L3afDQueueInstance defines the instance of a program serving an RX queue
+type L3afDQueueInstance struct {
+	QueueID int  `json:"queue_id"` // RX queue served by the instance
+	Running bool `json:"running"`  // Instance is running
+	PID     int  `json:"pid"`      // Process id of the instance, 0 when not running
+}
+
+// Results of the runs of the scheduled tasks
+const (
+	TaskResultSuccess = "success"
+	TaskResultFailure = "failure"
+	TaskResultTimeout = "timeout"
+	TaskResultSkipped = "skipped"
+)
+
+// L3afDTaskStatus defines the state of a scheduled task of a program
+type L3afDTaskStatus struct {
+	Name       string `json:"name"`        // Name of the task
+	Running    bool   `json:"running"`     // Task is running
+	LastRun    string `json:"last_run"`    // Start time of the last run in RFC 3339 format, empty before the first run
+	LastResult string `json:"last_result"` // success, failure or timeout of the last run, skipped when the previous run had not completed, empty before the first run
+	NextRun    string `json:"next_run"`    // Time of the next run in RFC 3339 format
+}
+
+// Changes of the programs between two config revisions
+const (
+	ConfigChangeAdded   = "added"
+	ConfigChangeRemoved = "removed"
+	ConfigChangeChanged = "changed"
+)
+
+// L3afDConfigRevision defines an applied config of the config history
+type L3afDConfigRevision struct {
+	Revision  uint64            `json:"revision"`          // Revision of the config history, incremented by every apply changing the config
+	AppliedAt string            `json:"applied_at"`        // Time of the apply in RFC 3339 format
+	Configs   []L3afBPFPrograms `json:"configs,omitempty"` // Applied configs, omitted in the history list
+}
+
+// L3afDConfigDiff defines the changes of the programs between two config revisions
+type L3afDConfigDiff struct {
+	From    uint64              `json:"from"`    // Revision compared from
+	To      uint64              `json:"to"`      // Revision compared to
+	Changes []L3afDConfigChange `json:"changes"` // Changed programs sorted by interface, direction and name
+}
+
+// L3afDConfigChange defines a change of a program between two config revisions
+type L3afDConfigChange struct {
+	Iface     string             `json:"iface"`            // Interface name
+	Direction string             `json:"direction"`        // xdpingress, ingress or egress
+	Name      string             `json:"name"`             // Program name
+	Change    string             `json:"change"`           // added, removed or changed
+	Fields    []L3afDFieldChange `json:"fields,omitempty"` // Changed fields of the program
+}
+
+// L3afDFieldChange defines a changed field of a program
+type L3afDFieldChange struct {
+	Field string          `json:"field"` // JSON name of the field
+	From  json.RawMessage `json:"from"`  // Value in the revision compared from, null when unset
+	To    json.RawMessage `json:"to"`    // Value in the revision compared to, null when unset
+}
+
+// Statuses of the diagnostics checks
+const (
+	DoctorPass = "pass"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+)
+
+// L3afDIfaceNUMA defines the NUMA node of the NIC of an interface
+type L3afDIfaceNUMA struct {
+	Iface    string `json:"iface"`     // Interface name
+	NUMANode int    `json:"numa_node"` // NUMA node of the NIC, -1 when the NIC has no NUMA node
+	CPUs     string `json:"cpus"`      // CPUs of the NUMA node e.g. 0-11,24-35
+	Error    string `json:"error"`     // Error of the discovery
+}
+
+// L3afDDoctorReport defines the report of the preflight diagnostics of the node
+type L3afDDoctorReport struct {
+	HostName string             `json:"host_name"` // Host name
+	Time     string             `json:"time"`      // Time of the diagnostics in RFC 3339 format
+	Status   string             `json:"status"`    // Worst status of the checks, pass, warn or fail
+	Checks   []L3afDDoctorCheck `json:"checks"`    // Checks in the order they are run
+}
+
+// L3afDDoctorCheck defines a check of the preflight diagnostics
+type L3afDDoctorCheck struct {
+	Name    string `json:"name"`    // bpffs, kernel, btf, capabilities, memlock, iface <name>, kf-repo or disk <dir>
+	Status  string `json:"status"`  // pass, warn or fail
+	Message string `json:"message"` // Finding of the check
+}
+
+// L3afDFreeze defines the config freeze state, mutating config operations are rejected while frozen
+type L3afDFreeze struct {
+	Frozen bool   `json:"frozen"` // Config is frozen
+	Reason string `json:"reason"` // Reason of the freeze e.g. the incident id
+	Since  string `json:"since"`  // Time the config was frozen in RFC 3339 format, empty when not frozen
+	By     string `json:"by"`     // Remote address of the admin or config when frozen by the config file
+}
+
+// L3afDPendingApply defines a disruptive update of a program queued until its apply window opens
+type L3afDPendingApply struct {
+	Iface       string `json:"iface"`        // Interface name
+	Direction   string `json:"direction"`    // Direction of the program
+	Name        string `json:"name"`         // Program name
+	Version     string `json:"version"`      // Queued version
+	SeqID       int    `json:"seq_id"`       // Queued seq id
+	ApplyWindow string `json:"apply_window"` // Window the update waits for
+	QueuedAt    string `json:"queued_at"`    // Time the update was queued in RFC 3339 format
+}
+
+// L3afDNotification defines a state change notification posted to the webhook targets
+type L3afDNotification struct {
+	ID        string `json:"id"`                  // Delivery id, the same for the retries of the notification
+	Time      string `json:"time"`                // Time of the state change in RFC 3339 format
+	Host      string `json:"host"`                // Host name of the node
+	Event     string `json:"event"`               // program-failed, program-restarted, program-bypassed, program-watchdog, chain-repaired or apply-failed
+	Iface     string `json:"iface,omitempty"`     // Interface name
+	Direction string `json:"direction,omitempty"` // Direction of the program
+	Program   string `json:"program,omitempty"`   // Program name
+	Message   string `json:"message"`             // Details of the state change
+}
+
+// L3afDLogLevels defines the level of the l3afd logs and the level overrides of the modules
+type L3afDLogLevels struct {
+	Level   string            `json:"level"`   // Level of the logs i.e. trace, debug, info, warn, error, fatal or panic
+	Modules map[string]string `json:"modules"` // Level overrides of the kf, apis and stats modules, an empty level removes the override
+}
+
+// L3afDLogLevel defines the level of the l3afd logs and the programs with debug enabled
+type L3afDLogLevel struct {
+	Level         string   `json:"level"`          // Level of the logs, unchanged when empty
+	DebugPrograms []string `json:"debug_programs"` // Programs whose map lookups and chain updates are logged regardless of the level, unchanged when absent
+}
+
+// Kinds of the edges of the chain graph
+const (
+	ChainEdge     = "chain"      // Root or previous program tail calls the program through the prog map
+	SharedMapEdge = "shared-map" // Program uses the pinned map shared by the other program
+)
+
+// L3afDMetricSample defines a sampled value of a monitor map metric
+type L3afDMetricSample struct {
+	Time  string  `json:"time"`  // Time of the sample in RFC 3339 format
+	Value float64 `json:"value"` // Aggregated value of the monitor map
+}
+
+// L3afDMetricHistory defines the retained samples of a monitor map metric of a program
+type L3afDMetricHistory struct {
+	Program string              `json:"program"` // Program name
+	Metric  string              `json:"metric"`  // Metric name of the monitor map i.e. <map>_<key>_<aggregator>[_<field>]
+	Samples []L3afDMetricSample `json:"samples"` // Samples oldest first
+}
+
+// L3afDChainGraph defines the graph of the programs chained on an interface and the maps linking them
+type L3afDChainGraph struct {
+	Iface string                `json:"iface"` // Interface name
+	Nodes []L3afDChainGraphNode `json:"nodes"` // Programs in the chain order of the directions
+	Edges []L3afDChainGraphEdge `json:"edges"` // Maps linking the programs
+}
+
+// L3afDChainGraphNode defines a program of the chain graph
+type L3afDChainGraphNode struct {
+	ID        string `json:"id"`               // Node id, <direction>/<program name>
+	Name      string `json:"name"`             // Program name
+	Direction string `json:"direction"`        // Direction of the chain, xdpingress, ingress or egress
+	SeqID     int    `json:"seq_id"`           // Sequence position in the chain
+	ProgID    int    `json:"prog_id"`          // eBPF program ID, 0 when not loaded
+	Version   string `json:"version"`          // Program version
+	State     string `json:"state"`            // root, running or bypassed
+	Flavor    string `json:"flavor,omitempty"` // Root program flavor of the interface, set on the root node of the interface of a flavor
+}
+
+// L3afDChainGraphEdge defines a map linking two programs of the chain graph
+type L3afDChainGraphEdge struct {
+	From string `json:"from"` // Node id of the program owning the map
+	To   string `json:"to"`   // Node id of the program reached through or using the map
+	Map  string `json:"map"`  // Pinned prog map of the chain or logical name of the shared map
+	Kind string `json:"kind"` // chain or shared-map
+}
+
+// L3afDNFImplicitArgs defines the args l3afd passes to the start and stop commands of a program of the arg
+// schema version 2 as a JSON document on stdin, and of the arg schema version 3 over the control socket
+type L3afDNFImplicitArgs struct {
+	SchemaVersion int               `json:"schema_version"`           // Arg schema version of the document
+	Command       string            `json:"command"`                  // start or stop
+	Iface         string            `json:"iface"`                    // Interface name
+	Direction     string            `json:"direction"`                // xdpingress, ingress or egress
+	MapName       string            `json:"map_name,omitempty"`       // Prog map of the previous program in the chain to insert the program fd
+	LogDir        string            `json:"log_dir,omitempty"`        // Log dir of the program
+	BTFPath       string            `json:"btf_path,omitempty"`       // BTF of the running kernel when not the kernel BTF
+	RulesFile     string            `json:"rules_file,omitempty"`     // Rules file of the program
+	DefaultArgs   map[string]string `json:"default_args,omitempty"`   // Default args of the direction in l3afd.cfg
+	ControlSocket string            `json:"control_socket,omitempty"` // Control socket of the program of the arg schema version 3
+	Slot          string            `json:"slot,omitempty"`           // green when the program runs in the green slot of a blue/green upgrade, its maps are pinned apart from the blue slot
+	XDPMode       string            `json:"xdp_mode,omitempty"`       // XDP mode generic, native or offload the program attaches or loads its programs in
+	Ifindex       int               `json:"ifindex,omitempty"`        // Index of the interface, the offloaded programs are loaded for the device of the index
+	RxQueues      []int             `json:"rx_queues,omitempty"`      // RX queues served by the program, or by the instance of the queue
+}
+
+// Types of the messages of the control socket
+const (
+	ControlMessageConfig = "config" // sent on connect along with the map fds
+	ControlMessageUpdate = "update" // sent when the config is updated without a restart
+)
+
+// L3afDControlMessage defines a message l3afd sends to a program of the arg schema version 3 over its control
+// socket, the map fds are passed with the message as SCM_RIGHTS
+type L3afDControlMessage struct {
+	Type    string              `json:"type"`              // config or update
+	Args    L3afDNFImplicitArgs `json:"args"`              // Implicit args of the program
+	Program BPFProgram          `json:"program"`           // Full config of the program
+	MapFDs  []string            `json:"map_fds,omitempty"` // Names of the map and program fds passed with the message, in the order of the fds
+}
+
+// L3afDStatsReset defines the result of a stats reset
+type L3afDStatsReset struct {
+	Programs []string `json:"programs"` // Programs reset as iface/direction/program
+	Counters int      `json:"counters"` // Map counters zeroed
+}
@@ -0,0 +1,45 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package routes
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AllowCIDRs returns a handler rejecting the requests from source addresses outside of the CIDRs,
+// all the sources are allowed when the CIDRs are empty
+func AllowCIDRs(cidrs []*net.IPNet, next http.Handler) http.Handler {
+	if len(cidrs) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !sourceAllowed(cidrs, r.RemoteAddr) {
+			log.Warn().Msgf("request %s %s from %s rejected, source is not in the allowed CIDRs", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sourceAllowed - remote address of the connection is in the CIDRs, forwarded headers are not trusted
+func sourceAllowed(cidrs []*net.IPNet, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
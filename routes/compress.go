@@ -0,0 +1,111 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package routes
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Compress returns a handler accepting gzip request bodies and compressing the responses of the clients
+// accepting gzip. Decompressed request bodies fail to read beyond max bytes, zero max means unlimited.
+func Compress(max int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				log.Warn().Err(err).Msgf("request %s %s from %s rejected, invalid gzip body", r.Method, r.URL.Path, r.RemoteAddr)
+				http.Error(w, "invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			defer zr.Close()
+			r.Body = io.NopCloser(zr)
+			if max > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, max)
+			}
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1
+		}
+
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip - Accept-Encoding header lists gzip without zero quality
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		params := strings.Split(coding, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "gzip") {
+			continue
+		}
+		for _, p := range params[1:] {
+			if q := strings.TrimSpace(p); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter - compresses the response body, responses without body and responses already
+// compressed by the handler are passed through
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	contentType := h.Get("Content-Type")
+	passThrough := code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified ||
+		len(h.Get("Content-Encoding")) > 0 || contentType == "application/gzip" || contentType == "application/x-gzip"
+	if !passThrough {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if len(w.Header().Get("Content-Type")) == 0 {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// close - flushes the compressed body
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			log.Warn().Err(err).Msg("failed to flush the compressed response")
+		}
+	}
+}
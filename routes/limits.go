@@ -0,0 +1,114 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LimitBodySize returns a handler rejecting the request bodies larger than max bytes, bodies without
+// content length fail to read beyond max bytes. Zero max means unlimited.
+func LimitBodySize(max int64, next http.Handler) http.Handler {
+	if max <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			log.Warn().Msgf("request %s %s from %s rejected, body size %d exceeds %d bytes", r.Method, r.URL.Path, r.RemoteAddr, r.ContentLength, max)
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenBucket - rate limiter refilled with rate tokens per second up to burst tokens
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// take - takes a token, returns the time until the next token when the bucket is empty
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// RateLimit returns a handler limiting the requests changing the state, i.e. other than GET and HEAD,
+// to rate per second with bursts of burst requests. Zero rate means unlimited.
+func RateLimit(rate float64, burst int, next http.Handler) http.Handler {
+	if rate <= 0 {
+		return next
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	bucket := &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, wait := bucket.take(time.Now()); !ok {
+			log.Warn().Msgf("request %s %s from %s rejected, rate limit exceeded", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ApplyGate serializes the config applies, overlapping applies are rejected instead of queued
+type ApplyGate chan struct{}
+
+// NewApplyGate returns the gate shared by the handlers applying configs
+func NewApplyGate() ApplyGate {
+	return make(ApplyGate, 1)
+}
+
+// Wrap returns a handler rejecting the request with 409 Conflict while another apply is in progress
+func (g ApplyGate) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case g <- struct{}{}:
+			defer func() { <-g }()
+			next(w, r)
+		default:
+			log.Warn().Msgf("request %s %s from %s rejected, another config apply is in progress", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "another config apply is in progress", http.StatusConflict)
+		}
+	}
+}
+
+// FreezeGate rejects the mutating requests while the config is frozen, the func returns the rejection
+type FreezeGate func(r *http.Request) error
+
+// Wrap returns a handler rejecting the request with 423 Locked when the gate returns an error
+func (g FreezeGate) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := g(r); err != nil {
+			http.Error(w, err.Error(), http.StatusLocked)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+//
+//go:build !WINDOWS
+// +build !WINDOWS
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/l3af-project/l3afd/config"
+)
+
+// startService - l3afd runs under the init system, the shutdown signals are handled by the config watcher
+func startService(conf *config.Config, shutdown func() uint32) (started func()) {
+	return func() {}
+}
+
+// manageService - service install is left to the init system e.g. the systemd unit
+func manageService(action, confPath string) error {
+	return fmt.Errorf("service %s is only supported on windows", action)
+}
@@ -0,0 +1,173 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+//
+//go:build WINDOWS
+// +build WINDOWS
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// startPendingWaitHint - time the SCM waits for l3afd to report running, the programs of the config store
+// are started before
+const startPendingWaitHint = 5 * time.Minute
+
+// Event ids of the event log entries
+const (
+	eventIDInfo uint32 = iota + 1
+	eventIDWarning
+	eventIDError
+)
+
+// windowsService - SCM lifecycle of l3afd, stop and shutdown are accepted once l3afd is running
+type windowsService struct {
+	running  chan struct{}
+	shutdown func() uint32
+}
+
+func (s *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending, WaitHint: uint32(startPendingWaitHint / time.Millisecond)}
+	for {
+		select {
+		case <-s.running:
+			s.running = nil
+			changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+			log.Info().Msgf("%s service is running", daemonName)
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Info().Msgf("%s service stop requested", daemonName)
+				changes <- svc.Status{State: svc.StopPending}
+				return false, s.shutdown()
+			}
+		}
+	}
+}
+
+// startService - runs the service control dispatcher when l3afd is started by the SCM and sends the logs to
+// the event log, started reports l3afd running to the SCM
+func startService(conf *config.Config, shutdown func() uint32) (started func()) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return func() {}
+	}
+
+	if elog, err := eventlog.Open(daemonName); err != nil {
+		log.Warn().Err(err).Msg("failed to open the event log")
+	} else {
+		level, err := zerolog.ParseLevel(conf.EventLogLevel)
+		if err != nil {
+			level = zerolog.WarnLevel
+		}
+		log.Logger = log.Output(zerolog.MultiLevelWriter(
+			zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339Nano},
+			&eventLogWriter{elog: elog, level: level}))
+	}
+
+	s := &windowsService{running: make(chan struct{}), shutdown: shutdown}
+	go func() {
+		if err := svc.Run(daemonName, s); err != nil {
+			log.Fatal().Err(err).Msgf("%s service failed", daemonName)
+		}
+		log.Info().Msgf("%s service stopped", daemonName)
+		os.Exit(0)
+	}()
+	return func() { close(s.running) }
+}
+
+// eventLogWriter - zerolog writer of the event log, entries below the level are dropped
+type eventLogWriter struct {
+	elog  *eventlog.Log
+	level zerolog.Level
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.InfoLevel, p)
+}
+
+func (w *eventLogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < w.level {
+		return len(p), nil
+	}
+	var buf bytes.Buffer
+	cw := zerolog.ConsoleWriter{Out: &buf, NoColor: true, TimeFormat: time.RFC3339}
+	if _, err := cw.Write(p); err != nil {
+		buf.Reset()
+		buf.Write(p)
+	}
+	msg := buf.String()
+	var err error
+	switch {
+	case level >= zerolog.ErrorLevel:
+		err = w.elog.Error(eventIDError, msg)
+	case level == zerolog.WarnLevel:
+		err = w.elog.Warning(eventIDWarning, msg)
+	default:
+		err = w.elog.Info(eventIDInfo, msg)
+	}
+	return len(p), err
+}
+
+// manageService - installs l3afd as an automatic start service with its event log source, or removes them
+func manageService(action, confPath string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	switch action {
+	case "install":
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to get the executable: %w", err)
+		}
+		if confPath, err = filepath.Abs(confPath); err != nil {
+			return fmt.Errorf("failed to get the config path: %w", err)
+		}
+		s, err := m.CreateService(daemonName, exe, mgr.Config{
+			DisplayName: "L3AF daemon",
+			Description: "Runs and supervises the eBPF network functions of the node",
+			StartType:   mgr.StartAutomatic,
+		}, "-config", confPath)
+		if err != nil {
+			return fmt.Errorf("failed to create the service: %w", err)
+		}
+		defer s.Close()
+		if err := eventlog.InstallAsEventCreate(daemonName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+			s.Delete()
+			return fmt.Errorf("failed to install the event log source: %w", err)
+		}
+		return nil
+	case "uninstall":
+		s, err := m.OpenService(daemonName)
+		if err != nil {
+			return fmt.Errorf("service %s is not installed: %w", daemonName, err)
+		}
+		defer s.Close()
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("failed to delete the service: %w", err)
+		}
+		if err := eventlog.Remove(daemonName); err != nil {
+			return fmt.Errorf("failed to remove the event log source: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown service action %q, expected install or uninstall", action)
+}
@@ -4,7 +4,9 @@
 package stats
 
 import (
-	"net/http"
+	"time"
+
+	"github.com/l3af-project/l3afd/config"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -19,9 +21,51 @@ var (
 	NFRunning     *prometheus.GaugeVec
 	NFStartTime   *prometheus.GaugeVec
 	NFMointorMap  *prometheus.GaugeVec
+
+	NFProcessRSS        *prometheus.GaugeVec
+	NFProcessCPUSeconds *prometheus.GaugeVec
+	NFProcessOpenFDs    *prometheus.GaugeVec
+	NFProcessThreads    *prometheus.GaugeVec
+	NFMapMemory         *prometheus.GaugeVec
+	NFChainLatency      *prometheus.GaugeVec
+	NFAFXDPStats        *prometheus.GaugeVec
+	NFVersionSkew       *prometheus.GaugeVec
+	NFDegraded          *prometheus.GaugeVec
+	NFReconcileRepairs  *prometheus.CounterVec
+	NFMonitorMapLag     *prometheus.GaugeVec
+	NFMonitorMapSkipped *prometheus.CounterVec
+	NFChainHits         *prometheus.GaugeVec
+	NFForcedKillCount   *prometheus.CounterVec
+	NFWatchdogTrips     *prometheus.CounterVec
+	NFHeartbeatFailures *prometheus.CounterVec
+	NFShadowSamples     *prometheus.CounterVec
+	NFShadowDivergence  *prometheus.CounterVec
+	NFQueueInstances    *prometheus.GaugeVec
+	NFQueueRestarts     *prometheus.CounterVec
+	NFQueueAFXDPStats   *prometheus.GaugeVec
+	NFTaskRuns          *prometheus.CounterVec
+	NFTaskDuration      *prometheus.GaugeVec
+
+	NFArtifactCacheInvalidations *prometheus.CounterVec
+	NFArtifactPeerDownloads      *prometheus.CounterVec
+
+	// Metrics of l3afd itself, the goroutines and the memory of l3afd are the go_* and process_* metrics
+	NFPhaseDuration          *prometheus.HistogramVec
+	ConfigApplyDuration      *prometheus.HistogramVec
+	ArtifactDownloadDuration *prometheus.HistogramVec
+	ArtifactDownloadBytes    *prometheus.CounterVec
+	KFRepoFailures           *prometheus.CounterVec
+	ChainRepairs             *prometheus.CounterVec
+	APIRequestDuration       *prometheus.HistogramVec
+
+	// exemplars - trace ids are attached to the metrics as exemplars
+	exemplars bool
 )
 
-func SetupMetrics(hostname, daemonName, metricsAddr string) {
+// TraceIDLabel - label of the trace id of the exemplars
+const TraceIDLabel = "trace_id"
+
+func SetupMetrics(hostname, daemonName string, conf *config.Config) {
 
 	nfStartCountVec := promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -101,14 +145,495 @@ func SetupMetrics(hostname, daemonName, metricsAddr string) {
 
 	NFMointorMap = nfMonitorMapVec.MustCurryWith(prometheus.Labels{"host": hostname})
 
+	nfProcessRSSVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFProcessRSSBytes",
+			Help:      "This value indicates resident memory size of the network function user process in bytes",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfProcessRSSVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFProcessRSSBytes metrics")
+	}
+
+	NFProcessRSS = nfProcessRSSVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfProcessCPUSecondsVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFProcessCPUSeconds",
+			Help:      "This value indicates user and system cpu time of the network function user process in seconds",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfProcessCPUSecondsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFProcessCPUSeconds metrics")
+	}
+
+	NFProcessCPUSeconds = nfProcessCPUSecondsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfProcessOpenFDsVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFProcessOpenFDs",
+			Help:      "This value indicates number of open file descriptors of the network function user process",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfProcessOpenFDsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFProcessOpenFDs metrics")
+	}
+
+	NFProcessOpenFDs = nfProcessOpenFDsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfProcessThreadsVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFProcessThreads",
+			Help:      "This value indicates number of threads of the network function user process",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfProcessThreadsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFProcessThreads metrics")
+	}
+
+	NFProcessThreads = nfProcessThreadsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfMapMemoryVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFMapMemoryBytes",
+			Help:      "This value indicates declared BPF map memory of the network function in bytes",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfMapMemoryVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFMapMemoryBytes metrics")
+	}
+
+	NFMapMemory = nfMapMemoryVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfChainLatencyVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFChainLatencySeconds",
+			Help:      "This value indicates chain verdict latency measured by the chain self test in seconds",
+		},
+		[]string{"host", "iface", "direction"},
+	)
+
+	if err := prometheus.Register(nfChainLatencyVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFChainLatencySeconds metrics")
+	}
+
+	NFChainLatency = nfChainLatencyVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfAFXDPStatsVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFAFXDPStats",
+			Help:      "This value indicates fill ring and rx/tx ring statistics of the network function AF_XDP sockets",
+		},
+		[]string{"host", "network_function", "stat"},
+	)
+
+	if err := prometheus.Register(nfAFXDPStatsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFAFXDPStats metrics")
+	}
+
+	NFAFXDPStats = nfAFXDPStatsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfVersionSkewVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFVersionSkew",
+			Help:      "This value indicates the running network function drifted from the configured artifact and version",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfVersionSkewVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFVersionSkew metrics")
+	}
+
+	NFVersionSkew = nfVersionSkewVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfDegradedVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFDegraded",
+			Help:      "This value indicates the crash looping network function is bypassed in the chain",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfDegradedVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFDegraded metrics")
+	}
+
+	NFDegraded = nfDegradedVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfReconcileRepairsVec := promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFReconcileRepairs",
+			Help:      "The count of network function drifts repaired by the reconciler",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	NFReconcileRepairs = nfReconcileRepairsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfMonitorMapLagVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFMonitorMapLagSeconds",
+			Help:      "This value indicates the delay of the network function monitor map sample behind its schedule",
+		},
+		[]string{"host", "network_function", "map_name"},
+	)
+
+	if err := prometheus.Register(nfMonitorMapLagVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFMonitorMapLagSeconds metrics")
+	}
+
+	NFMonitorMapLag = nfMonitorMapLagVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfMonitorMapSkippedVec := promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFMonitorMapSkipped",
+			Help:      "The count of network function monitor map samples skipped when the sampling overran its budget",
+		},
+		[]string{"host", "network_function", "map_name"},
+	)
+
+	NFMonitorMapSkipped = nfMonitorMapSkippedVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfChainHitsVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFChainHits",
+			Help:      "This value indicates packets processed by the chain position of the network function, read from the root program counters",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfChainHitsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFChainHits metrics")
+	}
+
+	NFChainHits = nfChainHitsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfForcedKillCountVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFForcedKillCount",
+			Help:      "The count of network functions killed after not exiting in the stop grace period",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfForcedKillCountVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFForcedKillCount metrics")
+	}
+
+	NFForcedKillCount = nfForcedKillCountVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfWatchdogTripsVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFWatchdogTrips",
+			Help:      "The count of resource thresholds of the network functions exceeded, by the exceeded threshold",
+		},
+		[]string{"host", "network_function", "direction", "reason"},
+	)
+
+	if err := prometheus.Register(nfWatchdogTripsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFWatchdogTrips metrics")
+	}
+
+	NFWatchdogTrips = nfWatchdogTripsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfHeartbeatFailuresVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFHeartbeatFailures",
+			Help:      "The count of heartbeats of l3afd failed to be written to the heartbeat maps of the network functions",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfHeartbeatFailuresVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFHeartbeatFailures metrics")
+	}
+
+	NFHeartbeatFailures = nfHeartbeatFailuresVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfShadowSamplesVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFShadowSamples",
+			Help:      "The count of packets run through the network functions and their candidate versions in shadow mode",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfShadowSamplesVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFShadowSamples metrics")
+	}
+
+	NFShadowSamples = nfShadowSamplesVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfShadowDivergenceVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFShadowDivergence",
+			Help:      "The count of packets the candidate versions in shadow mode returned a verdict different from the network functions for",
+		},
+		[]string{"host", "network_function", "direction"},
+	)
+
+	if err := prometheus.Register(nfShadowDivergenceVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFShadowDivergence metrics")
+	}
+
+	NFShadowDivergence = nfShadowDivergenceVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfQueueInstancesVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFQueueInstances",
+			Help:      "This value indicates the per-queue instance of the network function serving the RX queue is running",
+		},
+		[]string{"host", "network_function", "direction", "queue"},
+	)
+
+	if err := prometheus.Register(nfQueueInstancesVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFQueueInstances metrics")
+	}
+
+	NFQueueInstances = nfQueueInstancesVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfQueueRestartsVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFQueueRestarts",
+			Help:      "The count of restarts of the per-queue instances of the network functions found not running",
+		},
+		[]string{"host", "network_function", "direction", "queue"},
+	)
+
+	if err := prometheus.Register(nfQueueRestartsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFQueueRestarts metrics")
+	}
+
+	NFQueueRestarts = nfQueueRestartsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfQueueAFXDPStatsVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFQueueAFXDPStats",
+			Help:      "This value indicates fill ring and rx/tx ring statistics of the AF_XDP sockets of the per-queue instances of the network functions",
+		},
+		[]string{"host", "network_function", "queue", "stat"},
+	)
+
+	if err := prometheus.Register(nfQueueAFXDPStatsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFQueueAFXDPStats metrics")
+	}
+
+	NFQueueAFXDPStats = nfQueueAFXDPStatsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfTaskRunsVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFTaskRuns",
+			Help:      "The count of runs of the scheduled tasks of the network functions by result",
+		},
+		[]string{"host", "network_function", "direction", "task", "result"},
+	)
+
+	if err := prometheus.Register(nfTaskRunsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFTaskRuns metrics")
+	}
+
+	NFTaskRuns = nfTaskRunsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfTaskDurationVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: daemonName,
+			Name:      "NFTaskDuration",
+			Help:      "This value indicates the duration in seconds of the last run of the scheduled tasks of the network functions",
+		},
+		[]string{"host", "network_function", "direction", "task"},
+	)
+
+	if err := prometheus.Register(nfTaskDurationVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFTaskDuration metrics")
+	}
+
+	NFTaskDuration = nfTaskDurationVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfArtifactCacheInvalidationsVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFArtifactCacheInvalidations",
+			Help:      "The count of corrupted cached network function artifacts downloaded again",
+		},
+		[]string{"host", "network_function", "version"},
+	)
+
+	if err := prometheus.Register(nfArtifactCacheInvalidationsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFArtifactCacheInvalidations metrics")
+	}
+
+	NFArtifactCacheInvalidations = nfArtifactCacheInvalidationsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfArtifactPeerDownloadsVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "NFArtifactPeerDownloads",
+			Help:      "The count of network function artifacts downloaded from the peers of the site instead of the KF repo",
+		},
+		[]string{"host", "network_function", "version"},
+	)
+
+	if err := prometheus.Register(nfArtifactPeerDownloadsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFArtifactPeerDownloads metrics")
+	}
+
+	NFArtifactPeerDownloads = nfArtifactPeerDownloadsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	nfPhaseDurationVec := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: daemonName,
+			Name:      "NFPhaseDurationSeconds",
+			Help:      "The time taken by the phases of the network function starts and stops",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		},
+		[]string{"host", "network_function", "operation", "phase"},
+	)
+
+	if err := prometheus.Register(nfPhaseDurationVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register NFPhaseDurationSeconds metrics")
+	}
+
+	NFPhaseDuration = nfPhaseDurationVec.MustCurryWith(prometheus.Labels{"host": hostname}).(*prometheus.HistogramVec)
+
+	configApplyDurationVec := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: daemonName,
+			Name:      "ConfigApplyDurationSeconds",
+			Help:      "The time taken by the config applies",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"host", "result"},
+	)
+
+	if err := prometheus.Register(configApplyDurationVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register ConfigApplyDurationSeconds metrics")
+	}
+
+	ConfigApplyDuration = configApplyDurationVec.MustCurryWith(prometheus.Labels{"host": hostname}).(*prometheus.HistogramVec)
+
+	artifactDownloadDurationVec := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: daemonName,
+			Name:      "ArtifactDownloadDurationSeconds",
+			Help:      "The time taken by the artifact downloads from the KF repo or the peers",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"host", "network_function", "source"},
+	)
+
+	if err := prometheus.Register(artifactDownloadDurationVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register ArtifactDownloadDurationSeconds metrics")
+	}
+
+	ArtifactDownloadDuration = artifactDownloadDurationVec.MustCurryWith(prometheus.Labels{"host": hostname}).(*prometheus.HistogramVec)
+
+	artifactDownloadBytesVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "ArtifactDownloadBytes",
+			Help:      "The bytes of the artifacts downloaded from the KF repo or the peers",
+		},
+		[]string{"host", "network_function", "source"},
+	)
+
+	if err := prometheus.Register(artifactDownloadBytesVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register ArtifactDownloadBytes metrics")
+	}
+
+	ArtifactDownloadBytes = artifactDownloadBytesVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	kfRepoFailuresVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "KFRepoFailures",
+			Help:      "The count of artifact downloads failed by the KF repo or its mirrors",
+		},
+		[]string{"host", "repo"},
+	)
+
+	if err := prometheus.Register(kfRepoFailuresVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register KFRepoFailures metrics")
+	}
+
+	KFRepoFailures = kfRepoFailuresVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	chainRepairsVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: daemonName,
+			Name:      "ChainRepairs",
+			Help:      "The count of chains repaired by the reconciler",
+		},
+		[]string{"host", "iface", "direction"},
+	)
+
+	if err := prometheus.Register(chainRepairsVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register ChainRepairs metrics")
+	}
+
+	ChainRepairs = chainRepairsVec.MustCurryWith(prometheus.Labels{"host": hostname})
+
+	apiRequestDurationVec := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: daemonName,
+			Name:      "APIRequestDurationSeconds",
+			Help:      "The latency of the config API requests",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"host", "method", "route", "code"},
+	)
+
+	if err := prometheus.Register(apiRequestDurationVec); err != nil {
+		log.Warn().Err(err).Msg("Failed to register APIRequestDurationSeconds metrics")
+	}
+
+	APIRequestDuration = apiRequestDurationVec.MustCurryWith(prometheus.Labels{"host": hostname}).(*prometheus.HistogramVec)
+
 	// Prometheus handler
-	metricsHandler := promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{})
+	// exemplars are exported in OpenMetrics only
+	exemplars = conf.MetricsExemplars
+	metricsHandler := promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: conf.MetricsExemplars})
+
+	server, err := newMetricsServer(conf, metricsHandler)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed to configure prometheus metrics endpoint")
+	}
 
 	// Adding web endpoint
 	go func() {
-		// Expose the registered metrics via HTTP.
-		http.Handle("/metrics", metricsHandler)
-		if err := http.ListenAndServe(metricsAddr, nil); err != nil {
+		if err := serveMetrics(conf, server); err != nil {
 			log.Fatal().Err(err).Msgf("Failed to launch prometheus metrics endpoint")
 		}
 	}()
@@ -146,3 +671,104 @@ func SetValue(value float64, gaugeVec *prometheus.GaugeVec, networkFunction, map
 		nfGauge.Set(value)
 	}
 }
+
+// IncrWithExemplar - increments the counter with the trace id as the exemplar, without the exemplar when
+// the exemplars are disabled or the trace id is empty
+func IncrWithExemplar(counterVec *prometheus.CounterVec, traceID, networkFunction, direction string) {
+
+	if counterVec == nil {
+		log.Warn().Msg("Metrics: counter vector is nil and needs to be initialized before Incr")
+		return
+	}
+	nfCounter, err := counterVec.GetMetricWithLabelValues(networkFunction, direction)
+	if err != nil {
+		return
+	}
+	if adder, ok := nfCounter.(prometheus.ExemplarAdder); ok && exemplars && len(traceID) > 0 {
+		adder.AddWithExemplar(1, prometheus.Labels{TraceIDLabel: traceID})
+		return
+	}
+	nfCounter.Inc()
+}
+
+// Add - adds the value to the counter of the label values
+func Add(value float64, counterVec *prometheus.CounterVec, labelValues ...string) {
+
+	if counterVec == nil {
+		log.Warn().Msg("Metrics: counter vector is nil and needs to be initialized before Add")
+		return
+	}
+	if counter, err := counterVec.GetMetricWithLabelValues(labelValues...); err == nil {
+		counter.Add(value)
+	}
+}
+
+// SetGauge - sets the gauge of the label values
+func SetGauge(value float64, gaugeVec *prometheus.GaugeVec, labelValues ...string) {
+
+	if gaugeVec == nil {
+		log.Warn().Msg("Metrics: gauge vector is nil and needs to be initialized before SetGauge")
+		return
+	}
+	if gauge, err := gaugeVec.GetMetricWithLabelValues(labelValues...); err == nil {
+		gauge.Set(value)
+	}
+}
+
+// ResetNFCounters - resets the counters of the network function in the directions, the watchdog trips of the
+// reasons, the counters of all the network functions when the network function is empty. The gauges report the
+// current state of the programs and are not reset.
+func ResetNFCounters(networkFunction string, directions, watchdogReasons []string) {
+
+	counterVecs := []*prometheus.CounterVec{NFStartCount, NFStopCount, NFUpdateCount, NFReconcileRepairs,
+		NFMonitorMapSkipped, NFForcedKillCount, NFHeartbeatFailures, NFShadowSamples, NFShadowDivergence}
+	if len(networkFunction) == 0 {
+		for _, counterVec := range append(counterVecs, NFWatchdogTrips, NFQueueRestarts, NFTaskRuns) {
+			if counterVec != nil {
+				counterVec.Reset()
+			}
+		}
+		return
+	}
+	for _, direction := range directions {
+		for _, counterVec := range counterVecs {
+			if counterVec != nil {
+				counterVec.DeleteLabelValues(networkFunction, direction)
+			}
+		}
+		for _, reason := range watchdogReasons {
+			if NFWatchdogTrips != nil {
+				NFWatchdogTrips.DeleteLabelValues(networkFunction, direction, reason)
+			}
+		}
+	}
+}
+
+// ObserveWithExemplar - records the duration in seconds in the histogram of the label values with the trace
+// id as the exemplar, without the exemplar when the exemplars are disabled or the trace id is empty
+func ObserveWithExemplar(d time.Duration, histogramVec *prometheus.HistogramVec, traceID string, labelValues ...string) {
+
+	if histogramVec == nil {
+		return
+	}
+	observer, err := histogramVec.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		return
+	}
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && exemplars && len(traceID) > 0 {
+		eo.ObserveWithExemplar(d.Seconds(), prometheus.Labels{TraceIDLabel: traceID})
+		return
+	}
+	observer.Observe(d.Seconds())
+}
+
+// Observe - records the duration in seconds in the histogram of the label values
+func Observe(d time.Duration, histogramVec *prometheus.HistogramVec, labelValues ...string) {
+
+	if histogramVec == nil {
+		return
+	}
+	if observer, err := histogramVec.GetMetricWithLabelValues(labelValues...); err == nil {
+		observer.Observe(d.Seconds())
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright Contributors to the L3AF Project.
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/l3af-project/l3afd/config"
+	"github.com/l3af-project/l3afd/routes"
+)
+
+// newMetricsServer - metrics listener, independent of the config API listener with its own allowlist,
+// TLS and basic auth
+func newMetricsServer(conf *config.Config, metricsHandler http.Handler) (*http.Server, error) {
+	// Expose the registered metrics via HTTP, on its own mux so the debug API is not served by this listener.
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler)
+
+	var handler http.Handler = mux
+	if len(conf.MetricsBasicAuthUsername) > 0 {
+		if len(conf.MetricsBasicAuthPasswordFile) == 0 {
+			return nil, fmt.Errorf("metrics basic auth password file is not set")
+		}
+		password, err := os.ReadFile(conf.MetricsBasicAuthPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metrics basic auth password file: %w", err)
+		}
+		handler = routes.BasicAuth("l3afd metrics", conf.MetricsBasicAuthUsername, string(bytes.TrimSpace(password)), handler)
+	}
+
+	server := &http.Server{
+		Addr:    conf.MetricsAddr,
+		Handler: routes.AllowCIDRs(conf.MetricsAllowedCIDRs, handler),
+	}
+	if !conf.MetricsTLSEnabled {
+		return server, nil
+	}
+
+	if len(conf.MetricsTLSCertFile) == 0 || len(conf.MetricsTLSKeyFile) == 0 {
+		return nil, fmt.Errorf("metrics TLS cert or key file is not set")
+	}
+	server.TLSConfig = &tls.Config{MinVersion: conf.MetricsTLSMinVersion}
+	if len(conf.MetricsTLSClientCAFile) > 0 {
+		caCert, err := os.ReadFile(conf.MetricsTLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metrics client CA file: %w", err)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in metrics client CA file %s", conf.MetricsTLSClientCAFile)
+		}
+		server.TLSConfig.ClientCAs = caCertPool
+		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return server, nil
+}
+
+// serveMetrics - serves the metrics with TLS when enabled
+func serveMetrics(conf *config.Config, server *http.Server) error {
+	if conf.MetricsTLSEnabled {
+		return server.ListenAndServeTLS(conf.MetricsTLSCertFile, conf.MetricsTLSKeyFile)
+	}
+	return server.ListenAndServe()
+}